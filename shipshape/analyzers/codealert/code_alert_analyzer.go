@@ -51,6 +51,9 @@ type CodeAlertAnalyzer struct {
 
 func (CodeAlertAnalyzer) Category() string { return "CodeAlert" }
 
+// Processes is true for all files, since code alerts can be in any of them.
+func (CodeAlertAnalyzer) Processes(path string) bool { return true }
+
 // TODO(emso): Use file filter in code alert
 func (a CodeAlertAnalyzer) Analyze(ctx *ctxpb.ShipshapeContext) ([]*notepb.Note, error) {
 	var notes []*notepb.Note
@@ -30,6 +30,7 @@ import (
 
 	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
 	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
 	rangepb "github.com/google/shipshape/shipshape/proto/textrange_proto"
 )
 
@@ -49,6 +50,24 @@ type GoVetAnalyzer struct{}
 
 func (GoVetAnalyzer) Category() string { return "go vet" }
 
+// Documentation explains the notes of go vet. It has no subcategories.
+func (GoVetAnalyzer) Documentation(subcategory string) (*rpcpb.Documentation, error) {
+	if subcategory != "" {
+		return nil, fmt.Errorf("go vet has no subcategory %q", subcategory)
+	}
+	return &rpcpb.Documentation{
+		Description: proto.String("Runs go vet, which reports suspicious constructs in Go code, such as Printf calls whose arguments do not match the format string, unreachable code, and copied locks."),
+		Rationale:   proto.String("The constructs that go vet reports compile, but are almost always bugs. It has few false positives, so its notes are worth fixing."),
+		Example: []string{
+			"fmt.Printf(\"%d files\\n\", name) // name is a string: use %s, or pass the count",
+		},
+		Url: proto.String("https://golang.org/cmd/vet/"),
+	}, nil
+}
+
+// Processes is true for Go files.
+func (GoVetAnalyzer) Processes(path string) bool { return isGoFile(path) }
+
 func isGoFile(path string) bool {
 	return filepath.Ext(path) == ".go"
 }
@@ -43,11 +43,18 @@ var (
 // JSHintAnalyzer is a wrapper around a the jshint command line tool.
 // This assumes it runs in a location where jshint is on the path.
 // It can run on JS and HTML files. Right now, it just puts the file
-// contents into stdin and uses no configuration
-type JSHintAnalyzer struct{}
+// contents into stdin.
+type JSHintAnalyzer struct {
+	// Config is the jshint configuration to use. If empty, jshint finds
+	// one in the repository as usual.
+	Config string
+}
 
 func (JSHintAnalyzer) Category() string { return "JSHint" }
 
+// Processes is true for the files that JSHint can check.
+func (JSHintAnalyzer) Processes(path string) bool { return isJSHintFile(path) }
+
 func isJSHintFile(path string) bool {
 	switch filepath.Ext(path) {
 	// TODO(ciera): we can handle .html ONLY if we pull out the
@@ -68,7 +75,11 @@ func (jsa *JSHintAnalyzer) Analyze(ctx *ctxpb.ShipshapeContext) ([]*notepb.Note,
 			continue
 		}
 
-		cmd := exec.Command("jshint", path)
+		args := []string{path}
+		if jsa.Config != "" {
+			args = append([]string{"--config", jsa.Config}, args...)
+		}
+		cmd := exec.Command("jshint", args...)
 		buf, err := cmd.CombinedOutput()
 
 		switch err := err.(type) {
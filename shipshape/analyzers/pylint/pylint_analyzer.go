@@ -34,6 +34,9 @@ import (
 const (
 	usageError   = "exit status 32"
 	modulePrefix = "************* Module"
+	// docsURL is the pylint documentation page for a message, formatted with
+	// the message's category (e.g. "convention") and symbolic name.
+	docsURL = "https://pylint.readthedocs.io/en/latest/user_guide/messages/%s/%s.html"
 )
 
 var ()
@@ -43,21 +46,32 @@ var ()
 // to run on.
 // After running, it will convert findings to the original directory
 // structure
-type PyLintAnalyzer struct{}
+type PyLintAnalyzer struct {
+	// RCFile is the pylint configuration to use. If empty, pylint finds
+	// one in the repository as usual.
+	RCFile string
+}
 
 func (PyLintAnalyzer) Category() string { return "PyLint" }
 
+// Processes is true for Python files.
+func (PyLintAnalyzer) Processes(path string) bool { return filepath.Ext(path) == ".py" }
+
 func (pya *PyLintAnalyzer) Analyze(ctx *ctxpb.ShipshapeContext) ([]*notepb.Note, error) {
 	var notes []*notepb.Note
 	// Call pylint on the files
 	pythonFiles := extractPyFiles(ctx.FilePath)
 	for _, pyFile := range pythonFiles {
-		cmd := exec.Command("pylint",
+		args := []string{
 			// TODO(ciera): get the python path
 			//"--init-hook='import sys; sys.path.append(" + pythonpath + ")'",
-			"--msg-template='{path}:::{line}:::{msg}'",
+			"--msg-template='{path}:::{line}:::{category}:::{symbol}:::{msg}'",
 			"--reports=no",
-			pyFile)
+		}
+		if pya.RCFile != "" {
+			args = append(args, "--rcfile="+pya.RCFile)
+		}
+		cmd := exec.Command("pylint", append(args, pyFile)...)
 		buf, err := cmd.CombinedOutput()
 
 		switch err := err.(type) {
@@ -85,9 +99,9 @@ func (pya *PyLintAnalyzer) Analyze(ctx *ctxpb.ShipshapeContext) ([]*notepb.Note,
 					continue
 				}
 
-				parts := strings.Split(issue, ":::")
+				parts := strings.SplitN(issue, ":::", 5)
 
-				if len(parts) != 3 {
+				if len(parts) != 5 {
 					return notes, fmt.Errorf("Found ill-formated issue: %s", issue)
 				}
 
@@ -99,7 +113,8 @@ func (pya *PyLintAnalyzer) Analyze(ctx *ctxpb.ShipshapeContext) ([]*notepb.Note,
 
 				notes = append(notes, &notepb.Note{
 					Category:    proto.String(pya.Category()),
-					Description: proto.String(strings.TrimSpace(parts[2])),
+					Description: proto.String(strings.TrimSpace(parts[4])),
+					Url:         proto.String(fmt.Sprintf(docsURL, parts[2], parts[3])),
 					Location: &notepb.Location{
 						SourceContext: ctx.SourceContext,
 						Path:          proto.String(parts[0]),
@@ -32,6 +32,9 @@ type WordCountAnalyzer struct {
 
 func (WordCountAnalyzer) Category() string { return "WordCount" }
 
+// Processes is true for all files.
+func (WordCountAnalyzer) Processes(path string) bool { return true }
+
 func (p WordCountAnalyzer) Analyze(ctx *ctxpb.ShipshapeContext) ([]*notepb.Note, error) {
 	var notes []*notepb.Note
 	notes = make([]*notepb.Note, len(ctx.FilePath))
@@ -20,6 +20,7 @@ package api
 import (
 	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
 	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
 )
 
 // An Analyzer provides the shipshape service with the functionality to run analysis
@@ -38,3 +39,21 @@ type Analyzer interface {
 	// that case.
 	Analyze(*ctxpb.ShipshapeContext) ([]*notepb.Note, error)
 }
+
+// A Documenter is an Analyzer that can explain its notes to the developers who
+// read them, through `shipshape explain`. Analyzers do not need to implement it.
+type Documenter interface {
+	// Documentation describes the notes of the analyzer's category, or of
+	// subcategory if it is not empty. It returns an error if there is no
+	// documentation for subcategory.
+	Documentation(subcategory string) (*rpcpb.Documentation, error)
+}
+
+// A FileSelector is an Analyzer that says which files it processes, so that
+// files that no analyzer processed can be reported. Analyzers do not need to
+// implement it.
+type FileSelector interface {
+	// Processes is true if the analyzer analyzes the file at path, which is
+	// relative to the repo root, when it is in the ShipshapeContext.
+	Processes(path string) bool
+}
@@ -17,6 +17,7 @@
 package api
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/golang/protobuf/proto"
@@ -54,10 +55,12 @@ func (s analyzerService) Analyze(ctx server.Context, in *rpcpb.AnalyzeRequest) (
 	log.Print("starting analyzing")
 	var nts []*notepb.Note
 	var errs []*rpcpb.AnalysisFailure
+	var coverage []*rpcpb.AnalysisCoverage
 
 	defer func() {
 		resp.Note = nts
 		resp.Failure = errs
+		resp.Coverage = coverage
 	}()
 
 	orgDir, restore, err := file.ChangeDir(*in.ShipshapeContext.RepoRoot)
@@ -75,7 +78,12 @@ func (s analyzerService) Analyze(ctx server.Context, in *rpcpb.AnalyzeRequest) (
 	reqCats := strset.New(in.Category...)
 	for _, a := range s.analyzers {
 		if reqCats.Contains(a.Category()) {
+			failures := len(errs)
 			runAnalyzer(a, in.ShipshapeContext, &nts, &errs)
+			// An analyzer that failed may not have processed its files.
+			if fs, ok := a.(FileSelector); ok && len(errs) == failures {
+				coverage = append(coverage, selectFiles(a.Category(), fs, in.ShipshapeContext.FilePath))
+			}
 		}
 	}
 	log.Printf("finished analyzing, sending back %d notes and %d errors", len(nts), len(errs))
@@ -99,6 +107,32 @@ func (s analyzerService) GetStage(ctx server.Context, in *rpcpb.GetStageRequest)
 	return &rpcpb.GetStageResponse{Stage: s.stage.Enum()}, nil
 }
 
+// GetDocumentation returns the documentation of the analyzer for the requested category,
+// if it is a Documenter.
+func (s analyzerService) GetDocumentation(ctx server.Context, in *rpcpb.GetDocumentationRequest) (*rpcpb.GetDocumentationResponse, error) {
+	for _, a := range s.analyzers {
+		if a.Category() != in.GetCategory() {
+			continue
+		}
+		d, ok := a.(Documenter)
+		if !ok {
+			return nil, fmt.Errorf("analyzer for %s has no documentation", in.GetCategory())
+		}
+		doc, err := d.Documentation(in.GetSubcategory())
+		if err != nil {
+			return nil, err
+		}
+		if doc.Category == nil {
+			doc.Category = proto.String(in.GetCategory())
+		}
+		if doc.Subcategory == nil && in.Subcategory != nil {
+			doc.Subcategory = proto.String(in.GetSubcategory())
+		}
+		return &rpcpb.GetDocumentationResponse{Documentation: doc}, nil
+	}
+	return nil, fmt.Errorf("no analyzer for category %s", in.GetCategory())
+}
+
 // runAnalyzer attempts to run the given analyzer on the provided context. It returns the list of notes
 // and errors that occured in the process.
 func runAnalyzer(analyzer Analyzer, ctx *ctxpb.ShipshapeContext, nts *[]*notepb.Note, errs *[]*rpcpb.AnalysisFailure) {
@@ -112,6 +146,17 @@ func runAnalyzer(analyzer Analyzer, ctx *ctxpb.ShipshapeContext, nts *[]*notepb.
 	*nts = append(*nts, notes...)
 }
 
+// selectFiles records which of the files the analyzer for category processes.
+func selectFiles(category string, fs FileSelector, files []string) *rpcpb.AnalysisCoverage {
+	coverage := &rpcpb.AnalysisCoverage{Category: proto.String(category)}
+	for _, f := range files {
+		if fs.Processes(f) {
+			coverage.File = append(coverage.File, f)
+		}
+	}
+	return coverage
+}
+
 // appendFailure adds a new analysis failure to the list in errs
 func appendFailure(errs *[]*rpcpb.AnalysisFailure, cat string, err error) {
 	*errs = append(*errs, &rpcpb.AnalysisFailure{
@@ -17,8 +17,13 @@
 package api
 
 import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/google/shipshape/shipshape/util/strings"
 
 	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
@@ -64,4 +69,74 @@ func TestGetCategory(t *testing.T) {
 	}
 }
 
+type documentedAnalyzer struct {
+	fakeAnalyzer
+}
+
+func (d documentedAnalyzer) Documentation(subcategory string) (*rpcpb.Documentation, error) {
+	if subcategory != "" && subcategory != "Sub" {
+		return nil, errors.New("no such subcategory")
+	}
+	return &rpcpb.Documentation{Description: proto.String("Finds foos")}, nil
+}
+
+func TestGetDocumentation(t *testing.T) {
+	a := CreateAnalyzerService([]Analyzer{
+		fakeAnalyzer{"Undocumented", nil, nil},
+		documentedAnalyzer{fakeAnalyzer{"Foo", nil, nil}},
+	}, ctxpb.Stage_PRE_BUILD)
+
+	resp, err := a.GetDocumentation(nil, &rpcpb.GetDocumentationRequest{Category: proto.String("Foo"), Subcategory: proto.String("Sub")})
+	if err != nil {
+		t.Fatalf("GetDocumentation failed: %v", err)
+	}
+	doc := resp.GetDocumentation()
+	if doc.GetCategory() != "Foo" || doc.GetSubcategory() != "Sub" || doc.GetDescription() != "Finds foos" {
+		t.Errorf("Wrong documentation: got %v", doc)
+	}
+
+	for _, in := range []*rpcpb.GetDocumentationRequest{
+		{Category: proto.String("Undocumented")},
+		{Category: proto.String("Missing")},
+		{Category: proto.String("Foo"), Subcategory: proto.String("Other")},
+	} {
+		if _, err := a.GetDocumentation(nil, in); err == nil {
+			t.Errorf("Expected an error for %v", in)
+		}
+	}
+}
+
+type selectiveAnalyzer struct {
+	fakeAnalyzer
+	ext string
+}
+
+func (s selectiveAnalyzer) Processes(path string) bool { return filepath.Ext(path) == s.ext }
+
+func TestAnalyzeCoverage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dispatcher_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := CreateAnalyzerService([]Analyzer{
+		fakeAnalyzer{"Unselective", nil, nil},
+		selectiveAnalyzer{fakeAnalyzer{"Go", nil, nil}, ".go"},
+		selectiveAnalyzer{fakeAnalyzer{"Broken", nil, errors.New("boom")}, ".go"},
+		selectiveAnalyzer{fakeAnalyzer{"Unrequested", nil, nil}, ".go"},
+	}, ctxpb.Stage_PRE_BUILD)
+	resp, err := a.Analyze(nil, &rpcpb.AnalyzeRequest{
+		ShipshapeContext: &ctxpb.ShipshapeContext{RepoRoot: proto.String(dir), FilePath: []string{"a.go", "b.py", "c/d.go"}},
+		Category:         []string{"Unselective", "Go", "Broken"},
+	})
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	want := []*rpcpb.AnalysisCoverage{{Category: proto.String("Go"), File: []string{"a.go", "c/d.go"}}}
+	if len(resp.Coverage) != len(want) || !proto.Equal(resp.Coverage[0], want[0]) {
+		t.Errorf("Wrong coverage: got %v, want %v", resp.Coverage, want)
+	}
+}
+
 // TODO(ciera): test analyze!
@@ -0,0 +1,94 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/shipshape/shipshape/util/docker"
+	"github.com/google/shipshape/shipshape/util/redact"
+	glog "github.com/google/shipshape/third_party/go-glog"
+)
+
+// prefixWriter writes the lines written to it to w, each prefixed with prefix and
+// with any registered secrets redacted. Lines are written whole while holding mu,
+// so that the lines of writers that share w and mu are interleaved but not mixed.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		nl := bytes.IndexByte(p.buf, '\n')
+		if nl < 0 {
+			return len(b), nil
+		}
+		p.writeLine(p.buf[:nl])
+		p.buf = p.buf[nl+1:]
+	}
+}
+
+// Flush writes what is left of an unfinished last line.
+func (p *prefixWriter) Flush() {
+	if len(p.buf) > 0 {
+		p.writeLine(p.buf)
+		p.buf = nil
+	}
+}
+
+func (p *prefixWriter) writeLine(line []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	io.WriteString(p.w, p.prefix+redact.String(string(line))+"\n")
+}
+
+// streamAnalyzerOutput streams what the containers have written since since, and
+// write while they run, to AnalyzerOutput, and returns a function that stops it.
+// Each line is prefixed with the name of its container.
+func (i *Invocation) streamAnalyzerOutput(containers []string, since time.Time) func() {
+	var mu sync.Mutex
+	var stops []func()
+	var writers []*prefixWriter
+	for _, container := range containers {
+		w := &prefixWriter{mu: &mu, w: i.options.AnalyzerOutput, prefix: "[" + container + "] "}
+		stop, err := docker.FollowLogs(container, since, w, w)
+		if err != nil {
+			glog.Errorf("Could not stream the output of %s: %v", container, err)
+			continue
+		}
+		stops = append(stops, stop)
+		writers = append(writers, w)
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			for _, stop := range stops {
+				stop()
+			}
+			for _, w := range writers {
+				w.Flush()
+			}
+		})
+	}
+}
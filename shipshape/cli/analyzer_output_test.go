@@ -0,0 +1,45 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/shipshape/shipshape/util/redact"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	redact.Register("hunter2")
+	var out bytes.Buffer
+	var mu sync.Mutex
+	linter := &prefixWriter{mu: &mu, w: &out, prefix: "[linter_0] "}
+	scanner := &prefixWriter{mu: &mu, w: &out, prefix: "[scanner_1] "}
+
+	linter.Write([]byte("Loading ru"))
+	scanner.Write([]byte("Scanning 3 files\n"))
+	linter.Write([]byte("les\nUsing key hunter2\nDone"))
+	if got, want := out.String(), "[scanner_1] Scanning 3 files\n[linter_0] Loading rules\n[linter_0] Using key <redacted>\n"; got != want {
+		t.Errorf("Wrong output before flushing: got %q, want %q", got, want)
+	}
+	linter.Flush()
+	if got, want := out.String(), "[linter_0] Done\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("Unfinished line not written by Flush: got %q, want it to end with %q", got, want)
+	}
+}
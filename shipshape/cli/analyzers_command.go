@@ -0,0 +1,98 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/shipshape/shipshape/cli"
+	"github.com/google/shipshape/shipshape/service"
+)
+
+// analyzersCommand implements `shipshape analyzers search|add`, which finds
+// vetted third-party analyzers in a manifest and adds them to the
+// .shipshape file.
+func analyzersCommand(args []string) int {
+	fs := flag.NewFlagSet("analyzers", flag.ExitOnError)
+	dir := fs.String("dir", ".", "The directory whose .shipshape file is read, and edited by add")
+	manifest := fs.String("manifest", "", "URL or path of the analyzer manifest. If empty, the manifest in the global section of the .shipshape file is used.")
+	fs.Usage = func() {
+		fmt.Println("USAGE: shipshape analyzers [flags] search [<query>]")
+		fmt.Println("       shipshape analyzers [flags] add <name>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 || (fs.Arg(0) == "add" && fs.NArg() != 2) {
+		fs.Usage()
+		return returnError
+	}
+
+	location := *manifest
+	if location == "" {
+		var err error
+		if location, err = service.ManifestURL(*dir); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return returnError
+		}
+		if location == "" {
+			fmt.Printf("Error: no analyzer manifest: pass --manifest or set global.manifest in %s\n", filepath.Join(*dir, ".shipshape"))
+			return returnError
+		}
+	}
+	m, err := cli.LoadManifest(location)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+
+	switch fs.Arg(0) {
+	case "search":
+		found := m.Search(fs.Arg(1))
+		if len(found) == 0 {
+			fmt.Println("No matching analyzers")
+			return returnNoFindings
+		}
+		for _, e := range found {
+			fmt.Printf("%s: %s\n", e.Name, e.Description)
+			fmt.Printf("  Image: %s\n", e.Ref())
+			if len(e.Categories) > 0 {
+				fmt.Printf("  Categories: %s\n", strings.Join(e.Categories, ", "))
+			}
+		}
+	case "add":
+		e := m.Find(fs.Arg(1))
+		if e == nil {
+			fmt.Printf("Error: there is no analyzer called %q in %s\n", fs.Arg(1), location)
+			return returnError
+		}
+		if err := cli.AddAnalyzer(*dir, *e); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return returnError
+		}
+		fmt.Printf("Added %s to %s\n", e.Ref(), filepath.Join(*dir, ".shipshape"))
+		if len(e.Categories) > 0 {
+			fmt.Printf("Add its categories to the events that should run them: %s\n", strings.Join(e.Categories, ", "))
+		}
+	default:
+		fs.Usage()
+		return returnError
+	}
+	return returnNoFindings
+}
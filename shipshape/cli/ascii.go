@@ -0,0 +1,88 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+)
+
+// asciiReplacements are the ASCII spellings of the characters that ASCII
+// transliterates, other than letters with diacritics.
+var asciiReplacements = map[rune]string{
+	'‘': "'", '’': "'", '‚': "'", '′': "'",
+	'“': `"`, '”': `"`, '„': `"`, '″': `"`, '«': `"`, '»': `"`,
+	'‐': "-", '‒': "-", '–': "-", '—': "--", '−': "-",
+	'…': "...", '•': "*", '·': "*",
+	'→': "->", '←': "<-", '⇒': "=>", '≤': "<=", '≥': ">=", '≠': "!=", '×': "x",
+	'✓': "v", '✔': "v", '✗': "x", '✘': "x",
+	'©': "(c)", '®': "(R)", '™': "(TM)",
+	'\u00a0': " ", '\u200b': "",
+	'ä': "ae", 'ö': "oe", 'ü': "ue", 'Ä': "Ae", 'Ö': "Oe", 'Ü': "Ue",
+	'ß': "ss", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE",
+}
+
+// asciiLetters maps letters with diacritics to the letters without them.
+var asciiLetters = map[string]string{
+	"ÀÁÂÃÅĀĂĄ": "A", "àáâãåāăą": "a",
+	"ÇĆČ": "C", "çćč": "c",
+	"ÈÉÊËĒĘĚ": "E", "èéêëēęě": "e",
+	"ÌÍÎÏ": "I", "ìíîïı": "i",
+	"Ł": "L", "ł": "l",
+	"ŃÑŇ": "N", "ńñň": "n",
+	"ÒÓÔÕØŌŐ": "O", "òóôõøōő": "o",
+	"Ř": "R", "ř": "r",
+	"ŚŠ": "S", "śš": "s",
+	"ÙÚÛŪŮŰ": "U", "ùúûūůű": "u",
+	"ÝŸ": "Y", "ýÿ": "y",
+	"ŹŻŽ": "Z", "źżž": "z",
+}
+
+func init() {
+	for letters, ascii := range asciiLetters {
+		for _, r := range letters {
+			if _, ok := asciiReplacements[r]; !ok {
+				asciiReplacements[r] = ascii
+			}
+		}
+	}
+}
+
+// ASCII transliterates s to ASCII, for terminals and screen readers that
+// cannot show other characters: typographic quotes, dashes and arrows are
+// spelled out, diacritics are dropped, and any other character becomes a ?.
+func ASCII(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	var b bytes.Buffer
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			b.WriteRune(r)
+		} else if rep, ok := asciiReplacements[r]; ok {
+			b.WriteString(rep)
+		} else {
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+func isASCII(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool { return r >= utf8.RuneSelf }) < 0
+}
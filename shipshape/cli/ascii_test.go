@@ -0,0 +1,36 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import "testing"
+
+func TestASCII(t *testing.T) {
+	tests := []struct {
+		s, want string
+	}{
+		{"plain text", "plain text"},
+		{"Use “is” instead of ‘==’ — see the docs…", `Use "is" instead of '==' -- see the docs...`},
+		{"a → b, x ≤ 3", "a -> b, x <= 3"},
+		{"Größe ändern, café, Łódź", "Groesse aendern, cafe, Lodz"},
+		{"emoji 🚀 and 漢字", "emoji ? and ??"},
+	}
+	for _, test := range tests {
+		if got := ASCII(test.s); got != test.want {
+			t.Errorf("ASCII(%q) = %q, want %q", test.s, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+const (
+	// StatementType is the type of in-toto statements.
+	StatementType = "https://in-toto.io/Statement/v0.1"
+	// AttestationPredicateType identifies the predicate of the statements
+	// written by shipshape, which is an AnalysisPredicate.
+	AttestationPredicateType = "https://github.com/google/shipshape/attestation/analysis/v0.1"
+	// AttestationPayloadType is the payload type of the envelopes that
+	// statements are signed in.
+	AttestationPayloadType = "application/vnd.in-toto+json"
+)
+
+// Statement is an in-toto statement that its subjects were analyzed by
+// shipshape.
+type Statement struct {
+	Type          string            `json:"_type"`
+	Subject       []Subject         `json:"subject"`
+	PredicateType string            `json:"predicateType"`
+	Predicate     AnalysisPredicate `json:"predicate"`
+}
+
+// Subject is an artifact that a statement is about, identified by its
+// digests, e.g. {"gitCommit": "3f2a1c..."}.
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// AttestedImage is an image used by the analysis.
+type AttestedImage struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// AnalysisPredicate describes how the subjects were analyzed, and what the
+// analysis found.
+type AnalysisPredicate struct {
+	Images     []AttestedImage `json:"images"`
+	Event      string          `json:"event"`
+	Categories []string        `json:"categories"`
+	// ConfigDigest is the digest of the .shipshape file, if there was one.
+	ConfigDigest map[string]string `json:"configDigest,omitempty"`
+	NumNotes     int               `json:"numNotes"`
+	// NotesDigest is the digest of the sorted fingerprints of the notes, so
+	// that the notes can be checked against a results file.
+	NotesDigest map[string]string `json:"notesDigest"`
+	// FailedCategories are the categories whose analyzers failed to run.
+	FailedCategories []string `json:"failedCategories,omitempty"`
+	Incomplete       string   `json:"incomplete,omitempty"`
+	// Dirty is whether the work tree had uncommitted changes, in which case
+	// what was analyzed is not exactly the subject commit.
+	Dirty      bool      `json:"dirty,omitempty"`
+	RunID      string    `json:"runId,omitempty"`
+	StartedOn  time.Time `json:"startedOn"`
+	FinishedOn time.Time `json:"finishedOn"`
+}
+
+// AttestedRun is what is known about a run when it is attested.
+type AttestedRun struct {
+	// Repository names the subject, e.g. the remote URL of the repository
+	// or the analyzed directory.
+	Repository string
+	Commit     string
+	// Dirty is whether the work tree had changes that are not in Commit.
+	Dirty bool
+	// Images maps the images used to their digests, such as sha256:....
+	Images     map[string]string
+	Event      string
+	Categories []string
+	// Config is the content of the .shipshape file, or nil if there was none.
+	Config     []byte
+	RunID      string
+	StartedOn  time.Time
+	FinishedOn time.Time
+}
+
+// NewStatement builds the statement that run was analyzed with resp as the
+// results. Images whose digest is unknown are left out, since they cannot be
+// verified.
+func NewStatement(run AttestedRun, resp *rpcpb.ShipshapeResponse) *Statement {
+	p := AnalysisPredicate{
+		Event:       run.Event,
+		Categories:  append([]string(nil), run.Categories...),
+		NotesDigest: map[string]string{"sha256": NotesDigest(resp)},
+		NumNotes:    len(AllNotes(resp)),
+		RunID:       run.RunID,
+		StartedOn:   run.StartedOn.UTC(),
+		FinishedOn:  run.FinishedOn.UTC(),
+	}
+	sort.Strings(p.Categories)
+	for name, digest := range run.Images {
+		if algorithm, value, ok := splitDigest(digest); ok {
+			p.Images = append(p.Images, AttestedImage{name, map[string]string{algorithm: value}})
+		}
+	}
+	sort.Sort(byImageName(p.Images))
+	if run.Config != nil {
+		sum := sha256.Sum256(run.Config)
+		p.ConfigDigest = map[string]string{"sha256": hex.EncodeToString(sum[:])}
+	}
+	for _, analysis := range resp.AnalyzeResponse {
+		for _, f := range analysis.Failure {
+			p.FailedCategories = append(p.FailedCategories, f.GetCategory())
+		}
+	}
+	sort.Strings(p.FailedCategories)
+	if inc := resp.Incomplete; inc != nil {
+		p.Incomplete = inc.GetReason()
+	}
+	s := &Statement{
+		Type:          StatementType,
+		PredicateType: AttestationPredicateType,
+		Predicate:     p,
+	}
+	if run.Commit != "" {
+		s.Subject = []Subject{{run.Repository, map[string]string{"gitCommit": run.Commit}}}
+		s.Predicate.Dirty = run.Dirty
+	}
+	return s
+}
+
+// NotesDigest returns the hex SHA-256 of the sorted fingerprints of the notes
+// in resp, one per line.
+func NotesDigest(resp *rpcpb.ShipshapeResponse) string {
+	var fingerprints []string
+	for _, note := range AllNotes(resp) {
+		fingerprints = append(fingerprints, Fingerprint(note)+"\n")
+	}
+	sort.Strings(fingerprints)
+	sum := sha256.Sum256([]byte(strings.Join(fingerprints, "")))
+	return hex.EncodeToString(sum[:])
+}
+
+// splitDigest splits a digest such as sha256:abc into its algorithm and value.
+func splitDigest(digest string) (string, string, bool) {
+	i := strings.Index(digest, ":")
+	if i <= 0 || i == len(digest)-1 {
+		return "", "", false
+	}
+	return digest[:i], digest[i+1:], true
+}
+
+type byImageName []AttestedImage
+
+func (a byImageName) Len() int           { return len(a) }
+func (a byImageName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byImageName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+
+// Envelope is a DSSE envelope holding a signed statement.
+type Envelope struct {
+	PayloadType string      `json:"payloadType"`
+	Payload     string      `json:"payload"`
+	Signatures  []Signature `json:"signatures"`
+}
+
+// Signature is the signature of an envelope's payload. KeyID is the hex
+// SHA-256 of the signing key's public key in PKIX form.
+type Signature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+// LoadSigningKey reads a PEM encoded ECDSA or RSA private key, which may be in
+// PKCS #8 form.
+func LoadSigningKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	var key interface{}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse signing key %s: %v", path, err)
+	}
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		return k, nil
+	case *rsa.PrivateKey:
+		return k, nil
+	}
+	return nil, fmt.Errorf("unsupported signing key %s: only ECDSA and RSA keys are supported", path)
+}
+
+// LoadVerifyingKey reads a PEM encoded public key in PKIX form.
+func LoadVerifyingKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse public key %s: %v", path, err)
+	}
+	return key, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read key: %v", err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", path)
+	}
+	return block, nil
+}
+
+// Sign signs s with key and returns it in an envelope.
+func Sign(s *Statement, key crypto.Signer) (*Envelope, error) {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := KeyID(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(pae(AttestationPayloadType, payload))
+	sig, err := key.Sign(rand.Reader, sum[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("could not sign attestation: %v", err)
+	}
+	return &Envelope{
+		PayloadType: AttestationPayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures:  []Signature{{keyID, base64.StdEncoding.EncodeToString(sig)}},
+	}, nil
+}
+
+// Verify checks that env was signed with the private key of pub, and returns
+// the statement it holds.
+func Verify(env *Envelope, pub crypto.PublicKey) (*Statement, error) {
+	if env.PayloadType != AttestationPayloadType {
+		return nil, fmt.Errorf("unexpected payload type %q", env.PayloadType)
+	}
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode payload: %v", err)
+	}
+	keyID, err := KeyID(pub)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(pae(env.PayloadType, payload))
+	verified := false
+	for _, s := range env.Signatures {
+		if s.KeyID != "" && s.KeyID != keyID {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err != nil {
+			continue
+		}
+		switch k := pub.(type) {
+		case *ecdsa.PublicKey:
+			// ECDSA signatures are the ASN.1 sequence of r and s.
+			var rs struct{ R, S *big.Int }
+			if rest, err := asn1.Unmarshal(sig, &rs); err == nil && len(rest) == 0 {
+				verified = ecdsa.Verify(k, sum[:], rs.R, rs.S)
+			}
+		case *rsa.PublicKey:
+			verified = rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig) == nil
+		default:
+			return nil, fmt.Errorf("unsupported public key type %T", pub)
+		}
+		if verified {
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("no valid signature by key %s", keyID)
+	}
+	var s Statement
+	if err := json.Unmarshal(payload, &s); err != nil {
+		return nil, fmt.Errorf("could not decode statement: %v", err)
+	}
+	if s.Type != StatementType || s.PredicateType != AttestationPredicateType {
+		return nil, fmt.Errorf("unexpected statement type %q with predicate %q", s.Type, s.PredicateType)
+	}
+	return &s, nil
+}
+
+// LoadEnvelope reads an envelope written by shipshape --attestation.
+func LoadEnvelope(path string) (*Envelope, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read attestation: %v", err)
+	}
+	var env Envelope
+	if err := json.Unmarshal(b, &env); err != nil {
+		return nil, fmt.Errorf("could not parse attestation %s: %v", path, err)
+	}
+	return &env, nil
+}
+
+// KeyID returns the hex SHA-256 of pub in PKIX form.
+func KeyID(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("could not encode public key: %v", err)
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// pae is the DSSE pre-authentication encoding of payload, which is what
+// is actually signed.
+func pae(payloadType string, payload []byte) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "DSSEv1 %d %s %d ", len(payloadType), payloadType, len(payload))
+	b.Write(payload)
+	return b.Bytes()
+}
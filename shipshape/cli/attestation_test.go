@@ -0,0 +1,174 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func TestNewStatement(t *testing.T) {
+	resp := warehouseResults(3)
+	resp.AnalyzeResponse[0].Failure = []*rpcpb.AnalysisFailure{{Category: proto.String("PyLint")}}
+	run := AttestedRun{
+		Repository: "https://example.com/project.git",
+		Commit:     "3f2a1c",
+		Images: map[string]string{
+			"gcr.io/shipshape_releases/service:prod": "sha256:abc",
+			"local/analyzer:latest":                  "",
+		},
+		Event:      "manual",
+		Categories: []string{"PyLint", "JSHint"},
+		Config:     []byte("global:\n"),
+		StartedOn:  time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC),
+		FinishedOn: time.Date(2015, 6, 1, 12, 5, 0, 0, time.UTC),
+	}
+	s := NewStatement(run, resp)
+	if got, want := s.Subject, []Subject{{run.Repository, map[string]string{"gitCommit": "3f2a1c"}}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong subject: got %v, want %v", got, want)
+	}
+	p := s.Predicate
+	if got, want := p.Images, []AttestedImage{{"gcr.io/shipshape_releases/service:prod", map[string]string{"sha256": "abc"}}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong images: got %v, want %v", got, want)
+	}
+	if got, want := p.Categories, []string{"JSHint", "PyLint"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong categories: got %v, want %v", got, want)
+	}
+	if got, want := p.FailedCategories, []string{"PyLint"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong failed categories: got %v, want %v", got, want)
+	}
+	if p.NumNotes != 3 || p.ConfigDigest["sha256"] == "" || p.Dirty {
+		t.Errorf("Wrong predicate: got %+v", p)
+	}
+
+	// The digest does not depend on the order of the notes.
+	notes := resp.AnalyzeResponse[0].Note
+	notes[0], notes[2] = notes[2], notes[0]
+	if got, want := NotesDigest(resp), p.NotesDigest["sha256"]; got != want {
+		t.Errorf("Notes digest depends on order: got %s, want %s", got, want)
+	}
+	if NewStatement(AttestedRun{}, resp).Subject != nil {
+		t.Errorf("Expected no subject without a commit")
+	}
+	run.Dirty = true
+	if !NewStatement(run, resp).Predicate.Dirty {
+		t.Errorf("Expected a dirty work tree to be recorded")
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewStatement(AttestedRun{Commit: "3f2a1c", Event: "manual"}, warehouseResults(1))
+	for _, key := range []crypto.Signer{ecKey, rsaKey} {
+		env, err := Sign(s, key)
+		if err != nil {
+			t.Errorf("Sign with %T failed: %v", key, err)
+			continue
+		}
+		got, err := Verify(env, key.Public())
+		if err != nil {
+			t.Errorf("Verify with %T failed: %v", key, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, s) {
+			t.Errorf("Wrong statement with %T: got %+v, want %+v", key, got, s)
+		}
+
+		if _, err := Verify(env, ecdsaPublic(t)); err == nil {
+			t.Errorf("Verify with %T: expected an error for another key", key)
+		}
+		tampered := *env
+		tampered.Payload = base64.StdEncoding.EncodeToString([]byte(`{"_type": "forged"}`))
+		if _, err := Verify(&tampered, key.Public()); err == nil {
+			t.Errorf("Verify with %T: expected an error for a tampered payload", key)
+		}
+	}
+}
+
+func ecdsaPublic(t *testing.T) crypto.PublicKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key.Public()
+}
+
+func TestLoadKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "attestation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	privatePath, publicPath := filepath.Join(dir, "key.pem"), filepath.Join(dir, "key.pub")
+	ioutil.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: private}), 0600)
+	ioutil.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}), 0644)
+
+	signer, err := LoadSigningKey(privatePath)
+	if err != nil {
+		t.Fatalf("LoadSigningKey failed: %v", err)
+	}
+	pub, err := LoadVerifyingKey(publicPath)
+	if err != nil {
+		t.Fatalf("LoadVerifyingKey failed: %v", err)
+	}
+	env, err := Sign(NewStatement(AttestedRun{}, warehouseResults(0)), signer)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if _, err := Verify(env, pub); err != nil {
+		t.Errorf("Verify with the loaded keys failed: %v", err)
+	}
+	if _, err := LoadSigningKey(publicPath); err == nil {
+		t.Errorf("Expected an error loading a public key as a signing key")
+	}
+}
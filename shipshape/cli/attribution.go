@@ -0,0 +1,237 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	glog "github.com/google/shipshape/third_party/go-glog"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// codeownersLocations are the places a CODEOWNERS file is looked for,
+// relative to the repository root, in order of preference.
+var codeownersLocations = []string{"CODEOWNERS", ".github/CODEOWNERS", "docs/CODEOWNERS"}
+
+// CodeownersRule is a single line of a CODEOWNERS file.
+type CodeownersRule struct {
+	Pattern string
+	Owners  []string
+}
+
+// ParseCodeowners reads the rules in a CODEOWNERS file. Blank lines and
+// comments are skipped. A pattern without owners is kept, since it marks
+// the matching files as unowned.
+func ParseCodeowners(r io.Reader) ([]CodeownersRule, error) {
+	var rules []CodeownersRule
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		rules = append(rules, CodeownersRule{Pattern: fields[0], Owners: fields[1:]})
+	}
+	return rules, scanner.Err()
+}
+
+// MatchOwners returns the owners of file, a slash-separated path relative
+// to the repository root. As with GitHub, the last matching rule wins.
+func MatchOwners(rules []CodeownersRule, file string) []string {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if codeownersMatch(rules[i].Pattern, file) {
+			return rules[i].Owners
+		}
+	}
+	return nil
+}
+
+// codeownersMatch reports whether a CODEOWNERS pattern matches file. Patterns
+// follow gitignore rules: a pattern with no slash, other than a trailing one,
+// matches at any depth, a trailing slash matches only directories, "**"
+// matches any number of directories, and a pattern naming a directory
+// matches everything beneath it.
+func codeownersMatch(pattern, file string) bool {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	segs := strings.Split(pattern, "/")
+	if !anchored {
+		segs = append([]string{"**"}, segs...)
+	}
+	return matchSegments(segs, strings.Split(file, "/"), dirOnly)
+}
+
+// matchSegments matches the pattern segments against a prefix of the path
+// segments. If dirOnly is set, the matched prefix must be a directory, that
+// is, not the whole path.
+func matchSegments(pattern, segs []string, dirOnly bool) bool {
+	if len(pattern) == 0 {
+		return !dirOnly || len(segs) > 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segs); i++ {
+			if matchSegments(pattern[1:], segs[i:], dirOnly) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segs) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segs[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segs[1:], dirOnly)
+}
+
+// Attributor annotates notes with the last author of the line they are on,
+// from git blame, and the owners of their file, from CODEOWNERS.
+type Attributor struct {
+	// root is the directory note paths are relative to.
+	root string
+	// prefix is root relative to the top of the git repository,
+	// with a trailing slash unless it is empty.
+	prefix string
+	rules  []CodeownersRule
+	blame  bool
+	// blames caches the line authors of each file, keyed by path.
+	blames map[string]map[int32]string
+}
+
+// NewAttributor creates an Attributor for notes with paths relative to root.
+// If root is not in a git repository, no authors are found and CODEOWNERS is
+// looked for in root itself.
+func NewAttributor(root string) (*Attributor, error) {
+	a := &Attributor{root: root, blames: make(map[string]map[int32]string)}
+	top := git(root, "rev-parse", "--show-toplevel")
+	if top == "" {
+		top = root
+	} else {
+		a.blame = true
+		a.prefix = git(root, "rev-parse", "--show-prefix")
+	}
+	for _, loc := range codeownersLocations {
+		f, err := os.Open(filepath.Join(top, loc))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if a.rules, err = ParseCodeowners(f); err != nil {
+			return nil, fmt.Errorf("could not read %s: %v", f.Name(), err)
+		}
+		break
+	}
+	return a, nil
+}
+
+// Attribute fills in the attribution of each note in resp that has a path.
+// Files that cannot be blamed, for example because they are not checked in,
+// get owners but no author.
+func (a *Attributor) Attribute(resp *rpcpb.ShipshapeResponse) {
+	for _, analysis := range resp.AnalyzeResponse {
+		for _, note := range analysis.Note {
+			file := note.GetLocation().GetPath()
+			if file == "" {
+				continue
+			}
+			attr := &notepb.Attribution{Owner: MatchOwners(a.rules, a.prefix+file)}
+			if line := note.GetLocation().GetRange().GetStartLine(); line > 0 {
+				if author := a.author(file, line); author != "" {
+					attr.Author = proto.String(author)
+				}
+			}
+			if attr.Author != nil || len(attr.Owner) > 0 {
+				note.Attribution = attr
+			}
+		}
+	}
+}
+
+// author returns the last author of a line of file, or the empty string if
+// it is unknown.
+func (a *Attributor) author(file string, line int32) string {
+	if !a.blame {
+		return ""
+	}
+	authors, ok := a.blames[file]
+	if !ok {
+		cmd := exec.Command("git", "blame", "--line-porcelain", "--", file)
+		cmd.Dir = a.root
+		out, err := cmd.Output()
+		if err != nil {
+			glog.Infof("Could not blame %s: %v", file, err)
+		}
+		authors = parseBlame(string(out))
+		a.blames[file] = authors
+	}
+	return authors[line]
+}
+
+// parseBlame reads the output of git blame --line-porcelain and returns the
+// author of each line, as "Name <email>". Lines that are not committed yet
+// are left out.
+func parseBlame(out string) map[int32]string {
+	authors := make(map[int32]string)
+	var line int64
+	var name, mail string
+	uncommitted := false
+	header := true
+	for _, l := range strings.Split(out, "\n") {
+		switch {
+		case header:
+			fields := strings.Fields(l)
+			if len(fields) < 3 {
+				continue
+			}
+			line, _ = strconv.ParseInt(fields[2], 10, 32)
+			uncommitted = strings.Trim(fields[0], "0") == ""
+			name, mail = "", ""
+			header = false
+		case strings.HasPrefix(l, "\t"):
+			// The content of the line ends its entry.
+			if !uncommitted && name != "" {
+				authors[int32(line)] = strings.TrimSpace(name + " " + mail)
+			}
+			header = true
+		case strings.HasPrefix(l, "author "):
+			name = strings.TrimPrefix(l, "author ")
+		case strings.HasPrefix(l, "author-mail "):
+			mail = strings.TrimPrefix(l, "author-mail ")
+		}
+	}
+	return authors
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testCodeowners = `
+# Default owners.
+*            @org/everyone
+*.js         @org/frontend   # inline comment
+/build/      @org/infra
+docs         @writer
+src/**/gen/  @org/codegen
+/third_party
+`
+
+func TestMatchOwners(t *testing.T) {
+	rules, err := ParseCodeowners(strings.NewReader(testCodeowners))
+	if err != nil {
+		t.Fatalf("Could not parse CODEOWNERS: %v", err)
+	}
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{"README.md", []string{"@org/everyone"}},
+		{"web/app.js", []string{"@org/frontend"}},
+		{"build/rules.bzl", []string{"@org/infra"}},
+		{"src/build/main.go", []string{"@org/everyone"}},
+		{"docs/index.md", []string{"@writer"}},
+		{"src/docs/index.md", []string{"@writer"}},
+		{"src/a/b/gen/api.go", []string{"@org/codegen"}},
+		{"src/gen", []string{"@org/everyone"}},
+		{"third_party/lib/lib.js", []string{}},
+	}
+	for _, test := range tests {
+		if got := MatchOwners(rules, test.file); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("MatchOwners(%q): got %v, want %v", test.file, got, test.want)
+		}
+	}
+}
+
+func TestParseBlame(t *testing.T) {
+	out := `8f3b1c2d8f3b1c2d8f3b1c2d8f3b1c2d8f3b1c2d 1 1 2
+author Jane Doe
+author-mail <jane@example.com>
+author-time 1420070400
+filename main.go
+	package main
+8f3b1c2d8f3b1c2d8f3b1c2d8f3b1c2d8f3b1c2d 2 2
+author Jane Doe
+author-mail <jane@example.com>
+filename main.go
+	
+0000000000000000000000000000000000000000 3 3 1
+author Not Committed Yet
+author-mail <not.committed.yet>
+filename main.go
+	func main() {}
+`
+	got := parseBlame(out)
+	want := map[int32]string{
+		1: "Jane Doe <jane@example.com>",
+		2: "Jane Doe <jane@example.com>",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong authors: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// Baseline is a set of accepted notes, which are left out of the results so
+// that only new notes are reported. Notes are matched by Fingerprint, so
+// they stay in the baseline when edits move them around their file. The
+// other fields of the entries are there for people reviewing the file.
+type Baseline struct {
+	Notes []HistoryNote `json:"notes"`
+	index map[string]bool
+}
+
+// LoadBaseline reads the baseline at path. A missing file is an empty
+// baseline.
+func LoadBaseline(path string) (*Baseline, error) {
+	b := &Baseline{index: make(map[string]bool)}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return b, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("could not parse baseline %s: %v", path, err)
+	}
+	for _, n := range b.Notes {
+		b.index[n.Fingerprint] = true
+	}
+	return b, nil
+}
+
+// Contains is true if note is in the baseline.
+func (b *Baseline) Contains(note *notepb.Note) bool {
+	return b.index[Fingerprint(note)]
+}
+
+// Add adds note to the baseline, unless it is already in it.
+func (b *Baseline) Add(note *notepb.Note) {
+	fp := Fingerprint(note)
+	if b.index[fp] {
+		return
+	}
+	b.index[fp] = true
+	b.Notes = append(b.Notes, HistoryNote{
+		Fingerprint: fp,
+		Category:    note.GetCategory(),
+		Path:        note.GetLocation().GetPath(),
+		Line:        note.GetLocation().GetRange().GetStartLine(),
+		Description: note.GetDescription(),
+	})
+}
+
+// Filter removes the notes in resp that are in the baseline, and returns how
+// many it removed.
+func (b *Baseline) Filter(resp *rpcpb.ShipshapeResponse) int {
+	removed := 0
+	for _, analysis := range resp.AnalyzeResponse {
+		var kept []*notepb.Note
+		for _, note := range analysis.Note {
+			if b.Contains(note) {
+				removed++
+				continue
+			}
+			kept = append(kept, note)
+		}
+		analysis.Note = kept
+	}
+	return removed
+}
+
+// Save writes the baseline to path, sorted by path and category so that
+// changes to it are easy to review.
+func (b *Baseline) Save(path string) error {
+	sort.Sort(byLocation(b.Notes))
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+type byLocation []HistoryNote
+
+func (n byLocation) Len() int      { return len(n) }
+func (n byLocation) Swap(i, j int) { n[i], n[j] = n[j], n[i] }
+func (n byLocation) Less(i, j int) bool {
+	if n[i].Path != n[j].Path {
+		return n[i].Path < n[j].Path
+	}
+	if n[i].Category != n[j].Category {
+		return n[i].Category < n[j].Category
+	}
+	if n[i].Line != n[j].Line {
+		return n[i].Line < n[j].Line
+	}
+	return n[i].Fingerprint < n[j].Fingerprint
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func TestBaseline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "baseline_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "baseline.json")
+
+	b, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatalf("Loading a missing baseline failed: %v", err)
+	}
+	accepted := tableNote("PyLint", "b.py", 3, notepb.Note_WARNING, "unused import")
+	b.Add(accepted)
+	b.Add(accepted)
+	b.Add(tableNote("go vet", "a.go", 9, notepb.Note_WARNING, "bad printf"))
+	if got, want := len(b.Notes), 2; got != want {
+		t.Errorf("Wrong number of baseline notes: got %d, want %d", got, want)
+	}
+	if err := b.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatalf("LoadBaseline failed: %v", err)
+	}
+	if len(loaded.Notes) != 2 || loaded.Notes[0].Path != "a.go" {
+		t.Errorf("Baseline not saved sorted by path: got %v", loaded.Notes)
+	}
+	// The same note on another line is still in the baseline.
+	moved := tableNote("PyLint", "b.py", 10, notepb.Note_WARNING, "unused import")
+	fresh := tableNote("PyLint", "b.py", 3, notepb.Note_WARNING, "unused variable")
+	resp := &rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{{Note: []*notepb.Note{moved, fresh}}}}
+	if got, want := loaded.Filter(resp), 1; got != want {
+		t.Errorf("Wrong number of notes removed: got %d, want %d", got, want)
+	}
+	if notes := AllNotes(resp); len(notes) != 1 || notes[0] != fresh {
+		t.Errorf("Wrong notes left: got %v", notes)
+	}
+}
+
+func TestLoadBaselineCorrupt(t *testing.T) {
+	f, err := ioutil.TempFile("", "baseline_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("not json")
+	f.Close()
+	if _, err := LoadBaseline(f.Name()); err == nil {
+		t.Errorf("Expected an error for a corrupt baseline")
+	}
+}
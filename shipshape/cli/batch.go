@@ -0,0 +1,95 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/shipshape/shipshape/util/rpc/client"
+	glog "github.com/google/shipshape/third_party/go-glog"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// analyzeBatches calls analyze for req, once for each of batches if there are any, with
+// the files of the request set to the batch. The progress of each batch is added to that
+// of the ones before it, as estimated by offsetProgress. If the results of a batch stop part way, the remaining batches
+// are still analyzed, unless the run was interrupted, and the first error is returned.
+func (i *Invocation) analyzeBatches(c *client.Client, req *rpcpb.ShipshapeRequest, origDir string, batches [][]string, start time.Time) (int, error) {
+	if len(batches) == 0 {
+		i.setTimeBudget(req, start)
+		glog.Infof("Calling with request %v", req)
+		return analyze(c, i.streamOptions(), req, origDir, i.handleResponse, i.options.HandleProgress)
+	}
+	var firstErr error
+	numNotes := 0
+	for n, batch := range batches {
+		req.ShipshapeContext.FilePath = batch
+		i.setTimeBudget(req, start)
+		glog.Infof("Calling with batch %d of %d, of %d files", n+1, len(batches), len(batch))
+		var handleProgress func([]*rpcpb.AnalysisProgress)
+		if i.options.HandleProgress != nil {
+			index := n
+			handleProgress = func(progress []*rpcpb.AnalysisProgress) {
+				i.options.HandleProgress(offsetProgress(progress, index, len(batches)))
+			}
+		}
+		batchNotes, err := analyze(c, i.streamOptions(), req, origDir, i.handleResponse, handleProgress)
+		numNotes += batchNotes
+		if err != nil {
+			se, isStream := err.(streamError)
+			if !isStream || se.err == errInterrupted {
+				return numNotes, err
+			}
+			glog.Errorf("Lost the results of batch %d of %d: %v", n+1, len(batches), err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return numNotes, firstErr
+}
+
+// splitBatches splits files into batches of at most size files.
+func splitBatches(files []string, size int) [][]string {
+	var batches [][]string
+	for len(files) > size {
+		batches = append(batches, files[:size])
+		files = files[size:]
+	}
+	if len(files) > 0 {
+		batches = append(batches, files)
+	}
+	return batches
+}
+
+// offsetProgress converts the progress of the batch with index batch to the progress of
+// the whole run of batches. The service only knows the analyzer calls of the batch it is
+// running, so each batch is assumed to call as many analyzers for a category as this one.
+func offsetProgress(progress []*rpcpb.AnalysisProgress, batch, batches int) []*rpcpb.AnalysisProgress {
+	var adjusted []*rpcpb.AnalysisProgress
+	for _, p := range progress {
+		total := p.GetAnalyzersTotal()
+		adjusted = append(adjusted, &rpcpb.AnalysisProgress{
+			Category:           p.Category,
+			AnalyzersCompleted: proto.Int32(int32(batch)*total + p.GetAnalyzersCompleted()),
+			AnalyzersTotal:     proto.Int32(int32(batches) * total),
+		})
+	}
+	return adjusted
+}
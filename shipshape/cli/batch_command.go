@@ -0,0 +1,157 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/google/shipshape/shipshape/cli"
+)
+
+// batchCommand implements `shipshape batch`, which brings the repositories of
+// a manifest up to date and runs a pipeline against each of them, one after
+// the other, aggregating their summaries into one report. A repository that
+// cannot be checked out or analyzed is reported, and the rest are still
+// analyzed.
+func batchCommand(args []string) int {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	manifest := fs.String("manifest", "", "The YAML file that lists the repositories to analyze")
+	workdir := fs.String("workdir", filepath.Join(cli.TelemetryDir(), "batch"), "The directory to check the repositories out in. The checkouts are reused by later batches.")
+	report := fs.String("report", "", "When specified, also write the report as JSON to this file")
+	fs.Usage = func() {
+		fmt.Println("USAGE: shipshape batch --manifest=<repos.yaml> [--workdir=<dir>] [--report=<report.json>]")
+		fmt.Println("The results and the output of each run are kept in the workdir, next to the checkout.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *manifest == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return returnError
+	}
+
+	m, err := cli.LoadBatchManifest(*manifest)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+	if err := os.MkdirAll(*workdir, 0755); err != nil {
+		fmt.Printf("Error: could not create the workdir: %v\n", err)
+		return returnError
+	}
+	self, err := selfPath()
+	if err != nil {
+		fmt.Printf("Error: could not find the shipshape binary to run: %v\n", err)
+		return returnError
+	}
+
+	b := &cli.BatchReport{Manifest: *manifest, Timestamp: time.Now().UTC()}
+	for n, r := range m.Repos {
+		fmt.Printf("[%d/%d] %s\n", n+1, len(m.Repos), r.Name)
+		b.Add(analyzeRepo(self, *workdir, m.PipelineOf(r), r))
+	}
+	fmt.Print(b.Text())
+	if *report != "" {
+		if err := b.Write(*report); err != nil {
+			fmt.Printf("Error: could not write the report: %v\n", err)
+			return returnError
+		}
+	}
+	switch {
+	case b.NumFailed > 0:
+		return returnError
+	case b.NumNotes > 0:
+		return returnFindings
+	}
+	return returnNoFindings
+}
+
+// selfPath returns the absolute path of the running shipshape binary, to run
+// it again for each repository.
+func selfPath() (string, error) {
+	path, err := exec.LookPath(os.Args[0])
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(path)
+}
+
+// analyzeRepo checks out r in workdir and runs pipeline against it with the
+// shipshape binary self.
+func analyzeRepo(self, workdir, pipeline string, r cli.BatchRepo) *cli.RepoResult {
+	result := &cli.RepoResult{Name: r.Name, URL: cli.SanitizeRemote(r.URL), Pipeline: pipeline}
+	dir, err := cli.SyncRepo(workdir, r)
+	if err != nil {
+		result.ExitStatus = returnError
+		result.Error = err.Error()
+		return result
+	}
+	result.Commit = cli.GetRevisionDetails(dir).GetCommit()
+	runPipeline(self, workdir, dir, result)
+	return result
+}
+
+// runPipeline runs the pipeline of result against dir with the shipshape
+// binary self, keeping the results and the output in workdir, named after
+// result. Each run is a process of its own, so that the flags of one pipeline
+// do not carry over to the next.
+func runPipeline(self, workdir, dir string, result *cli.RepoResult) {
+	results := filepath.Join(workdir, result.Name+".json")
+	os.Remove(results)
+	logPath := filepath.Join(workdir, result.Name+".log")
+	log, err := os.Create(logPath)
+	if err != nil {
+		result.ExitStatus = returnError
+		result.Error = fmt.Sprintf("could not create %s: %v", logPath, err)
+		return
+	}
+	defer log.Close()
+	cmd := exec.Command(self, "run", result.Pipeline, "--json_output="+results, dir)
+	cmd.Stdout = log
+	cmd.Stderr = log
+	if err := cmd.Run(); err != nil {
+		var status syscall.WaitStatus
+		ok := cmd.ProcessState != nil
+		if ok {
+			status, ok = cmd.ProcessState.Sys().(syscall.WaitStatus)
+		}
+		if !ok {
+			result.ExitStatus = returnError
+			result.Error = fmt.Sprintf("could not run shipshape: %v", err)
+			return
+		}
+		result.ExitStatus = status.ExitStatus()
+		if result.ExitStatus != returnFindings {
+			result.Error = fmt.Sprintf("shipshape exited with status %d; see %s", result.ExitStatus, logPath)
+		}
+	}
+
+	resp, err := cli.LoadResults(results)
+	if err != nil {
+		if result.Error == "" {
+			result.Error = fmt.Sprintf("could not read the results: %v", err)
+		}
+		return
+	}
+	result.Results = results
+	result.Summary = cli.Summarize(resp, dir, 0)
+}
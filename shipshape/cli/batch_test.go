@@ -0,0 +1,52 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func TestSplitBatches(t *testing.T) {
+	files := []string{"a", "b", "c", "d", "e"}
+	tests := []struct {
+		size   int
+		expect [][]string
+	}{
+		{2, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}},
+		{5, [][]string{{"a", "b", "c", "d", "e"}}},
+		{10, [][]string{{"a", "b", "c", "d", "e"}}},
+	}
+	for _, test := range tests {
+		if got := splitBatches(files, test.size); !reflect.DeepEqual(got, test.expect) {
+			t.Errorf("Wrong batches of size %d: got %v, want %v", test.size, got, test.expect)
+		}
+	}
+	if got := splitBatches(nil, 2); len(got) != 0 {
+		t.Errorf("Wrong batches of no files: got %v, want none", got)
+	}
+}
+
+func TestOffsetProgress(t *testing.T) {
+	got := offsetProgress([]*rpcpb.AnalysisProgress{progress("PyLint", 0, 1), progress("JSHint", 2, 2)}, 2, 3)
+	want := []*rpcpb.AnalysisProgress{progress("PyLint", 2, 3), progress("JSHint", 6, 6)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong progress: got %v, want %v", got, want)
+	}
+}
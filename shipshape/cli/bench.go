@@ -0,0 +1,54 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import "time"
+
+// Timings break down how long a run took. The phases overlap: images are
+// pulled while the workspace is examined, and each analyzer starts as soon as
+// its own image is pulled, so they need not add up to Total.
+type Timings struct {
+	// Pull is how long the images took to be checked for updates and pulled.
+	Pull time.Duration
+	// Start is how long the analyzer and service containers took to start, or
+	// be found running, once their images were pulled.
+	Start time.Duration
+	// Extraction is how long the build took to extract compilation units, if
+	// the run built the code.
+	Extraction time.Duration
+	// Analysis is how long the service took to analyze the files, in all stages.
+	Analysis time.Duration
+	Total    time.Duration
+}
+
+// MeanTimings returns the mean of each phase across runs, or zero timings if
+// there are no runs.
+func MeanTimings(runs []Timings) Timings {
+	var sum Timings
+	if len(runs) == 0 {
+		return sum
+	}
+	for _, t := range runs {
+		sum.Pull += t.Pull
+		sum.Start += t.Start
+		sum.Extraction += t.Extraction
+		sum.Analysis += t.Analysis
+		sum.Total += t.Total
+	}
+	n := time.Duration(len(runs))
+	return Timings{sum.Pull / n, sum.Start / n, sum.Extraction / n, sum.Analysis / n, sum.Total / n}
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/shipshape/shipshape/cli"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// benchCommand implements `shipshape bench`, which analyzes a workspace
+// repeatedly and reports how long each phase of the runs took. The first run
+// is cold: it stops any containers left by earlier runs. The others reuse the
+// containers, as repeated runs usually do.
+func benchCommand(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	iterations := fs.Int("iterations", 5, "How many times to run the analysis, including the cold run")
+	cats := fs.String("categories", "", "Categories to trigger (comma-separated). If none are specified, will use the .shipshape configuration file to decide which categories to run.")
+	event := fs.String("event", cli.DefaultEvent, "The name of the event to use")
+	repo := fs.String("repo", cli.DefaultRepo, "The name of the docker repo to use")
+	tag := fs.String("tag", "prod", "Tag to use for the analysis service image. If this is local, we will not attempt to pull the image.")
+	build := fs.String("build", "", "The name of the build system to use to generate compilation units. If empty, will not run the compilation step.")
+	analyzers := fs.String("analyzer_images", "", "Full docker path to images of external analyzers to use (comma-separated)")
+	stayUp := fs.Bool("stay_up", false, "True if we should keep the containers running after the last run")
+	fs.Usage = func() {
+		fmt.Println("USAGE: shipshape bench [--iterations=N] [--categories=<list>] <directory>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 || *iterations < 1 {
+		fs.Usage()
+		return returnError
+	}
+
+	var runs []cli.Timings
+	fmt.Printf("%-4s %-5s %10s %10s %10s %10s %10s %6s\n", "run", "kind", "pull", "containers", "extraction", "analysis", "total", "notes")
+	for n := 1; n <= *iterations; n++ {
+		notes := 0
+		options := cli.Options{
+			File:      fs.Arg(0),
+			Build:     *build,
+			Event:     *event,
+			Repo:      *repo,
+			Tag:       *tag,
+			ColdStart: n == 1,
+			StayUp:    n < *iterations || *stayUp,
+			HandleResponse: func(msg *rpcpb.ShipshapeResponse, _ string) error {
+				for _, analysis := range msg.AnalyzeResponse {
+					notes += len(analysis.Note)
+				}
+				return nil
+			},
+		}
+		if *cats != "" {
+			options.TriggerCats = strings.Split(*cats, ",")
+		}
+		if *analyzers != "" {
+			options.ThirdPartyAnalyzers = strings.Split(*analyzers, ",")
+		}
+		invocation := cli.New(options)
+		if _, err := invocation.Run(); err != nil {
+			fmt.Printf("Error: run %d failed: %v\n", n, err)
+			return returnError
+		}
+		t := invocation.Timings()
+		kind := "warm"
+		if n == 1 {
+			kind = "cold"
+		} else {
+			runs = append(runs, t)
+		}
+		printTimings(fmt.Sprint(n), kind, t, fmt.Sprint(notes))
+	}
+	if len(runs) > 0 {
+		printTimings("mean", "warm", cli.MeanTimings(runs), "")
+	}
+	return returnNoFindings
+}
+
+func printTimings(run, kind string, t cli.Timings, notes string) {
+	ms := func(d time.Duration) string { return fmt.Sprintf("%.1fms", d.Seconds()*1000) }
+	fmt.Printf("%-4s %-5s %10s %10s %10s %10s %10s %6s\n", run, kind, ms(t.Pull), ms(t.Start), ms(t.Extraction), ms(t.Analysis), ms(t.Total), notes)
+}
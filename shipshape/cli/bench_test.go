@@ -0,0 +1,36 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMeanTimings(t *testing.T) {
+	runs := []Timings{
+		{Pull: 2 * time.Second, Start: time.Second, Analysis: 4 * time.Second, Total: 6 * time.Second},
+		{Start: 3 * time.Second, Extraction: 2 * time.Second, Analysis: 2 * time.Second, Total: 8 * time.Second},
+	}
+	want := Timings{Pull: time.Second, Start: 2 * time.Second, Extraction: time.Second, Analysis: 3 * time.Second, Total: 7 * time.Second}
+	if got := MeanTimings(runs); got != want {
+		t.Errorf("Wrong mean: got %+v, want %+v", got, want)
+	}
+	if got := MeanTimings(nil); got != (Timings{}) {
+		t.Errorf("Wrong mean of no runs: got %+v, want zero timings", got)
+	}
+}
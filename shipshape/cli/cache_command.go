@@ -0,0 +1,104 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/google/shipshape/shipshape/cli"
+	"github.com/google/shipshape/shipshape/util/docker"
+)
+
+// cacheCommand implements `shipshape cache ls|stats|clear`, which manages the
+// results cached from earlier runs and the docker volumes that keep analyzer
+// tool caches between runs.
+func cacheCommand(args []string) int {
+	fs := flag.NewFlagSet("cache", flag.ExitOnError)
+	snapshotDir := fs.String("snapshot_dir", cli.DefaultSnapshotDir, "Where the results of earlier runs are cached, as for --snapshot_dir of a run")
+	fs.Usage = func() {
+		fmt.Println("USAGE: shipshape cache ls|stats|clear")
+		fmt.Println("  ls     lists the tool cache volumes")
+		fmt.Println("  stats  describes the cached results and counts the runs that used them")
+		fmt.Println("  clear  removes the cached results and the tool cache volumes")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return returnError
+	}
+
+	switch fs.Arg(0) {
+	case "ls":
+		volumes, err := docker.ListCacheVolumes()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return returnError
+		}
+		if len(volumes) == 0 {
+			fmt.Println("No tool cache volumes")
+			return returnNoFindings
+		}
+		for _, volume := range volumes {
+			fmt.Printf("  %-25s %s\n", volume, docker.CacheVolumes[volume])
+		}
+	case "stats":
+		stats, err := cli.ReadCacheStats(*snapshotDir)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return returnError
+		}
+		fmt.Printf("Cached results in %s: %d targets, %d bytes\n", *snapshotDir, stats.Entries, stats.Bytes)
+		if stats.Entries > 0 {
+			fmt.Printf("  oldest  %s\n", stats.Oldest.Local().Format(time.RFC1123))
+			fmt.Printf("  newest  %s\n", stats.Newest.Local().Format(time.RFC1123))
+		}
+		fmt.Printf("  hits    %d\n", stats.Hits)
+		fmt.Printf("  misses  %d\n", stats.Misses)
+		if volumes, err := docker.ListCacheVolumes(); err == nil {
+			fmt.Printf("Tool cache volumes: %d\n", len(volumes))
+		}
+	case "clear":
+		status := returnNoFindings
+		removed, err := cli.ClearCache(*snapshotDir)
+		if err != nil {
+			fmt.Printf("Error: could not clear the cached results in %s: %v\n", *snapshotDir, err)
+			status = returnError
+		}
+		fmt.Printf("Removed the cached results of %d targets\n", removed)
+		volumes, err := docker.ListCacheVolumes()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return returnError
+		}
+		for _, volume := range volumes {
+			if result := docker.RemoveVolume(volume); result.Err != nil {
+				fmt.Printf("Error: could not remove %s (is a container still using it?): %s\n", volume, result.Stderr)
+				status = returnError
+				continue
+			}
+			fmt.Printf("Removed %s\n", volume)
+		}
+		return status
+	default:
+		fs.Usage()
+		return returnError
+	}
+	return returnNoFindings
+}
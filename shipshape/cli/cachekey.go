@@ -0,0 +1,87 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// CacheKey builds the key of the results of a run from the inputs that they
+// depend on, so that cached results are only used if none of the inputs
+// changed. Each input is named, and the key does not depend on the order in
+// which they are added.
+type CacheKey struct {
+	inputs map[string]string
+}
+
+// NewCacheKey returns a key with no inputs.
+func NewCacheKey() *CacheKey {
+	return &CacheKey{inputs: make(map[string]string)}
+}
+
+// Add records the input name with value, replacing any earlier value.
+func (k *CacheKey) Add(name, value string) {
+	k.inputs[name] = value
+}
+
+// AddAll records each of values as an input, named name/<key>.
+func (k *CacheKey) AddAll(name string, values map[string]string) {
+	for key, value := range values {
+		k.Add(name+"/"+key, value)
+	}
+}
+
+// Sum returns the hex SHA-256 of the inputs.
+func (k *CacheKey) Sum() string {
+	h := sha256.New()
+	for _, name := range k.names() {
+		// The lengths keep the boundaries between names and values unambiguous.
+		value := k.inputs[name]
+		fmt.Fprintf(h, "%d:%s%d:%s", len(name), name, len(value), value)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Changed returns the sorted names of the inputs whose values differ between
+// k and other, including those that only one of them has.
+func (k *CacheKey) Changed(other *CacheKey) []string {
+	var changed []string
+	for name, value := range k.inputs {
+		if v, ok := other.inputs[name]; !ok || v != value {
+			changed = append(changed, name)
+		}
+	}
+	for name := range other.inputs {
+		if _, ok := k.inputs[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func (k *CacheKey) names() []string {
+	var names []string
+	for name := range k.inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCacheKey(t *testing.T) {
+	k := NewCacheKey()
+	k.Add("config", "abc")
+	k.AddAll("image", map[string]string{"service": "sha256:1", "kythe": "sha256:2"})
+
+	same := NewCacheKey()
+	same.AddAll("image", map[string]string{"kythe": "sha256:2", "service": "sha256:1"})
+	same.Add("config", "abc")
+	if k.Sum() != same.Sum() {
+		t.Errorf("Key depends on the order of the inputs")
+	}
+	if got := k.Changed(same); len(got) != 0 {
+		t.Errorf("Wrong changed inputs of the same key: got %v, want none", got)
+	}
+
+	other := NewCacheKey()
+	other.Add("config", "abd")
+	other.AddAll("image", map[string]string{"service": "sha256:1"})
+	other.Add("analyzer_env", "123")
+	if k.Sum() == other.Sum() {
+		t.Errorf("Keys of different inputs are the same")
+	}
+	if got, want := k.Changed(other), []string{"analyzer_env", "config", "image/kythe"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong changed inputs: got %v, want %v", got, want)
+	}
+
+	// Moving text between a name and its value must change the key.
+	a, b := NewCacheKey(), NewCacheKey()
+	a.Add("ab", "c")
+	b.Add("a", "bc")
+	if a.Sum() == b.Sum() {
+		t.Errorf("Keys of different inputs are the same: %v and %v", a.inputs, b.inputs)
+	}
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"strings"
+
+	"github.com/google/shipshape/shipshape/service"
+)
+
+// CategoriesLabel is the image label listing the categories that an analyzer
+// image provides, separated by commas. Analyzers with the label are only
+// started when one of their categories is triggered.
+const CategoriesLabel = "shipshape.categories"
+
+// triggeredCategories returns the categories that a run on absRoot would
+// analyze, or nil if they are not known, in which case the service decides.
+func (i *Invocation) triggeredCategories(absRoot string) []string {
+	if len(i.options.TriggerCats) > 0 {
+		return i.options.TriggerCats
+	}
+	categories, err := service.EventCategories(absRoot, i.options.Event)
+	if err != nil {
+		// The service reports the broken config when it loads it.
+		return nil
+	}
+	return categories
+}
+
+// analyzerNeeded returns whether an analyzer whose image has CategoriesLabel
+// set to provides is needed to analyze categories. An image without the
+// label could provide any category, so it is always needed, as is every
+// image when the categories are not known.
+func analyzerNeeded(provides string, categories []string) bool {
+	if strings.TrimSpace(provides) == "" || len(categories) == 0 {
+		return true
+	}
+	want := make(map[string]bool)
+	for _, c := range categories {
+		want[c] = true
+	}
+	for _, c := range strings.Split(provides, ",") {
+		if want[strings.TrimSpace(c)] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import "testing"
+
+func TestAnalyzerNeeded(t *testing.T) {
+	tests := []struct {
+		provides   string
+		categories []string
+		want       bool
+	}{
+		{"", []string{"JSHint"}, true},
+		{"AndroidLint", nil, true},
+		{"AndroidLint", []string{"JSHint", "AndroidLint"}, true},
+		{"ErrorProne, AndroidLint", []string{"AndroidLint"}, true},
+		{"AndroidLint", []string{"JSHint", "go vet"}, false},
+		{"AndroidLint,ErrorProne", []string{"Android"}, false},
+	}
+	for _, test := range tests {
+		if got := analyzerNeeded(test.provides, test.categories); got != test.want {
+			t.Errorf("analyzerNeeded(%q, %v): got %v, want %v", test.provides, test.categories, got, test.want)
+		}
+	}
+}
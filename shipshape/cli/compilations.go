@@ -0,0 +1,110 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// compilationsDir is the directory of the workspace that the kythe image extracts
+// the compilation units of the build to, and that the service reads them from.
+const compilationsDir = "compilations"
+
+// isCompilation returns whether the file name holds compilation units: a .kindex
+// file, as this kythe image writes, or a .kzip one, as newer ones do.
+func isCompilation(name string) bool {
+	return strings.HasSuffix(name, ".kindex") || strings.HasSuffix(name, ".kzip")
+}
+
+// compilationFiles returns the paths, relative to dir, of the files holding
+// compilation units in the directory tree dir.
+func compilationFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() && isCompilation(fi.Name()) {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// countCompilations returns the number of files holding compilation units in the
+// directory tree dir.
+func countCompilations(dir string) (int, error) {
+	files, err := compilationFiles(dir)
+	return len(files), err
+}
+
+// copyCompilations copies the files holding compilation units in the directory
+// tree src to the same paths under dst, creating the directories as needed and
+// replacing any files already there. It returns the number of files copied.
+func copyCompilations(src, dst string) (int, error) {
+	files, err := compilationFiles(src)
+	if err != nil {
+		return 0, err
+	}
+	for _, file := range files {
+		if err := copyFile(filepath.Join(src, file), filepath.Join(dst, file)); err != nil {
+			return 0, err
+		}
+	}
+	return len(files), nil
+}
+
+// copyFile copies the regular file src to dst, creating the directory dst is in.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("could not copy %s to %s: %v", src, dst, err)
+	}
+	return out.Close()
+}
+
+// importCompilations replaces the compilation units in the workspace absRoot
+// with those in dir, so that the POST_BUILD stage analyzes them instead of
+// extracting them from the build.
+func importCompilations(dir, absRoot string) (int, error) {
+	dst := filepath.Join(absRoot, compilationsDir)
+	if err := os.RemoveAll(dst); err != nil {
+		return 0, err
+	}
+	return copyCompilations(dir, dst)
+}
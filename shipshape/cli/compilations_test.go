@@ -0,0 +1,110 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, contents := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestImportCompilations(t *testing.T) {
+	units, err := ioutil.TempDir("", "units")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(units)
+	writeFiles(t, units, map[string]string{
+		"java/a.kindex": "a",
+		"go/b.kzip":     "b",
+		"README":        "not a unit",
+	})
+	root, err := ioutil.TempDir("", "workspace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	writeFiles(t, root, map[string]string{"compilations/stale.kindex": "stale"})
+
+	n, err := importCompilations(units, root)
+	if err != nil {
+		t.Fatalf("Could not import the compilation units: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Wrong number of units imported: got %d, want 2", n)
+	}
+	got, err := compilationFiles(filepath.Join(root, compilationsDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join("go", "b.kzip"), filepath.Join("java", "a.kindex")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong compilation units in the workspace: got %v, want %v", got, want)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(root, compilationsDir, "java", "a.kindex")); err != nil || string(b) != "a" {
+		t.Errorf("Wrong contents of the imported unit: got %q, %v", b, err)
+	}
+}
+
+func TestCheckImportCompilations(t *testing.T) {
+	units, err := ioutil.TempDir("", "units")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(units)
+
+	i := New(Options{ImportCompilations: units})
+	if err := i.checkStages(); err == nil {
+		t.Errorf("Expected an error for a directory without compilation units")
+	}
+	writeFiles(t, units, map[string]string{"a.kindex": "a"})
+	if err := i.checkStages(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if got, want := i.stages(), []ctxpb.Stage{ctxpb.Stage_PRE_BUILD, ctxpb.Stage_POST_BUILD}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong stages: got %v, want %v", got, want)
+	}
+	if i.extracts() {
+		t.Errorf("Imported compilation units should not be extracted")
+	}
+
+	tests := []Options{
+		{ImportCompilations: units, ExportCompilations: "/tmp/units"},
+		{ImportCompilations: units, Stages: []ctxpb.Stage{ctxpb.Stage_PRE_BUILD}},
+		{ImportCompilations: filepath.Join(units, "missing")},
+	}
+	for _, options := range tests {
+		if err := New(options).checkStages(); err == nil {
+			t.Errorf("Expected an error for %+v", options)
+		}
+	}
+}
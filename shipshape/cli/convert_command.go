@@ -0,0 +1,77 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/google/shipshape/shipshape/cli"
+)
+
+// convertCommand implements `shipshape convert`, which converts a results file
+// written by --json_output from one version of its schema to another, so that
+// results stored by earlier versions of shipshape can be read by tools that
+// expect the current one, or the other way around.
+func convertCommand(args []string) int {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	from := fs.String("from", "", "The version of the schema the results were written with. If empty, the version recorded in the results is used; if given, it must match it.")
+	to := fs.String("to", cli.CurrentSchema, "The version of the schema to convert the results to")
+	output := fs.String("output", "", "Where to write the converted results. If empty, they are printed.")
+	fs.Usage = func() {
+		fmt.Println("USAGE: shipshape convert [--from=v1] [--to=v2] [--output=<file>] <results.json>")
+		fmt.Printf("Schema versions: %s\n", strings.Join([]string{cli.SchemaV1, cli.SchemaV2}, ", "))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return returnError
+	}
+
+	resp, err := cli.ReadResults(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+	if v := cli.SchemaVersion(resp); *from != "" && *from != v {
+		fmt.Printf("Error: %s has schema version %s, not %s\n", fs.Arg(0), v, *from)
+		return returnError
+	}
+	if err := cli.ConvertSchema(resp, *to); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+	if *output == "" {
+		os.Stdout.Write(append(b, '\n'))
+		return returnNoFindings
+	}
+	if err := ioutil.WriteFile(*output, b, 0644); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+	return returnNoFindings
+}
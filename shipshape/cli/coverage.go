@@ -0,0 +1,81 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"sort"
+
+	strset "github.com/google/shipshape/shipshape/util/strings"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// CoverageReport maps each analyzed file to the categories that processed it,
+// so that files which were silently skipped, because no analyzer handles
+// their extension or runs at their stage, can be found.
+type CoverageReport struct {
+	// RunID is the ID of the run, if it is known.
+	RunID string `json:"run_id,omitempty"`
+	// Files maps each analyzed file to the categories that processed it, sorted.
+	Files map[string][]string `json:"files"`
+	// Skipped are the files that no category processed, sorted.
+	Skipped []string `json:"skipped"`
+	// Unreported are the categories that ran, but whose analyzers do not say
+	// which files they process. They may have processed the skipped files.
+	Unreported []string `json:"unreported_categories,omitempty"`
+	// Undecodable are the text files in an unknown encoding, which the analyzers
+	// saw as they are, sorted. Their notes may have wrong columns.
+	Undecodable []string `json:"undecodable,omitempty"`
+}
+
+// Coverage builds the coverage report for the results in resp of a run on
+// files, which is expected to run categories. Categories that failed are
+// not counted as having run.
+func Coverage(resp *rpcpb.ShipshapeResponse, files, categories []string) *CoverageReport {
+	r := &CoverageReport{RunID: resp.GetRunInfo().GetRunId(), Files: make(map[string][]string), Skipped: []string{}}
+	for _, f := range files {
+		r.Files[f] = []string{}
+	}
+	reported := make(map[string]bool)
+	failed := make(map[string]bool)
+	for _, analysis := range resp.AnalyzeResponse {
+		for _, f := range analysis.Failure {
+			failed[f.GetCategory()] = true
+		}
+		for _, c := range analysis.Coverage {
+			reported[c.GetCategory()] = true
+			for _, f := range c.File {
+				if cats, ok := r.Files[f]; ok && !strset.Contains(cats, c.GetCategory()) {
+					r.Files[f] = append(cats, c.GetCategory())
+				}
+			}
+		}
+	}
+	for f, cats := range r.Files {
+		sort.Strings(cats)
+		if len(cats) == 0 {
+			r.Skipped = append(r.Skipped, f)
+		}
+	}
+	sort.Strings(r.Skipped)
+	for _, c := range categories {
+		if !reported[c] && !failed[c] {
+			r.Unreported = append(r.Unreported, c)
+		}
+	}
+	return r
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func TestCoverage(t *testing.T) {
+	resp := &rpcpb.ShipshapeResponse{
+		AnalyzeResponse: []*rpcpb.AnalyzeResponse{
+			{Coverage: []*rpcpb.AnalysisCoverage{
+				{Category: proto.String("go vet"), File: []string{"main.go", "lib/lib.go"}},
+				{Category: proto.String("PyLint")},
+			}},
+			{
+				Coverage: []*rpcpb.AnalysisCoverage{{Category: proto.String("CodeAlert"), File: []string{"main.go", "elsewhere.go"}}},
+				Failure:  []*rpcpb.AnalysisFailure{{Category: proto.String("JSHint")}},
+			},
+		},
+	}
+	files := []string{"main.go", "lib/lib.go", "README.md", "app.java"}
+	got := Coverage(resp, files, []string{"go vet", "PyLint", "CodeAlert", "JSHint", "ErrorProne"})
+	want := &CoverageReport{
+		Files: map[string][]string{
+			"main.go":    {"CodeAlert", "go vet"},
+			"lib/lib.go": {"go vet"},
+			"README.md":  {},
+			"app.java":   {},
+		},
+		Skipped:    []string{"README.md", "app.java"},
+		Unreported: []string{"ErrorProne"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong coverage: got %v, want %v", got, want)
+	}
+}
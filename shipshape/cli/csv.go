@@ -0,0 +1,124 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+)
+
+// CSVColumns are the columns written by WriteCSV and WriteTSV, in order.
+var CSVColumns = []string{"path", "start_line", "start_col", "category", "subcategory", "severity", "description", "fingerprint"}
+
+// WriteCSV writes notes to w as comma-separated values, with a header row,
+// sorted by path and line. Fields are quoted as needed.
+func WriteCSV(w io.Writer, notes []*notepb.Note) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(CSVColumns); err != nil {
+		return err
+	}
+	for _, n := range sortedNotes(notes) {
+		if err := cw.Write(csvRecord(n)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteTSV writes notes to w as tab-separated values, with a header row,
+// sorted by path and line. Fields are never quoted; tabs and newlines in them
+// are replaced by spaces.
+func WriteTSV(w io.Writer, notes []*notepb.Note) error {
+	clean := strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ")
+	records := [][]string{CSVColumns}
+	for _, n := range sortedNotes(notes) {
+		records = append(records, csvRecord(n))
+	}
+	for _, r := range records {
+		for i := range r {
+			r[i] = clean.Replace(r[i])
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(r, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formulaPrefixes are the first characters that make a spreadsheet evaluate a
+// field as a formula.
+const formulaPrefixes = "=+-@\t\r"
+
+// spreadsheetSafe returns s with a ' in front if a spreadsheet would evaluate
+// it as a formula. Descriptions often quote the code analyzed, which may come
+// from anyone.
+func spreadsheetSafe(s string) string {
+	if s != "" && strings.IndexByte(formulaPrefixes, s[0]) >= 0 {
+		return "'" + s
+	}
+	return s
+}
+
+// csvRecord returns the fields of n, with those that analyzers report made
+// spreadsheetSafe.
+func csvRecord(n *notepb.Note) []string {
+	r := n.GetLocation().GetRange()
+	number := func(i int32) string {
+		if i == 0 {
+			return ""
+		}
+		return fmt.Sprint(i)
+	}
+	return []string{
+		spreadsheetSafe(n.GetLocation().GetPath()),
+		number(r.GetStartLine()),
+		number(r.GetStartColumn()),
+		spreadsheetSafe(n.GetCategory()),
+		spreadsheetSafe(n.GetSubcategory()),
+		n.GetSeverity().String(),
+		spreadsheetSafe(n.GetDescription()),
+		Fingerprint(n),
+	}
+}
+
+// sortedNotes returns a copy of notes sorted by path and start line and column.
+func sortedNotes(notes []*notepb.Note) []*notepb.Note {
+	sorted := append([]*notepb.Note(nil), notes...)
+	sort.Stable(byPosition(sorted))
+	return sorted
+}
+
+type byPosition []*notepb.Note
+
+func (n byPosition) Len() int      { return len(n) }
+func (n byPosition) Swap(i, j int) { n[i], n[j] = n[j], n[i] }
+func (n byPosition) Less(i, j int) bool {
+	li, lj := n[i].GetLocation(), n[j].GetLocation()
+	if li.GetPath() != lj.GetPath() {
+		return li.GetPath() < lj.GetPath()
+	}
+	if ri, rj := li.GetRange().GetStartLine(), lj.GetRange().GetStartLine(); ri != rj {
+		return ri < rj
+	}
+	return li.GetRange().GetStartColumn() < lj.GetRange().GetStartColumn()
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+)
+
+func csvNotes() []*notepb.Note {
+	global := tableNote("PyLint", "", 0, notepb.Note_OTHER, "A global note")
+	global.Location = nil
+	return []*notepb.Note{
+		tableNote("JSHint", "b.js", 3, notepb.Note_WARNING, "Missing semicolon"),
+		tableNote("go vet", "a.go", 12, notepb.Note_BUILD_ERROR, "Unreachable code,\n\"really\""),
+		tableNote("JSHint", "b.js", 1, notepb.Note_WARNING, "Tab\there"),
+		global,
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, csvNotes()); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Could not read CSV back: %v", err)
+	}
+	if len(records) != 5 {
+		t.Fatalf("Wrong number of records: got %d, want 5", len(records))
+	}
+	if got := records[0]; !reflect.DeepEqual(got, CSVColumns) {
+		t.Errorf("Wrong header: got %v, want %v", got, CSVColumns)
+	}
+	var paths []string
+	for _, r := range records[1:] {
+		paths = append(paths, r[0]+":"+r[1])
+	}
+	if want := []string{":", "a.go:12", "b.js:1", "b.js:3"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("Wrong order: got %v, want %v", paths, want)
+	}
+	got := records[2]
+	want := []string{"a.go", "12", "", "go vet", "", "BUILD_ERROR", "Unreachable code,\n\"really\"", got[7]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong record: got %q, want %q", got, want)
+	}
+	if got[7] == "" {
+		t.Errorf("Missing fingerprint")
+	}
+}
+
+func TestWriteTSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteTSV(&buf, csvNotes()); err != nil {
+		t.Fatalf("WriteTSV failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("Wrong number of lines: got %d, want 5 in %q", len(lines), buf.String())
+	}
+	if got, want := lines[0], strings.Join(CSVColumns, "\t"); got != want {
+		t.Errorf("Wrong header: got %q, want %q", got, want)
+	}
+	for _, l := range lines {
+		if n := len(strings.Split(l, "\t")); n != len(CSVColumns) {
+			t.Errorf("Wrong number of fields in %q: got %d, want %d", l, n, len(CSVColumns))
+		}
+	}
+	if got, want := strings.Split(lines[2], "\t")[6], "Unreachable code, \"really\""; got != want {
+		t.Errorf("Wrong description: got %q, want %q", got, want)
+	}
+	if got, want := strings.Split(lines[3], "\t")[6], "Tab here"; got != want {
+		t.Errorf("Wrong description: got %q, want %q", got, want)
+	}
+}
+
+func TestSpreadsheetFormulas(t *testing.T) {
+	notes := []*notepb.Note{
+		tableNote("PyLint", "=cmd|' /C calc'!A0.py", 1, notepb.Note_WARNING, "=HYPERLINK(\"http://example.com\")"),
+		tableNote("@evil", "a.py", 2, notepb.Note_WARNING, "+1 too many"),
+		tableNote("PyLint", "a.py", 3, notepb.Note_WARNING, "-x is unused"),
+		tableNote("PyLint", "a.py", 4, notepb.Note_WARNING, "No formula = here"),
+	}
+	want := [][]string{
+		{"'=cmd|' /C calc'!A0.py", "PyLint", "'=HYPERLINK(\"http://example.com\")"},
+		{"a.py", "'@evil", "'+1 too many"},
+		{"a.py", "PyLint", "'-x is unused"},
+		{"a.py", "PyLint", "No formula = here"},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, notes); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Could not read CSV back: %v", err)
+	}
+	buf.Reset()
+	if err := WriteTSV(&buf, notes); err != nil {
+		t.Fatalf("WriteTSV failed: %v", err)
+	}
+	for i, l := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")[1:] {
+		for format, r := range map[string][]string{"CSV": records[i+1], "TSV": strings.Split(l, "\t")} {
+			if got := []string{r[0], r[3], r[6]}; !reflect.DeepEqual(got, want[i]) {
+				t.Errorf("Wrong %s path, category and description: got %q, want %q", format, got, want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// Deduper removes notes that are identical to notes it has already seen, such
+// as a finding reported by both the pre-build and the post-build stage, or by
+// two analyzers that run the same tool. It is used on each response from the
+// service in turn, so that duplicates are dropped before they are printed.
+type Deduper struct {
+	seen       map[string]bool
+	duplicates int
+}
+
+// NewDeduper returns a Deduper that has seen no notes.
+func NewDeduper() *Deduper {
+	return &Deduper{seen: make(map[string]bool)}
+}
+
+// Filter removes the notes in resp that have been seen before, in resp or in
+// an earlier response, and returns how many it removed.
+func (d *Deduper) Filter(resp *rpcpb.ShipshapeResponse) int {
+	removed := 0
+	for _, analysis := range resp.AnalyzeResponse {
+		var kept []*notepb.Note
+		for _, note := range analysis.Note {
+			key := dedupKey(note)
+			if d.seen[key] {
+				removed++
+				continue
+			}
+			d.seen[key] = true
+			kept = append(kept, note)
+		}
+		analysis.Note = kept
+	}
+	d.duplicates += removed
+	return removed
+}
+
+// Duplicates returns the number of notes removed so far.
+func (d *Deduper) Duplicates() int {
+	return d.duplicates
+}
+
+// dedupKey identifies a finding. Unlike the Fingerprint, it includes the
+// range, since the same message on two different lines is two findings.
+func dedupKey(note *notepb.Note) string {
+	r := note.GetLocation().GetRange()
+	return fmt.Sprintf("%s:%d:%d:%d:%d", Fingerprint(note), r.GetStartLine(), r.GetStartColumn(), r.GetEndLine(), r.GetEndColumn())
+}
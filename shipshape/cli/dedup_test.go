@@ -0,0 +1,64 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+	textpb "github.com/google/shipshape/shipshape/proto/textrange_proto"
+)
+
+func noteAt(category, path string, line int32) *notepb.Note {
+	return &notepb.Note{
+		Category:    proto.String(category),
+		Description: proto.String("A note"),
+		Location: &notepb.Location{
+			Path:  proto.String(path),
+			Range: &textpb.TextRange{StartLine: proto.Int32(line)},
+		},
+	}
+}
+
+func TestDeduper(t *testing.T) {
+	d := NewDeduper()
+	preBuild := &rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{
+		{Note: []*notepb.Note{noteAt("JSHint", "a.js", 1), noteAt("JSHint", "a.js", 1), noteAt("JSHint", "a.js", 2)}},
+	}}
+	if got, want := d.Filter(preBuild), 1; got != want {
+		t.Errorf("Wrong number of duplicates in the first response: got %d, want %d", got, want)
+	}
+	if got, want := len(preBuild.AnalyzeResponse[0].Note), 2; got != want {
+		t.Errorf("Wrong number of notes kept: got %d, want %d", got, want)
+	}
+
+	postBuild := &rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{
+		{Note: []*notepb.Note{noteAt("JSHint", "a.js", 2), noteAt("ErrorProne", "a.js", 2)}},
+	}}
+	if got, want := d.Filter(postBuild), 1; got != want {
+		t.Errorf("Wrong number of duplicates in the second response: got %d, want %d", got, want)
+	}
+	if got := postBuild.AnalyzeResponse[0].Note; len(got) != 1 || got[0].GetCategory() != "ErrorProne" {
+		t.Errorf("Wrong notes kept: got %v, want only the ErrorProne note", got)
+	}
+	if got, want := d.Duplicates(), 2; got != want {
+		t.Errorf("Wrong total duplicates: got %d, want %d", got, want)
+	}
+}
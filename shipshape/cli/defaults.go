@@ -1,6 +1,23 @@
 package cli
 
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/google/shipshape/shipshape/service"
+)
+
 const (
-	DefaultEvent = "manual"
+	DefaultEvent = service.EventManual
 	DefaultRepo  = "beta.gcr.io/shipshape_releases"
 )
+
+// DefaultSnapshotDir is where the results of runs are cached by default.
+var DefaultSnapshotDir = filepath.Join(os.Getenv("HOME"), ".shipshape", "snapshots")
+
+// DefaultTranscodeDir is where copies of workspaces with files that are not plain
+// UTF-8 are staged, with those files transcoded, for the analyzers to see.
+var DefaultTranscodeDir = filepath.Join(os.Getenv("HOME"), ".shipshape", "transcoded")
+
+// DefaultImageUseFile is where the CLI records when it last used each image.
+var DefaultImageUseFile = filepath.Join(os.Getenv("HOME"), ".shipshape", "images.json")
@@ -0,0 +1,204 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/shipshape/shipshape/util/docker"
+	"github.com/google/shipshape/shipshape/util/redact"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// diagnosticEnvPrefixes are the environment variables that are recorded in a
+// diagnostics bundle. Only those that affect how shipshape talks to docker or
+// that describe the CI system are kept, since the rest may be private.
+var diagnosticEnvPrefixes = []string{"DOCKER_", "SHIPSHAPE_", "CI", "GITHUB_ACTIONS", "TRAVIS", "GITLAB_CI", "JENKINS_URL"}
+
+// Diagnostics is what is known about a run for a bug report: how the CLI was
+// invoked, what it asked the service and which containers it used.
+type Diagnostics struct {
+	// Args is the command line, and Flags are the values of all of the flags,
+	// as name=value.
+	Args  []string
+	Flags []string
+	// Err is the error that the run ended with, if any.
+	Err        error
+	RunID      string
+	Request    *rpcpb.ShipshapeRequest
+	Containers []string
+	Images     []string
+}
+
+// Diagnostics describes the run of the invocation, which ended with err.
+func (i *Invocation) Diagnostics(args, flags []string, err error) Diagnostics {
+	return Diagnostics{
+		Args:       args,
+		Flags:      flags,
+		Err:        err,
+		RunID:      i.options.RunID,
+		Request:    i.request,
+		Containers: i.containers,
+		Images:     i.images,
+	}
+}
+
+// InfrastructureFailed returns whether err, which Run returned, is a failure of
+// docker, the containers or the service, rather than of the options or the
+// config, which Run checks first, or an interruption by the user.
+func (i *Invocation) InfrastructureFailed(err error) bool {
+	if err == nil || !i.validated {
+		return false
+	}
+	se, ok := err.(streamError)
+	return !ok || se.err != errInterrupted
+}
+
+// WriteDiagnostics writes a gzipped tarball to path with the diagnostics, the
+// environment that the CLI ran in, docker inspect output and logs for each of
+// the containers, and the logs that the containers wrote to the host. Anything
+// registered with redact is redacted. Containers that are no longer running
+// are left out. Only the user can read the bundle.
+func WriteDiagnostics(path string, d Diagnostics) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("could not create diagnostics bundle: %v", err)
+	}
+	gz := gzip.NewWriter(f)
+	w := &bundleWriter{tw: tar.NewWriter(gz), modTime: time.Now()}
+
+	w.add("command_line.txt", strings.Join(d.Args, "\n"))
+	w.add("flags.txt", strings.Join(d.Flags, "\n"))
+	if d.Err != nil {
+		w.add("error.txt", d.Err.Error())
+	}
+	if d.RunID != "" {
+		w.add("run_id.txt", d.RunID)
+	}
+	if d.Request != nil {
+		w.add("request.txt", proto.MarshalTextString(d.Request))
+	}
+	w.add("environment.txt", environmentInfo(d.Images, os.Environ()))
+	if docker.HasDocker() {
+		for _, container := range d.Containers {
+			if exists, err := docker.ContainerExists(container); err != nil || !exists {
+				continue
+			}
+			if result := docker.Inspect(container); result.Err == nil {
+				w.add(filepath.Join("containers", container+".json"), result.Stdout)
+			}
+			result := docker.Logs(container)
+			w.add(filepath.Join("containers", container+".log"), result.Stdout+result.Stderr)
+		}
+	}
+	logs, _ := filepath.Glob(filepath.Join(localLogs, "shipshape.*.log"))
+	for _, logFile := range logs {
+		w.addFile(filepath.Join("logs", filepath.Base(logFile)), logFile)
+	}
+
+	if err := w.tw.Close(); w.err == nil {
+		w.err = err
+	}
+	if err := gz.Close(); w.err == nil {
+		w.err = err
+	}
+	if err := f.Close(); w.err == nil {
+		w.err = err
+	}
+	if w.err != nil {
+		return fmt.Errorf("could not write diagnostics bundle %s: %v", path, w.err)
+	}
+	return nil
+}
+
+// environmentInfo describes the CLI, the host, docker and the images, and
+// the environment variables in env that are safe to record.
+func environmentInfo(images []string, env []string) string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "shipshape %s, %s, %s/%s\n", Version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if docker.HasDocker() {
+		result := docker.Version()
+		fmt.Fprintf(&b, "\n%s%s\n", result.Stdout, result.Stderr)
+	} else {
+		b.WriteString("\ndocker is not in the PATH\n")
+	}
+	if len(images) > 0 {
+		b.WriteString("\nImages:\n")
+	}
+	for _, image := range images {
+		digest := docker.ImageDigest(image)
+		if digest == "" {
+			digest = "not found"
+		}
+		fmt.Fprintf(&b, "  %s %s\n", image, digest)
+	}
+	var vars []string
+	for _, kv := range env {
+		for _, prefix := range diagnosticEnvPrefixes {
+			if strings.HasPrefix(kv, prefix) {
+				vars = append(vars, kv)
+				break
+			}
+		}
+	}
+	sort.Strings(vars)
+	if len(vars) > 0 {
+		b.WriteString("\nEnvironment:\n  " + strings.Join(vars, "\n  ") + "\n")
+	}
+	return b.String()
+}
+
+// bundleWriter adds files to a tarball, keeping the first error.
+type bundleWriter struct {
+	tw      *tar.Writer
+	modTime time.Time
+	err     error
+}
+
+// add adds a file with contents, redacted.
+func (w *bundleWriter) add(name, contents string) {
+	if w.err != nil {
+		return
+	}
+	contents = redact.String(contents)
+	hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(contents)), ModTime: w.modTime}
+	if w.err = w.tw.WriteHeader(hdr); w.err == nil {
+		_, w.err = w.tw.Write([]byte(contents))
+	}
+}
+
+// addFile adds the file at path. Files that can't be read are
+// skipped, since the logs of a run are only there if its containers started.
+func (w *bundleWriter) addFile(name, path string) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	w.add(name, string(contents))
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/shipshape/shipshape/util/redact"
+
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func readBundle(t *testing.T, path string) map[string]string {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("Bundle is not gzipped: %v", err)
+	}
+	files := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		} else if err != nil {
+			t.Fatalf("Bundle is not a tarball: %v", err)
+		}
+		contents, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name] = string(contents)
+	}
+}
+
+func TestWriteDiagnostics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnostics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	redact.Register("hunter2")
+	d := Diagnostics{
+		Args:  []string{"shipshape", "--categories=PyLint", "."},
+		Flags: []string{"categories=PyLint", "webhook_url=https://hooks.example.com/hunter2"},
+		Err:   errors.New("HTTP client did not become healthy"),
+		Request: &rpcpb.ShipshapeRequest{
+			TriggeredCategory: []string{"PyLint"},
+			Event:             proto.String("manual"),
+			Stage:             ctxpb.Stage_PRE_BUILD.Enum(),
+		},
+		// Not running, so left out.
+		Containers: []string{"shipshape_test_no_such_container"},
+	}
+	path := filepath.Join(dir, "bundle.tar.gz")
+	if err := WriteDiagnostics(path, d); err != nil {
+		t.Fatalf("WriteDiagnostics failed: %v", err)
+	}
+	files := readBundle(t, path)
+	if fi, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if got, want := fi.Mode().Perm(), os.FileMode(0600); got != want {
+		t.Errorf("Wrong permissions for the bundle: got %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		file, contains string
+	}{
+		{"command_line.txt", "--categories=PyLint"},
+		{"flags.txt", "webhook_url=https://hooks.example.com/"},
+		{"error.txt", "did not become healthy"},
+		{"request.txt", `triggered_category: "PyLint"`},
+		{"environment.txt", "shipshape " + Version},
+	}
+	for _, test := range tests {
+		contents, ok := files[test.file]
+		if !ok {
+			t.Errorf("Bundle has no %s: got files %v", test.file, files)
+			continue
+		}
+		if !strings.Contains(contents, test.contains) {
+			t.Errorf("Wrong %s: got %q, want it to contain %q", test.file, contents, test.contains)
+		}
+	}
+	for name, contents := range files {
+		if strings.Contains(contents, "hunter2") {
+			t.Errorf("Secret not redacted from %s: %q", name, contents)
+		}
+		if strings.HasPrefix(name, "containers/") {
+			t.Errorf("Bundle has %s for a container that is not running", name)
+		}
+	}
+}
+
+func TestInfrastructureFailed(t *testing.T) {
+	failure := errors.New("HTTP client did not become healthy")
+	tests := []struct {
+		validated bool
+		err       error
+		want      bool
+	}{
+		{true, nil, false},
+		{false, errors.New("no such file or directory"), false},
+		{true, failure, true},
+		{true, streamError{failure}, true},
+		{true, streamError{errInterrupted}, false},
+	}
+	for _, test := range tests {
+		i := &Invocation{validated: test.validated}
+		if got := i.InfrastructureFailed(test.err); got != test.want {
+			t.Errorf("InfrastructureFailed(%v) after validating: %v = %v, want %v", test.err, test.validated, got, test.want)
+		}
+	}
+}
+
+func TestEnvironmentInfo(t *testing.T) {
+	info := environmentInfo(nil, []string{"DOCKER_HOST=tcp://docker:2375", "HOME=/home/me", "GITHUB_ACTIONS=true", "SSH_AUTH_SOCK=/tmp/agent"})
+	for _, want := range []string{"DOCKER_HOST=tcp://docker:2375", "GITHUB_ACTIONS=true"} {
+		if !strings.Contains(info, want) {
+			t.Errorf("Environment info is missing %s: got %q", want, info)
+		}
+	}
+	for _, unwanted := range []string{"HOME", "SSH_AUTH_SOCK"} {
+		if strings.Contains(info, unwanted) {
+			t.Errorf("Environment info has %s: got %q", unwanted, info)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// LoadResults reads a results file as written by --json_output, converting it
+// to the CurrentSchema if it was written with an earlier one.
+func LoadResults(path string) (*rpcpb.ShipshapeResponse, error) {
+	resp, err := ReadResults(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := ConvertSchema(resp, CurrentSchema); err != nil {
+		return nil, fmt.Errorf("could not read results in %s: %v", path, err)
+	}
+	return resp, nil
+}
+
+// ReadResults reads a results file as written by --json_output, in whichever
+// version of the schema it was written with.
+func ReadResults(path string) (*rpcpb.ShipshapeResponse, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var resp rpcpb.ShipshapeResponse
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return nil, fmt.Errorf("could not parse results in %s: %v", path, err)
+	}
+	return &resp, nil
+}
+
+// AllNotes returns the notes from all the analyze responses in resp.
+func AllNotes(resp *rpcpb.ShipshapeResponse) []*notepb.Note {
+	var notes []*notepb.Note
+	for _, analysis := range resp.AnalyzeResponse {
+		notes = append(notes, analysis.Note...)
+	}
+	return notes
+}
+
+// NoteDiff is the result of comparing the notes of two runs.
+type NoteDiff struct {
+	Introduced []*notepb.Note
+	Resolved   []*notepb.Note
+	// Unchanged is the number of notes found in both runs.
+	Unchanged int
+}
+
+// DiffNotes compares two sets of notes. Notes are first paired by
+// fingerprint. Any notes left over are then paired if they have the same
+// category and path and start within fuzzLines lines of each other, which
+// catches findings whose description changed slightly (e.g. because it
+// mentions a renamed variable). Remaining new notes are introduced;
+// remaining old notes are resolved.
+func DiffNotes(older, newer []*notepb.Note, fuzzLines int32) NoteDiff {
+	var d NoteDiff
+	byPrint := make(map[string][]int)
+	matched := make([]bool, len(older))
+	for i, n := range older {
+		fp := Fingerprint(n)
+		byPrint[fp] = append(byPrint[fp], i)
+	}
+
+	var unmatched []*notepb.Note
+	for _, n := range newer {
+		fp := Fingerprint(n)
+		if candidates := byPrint[fp]; len(candidates) > 0 {
+			matched[candidates[0]] = true
+			byPrint[fp] = candidates[1:]
+			d.Unchanged++
+		} else {
+			unmatched = append(unmatched, n)
+		}
+	}
+
+	for _, n := range unmatched {
+		best := -1
+		var bestDist int32
+		for i, o := range older {
+			if matched[i] || o.GetCategory() != n.GetCategory() || o.GetLocation().GetPath() != n.GetLocation().GetPath() {
+				continue
+			}
+			dist := o.GetLocation().GetRange().GetStartLine() - n.GetLocation().GetRange().GetStartLine()
+			if dist < 0 {
+				dist = -dist
+			}
+			if dist <= fuzzLines && (best == -1 || dist < bestDist) {
+				best, bestDist = i, dist
+			}
+		}
+		if best == -1 {
+			d.Introduced = append(d.Introduced, n)
+		} else {
+			matched[best] = true
+			d.Unchanged++
+		}
+	}
+
+	for i, o := range older {
+		if !matched[i] {
+			d.Resolved = append(d.Resolved, o)
+		}
+	}
+	return d
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/google/shipshape/shipshape/cli"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+)
+
+// diffCommand implements `shipshape diff`, which compares two results files
+// written by --json_output and reports the introduced and resolved notes.
+func diffCommand(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fuzzLines := fs.Int("fuzz_lines", 3, "Notes that did not match exactly are still considered the same if they have the same category and path and are at most this many lines apart")
+	fs.Usage = func() {
+		fmt.Println("USAGE: shipshape diff [flags] <old.json> <new.json>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return returnError
+	}
+
+	older, err := cli.LoadResults(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+	newer, err := cli.LoadResults(fs.Arg(1))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+
+	d := cli.DiffNotes(cli.AllNotes(older), cli.AllNotes(newer), int32(*fuzzLines))
+	printDiffNotes("Introduced", d.Introduced)
+	printDiffNotes("Resolved", d.Resolved)
+	fmt.Printf("%d introduced, %d resolved, %d unchanged\n", len(d.Introduced), len(d.Resolved), d.Unchanged)
+	if len(d.Introduced) > 0 {
+		return returnFindings
+	}
+	return returnNoFindings
+}
+
+func printDiffNotes(title string, notes []*notepb.Note) {
+	if len(notes) == 0 {
+		return
+	}
+	fmt.Printf("%s:\n", title)
+	for _, n := range notes {
+		loc := n.GetLocation().GetPath()
+		if line := n.GetLocation().GetRange().GetStartLine(); line != 0 {
+			loc = fmt.Sprintf("%s:%d", loc, line)
+		}
+		if loc == "" {
+			loc = "Global"
+		}
+		fmt.Printf("  %s [%s]\n\t%s\n", loc, n.GetCategory(), n.GetDescription())
+	}
+	fmt.Println()
+}
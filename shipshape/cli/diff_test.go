@@ -0,0 +1,88 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func TestLoadResults(t *testing.T) {
+	resp := rpcpb.ShipshapeResponse{
+		AnalyzeResponse: []*rpcpb.AnalyzeResponse{
+			{Note: []*notepb.Note{createNote("PyLint", "a.py", 3, "Unused import os")}},
+			{Note: []*notepb.Note{createNote("JSHint", "b.js", 1, "Missing semicolon")}},
+		},
+	}
+	f, err := ioutil.TempFile("", "diff_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if err := json.NewEncoder(f).Encode(resp); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	got, err := LoadResults(f.Name())
+	if err != nil {
+		t.Fatalf("Could not load results: %v", err)
+	}
+	notes := AllNotes(got)
+	if len(notes) != 2 || notes[1].GetCategory() != "JSHint" || notes[0].GetLocation().GetRange().GetStartLine() != 3 {
+		t.Errorf("Wrong notes loaded: got %v", notes)
+	}
+}
+
+func TestDiffNotes(t *testing.T) {
+	older := []*notepb.Note{
+		createNote("PyLint", "a.py", 3, "Unused import os"),
+		createNote("PyLint", "a.py", 20, "Unused variable 'foo'"),
+		createNote("PyLint", "a.py", 40, "Line too long"),
+		createNote("JSHint", "b.js", 1, "Missing semicolon"),
+	}
+	newer := []*notepb.Note{
+		// Moved down, same fingerprint.
+		createNote("PyLint", "a.py", 8, "Unused import os"),
+		// Variable renamed; matches fuzzily.
+		createNote("PyLint", "a.py", 21, "Unused variable 'bar'"),
+		// Too far away to match fuzzily.
+		createNote("PyLint", "a.py", 60, "Line too long, really"),
+		createNote("JSHint", "c.js", 1, "Missing semicolon"),
+	}
+
+	d := DiffNotes(older, newer, 3)
+	if got, want := d.Unchanged, 2; got != want {
+		t.Errorf("Wrong number of unchanged notes: got %d, want %d", got, want)
+	}
+	if got, want := len(d.Introduced), 2; got != want {
+		t.Errorf("Wrong number of introduced notes: got %d, want %d (%v)", got, want, d.Introduced)
+	}
+	if got, want := len(d.Resolved), 2; got != want {
+		t.Errorf("Wrong number of resolved notes: got %d, want %d (%v)", got, want, d.Resolved)
+	}
+
+	d = DiffNotes(older, newer, 0)
+	if got, want := d.Unchanged, 1; got != want {
+		t.Errorf("Wrong number of unchanged notes without fuzzing: got %d, want %d", got, want)
+	}
+}
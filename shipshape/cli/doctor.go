@@ -0,0 +1,198 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/shipshape/shipshape/util/docker"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+const (
+	// doctorSample is the file that `shipshape doctor` analyzes, and
+	// doctorSampleLine is the line of it that PyLint must report as
+	// unreachable.
+	doctorSample     = "doctor_sample.py"
+	doctorSampleLine = 5
+	doctorSampleCode = `"""A sample for shipshape doctor."""
+
+def sample():
+    return 1
+    print("unreachable")
+`
+)
+
+// DoctorResult is the outcome of one of the self-checks of `shipshape doctor`.
+type DoctorResult struct {
+	Check string
+	// Err is why the check failed, or nil if it passed.
+	Err error
+	// Remedy tells the user how to fix a failed check.
+	Remedy string
+	// Skipped is true if the check was not run because an earlier one failed.
+	Skipped bool
+}
+
+// doctorCheck is a self-check, which returns an error if it fails. If the
+// error is a docker.Problem, its remedy is used rather than the check's.
+type doctorCheck struct {
+	name   string
+	run    func() error
+	remedy string
+}
+
+// Doctor checks, in order, that docker is reachable, that the images of the
+// run described by options can be pulled, that the service starts and
+// becomes healthy, and that analyzing a sample file gives the expected note.
+// Each check needs the ones before it, so once one fails the rest are
+// skipped.
+func Doctor(options Options) []DoctorResult {
+	i := New(options)
+	images := append([]string{docker.FullImageName(options.Repo, image, options.Tag)}, options.ThirdPartyAnalyzers...)
+
+	sample, err := ioutil.TempDir("", "shipshape-doctor")
+	if err != nil {
+		return []DoctorResult{{Check: "sample workspace", Err: err, Remedy: "Make sure the temporary directory is writable."}}
+	}
+	defer os.RemoveAll(sample)
+
+	return runChecks([]doctorCheck{
+		{
+			name: "docker",
+			run: func() error {
+				if problems := docker.Preflight(i.dockerAccess() == docker.DindAccess); len(problems) > 0 {
+					return problems[0]
+				}
+				return nil
+			},
+		},
+		{
+			name: "images",
+			run: func() error {
+				return checkImages(images, options.Tag == "local")
+			},
+			remedy: "Check the network connection and that you can log in to the registry. With --tag=local, build the images first, e.g. 'bazel build //shipshape/docker:service'.",
+		},
+		{
+			name: "service",
+			run: func() error {
+				if err := ioutil.WriteFile(filepath.Join(sample, doctorSample), []byte(doctorSampleCode), 0644); err != nil {
+					return err
+				}
+				// The sample run reuses this container, since it is mapped to the
+				// same directory.
+				_, _, err := startShipshapeService(images[0], sample, nil, i.dockerAccess(), "", true)
+				return err
+			},
+			remedy: "Look for errors in /tmp/shipshape.shipping_container.log.",
+		},
+		{
+			name: "sample analysis",
+			run: func() error {
+				return checkSample(sample, options)
+			},
+			remedy: "Look for errors in /tmp/shipshape.go_dispatcher.log. If the service image is out of date, pull it again.",
+		},
+	})
+}
+
+// runChecks runs the checks in order, skipping the rest once one fails.
+func runChecks(checks []doctorCheck) []DoctorResult {
+	var results []DoctorResult
+	failed := false
+	for _, c := range checks {
+		r := DoctorResult{Check: c.name, Remedy: c.remedy, Skipped: failed}
+		if !failed {
+			r.Err = c.run()
+			if p, ok := r.Err.(docker.Problem); ok {
+				r.Err, r.Remedy = fmt.Errorf("%s: %s", p.Check, p.Message), p.Remedy
+			}
+			failed = r.Err != nil
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// checkImages pulls each of the images, or if local is true, checks that they
+// have been built.
+func checkImages(images []string, local bool) error {
+	var missing []string
+	for _, image := range images {
+		if !local {
+			if result := docker.Pull(image); result.Err != nil {
+				if p := docker.PullProblem(image, result.Stderr); p != nil {
+					return *p
+				}
+				return fmt.Errorf("could not pull %s: %v %s", image, result.Err, strings.TrimSpace(result.Stderr))
+			}
+		}
+		if docker.ImageDigest(image) == "" {
+			missing = append(missing, image)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("not found locally: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkSample analyzes the sample workspace with PyLint and checks that it
+// reports the unreachable line.
+func checkSample(sample string, options Options) error {
+	var responses []*rpcpb.ShipshapeResponse
+	options.File = sample
+	options.TriggerCats = []string{"PyLint"}
+	options.ThirdPartyAnalyzers = nil
+	options.Build = ""
+	options.StayUp = false
+	options.Deterministic = true
+	options.SnapshotDir = ""
+	options.HandleResponse = func(msg *rpcpb.ShipshapeResponse, _ string) error {
+		responses = append(responses, msg)
+		return nil
+	}
+	if _, err := New(options).Run(); err != nil {
+		return err
+	}
+	return sampleNotesFound(responses)
+}
+
+// sampleNotesFound returns an error unless the responses have no failures
+// and a PyLint note on the unreachable line of the sample.
+func sampleNotesFound(responses []*rpcpb.ShipshapeResponse) error {
+	for _, resp := range responses {
+		for _, analysis := range resp.AnalyzeResponse {
+			if len(analysis.Failure) > 0 {
+				f := analysis.Failure[0]
+				return fmt.Errorf("%s failed: %s", f.GetCategory(), f.GetFailureMessage())
+			}
+			for _, note := range analysis.Note {
+				if note.GetCategory() == "PyLint" && filepath.Base(note.GetLocation().GetPath()) == doctorSample && note.GetLocation().GetRange().GetStartLine() == doctorSampleLine {
+					return nil
+				}
+			}
+		}
+	}
+	return fmt.Errorf("PyLint did not report the unreachable code on line %d of the sample", doctorSampleLine)
+}
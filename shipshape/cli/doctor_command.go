@@ -0,0 +1,69 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/google/shipshape/shipshape/cli"
+)
+
+// doctorCommand implements `shipshape doctor`, which checks that shipshape
+// can run on this machine, from docker up to analyzing a sample file, and
+// says how to fix the first thing that is wrong.
+func doctorCommand(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	repo := fs.String("repo", cli.DefaultRepo, "The name of the docker repo to use")
+	tag := fs.String("tag", "prod", "Tag to use for the analysis service image. If this is local, we will not attempt to pull the image.")
+	analyzers := fs.String("analyzer_images", "", "Full docker path to images of external analyzers to check that they can be pulled (comma-separated)")
+	dind := fs.Bool("inside_docker", false, "True if the CLI is run from inside a docker container")
+	dockerAccess := fs.String("docker_access", "dind", "How the containers shipshape starts can use docker: 'dind' or 'socket', as for a run")
+	fs.Usage = func() {
+		fmt.Println("USAGE: shipshape doctor [flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return returnError
+	}
+
+	options := cli.Options{
+		Repo:         *repo,
+		Tag:          *tag,
+		Dind:         *dind,
+		DockerSocket: *dockerAccess == "socket",
+	}
+	if *analyzers != "" {
+		options.ThirdPartyAnalyzers = strings.Split(*analyzers, ",")
+	}
+	status := returnNoFindings
+	for _, r := range cli.Doctor(options) {
+		switch {
+		case r.Skipped:
+			fmt.Printf("SKIP %s\n", r.Check)
+		case r.Err != nil:
+			fmt.Printf("FAIL %s: %v\n\t%s\n", r.Check, r.Err, r.Remedy)
+			status = returnError
+		default:
+			fmt.Printf("PASS %s\n", r.Check)
+		}
+	}
+	return status
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/shipshape/shipshape/util/docker"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+	rangepb "github.com/google/shipshape/shipshape/proto/textrange_proto"
+)
+
+func TestRunChecks(t *testing.T) {
+	var ran []string
+	check := func(name string, err error) doctorCheck {
+		return doctorCheck{
+			name:   name,
+			run:    func() error { ran = append(ran, name); return err },
+			remedy: "fix " + name,
+		}
+	}
+	results := runChecks([]doctorCheck{
+		check("first", nil),
+		check("second", docker.Problem{Check: "docker daemon", Message: "not running", Remedy: "start it"}),
+		check("third", nil),
+	})
+
+	if got, want := len(ran), 2; got != want {
+		t.Errorf("Wrong number of checks run: got %v, want %d", ran, want)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Wrong number of results: got %v, want 3", results)
+	}
+	if r := results[0]; r.Err != nil || r.Skipped {
+		t.Errorf("Wrong result for a passing check: got %+v", r)
+	}
+	if r := results[1]; r.Err == nil || r.Err.Error() != "docker daemon: not running" || r.Remedy != "start it" {
+		t.Errorf("Wrong result for a docker problem: got %+v", r)
+	}
+	if r := results[2]; !r.Skipped || r.Err != nil {
+		t.Errorf("Check after a failure was not skipped: got %+v", r)
+	}
+
+	if r := runChecks([]doctorCheck{check("plain", errors.New("boom"))})[0]; r.Remedy != "fix plain" {
+		t.Errorf("Wrong remedy for a plain error: got %q, want %q", r.Remedy, "fix plain")
+	}
+}
+
+func TestSampleNotesFound(t *testing.T) {
+	note := func(category, path string, line int32) *notepb.Note {
+		return &notepb.Note{
+			Category: proto.String(category),
+			Location: &notepb.Location{Path: proto.String(path), Range: &rangepb.TextRange{StartLine: proto.Int32(line)}},
+		}
+	}
+	tests := []struct {
+		desc     string
+		analysis *rpcpb.AnalyzeResponse
+		ok       bool
+	}{
+		{"expected note", &rpcpb.AnalyzeResponse{Note: []*notepb.Note{note("PyLint", doctorSample, 1), note("PyLint", doctorSample, doctorSampleLine)}}, true},
+		{"no notes", &rpcpb.AnalyzeResponse{}, false},
+		{"wrong line", &rpcpb.AnalyzeResponse{Note: []*notepb.Note{note("PyLint", doctorSample, 1)}}, false},
+		{"wrong category", &rpcpb.AnalyzeResponse{Note: []*notepb.Note{note("JSHint", doctorSample, doctorSampleLine)}}, false},
+		{"failure", &rpcpb.AnalyzeResponse{
+			Note:    []*notepb.Note{note("PyLint", doctorSample, doctorSampleLine)},
+			Failure: []*rpcpb.AnalysisFailure{{Category: proto.String("PyLint"), FailureMessage: proto.String("pylint not found")}},
+		}, false},
+	}
+	for _, test := range tests {
+		err := sampleNotesFound([]*rpcpb.ShipshapeResponse{{AnalyzeResponse: []*rpcpb.AnalyzeResponse{test.analysis}}})
+		if got := err == nil; got != test.ok {
+			t.Errorf("%s: got error %v, want ok %v", test.desc, err, test.ok)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+)
+
+// efforts are the efforts that notes are grouped by, from the least work to
+// fix to the most, with the names they are reported under. Notes whose effort
+// is unknown come last.
+var efforts = []struct {
+	effort notepb.Note_Effort
+	name   string
+}{
+	{notepb.Note_AUTO_FIXABLE, "auto-fixable"},
+	{notepb.Note_TRIVIAL, "trivial"},
+	{notepb.Note_MANUAL, "manual"},
+	{notepb.Note_UNKNOWN_EFFORT, "unclassified"},
+}
+
+// EffortCount is the number of notes that take the same effort to fix.
+type EffortCount struct {
+	// Effort is auto-fixable, trivial, manual or unclassified.
+	Effort string `json:"effort"`
+	Count  int    `json:"count"`
+}
+
+// NoteEffort returns how much work fixing note takes: the effort its analyzer
+// gave it, or, if it gave none, AUTO_FIXABLE for a note with a fix.
+func NoteEffort(note *notepb.Note) notepb.Note_Effort {
+	if e := note.GetEffort(); e != notepb.Note_UNKNOWN_EFFORT {
+		return e
+	}
+	if len(note.Fix) > 0 {
+		return notepb.Note_AUTO_FIXABLE
+	}
+	return notepb.Note_UNKNOWN_EFFORT
+}
+
+// CountEfforts groups notes by the effort to fix them, from the least work to
+// the most, leaving out the efforts without notes. It returns nil if none of
+// the notes are classified, since then there is nothing to plan with.
+func CountEfforts(notes []*notepb.Note) []EffortCount {
+	counts := make(map[notepb.Note_Effort]int)
+	for _, note := range notes {
+		counts[NoteEffort(note)]++
+	}
+	if counts[notepb.Note_UNKNOWN_EFFORT] == len(notes) {
+		return nil
+	}
+	var result []EffortCount
+	for _, e := range efforts {
+		if n := counts[e.effort]; n > 0 {
+			result = append(result, EffortCount{e.name, n})
+		}
+	}
+	return result
+}
+
+// DescribeEfforts describes counts for the text output, e.g. "600
+// auto-fixable notes, 40 manual".
+func DescribeEfforts(counts []EffortCount) string {
+	var parts []string
+	for i, c := range counts {
+		part := fmt.Sprintf("%d %s", c.Count, c.Effort)
+		if i == 0 {
+			part += " notes"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
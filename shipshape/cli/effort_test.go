@@ -0,0 +1,74 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+)
+
+func effortNote(effort notepb.Note_Effort, fixed bool) *notepb.Note {
+	note := makeNotes("PyLint", 1)[0]
+	if effort != notepb.Note_UNKNOWN_EFFORT {
+		note.Effort = effort.Enum()
+	}
+	if fixed {
+		note.Fix = []*notepb.Fix{{}}
+	}
+	return note
+}
+
+func TestNoteEffort(t *testing.T) {
+	tests := []struct {
+		note *notepb.Note
+		want notepb.Note_Effort
+	}{
+		{effortNote(notepb.Note_MANUAL, false), notepb.Note_MANUAL},
+		{effortNote(notepb.Note_TRIVIAL, true), notepb.Note_TRIVIAL},
+		{effortNote(notepb.Note_UNKNOWN_EFFORT, true), notepb.Note_AUTO_FIXABLE},
+		{effortNote(notepb.Note_UNKNOWN_EFFORT, false), notepb.Note_UNKNOWN_EFFORT},
+	}
+	for _, test := range tests {
+		if got := NoteEffort(test.note); got != test.want {
+			t.Errorf("NoteEffort(%v) = %v, want %v", test.note, got, test.want)
+		}
+	}
+}
+
+func TestCountEfforts(t *testing.T) {
+	notes := []*notepb.Note{
+		effortNote(notepb.Note_MANUAL, false),
+		effortNote(notepb.Note_UNKNOWN_EFFORT, true),
+		effortNote(notepb.Note_AUTO_FIXABLE, false),
+		effortNote(notepb.Note_UNKNOWN_EFFORT, false),
+		effortNote(notepb.Note_AUTO_FIXABLE, false),
+	}
+	got := CountEfforts(notes)
+	want := []EffortCount{{"auto-fixable", 3}, {"manual", 1}, {"unclassified", 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CountEfforts = %v, want %v", got, want)
+	}
+	if got, want := DescribeEfforts(got), "3 auto-fixable notes, 1 manual, 1 unclassified"; got != want {
+		t.Errorf("DescribeEfforts = %q, want %q", got, want)
+	}
+
+	if got := CountEfforts(makeNotes("PyLint", 3)); got != nil {
+		t.Errorf("CountEfforts of unclassified notes = %v, want nil", got)
+	}
+}
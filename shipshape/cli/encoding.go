@@ -0,0 +1,402 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// Encoding is the character encoding of a file, as found by DetectEncoding.
+type Encoding string
+
+const (
+	EncodingUTF8    Encoding = "utf-8"
+	EncodingUTF8BOM Encoding = "utf-8-bom"
+	EncodingUTF16LE Encoding = "utf-16le"
+	EncodingUTF16BE Encoding = "utf-16be"
+	EncodingLatin1  Encoding = "latin-1"
+	// EncodingBinary is for files with NUL bytes and no byte order mark, which
+	// are not text.
+	EncodingBinary Encoding = "binary"
+	// EncodingUnknown is for text in none of the encodings above.
+	EncodingUnknown Encoding = "unknown"
+)
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+// DetectEncoding finds the encoding of data. UTF-16 is only recognized by its
+// byte order mark. Text that is not UTF-8 is taken to be Latin-1, unless it has
+// bytes in 0x80-0x9f, which are control characters in Latin-1 and more likely
+// to be from another encoding, such as Windows-1252.
+func DetectEncoding(data []byte) Encoding {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		if utf8.Valid(data[len(bomUTF8):]) {
+			return EncodingUTF8BOM
+		}
+		return EncodingUnknown
+	case bytes.HasPrefix(data, bomUTF16LE):
+		if _, _, ok := decodeUTF16(data, binary.LittleEndian); ok {
+			return EncodingUTF16LE
+		}
+		return EncodingUnknown
+	case bytes.HasPrefix(data, bomUTF16BE):
+		if _, _, ok := decodeUTF16(data, binary.BigEndian); ok {
+			return EncodingUTF16BE
+		}
+		return EncodingUnknown
+	case bytes.IndexByte(data, 0) >= 0:
+		return EncodingBinary
+	case utf8.Valid(data):
+		return EncodingUTF8
+	}
+	for _, b := range data {
+		if b >= 0x80 && b < 0xa0 {
+			return EncodingUnknown
+		}
+	}
+	return EncodingLatin1
+}
+
+// Transcode converts data in enc to UTF-8 without a byte order mark. It also
+// returns the offset in data of each byte of the result, and of its end.
+func Transcode(data []byte, enc Encoding) ([]byte, []int, error) {
+	var out []byte
+	var offsets []int
+	switch enc {
+	case EncodingUTF8:
+		out = data
+		for i := range data {
+			offsets = append(offsets, i)
+		}
+	case EncodingUTF8BOM:
+		out = data[len(bomUTF8):]
+		for i := range out {
+			offsets = append(offsets, i+len(bomUTF8))
+		}
+	case EncodingLatin1:
+		for i, b := range data {
+			before := len(out)
+			out = append(out, string(rune(b))...)
+			for range out[before:] {
+				offsets = append(offsets, i)
+			}
+		}
+	case EncodingUTF16LE, EncodingUTF16BE:
+		order := binary.ByteOrder(binary.LittleEndian)
+		if enc == EncodingUTF16BE {
+			order = binary.BigEndian
+		}
+		var ok bool
+		if out, offsets, ok = decodeUTF16(data, order); !ok {
+			return nil, nil, fmt.Errorf("not valid %s", enc)
+		}
+	default:
+		return nil, nil, fmt.Errorf("can not transcode %s", enc)
+	}
+	return out, append(offsets, len(data)), nil
+}
+
+// decodeUTF16 decodes data, which starts with a byte order mark, to UTF-8 and the
+// offset in data of each byte of the result. It fails if data has unpaired surrogates.
+func decodeUTF16(data []byte, order binary.ByteOrder) ([]byte, []int, bool) {
+	if len(data)%2 != 0 {
+		return nil, nil, false
+	}
+	var out []byte
+	var offsets []int
+	for i := 2; i < len(data); i += 2 {
+		start := i
+		r := rune(order.Uint16(data[i:]))
+		if utf16.IsSurrogate(r) {
+			if i+4 > len(data) {
+				return nil, nil, false
+			}
+			i += 2
+			if r = utf16.DecodeRune(r, rune(order.Uint16(data[i:]))); r == utf8.RuneError {
+				return nil, nil, false
+			}
+		}
+		before := len(out)
+		out = append(out, string(r)...)
+		for range out[before:] {
+			offsets = append(offsets, start)
+		}
+	}
+	return out, offsets, true
+}
+
+// encodeAs converts s from UTF-8 to enc, without a byte order mark. It fails if
+// enc can not represent s.
+func encodeAs(s string, enc Encoding) (string, bool) {
+	switch enc {
+	case EncodingUTF8, EncodingUTF8BOM:
+		return s, true
+	case EncodingLatin1:
+		var out []byte
+		for _, r := range s {
+			if r > 0xff {
+				return "", false
+			}
+			out = append(out, byte(r))
+		}
+		return string(out), true
+	case EncodingUTF16LE, EncodingUTF16BE:
+		order := binary.ByteOrder(binary.LittleEndian)
+		if enc == EncodingUTF16BE {
+			order = binary.BigEndian
+		}
+		var out []byte
+		for _, u := range utf16.Encode([]rune(s)) {
+			var b [2]byte
+			order.PutUint16(b[:], u)
+			out = append(out, b[:]...)
+		}
+		return string(out), true
+	}
+	return "", false
+}
+
+// EncodingReport describes the files of a workspace that are not plain UTF-8
+// or binary.
+type EncodingReport struct {
+	// Transcoded maps the files that the analyzers see converted to UTF-8 to
+	// their encodings.
+	Transcoded map[string]Encoding
+	// Undecodable are the text files in none of the encodings that can be
+	// transcoded, sorted. The analyzers see them as they are.
+	Undecodable []string
+	// offsets map the byte offsets in each transcoded file to those in the original.
+	offsets map[string][]int
+}
+
+// DetectEncodings finds the encodings of files, which are relative to root.
+func DetectEncodings(root string, files []string) (*EncodingReport, error) {
+	r := &EncodingReport{Transcoded: make(map[string]Encoding), offsets: make(map[string][]int)}
+	for _, f := range files {
+		data, err := ioutil.ReadFile(filepath.Join(root, f))
+		if err != nil {
+			return nil, err
+		}
+		switch enc := DetectEncoding(data); enc {
+		case EncodingUTF8, EncodingBinary:
+		case EncodingUnknown:
+			r.Undecodable = append(r.Undecodable, f)
+		default:
+			r.Transcoded[f] = enc
+		}
+	}
+	sort.Strings(r.Undecodable)
+	return r, nil
+}
+
+// vcsDirs are the version control directories that Stage leaves out, since the
+// analyzers only read the files of the workspace.
+var vcsDirs = map[string]bool{".git": true, ".hg": true, ".svn": true}
+
+// Stage makes dir a copy of root with the transcoded files converted to UTF-8.
+// The other files are hard links to the originals where possible, so that the
+// copy is cheap. A copy left in dir by an earlier run is updated in place: only
+// the files that changed since are linked or copied again, and those that are
+// gone from root are removed.
+func (r *EncodingReport) Stage(root, dir string) error {
+	staged := map[string]bool{".": true}
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() && rel != "." && vcsDirs[fi.Name()] {
+			return filepath.SkipDir
+		}
+		staged[rel] = true
+		dst := filepath.Join(dir, rel)
+		old, err := os.Lstat(dst)
+		if err != nil {
+			old = nil
+		}
+		switch {
+		case fi.IsDir():
+			if old != nil && !old.IsDir() {
+				if err := os.Remove(dst); err != nil {
+					return err
+				}
+			}
+			return os.MkdirAll(dst, fi.Mode().Perm()|0700)
+		case fi.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if current, err := os.Readlink(dst); err == nil && current == target {
+				return nil
+			}
+			if err := os.RemoveAll(dst); err != nil {
+				return err
+			}
+			return os.Symlink(target, dst)
+		case !fi.Mode().IsRegular():
+			return nil
+		}
+		enc, ok := r.Transcoded[filepath.ToSlash(rel)]
+		if !ok && old != nil && old.Mode().IsRegular() && (os.SameFile(fi, old) || old.Size() == fi.Size() && old.ModTime().Equal(fi.ModTime())) {
+			return nil
+		}
+		// dst may be a hard link to the original, so it is replaced rather than
+		// written to.
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+		if !ok {
+			if err := os.Link(path, dst); err == nil {
+				return nil
+			}
+			if err := copyFile(path, dst); err != nil {
+				return err
+			}
+			return os.Chtimes(dst, fi.ModTime(), fi.ModTime())
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		out, offsets, err := Transcode(data, enc)
+		if err != nil {
+			return fmt.Errorf("could not transcode %s: %v", rel, err)
+		}
+		r.offsets[filepath.ToSlash(rel)] = offsets
+		return ioutil.WriteFile(dst, out, fi.Mode().Perm())
+	})
+	if err == nil {
+		err = filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil || staged[rel] {
+				return err
+			}
+			if err := os.RemoveAll(path); err != nil {
+				return err
+			}
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("could not copy %s to %s: %v", root, dir, err)
+	}
+	return nil
+}
+
+// Restore maps the byte offsets of the notes in resp on transcoded files back
+// to the original files, and the replacements of their fixes back to the
+// original encodings. Fixes whose new content the original encoding can not
+// represent are left out. It returns the number of fixes left out.
+func (r *EncodingReport) Restore(resp *rpcpb.ShipshapeResponse) int {
+	dropped := 0
+	for _, analysis := range resp.AnalyzeResponse {
+		for _, note := range analysis.Note {
+			if loc := note.Location; loc != nil && loc.Range != nil {
+				if offsets, ok := r.offsets[loc.GetPath()]; ok {
+					if loc.Range.StartOffset != nil {
+						loc.Range.StartOffset = proto.Int32(int32(original(offsets, int(loc.Range.GetStartOffset()))))
+					}
+					if loc.Range.EndOffset != nil {
+						loc.Range.EndOffset = proto.Int32(int32(original(offsets, int(loc.Range.GetEndOffset()))))
+					}
+				}
+			}
+			var fixes []*notepb.Fix
+			for _, fix := range note.Fix {
+				if r.restoreFix(fix) {
+					fixes = append(fixes, fix)
+				} else {
+					dropped++
+				}
+			}
+			note.Fix = fixes
+		}
+	}
+	return dropped
+}
+
+// restoreFix restores the replacements of fix, returning false if one of them
+// can not be represented in the encoding of its file.
+func (r *EncodingReport) restoreFix(fix *notepb.Fix) bool {
+	for _, rep := range fix.Replacement {
+		offsets, ok := r.offsets[rep.GetPath()]
+		if !ok {
+			continue
+		}
+		if rep.NewContent != nil {
+			content, ok := encodeAs(rep.GetNewContent(), r.Transcoded[rep.GetPath()])
+			if !ok {
+				return false
+			}
+			rep.NewContent = proto.String(content)
+		}
+		if rng := rep.Range; rng != nil {
+			for _, pos := range []*notepb.FixRange_Position{rng.Start, rng.End} {
+				if pos != nil && pos.Byte != nil {
+					pos.Byte = proto.Uint32(uint32(original(offsets, int(pos.GetByte()))))
+				}
+			}
+		}
+	}
+	return true
+}
+
+// original returns the offset in the original file of offset in the transcoded one.
+func original(offsets []int, offset int) int {
+	if offset < 0 {
+		return offset
+	}
+	if offset >= len(offsets) {
+		return offsets[len(offsets)-1]
+	}
+	return offsets[offset]
+}
+
+// stagingDir returns the directory in dir that the copy of the workspace at
+// absRoot is staged in, which is the same for every run on it.
+func stagingDir(dir, absRoot string) string {
+	return filepath.Join(dir, fmt.Sprintf("%x", sha256.Sum256([]byte(absRoot)))[:16])
+}
@@ -0,0 +1,225 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+	textpb "github.com/google/shipshape/shipshape/proto/textrange_proto"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		data string
+		want Encoding
+	}{
+		{"", EncodingUTF8},
+		{"caf\xc3\xa9\n", EncodingUTF8},
+		{"\xef\xbb\xbfcaf\xc3\xa9\n", EncodingUTF8BOM},
+		{"\xef\xbb\xbfcaf\xe9\n", EncodingUnknown},
+		{"\xff\xfec\x00a\x00f\x00\xe9\x00", EncodingUTF16LE},
+		{"\xfe\xff\x00c\x00a\x00f\x00\xe9", EncodingUTF16BE},
+		{"\xff\xfec\x00a", EncodingUnknown},
+		{"\xff\xfe\x00\xd8c\x00", EncodingUnknown},
+		{"\x7fELF\x02\x01\x01\x00", EncodingBinary},
+		{"caf\xe9\n", EncodingLatin1},
+		{"\x93quoted\x94\n", EncodingUnknown},
+	}
+	for _, test := range tests {
+		if got := DetectEncoding([]byte(test.data)); got != test.want {
+			t.Errorf("DetectEncoding(%q) = %s, want %s", test.data, got, test.want)
+		}
+	}
+}
+
+func TestTranscode(t *testing.T) {
+	tests := []struct {
+		data    string
+		enc     Encoding
+		want    string
+		offsets []int
+	}{
+		{"\xef\xbb\xbfab", EncodingUTF8BOM, "ab", []int{3, 4, 5}},
+		{"a\xe9b", EncodingLatin1, "a\xc3\xa9b", []int{0, 1, 1, 2, 3}},
+		{"\xff\xfea\x00\x3d\xd8\x00\xdeb\x00", EncodingUTF16LE, "a\xf0\x9f\x98\x80b", []int{2, 4, 4, 4, 4, 8, 10}},
+		{"\xfe\xff\x00a\x00\xe9", EncodingUTF16BE, "a\xc3\xa9", []int{2, 4, 4, 6}},
+	}
+	for _, test := range tests {
+		got, offsets, err := Transcode([]byte(test.data), test.enc)
+		if err != nil {
+			t.Errorf("Transcode(%q, %s) failed: %v", test.data, test.enc, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("Transcode(%q, %s) = %q, want %q", test.data, test.enc, got, test.want)
+		}
+		if !reflect.DeepEqual(offsets, test.offsets) {
+			t.Errorf("Wrong offsets for Transcode(%q, %s): got %v, want %v", test.data, test.enc, offsets, test.offsets)
+		}
+	}
+	if _, _, err := Transcode([]byte("\x93"), EncodingUnknown); err == nil {
+		t.Errorf("Expected an error transcoding an unknown encoding")
+	}
+}
+
+func TestStageAndRestore(t *testing.T) {
+	root, err := ioutil.TempDir("", "shipshape-encoding")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	workspace := filepath.Join(root, "workspace")
+	writeFiles(t, workspace, map[string]string{
+		"plain.py":     "x = 1\n",
+		"src/latin.py": "s = 'caf\xe9'\n",
+		"src/bom.js":   "\xef\xbb\xbfvar x;\n",
+		"cp1252.txt":   "\x93quoted\x94\n",
+		".git/HEAD":    "ref: refs/heads/main\n",
+	})
+
+	r, err := DetectEncodings(workspace, []string{"plain.py", "src/latin.py", "src/bom.js", "cp1252.txt"})
+	if err != nil {
+		t.Fatalf("DetectEncodings failed: %v", err)
+	}
+	if want := map[string]Encoding{"src/latin.py": EncodingLatin1, "src/bom.js": EncodingUTF8BOM}; !reflect.DeepEqual(r.Transcoded, want) {
+		t.Errorf("Wrong transcoded files: got %v, want %v", r.Transcoded, want)
+	}
+	if want := []string{"cp1252.txt"}; !reflect.DeepEqual(r.Undecodable, want) {
+		t.Errorf("Wrong undecodable files: got %v, want %v", r.Undecodable, want)
+	}
+
+	staged := stagingDir(filepath.Join(root, "staged"), workspace)
+	if err := r.Stage(workspace, staged); err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	for path, want := range map[string]string{
+		"plain.py":     "x = 1\n",
+		"src/latin.py": "s = 'caf\xc3\xa9'\n",
+		"src/bom.js":   "var x;\n",
+		"cp1252.txt":   "\x93quoted\x94\n",
+	} {
+		if got, err := ioutil.ReadFile(filepath.Join(staged, path)); err != nil || string(got) != want {
+			t.Errorf("Wrong staged %s: got %q (%v), want %q", path, got, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(staged, ".git")); !os.IsNotExist(err) {
+		t.Errorf("The .git directory was staged: %v", err)
+	}
+
+	// The note is on "é" in the staged file, and its fix replaces it with "è".
+	note := &notepb.Note{
+		Category: proto.String("PyLint"),
+		Location: &notepb.Location{
+			Path:  proto.String("src/latin.py"),
+			Range: &textpb.TextRange{StartLine: proto.Int32(1), StartOffset: proto.Int32(8), EndOffset: proto.Int32(10)},
+		},
+		Fix: []*notepb.Fix{
+			{Replacement: []*notepb.Replacement{{
+				Path:       proto.String("src/latin.py"),
+				Range:      &notepb.FixRange{Start: &notepb.FixRange_Position{Byte: proto.Uint32(8)}, End: &notepb.FixRange_Position{Byte: proto.Uint32(10)}},
+				NewContent: proto.String("\xc3\xa8"),
+			}}},
+			{Replacement: []*notepb.Replacement{{
+				Path:       proto.String("src/latin.py"),
+				NewContent: proto.String("\xe2\x82\xac"),
+			}}},
+		},
+	}
+	resp := &rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{{Note: []*notepb.Note{note}}}}
+	if got, want := r.Restore(resp), 1; got != want {
+		t.Errorf("Wrong number of fixes left out: got %d, want %d", got, want)
+	}
+	if got, want := []int32{note.Location.Range.GetStartOffset(), note.Location.Range.GetEndOffset()}, []int32{8, 9}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong restored offsets: got %v, want %v", got, want)
+	}
+	if len(note.Fix) != 1 {
+		t.Fatalf("Wrong fixes kept: got %v", note.Fix)
+	}
+	rep := note.Fix[0].Replacement[0]
+	if got, want := []uint32{rep.Range.Start.GetByte(), rep.Range.End.GetByte()}, []uint32{8, 9}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong restored fix range: got %v, want %v", got, want)
+	}
+	if got, want := rep.GetNewContent(), "\xe8"; got != want {
+		t.Errorf("Wrong restored new content: got %q, want %q", got, want)
+	}
+}
+
+func TestStageAgain(t *testing.T) {
+	root, err := ioutil.TempDir("", "shipshape-encoding")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	workspace := filepath.Join(root, "workspace")
+	writeFiles(t, workspace, map[string]string{
+		"plain.py":   "x = 1\n",
+		"same.py":    "y = 2\n",
+		"gone.py":    "z = 3\n",
+		"src/bom.js": "\xef\xbb\xbfvar x;\n",
+	})
+	staged := filepath.Join(root, "staged")
+	stage := func(files ...string) {
+		r, err := DetectEncodings(workspace, files)
+		if err != nil {
+			t.Fatalf("DetectEncodings failed: %v", err)
+		}
+		if err := r.Stage(workspace, staged); err != nil {
+			t.Fatalf("Stage failed: %v", err)
+		}
+	}
+	stage("plain.py", "same.py", "gone.py", "src/bom.js")
+	before, err := os.Stat(filepath.Join(staged, "same.py"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// plain.py was a hard link to the original, so transcoding it now must not
+	// change the original.
+	writeFiles(t, workspace, map[string]string{"plain.py": "s = 'caf\xe9'\n", "new.py": "w = 4\n"})
+	if err := os.Remove(filepath.Join(workspace, "gone.py")); err != nil {
+		t.Fatal(err)
+	}
+	stage("plain.py", "same.py", "new.py", "src/bom.js")
+
+	for path, want := range map[string]string{
+		"plain.py":   "s = 'caf\xc3\xa9'\n",
+		"same.py":    "y = 2\n",
+		"new.py":     "w = 4\n",
+		"src/bom.js": "var x;\n",
+	} {
+		if got, err := ioutil.ReadFile(filepath.Join(staged, path)); err != nil || string(got) != want {
+			t.Errorf("Wrong staged %s: got %q (%v), want %q", path, got, err, want)
+		}
+	}
+	if got, _ := ioutil.ReadFile(filepath.Join(workspace, "plain.py")); string(got) != "s = 'caf\xe9'\n" {
+		t.Errorf("Staging changed the original plain.py: got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(staged, "gone.py")); !os.IsNotExist(err) {
+		t.Errorf("A file removed from the workspace is still staged: %v", err)
+	}
+	if after, err := os.Stat(filepath.Join(staged, "same.py")); err != nil || !os.SameFile(before, after) {
+		t.Errorf("An unchanged file was staged again: %v", err)
+	}
+}
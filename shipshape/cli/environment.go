@@ -0,0 +1,78 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+	"github.com/google/shipshape/shipshape/util/docker"
+	"github.com/google/shipshape/shipshape/util/redact"
+)
+
+// analyzerEnvironments resolves the configured environments of third-party
+// analyzer containers into the variables to set for each image. Secrets are
+// read with getenv; it is an error for one to be unset. Their values are
+// registered with redact so that they are never logged.
+func analyzerEnvironments(envs []*configpb.AnalyzerEnvironment, getenv func(string) string) (map[string]map[string]string, error) {
+	resolved := make(map[string]map[string]string)
+	for _, e := range envs {
+		vars := resolved[e.GetImage()]
+		if vars == nil {
+			vars = make(map[string]string)
+			resolved[e.GetImage()] = vars
+		}
+		for _, v := range e.Variables {
+			if v.Secret == nil {
+				vars[v.GetName()] = v.GetValue()
+				continue
+			}
+			value := getenv(v.GetSecret())
+			if value == "" {
+				return nil, fmt.Errorf("%s is not set; it is needed for %s in the environment of %s", v.GetSecret(), v.GetName(), e.GetImage())
+			}
+			redact.Register(value)
+			vars[v.GetName()] = value
+		}
+	}
+	return resolved, nil
+}
+
+// analyzerSandboxes resolves the configured security profiles of third-party
+// analyzer containers into the sandbox of each of images. Seccomp profiles
+// are relative to root, the directory of the config. If strict is set, every
+// image is also held to docker.StrictSandbox.
+func analyzerSandboxes(sandboxes []*configpb.AnalyzerSandbox, images []string, root string, strict bool) map[string]docker.Sandbox {
+	resolved := make(map[string]docker.Sandbox)
+	for _, sb := range sandboxes {
+		s := docker.Sandbox{Offline: sb.GetOffline(), ReadOnly: sb.GetReadonly(), Drop: sb.GetDrop()}
+		if sb.GetSeccomp() != "" {
+			s.Seccomp = sb.GetSeccomp()
+			if !filepath.IsAbs(s.Seccomp) {
+				s.Seccomp = filepath.Join(root, s.Seccomp)
+			}
+		}
+		resolved[sb.GetImage()] = resolved[sb.GetImage()].Tighten(s)
+	}
+	if strict {
+		for _, image := range images {
+			resolved[image] = resolved[image].Tighten(docker.StrictSandbox())
+		}
+	}
+	return resolved
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+	"github.com/google/shipshape/shipshape/util/docker"
+)
+
+func TestAnalyzerEnvironments(t *testing.T) {
+	envs := []*configpb.AnalyzerEnvironment{
+		{
+			Image: proto.String("gcr.io/example/scanner:prod"),
+			Variables: []*configpb.EnvironmentVariable{
+				{Name: proto.String("MODE"), Value: proto.String("strict")},
+				{Name: proto.String("LICENSE"), Secret: proto.String("SCANNER_LICENSE_KEY")},
+			},
+		},
+	}
+	got, err := analyzerEnvironments(envs, fakeEnv(map[string]string{"SCANNER_LICENSE_KEY": "abc123"}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := map[string]map[string]string{
+		"gcr.io/example/scanner:prod": {"MODE": "strict", "LICENSE": "abc123"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong environments: got %v, want %v", got, want)
+	}
+
+	if _, err := analyzerEnvironments(envs, fakeEnv(nil)); err == nil {
+		t.Errorf("Expected an error for an unset secret")
+	}
+}
+
+func TestAnalyzerSandboxes(t *testing.T) {
+	sandboxes := []*configpb.AnalyzerSandbox{
+		{
+			Image:   proto.String("gcr.io/example/scanner:prod"),
+			Drop:    []string{"NET_RAW"},
+			Seccomp: proto.String("seccomp/scanner.json"),
+		},
+	}
+	images := []string{"gcr.io/example/scanner:prod", "gcr.io/example/linter:prod"}
+
+	got := analyzerSandboxes(sandboxes, images, "/src", false)
+	want := map[string]docker.Sandbox{
+		"gcr.io/example/scanner:prod": {Drop: []string{"NET_RAW"}, Seccomp: "/src/seccomp/scanner.json"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong sandboxes: got %v, want %v", got, want)
+	}
+
+	got = analyzerSandboxes(sandboxes, images, "/src", true)
+	want = map[string]docker.Sandbox{
+		"gcr.io/example/scanner:prod": {Offline: true, ReadOnly: true, Drop: []string{"ALL", "NET_RAW"}, Seccomp: "/src/seccomp/scanner.json"},
+		"gcr.io/example/linter:prod":  docker.StrictSandbox(),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong strict sandboxes: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/shipshape/shipshape/util/rpc/client"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// ErrNoService is returned by Explain when the documentation is not cached
+// and no shipshape service is running to ask for it.
+var ErrNoService = errors.New("no shipshape service is running; run shipshape on a directory (with --stay_up, the default) and try again")
+
+// DocCache stores the documentation of categories on disk, so that it can be
+// shown without a running service.
+type DocCache struct {
+	dir string
+}
+
+// OpenDocCache returns the cache in dir, which is created when the first
+// documentation is stored.
+func OpenDocCache(dir string) *DocCache {
+	return &DocCache{dir}
+}
+
+// Get returns the cached documentation of category and subcategory, if any.
+func (c *DocCache) Get(category, subcategory string) (*rpcpb.Documentation, bool) {
+	b, err := ioutil.ReadFile(c.path(category, subcategory))
+	if err != nil {
+		return nil, false
+	}
+	var doc rpcpb.Documentation
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, false
+	}
+	return &doc, true
+}
+
+// Put stores doc for category and subcategory.
+func (c *DocCache) Put(category, subcategory string, doc *rpcpb.Documentation) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.path(category, subcategory), b, 0644)
+}
+
+func (c *DocCache) path(category, subcategory string) string {
+	return filepath.Join(c.dir, url.QueryEscape(category+":"+subcategory)+".json")
+}
+
+// ParseCategory splits an argument of the form Category[:Subcategory].
+func ParseCategory(arg string) (category, subcategory string) {
+	parts := strings.SplitN(arg, ":", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return parts[0], ""
+}
+
+// Explain returns the documentation of category, or of its subcategory if
+// that is not empty. Unless refresh is set, cached documentation is used;
+// otherwise the running shipshape service is asked for it, and the result is
+// cached. The service is the one listening on a unix socket in socketDir, if
+// that is not empty.
+func Explain(cache *DocCache, category, subcategory string, refresh bool, socketDir string) (*rpcpb.Documentation, error) {
+	if !refresh {
+		if doc, ok := cache.Get(category, subcategory); ok {
+			return doc, nil
+		}
+	}
+	c := serviceClient(socketDir)
+	if err := c.WaitUntilReady(time.Second); err != nil {
+		return nil, ErrNoService
+	}
+	doc, err := client.ShipshapeClient{Client: c}.GetDocumentation(category, subcategory)
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.Put(category, subcategory, doc); err != nil {
+		return nil, fmt.Errorf("could not cache the documentation: %v", err)
+	}
+	return doc, nil
+}
+
+// FormatDocumentation renders doc for the terminal.
+func FormatDocumentation(doc *rpcpb.Documentation) string {
+	var b bytes.Buffer
+	name := doc.GetCategory()
+	if doc.Subcategory != nil {
+		name += ":" + doc.GetSubcategory()
+	}
+	fmt.Fprintf(&b, "%s\n\n", name)
+	if doc.Description != nil {
+		fmt.Fprintf(&b, "%s\n\n", doc.GetDescription())
+	}
+	if doc.Rationale != nil {
+		fmt.Fprintf(&b, "Why it matters:\n\t%s\n\n", doc.GetRationale())
+	}
+	for i, example := range doc.Example {
+		if i == 0 {
+			fmt.Fprintln(&b, "Examples:")
+		}
+		fmt.Fprintf(&b, "\t%s\n", strings.Replace(example, "\n", "\n\t", -1))
+	}
+	if len(doc.Example) > 0 {
+		fmt.Fprintln(&b)
+	}
+	if doc.Url != nil {
+		fmt.Fprintf(&b, "More: %s\n", doc.GetUrl())
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
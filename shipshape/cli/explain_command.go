@@ -0,0 +1,53 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/shipshape/shipshape/cli"
+)
+
+// explainCommand implements `shipshape explain`, which prints the analyzer's
+// own documentation of a category or subcategory.
+func explainCommand(args []string) int {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	cacheDir := fs.String("cache_dir", filepath.Join(os.Getenv("HOME"), ".shipshape", "docs"), "Where documentation is cached, so that it can be shown without a running service")
+	refresh := fs.Bool("refresh", false, "Ask the running service for the documentation even if it is cached")
+	socketDir := fs.String("service_socket_dir", "", "The --service_socket_dir that the running service was started with, if any")
+	fs.Usage = func() {
+		fmt.Println("USAGE: shipshape explain [--refresh] <Category>[:<Subcategory>]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return returnError
+	}
+
+	category, subcategory := cli.ParseCategory(fs.Arg(0))
+	doc, err := cli.Explain(cli.OpenDocCache(*cacheDir), category, subcategory, *refresh, *socketDir)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+	fmt.Print(cli.FormatDocumentation(doc))
+	return returnNoFindings
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func TestParseCategory(t *testing.T) {
+	tests := []struct {
+		arg, category, subcategory string
+	}{
+		{"go vet", "go vet", ""},
+		{"PyLint:unused-import", "PyLint", "unused-import"},
+		{"JSHint:", "JSHint", ""},
+	}
+	for _, test := range tests {
+		if cat, sub := ParseCategory(test.arg); cat != test.category || sub != test.subcategory {
+			t.Errorf("ParseCategory(%q): got (%q, %q), want (%q, %q)", test.arg, cat, sub, test.category, test.subcategory)
+		}
+	}
+}
+
+func TestDocCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "explain_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cache := OpenDocCache(dir)
+
+	if _, ok := cache.Get("go vet", ""); ok {
+		t.Errorf("Empty cache returned documentation")
+	}
+	doc := &rpcpb.Documentation{Category: proto.String("go vet"), Description: proto.String("Runs go vet")}
+	if err := cache.Put("go vet", "", doc); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	got, ok := cache.Get("go vet", "")
+	if !ok || !proto.Equal(got, doc) {
+		t.Errorf("Wrong cached documentation: got %v, want %v", got, doc)
+	}
+	if _, ok := cache.Get("go vet", "printf"); ok {
+		t.Errorf("Cache returned the category's documentation for a subcategory")
+	}
+
+	// Cached documentation is used without a service.
+	if got, err := Explain(cache, "go vet", "", false, ""); err != nil || !proto.Equal(got, doc) {
+		t.Errorf("Explain from the cache: got (%v, %v), want (%v, nil)", got, err, doc)
+	}
+}
+
+func TestFormatDocumentation(t *testing.T) {
+	doc := &rpcpb.Documentation{
+		Category:    proto.String("PyLint"),
+		Subcategory: proto.String("unused-import"),
+		Description: proto.String("Finds imports that are not used."),
+		Rationale:   proto.String("They slow down loading."),
+		Example:     []string{"import os\nprint('hi')"},
+		Url:         proto.String("https://pylint.org"),
+	}
+	want := `PyLint:unused-import
+
+Finds imports that are not used.
+
+Why it matters:
+	They slow down loading.
+
+Examples:
+	import os
+	print('hi')
+
+More: https://pylint.org
+`
+	if got := FormatDocumentation(doc); got != want {
+		t.Errorf("Wrong formatting: got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,439 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+)
+
+// IssueLabel is attached to every issue filed by shipshape, and is used to
+// find them again.
+const IssueLabel = "shipshape"
+
+const (
+	groupMarker       = "shipshape-group: "
+	fingerprintMarker = "shipshape-fingerprints: "
+)
+
+// Issue is an issue previously filed in a tracker.
+type Issue struct {
+	// ID identifies the issue to the tracker, e.g. a GitHub issue number
+	// or a Jira issue key.
+	ID    string
+	Title string
+	Body  string
+	// Closed is set if the issue was closed, e.g. as fixed or won't fix.
+	Closed bool
+}
+
+// IssueTracker files and updates issues.
+type IssueTracker interface {
+	// Issues returns the issues previously filed by shipshape, open and
+	// closed.
+	Issues() ([]*Issue, error)
+	Create(title, body string) error
+	Update(issue *Issue, title, body string) error
+}
+
+// SeverityAtLeast reports whether severity is at least as severe as min.
+// BUILD_ERROR is the most severe, then WARNING, then OTHER.
+func SeverityAtLeast(severity, min notepb.Note_Severity) bool {
+	rank := map[notepb.Note_Severity]int{
+		notepb.Note_BUILD_ERROR: 3,
+		notepb.Note_WARNING:     2,
+		notepb.Note_OTHER:       1,
+	}
+	return rank[severity] >= rank[min]
+}
+
+// ExportResult counts what ExportIssues did. Closed counts the notes that
+// were left out because they are in a closed issue.
+type ExportResult struct {
+	Created, Updated, Unchanged, Closed int
+}
+
+// ExportIssues files an issue for each group of notes at or above
+// minSeverity. Notes are grouped by category if groupBy is "category", or
+// by path if it is "file". Each issue records the group and the
+// fingerprints of its notes, so rerunning the export updates the existing
+// issue for a group instead of filing a duplicate, and skips notes that
+// were already filed in another issue. Notes in a closed issue are not filed
+// again, so that closing an issue, e.g. as won't fix, dismisses its notes;
+// new notes of its group are filed in a new issue. The issues say who
+// maintains the categories of their notes, as looked up in owners.
+func ExportIssues(tracker IssueTracker, notes []*notepb.Note, owners *Ownership, groupBy string, minSeverity notepb.Note_Severity) (ExportResult, error) {
+	var result ExportResult
+	if groupBy != "category" && groupBy != "file" {
+		return result, fmt.Errorf("unknown grouping %q: must be category or file", groupBy)
+	}
+	existing, err := tracker.Issues()
+	if err != nil {
+		return result, err
+	}
+	byGroup := make(map[string]*Issue)
+	filed := make(map[string]string)
+	closed := make(map[string]bool)
+	for _, issue := range existing {
+		group, prints := parseIssueMarkers(issue.Body)
+		if group == "" {
+			continue
+		}
+		if issue.Closed {
+			for _, fp := range prints {
+				closed[fp] = true
+			}
+			continue
+		}
+		byGroup[group] = issue
+		for _, fp := range prints {
+			filed[fp] = group
+		}
+	}
+
+	groups := make(map[string][]*notepb.Note)
+	for _, n := range notes {
+		if !SeverityAtLeast(n.GetSeverity(), minSeverity) {
+			continue
+		}
+		key := groupBy + ":" + n.GetCategory()
+		if groupBy == "file" {
+			key = groupBy + ":" + n.GetLocation().GetPath()
+		}
+		if group, ok := filed[Fingerprint(n)]; ok {
+			if group != key {
+				continue
+			}
+		} else if closed[Fingerprint(n)] {
+			result.Closed++
+			continue
+		}
+		groups[key] = append(groups[key], n)
+	}
+
+	for _, key := range sortedGroupKeys(groups) {
+		title, body := renderIssue(key, groups[key], owners)
+		issue, ok := byGroup[key]
+		switch {
+		case !ok:
+			err = tracker.Create(title, body)
+			result.Created++
+		case issue.Body != body:
+			err = tracker.Update(issue, title, body)
+			result.Updated++
+		default:
+			result.Unchanged++
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func sortedGroupKeys(groups map[string][]*notepb.Note) []string {
+	var keys []string
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// renderIssue returns the title and body of the issue for a group of notes.
+// The markers identifying the issue are at the end of the body, in an HTML
+// comment so that they are hidden by trackers that render markdown.
+func renderIssue(key string, notes []*notepb.Note, owners *Ownership) (string, string) {
+	kind := strings.SplitN(key, ":", 2)
+	var title string
+	if kind[0] == "file" {
+		path := kind[1]
+		if path == "" {
+			path = "global scope"
+		}
+		title = fmt.Sprintf("Shipshape: %d notes in %s", len(notes), path)
+	} else {
+		title = fmt.Sprintf("Shipshape: %d %s notes", len(notes), kind[1])
+	}
+
+	var body bytes.Buffer
+	var prints []string
+	for _, n := range notes {
+		loc := n.GetLocation().GetPath()
+		if lines := formatLines(n.GetLocation().GetRange()); lines != "" {
+			loc = loc + ":" + lines
+		}
+		if loc == "" {
+			loc = "Global"
+		}
+		fmt.Fprintf(&body, "* %s [%s] %s\n", loc, n.GetCategory(), n.GetDescription())
+		prints = append(prints, Fingerprint(n))
+	}
+	var maintained []string
+	for _, cat := range noteCategories(notes) {
+		if owner := owners.Owner(cat); owner != nil {
+			maintained = append(maintained, fmt.Sprintf("The %s analyzer is %s.\n", cat, MaintainedBy(owner)))
+		}
+	}
+	if len(maintained) > 0 {
+		fmt.Fprintf(&body, "\n%s", strings.Join(maintained, ""))
+	}
+	fmt.Fprintf(&body, "\n<!--\n%s%s\n%s%s\n-->\n", groupMarker, key, fingerprintMarker, strings.Join(prints, ","))
+	return title, body.String()
+}
+
+// noteCategories returns the categories of notes, sorted.
+func noteCategories(notes []*notepb.Note) []string {
+	seen := make(map[string]bool)
+	var cats []string
+	for _, n := range notes {
+		if !seen[n.GetCategory()] {
+			seen[n.GetCategory()] = true
+			cats = append(cats, n.GetCategory())
+		}
+	}
+	sort.Strings(cats)
+	return cats
+}
+
+// parseIssueMarkers returns the group and fingerprints recorded in the body
+// of an issue filed by shipshape.
+func parseIssueMarkers(body string) (string, []string) {
+	var group string
+	var prints []string
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, groupMarker):
+			group = strings.TrimPrefix(line, groupMarker)
+		case strings.HasPrefix(line, fingerprintMarker):
+			if p := strings.TrimPrefix(line, fingerprintMarker); p != "" {
+				prints = strings.Split(p, ",")
+			}
+		}
+	}
+	return group, prints
+}
+
+// GitHubTracker files issues in a GitHub repository.
+type GitHubTracker struct {
+	// API is the base URL of the GitHub API, e.g. https://api.github.com.
+	API string
+	// Repo is the repository, as owner/name.
+	Repo  string
+	Token string
+}
+
+type githubIssue struct {
+	Number int      `json:"number,omitempty"`
+	Title  string   `json:"title"`
+	Body   string   `json:"body"`
+	Labels []string `json:"labels,omitempty"`
+	State  string   `json:"state,omitempty"`
+}
+
+// Issues returns the issues labeled with IssueLabel, open and closed.
+func (g *GitHubTracker) Issues() ([]*Issue, error) {
+	var issues []*Issue
+	for page := 1; ; page++ {
+		var batch []githubIssue
+		path := fmt.Sprintf("/repos/%s/issues?state=all&labels=%s&per_page=100&page=%d", g.Repo, IssueLabel, page)
+		if err := g.call("GET", path, nil, &batch); err != nil {
+			return nil, err
+		}
+		for _, i := range batch {
+			issues = append(issues, &Issue{ID: fmt.Sprint(i.Number), Title: i.Title, Body: i.Body, Closed: i.State == "closed"})
+		}
+		if len(batch) < 100 {
+			return issues, nil
+		}
+	}
+}
+
+// Create files a new issue labeled with IssueLabel.
+func (g *GitHubTracker) Create(title, body string) error {
+	return g.call("POST", fmt.Sprintf("/repos/%s/issues", g.Repo), githubIssue{Title: title, Body: body, Labels: []string{IssueLabel}}, nil)
+}
+
+// Update replaces the title and body of an issue.
+func (g *GitHubTracker) Update(issue *Issue, title, body string) error {
+	return g.call("PATCH", fmt.Sprintf("/repos/%s/issues/%s", g.Repo, issue.ID), githubIssue{Title: title, Body: body}, nil)
+}
+
+func (g *GitHubTracker) call(method, path string, in, out interface{}) error {
+	return callJSON(method, strings.TrimSuffix(g.API, "/")+path, in, out, func(req *http.Request) {
+		if g.Token != "" {
+			req.Header.Set("Authorization", "token "+g.Token)
+		}
+	})
+}
+
+// JiraTracker files issues in a Jira project.
+type JiraTracker struct {
+	// URL is the base URL of the Jira instance.
+	URL     string
+	Project string
+	// IssueType is the type of issue to file, e.g. Bug.
+	IssueType string
+	User      string
+	Token     string
+}
+
+type jiraFields struct {
+	Project     *jiraKey    `json:"project,omitempty"`
+	IssueType   *jiraName   `json:"issuetype,omitempty"`
+	Summary     string      `json:"summary"`
+	Description string      `json:"description"`
+	Labels      []string    `json:"labels,omitempty"`
+	Status      *jiraStatus `json:"status,omitempty"`
+}
+
+type jiraStatus struct {
+	Category jiraKey `json:"statusCategory"`
+}
+
+type jiraKey struct {
+	Key string `json:"key"`
+}
+
+type jiraName struct {
+	Name string `json:"name"`
+}
+
+type jiraIssue struct {
+	Key    string     `json:"key,omitempty"`
+	Fields jiraFields `json:"fields"`
+}
+
+// Issues returns the issues in the project labeled with IssueLabel, resolved
+// and unresolved. Resolved issues, whose status is in the Done category, are
+// Closed.
+func (j *JiraTracker) Issues() ([]*Issue, error) {
+	jql := fmt.Sprintf("project = %q AND labels = %s", j.Project, IssueLabel)
+	var issues []*Issue
+	for start := 0; ; {
+		var result struct {
+			Total  int         `json:"total"`
+			Issues []jiraIssue `json:"issues"`
+		}
+		path := fmt.Sprintf("/rest/api/2/search?jql=%s&fields=summary,description,status&startAt=%d", url.QueryEscape(jql), start)
+		if err := j.call("GET", path, nil, &result); err != nil {
+			return nil, err
+		}
+		for _, i := range result.Issues {
+			done := i.Fields.Status != nil && i.Fields.Status.Category.Key == "done"
+			issues = append(issues, &Issue{ID: i.Key, Title: i.Fields.Summary, Body: i.Fields.Description, Closed: done})
+		}
+		start += len(result.Issues)
+		if len(result.Issues) == 0 || start >= result.Total {
+			return issues, nil
+		}
+	}
+}
+
+// Create files a new issue labeled with IssueLabel.
+func (j *JiraTracker) Create(title, body string) error {
+	issue := jiraIssue{Fields: jiraFields{
+		Project:     &jiraKey{j.Project},
+		IssueType:   &jiraName{j.IssueType},
+		Summary:     title,
+		Description: body,
+		Labels:      []string{IssueLabel},
+	}}
+	return j.call("POST", "/rest/api/2/issue", issue, nil)
+}
+
+// Update replaces the summary and description of an issue.
+func (j *JiraTracker) Update(issue *Issue, title, body string) error {
+	return j.call("PUT", "/rest/api/2/issue/"+issue.ID, jiraIssue{Fields: jiraFields{Summary: title, Description: body}}, nil)
+}
+
+func (j *JiraTracker) call(method, path string, in, out interface{}) error {
+	return callJSON(method, strings.TrimSuffix(j.URL, "/")+path, in, out, func(req *http.Request) {
+		if j.User != "" {
+			req.SetBasicAuth(j.User, j.Token)
+		}
+	})
+}
+
+// apiTimeout is how long the API of a tracker, or of another service the CLI
+// sends results to, has to answer a request.
+var apiTimeout = time.Minute
+
+// callJSON sends in as the JSON body of a request, and decodes the JSON
+// response into out if it is not nil. The target may carry credentials, e.g.
+// in its query, so errors only name its host.
+func callJSON(method, target string, in, out interface{}, auth func(*http.Request)) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return fmt.Errorf("invalid URL for %s: %v", URLHost(target), unwrapURLError(err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	auth(req)
+	client := http.Client{Timeout: apiTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s to %s failed: %v", method, req.URL.Host, unwrapURLError(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s to %s returned %s: %s", method, req.URL.Host, resp.Status, msg)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// URLHost returns the host of rawurl, which unlike the whole URL is safe to
+// print.
+func URLHost(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Host == "" {
+		return "an unknown host"
+	}
+	return u.Host
+}
+
+// unwrapURLError returns the cause of err if it is a *url.Error, whose message
+// would repeat the whole URL.
+func unwrapURLError(err error) error {
+	if ue, ok := err.(*url.Error); ok {
+		return ue.Err
+	}
+	return err
+}
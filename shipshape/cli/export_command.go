@@ -0,0 +1,230 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/google/shipshape/shipshape/cli"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+)
+
+// exportCommand implements `shipshape export`, which sends the notes in a
+// results file written by --json_output elsewhere.
+func exportCommand(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "issues":
+			return exportIssuesCommand(args[1:])
+		case "bigquery":
+			return exportBigQueryCommand(args[1:])
+		}
+	}
+	fmt.Println("USAGE: shipshape export issues [flags] <results.json>")
+	fmt.Println("       shipshape export bigquery [flags] <results.json>")
+	return returnError
+}
+
+// exportIssuesCommand implements `shipshape export issues`, which files the
+// notes as issues in a tracker.
+func exportIssuesCommand(args []string) int {
+	fs := flag.NewFlagSet("export issues", flag.ExitOnError)
+	trackers := addTrackerFlags(fs, "github")
+	groupBy := fs.String("group_by", "category", "File one issue per category or per file")
+	minSeverity := fs.String("min_severity", "WARNING", "Only export notes at least this severe: BUILD_ERROR, WARNING, or OTHER")
+	fs.Usage = func() {
+		fmt.Println("USAGE: shipshape export issues [flags] <results.json>")
+		fmt.Println("The GitHub token is read from $GITHUB_TOKEN.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return returnError
+	}
+
+	severity, ok := notepb.Note_Severity_value[*minSeverity]
+	if !ok {
+		fmt.Printf("Error: unknown severity %q\n", *minSeverity)
+		return returnError
+	}
+	t, err := trackers.issueTracker()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+	if t == nil {
+		fmt.Println("Error: --tracker is required")
+		return returnError
+	}
+	resp, err := cli.LoadResults(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+	result, err := cli.ExportIssues(t, cli.AllNotes(resp), cli.NewOwnership(resp.Owner), *groupBy, notepb.Note_Severity(severity))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+	fmt.Printf("%d issues created, %d updated, %d unchanged\n", result.Created, result.Updated, result.Unchanged)
+	if result.Closed > 0 {
+		fmt.Printf("%d notes were left out because their issues were closed\n", result.Closed)
+	}
+	return returnNoFindings
+}
+
+// trackerFlags choose an issue tracker and say how to reach it.
+type trackerFlags struct {
+	tracker       *string
+	githubRepo    *string
+	githubAPI     *string
+	jiraURL       *string
+	jiraProject   *string
+	jiraIssueType *string
+	jiraUser      *string
+}
+
+// addTrackerFlags defines the flags of an issue tracker in fs, with
+// defaultTracker as the default tracker.
+func addTrackerFlags(fs *flag.FlagSet, defaultTracker string) *trackerFlags {
+	return &trackerFlags{
+		tracker:       fs.String("tracker", defaultTracker, "The issue tracker to file issues in: github or jira"),
+		githubRepo:    fs.String("github_repo", "", "The GitHub repository to file issues in, as owner/name"),
+		githubAPI:     fs.String("github_api", "https://api.github.com", "The base URL of the GitHub API"),
+		jiraURL:       fs.String("jira_url", "", "The base URL of the Jira instance"),
+		jiraProject:   fs.String("jira_project", "", "The key of the Jira project to file issues in"),
+		jiraIssueType: fs.String("jira_issue_type", "Bug", "The type of Jira issue to file"),
+		jiraUser:      fs.String("jira_user", "", "The Jira user to file issues as. The API token is read from $JIRA_TOKEN."),
+	}
+}
+
+// issueTracker returns the tracker chosen by f, or nil if none was.
+func (f *trackerFlags) issueTracker() (cli.IssueTracker, error) {
+	switch *f.tracker {
+	case "":
+		return nil, nil
+	case "github":
+		if *f.githubRepo == "" {
+			return nil, fmt.Errorf("--github_repo is required")
+		}
+		return &cli.GitHubTracker{API: *f.githubAPI, Repo: *f.githubRepo, Token: os.Getenv("GITHUB_TOKEN")}, nil
+	case "jira":
+		if *f.jiraURL == "" || *f.jiraProject == "" {
+			return nil, fmt.Errorf("--jira_url and --jira_project are required")
+		}
+		return &cli.JiraTracker{URL: *f.jiraURL, Project: *f.jiraProject, IssueType: *f.jiraIssueType, User: *f.jiraUser, Token: os.Getenv("JIRA_TOKEN")}, nil
+	}
+	return nil, fmt.Errorf("unknown tracker %q", *f.tracker)
+}
+
+// exportBigQueryCommand implements `shipshape export bigquery`, which writes
+// the notes as rows for a data warehouse, and optionally streams them into a
+// BigQuery table.
+func exportBigQueryCommand(args []string) int {
+	fs := flag.NewFlagSet("export bigquery", flag.ExitOnError)
+	output := fs.String("output", "", "The file to write newline-delimited JSON rows to. If empty, they are written to stdout unless --table is given.")
+	table := fs.String("table", "", "When specified, stream the rows into this BigQuery table, as project.dataset.table. The access token is read from $BIGQUERY_TOKEN.")
+	bigqueryAPI := fs.String("bigquery_api", "https://bigquery.googleapis.com/bigquery/v2", "The base URL of the BigQuery API")
+	dir := fs.String("dir", ".", "The analyzed directory, used to find the commit, branch and repository of the run")
+	schema := fs.Bool("schema", false, "Print the BigQuery schema of the rows and exit")
+	fs.Usage = func() {
+		fmt.Println("USAGE: shipshape export bigquery [flags] <results.json>")
+		fmt.Println("       shipshape export bigquery --schema")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *schema {
+		b, err := json.MarshalIndent(cli.WarehouseSchema, "", "  ")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return returnError
+		}
+		fmt.Println(string(b))
+		return returnNoFindings
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return returnError
+	}
+
+	var bq *cli.BigQueryTable
+	if *table != "" {
+		var err error
+		if bq, err = cli.ParseBigQueryTable(*table); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return returnError
+		}
+		bq.API = *bigqueryAPI
+		bq.Token = os.Getenv("BIGQUERY_TOKEN")
+		if bq.Token == "" {
+			fmt.Println("Error: $BIGQUERY_TOKEN is required with --table")
+			return returnError
+		}
+	}
+
+	resp, err := cli.LoadResults(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+	run := cli.WarehouseRun{RunMetadata: cli.GetRunMetadata(*dir)}
+	// The results file was written at the end of the run.
+	if fi, err := os.Stat(fs.Arg(0)); err == nil {
+		run.Timestamp = fi.ModTime().UTC()
+	}
+	if rev := cli.GetRevisionDetails(*dir); rev != nil {
+		run.Repository = rev.GetRemoteUrl()
+	}
+	if ci := cli.GetCiDetails(); ci != nil {
+		run.CiSystem = ci.GetSystem()
+		run.CiBuildURL = ci.GetBuildUrl()
+	}
+	rows := cli.WarehouseRows(run, resp)
+
+	if *output != "" || bq == nil {
+		out := os.Stdout
+		if *output != "" {
+			if out, err = os.Create(*output); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return returnError
+			}
+		}
+		err := cli.WriteWarehouseRows(out, rows)
+		if out != os.Stdout {
+			if cerr := out.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return returnError
+		}
+	}
+	if bq != nil {
+		if err := bq.Insert(rows); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return returnError
+		}
+		fmt.Printf("%d rows inserted into %s\n", len(rows), *table)
+	}
+	return returnNoFindings
+}
@@ -0,0 +1,244 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+)
+
+// fakeTracker keeps issues in memory.
+type fakeTracker struct {
+	issues  []*Issue
+	updates int
+}
+
+func (f *fakeTracker) Issues() ([]*Issue, error) { return f.issues, nil }
+
+func (f *fakeTracker) Create(title, body string) error {
+	f.issues = append(f.issues, &Issue{ID: fmt.Sprint(len(f.issues) + 1), Title: title, Body: body})
+	return nil
+}
+
+func (f *fakeTracker) Update(issue *Issue, title, body string) error {
+	issue.Title, issue.Body = title, body
+	f.updates++
+	return nil
+}
+
+func TestExportIssues(t *testing.T) {
+	tracker := &fakeTracker{}
+	info := createNote("PyLint", "a.py", 1, "Consider a docstring")
+	info.Severity = notepb.Note_OTHER.Enum()
+	notes := []*notepb.Note{
+		createNote("PyLint", "a.py", 3, "Unused import os"),
+		createNote("PyLint", "b.py", 7, "Undefined name x"),
+		createNote("JSHint", "c.js", 2, "Missing semicolon"),
+		info,
+	}
+	owners := NewOwnership([]*configpb.CategoryOwner{{Category: proto.String("PyLint"), Team: proto.String("python-infra")}})
+
+	got, err := ExportIssues(tracker, notes, owners, "category", notepb.Note_WARNING)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if want := (ExportResult{Created: 2}); got != want {
+		t.Errorf("Wrong result of first export: got %+v, want %+v", got, want)
+	}
+	if len(tracker.issues) != 2 || tracker.issues[1].Title != "Shipshape: 2 PyLint notes" {
+		t.Fatalf("Wrong issues filed: %v", tracker.issues)
+	}
+	if !strings.Contains(tracker.issues[1].Body, "The PyLint analyzer is maintained by python-infra.") {
+		t.Errorf("Owner not named in the issue: %s", tracker.issues[1].Body)
+	}
+	if strings.Contains(tracker.issues[1].Body, "docstring") {
+		t.Errorf("Note below the severity threshold was exported: %s", tracker.issues[1].Body)
+	}
+
+	got, err = ExportIssues(tracker, notes, owners, "category", notepb.Note_WARNING)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if want := (ExportResult{Unchanged: 2}); got != want {
+		t.Errorf("Wrong result of repeated export: got %+v, want %+v", got, want)
+	}
+
+	notes = append(notes, createNote("JSHint", "c.js", 9, "Unused variable y"))
+	got, err = ExportIssues(tracker, notes, owners, "category", notepb.Note_WARNING)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if want := (ExportResult{Updated: 1, Unchanged: 1}); got != want {
+		t.Errorf("Wrong result of export with a new note: got %+v, want %+v", got, want)
+	}
+	if !strings.Contains(tracker.issues[0].Body, "Unused variable y") {
+		t.Errorf("New note not added to existing issue: %s", tracker.issues[0].Body)
+	}
+
+	// Already filed notes are not filed again under a different grouping.
+	got, err = ExportIssues(tracker, notes, owners, "file", notepb.Note_WARNING)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if want := (ExportResult{}); got != want {
+		t.Errorf("Wrong result of export by file: got %+v, want %+v", got, want)
+	}
+}
+
+func TestExportIssuesClosed(t *testing.T) {
+	tracker := &fakeTracker{}
+	notes := []*notepb.Note{
+		createNote("PyLint", "a.py", 3, "Unused import os"),
+		createNote("JSHint", "c.js", 2, "Missing semicolon"),
+	}
+	if _, err := ExportIssues(tracker, notes, nil, "category", notepb.Note_WARNING); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	// Closing the JSHint issue, e.g. as won't fix, dismisses its notes.
+	tracker.issues[0].Closed = true
+
+	got, err := ExportIssues(tracker, notes, nil, "category", notepb.Note_WARNING)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if want := (ExportResult{Unchanged: 1, Closed: 1}); got != want {
+		t.Errorf("Wrong result of export after closing an issue: got %+v, want %+v", got, want)
+	}
+	if got, err = ExportIssues(tracker, notes, nil, "file", notepb.Note_WARNING); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if want := (ExportResult{Closed: 1}); got != want {
+		t.Errorf("Wrong result of export by file after closing an issue: got %+v, want %+v", got, want)
+	}
+
+	// New notes in the group of the closed issue get an issue of their own.
+	notes = append(notes, createNote("JSHint", "c.js", 9, "Unused variable y"))
+	if got, err = ExportIssues(tracker, notes, nil, "category", notepb.Note_WARNING); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if want := (ExportResult{Created: 1, Unchanged: 1, Closed: 1}); got != want {
+		t.Errorf("Wrong result of export with a new note: got %+v, want %+v", got, want)
+	}
+	if len(tracker.issues) != 3 || strings.Contains(tracker.issues[2].Body, "Missing semicolon") || !strings.Contains(tracker.issues[2].Body, "Unused variable y") {
+		t.Errorf("Wrong issue filed for the new note: %v", tracker.issues)
+	}
+}
+
+func TestGitHubTracker(t *testing.T) {
+	var created githubIssue
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Authorization"), "token secret"; got != want {
+			t.Errorf("Wrong authorization: got %q, want %q", got, want)
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/repos/o/r/issues":
+			if got := r.URL.Query().Get("labels"); got != IssueLabel {
+				t.Errorf("Issues not filtered by label: got %q", got)
+			}
+			if got := r.URL.Query().Get("state"); got != "all" {
+				t.Errorf("Closed issues not listed: got state %q", got)
+			}
+			json.NewEncoder(w).Encode([]githubIssue{{Number: 4, Title: "Old", Body: "body", State: "open"}, {Number: 5, Title: "Done", Body: "body", State: "closed"}})
+		case r.Method == "POST" && r.URL.Path == "/repos/o/r/issues":
+			json.NewDecoder(r.Body).Decode(&created)
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	g := &GitHubTracker{API: server.URL, Repo: "o/r", Token: "secret"}
+	issues, err := g.Issues()
+	if err != nil {
+		t.Fatalf("Could not list issues: %v", err)
+	}
+	if len(issues) != 2 || issues[0].ID != "4" || issues[0].Closed || !issues[1].Closed {
+		t.Errorf("Wrong issues: got %v", issues)
+	}
+	if err := g.Create("New", "body"); err != nil {
+		t.Fatalf("Could not create issue: %v", err)
+	}
+	if created.Title != "New" || len(created.Labels) != 1 || created.Labels[0] != IssueLabel {
+		t.Errorf("Wrong issue created: got %+v", created)
+	}
+	if err := g.Update(issues[0], "Old", "new body"); err == nil {
+		t.Errorf("Expected an error from a failing request")
+	}
+}
+
+func TestJiraTrackerIssues(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/2/search" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if jql := r.URL.Query().Get("jql"); strings.Contains(jql, "statusCategory") {
+			t.Errorf("Resolved issues not listed: got jql %q", jql)
+		}
+		fmt.Fprint(w, `{"total": 2, "issues": [
+			{"key": "PROJ-1", "fields": {"summary": "Open", "status": {"statusCategory": {"key": "indeterminate"}}}},
+			{"key": "PROJ-2", "fields": {"summary": "Won't fix", "status": {"statusCategory": {"key": "done"}}}}
+		]}`)
+	}))
+	defer server.Close()
+
+	j := &JiraTracker{URL: server.URL, Project: "PROJ"}
+	issues, err := j.Issues()
+	if err != nil {
+		t.Fatalf("Could not list issues: %v", err)
+	}
+	if len(issues) != 2 || issues[0].ID != "PROJ-1" || issues[0].Closed || !issues[1].Closed {
+		t.Errorf("Wrong issues: got %v", issues)
+	}
+}
+
+func TestCallJSONErrors(t *testing.T) {
+	defer func(d time.Duration) { apiTimeout = d }(apiTimeout)
+	apiTimeout = 100 * time.Millisecond
+	release := make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+		http.Error(w, "bad token", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	defer close(release)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	for _, target := range []string{server.URL + "/issues?token=hunter2", server.URL + "/slow?token=hunter2", down.URL + "/issues?token=hunter2"} {
+		err := callJSON("GET", target, nil, nil, func(*http.Request) {})
+		if err == nil || strings.Contains(err.Error(), "hunter2") {
+			t.Errorf("Wrong error for %s: got %v", target, err)
+		}
+	}
+	if err := callJSON("GET", server.URL+"/issues?token=hunter2", nil, nil, func(*http.Request) {}); err == nil || !strings.Contains(err.Error(), host) {
+		t.Errorf("The error does not name the host %s: %v", host, err)
+	}
+}
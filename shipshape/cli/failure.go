@@ -0,0 +1,51 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"github.com/google/shipshape/shipshape/service"
+
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// RequiredCategories returns the set of categories whose analyzers are
+// configured as required. Analyzers are best-effort by default.
+func RequiredCategories(analyzers []*configpb.AnalyzerConfig) map[string]bool {
+	required := make(map[string]bool)
+	for _, a := range analyzers {
+		if a.GetFailure() == service.FailureRequired {
+			required[a.GetCategory()] = true
+		}
+	}
+	return required
+}
+
+// Failures returns the analysis failures in resp, split into those from
+// required categories and the rest.
+func Failures(resp *rpcpb.ShipshapeResponse, required map[string]bool) (requiredFailures, otherFailures []*rpcpb.AnalysisFailure) {
+	for _, analysis := range resp.AnalyzeResponse {
+		for _, f := range analysis.Failure {
+			if required[f.GetCategory()] {
+				requiredFailures = append(requiredFailures, f)
+			} else {
+				otherFailures = append(otherFailures, f)
+			}
+		}
+	}
+	return requiredFailures, otherFailures
+}
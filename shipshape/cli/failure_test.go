@@ -0,0 +1,50 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func TestFailures(t *testing.T) {
+	required := RequiredCategories([]*configpb.AnalyzerConfig{
+		{Category: proto.String("ErrorProne"), Failure: proto.String("required")},
+		{Category: proto.String("PyLint")},
+	})
+	resp := &rpcpb.ShipshapeResponse{
+		AnalyzeResponse: []*rpcpb.AnalyzeResponse{
+			{Failure: []*rpcpb.AnalysisFailure{{Category: proto.String("ErrorProne"), FailureMessage: proto.String("javac crashed")}}},
+			{Failure: []*rpcpb.AnalysisFailure{
+				{Category: proto.String("PyLint"), FailureMessage: proto.String("timeout")},
+				{Category: proto.String("JSHint"), FailureMessage: proto.String("no node")},
+			}},
+		},
+	}
+
+	req, other := Failures(resp, required)
+	if len(req) != 1 || req[0].GetCategory() != "ErrorProne" {
+		t.Errorf("Wrong required failures: got %v", req)
+	}
+	if len(other) != 2 {
+		t.Errorf("Wrong best-effort failures: got %v", other)
+	}
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// FalsePositiveLog is the file in the shipshape directory of the user, the
+// same one TelemetryDir returns, that reports of false positives are appended
+// to.
+const FalsePositiveLog = "false_positives.log"
+
+// FalsePositiveReport is a user's report that a note is a false positive, for
+// the owners of its analyzer. The note is described if it was found in the
+// results it was reported from.
+type FalsePositiveReport struct {
+	Fingerprint string `json:"fingerprint"`
+	Reason      string `json:"reason"`
+	Category    string `json:"category,omitempty"`
+	Subcategory string `json:"subcategory,omitempty"`
+	Path        string `json:"path,omitempty"`
+	Line        int    `json:"line,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Owner says who maintains the category, as formatted by MaintainedBy.
+	Owner string `json:"owner,omitempty"`
+	// RunID is the ID of the run that found the note.
+	RunID string `json:"run_id,omitempty"`
+	// Reporter is the git user.email of the user who reported the note.
+	Reporter string    `json:"reporter,omitempty"`
+	Version  string    `json:"version"`
+	Time     time.Time `json:"time"`
+}
+
+// NewFalsePositiveReport reports the note with fingerprint as a false positive
+// for reason. If resp is not nil, the note must be in it, and is described in
+// the report. The reporter is the git user of dir.
+func NewFalsePositiveReport(fingerprint, reason string, resp *rpcpb.ShipshapeResponse, dir string) (*FalsePositiveReport, error) {
+	if strings.TrimSpace(reason) == "" {
+		return nil, fmt.Errorf("a reason is required, so that the owners of the analyzer know what is wrong")
+	}
+	r := &FalsePositiveReport{
+		Fingerprint: fingerprint,
+		Reason:      reason,
+		Reporter:    git(dir, "config", "user.email"),
+		Version:     Version,
+		Time:        time.Now().UTC(),
+	}
+	if resp == nil {
+		return r, nil
+	}
+	for _, n := range AllNotes(resp) {
+		if Fingerprint(n) != fingerprint {
+			continue
+		}
+		r.Category = n.GetCategory()
+		r.Subcategory = n.GetSubcategory()
+		r.Path = n.GetLocation().GetPath()
+		r.Line = int(n.GetLocation().GetRange().GetStartLine())
+		r.Description = n.GetDescription()
+		r.Owner = MaintainedBy(NewOwnership(resp.Owner).Owner(r.Category))
+		r.RunID = resp.GetRunInfo().GetRunId()
+		return r, nil
+	}
+	return nil, fmt.Errorf("no note with fingerprint %s in the results", fingerprint)
+}
+
+// RecordFalsePositive appends r to the log of reports in dir.
+func RecordFalsePositive(dir string, r *FalsePositiveReport) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, FalsePositiveLog), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// PostFalsePositive posts r as JSON to endpoint.
+func PostFalsePositive(endpoint string, r *FalsePositiveReport) error {
+	return callJSON("POST", endpoint, r, nil, func(*http.Request) {})
+}
+
+// FileFalsePositive files r as an issue in tracker. The issue has none of the
+// markers of the issues filed by ExportIssues, so exports leave it alone.
+func FileFalsePositive(tracker IssueTracker, r *FalsePositiveReport) error {
+	title, body := renderFalsePositive(r)
+	return tracker.Create(title, body)
+}
+
+// renderFalsePositive returns the title and body of the issue for r.
+func renderFalsePositive(r *FalsePositiveReport) (string, string) {
+	title := "Shipshape: false positive"
+	if r.Category != "" {
+		title = fmt.Sprintf("Shipshape: false positive in %s", r.Category)
+	}
+	var body bytes.Buffer
+	if r.Description != "" {
+		cat := r.Category
+		if r.Subcategory != "" {
+			cat += ":" + r.Subcategory
+		}
+		loc := r.Path
+		if loc == "" {
+			loc = "Global"
+		} else if r.Line > 0 {
+			loc = fmt.Sprintf("%s:%d", loc, r.Line)
+		}
+		fmt.Fprintf(&body, "* %s [%s] %s\n\n", loc, cat, r.Description)
+	}
+	fmt.Fprintf(&body, "Reason: %s\n\n", r.Reason)
+	if r.Owner != "" {
+		fmt.Fprintf(&body, "The %s analyzer is %s.\n", r.Category, r.Owner)
+	}
+	fmt.Fprintf(&body, "Fingerprint: %s\n", r.Fingerprint)
+	if r.RunID != "" {
+		fmt.Fprintf(&body, "Run: %s\n", r.RunID)
+	}
+	if r.Reporter != "" {
+		fmt.Fprintf(&body, "Reported by %s\n", r.Reporter)
+	}
+	return title, body.String()
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func TestNewFalsePositiveReport(t *testing.T) {
+	note := createNote("JavaLint", "src/A.java", 12, "Possible null dereference")
+	resp := &rpcpb.ShipshapeResponse{
+		RunInfo:         &rpcpb.RunInfo{RunId: proto.String("run-1")},
+		AnalyzeResponse: []*rpcpb.AnalyzeResponse{{Note: []*notepb.Note{createNote("PyLint", "a.py", 1, "Unused import"), note}}},
+		Owner:           []*configpb.CategoryOwner{{Category: proto.String("JavaLint"), Team: proto.String("java-infra")}},
+	}
+	r, err := NewFalsePositiveReport(Fingerprint(note), "x is checked above", resp, ".")
+	if err != nil {
+		t.Fatalf("NewFalsePositiveReport failed: %v", err)
+	}
+	if r.Category != "JavaLint" || r.Path != "src/A.java" || r.Line != 12 || r.Description != "Possible null dereference" {
+		t.Errorf("Note not described in the report: %+v", r)
+	}
+	if r.Owner != "maintained by java-infra" || r.RunID != "run-1" || r.Reason != "x is checked above" {
+		t.Errorf("Wrong owner, run or reason in the report: %+v", r)
+	}
+
+	if _, err := NewFalsePositiveReport("0123", "no such note", resp, "."); err == nil {
+		t.Errorf("Expected an error for a note that is not in the results")
+	}
+	if _, err := NewFalsePositiveReport(Fingerprint(note), " ", resp, "."); err == nil {
+		t.Errorf("Expected an error for a report without a reason")
+	}
+	if r, err := NewFalsePositiveReport("0123", "without results", nil, "."); err != nil || r.Category != "" {
+		t.Errorf("Report without results: got %+v, %v", r, err)
+	}
+}
+
+func TestRecordFalsePositive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feedback_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dir = filepath.Join(dir, ".shipshape")
+	for _, fp := range []string{"aaaa", "bbbb"} {
+		if err := RecordFalsePositive(dir, &FalsePositiveReport{Fingerprint: fp, Reason: "wrong"}); err != nil {
+			t.Fatalf("RecordFalsePositive failed: %v", err)
+		}
+	}
+	f, err := os.Open(filepath.Join(dir, FalsePositiveLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var got []string
+	for s := bufio.NewScanner(f); s.Scan(); {
+		var r FalsePositiveReport
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			t.Fatalf("Could not parse a recorded report: %v", err)
+		}
+		got = append(got, r.Fingerprint)
+	}
+	if strings.Join(got, ",") != "aaaa,bbbb" {
+		t.Errorf("Wrong reports recorded: got %v, want aaaa and bbbb", got)
+	}
+}
+
+func TestPostFalsePositive(t *testing.T) {
+	var got FalsePositiveReport
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("Wrong method: got %s, want POST", r.Method)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Could not decode report: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	if err := PostFalsePositive(server.URL, &FalsePositiveReport{Fingerprint: "aaaa", Reason: "wrong"}); err != nil {
+		t.Fatalf("PostFalsePositive failed: %v", err)
+	}
+	if got.Fingerprint != "aaaa" || got.Reason != "wrong" {
+		t.Errorf("Wrong report posted: got %+v", got)
+	}
+}
+
+func TestFileFalsePositive(t *testing.T) {
+	tracker := &fakeTracker{}
+	r := &FalsePositiveReport{
+		Fingerprint: "aaaa",
+		Reason:      "x is checked above",
+		Category:    "JavaLint",
+		Path:        "src/A.java",
+		Line:        12,
+		Description: "Possible null dereference",
+		Owner:       "maintained by java-infra",
+	}
+	if err := FileFalsePositive(tracker, r); err != nil {
+		t.Fatalf("FileFalsePositive failed: %v", err)
+	}
+	if len(tracker.issues) != 1 || tracker.issues[0].Title != "Shipshape: false positive in JavaLint" {
+		t.Fatalf("Wrong issues filed: %v", tracker.issues)
+	}
+	body := tracker.issues[0].Body
+	for _, want := range []string{"* src/A.java:12 [JavaLint] Possible null dereference", "Reason: x is checked above", "The JavaLint analyzer is maintained by java-infra.", "Fingerprint: aaaa"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("%q not in the issue:\n%s", want, body)
+		}
+	}
+	if group, _ := parseIssueMarkers(body); group != "" {
+		t.Errorf("Issue has the markers of an export: group %q", group)
+	}
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+)
+
+// Fingerprint returns a stable identifier for a note, used to match the same
+// finding across runs. It covers the category, subcategory, path, and
+// description, but not the line or column, so that a note keeps its
+// fingerprint when unrelated edits move it around the file.
+func Fingerprint(note *notepb.Note) string {
+	h := sha1.New()
+	for _, field := range []string{
+		note.GetCategory(),
+		note.GetSubcategory(),
+		note.GetLocation().GetPath(),
+		note.GetDescription(),
+	} {
+		// Separate the fields so that ("ab", "c") and ("a", "bc") differ.
+		io.WriteString(h, field)
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
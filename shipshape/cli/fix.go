@@ -0,0 +1,619 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+)
+
+// FixResult counts the decisions made by a Fixer. Each note with fixes counts
+// once, whichever of its fixes was applied.
+type FixResult struct {
+	Applied  int
+	Rejected int
+	// Failed notes have no fix that could be applied, because the fixes
+	// overlap ones that were applied before them or the files they change no
+	// longer match the results.
+	Failed int
+	// Skipped notes were not decided on because fixing was quit.
+	Skipped int
+	// Staged are the paths of the files that applied fixes were staged in.
+	Staged []string
+}
+
+// A Fixer applies the fixes that analyzers suggested in notes to the files in
+// Directory, which the notes were found in. The replacements of a fix refer to
+// the files as they were analyzed, so a Fixer keeps track of the changes it
+// made to each file and moves the fixes that come after them accordingly.
+type Fixer struct {
+	Directory string
+	// Stage adds each applied fix to the git index with `git apply --cached`,
+	// leaving any other changes to the same files unstaged, like `git add -p`.
+	// It is ignored if Directory is not in a git work tree.
+	Stage bool
+	// Color colors the diffs shown by Interactive like git does, in Theme,
+	// or in the default theme if Theme is nil.
+	Color bool
+	Theme *Theme
+	// Edit opens the file at path in an editor, at line if it is positive,
+	// and returns once the editor is closed.
+	Edit func(path string, line int) error
+
+	files   map[string]*fixedFile
+	staging bool
+	staged  map[string]bool
+}
+
+// fixedFile is a file that a Fixer has read, with the changes made to it since.
+type fixedFile struct {
+	original []byte
+	current  []byte
+	exists   bool
+	edits    []edit
+}
+
+// edit records that the oldLen bytes at start of a file, as it was when the
+// edit was made, were replaced with newLen bytes.
+type edit struct {
+	start, oldLen, newLen int
+}
+
+// A Patch is the change that a fix makes to a single file.
+type Patch struct {
+	// Path is relative to the directory of the Fixer.
+	Path string
+	Old  []byte
+	New  []byte
+	// Create is set if the file does not exist yet.
+	Create bool
+	// edits are the replacements of the fix, in order, or nil if the patch was
+	// edited, in which case its edit is worked out from Old and New.
+	edits []edit
+}
+
+func (f *Fixer) init(out io.Writer) {
+	if f.files != nil {
+		return
+	}
+	f.files = make(map[string]*fixedFile)
+	f.staged = make(map[string]bool)
+	if f.Stage {
+		f.staging = git(f.Directory, "rev-parse", "--is-inside-work-tree") == "true"
+		if !f.staging {
+			fmt.Fprint(out, Messages.Sprintf("%s is not in a git work tree, so fixes will not be staged.\n", f.Directory))
+		}
+	}
+}
+
+// ApplyAll applies the first fix of each note that can be applied, reporting
+// on out the notes that have none.
+func (f *Fixer) ApplyAll(notes []*notepb.Note, out io.Writer) (FixResult, error) {
+	f.init(out)
+	var result FixResult
+	for _, n := range fixable(notes) {
+		if err := f.applyFirst(n, out, &result); err != nil {
+			return f.done(result), err
+		}
+	}
+	return f.done(result), nil
+}
+
+// applyFirst applies the first fix of n that can be applied.
+func (f *Fixer) applyFirst(n *notepb.Note, out io.Writer, result *FixResult) error {
+	var errs []string
+	for _, fix := range n.Fix {
+		patches, err := f.Patches(fix)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		result.Applied++
+		return f.apply(patches, out)
+	}
+	result.Failed++
+	fmt.Fprint(out, Messages.Sprintf("Could not fix %s: %s\n", noteLocation(n), strings.Join(errs, "; ")))
+	return nil
+}
+
+// Interactive walks through the fixes of notes, showing on out the diff of
+// each and asking whether to apply it, not to apply it or to edit it before
+// deciding, reading the answers from in. The alternative fixes of a note are
+// offered in turn until one is applied.
+func (f *Fixer) Interactive(notes []*notepb.Note, in io.Reader, out io.Writer) (FixResult, error) {
+	f.init(out)
+	var result FixResult
+	answers := bufio.NewReader(in)
+	open := fixable(notes)
+	all := false
+next:
+	for i, n := range open {
+		if all {
+			if err := f.applyFirst(n, out, &result); err != nil {
+				return f.done(result), err
+			}
+			continue
+		}
+		fmt.Fprintf(out, "\n[%d/%d] %s [%s]\n\t%s\n", i+1, len(open), noteLocation(n), noteCategory(n), n.GetDescription())
+		failed := true
+		for j, fix := range n.Fix {
+			if len(n.Fix) > 1 {
+				fmt.Fprint(out, Messages.Sprintf("Fix %d of %d", j+1, len(n.Fix)))
+				if fix.Description != nil {
+					fmt.Fprintf(out, ": %s", fix.GetDescription())
+				}
+				fmt.Fprintln(out)
+			} else if fix.Description != nil {
+				fmt.Fprint(out, Messages.Sprintf("Fix: %s\n", fix.GetDescription()))
+			}
+			patches, err := f.Patches(fix)
+			if err != nil {
+				fmt.Fprint(out, Messages.Sprintf("This fix cannot be applied: %v\n", err))
+				continue
+			}
+			failed = false
+			for {
+				for _, p := range patches {
+					fmt.Fprint(out, f.diff(p))
+				}
+				answer, quit := askFix(answers, out, f.Edit != nil)
+				if quit {
+					result.Skipped = len(open) - i
+					break next
+				}
+				switch answer {
+				case "y", "a":
+					all = answer == "a"
+					result.Applied++
+					if err := f.apply(patches, out); err != nil {
+						return f.done(result), err
+					}
+					continue next
+				case "e":
+					if err := f.edit(patches); err != nil {
+						return f.done(result), err
+					}
+					continue
+				}
+				break
+			}
+		}
+		if failed {
+			result.Failed++
+		} else {
+			result.Rejected++
+		}
+	}
+	return f.done(result), nil
+}
+
+func (f *Fixer) done(result FixResult) FixResult {
+	for path := range f.staged {
+		result.Staged = append(result.Staged, path)
+	}
+	sort.Strings(result.Staged)
+	return result
+}
+
+// askFix asks whether to apply a fix until it gets a valid answer. It reports
+// quit if the user quits or there are no more answers.
+func askFix(answers *bufio.Reader, out io.Writer, canEdit bool) (answer string, quit bool) {
+	prompt := Messages.Text("Apply this fix? (y)es, (n)o, (e)dit, apply (a)ll remaining, (q)uit? ")
+	if !canEdit {
+		prompt = Messages.Text("Apply this fix? (y)es, (n)o, apply (a)ll remaining, (q)uit? ")
+	}
+	for {
+		fmt.Fprint(out, prompt)
+		line, err := answers.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(line))
+		switch {
+		case answer == "q":
+			return "", true
+		case answer == "y" || answer == "n" || answer == "a" || (answer == "e" && canEdit):
+			return answer, false
+		case err != nil:
+			fmt.Fprintln(out)
+			return "", true
+		}
+	}
+}
+
+// edit lets the user edit the new content of each of patches, in a copy of
+// the file named like it so that editors recognize the language.
+func (f *Fixer) edit(patches []Patch) error {
+	tmp, err := ioutil.TempDir("", "shipshape-fix")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	for i := range patches {
+		p := &patches[i]
+		path := filepath.Join(tmp, filepath.Base(p.Path))
+		if err := ioutil.WriteFile(path, p.New, 0600); err != nil {
+			return err
+		}
+		line, _ := changedLines(p.Old, p.New)
+		if err := f.Edit(path, line+1); err != nil {
+			return fmt.Errorf("could not edit the fix to %s: %v", p.Path, err)
+		}
+		edited, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		p.New = edited
+		p.edits = nil
+	}
+	return nil
+}
+
+// fixable returns the notes that have fixes.
+func fixable(notes []*notepb.Note) []*notepb.Note {
+	var open []*notepb.Note
+	for _, n := range notes {
+		if len(n.Fix) > 0 {
+			open = append(open, n)
+		}
+	}
+	return open
+}
+
+func noteLocation(n *notepb.Note) string {
+	loc := n.GetLocation().GetPath()
+	if loc == "" {
+		return Messages.Text("Global")
+	}
+	if line := n.GetLocation().GetRange().GetStartLine(); line > 0 {
+		loc = fmt.Sprintf("%s:%d", loc, line)
+	}
+	return loc
+}
+
+func noteCategory(n *notepb.Note) string {
+	if n.Subcategory != nil {
+		return n.GetCategory() + ":" + n.GetSubcategory()
+	}
+	return n.GetCategory()
+}
+
+// Patches works out the changes that fix makes to each file, taking the
+// changes already applied by f into account. It fails if the fix overlaps an
+// applied change or does not fit the files.
+func (f *Fixer) Patches(fix *notepb.Fix) ([]Patch, error) {
+	if f.files == nil {
+		f.init(ioutil.Discard)
+	}
+	var patches []Patch
+	byPath := make(map[string]int)
+	var spans [][]replacementSpan
+	for _, r := range fix.Replacement {
+		path := filepath.Clean(r.GetPath())
+		if r.GetPath() == "" || strings.HasSuffix(r.GetPath(), "/") {
+			return nil, fmt.Errorf("cannot replace %q, which is not a file", r.GetPath())
+		}
+		if filepath.IsAbs(path) || path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("%s is outside of %s", r.GetPath(), f.Directory)
+		}
+		file, err := f.file(path)
+		if err != nil {
+			return nil, err
+		}
+		if !file.exists && r.Range != nil {
+			return nil, fmt.Errorf("%s does not exist", path)
+		}
+		start, end, err := replacedSpan(file.original, r.Range)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if start, end, err = file.moved(start, end); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		i, ok := byPath[path]
+		if !ok {
+			i = len(patches)
+			byPath[path] = i
+			patches = append(patches, Patch{Path: path, Old: file.current, Create: !file.exists})
+			spans = append(spans, nil)
+		}
+		spans[i] = append(spans[i], replacementSpan{start, end, r.GetNewContent()})
+	}
+
+	for i := range patches {
+		s := spans[i]
+		sort.Stable(byStart(s))
+		p := &patches[i]
+		var b bytes.Buffer
+		last, delta := 0, 0
+		for j, r := range s {
+			if j > 0 && r.start < last {
+				return nil, fmt.Errorf("%s: the replacements of the fix overlap", p.Path)
+			}
+			b.Write(p.Old[last:r.start])
+			b.WriteString(r.content)
+			p.edits = append(p.edits, edit{r.start + delta, r.end - r.start, len(r.content)})
+			delta += len(r.content) - (r.end - r.start)
+			last = r.end
+		}
+		b.Write(p.Old[last:])
+		p.New = b.Bytes()
+	}
+	return patches, nil
+}
+
+// replacementSpan is a replacement of the bytes from start to end of the
+// current content of a file.
+type replacementSpan struct {
+	start, end int
+	content    string
+}
+
+type byStart []replacementSpan
+
+func (s byStart) Len() int           { return len(s) }
+func (s byStart) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byStart) Less(i, j int) bool { return s[i].start < s[j].start }
+
+// file returns the file at path, relative to the directory of f, reading it
+// the first time it is asked for.
+func (f *Fixer) file(path string) (*fixedFile, error) {
+	if file, ok := f.files[path]; ok {
+		return file, nil
+	}
+	content, err := ioutil.ReadFile(filepath.Join(f.Directory, path))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	file := &fixedFile{original: content, current: content, exists: err == nil}
+	f.files[path] = file
+	return file, nil
+}
+
+// replacedSpan returns the start and end offsets of the bytes of content that
+// are replaced within r. A replacement without a range replaces all of them.
+func replacedSpan(content []byte, r *notepb.FixRange) (start, end int, err error) {
+	if r == nil {
+		return 0, len(content), nil
+	}
+	if start, err = fixOffset(content, r.Start); err != nil {
+		return 0, 0, err
+	}
+	if end, err = fixOffset(content, r.End); err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("the replaced range ends before it starts")
+	}
+	return start, end, nil
+}
+
+// fixOffset returns the byte offset of p in content. Lines are numbered from
+// 0, and a line position is the start of the line.
+func fixOffset(content []byte, p *notepb.FixRange_Position) (int, error) {
+	switch {
+	case p == nil:
+		return 0, fmt.Errorf("the replaced range is missing a position")
+	case p.Byte != nil:
+		if b := int(p.GetByte()); b <= len(content) {
+			return b, nil
+		}
+		return 0, fmt.Errorf("byte %d is past the end of the file", p.GetByte())
+	case p.Line != nil:
+		offset := 0
+		for line := uint32(0); line < p.GetLine(); line++ {
+			i := bytes.IndexByte(content[offset:], '\n')
+			if i < 0 {
+				if line == p.GetLine()-1 && offset < len(content) {
+					// The end of a last line without a newline.
+					return len(content), nil
+				}
+				return 0, fmt.Errorf("line %d is past the end of the file", p.GetLine())
+			}
+			offset += i + 1
+		}
+		return offset, nil
+	}
+	return 0, fmt.Errorf("the replaced range has a position with neither a line nor a byte")
+}
+
+// moved maps the span from start to end of the original file onto its current
+// content, failing if the span overlaps a change that was made since.
+func (file *fixedFile) moved(start, end int) (int, int, error) {
+	for _, e := range file.edits {
+		editEnd := e.start + e.oldLen
+		if start < editEnd && end > e.start {
+			return 0, 0, fmt.Errorf("the fix overlaps a fix that was applied before it")
+		}
+		if start >= editEnd {
+			start += e.newLen - e.oldLen
+			end += e.newLen - e.oldLen
+		}
+	}
+	return start, end, nil
+}
+
+// apply writes patches to their files and stages them if f stages fixes.
+func (f *Fixer) apply(patches []Patch, out io.Writer) error {
+	for _, p := range patches {
+		file := f.files[p.Path]
+		path := filepath.Join(f.Directory, p.Path)
+		mode := os.FileMode(0644)
+		if fi, err := os.Stat(path); err == nil {
+			mode = fi.Mode()
+		} else if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, p.New, mode); err != nil {
+			return fmt.Errorf("could not apply the fix to %s: %v", p.Path, err)
+		}
+		edits := p.edits
+		if edits == nil {
+			start, oldEnd, newEnd := changedBytes(p.Old, p.New)
+			edits = []edit{{start, oldEnd - start, newEnd - start}}
+		}
+		file.edits = append(file.edits, edits...)
+		file.current = p.New
+		file.exists = true
+		if f.staging {
+			if err := f.stage(p); err != nil {
+				fmt.Fprint(out, Messages.Sprintf("Could not stage the fix to %s: %v\n", p.Path, err))
+			} else {
+				f.staged[p.Path] = true
+			}
+		}
+	}
+	return nil
+}
+
+// stage adds the change of p, and none of the other changes to its file, to
+// the git index.
+func (f *Fixer) stage(p Patch) error {
+	diff := p.Diff(3)
+	if diff == "" {
+		return nil
+	}
+	cmd := exec.Command("git", "apply", "--cached", "-")
+	cmd.Dir = f.Directory
+	cmd.Stdin = strings.NewReader(diff)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func (f *Fixer) diff(p Patch) string {
+	if f.Color {
+		theme := f.Theme
+		if theme == nil {
+			theme = DefaultTheme
+		}
+		return theme.ColorDiff(p.Diff(3))
+	}
+	return p.Diff(3)
+}
+
+// changedBytes returns where Old and New stop having the same prefix, and the
+// ends of the parts of each that differ.
+func changedBytes(old, new []byte) (start, oldEnd, newEnd int) {
+	for start < len(old) && start < len(new) && old[start] == new[start] {
+		start++
+	}
+	oldEnd, newEnd = len(old), len(new)
+	for oldEnd > start && newEnd > start && old[oldEnd-1] == new[newEnd-1] {
+		oldEnd--
+		newEnd--
+	}
+	return start, oldEnd, newEnd
+}
+
+// splitLines splits content into lines, each with its newline if it has one.
+func splitLines(content []byte) []string {
+	var lines []string
+	for len(content) > 0 {
+		i := bytes.IndexByte(content, '\n') + 1
+		if i == 0 {
+			i = len(content)
+		}
+		lines = append(lines, string(content[:i]))
+		content = content[i:]
+	}
+	return lines
+}
+
+// changedLines returns the number of lines that old and new start and end
+// with in common.
+func changedLines(old, new []byte) (prefix, suffix int) {
+	a, b := splitLines(old), splitLines(new)
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	return prefix, suffix
+}
+
+// Diff formats p as a unified diff with context lines around the changed
+// lines, which git apply accepts. It is empty if p changes nothing.
+func (p Patch) Diff(context int) string {
+	a, b := splitLines(p.Old), splitLines(p.New)
+	prefix, suffix := changedLines(p.Old, p.New)
+	if prefix == len(a) && prefix == len(b) {
+		return ""
+	}
+	first := prefix - context
+	if first < 0 {
+		first = 0
+	}
+	after := suffix - context
+	if after < 0 {
+		after = 0
+	}
+	oldLines, newLines := a[first:len(a)-after], b[first:len(b)-after]
+
+	var d bytes.Buffer
+	if p.Create {
+		fmt.Fprintf(&d, "--- /dev/null\n+++ b/%s\n", filepath.ToSlash(p.Path))
+	} else {
+		fmt.Fprintf(&d, "--- a/%s\n+++ b/%s\n", filepath.ToSlash(p.Path), filepath.ToSlash(p.Path))
+	}
+	fmt.Fprintf(&d, "@@ -%s +%s @@\n", hunkRange(first, len(oldLines)), hunkRange(first, len(newLines)))
+	writeLine := func(mark byte, line string) {
+		d.WriteByte(mark)
+		d.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			d.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+	for _, line := range a[first:prefix] {
+		writeLine(' ', line)
+	}
+	for _, line := range a[prefix : len(a)-suffix] {
+		writeLine('-', line)
+	}
+	for _, line := range b[prefix : len(b)-suffix] {
+		writeLine('+', line)
+	}
+	for _, line := range a[len(a)-suffix : len(a)-after] {
+		writeLine(' ', line)
+	}
+	return d.String()
+}
+
+// hunkRange formats the lines of a hunk that start after first lines. An
+// empty range is numbered by the line before it.
+func hunkRange(first, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", first)
+	}
+	return fmt.Sprintf("%d,%d", first+1, n)
+}
+
+// ColorDiff colors a unified diff for a terminal like git does: the file
+// names in bold, hunk headers in cyan, removed lines in red and added lines
+// in green.
+func ColorDiff(diff string) string {
+	return DefaultTheme.ColorDiff(diff)
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/google/shipshape/shipshape/cli"
+)
+
+// fixCommand implements `shipshape fix`, which applies the fixes that analyzers
+// suggested in a results file written by --json_output, either all of them or,
+// with --interactive, the ones the user accepts.
+func fixCommand(args []string) int {
+	fs := flag.NewFlagSet("fix", flag.ExitOnError)
+	interactive := fs.Bool("interactive", false, "Show the diff of each fix and ask whether to apply it, not to apply it or to edit it first")
+	dir := fs.String("dir", ".", "The directory that was analyzed, which the paths in the results are relative to")
+	stage := fs.Bool("stage", true, "Add the applied fixes, and none of the other changes to the same files, to the git index, if the directory is in a git work tree")
+	color := fs.Bool("color", cli.IsTerminal(os.Stdout), "Color the diffs of the fixes")
+	theme := fs.String("theme", "", "The colors of the diffs: default, high-contrast, or no-color to color nothing. By default, no-color if $NO_COLOR is set.")
+	fs.Usage = func() {
+		printf("USAGE: shipshape fix [--interactive] [--dir=<directory>] [--stage=false] <results.json>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return returnError
+	}
+
+	colors, err := cli.LookupTheme(*theme, os.Getenv)
+	if err != nil {
+		printf("Error: %v\n", err)
+		return returnError
+	}
+	results, err := cli.LoadResults(fs.Arg(0))
+	if err != nil {
+		printf("Error: %v\n", err)
+		return returnError
+	}
+	fixer := &cli.Fixer{Directory: *dir, Stage: *stage, Color: *color && colors.Colored(), Theme: colors, Edit: runEditor}
+	var result cli.FixResult
+	if *interactive {
+		result, err = fixer.Interactive(cli.AllNotes(results), os.Stdin, os.Stdout)
+	} else {
+		result, err = fixer.ApplyAll(cli.AllNotes(results), os.Stdout)
+	}
+	if err != nil {
+		printf("Error: %v\n", err)
+		return returnError
+	}
+	printf("\n%d fixes applied, %d rejected, %d could not be applied", result.Applied, result.Rejected, result.Failed)
+	if result.Skipped > 0 {
+		printf(", %d not decided on", result.Skipped)
+	}
+	fmt.Println()
+	if len(result.Staged) > 0 {
+		printf("Staged the fixes to %s\n", strings.Join(result.Staged, ", "))
+	}
+	if result.Rejected+result.Failed+result.Skipped > 0 {
+		return returnFindings
+	}
+	return returnNoFindings
+}
+
+// runEditor opens path at line in $EDITOR and waits for it to exit.
+func runEditor(path string, line int) error {
+	args := cli.EditorCommand(os.Getenv("EDITOR"), path, line)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
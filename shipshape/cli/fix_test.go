@@ -0,0 +1,280 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+)
+
+func byteRange(start, end uint32) *notepb.FixRange {
+	return &notepb.FixRange{
+		Start: &notepb.FixRange_Position{Byte: proto.Uint32(start)},
+		End:   &notepb.FixRange_Position{Byte: proto.Uint32(end)},
+	}
+}
+
+func lineRange(start, end uint32) *notepb.FixRange {
+	return &notepb.FixRange{
+		Start: &notepb.FixRange_Position{Line: proto.Uint32(start)},
+		End:   &notepb.FixRange_Position{Line: proto.Uint32(end)},
+	}
+}
+
+func replace(path string, r *notepb.FixRange, content string) *notepb.Replacement {
+	return &notepb.Replacement{Path: proto.String(path), Range: r, NewContent: proto.String(content)}
+}
+
+func fixNote(description string, fixes ...*notepb.Fix) *notepb.Note {
+	n := tableNote("PyLint", "a.py", 1, notepb.Note_WARNING, description)
+	n.Fix = fixes
+	return n
+}
+
+func TestPatches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fix_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{"a.py": "import os\nimport sys\nprint('hi')\n", "b.txt": "no newline"})
+
+	tests := []struct {
+		replacements []*notepb.Replacement
+		want         map[string]string
+	}{
+		{[]*notepb.Replacement{replace("a.py", byteRange(7, 9), "re")}, map[string]string{"a.py": "import re\nimport sys\nprint('hi')\n"}},
+		{[]*notepb.Replacement{replace("a.py", lineRange(1, 2), "")}, map[string]string{"a.py": "import os\nprint('hi')\n"}},
+		{[]*notepb.Replacement{replace("a.py", lineRange(3, 3), "exit()\n")}, map[string]string{"a.py": "import os\nimport sys\nprint('hi')\nexit()\n"}},
+		{[]*notepb.Replacement{replace("b.txt", lineRange(0, 1), "all new")}, map[string]string{"b.txt": "all new"}},
+		{[]*notepb.Replacement{replace("b.txt", nil, "whole file\n")}, map[string]string{"b.txt": "whole file\n"}},
+		{
+			[]*notepb.Replacement{
+				replace("a.py", byteRange(17, 20), "json"),
+				replace("b.txt", byteRange(0, 2), "some"),
+				replace("a.py", byteRange(7, 9), "re"),
+			},
+			map[string]string{"a.py": "import re\nimport json\nprint('hi')\n", "b.txt": "some newline"},
+		},
+		{[]*notepb.Replacement{replace("new.py", nil, "pass\n")}, map[string]string{"new.py": "pass\n"}},
+	}
+	for _, test := range tests {
+		f := &Fixer{Directory: dir}
+		patches, err := f.Patches(&notepb.Fix{Replacement: test.replacements})
+		if err != nil {
+			t.Errorf("Patches(%v) failed: %v", test.replacements, err)
+			continue
+		}
+		got := make(map[string]string)
+		for _, p := range patches {
+			got[p.Path] = string(p.New)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Patches(%v): got %q, want %q", test.replacements, got, test.want)
+		}
+	}
+
+	for _, r := range []*notepb.Replacement{
+		replace("a.py", byteRange(9, 7), ""),
+		replace("a.py", byteRange(0, 100), ""),
+		replace("a.py", lineRange(0, 5), ""),
+		replace("missing.py", lineRange(0, 1), ""),
+		replace("../a.py", nil, ""),
+		replace("dir/", nil, ""),
+	} {
+		f := &Fixer{Directory: dir}
+		if _, err := f.Patches(&notepb.Fix{Replacement: []*notepb.Replacement{r}}); err == nil {
+			t.Errorf("Patches(%v) succeeded, want an error", r)
+		}
+	}
+	f := &Fixer{Directory: dir}
+	overlapping := &notepb.Fix{Replacement: []*notepb.Replacement{replace("a.py", byteRange(0, 9), ""), replace("a.py", byteRange(7, 12), "")}}
+	if _, err := f.Patches(overlapping); err == nil {
+		t.Errorf("Patches succeeded with overlapping replacements, want an error")
+	}
+}
+
+func TestApplyAll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fix_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{"a.py": "import os\nimport sys\nprint('hi')\n"})
+
+	notes := []*notepb.Note{
+		fixNote("no fix"),
+		// The fixes refer to the file as it was analyzed, so the second one
+		// has to be moved by the first.
+		fixNote("rename os", &notepb.Fix{Replacement: []*notepb.Replacement{replace("a.py", byteRange(7, 9), "posix")}}),
+		fixNote("rename sys", &notepb.Fix{Replacement: []*notepb.Replacement{replace("a.py", byteRange(17, 20), "json")}}),
+		fixNote("overlaps", &notepb.Fix{Replacement: []*notepb.Replacement{replace("a.py", byteRange(0, 9), "")}}),
+		fixNote("second alternative",
+			&notepb.Fix{Replacement: []*notepb.Replacement{replace("a.py", byteRange(8, 9), "")}},
+			&notepb.Fix{Replacement: []*notepb.Replacement{replace("a.py", lineRange(2, 3), "print('bye')\n")}}),
+	}
+	var out bytes.Buffer
+	got, err := (&Fixer{Directory: dir}).ApplyAll(notes, &out)
+	if err != nil {
+		t.Fatalf("ApplyAll failed: %v", err)
+	}
+	if want := (FixResult{Applied: 3, Failed: 1}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong result: got %+v, want %+v", got, want)
+	}
+	if !strings.Contains(out.String(), "Could not fix a.py:1: a.py: the fix overlaps") {
+		t.Errorf("Failed fix not reported:\n%s", out.String())
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "a.py"))
+	if want := "import posix\nimport json\nprint('bye')\n"; string(b) != want {
+		t.Errorf("Wrong fixes:\ngot  %q\nwant %q", b, want)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		patch Patch
+		want  string
+	}{
+		{
+			Patch{Path: "a.py", Old: []byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n"), New: []byte("1\n2\n3\n4\nfive\n6\n7\n8\n9\n")},
+			"--- a/a.py\n+++ b/a.py\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			Patch{Path: "a.py", Old: []byte("1\n2\n"), New: []byte("1\n")},
+			"--- a/a.py\n+++ b/a.py\n@@ -1,2 +1,1 @@\n 1\n-2\n",
+		},
+		{
+			Patch{Path: "a.txt", Old: []byte("end"), New: []byte("end\n")},
+			"--- a/a.txt\n+++ b/a.txt\n@@ -1,1 +1,1 @@\n-end\n\\ No newline at end of file\n+end\n",
+		},
+		{
+			Patch{Path: "new.py", New: []byte("pass\n"), Create: true},
+			"--- /dev/null\n+++ b/new.py\n@@ -0,0 +1,1 @@\n+pass\n",
+		},
+		{Patch{Path: "a.py", Old: []byte("same\n"), New: []byte("same\n")}, ""},
+	}
+	for _, test := range tests {
+		if got := test.patch.Diff(3); got != test.want {
+			t.Errorf("Diff of %q to %q:\ngot  %q\nwant %q", test.patch.Old, test.patch.New, got, test.want)
+		}
+	}
+
+	colored := ColorDiff("--- a/a.py\n+++ b/a.py\n@@ -1,1 +1,1 @@\n-old\n+new\n")
+	if want := "\x1b[1m--- a/a.py\x1b[m\n\x1b[1m+++ b/a.py\x1b[m\n\x1b[36m@@ -1,1 +1,1 @@\x1b[m\n\x1b[31m-old\x1b[m\n\x1b[32m+new\x1b[m\n"; colored != want {
+		t.Errorf("Wrong colors:\ngot  %q\nwant %q", colored, want)
+	}
+}
+
+func TestInteractive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fix_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{"a.py": "import os\nimport sys\nprint('hi')\n"})
+
+	notes := []*notepb.Note{
+		fixNote("rename os", &notepb.Fix{Description: proto.String("Use posix"), Replacement: []*notepb.Replacement{replace("a.py", byteRange(7, 9), "posix")}}),
+		fixNote("remove sys", &notepb.Fix{Replacement: []*notepb.Replacement{replace("a.py", lineRange(1, 2), "")}}),
+		fixNote("say bye",
+			&notepb.Fix{Description: proto.String("Say bye"), Replacement: []*notepb.Replacement{replace("a.py", lineRange(2, 3), "print('bye')\n")}},
+			&notepb.Fix{Description: proto.String("Say ciao"), Replacement: []*notepb.Replacement{replace("a.py", lineRange(2, 3), "print('ciao')\n")}}),
+		fixNote("not decided on", &notepb.Fix{Replacement: []*notepb.Replacement{replace("a.py", lineRange(3, 3), "exit()\n")}}),
+	}
+	var edited string
+	f := &Fixer{
+		Directory: dir,
+		Edit: func(path string, line int) error {
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			edited = filepath.Base(path)
+			return ioutil.WriteFile(path, bytes.Replace(b, []byte("ciao"), []byte("hello"), 1), 0644)
+		},
+	}
+	// "x" is not an answer, so it is asked again. The second fix is rejected,
+	// the first alternative of the third is rejected and the second edited.
+	in := strings.NewReader("x\ny\nn\nn\ne\ny\nq\n")
+	var out bytes.Buffer
+	got, err := f.Interactive(notes, in, &out)
+	if err != nil {
+		t.Fatalf("Interactive failed: %v", err)
+	}
+	if want := (FixResult{Applied: 2, Rejected: 1, Skipped: 1}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong result: got %+v, want %+v", got, want)
+	}
+	for _, want := range []string{"[1/4] a.py:1 [PyLint]", "Fix: Use posix", "-import os\n+import posix\n", "Fix 2 of 2: Say ciao", "+print('hello')\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("%q not shown:\n%s", want, out.String())
+		}
+	}
+	if edited != "a.py" {
+		t.Errorf("Wrong file edited: got %q, want a copy of a.py", edited)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "a.py"))
+	if want := "import posix\nimport sys\nprint('hello')\n"; string(b) != want {
+		t.Errorf("Wrong fixes:\ngot  %q\nwant %q", b, want)
+	}
+}
+
+func TestStageFixes(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "fix_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{"src/a.py": "import os\nimport sys\n\n\n\n\n\nprint('hi')\n"})
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+	// A change of the user's own, which is not to be staged.
+	writeFiles(t, dir, map[string]string{"src/a.py": "import os\nimport sys\n\n\n\n\n\nprint('hello')\n"})
+
+	notes := []*notepb.Note{fixNote("rename os", &notepb.Fix{Replacement: []*notepb.Replacement{replace("a.py", byteRange(7, 9), "posix")}})}
+	f := &Fixer{Directory: filepath.Join(dir, "src"), Stage: true}
+	got, err := f.ApplyAll(notes, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("ApplyAll failed: %v", err)
+	}
+	if want := []string{"a.py"}; !reflect.DeepEqual(got.Staged, want) {
+		t.Errorf("Wrong files staged: got %v, want %v", got.Staged, want)
+	}
+	staged := git(dir, "diff", "--cached")
+	if !strings.Contains(staged, "+import posix") || strings.Contains(staged, "hello") {
+		t.Errorf("Wrong changes staged:\n%s", staged)
+	}
+}
@@ -0,0 +1,298 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	glog "github.com/google/shipshape/third_party/go-glog"
+)
+
+// maxWebhookBody is the largest webhook payload that is read.
+const maxWebhookBody = 25 << 20
+
+// GitEvent is a push or a pull request that a git server sent a webhook for.
+type GitEvent struct {
+	// Provider is github or gitlab.
+	Provider string
+	// Repo is the full name of the repository, such as example/billing.
+	Repo     string
+	CloneURL string
+	// Ref is the ref to fetch to check out Commit, such as refs/heads/main
+	// or refs/pull/12/head.
+	Ref    string
+	Commit string
+	// PullRequest is the number of the pull (or merge) request, or 0 for a
+	// push.
+	PullRequest int
+	// Schedule is the name of the schedule that the event is a run of, for
+	// the events of a Scheduler, which also set the Pipeline to run and the
+	// Path in the repository to analyze, if not all of it.
+	Schedule string
+	Pipeline string
+	Path     string
+}
+
+// String describes e for the results, e.g. "example/billing pull request 12".
+func (e *GitEvent) String() string {
+	desc := e.Repo
+	switch {
+	case e.PullRequest != 0:
+		desc += fmt.Sprintf(" pull request %d", e.PullRequest)
+	case e.Schedule != "":
+		desc += " on schedule " + e.Schedule
+	default:
+		desc += " " + strings.TrimPrefix(e.Ref, "refs/heads/")
+	}
+	if len(e.Commit) >= 7 {
+		desc += " (" + e.Commit[:7] + ")"
+	}
+	return desc
+}
+
+// ParseGitHubEvent parses a webhook request from GitHub, checking its
+// signature against secret. It returns nil, with no error, for the events
+// that are not analyzed: events other than push and pull_request, pushes that
+// delete a branch or push a tag, and pull requests that are closed or only
+// relabelled.
+func ParseGitHubEvent(r *http.Request, body []byte, secret string) (*GitEvent, error) {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(r.Header.Get("X-Hub-Signature-256")), []byte(want)) {
+		return nil, errBadSecret
+	}
+	var payload struct {
+		Ref     string `json:"ref"`
+		After   string `json:"after"`
+		Deleted bool   `json:"deleted"`
+		Action  string `json:"action"`
+		Number  int    `json:"number"`
+		Pull    struct {
+			Head struct {
+				Sha string `json:"sha"`
+			} `json:"head"`
+		} `json:"pull_request"`
+		Repository struct {
+			FullName string `json:"full_name"`
+			CloneURL string `json:"clone_url"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("could not parse the payload: %v", err)
+	}
+	e := &GitEvent{Provider: "github", Repo: payload.Repository.FullName, CloneURL: payload.Repository.CloneURL}
+	switch r.Header.Get("X-GitHub-Event") {
+	case "push":
+		if payload.Deleted || !strings.HasPrefix(payload.Ref, "refs/heads/") {
+			return nil, nil
+		}
+		e.Ref, e.Commit = payload.Ref, payload.After
+	case "pull_request":
+		switch payload.Action {
+		case "opened", "reopened", "synchronize":
+		default:
+			return nil, nil
+		}
+		e.Ref = fmt.Sprintf("refs/pull/%d/head", payload.Number)
+		e.Commit, e.PullRequest = payload.Pull.Head.Sha, payload.Number
+	default:
+		return nil, nil
+	}
+	return e, e.validate()
+}
+
+// ParseGitLabEvent parses a webhook request from GitLab, checking its token
+// against secret. It returns nil, with no error, for the events that are not
+// analyzed: events other than pushes and merge requests, pushes that delete a
+// branch or push a tag, and merge requests that are not opened or updated.
+func ParseGitLabEvent(r *http.Request, body []byte, secret string) (*GitEvent, error) {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Gitlab-Token")), []byte(secret)) != 1 {
+		return nil, errBadSecret
+	}
+	var payload struct {
+		Ref     string `json:"ref"`
+		After   string `json:"after"`
+		Project struct {
+			PathWithNamespace string `json:"path_with_namespace"`
+			HTTPURL           string `json:"git_http_url"`
+		} `json:"project"`
+		Attributes struct {
+			IID        int    `json:"iid"`
+			Action     string `json:"action"`
+			LastCommit struct {
+				ID string `json:"id"`
+			} `json:"last_commit"`
+		} `json:"object_attributes"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("could not parse the payload: %v", err)
+	}
+	e := &GitEvent{Provider: "gitlab", Repo: payload.Project.PathWithNamespace, CloneURL: payload.Project.HTTPURL}
+	switch r.Header.Get("X-Gitlab-Event") {
+	case "Push Hook":
+		if strings.Trim(payload.After, "0") == "" || !strings.HasPrefix(payload.Ref, "refs/heads/") {
+			return nil, nil
+		}
+		e.Ref, e.Commit = payload.Ref, payload.After
+	case "Merge Request Hook":
+		switch payload.Attributes.Action {
+		case "open", "reopen", "update":
+		default:
+			return nil, nil
+		}
+		e.Ref = fmt.Sprintf("refs/merge-requests/%d/head", payload.Attributes.IID)
+		e.Commit, e.PullRequest = payload.Attributes.LastCommit.ID, payload.Attributes.IID
+	default:
+		return nil, nil
+	}
+	return e, e.validate()
+}
+
+// errBadSecret is returned for webhooks that were not signed with the secret.
+var errBadSecret = errors.New("the webhook secret does not match")
+
+func (e *GitEvent) validate() error {
+	if e.Repo == "" || e.CloneURL == "" || e.Ref == "" {
+		return fmt.Errorf("the payload has no repository or ref")
+	}
+	return nil
+}
+
+// WebhookReceiver is an http.Handler for the webhooks of GitHub (at /github)
+// and GitLab (at /gitlab). It answers each webhook as soon as it is parsed,
+// and queues the push or pull request for Analyze, which is called for one
+// event at a time.
+type WebhookReceiver struct {
+	// Secret is the secret that GitHub signs the webhooks with, or the
+	// token that GitLab sends with them.
+	Secret string
+	// Repos maps the full names of the repositories to analyze to the URLs
+	// to clone them from. If a URL is empty, the one in the webhook is used.
+	// If Repos is empty, every repository that knows the secret is analyzed.
+	Repos   map[string]string
+	Analyze func(*GitEvent)
+	queue   chan *GitEvent
+}
+
+// NewWebhookReceiver returns a receiver that queues at most size events and
+// starts analyzing them. Each of repos is the full name of a repository to
+// analyze, optionally followed by = and the URL to clone it from.
+func NewWebhookReceiver(secret string, repos []string, size int, analyze func(*GitEvent)) *WebhookReceiver {
+	w := &WebhookReceiver{Secret: secret, Repos: make(map[string]string), Analyze: analyze, queue: make(chan *GitEvent, size)}
+	for _, r := range repos {
+		parts := strings.SplitN(r, "=", 2)
+		w.Repos[parts[0]] = ""
+		if len(parts) == 2 {
+			w.Repos[parts[0]] = parts[1]
+		}
+	}
+	go func() {
+		for e := range w.queue {
+			w.Analyze(e)
+		}
+	}()
+	return w
+}
+
+func (w *WebhookReceiver) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	var parse func(*http.Request, []byte, string) (*GitEvent, error)
+	switch r.URL.Path {
+	case "/github":
+		parse = ParseGitHubEvent
+	case "/gitlab":
+		parse = ParseGitLabEvent
+	default:
+		http.NotFound(rw, r)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(rw, "webhooks must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(rw, r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("could not read the payload: %v", err), http.StatusBadRequest)
+		return
+	}
+	e, err := parse(r, body, w.Secret)
+	switch {
+	case err == errBadSecret:
+		glog.Warningf("Rejected a webhook to %s from %s: %v", r.URL.Path, r.RemoteAddr, err)
+		http.Error(rw, err.Error(), http.StatusUnauthorized)
+		return
+	case err != nil:
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	case e == nil:
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+	var configured string
+	if len(w.Repos) > 0 {
+		url, ok := w.Repos[e.Repo]
+		if !ok {
+			http.Error(rw, fmt.Sprintf("repository %s is not analyzed here", e.Repo), http.StatusForbidden)
+			return
+		}
+		configured = url
+	}
+	if configured != "" {
+		e.CloneURL = configured
+	} else if !remoteCloneURL(e.CloneURL) {
+		http.Error(rw, fmt.Sprintf("the clone URL of %s must be an https or ssh URL", e.Repo), http.StatusBadRequest)
+		return
+	}
+	if !w.Queue(e) {
+		http.Error(rw, "too many analyses are queued", http.StatusServiceUnavailable)
+		return
+	}
+	rw.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(rw, "queued %s\n", e)
+}
+
+// remoteCloneURL reports whether u, a clone URL sent in a webhook, is one to
+// clone from: an https or ssh URL of a host. Others, such as file:// URLs and
+// git's ext:: transport, could make the server read local paths or run
+// commands.
+func remoteCloneURL(u string) bool {
+	parsed, err := url.Parse(u)
+	return err == nil && (parsed.Scheme == "https" || parsed.Scheme == "ssh") && parsed.Host != ""
+}
+
+// Queue queues e for Analyze, unless the queue is full, and returns whether
+// it did.
+func (w *WebhookReceiver) Queue(e *GitEvent) bool {
+	select {
+	case w.queue <- e:
+		glog.Infof("Queued %s", e)
+		return true
+	default:
+		glog.Warningf("Dropped %s, because too many analyses are queued", e)
+		return false
+	}
+}
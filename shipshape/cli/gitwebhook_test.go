@@ -0,0 +1,195 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newRequest returns a request to the receiver for path.
+func newRequest(method, path, body string) *http.Request {
+	r, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func githubRequest(event, secret, body string) *http.Request {
+	r := newRequest("POST", "/github", body)
+	r.Header.Set("X-GitHub-Event", event)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	r.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+const githubRepository = `"repository": {"full_name": "example/billing", "clone_url": "https://github.com/example/billing.git"}`
+
+func TestParseGitHubEvent(t *testing.T) {
+	tests := []struct {
+		event, body string
+		want        *GitEvent
+	}{
+		{"push", `{"ref": "refs/heads/main", "after": "abc1234def", ` + githubRepository + `}`, &GitEvent{
+			Provider: "github", Repo: "example/billing", CloneURL: "https://github.com/example/billing.git",
+			Ref: "refs/heads/main", Commit: "abc1234def",
+		}},
+		{"pull_request", `{"action": "synchronize", "number": 12, "pull_request": {"head": {"sha": "fed4321"}}, ` + githubRepository + `}`, &GitEvent{
+			Provider: "github", Repo: "example/billing", CloneURL: "https://github.com/example/billing.git",
+			Ref: "refs/pull/12/head", Commit: "fed4321", PullRequest: 12,
+		}},
+		{"push", `{"ref": "refs/heads/old", "deleted": true, ` + githubRepository + `}`, nil},
+		{"push", `{"ref": "refs/tags/v1", "after": "abc", ` + githubRepository + `}`, nil},
+		{"pull_request", `{"action": "closed", "number": 12, ` + githubRepository + `}`, nil},
+		{"issues", `{}`, nil},
+	}
+	for _, test := range tests {
+		got, err := ParseGitHubEvent(githubRequest(test.event, "s3cret", test.body), []byte(test.body), "s3cret")
+		if err != nil {
+			t.Errorf("ParseGitHubEvent(%s, %s) failed: %v", test.event, test.body, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseGitHubEvent(%s, %s) = %+v, want %+v", test.event, test.body, got, test.want)
+		}
+	}
+
+	body := `{"ref": "refs/heads/main", "after": "abc", ` + githubRepository + `}`
+	if _, err := ParseGitHubEvent(githubRequest("push", "wrong", body), []byte(body), "s3cret"); err != errBadSecret {
+		t.Errorf("ParseGitHubEvent with the wrong signature returned %v, want %v", err, errBadSecret)
+	}
+}
+
+func TestParseGitLabEvent(t *testing.T) {
+	project := `"project": {"path_with_namespace": "example/frontend", "git_http_url": "https://gitlab.com/example/frontend.git"}`
+	tests := []struct {
+		event, body string
+		want        *GitEvent
+	}{
+		{"Push Hook", `{"ref": "refs/heads/main", "after": "abc1234", ` + project + `}`, &GitEvent{
+			Provider: "gitlab", Repo: "example/frontend", CloneURL: "https://gitlab.com/example/frontend.git",
+			Ref: "refs/heads/main", Commit: "abc1234",
+		}},
+		{"Merge Request Hook", `{"object_attributes": {"iid": 7, "action": "update", "last_commit": {"id": "fed"}}, ` + project + `}`, &GitEvent{
+			Provider: "gitlab", Repo: "example/frontend", CloneURL: "https://gitlab.com/example/frontend.git",
+			Ref: "refs/merge-requests/7/head", Commit: "fed", PullRequest: 7,
+		}},
+		{"Push Hook", `{"ref": "refs/heads/old", "after": "0000000000000000000000000000000000000000", ` + project + `}`, nil},
+		{"Push Hook", `{"ref": "--upload-pack=touch /tmp/pwned", "after": "abc1234", ` + project + `}`, nil},
+		{"Merge Request Hook", `{"object_attributes": {"iid": 7, "action": "merge"}, ` + project + `}`, nil},
+	}
+	for _, test := range tests {
+		r := newRequest("POST", "/gitlab", test.body)
+		r.Header.Set("X-Gitlab-Event", test.event)
+		r.Header.Set("X-Gitlab-Token", "s3cret")
+		got, err := ParseGitLabEvent(r, []byte(test.body), "s3cret")
+		if err != nil {
+			t.Errorf("ParseGitLabEvent(%s, %s) failed: %v", test.event, test.body, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseGitLabEvent(%s, %s) = %+v, want %+v", test.event, test.body, got, test.want)
+		}
+	}
+
+	r := newRequest("POST", "/gitlab", "")
+	r.Header.Set("X-Gitlab-Event", "Push Hook")
+	if _, err := ParseGitLabEvent(r, []byte(`{}`), "s3cret"); err != errBadSecret {
+		t.Errorf("ParseGitLabEvent without the token returned %v, want %v", err, errBadSecret)
+	}
+}
+
+func TestGitEventString(t *testing.T) {
+	tests := []struct {
+		event *GitEvent
+		want  string
+	}{
+		{&GitEvent{Repo: "example/billing", Ref: "refs/heads/main", Commit: "abc1234def"}, "example/billing main (abc1234)"},
+		{&GitEvent{Repo: "example/billing", Ref: "refs/pull/12/head", PullRequest: 12}, "example/billing pull request 12"},
+	}
+	for _, test := range tests {
+		if got := test.event.String(); got != test.want {
+			t.Errorf("%+v.String() = %q, want %q", test.event, got, test.want)
+		}
+	}
+}
+
+func TestWebhookReceiver(t *testing.T) {
+	analyzed := make(chan *GitEvent, 1)
+	w := NewWebhookReceiver("s3cret", []string{"example/billing=https://git.example.com/billing.git"}, 1, func(e *GitEvent) { analyzed <- e })
+
+	push := `{"ref": "refs/heads/main", "after": "abc", ` + githubRepository + `}`
+	other := `{"ref": "refs/heads/main", "after": "abc", "repository": {"full_name": "example/other", "clone_url": "https://github.com/example/other.git"}}`
+	tests := []struct {
+		r    *http.Request
+		want int
+	}{
+		{githubRequest("push", "s3cret", push), http.StatusAccepted},
+		{githubRequest("push", "wrong", push), http.StatusUnauthorized},
+		{githubRequest("push", "s3cret", other), http.StatusForbidden},
+		{githubRequest("ping", "s3cret", `{}`), http.StatusNoContent},
+		{githubRequest("push", "s3cret", `not json`), http.StatusBadRequest},
+		{newRequest("GET", "/github", ""), http.StatusMethodNotAllowed},
+		{newRequest("POST", "/bitbucket", ""), http.StatusNotFound},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		w.ServeHTTP(rec, test.r)
+		if rec.Code != test.want {
+			t.Errorf("%s %s returned %d, want %d: %s", test.r.Method, test.r.URL.Path, rec.Code, test.want, rec.Body)
+		}
+	}
+
+	select {
+	case e := <-analyzed:
+		if e.Repo != "example/billing" || e.Commit != "abc" {
+			t.Errorf("Wrong event analyzed: %+v", e)
+		}
+		if want := "https://git.example.com/billing.git"; e.CloneURL != want {
+			t.Errorf("Wrong clone URL: got %q, want the configured %q", e.CloneURL, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("The push was not analyzed")
+	}
+}
+
+func TestWebhookReceiverCloneURLs(t *testing.T) {
+	w := NewWebhookReceiver("s3cret", nil, 10, func(*GitEvent) {})
+	for _, test := range []struct {
+		url  string
+		want int
+	}{
+		{"https://github.com/example/billing.git", http.StatusAccepted},
+		{"ssh://git@github.com/example/billing.git", http.StatusAccepted},
+		{"file:///etc", http.StatusBadRequest},
+		{"ext::sh -c touch% /tmp/pwned", http.StatusBadRequest},
+		{"/srv/git/billing.git", http.StatusBadRequest},
+		{"http://github.com/example/billing.git", http.StatusBadRequest},
+	} {
+		push := `{"ref": "refs/heads/main", "after": "abc", "repository": {"full_name": "example/billing", "clone_url": "` + test.url + `"}}`
+		rec := httptest.NewRecorder()
+		w.ServeHTTP(rec, githubRequest("push", "s3cret", push))
+		if rec.Code != test.want {
+			t.Errorf("A push to clone from %s returned %d, want %d: %s", test.url, rec.Code, test.want, rec.Body)
+		}
+	}
+}
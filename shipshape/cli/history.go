@@ -0,0 +1,162 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// HistoryNote is the record of a single note kept in the results history.
+type HistoryNote struct {
+	Fingerprint string `json:"fingerprint"`
+	Category    string `json:"category"`
+	Path        string `json:"path,omitempty"`
+	Line        int32  `json:"line,omitempty"`
+	Description string `json:"description"`
+}
+
+// HistoryRun is the record of a single run kept in the results history.
+type HistoryRun struct {
+	Metadata RunMetadata   `json:"metadata"`
+	Notes    []HistoryNote `json:"notes"`
+}
+
+// History is an append-only store of past runs. Each run is kept as one JSON
+// record per line, so recording a run never needs to rewrite earlier ones and
+// the file can be inspected or trimmed with ordinary tools.
+type History struct {
+	path string
+}
+
+// OpenHistory returns the history stored at path. The file is created when
+// the first run is recorded.
+func OpenHistory(path string) *History {
+	return &History{path}
+}
+
+// Record appends a run with the given metadata and results to the history.
+func (h *History) Record(md RunMetadata, resp *rpcpb.ShipshapeResponse) error {
+	run := HistoryRun{Metadata: md}
+	for _, analysis := range resp.AnalyzeResponse {
+		for _, note := range analysis.Note {
+			run.Notes = append(run.Notes, HistoryNote{
+				Fingerprint: Fingerprint(note),
+				Category:    note.GetCategory(),
+				Path:        note.GetLocation().GetPath(),
+				Line:        note.GetLocation().GetRange().GetStartLine(),
+				Description: note.GetDescription(),
+			})
+		}
+	}
+	b, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open history %s: %v", h.path, err)
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("could not write to history %s: %v", h.path, err)
+	}
+	return f.Close()
+}
+
+// Runs returns all the recorded runs, oldest first.
+func (h *History) Runs() ([]HistoryRun, error) {
+	f, err := os.Open(h.path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open history %s: %v", h.path, err)
+	}
+	defer f.Close()
+
+	var runs []HistoryRun
+	scanner := bufio.NewScanner(f)
+	// Runs with many notes make for long lines.
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var run HistoryRun
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			return nil, fmt.Errorf("%s:%d: corrupt history record: %v", h.path, line, err)
+		}
+		runs = append(runs, run)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Stable(byTimestamp(runs))
+	return runs, nil
+}
+
+type byTimestamp []HistoryRun
+
+func (r byTimestamp) Len() int      { return len(r) }
+func (r byTimestamp) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r byTimestamp) Less(i, j int) bool {
+	return r[i].Metadata.Timestamp.Before(r[j].Metadata.Timestamp)
+}
+
+// FindRun returns the most recent run whose commit starts with commit.
+func FindRun(runs []HistoryRun, commit string) (HistoryRun, bool) {
+	for i := len(runs) - 1; i >= 0; i-- {
+		if c := runs[i].Metadata.Commit; c != "" && strings.HasPrefix(c, commit) {
+			return runs[i], true
+		}
+	}
+	return HistoryRun{}, false
+}
+
+// Trend describes how the notes changed between two runs.
+type Trend struct {
+	New        []HistoryNote
+	Fixed      []HistoryNote
+	Persisting []HistoryNote
+}
+
+// CompareRuns matches the notes of two runs by fingerprint. Notes with the
+// same fingerprint are paired off one to one, so a duplicated note that
+// appears once more in the newer run counts as new.
+func CompareRuns(older, newer HistoryRun) Trend {
+	remaining := make(map[string][]HistoryNote)
+	for _, n := range older.Notes {
+		remaining[n.Fingerprint] = append(remaining[n.Fingerprint], n)
+	}
+	var t Trend
+	for _, n := range newer.Notes {
+		if old := remaining[n.Fingerprint]; len(old) > 0 {
+			remaining[n.Fingerprint] = old[1:]
+			t.Persisting = append(t.Persisting, n)
+		} else {
+			t.New = append(t.New, n)
+		}
+	}
+	for _, n := range older.Notes {
+		if old := remaining[n.Fingerprint]; len(old) > 0 {
+			remaining[n.Fingerprint] = old[1:]
+			t.Fixed = append(t.Fixed, n)
+		}
+	}
+	return t
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+	textpb "github.com/google/shipshape/shipshape/proto/textrange_proto"
+)
+
+func createNote(category, path string, line int32, description string) *notepb.Note {
+	return &notepb.Note{
+		Category:    proto.String(category),
+		Description: proto.String(description),
+		Location: &notepb.Location{
+			Path:  proto.String(path),
+			Range: &textpb.TextRange{StartLine: proto.Int32(line)},
+		},
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	base := createNote("PyLint", "a.py", 3, "Unused import os")
+	tests := []struct {
+		desc string
+		note *notepb.Note
+		same bool
+	}{
+		{"identical note", createNote("PyLint", "a.py", 3, "Unused import os"), true},
+		{"moved to another line", createNote("PyLint", "a.py", 10, "Unused import os"), true},
+		{"different file", createNote("PyLint", "b.py", 3, "Unused import os"), false},
+		{"different category", createNote("JSHint", "a.py", 3, "Unused import os"), false},
+		{"different description", createNote("PyLint", "a.py", 3, "Unused import sys"), false},
+		{"fields shifted", createNote("PyLin", "ta.py", 3, "Unused import os"), false},
+	}
+	for _, test := range tests {
+		if got := Fingerprint(test.note) == Fingerprint(base); got != test.same {
+			t.Errorf("%s: fingerprints equal = %v, want %v", test.desc, got, test.same)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	h := OpenHistory(filepath.Join(dir, "history"))
+
+	start := time.Date(2015, 10, 9, 12, 0, 0, 0, time.UTC)
+	runs := []struct {
+		md    RunMetadata
+		notes []*notepb.Note
+	}{
+		{
+			RunMetadata{Commit: "aaaa", Timestamp: start},
+			[]*notepb.Note{
+				createNote("PyLint", "a.py", 3, "Unused import os"),
+				createNote("PyLint", "a.py", 7, "Line too long"),
+			},
+		},
+		{
+			RunMetadata{Commit: "bbbb", Timestamp: start.Add(time.Hour)},
+			[]*notepb.Note{
+				createNote("PyLint", "a.py", 5, "Unused import os"),
+				createNote("JSHint", "b.js", 1, "Missing semicolon"),
+				createNote("JSHint", "b.js", 9, "Missing semicolon"),
+			},
+		},
+	}
+	// Record out of order; Runs should still sort by time.
+	for i := len(runs) - 1; i >= 0; i-- {
+		resp := &rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{{Note: runs[i].notes}}}
+		if err := h.Record(runs[i].md, resp); err != nil {
+			t.Fatalf("Could not record run: %v", err)
+		}
+	}
+
+	got, err := h.Runs()
+	if err != nil {
+		t.Fatalf("Could not read runs: %v", err)
+	}
+	if len(got) != 2 || got[0].Metadata.Commit != "aaaa" || got[1].Metadata.Commit != "bbbb" {
+		t.Fatalf("Wrong runs: got %v", got)
+	}
+
+	older, ok := FindRun(got, "aa")
+	if !ok {
+		t.Fatalf("Could not find run for commit prefix aa")
+	}
+	if _, ok := FindRun(got, "cccc"); ok {
+		t.Errorf("Found a run for a commit that was not recorded")
+	}
+
+	trend := CompareRuns(older, got[1])
+	if len(trend.New) != 2 || len(trend.Fixed) != 1 || len(trend.Persisting) != 1 {
+		t.Errorf("Wrong trend: got %d new, %d fixed, %d persisting; want 2, 1, 1", len(trend.New), len(trend.Fixed), len(trend.Persisting))
+	}
+	if len(trend.Fixed) == 1 && trend.Fixed[0].Description != "Line too long" {
+		t.Errorf("Wrong fixed note: got %v", trend.Fixed[0])
+	}
+}
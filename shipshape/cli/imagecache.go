@@ -0,0 +1,242 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/shipshape/shipshape/util/docker"
+	glog "github.com/google/shipshape/third_party/go-glog"
+)
+
+// pullFactor estimates the disk space that pulling an image takes from the
+// compressed size that the registry reports: unpacked layers are about twice
+// as big, and the compressed ones are on disk too until they are unpacked.
+const pullFactor = 3
+
+// sizeUnits are the suffixes that ParseSize accepts, in powers of 1024.
+var sizeUnits = []string{"K", "M", "G", "T"}
+
+// ParseSize parses a size in bytes, optionally followed by K, M, G or T for
+// powers of 1024, and optionally B, e.g. 20G or 512MB.
+func ParseSize(s string) (int64, error) {
+	num := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	multiplier := int64(1)
+	for i, unit := range sizeUnits {
+		if strings.HasSuffix(num, unit) {
+			num = strings.TrimSuffix(num, unit)
+			multiplier = 1 << (10 * uint(i+1))
+			break
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a size: it must be a number of bytes, optionally followed by K, M, G or T", s)
+	}
+	return n * multiplier, nil
+}
+
+// FormatSize formats a number of bytes for people, e.g. 1.5 GB.
+func FormatSize(bytes int64) string {
+	if bytes < 1024 {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	size, unit := float64(bytes)/1024, 0
+	for size >= 1024 && unit < len(sizeUnits)-1 {
+		size /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f %sB", size, sizeUnits[unit])
+}
+
+// pullSpace estimates the disk space that pulling images takes, from the sizes
+// that reg reports. Images whose local copy, as found by localDigest, is
+// already what the registry has are not pulled, so they take none. It returns
+// the estimate and the images it covers; images that can't be looked up are
+// left out of both.
+func pullSpace(images []string, reg docker.Registry, localDigest func(string) string) (int64, []string) {
+	var total int64
+	var pulled []string
+	for _, image := range images {
+		remote, err := reg.Inspect(image)
+		if err != nil {
+			glog.Infof("Could not find the size of %s, so leaving it out of the disk space check: %v", image, err)
+			continue
+		}
+		if local := localDigest(image); local != "" && local == remote.Digest {
+			continue
+		}
+		total += remote.Size * pullFactor
+		pulled = append(pulled, image)
+	}
+	return total, pulled
+}
+
+// checkPullSpace returns an error if pulling images, which is estimated to
+// take needed bytes, would not fit in the free bytes of dir.
+func checkPullSpace(needed int64, free uint64, dir string, images []string) error {
+	if needed <= 0 || uint64(needed) <= free {
+		return nil
+	}
+	return fmt.Errorf("not enough disk space to pull %s: they need about %s, but only %s is free in %s. Free up space, e.g. with --max_image_cache or 'docker image prune'", strings.Join(images, ", "), FormatSize(needed), FormatSize(int64(free)), dir)
+}
+
+// checkDiskSpace fails if the images to pull would not fit in the space that
+// the docker daemon has for images. If either can't be found out, it only
+// logs why.
+func checkDiskSpace(images []string) error {
+	free, dir, err := docker.FreeSpace()
+	if err != nil {
+		glog.Infof("Could not find the free disk space for images, so not checking it: %v", err)
+		return nil
+	}
+	needed, pulled := pullSpace(images, docker.Registry{}, docker.LocalRepoDigest)
+	glog.Infof("Pulling %v needs about %s; %s is free in %s", pulled, FormatSize(needed), FormatSize(int64(free)), dir)
+	return checkPullSpace(needed, free, dir, pulled)
+}
+
+// ImageUse records when each image was last used by a run, for evicting the
+// least recently used ones.
+type ImageUse map[string]time.Time
+
+// LoadImageUse reads the record in path, which need not exist.
+func LoadImageUse(path string) (ImageUse, error) {
+	use := make(ImageUse)
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return use, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &use); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %v", path, err)
+	}
+	return use, nil
+}
+
+// Save writes the record to path, creating its directory if needed.
+func (u ImageUse) Save(path string) error {
+	b, err := json.MarshalIndent(u, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// Evict removes the least recently used images, other than those in keep,
+// until the images in the record take at most max bytes. size and remove find
+// the size of a local image and remove it. Images that are no longer there
+// are dropped from the record, and ones that can't be removed, e.g. because a
+// container is using them, are skipped. It returns the images removed.
+func (u ImageUse) Evict(max int64, keep []string, size func(string) (int64, error), remove func(string) error) []string {
+	kept := make(map[string]bool)
+	for _, image := range keep {
+		kept[image] = true
+	}
+	var total int64
+	sizes := make(map[string]int64)
+	var candidates []string
+	for image := range u {
+		s, err := size(image)
+		if err != nil {
+			// The images of the run may not have been pulled yet.
+			if !kept[image] {
+				delete(u, image)
+			}
+			continue
+		}
+		total += s
+		sizes[image] = s
+		if !kept[image] {
+			candidates = append(candidates, image)
+		}
+	}
+	sort.Sort(byLastUse{candidates, u})
+	var removed []string
+	for _, image := range candidates {
+		if total <= max {
+			break
+		}
+		if err := remove(image); err != nil {
+			glog.Infof("Could not remove %s from the image cache: %v", image, err)
+			continue
+		}
+		total -= sizes[image]
+		delete(u, image)
+		removed = append(removed, image)
+	}
+	return removed
+}
+
+// byLastUse sorts images by when they were last used, oldest first.
+type byLastUse struct {
+	images []string
+	use    ImageUse
+}
+
+func (b byLastUse) Len() int      { return len(b.images) }
+func (b byLastUse) Swap(i, j int) { b.images[i], b.images[j] = b.images[j], b.images[i] }
+func (b byLastUse) Less(i, j int) bool {
+	ti, tj := b.use[b.images[i]], b.use[b.images[j]]
+	if !ti.Equal(tj) {
+		return ti.Before(tj)
+	}
+	return b.images[i] < b.images[j]
+}
+
+// trimImageCache records that the run uses images, and then, if MaxImageCache is set,
+// removes the least recently used images of earlier runs until those that shipshape
+// has used take at most MaxImageCache bytes.
+func (i *Invocation) trimImageCache(images []string) {
+	if i.options.ImageUseFile == "" {
+		return
+	}
+	use, err := LoadImageUse(i.options.ImageUseFile)
+	if err != nil {
+		glog.Errorf("Could not read the record of image use, so starting it again: %v", err)
+		use = make(ImageUse)
+	}
+	now := time.Now().UTC()
+	for _, image := range images {
+		use[image] = now
+	}
+	if i.options.MaxImageCache > 0 {
+		removed := use.Evict(i.options.MaxImageCache, images, docker.ImageSize, func(image string) error {
+			if result := docker.RemoveImage(image); result.Err != nil {
+				return fmt.Errorf("%v: %s", result.Err, result.Stderr)
+			}
+			return nil
+		})
+		for _, image := range removed {
+			glog.Infof("Removed %s, which was not used recently, to keep the images under %s", image, FormatSize(i.options.MaxImageCache))
+		}
+	}
+	if err := use.Save(i.options.ImageUseFile); err != nil {
+		glog.Errorf("Could not record the images used: %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/shipshape/shipshape/util/docker"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"512", 512},
+		{"10K", 10 << 10},
+		{"512MB", 512 << 20},
+		{"20g", 20 << 30},
+		{"1T", 1 << 40},
+	}
+	for _, test := range tests {
+		if got, err := ParseSize(test.in); err != nil || got != test.want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", test.in, got, err, test.want)
+		}
+	}
+	for _, bad := range []string{"", "G", "1.5G", "-1", "10X"} {
+		if _, err := ParseSize(bad); err == nil {
+			t.Errorf("ParseSize(%q): expected an error", bad)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		100:               "100 B",
+		1536:              "1.5 KB",
+		3 << 30:           "3.0 GB",
+		(5 << 40) + 1<<39: "5.5 TB",
+	}
+	for in, want := range tests {
+		if got := FormatSize(in); got != want {
+			t.Errorf("FormatSize(%d) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPullSpace(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/example/linter/manifests/prod":
+			w.Header().Set("Docker-Content-Digest", "sha256:linter")
+			fmt.Fprint(w, `{"config": {"size": 100}, "layers": [{"size": 900}]}`)
+		case "/v2/example/scanner/manifests/prod":
+			w.Header().Set("Docker-Content-Digest", "sha256:scanner")
+			fmt.Fprint(w, `{"layers": [{"size": 5000}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	linter, scanner, missing := host+"/example/linter:prod", host+"/example/scanner:prod", host+"/example/missing:prod"
+
+	local := map[string]string{scanner: "sha256:scanner"}
+	needed, pulled := pullSpace([]string{linter, scanner, missing}, docker.Registry{Scheme: "http"}, func(img string) string { return local[img] })
+	if want := int64(1000 * pullFactor); needed != want {
+		t.Errorf("Wrong space needed: got %d, want %d", needed, want)
+	}
+	if want := []string{linter}; !reflect.DeepEqual(pulled, want) {
+		t.Errorf("Wrong images pulled: got %v, want %v", pulled, want)
+	}
+}
+
+func TestCheckPullSpace(t *testing.T) {
+	if err := checkPullSpace(1<<30, 2<<30, "/var/lib/docker", []string{"a"}); err != nil {
+		t.Errorf("Unexpected error with enough space: %v", err)
+	}
+	err := checkPullSpace(3<<30, 1<<30, "/var/lib/docker", []string{"a", "b"})
+	if err == nil {
+		t.Fatalf("Expected an error without enough space")
+	}
+	for _, want := range []string{"a, b", "3.0 GB", "1.0 GB", "/var/lib/docker", "--max_image_cache"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestImageUseEvict(t *testing.T) {
+	now := time.Now().UTC()
+	use := ImageUse{
+		"old":     now.Add(-3 * time.Hour),
+		"older":   now.Add(-5 * time.Hour),
+		"recent":  now.Add(-time.Hour),
+		"current": now,
+		"gone":    now.Add(-2 * time.Hour),
+		"busy":    now.Add(-6 * time.Hour),
+		"pulling": now,
+	}
+	sizes := map[string]int64{"old": 100, "older": 100, "recent": 100, "current": 100, "busy": 100}
+	size := func(image string) (int64, error) {
+		if s, ok := sizes[image]; ok {
+			return s, nil
+		}
+		return 0, errors.New("no such image")
+	}
+	remove := func(image string) error {
+		if image == "busy" {
+			return errors.New("in use")
+		}
+		return nil
+	}
+
+	removed := use.Evict(250, []string{"current", "pulling"}, size, remove)
+	if want := []string{"older", "old", "recent"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("Wrong images removed: got %v, want %v", removed, want)
+	}
+	var left []string
+	for image := range use {
+		left = append(left, image)
+	}
+	if len(left) != 3 || use["busy"].IsZero() || use["current"].IsZero() || use["pulling"].IsZero() {
+		t.Errorf("Wrong images left: got %v, want busy, current and pulling", left)
+	}
+}
+
+func TestImageUseSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imagecache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state", "images.json")
+	use, err := LoadImageUse(path)
+	if err != nil || len(use) != 0 {
+		t.Fatalf("LoadImageUse of a missing file = %v, %v; want an empty record", use, err)
+	}
+	when := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+	if err := (ImageUse{"gcr.io/example/linter:prod": when}).Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	use, err = LoadImageUse(path)
+	if err != nil {
+		t.Fatalf("LoadImageUse failed: %v", err)
+	}
+	if got := use["gcr.io/example/linter:prod"]; !got.Equal(when) {
+		t.Errorf("Wrong time of use: got %v, want %v", got, when)
+	}
+}
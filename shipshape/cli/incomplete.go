@@ -0,0 +1,67 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// MarkIncomplete records in resp whether its results are incomplete, and
+// which categories are affected. A category is incomplete if its analyzer
+// reported a failure, since an analyzer may fail after emitting some of its
+// notes. If streamErr is not nil, the stream of results ended early, and
+// all of the expected categories are incomplete. expected may be empty if
+// the categories that were run are not known.
+func MarkIncomplete(resp *rpcpb.ShipshapeResponse, expected []string, streamErr error) {
+	var reasons []string
+	cats := make(map[string]bool)
+	if streamErr != nil {
+		reasons = append(reasons, fmt.Sprintf("the results from the service ended early (%v)", streamErr))
+		for _, c := range expected {
+			cats[c] = true
+		}
+	}
+	var failed []string
+	seen := make(map[string]bool)
+	for _, analysis := range resp.AnalyzeResponse {
+		for _, f := range analysis.Failure {
+			if !seen[f.GetCategory()] {
+				failed = append(failed, f.GetCategory())
+				seen[f.GetCategory()] = true
+			}
+			cats[f.GetCategory()] = true
+		}
+	}
+	if len(failed) > 0 {
+		reasons = append(reasons, fmt.Sprintf("analyzers failed (%s)", strings.Join(failed, ", ")))
+	}
+	if len(reasons) == 0 {
+		resp.Incomplete = nil
+		return
+	}
+	resp.Incomplete = &rpcpb.IncompleteResults{Reason: proto.String(strings.Join(reasons, "; "))}
+	for c := range cats {
+		resp.Incomplete.Category = append(resp.Incomplete.Category, c)
+	}
+	sort.Strings(resp.Incomplete.Category)
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func TestMarkIncomplete(t *testing.T) {
+	failed := &rpcpb.AnalyzeResponse{
+		Note:    makeNotes("PyLint", 2),
+		Failure: []*rpcpb.AnalysisFailure{{Category: proto.String("PyLint"), FailureMessage: proto.String("killed")}},
+	}
+	tests := []struct {
+		label      string
+		responses  []*rpcpb.AnalyzeResponse
+		streamErr  error
+		reason     string
+		categories []string
+	}{
+		{
+			"complete",
+			[]*rpcpb.AnalyzeResponse{{Note: makeNotes("JSHint", 1)}},
+			nil,
+			"",
+			nil,
+		},
+		{
+			"analyzer crashed",
+			[]*rpcpb.AnalyzeResponse{{Note: makeNotes("JSHint", 1)}, failed},
+			nil,
+			"analyzers failed (PyLint)",
+			[]string{"PyLint"},
+		},
+		{
+			"stream ended early",
+			[]*rpcpb.AnalyzeResponse{failed},
+			errors.New("connection reset"),
+			"the results from the service ended early (connection reset); analyzers failed (PyLint)",
+			[]string{"JSHint", "PyLint", "go vet"},
+		},
+	}
+	for _, test := range tests {
+		resp := &rpcpb.ShipshapeResponse{AnalyzeResponse: test.responses}
+		MarkIncomplete(resp, []string{"go vet", "JSHint"}, test.streamErr)
+		if got := resp.Incomplete.GetReason(); got != test.reason {
+			t.Errorf("Wrong reason for %q: got %q, want %q", test.label, got, test.reason)
+		}
+		if got := resp.Incomplete.GetCategory(); !reflect.DeepEqual(got, test.categories) {
+			t.Errorf("Wrong categories for %q: got %v, want %v", test.label, got, test.categories)
+		}
+		if got := Summarize(resp, "", 0).Incomplete; got != test.reason {
+			t.Errorf("Wrong summary for %q: got %q, want %q", test.label, got, test.reason)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/google/shipshape/shipshape/api"
+	"github.com/google/shipshape/shipshape/service"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// LocalAnalyzer is an analyzer that runs in the process of the CLI, alongside the
+// analyzers in containers, so that a binary embedding the CLI library can ship
+// its own checks without an image for them.
+type LocalAnalyzer struct {
+	Analyzer api.Analyzer
+	// Stage is the stage that the analyzer runs at.
+	Stage ctxpb.Stage
+}
+
+// localAnalyzers returns the local analyzers of stage that analyze categories,
+// or all those of stage if the categories are not known.
+func (i *Invocation) localAnalyzers(stage ctxpb.Stage, categories []string) []api.Analyzer {
+	want := make(map[string]bool)
+	for _, c := range categories {
+		want[c] = true
+	}
+	var analyzers []api.Analyzer
+	for _, a := range i.options.LocalAnalyzers {
+		if a.Stage == stage && (len(categories) == 0 || want[a.Analyzer.Category()]) {
+			analyzers = append(analyzers, recoverPanics(a.Analyzer))
+		}
+	}
+	return analyzers
+}
+
+// serviceCategories returns the categories, less those of the local analyzers, for
+// the service to analyze, and whether any were left out.
+func (i *Invocation) serviceCategories(categories []string) ([]string, bool) {
+	local := make(map[string]bool)
+	for _, a := range i.options.LocalAnalyzers {
+		local[a.Analyzer.Category()] = true
+	}
+	var cats []string
+	for _, c := range categories {
+		if !local[c] {
+			cats = append(cats, c)
+		}
+	}
+	return cats, len(cats) < len(categories)
+}
+
+// runLocalAnalyzers runs the local analyzers of the stage of req that analyze
+// categories on files, relative to absRoot, or on all the files there if none
+// are given, and handles their results like those from the service. It returns
+// the number of notes they found.
+func (i *Invocation) runLocalAnalyzers(req *rpcpb.ShipshapeRequest, categories []string, absRoot string, files []string, origDir string) (int, error) {
+	analyzers := i.localAnalyzers(req.GetStage(), categories)
+	if len(analyzers) == 0 {
+		return 0, nil
+	}
+	files, err := service.FilesToAnalyze(absRoot, files)
+	if err != nil {
+		return 0, fmt.Errorf("could not list the files to analyze: %v", err)
+	}
+	// The context is that of the request to the service, but of the workspace
+	// as the CLI sees it.
+	ctx := proto.Clone(req.ShipshapeContext).(*ctxpb.ShipshapeContext)
+	ctx.RepoRoot = proto.String(absRoot)
+	ctx.FilePath = files
+	var cats []string
+	for _, a := range analyzers {
+		cats = append(cats, a.Category())
+	}
+	// The analyzer service runs the analyzers in the directory of the workspace,
+	// and changes back once they finish.
+	resp, _ := api.CreateAnalyzerService(analyzers, req.GetStage()).Analyze(nil, &rpcpb.AnalyzeRequest{ShipshapeContext: ctx, Category: cats})
+	resp.Stage = req.Stage
+	msg := &rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{resp}}
+	return len(resp.Note), i.handleResponse(msg, origDir)
+}
+
+// recoverPanics returns an analyzer that fails the analysis if a panics, rather
+// than letting it take down the CLI. It is a FileSelector if a is.
+func recoverPanics(a api.Analyzer) api.Analyzer {
+	if fs, ok := a.(api.FileSelector); ok {
+		return recoverSelector{recoverAnalyzer{a}, fs}
+	}
+	return recoverAnalyzer{a}
+}
+
+type recoverAnalyzer struct {
+	api.Analyzer
+}
+
+type recoverSelector struct {
+	recoverAnalyzer
+	api.FileSelector
+}
+
+func (a recoverAnalyzer) Analyze(ctx *ctxpb.ShipshapeContext) (notes []*notepb.Note, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("analyzer panicked: %v", r)
+		}
+	}()
+	return a.Analyzer.Analyze(ctx)
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// fileAnalyzer notes each of the files it is given, and processes the Go ones.
+type fileAnalyzer struct {
+	category string
+	panics   bool
+}
+
+func (a fileAnalyzer) Category() string { return a.category }
+
+func (a fileAnalyzer) Analyze(ctx *ctxpb.ShipshapeContext) ([]*notepb.Note, error) {
+	if a.panics {
+		panic("boom")
+	}
+	var notes []*notepb.Note
+	for _, f := range ctx.FilePath {
+		if _, err := os.Stat(f); err != nil {
+			return notes, err
+		}
+		notes = append(notes, &notepb.Note{Category: proto.String(a.category), Location: &notepb.Location{Path: proto.String(f)}})
+	}
+	return notes, nil
+}
+
+func (a fileAnalyzer) Processes(path string) bool { return strings.HasSuffix(path, ".go") }
+
+func TestRunLocalAnalyzers(t *testing.T) {
+	root, err := ioutil.TempDir("", "local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	writeFiles(t, root, map[string]string{"a.go": "package a", "b.py": "pass"})
+
+	var got []*rpcpb.ShipshapeResponse
+	i := New(Options{
+		LocalAnalyzers: []LocalAnalyzer{
+			{fileAnalyzer{category: "Files"}, ctxpb.Stage_PRE_BUILD},
+			{fileAnalyzer{category: "Panics", panics: true}, ctxpb.Stage_PRE_BUILD},
+			{fileAnalyzer{category: "Later"}, ctxpb.Stage_POST_BUILD},
+		},
+		HandleResponse: func(msg *rpcpb.ShipshapeResponse, directory string) error {
+			got = append(got, msg)
+			return nil
+		},
+	})
+	req := createRequest(nil, nil, "manual", "/shipshape-code", ctxpb.Stage_PRE_BUILD.Enum())
+	n, err := i.runLocalAnalyzers(req, []string{"Files", "Panics", "go vet"}, root, nil, root)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n != 2 || len(got) != 1 {
+		t.Fatalf("Wrong results: got %d notes in %v", n, got)
+	}
+	resp := got[0].AnalyzeResponse[0]
+	var paths []string
+	for _, note := range resp.Note {
+		paths = append(paths, note.GetLocation().GetPath())
+	}
+	if want := []string{"a.go", "b.py"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("Wrong notes: got %v, want %v", paths, want)
+	}
+	if len(resp.Failure) != 1 || resp.Failure[0].GetCategory() != "Panics" || !strings.Contains(resp.Failure[0].GetFailureMessage(), "boom") {
+		t.Errorf("Wrong failures: got %v, want the panic of Panics", resp.Failure)
+	}
+	if len(resp.Coverage) != 1 || !reflect.DeepEqual(resp.Coverage[0].File, []string{"a.go"}) {
+		t.Errorf("Wrong coverage: got %v, want a.go for Files", resp.Coverage)
+	}
+	if resp.GetStage() != ctxpb.Stage_PRE_BUILD {
+		t.Errorf("Wrong stage: got %v", resp.GetStage())
+	}
+
+	got = nil
+	if n, err := i.runLocalAnalyzers(req, []string{"go vet"}, root, nil, root); n != 0 || err != nil || got != nil {
+		t.Errorf("Ran analyzers of untriggered categories: got %d notes, %v, %v", n, err, got)
+	}
+}
+
+func TestServiceCategories(t *testing.T) {
+	i := New(Options{LocalAnalyzers: []LocalAnalyzer{{fileAnalyzer{category: "Files"}, ctxpb.Stage_PRE_BUILD}}})
+	tests := []struct {
+		categories []string
+		want       []string
+		local      bool
+	}{
+		{[]string{"go vet", "Files"}, []string{"go vet"}, true},
+		{[]string{"Files"}, nil, true},
+		{[]string{"go vet"}, []string{"go vet"}, false},
+		{nil, nil, false},
+	}
+	for _, test := range tests {
+		got, local := i.serviceCategories(test.categories)
+		if !reflect.DeepEqual(got, test.want) || local != test.local {
+			t.Errorf("serviceCategories(%v) = %v, %v; want %v, %v", test.categories, got, local, test.want, test.local)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+
+	textpb "github.com/google/shipshape/shipshape/proto/textrange_proto"
+)
+
+// FormatRange describes r for people, e.g. "Line 3, Col 5-9" or
+// "Line 3, Col 5 - Line 7, Col 2". It returns the empty string if r does not
+// have a start line, i.e. it refers to the entire file.
+func FormatRange(r *textpb.TextRange) string {
+	start, end := r.GetStartLine(), r.GetEndLine()
+	if start == 0 {
+		return ""
+	}
+	if end < start {
+		end = start
+	}
+	startCol, endCol := r.GetStartColumn(), r.GetEndColumn()
+	switch {
+	case startCol == 0 && end == start:
+		return fmt.Sprintf("Line %d", start)
+	case startCol == 0:
+		return fmt.Sprintf("Lines %d-%d", start, end)
+	case end == start && endCol > startCol:
+		return fmt.Sprintf("Line %d, Col %d-%d", start, startCol, endCol)
+	case end == start:
+		return fmt.Sprintf("Line %d, Col %d", start, startCol)
+	case endCol == 0:
+		return fmt.Sprintf("Line %d, Col %d - Line %d", start, startCol, end)
+	}
+	return fmt.Sprintf("Line %d, Col %d - Line %d, Col %d", start, startCol, end, endCol)
+}
+
+// formatLines is the line span of r, e.g. "3" or "3-7", or the empty string
+// if r has no start line.
+func formatLines(r *textpb.TextRange) string {
+	start, end := r.GetStartLine(), r.GetEndLine()
+	switch {
+	case start == 0:
+		return ""
+	case end > start:
+		return fmt.Sprintf("%d-%d", start, end)
+	}
+	return fmt.Sprint(start)
+}
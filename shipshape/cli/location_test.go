@@ -0,0 +1,66 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	textpb "github.com/google/shipshape/shipshape/proto/textrange_proto"
+)
+
+func TestFormatRange(t *testing.T) {
+	tests := []struct {
+		r    *textpb.TextRange
+		want string
+	}{
+		{nil, ""},
+		{&textpb.TextRange{}, ""},
+		{&textpb.TextRange{StartLine: proto.Int32(3)}, "Line 3"},
+		{&textpb.TextRange{StartLine: proto.Int32(3), EndLine: proto.Int32(7)}, "Lines 3-7"},
+		{&textpb.TextRange{StartLine: proto.Int32(3), StartColumn: proto.Int32(5)}, "Line 3, Col 5"},
+		{&textpb.TextRange{StartLine: proto.Int32(3), StartColumn: proto.Int32(5), EndColumn: proto.Int32(9)}, "Line 3, Col 5-9"},
+		{&textpb.TextRange{StartLine: proto.Int32(3), StartColumn: proto.Int32(5), EndLine: proto.Int32(7)}, "Line 3, Col 5 - Line 7"},
+		{
+			&textpb.TextRange{StartLine: proto.Int32(3), StartColumn: proto.Int32(5), EndLine: proto.Int32(7), EndColumn: proto.Int32(2)},
+			"Line 3, Col 5 - Line 7, Col 2",
+		},
+	}
+	for _, test := range tests {
+		if got := FormatRange(test.r); got != test.want {
+			t.Errorf("FormatRange(%v): got %q, want %q", test.r, got, test.want)
+		}
+	}
+}
+
+func TestFormatLines(t *testing.T) {
+	tests := []struct {
+		r    *textpb.TextRange
+		want string
+	}{
+		{nil, ""},
+		{&textpb.TextRange{StartLine: proto.Int32(3)}, "3"},
+		{&textpb.TextRange{StartLine: proto.Int32(3), EndLine: proto.Int32(3)}, "3"},
+		{&textpb.TextRange{StartLine: proto.Int32(3), EndLine: proto.Int32(7)}, "3-7"},
+	}
+	for _, test := range tests {
+		if got := formatLines(test.r); got != test.want {
+			t.Errorf("formatLines(%v): got %q, want %q", test.r, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/shipshape/shipshape/util/docker"
+
+	glog "github.com/google/shipshape/third_party/go-glog"
+)
+
+// LockFilename is the name of the lock file, which is kept next to the
+// .shipshape file.
+const LockFilename = ".shipshape.lock"
+
+// Lock records the digests that the images of a run resolved to, so that
+// later runs use exactly the same images until the lock is updated.
+type Lock struct {
+	// Images maps each image, named as in the flags or the config, to the
+	// digest it is pinned to.
+	Images  map[string]string `json:"images"`
+	Updated time.Time         `json:"updated"`
+}
+
+// LoadLock reads the lock file in dir, or returns nil if there is none.
+func LoadLock(dir string) (*Lock, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, LockFilename))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var l Lock
+	if err := json.Unmarshal(b, &l); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %v", filepath.Join(dir, LockFilename), err)
+	}
+	return &l, nil
+}
+
+// Save writes the lock file to dir.
+func (l *Lock) Save(dir string) error {
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, LockFilename), append(b, '\n'), 0644)
+}
+
+// Pin returns image pinned to its locked digest, and whether it is locked.
+// A nil lock pins nothing.
+func (l *Lock) Pin(image string) (string, bool) {
+	if l == nil || l.Images[image] == "" {
+		return image, false
+	}
+	return pinImage(image, l.Images[image]), true
+}
+
+// NewLock locks each of the images to the digest that its tag points at in its
+// registry. It fails if any of them could not be checked, rather than write
+// a lock that leaves some images unpinned.
+func NewLock(updates []ImageUpdate) (*Lock, error) {
+	l := &Lock{Images: make(map[string]string), Updated: time.Now().UTC()}
+	for _, u := range updates {
+		if u.Err != nil {
+			return nil, fmt.Errorf("could not resolve %s: %v", u.Image, u.Err)
+		}
+		l.Images[u.Image] = u.Remote
+	}
+	return l, nil
+}
+
+// pinImage replaces the tag of image, if it has one, with digest.
+func pinImage(image, digest string) string {
+	if strings.Contains(image, "@") {
+		return image
+	}
+	ref := docker.ParseImageRef(image)
+	if i := strings.LastIndex(image, ":"+ref.Tag); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image + "@" + digest
+}
+
+// pinImages replaces the third-party analyzers with the versions pinned in
+// the lock file in absRoot, if there is one, and moves their environments
+// along. It returns the service image to use.
+func (i *Invocation) pinImages(absRoot, serviceImage string, env map[string]map[string]string) (string, error) {
+	if i.options.IgnoreLock {
+		return serviceImage, nil
+	}
+	lock, err := LoadLock(absRoot)
+	if err != nil || lock == nil {
+		return serviceImage, err
+	}
+	i.lock = lock
+	var pinned []string
+	for _, analyzer := range i.options.ThirdPartyAnalyzers {
+		p := i.pin(analyzer)
+		if e, ok := env[analyzer]; ok {
+			delete(env, analyzer)
+			env[p] = e
+		}
+		pinned = append(pinned, p)
+	}
+	i.options.ThirdPartyAnalyzers = pinned
+	return i.pin(serviceImage), nil
+}
+
+// pin returns image pinned by the lock of the run, if it has one.
+func (i *Invocation) pin(image string) string {
+	if i.lock == nil {
+		return image
+	}
+	pinned, ok := i.lock.Pin(image)
+	if !ok {
+		glog.Infof("%s is not in %s, so using its latest version; run shipshape update to lock it", image, LockFilename)
+	}
+	return pinned
+}
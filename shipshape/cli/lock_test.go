@@ -0,0 +1,94 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestLockPinsPlan(t *testing.T) {
+	dir := writePlanWorkspace(t)
+	defer os.RemoveAll(dir)
+
+	lock, err := NewLock([]ImageUpdate{
+		{Image: "gcr.io/shipshape_releases/service:prod", Remote: "sha256:service"},
+		{Image: "gcr.io/example/linter:prod", Remote: "sha256:linter"},
+	})
+	if err != nil {
+		t.Fatalf("NewLock failed: %v", err)
+	}
+	if err := lock.Save(dir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := LoadLock(dir)
+	if err != nil {
+		t.Fatalf("LoadLock failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Images, lock.Images) {
+		t.Errorf("Wrong images loaded: got %v, want %v", loaded.Images, lock.Images)
+	}
+
+	options := Options{File: dir, Event: "manual", Repo: "gcr.io/shipshape_releases", Tag: "prod", Build: "maven"}
+	p, err := New(options).Plan()
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	want := []string{
+		"gcr.io/shipshape_releases/service@sha256:service",
+		"gcr.io/example/linter@sha256:linter",
+		// Not in the lock, so not pinned.
+		"gcr.io/shipshape_releases/kythe:prod",
+	}
+	if !reflect.DeepEqual(p.Pulls, want) {
+		t.Errorf("Wrong pulls: got %v, want %v", p.Pulls, want)
+	}
+	if c := p.Containers[0]; c.Name != "linter_0" || c.Image != want[1] || !reflect.DeepEqual(c.Env, []string{"LINT_LEVEL"}) {
+		t.Errorf("Wrong analyzer container: got %+v", c)
+	}
+
+	options.IgnoreLock = true
+	if p, err = New(options).Plan(); err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if got, want := p.Pulls[0], "gcr.io/shipshape_releases/service:prod"; got != want {
+		t.Errorf("Wrong service image when ignoring the lock: got %q, want %q", got, want)
+	}
+}
+
+func TestNewLockError(t *testing.T) {
+	if _, err := NewLock([]ImageUpdate{{Image: "gcr.io/example/private", Err: errors.New("denied")}}); err == nil {
+		t.Errorf("Expected an error for an image that could not be resolved")
+	}
+}
+
+func TestPinImage(t *testing.T) {
+	tests := []struct {
+		image, want string
+	}{
+		{"gcr.io/example/linter:prod", "gcr.io/example/linter@sha256:abc"},
+		{"localhost:5000/linter", "localhost:5000/linter@sha256:abc"},
+		{"gcr.io/example/linter@sha256:def", "gcr.io/example/linter@sha256:def"},
+	}
+	for _, test := range tests {
+		if got := pinImage(test.image, "sha256:abc"); got != test.want {
+			t.Errorf("pinImage(%q): got %q, want %q", test.image, got, test.want)
+		}
+	}
+}
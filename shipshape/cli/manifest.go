@@ -0,0 +1,286 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/google/shipshape/shipshape/util/docker"
+
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+)
+
+// ManifestEntry is a vetted third-party analyzer listed in a manifest.
+type ManifestEntry struct {
+	Name        string   `yaml:"name"`
+	Image       string   `yaml:"image"`
+	Digest      string   `yaml:"digest"`
+	Categories  []string `yaml:"categories"`
+	Description string   `yaml:"description"`
+}
+
+// Ref is the image to add to the config: the image pinned to its digest, if
+// the manifest has one.
+func (e ManifestEntry) Ref() string {
+	if e.Digest == "" {
+		return e.Image
+	}
+	return pinImage(e.Image, e.Digest)
+}
+
+// Manifest lists vetted third-party analyzers. It is a YAML (or JSON)
+// document with a list of analyzers, for example:
+//
+//	analyzers:
+//	  - name: android-lint
+//	    image: gcr.io/shipshape_releases/android_lint:prod
+//	    digest: sha256:...
+//	    categories: [AndroidLint]
+//	    description: Lint checks for Android projects
+type Manifest struct {
+	Analyzers []ManifestEntry `yaml:"analyzers"`
+}
+
+// manifestTimeout is how long the server of a manifest has to send it.
+var manifestTimeout = 30 * time.Second
+
+// LoadManifest reads the manifest at location, which is an http or https URL
+// or a local file. A URL may carry a token, so errors only name its host.
+func LoadManifest(location string) (*Manifest, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		data, err = fetch(location)
+		location = "on " + URLHost(location)
+	} else {
+		data, err = ioutil.ReadFile(strings.TrimPrefix(location, "file://"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read the analyzer manifest %s: %v", location, err)
+	}
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("could not parse the analyzer manifest %s: %v", location, err)
+	}
+	for i, e := range m.Analyzers {
+		if e.Name == "" || e.Image == "" {
+			return nil, fmt.Errorf("analyzer at index %d of the manifest %s must have a name and an image", i, location)
+		}
+	}
+	return &m, nil
+}
+
+func fetch(url string) ([]byte, error) {
+	client := http.Client{Timeout: manifestTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, unwrapURLError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Search returns the analyzers whose name, description or categories contain
+// query, ignoring case. An empty query matches all of them.
+func (m *Manifest) Search(query string) []ManifestEntry {
+	query = strings.ToLower(query)
+	var found []ManifestEntry
+	for _, e := range m.Analyzers {
+		text := strings.ToLower(strings.Join(append([]string{e.Name, e.Description}, e.Categories...), "\n"))
+		if strings.Contains(text, query) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// Find returns the analyzer called name, or nil if there is none.
+func (m *Manifest) Find(name string) *ManifestEntry {
+	for i, e := range m.Analyzers {
+		if e.Name == name {
+			return &m.Analyzers[i]
+		}
+	}
+	return nil
+}
+
+// AddAnalyzer adds the image of e to the global images in the .shipshape file
+// in dir, creating the file if there is none. An image from the same
+// repository that is already listed, such as one copied by hand, is replaced,
+// along with the environment that refers to it. The rest of the file,
+// including its comments, is left as it is.
+func AddAnalyzer(dir string, e ManifestEntry) error {
+	path := filepath.Join(dir, ".shipshape")
+	config, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	edited, err := addImage(config, e.Ref())
+	if err != nil {
+		return fmt.Errorf("could not add %s to %s: %v", e.Name, path, err)
+	}
+	return ioutil.WriteFile(path, edited, 0644)
+}
+
+// addImage edits the YAML config so that ref is in its global images.
+func addImage(config []byte, ref string) ([]byte, error) {
+	lines := strings.Split(string(config), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var replaced string
+	global := -1
+	for i, l := range lines {
+		if yamlKey(l) == "global" && indentation(l) == 0 {
+			global = i
+			break
+		}
+	}
+	if global < 0 {
+		lines = append(lines, "global:", "  images:", "    - "+ref)
+	} else {
+		// The keys of the global section are indented like its first line.
+		childIndent := "  "
+		first := nextContent(lines, global+1)
+		if first < len(lines) && indentation(lines[first]) > 0 {
+			childIndent = lines[first][:indentation(lines[first])]
+		}
+		end := len(lines)
+		for i := first; i < len(lines); i++ {
+			if !isBlank(lines[i]) && indentation(lines[i]) == 0 {
+				end = i
+				break
+			}
+		}
+		images := -1
+		for i := first; i < end; i++ {
+			if indentation(lines[i]) == len(childIndent) && yamlKey(lines[i]) == "images" {
+				images = i
+				break
+			}
+		}
+		if images < 0 {
+			lines = insert(lines, global+1, childIndent+"images:", childIndent+"  - "+ref)
+		} else {
+			if rest := strings.TrimSpace(stripComment(lines[images][strings.Index(lines[images], ":")+1:])); rest != "" {
+				return nil, fmt.Errorf("the images list must be a block list, not %q", rest)
+			}
+			last, prefix := images, childIndent+"  - "
+			for i := images + 1; i < end; i++ {
+				l := lines[i]
+				if isBlank(l) {
+					continue
+				}
+				trimmed := strings.TrimSpace(l)
+				if !strings.HasPrefix(trimmed, "- ") || indentation(l) < len(childIndent) {
+					break
+				}
+				last, prefix = i, l[:strings.Index(l, "-")+2]
+				value := unquote(strings.TrimSpace(stripComment(trimmed[2:])))
+				if value == ref {
+					return config, nil
+				}
+				if sameRepository(value, ref) {
+					replaced = value
+					lines[i] = prefix + ref
+				}
+			}
+			if replaced == "" {
+				lines = insert(lines, last+1, prefix+ref)
+			}
+		}
+	}
+	if replaced != "" {
+		for i, l := range lines {
+			if yamlKey(l) == "image" && unquote(strings.TrimSpace(stripComment(l[strings.Index(l, ":")+1:]))) == replaced {
+				lines[i] = l[:strings.Index(l, ":")+1] + " " + ref
+			}
+		}
+	}
+	edited := []byte(strings.Join(lines, "\n") + "\n")
+
+	var cfg configpb.ShipshapeConfig
+	if err := yaml.Unmarshal(edited, &cfg); err != nil {
+		return nil, fmt.Errorf("the edited config is not valid: %v", err)
+	}
+	for _, img := range cfg.GetGlobal().GetImages() {
+		if img == ref {
+			return edited, nil
+		}
+	}
+	return nil, fmt.Errorf("could not find where to add the image")
+}
+
+// sameRepository is whether images a and b are from the same repository,
+// whatever their tags or digests.
+func sameRepository(a, b string) bool {
+	ra, rb := docker.ParseImageRef(a), docker.ParseImageRef(b)
+	return ra.Registry == rb.Registry && ra.Repository == rb.Repository
+}
+
+// yamlKey returns the key of a line of the form `key: value` or `- key: value`.
+func yamlKey(line string) string {
+	trimmed := strings.TrimPrefix(strings.TrimSpace(line), "- ")
+	colon := strings.Index(trimmed, ":")
+	if colon < 0 || strings.HasPrefix(trimmed, "#") {
+		return ""
+	}
+	return trimmed[:colon]
+}
+
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func isBlank(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || strings.HasPrefix(trimmed, "#")
+}
+
+// nextContent returns the index of the first line from i on that is not
+// blank or a comment.
+func nextContent(lines []string, i int) int {
+	for ; i < len(lines) && isBlank(lines[i]); i++ {
+	}
+	return i
+}
+
+func stripComment(s string) string {
+	if i := strings.Index(s, " #"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func unquote(s string) string {
+	return strings.Trim(s, `"'`)
+}
+
+func insert(lines []string, i int, added ...string) []string {
+	return append(lines[:i], append(added, lines[i:]...)...)
+}
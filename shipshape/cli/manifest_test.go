@@ -0,0 +1,163 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testManifest = `
+analyzers:
+  - name: android-lint
+    image: gcr.io/shipshape_releases/android_lint:prod
+    digest: sha256:abc
+    categories: [AndroidLint]
+    description: Lint checks for Android projects
+  - name: scanner
+    image: gcr.io/example/scanner
+    categories: [Scanner]
+    description: A commercial security scanner
+`
+
+func TestLoadManifest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, testManifest)
+	}))
+	defer server.Close()
+
+	m, err := LoadManifest(server.URL)
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+	if got := m.Search("ANDROID"); len(got) != 1 || got[0].Name != "android-lint" {
+		t.Errorf("Wrong search results for android: got %v", got)
+	}
+	if got := m.Search("scanner"); len(got) != 1 || got[0].Name != "scanner" {
+		t.Errorf("Wrong search results for a category: got %v", got)
+	}
+	if got := m.Search(""); len(got) != 2 {
+		t.Errorf("Expected an empty query to match everything, got %v", got)
+	}
+	e := m.Find("android-lint")
+	if e == nil {
+		t.Fatalf("Could not find android-lint in %v", m.Analyzers)
+	}
+	if got, want := e.Ref(), "gcr.io/shipshape_releases/android_lint@sha256:abc"; got != want {
+		t.Errorf("Wrong image: got %q, want %q", got, want)
+	}
+	if got, want := m.Find("scanner").Ref(), "gcr.io/example/scanner"; got != want {
+		t.Errorf("Wrong image without a digest: got %q, want %q", got, want)
+	}
+	if m.Find("pylint") != nil {
+		t.Errorf("Found an analyzer that is not in the manifest")
+	}
+}
+
+func TestLoadManifestTimeout(t *testing.T) {
+	defer func(d time.Duration) { manifestTimeout = d }(manifestTimeout)
+	manifestTimeout = 100 * time.Millisecond
+	release := make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	_, err := LoadManifest(server.URL + "/manifest.yaml?token=hunter2")
+	if err == nil || strings.Contains(err.Error(), "hunter2") || !strings.Contains(err.Error(), strings.TrimPrefix(server.URL, "http://")) {
+		t.Errorf("Wrong error from a stalled manifest server: %v", err)
+	}
+}
+
+func TestLoadManifestInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "analyzers.yaml")
+	if err := ioutil.WriteFile(path, []byte("analyzers:\n  - name: no-image\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadManifest(path); err == nil {
+		t.Errorf("Expected an error for an analyzer without an image")
+	}
+}
+
+func TestAddImage(t *testing.T) {
+	const ref = "gcr.io/example/scanner@sha256:abc"
+	tests := []struct {
+		label  string
+		config string
+		want   string
+	}{
+		{
+			label:  "no config",
+			config: "",
+			want:   "global:\n  images:\n    - " + ref + "\n",
+		},
+		{
+			label:  "no global section",
+			config: "events:\n  - event: default\n    categories: [go vet]\n",
+			want:   "events:\n  - event: default\n    categories: [go vet]\nglobal:\n  images:\n    - " + ref + "\n",
+		},
+		{
+			label:  "no images",
+			config: "global:\n    ignore:\n      - third_party\nevents: []\n",
+			want:   "global:\n    images:\n      - " + ref + "\n    ignore:\n      - third_party\nevents: []\n",
+		},
+		{
+			label:  "other images",
+			config: "# Analyzers\nglobal:\n  images:\n  - gcr.io/example/other:prod  # keep\n  ignore: [third_party]\n",
+			want:   "# Analyzers\nglobal:\n  images:\n  - gcr.io/example/other:prod  # keep\n  - " + ref + "\n  ignore: [third_party]\n",
+		},
+		{
+			label: "hand-copied image",
+			config: "global:\n  images:\n    - \"gcr.io/example/scanner:prod\"\n  environment:\n" +
+				"    - image: gcr.io/example/scanner:prod\n      variables: []\n",
+			want: "global:\n  images:\n    - " + ref + "\n  environment:\n" +
+				"    - image: " + ref + "\n      variables: []\n",
+		},
+		{
+			label:  "already added",
+			config: "global:\n  images:\n    - " + ref,
+			want:   "global:\n  images:\n    - " + ref,
+		},
+	}
+	for _, test := range tests {
+		got, err := addImage([]byte(test.config), ref)
+		if err != nil {
+			t.Errorf("%s: addImage failed: %v", test.label, err)
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("%s: wrong config: got\n%s\nwant\n%s", test.label, got, test.want)
+		}
+	}
+
+	if _, err := addImage([]byte("global:\n  images: [gcr.io/example/other]\n"), ref); err == nil {
+		t.Errorf("Expected an error for a flow-style images list")
+	}
+}
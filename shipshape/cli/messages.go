@@ -0,0 +1,262 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MessageCatalog translates the messages that the CLI prints for people,
+// such as summaries, errors and prompts, into a locale. Messages are looked
+// up by their English format string, and printed in English if the catalog
+// has no translation for them. The words that scripts match on, such as
+// NOTE: and FAILED:, stay in English, and so does the text of the notes,
+// which comes from the analyzers.
+type MessageCatalog struct {
+	locale       string
+	translations map[string]string
+}
+
+// Messages is the catalog that the CLI prints its messages with. It is
+// English until the CLI sets it for the locale it runs in.
+var Messages = &MessageCatalog{locale: "en"}
+
+// english is a catalog without translations, for text that is shared rather
+// than read by the person who ran the CLI, such as issues.
+var english = &MessageCatalog{locale: "en"}
+
+// Locale returns the locale of the catalog, e.g. de_DE, or en if it is
+// English.
+func (c *MessageCatalog) Locale() string {
+	return c.locale
+}
+
+// Text returns the translation of msg, which is not a format string.
+func (c *MessageCatalog) Text(msg string) string {
+	if t, ok := c.translations[msg]; ok {
+		return t
+	}
+	return msg
+}
+
+// Sprintf formats the translation of format with a, as fmt.Sprintf does.
+func (c *MessageCatalog) Sprintf(format string, a ...interface{}) string {
+	if t, ok := c.translations[format]; ok {
+		format = t
+	}
+	return fmt.Sprintf(format, a...)
+}
+
+// Locale returns the locale to print messages in: flagValue if it is set,
+// and otherwise the first of $LC_ALL, $LC_MESSAGES and $LANG that is, as
+// read by getenv. The encoding and modifier are dropped, so de_DE.UTF-8
+// becomes de_DE. The C and POSIX locales are English.
+func Locale(flagValue string, getenv func(string) string) string {
+	locale := flagValue
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale != "" {
+			break
+		}
+		locale = getenv(env)
+	}
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	locale = strings.Replace(locale, "-", "_", -1)
+	if locale == "" || locale == "C" || locale == "POSIX" {
+		return "en"
+	}
+	return locale
+}
+
+// LoadMessages returns the catalog for locale. The built-in translations
+// for its language are overlaid with those in <dir>/messages/<language>.json
+// and <dir>/messages/<locale>.json, if they exist, so that teams can add
+// languages and wordings of their own. Each file is a JSON object from the
+// English format strings to their translations. If a file is malformed, the
+// error is returned along with the catalog without it.
+func LoadMessages(dir, locale string) (*MessageCatalog, error) {
+	c := &MessageCatalog{locale: locale, translations: make(map[string]string)}
+	names := []string{locale}
+	if i := strings.Index(locale, "_"); i >= 0 {
+		names = []string{locale[:i], locale}
+	}
+	for _, name := range names {
+		for k, v := range builtinTranslations[name] {
+			c.translations[k] = v
+		}
+	}
+	for _, name := range names {
+		b, err := ioutil.ReadFile(filepath.Join(dir, "messages", name+".json"))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return c, err
+		}
+		var translations map[string]string
+		if err := json.Unmarshal(b, &translations); err != nil {
+			return c, fmt.Errorf("could not parse the messages in %s: %v", filepath.Join(dir, "messages", name+".json"), err)
+		}
+		for k, v := range translations {
+			c.translations[k] = v
+		}
+	}
+	return c, nil
+}
+
+// builtinTranslations are the translations that ship with the CLI, by
+// language or locale.
+var builtinTranslations = map[string]map[string]string{
+	"de": {
+		"NOTE: Analyzer %s failed and was retried (%d attempts)\n":                                              "NOTE: Analyzer %s ist fehlgeschlagen und wurde wiederholt (%d Versuche)\n",
+		"NOTE: Analyzer %s stopped responding and was restarted: %s\n":                                          "NOTE: Analyzer %s hat nicht mehr geantwortet und wurde neu gestartet: %s\n",
+		"ERROR: Required analyzer %s failed to run: %s\n":                                                       "ERROR: Der erforderliche Analyzer %s konnte nicht laufen: %s\n",
+		"WARNING: Analyzer %s failed to run: %s\n":                                                              "WARNING: Analyzer %s konnte nicht laufen: %s\n",
+		"NOTE: %d of %d files were not processed by any category; see %s\n":                                     "NOTE: %d von %d Dateien wurden von keiner Kategorie verarbeitet; siehe %s\n",
+		"NOTE: %d files are in an unknown encoding, so their notes may be wrong; see %s\n":                      "NOTE: %d Dateien haben eine unbekannte Kodierung, daher können ihre Hinweise falsch sein; siehe %s\n",
+		"Error: unknown --docker_access %q (must be dind or socket)\n":                                          "Error: unbekannter Wert für --docker_access %q (erlaubt sind dind oder socket)\n",
+		"Error: unknown --format %q (must be text, csv or tsv)\n":                                               "Error: unbekannter Wert für --format %q (erlaubt sind text, csv oder tsv)\n",
+		"Error: unknown --open %q (must be %s)\n":                                                               "Error: unbekannter Wert für --open %q (erlaubt sind %s)\n",
+		"Error: unknown --fail_fast_severity %q (must be BUILD_ERROR, WARNING or OTHER)\n":                      "Error: unbekannter Wert für --fail_fast_severity %q (erlaubt sind BUILD_ERROR, WARNING oder OTHER)\n",
+		"Error: unknown tier %q (must be %s or %s)\n":                                                           "Error: unbekannte Stufe %q (erlaubt sind %s oder %s)\n",
+		"Error: --attestation requires --attestation_key\n":                                                     "Error: --attestation erfordert --attestation_key\n",
+		"Error: required analyzers failed to run: %s\n":                                                         "Error: erforderliche Analyzer konnten nicht laufen: %s\n",
+		"No categories in the %s tier to run\n":                                                                 "Keine Kategorien der Stufe %s auszuführen\n",
+		"NOTE: Left out %d duplicate notes\n":                                                                   "NOTE: %d doppelte Hinweise ausgelassen\n",
+		"NOTE: %d notes are marked as suppressed: %s\n":                                                         "NOTE: %d Hinweise sind als unterdrückt markiert: %s\n",
+		"NOTE: Left out %d suppressed notes: %s. Pass --show_suppressed to show them.\n":                        "NOTE: %d unterdrückte Hinweise ausgelassen: %s. Mit --show_suppressed werden sie angezeigt.\n",
+		"NOTE: By effort to fix: %s\n":                                                                          "NOTE: Nach Aufwand der Behebung: %s\n",
+		"NOTE: Truncated %d long descriptions; pass --full_descriptions to show them in full\n":                 "NOTE: %d lange Beschreibungen gekürzt; mit --full_descriptions werden sie vollständig angezeigt\n",
+		"WARNING: These results are incomplete: %s\n":                                                           "WARNING: Diese Ergebnisse sind unvollständig: %s\n",
+		"Categories that may be missing notes: %s\n":                                                            "Kategorien, denen Hinweise fehlen können: %s\n",
+		"NOTE: Nothing changed since the run at %s, so these are its results. Pass --force to analyze again.\n": "NOTE: Seit dem Lauf am %s hat sich nichts geändert, daher sind dies dessen Ergebnisse. Mit --force wird erneut analysiert.\n",
+		"WARNING: Could not open %s in %s: %v\n":                                                                "WARNING: %s konnte nicht in %s geöffnet werden: %v\n",
+		"FAILED: found a note at least as severe as %s (--fail_fast)\n":                                         "FAILED: Hinweis gefunden, der mindestens so schwer wiegt wie %s (--fail_fast)\n",
+		"%d in the baseline":                           "%d in der Baseline",
+		"%d suppressed inline":                         "%d im Code unterdrückt",
+		"%d suppressed by the overrides in .shipshape": "%d durch die Overrides in .shipshape unterdrückt",
+		"in the baseline":                              "in der Baseline",
+		"suppressed inline":                            "im Code unterdrückt",
+		"suppressed by the overrides in .shipshape":    "durch die Overrides in .shipshape unterdrückt",
+		"(f)ix, (b)aseline, (i)nline suppress, baseline (a)ll remaining, (q)uit? ": "(f) beheben, (b) in die Baseline, (i) im Code unterdrücken, (a) alle übrigen in die Baseline, (q) beenden? ",
+		"(f)ix, (b)aseline, baseline (a)ll remaining, (q)uit? ":                    "(f) beheben, (b) in die Baseline, (a) alle übrigen in die Baseline, (q) beenden? ",
+		"Apply this fix? (y)es, (n)o, (e)dit, apply (a)ll remaining, (q)uit? ":     "Diese Korrektur anwenden? (y) ja, (n) nein, (e) bearbeiten, (a) alle übrigen anwenden, (q) beenden? ",
+		"Apply this fix? (y)es, (n)o, apply (a)ll remaining, (q)uit? ":             "Diese Korrektur anwenden? (y) ja, (n) nein, (a) alle übrigen anwenden, (q) beenden? ",
+		"USAGE: shipshape [flags] <directory>\n":                                   "VERWENDUNG: shipshape [Flags] <Verzeichnis>\n",
+		"       shipshape run <pipeline> [flags] [<directory>]\n":                  "            shipshape run <Pipeline> [Flags] [<Verzeichnis>]\n",
+		"       shipshape <command> [flags]\n":                                     "            shipshape <Befehl> [Flags]\n",
+		"Commands: %s\n":                                                           "Befehle: %s\n",
+		"Shipshape flags: (for all flags, run shipshape -help)\n":                  "Shipshape-Flags: (alle Flags zeigt shipshape -help)\n",
+		"  -%s:\n\t %s (default: %s)\n":                                            "  -%s:\n\t %s (Standard: %s)\n",
+		"Built-in events:\n":                                                       "Eingebaute Ereignisse:\n",
+		"Custom events declared in %s:\n":                                          "In %s deklarierte eigene Ereignisse:\n",
+		"a run started by hand from the command line":                              "ein von Hand auf der Kommandozeile gestarteter Lauf",
+		"a run on a change before it is submitted":                                 "ein Lauf auf einer Änderung, bevor sie eingereicht wird",
+		"a run on a change after it is submitted":                                  "ein Lauf auf einer Änderung, nachdem sie eingereicht wurde",
+		"a run in a continuous integration build":                                  "ein Lauf in einem Build der Continuous Integration",
+		"a run on the files open in an editor":                                     "ein Lauf auf den in einem Editor geöffneten Dateien",
+		"Error: invalid --max_image_cache: %v\n":                                   "Error: ungültiger Wert für --max_image_cache: %v\n",
+		"Error: could not create --service_socket_dir: %v\n":                       "Error: --service_socket_dir konnte nicht angelegt werden: %v\n",
+		"policy %q violated (%d notes, %d allowed)":                                "Richtlinie %q verletzt (%d Hinweise, %d erlaubt)",
+		"found %d notes, more than the maximum of %d":                              "%d Hinweise gefunden, mehr als das Maximum von %d",
+		"found %d %s notes, more than the maximum of %d":                           "%d Hinweise für %s gefunden, mehr als das Maximum von %d",
+		"found %d %s notes, more than the %d recorded by the ratchet":              "%d Hinweise für %s gefunden, mehr als die %d in der Ratchet-Datei festgehaltenen",
+		"Global":                       "Ohne Datei",
+		"\tSee %s\n":                   "\tSiehe %s\n",
+		"\tThe %s analyzer is %s.\n":   "\tDer Analyzer %s: %s.\n",
+		"maintained by %s":             "betreut von %s",
+		"report false positives at %s": "Fehlalarme bitte an %s melden",
+		"Author: %s":                   "Autor: %s",
+		"Owners: %s":                   "Verantwortlich: %s",
+		"%s is not in a git work tree, so fixes will not be staged.\n": "%s ist nicht in einem Git-Arbeitsverzeichnis, daher werden die Korrekturen nicht vorgemerkt.\n",
+		"Could not fix %s: %s\n":              "%s konnte nicht korrigiert werden: %s\n",
+		"Fix %d of %d":                        "Korrektur %d von %d",
+		"Fix: %s\n":                           "Korrektur: %s\n",
+		"This fix cannot be applied: %v\n":    "Diese Korrektur kann nicht angewendet werden: %v\n",
+		"Could not stage the fix to %s: %v\n": "Die Korrektur von %s konnte nicht vorgemerkt werden: %v\n",
+		"USAGE: shipshape fix [--interactive] [--dir=<directory>] [--stage=false] <results.json>\n": "VERWENDUNG: shipshape fix [--interactive] [--dir=<Verzeichnis>] [--stage=false] <Ergebnisse.json>\n",
+		"\n%d fixes applied, %d rejected, %d could not be applied":                                  "\n%d Korrekturen angewendet, %d abgelehnt, %d konnten nicht angewendet werden",
+		", %d not decided on":      ", über %d nicht entschieden",
+		"Staged the fixes to %s\n": "Korrekturen an %s vorgemerkt\n",
+		"USAGE: shipshape triage --baseline=<path> [--dir=<directory>] <results.json>\n": "VERWENDUNG: shipshape triage --baseline=<Pfad> [--dir=<Verzeichnis>] <Ergebnisse.json>\n",
+		"Error: could not save the baseline: %v\n":                                       "Error: die Baseline konnte nicht gespeichert werden: %v\n",
+		"\n%d to fix, %d added to %s, %d suppressed inline":                              "\n%d zu beheben, %d zu %s hinzugefügt, %d im Code unterdrückt",
+		", %d not triaged":           ", %d nicht gesichtet",
+		"Waiting for %s to finish\n": "Warte, bis %s fertig ist\n",
+		"Interrupted, cancelling the analysis. Interrupt again to quit immediately.": "Unterbrochen, die Analyse wird abgebrochen. Erneut unterbrechen, um sofort zu beenden.",
+		"; %s %d/%d analyzers":                   "; %s %d/%d Analyzer",
+		"Analyzing [%s] %d/%d categories done%s": "Analyse [%s] %d/%d Kategorien fertig%s",
+		// The usage of the flags that shipshape -h lists.
+		"Full docker path to images of external analyzers to use (comma-separated)":                                                                     "Vollständiger Docker-Pfad der Images externer Analyzer (durch Kommas getrennt)",
+		"The name of the build system to use to generate compilation units. If empty, will not run the compilation step. Options are maven and go.":     "Das Build-System, mit dem die Kompilierungseinheiten erzeugt werden. Ist es leer, wird der Kompilierungsschritt nicht ausgeführt. Möglich sind maven und go.",
+		"Categories to trigger (comma-separated). If none are specified, will use the .shipshape configuration file to decide which categories to run.": "Die auszuführenden Kategorien (durch Kommas getrennt). Sind keine angegeben, entscheidet die Konfigurationsdatei .shipshape, welche Kategorien laufen.",
+		"True if the CLI is run from inside a docker container":                                                                                         "True, wenn die CLI in einem Docker-Container läuft",
+		"The name of the event to use":                                                                                                                                                                                                                                              "Der Name des zu verwendenden Ereignisses",
+		"When specified, log shipshape results to provided .json file":                                                                                                                                                                                                              "Wenn angegeben, werden die Ergebnisse von shipshape in diese .json-Datei geschrieben",
+		"The name of the docker repo to use":                                                                                                                                                                                                                                        "Der Name des zu verwendenden Docker-Repositorys",
+		"True if we should keep the container running, false if we should stop and remove it.":                                                                                                                                                                                      "True, wenn der Container weiterlaufen soll, false, wenn er gestoppt und entfernt werden soll.",
+		"Tag to use for the analysis service image. If this is local, we will not attempt to pull the image.":                                                                                                                                                                       "Das Tag des Images des Analysedienstes. Ist es local, wird nicht versucht, das Image zu laden.",
+		"True if we should not pull down the kythe image. This is used for testing a new kythe image.":                                                                                                                                                                              "True, wenn das Kythe-Image nicht geladen werden soll. Dient zum Testen eines neuen Kythe-Images.",
+		"When specified, post a summary of the results to this webhook (e.g. a Slack incoming webhook)":                                                                                                                                                                             "Wenn angegeben, wird eine Zusammenfassung der Ergebnisse an diesen Webhook gesendet (z. B. einen eingehenden Slack-Webhook)",
+		"When specified, upload the result files to this cloud storage location (gs://bucket/prefix or s3://bucket/prefix)":                                                                                                                                                         "Wenn angegeben, werden die Ergebnisdateien an diesen Speicherort in der Cloud hochgeladen (gs://bucket/prefix oder s3://bucket/prefix)",
+		"When specified, record the results of this run in the results history at this path. See shipshape trend.":                                                                                                                                                                  "Wenn angegeben, werden die Ergebnisse dieses Laufs im Ergebnisverlauf unter diesem Pfad festgehalten. Siehe shipshape trend.",
+		"When non-negative, fail if more than this many notes are found":                                                                                                                                                                                                            "Wenn nicht negativ, schlägt der Lauf fehl, wenn mehr Hinweise gefunden werden",
+		"Fail if more notes than the limit are found for a category (comma-separated category=limit pairs, e.g. PyLint=20,JSHint=0)":                                                                                                                                                "Der Lauf schlägt fehl, wenn für eine Kategorie mehr Hinweise als ihr Limit gefunden werden (durch Kommas getrennte Paare Kategorie=Limit, z. B. PyLint=20,JSHint=0)",
+		"When specified, fail if any category has more notes than recorded in this file, and record the new counts if none do. The file is created if it does not exist.":                                                                                                           "Wenn angegeben, schlägt der Lauf fehl, wenn eine Kategorie mehr Hinweise hat als in dieser Datei festgehalten, und sonst werden die neuen Anzahlen festgehalten. Die Datei wird angelegt, wenn es sie nicht gibt.",
+		"Annotate each note with the last author of its line (from git blame) and the owners of its file (from CODEOWNERS)":                                                                                                                                                         "Jeden Hinweis mit dem letzten Autor seiner Zeile (aus git blame) und den Verantwortlichen seiner Datei (aus CODEOWNERS) versehen",
+		"When non-zero, stop analyzing after this long (e.g. 5m). Categories are run in order of their priority in .shipshape, and those that do not finish are reported as failures.":                                                                                              "Wenn nicht null, wird die Analyse nach dieser Zeit beendet (z. B. 5m). Die Kategorien laufen in der Reihenfolge ihrer Priorität in .shipshape, und die nicht fertig gewordenen werden als Fehlschläge gemeldet.",
+		"When specified, only run the categories configured in .shipshape to be in this tier: fast or slow":                                                                                                                                                                         "Wenn angegeben, laufen nur die Kategorien, die in .shipshape dieser Stufe zugeordnet sind: fast oder slow",
+		"When positive, the most analyzers to run at the same time. Lower this if running all analyzers at once uses too much memory.":                                                                                                                                              "Wenn positiv, die Höchstzahl gleichzeitig laufender Analyzer. Verringern, wenn alle Analyzer gleichzeitig zu viel Speicher brauchen.",
+		"How the containers shipshape starts can use docker: 'dind' runs them privileged when --inside_docker is set, 'socket' mounts the host's docker socket into them instead":                                                                                                   "Wie die von shipshape gestarteten Container Docker nutzen können: 'dind' startet sie privilegiert, wenn --inside_docker gesetzt ist, 'socket' bindet stattdessen den Docker-Socket des Hosts in sie ein",
+		"The path of the analyzed directory on the docker host, if it differs from the path the CLI sees (e.g. when run in a container). Found from the container's mounts with --inside_docker --docker_access=socket.":                                                            "Der Pfad des analysierten Verzeichnisses auf dem Docker-Host, falls er sich von dem Pfad unterscheidet, den die CLI sieht (z. B. wenn sie in einem Container läuft). Wird mit --inside_docker --docker_access=socket aus den Mounts des Containers ermittelt.",
+		"When non-zero, give up if the service sends nothing for this long (e.g. 10m). The service sends heartbeats while analyzers are running, so this only detects a service that has hung.":                                                                                     "Wenn nicht null, wird aufgegeben, wenn der Dienst so lange nichts sendet (z. B. 10m). Der Dienst sendet Heartbeats, während Analyzer laufen, daher erkennt dies nur einen hängenden Dienst.",
+		"Resolve symlinks in the path to analyze, so that the analyzers see the directory it links to and note paths are relative to it":                                                                                                                                            "Symbolische Links im zu analysierenden Pfad auflösen, damit die Analyzer das Verzeichnis sehen, auf das er verweist, und die Pfade der Hinweise relativ dazu sind",
+		"Print which categories would run on which files and stages, and which images would be pulled and containers started, without touching docker":                                                                                                                              "Ausgeben, welche Kategorien auf welchen Dateien und Stufen laufen und welche Images geladen und Container gestartet würden, ohne Docker zu verwenden",
+		"When specified, write a JSON report to this file mapping each analyzed file to the categories that processed it, and listing the files that none did":                                                                                                                      "Wenn angegeben, wird ein JSON-Bericht in diese Datei geschrieben, der jeder analysierten Datei die Kategorien zuordnet, die sie verarbeitet haben, und die Dateien auflistet, die keine verarbeitet hat",
+		"When specified, leave out the notes recorded in this baseline file. See shipshape triage.":                                                                                                                                                                                 "Wenn angegeben, werden die in dieser Baseline-Datei festgehaltenen Hinweise ausgelassen. Siehe shipshape triage.",
+		"How to print the notes: text, or csv or tsv for loading into spreadsheets and BI tools, with columns path, start_line, start_col, category, subcategory, severity, description and fingerprint":                                                                            "Wie die Hinweise ausgegeben werden: text, oder csv oder tsv zum Laden in Tabellenkalkulationen und BI-Werkzeuge, mit den Spalten path, start_line, start_col, category, subcategory, severity, description und fingerprint",
+		"When specified, write a signed in-toto attestation of the run to this file, recording the images, config and categories used and a digest of the notes found. Requires --attestation_key.":                                                                                 "Wenn angegeben, wird eine signierte in-toto-Attestierung des Laufs in diese Datei geschrieben, mit den verwendeten Images, der Konfiguration und den Kategorien sowie einem Digest der gefundenen Hinweise. Erfordert --attestation_key.",
+		"PEM file with the ECDSA or RSA private key to sign the attestation with":                                                                                                                                                                                                   "PEM-Datei mit dem privaten ECDSA- oder RSA-Schlüssel, mit dem die Attestierung signiert wird",
+		"When specified, mount this directory into the service's container and talk to the service over a unix socket in it, instead of publishing port 10007 on the host":                                                                                                          "Wenn angegeben, wird dieses Verzeichnis in den Container des Dienstes eingebunden und über einen Unix-Socket darin mit dem Dienst gesprochen, statt Port 10007 auf dem Host freizugeben",
+		"When positive, analyze a directory in batches of this many files, each in its own request to the service, so that results arrive sooner and a crash only loses one batch":                                                                                                  "Wenn positiv, wird ein Verzeichnis in Stapeln von so vielen Dateien analysiert, jeder in einer eigenen Anfrage an den Dienst, damit Ergebnisse früher ankommen und ein Absturz nur einen Stapel kostet",
+		"Analyze even if nothing changed since the last run":                                                                                                                                                                                                                        "Auch analysieren, wenn sich seit dem letzten Lauf nichts geändert hat",
+		"Use the latest versions of the images even if they are pinned by a .shipshape.lock file":                                                                                                                                                                                   "Die neuesten Versionen der Images verwenden, auch wenn sie in einer Datei .shipshape.lock festgelegt sind",
+		"Show note descriptions in full in text output, however long they are":                                                                                                                                                                                                      "Die Beschreibungen der Hinweise in der Textausgabe vollständig anzeigen, egal wie lang sie sind",
+		"Run the built-in analyzers with the fixed configuration in the service image, ignoring any of theirs in the workspace (e.g. a pylintrc), so that the results only depend on the code and the images. Use with a .shipshape.lock file to also fix the images.":              "Die eingebauten Analyzer mit der festen Konfiguration aus dem Image des Dienstes ausführen und ihre Konfiguration im Arbeitsbereich (z. B. eine pylintrc) ignorieren, damit die Ergebnisse nur vom Code und den Images abhängen. Zusammen mit einer Datei .shipshape.lock werden auch die Images festgelegt.",
+		"When specified, write a diagnostics bundle for bug reports to this file after the run: the flags, the request to the service, docker inspect output and logs of the containers, and environment info":                                                                      "Wenn angegeben, wird nach dem Lauf ein Diagnosepaket für Fehlerberichte in diese Datei geschrieben: die Flags, die Anfrage an den Dienst, die Ausgabe von docker inspect und die Logs der Container sowie Angaben zur Umgebung",
+		"Stages to analyze, in order (comma-separated): PRE_BUILD, POST_BUILD, or the stages that analyzers register for beyond the build, DEPENDENCIES, SECURITY and DEPLOYMENT_CONFIG. If none are specified, PRE_BUILD is analyzed, followed by POST_BUILD if --build is given.": "Die zu analysierenden Stufen, in dieser Reihenfolge (durch Kommas getrennt): PRE_BUILD, POST_BUILD oder die Stufen nach dem Build, für die sich Analyzer registrieren, DEPENDENCIES, SECURITY und DEPLOYMENT_CONFIG. Sind keine angegeben, wird PRE_BUILD analysiert, gefolgt von POST_BUILD, wenn --build angegeben ist.",
+		"Stop the analysis and fail as soon as a note at least as severe as --fail_fast_severity is found, e.g. for pre-commit checks where any such note rejects the commit anyway. Suppressed and baselined notes do not count.":                                                  "Die Analyse beenden und fehlschlagen, sobald ein Hinweis gefunden wird, der mindestens so schwer wiegt wie --fail_fast_severity, z. B. für Pre-Commit-Prüfungen, bei denen jeder solche Hinweis den Commit ohnehin ablehnt. Unterdrückte Hinweise und Hinweise in der Baseline zählen nicht.",
+		"The least severe note that stops the analysis with --fail_fast: BUILD_ERROR, WARNING, or OTHER":                                                                                                                                                                            "Der am wenigsten schwere Hinweis, der die Analyse mit --fail_fast beendet: BUILD_ERROR, WARNING oder OTHER",
+		"Print only a single summary line, e.g. notes=12 failures=0 duration=43s, instead of the notes and messages about them; for scripts that only need the exit status and get the details from --json_output":                                                                  "Nur eine einzige Zusammenfassungszeile ausgeben, z. B. notes=12 failures=0 duration=43s, statt der Hinweise und der Meldungen dazu; für Skripte, die nur den Exit-Status brauchen und die Details aus --json_output holen",
+		"Run every third-party analyzer offline, with a read-only root filesystem and all capabilities dropped, on top of any sandbox configured for it in .shipshape. Sandboxed analyzers get no access to docker.":                                                                "Jeden Analyzer von Drittanbietern offline ausführen, mit schreibgeschütztem Root-Dateisystem und ohne Capabilities, zusätzlich zu einer in .shipshape für ihn konfigurierten Sandbox. Analyzer in der Sandbox haben keinen Zugriff auf Docker.",
+		"When specified, e.g. 20G, remove the least recently used images of earlier shipshape runs before pulling, until the images shipshape has used take at most this much disk space":                                                                                           "Wenn angegeben, z. B. 20G, werden vor dem Laden die am längsten nicht verwendeten Images früherer Läufe von shipshape entfernt, bis die von shipshape verwendeten Images höchstens so viel Speicherplatz belegen",
+		"Print what the third-party analyzer containers write to their stdout and stderr while they run, each line prefixed with the name of its container, e.g. to debug a custom analyzer that finds no notes. Turns off the progress bar.":                                       "Ausgeben, was die Container der Analyzer von Drittanbietern auf stdout und stderr schreiben, während sie laufen, jede Zeile mit dem Namen ihres Containers davor, z. B. um einen eigenen Analyzer zu untersuchen, der keine Hinweise findet. Schaltet den Fortschrittsbalken ab.",
+		"A directory to copy the compilation units that the POST_BUILD stage extracts from the build to, for other tools or later runs to use.":                                                                                                                                     "Ein Verzeichnis, in das die Kompilierungseinheiten kopiert werden, die die Stufe POST_BUILD aus dem Build gewinnt, damit andere Werkzeuge oder spätere Läufe sie verwenden können.",
+		"A directory of compilation units, e.g. from --export_compilations, for the POST_BUILD stage to analyze instead of extracting them from the build. Implies the POST_BUILD stage; --build is not needed.":                                                                    "Ein Verzeichnis mit Kompilierungseinheiten, z. B. aus --export_compilations, die die Stufe POST_BUILD analysiert, statt sie aus dem Build zu gewinnen. Schließt die Stufe POST_BUILD ein; --build ist nicht nötig.",
+		"How to print the paths of the notes, in text, csv and tsv: relative to the directory analyzed, absolute, or relative to the top of its git repository (repo-root). By default, joined to the directory as it was given":                                                    "Wie die Pfade der Hinweise in text, csv und tsv ausgegeben werden: relativ zum analysierten Verzeichnis, absolut oder relativ zur Wurzel seines Git-Repositorys (repo-root). Standardmäßig an das Verzeichnis angefügt, wie es angegeben wurde",
+		"Where to stage a copy of the workspace when some files to analyze are Latin-1, UTF-16 or UTF-8 with a byte order mark, so that the analyzers see them as plain UTF-8. Empty to analyze them as they are.":                                                                  "Wo eine Kopie des Arbeitsbereichs bereitgestellt wird, wenn zu analysierende Dateien in Latin-1, UTF-16 oder UTF-8 mit Byte Order Mark sind, damit die Analyzer sie als reines UTF-8 sehen. Leer, um sie so zu analysieren, wie sie sind.",
+		"Check that the JSON results match the schema that shipshape schema prints, failing the run if they do not, e.g. in CI for integrators to rely on it":                                                                                                                       "Prüfen, ob die JSON-Ergebnisse dem Schema entsprechen, das shipshape schema ausgibt, und den Lauf sonst fehlschlagen lassen, z. B. in der CI, damit Integratoren sich darauf verlassen können",
+		"The ID of the run, which is logged, labels the containers it starts, is sent to the service and is recorded in the results, so that they can be matched up, e.g. to the ID of a CI build. By default, a random UUID":                                                       "Die ID des Laufs, die protokolliert wird, die von ihm gestarteten Container kennzeichnet, an den Dienst gesendet und in den Ergebnissen festgehalten wird, damit sie zugeordnet werden können, z. B. der ID eines CI-Builds. Standardmäßig eine zufällige UUID",
+		"Include the notes that are in the baseline, suppressed inline or suppressed by an override in the text and JSON output, marked as suppressed. They do not count towards the exit status or the gates.":                                                                     "Die Hinweise in der Baseline, die im Code unterdrückten und die durch einen Override unterdrückten in die Text- und JSON-Ausgabe aufnehmen, als unterdrückt markiert. Sie zählen weder für den Exit-Status noch für die Prüfungen.",
+		"How long to wait for another run using the same docker daemon, on any directory, to finish before failing. By default, fail straight away, instead of both runs restarting the containers under each other.":                                                               "Wie lange gewartet wird, bis ein anderer Lauf mit demselben Docker-Daemon, auf welchem Verzeichnis auch immer, fertig ist, bevor der Lauf fehlschlägt. Standardmäßig schlägt er sofort fehl, statt dass beide Läufe die Container gegenseitig neu starten.",
+	},
+}
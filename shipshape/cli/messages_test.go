@@ -0,0 +1,119 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/shipshape/shipshape/service"
+)
+
+func TestLocale(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(name string) string { return vars[name] }
+	}
+	tests := []struct {
+		flagValue string
+		env       map[string]string
+		want      string
+	}{
+		{"", nil, "en"},
+		{"", map[string]string{"LANG": "de_DE.UTF-8"}, "de_DE"},
+		{"", map[string]string{"LANG": "de_DE.UTF-8", "LC_MESSAGES": "fr_FR"}, "fr_FR"},
+		{"", map[string]string{"LANG": "de_DE", "LC_ALL": "C"}, "en"},
+		{"pt-BR", map[string]string{"LANG": "de_DE"}, "pt_BR"},
+		{"", map[string]string{"LANG": "ca_ES@valencia"}, "ca_ES"},
+	}
+	for _, test := range tests {
+		if got := Locale(test.flagValue, env(test.env)); got != test.want {
+			t.Errorf("Locale(%q) with %v = %q, want %q", test.flagValue, test.env, got, test.want)
+		}
+	}
+}
+
+func TestLoadMessages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "messages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"messages/de_AT.json": `{"NOTE: Left out %d duplicate notes\n": "NOTE: %d doppelte Befunde weggelassen\n"}`,
+	})
+
+	c, err := LoadMessages(dir, "de_AT")
+	if err != nil {
+		t.Fatalf("LoadMessages failed: %v", err)
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		// From the file for the locale.
+		{"NOTE: Left out %d duplicate notes\n", "NOTE: 3 doppelte Befunde weggelassen\n"},
+		// Built in for the language.
+		{"NOTE: Truncated %d long descriptions; pass --full_descriptions to show them in full\n", "NOTE: 3 lange Beschreibungen gekürzt; mit --full_descriptions werden sie vollständig angezeigt\n"},
+		// Not translated.
+		{"%d new notes", "3 new notes"},
+	}
+	for _, test := range tests {
+		if got := c.Sprintf(test.format, 3); got != test.want {
+			t.Errorf("Sprintf(%q) = %q, want %q", test.format, got, test.want)
+		}
+	}
+
+	writeFiles(t, dir, map[string]string{"messages/fr.json": `["not", "an", "object"]`})
+	if _, err := LoadMessages(dir, "fr"); err == nil {
+		t.Errorf("LoadMessages succeeded with a malformed file")
+	}
+}
+
+var (
+	verbs    = regexp.MustCompile(`%[a-z]`)
+	prefixes = regexp.MustCompile(`^(NOTE|WARNING|ERROR|FAILED|Error): `)
+)
+
+// Translations must take the same arguments as the English messages, and
+// keep the words that scripts match on.
+func TestBuiltinTranslations(t *testing.T) {
+	for lang, translations := range builtinTranslations {
+		for format, translation := range translations {
+			if got, want := verbs.FindAllString(translation, -1), verbs.FindAllString(format, -1); !reflect.DeepEqual(got, want) {
+				t.Errorf("The %s translation of %q has verbs %v, want %v", lang, format, got, want)
+			}
+			if got, want := prefixes.FindString(translation), prefixes.FindString(format); got != want {
+				t.Errorf("The %s translation of %q starts with %q, want %q", lang, format, got, want)
+			}
+			if strings.HasSuffix(format, "\n") != strings.HasSuffix(translation, "\n") {
+				t.Errorf("The %s translation of %q does not end the line as it does", lang, format)
+			}
+		}
+	}
+}
+
+func TestBuiltinEventsTranslated(t *testing.T) {
+	for _, e := range service.BuiltInEvents {
+		if _, ok := builtinTranslations["de"][e.Description]; !ok {
+			t.Errorf("The description of event %s has no de translation", e.Name)
+		}
+	}
+}
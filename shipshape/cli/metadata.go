@@ -0,0 +1,222 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+	"github.com/google/shipshape/shipshape/util/redact"
+	glog "github.com/google/shipshape/third_party/go-glog"
+)
+
+// Version is the version of the shipshape CLI. Release builds set it with
+// -ldflags "-X github.com/google/shipshape/shipshape/cli.Version=...".
+var Version = "dev"
+
+// NewRunID returns a random version 4 UUID to identify a run by. If there is no
+// randomness to be had, it returns the empty string.
+func NewRunID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		glog.Errorf("Could not generate a run ID: %v", err)
+		return ""
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// RunMetadata describes the source and time of a shipshape run.
+type RunMetadata struct {
+	Commit    string    `json:"commit,omitempty"`
+	Branch    string    `json:"branch,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// GetRunMetadata collects metadata for a run on path, which may be a file or
+// a directory. If path is not in a git repository, Commit and Branch are
+// left empty.
+func GetRunMetadata(path string) RunMetadata {
+	dir := gitDir(path)
+	return RunMetadata{
+		Commit:    git(dir, "rev-parse", "HEAD"),
+		Branch:    branch(dir, os.Getenv),
+		Timestamp: time.Now().UTC(),
+	}
+}
+
+// NewRunInfo describes the run with ID runID on path with the command line args,
+// with any registered secrets redacted. The run started at started and has just
+// finished. images are the images the run
+// used, service first, as returned by Invocation.Images; digest looks up the
+// digest of an image, returning the empty string if it is unknown.
+func NewRunInfo(runID string, args []string, path string, images []string, digest func(string) string, started time.Time) *rpcpb.RunInfo {
+	info := &rpcpb.RunInfo{
+		CliVersion:           proto.String(Version),
+		CommandLine:          redact.Strings(args),
+		WorkspaceRoot:        proto.String(path),
+		StartTimestampMillis: proto.Int64(started.UnixNano() / int64(time.Millisecond)),
+		DurationMs:           proto.Int64(int64(time.Since(started) / time.Millisecond)),
+	}
+	if runID != "" {
+		info.RunId = proto.String(runID)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		info.WorkspaceRoot = proto.String(abs)
+	}
+	if commit := git(gitDir(path), "rev-parse", "HEAD"); commit != "" {
+		info.Commit = proto.String(commit)
+	}
+	for i, image := range images {
+		img := &rpcpb.ImageInfo{Name: proto.String(image)}
+		if d := digest(image); d != "" {
+			img.Digest = proto.String(d)
+		}
+		if i == 0 {
+			info.ServiceImage = img
+		} else {
+			info.Image = append(info.Image, img)
+		}
+	}
+	return info
+}
+
+// GetRevisionDetails describes the git revision that path is in, or returns
+// nil if path is not in a git repository.
+func GetRevisionDetails(path string) *ctxpb.RevisionDetails {
+	dir := gitDir(path)
+	commit := git(dir, "rev-parse", "HEAD")
+	if commit == "" {
+		return nil
+	}
+	details := &ctxpb.RevisionDetails{Commit: proto.String(commit)}
+	if b := branch(dir, os.Getenv); b != "" {
+		details.Branch = proto.String(b)
+	}
+	if remote := SanitizeRemote(git(dir, "config", "--get", "remote.origin.url")); remote != "" {
+		details.RemoteUrl = proto.String(remote)
+	}
+	return details
+}
+
+// GetCiDetails describes the continuous integration build that shipshape is
+// running in, or returns nil if it does not appear to be running in one.
+func GetCiDetails() *ctxpb.CiDetails {
+	return ciDetails(os.Getenv)
+}
+
+// ciDetails detects the CI system from the environment, as read by getenv.
+func ciDetails(getenv func(string) string) *ctxpb.CiDetails {
+	var system, id, link string
+	switch {
+	case getenv("GITHUB_ACTIONS") == "true":
+		system, id = "github", getenv("GITHUB_RUN_ID")
+		if server, repo := getenv("GITHUB_SERVER_URL"), getenv("GITHUB_REPOSITORY"); server != "" && repo != "" && id != "" {
+			link = server + "/" + repo + "/actions/runs/" + id
+		}
+	case getenv("TRAVIS") == "true":
+		system, id, link = "travis", getenv("TRAVIS_BUILD_ID"), getenv("TRAVIS_BUILD_WEB_URL")
+	case getenv("GITLAB_CI") != "":
+		system, id, link = "gitlab", getenv("CI_PIPELINE_ID"), getenv("CI_PIPELINE_URL")
+	case getenv("CIRCLECI") == "true":
+		system, id, link = "circleci", getenv("CIRCLE_BUILD_NUM"), getenv("CIRCLE_BUILD_URL")
+	case getenv("JENKINS_URL") != "":
+		system, id, link = "jenkins", getenv("BUILD_NUMBER"), getenv("BUILD_URL")
+	default:
+		return nil
+	}
+	details := &ctxpb.CiDetails{System: proto.String(system)}
+	if id != "" {
+		details.BuildId = proto.String(id)
+	}
+	if link != "" {
+		details.BuildUrl = proto.String(link)
+	}
+	return details
+}
+
+// branch returns the branch checked out in dir. CI systems usually check out
+// a detached HEAD, so in that case the branch is taken from the environment.
+func branch(dir string, getenv func(string) string) string {
+	if b := git(dir, "rev-parse", "--abbrev-ref", "HEAD"); b != "" && b != "HEAD" {
+		return b
+	}
+	for _, env := range []string{"GITHUB_HEAD_REF", "GITHUB_REF_NAME", "TRAVIS_BRANCH", "CI_COMMIT_REF_NAME", "CIRCLE_BRANCH", "GIT_BRANCH"} {
+		if b := getenv(env); b != "" {
+			return b
+		}
+	}
+	return ""
+}
+
+// SanitizeRemote strips any credentials, in the user or the query, from a git
+// remote URL so that it is safe to pass to analyzers and publish with the
+// results.
+func SanitizeRemote(remote string) string {
+	u, err := url.Parse(remote)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		// Not a URL; most likely scp-like syntax such as git@host:repo.git,
+		// which carries no password.
+		return remote
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+// WorkTreeDirty reports whether the git work tree that path is in has
+// uncommitted changes or untracked files, so that what was analyzed is not
+// just its commit. It also reports true if git status fails.
+func WorkTreeDirty(path string) bool {
+	cmd := exec.Command("git", "status", "--porcelain")
+	cmd.Dir = gitDir(path)
+	out, err := cmd.Output()
+	return err != nil || len(strings.TrimSpace(string(out))) > 0
+}
+
+// gitDir returns the directory to run git commands in for path, which may be
+// a file or a directory.
+func gitDir(path string) string {
+	if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+		return filepath.Dir(path)
+	}
+	return path
+}
+
+// git runs a git command in dir and returns its trimmed output, or the empty
+// string if the command failed.
+func git(dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// Editors are the editors that --open can open the location of a note in.
+var Editors = []string{"idea", "vim", "vscode"}
+
+// IsEditor returns whether name is one of Editors.
+func IsEditor(name string) bool {
+	for _, e := range Editors {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenCommand returns the command line that opens path at line and col in
+// editor, which must be one of Editors. Lines and columns start at 1; a col of
+// 0 opens the start of the line, and a line of 0 the start of the file.
+func OpenCommand(editor, path string, line, col int) []string {
+	switch editor {
+	case "vscode":
+		switch {
+		case line <= 0:
+			return []string{"code", path}
+		case col <= 0:
+			return []string{"code", "-g", fmt.Sprintf("%s:%d", path, line)}
+		}
+		return []string{"code", "-g", fmt.Sprintf("%s:%d:%d", path, line, col)}
+	case "idea":
+		args := []string{"idea"}
+		if line > 0 {
+			args = append(args, "--line", strconv.Itoa(line))
+			if col > 0 {
+				args = append(args, "--column", strconv.Itoa(col))
+			}
+		}
+		return append(args, path)
+	}
+	switch {
+	case line <= 0:
+		return []string{"vim", path}
+	case col <= 0:
+		return []string{"vim", fmt.Sprintf("+%d", line), path}
+	}
+	return []string{"vim", fmt.Sprintf("+call cursor(%d, %d)", line, col), path}
+}
+
+// FirstLocation returns the file of the first note in msg that is in one,
+// joined to the directory it was found in, and where in the file the note
+// starts. ok is false if none of the notes are in a file.
+func FirstLocation(msg *rpcpb.ShipshapeResponse, directory string) (path string, line, col int, ok bool) {
+	for _, analysis := range msg.AnalyzeResponse {
+		for _, note := range analysis.Note {
+			loc := note.GetLocation()
+			if loc.GetPath() == "" {
+				continue
+			}
+			r := loc.GetRange()
+			return filepath.Join(directory, loc.GetPath()), int(r.GetStartLine()), int(r.GetStartColumn()), true
+		}
+	}
+	return "", 0, 0, false
+}
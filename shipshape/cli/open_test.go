@@ -0,0 +1,68 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func TestOpenCommand(t *testing.T) {
+	tests := []struct {
+		editor    string
+		line, col int
+		want      []string
+	}{
+		{"vscode", 12, 3, []string{"code", "-g", "a.go:12:3"}},
+		{"vscode", 12, 0, []string{"code", "-g", "a.go:12"}},
+		{"vscode", 0, 0, []string{"code", "a.go"}},
+		{"idea", 12, 3, []string{"idea", "--line", "12", "--column", "3", "a.go"}},
+		{"idea", 12, 0, []string{"idea", "--line", "12", "a.go"}},
+		{"vim", 12, 3, []string{"vim", "+call cursor(12, 3)", "a.go"}},
+		{"vim", 12, 0, []string{"vim", "+12", "a.go"}},
+		{"vim", 0, 0, []string{"vim", "a.go"}},
+	}
+	for _, test := range tests {
+		if got := OpenCommand(test.editor, "a.go", test.line, test.col); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("OpenCommand(%q, %d, %d): got %q, want %q", test.editor, test.line, test.col, got, test.want)
+		}
+	}
+	if IsEditor("emacs") {
+		t.Errorf("IsEditor(\"emacs\") = true, want false")
+	}
+}
+
+func TestFirstLocation(t *testing.T) {
+	located := tableNote("go vet", "b.go", 7, notepb.Note_WARNING, "a note")
+	located.Location.Range.StartColumn = proto.Int32(4)
+	msg := &rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{
+		{Note: []*notepb.Note{{Category: proto.String("go vet"), Description: proto.String("a global note")}}},
+		{Note: []*notepb.Note{located, tableNote("go vet", "c.go", 1, notepb.Note_WARNING, "a note")}},
+	}}
+	path, line, col, ok := FirstLocation(msg, "/src")
+	if !ok || path != "/src/b.go" || line != 7 || col != 4 {
+		t.Errorf("FirstLocation() = %q, %d, %d, %v, want \"/src/b.go\", 7, 4, true", path, line, col, ok)
+	}
+	if _, _, _, ok := FirstLocation(&rpcpb.ShipshapeResponse{}, "/src"); ok {
+		t.Errorf("FirstLocation() found a location without notes")
+	}
+}
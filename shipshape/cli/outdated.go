@@ -0,0 +1,76 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"github.com/google/shipshape/shipshape/service"
+	"github.com/google/shipshape/shipshape/util/docker"
+)
+
+// ChangelogLabel is the image label whose value is a URL describing the
+// changes in each release of the image.
+const ChangelogLabel = "shipshape.changelog"
+
+// ImageUpdate compares the local copy of an image with its registry.
+type ImageUpdate struct {
+	Image string
+	// Local is the registry digest of the local image, or empty if the image
+	// was never pulled.
+	Local string
+	// Remote is the digest that the tag points at in the registry.
+	Remote    string
+	Changelog string
+	// Err is set if the registry could not be checked.
+	Err error
+}
+
+// Outdated is whether the registry has a different image than the local one.
+func (u ImageUpdate) Outdated() bool {
+	return u.Err == nil && u.Local != u.Remote
+}
+
+// Images lists the images that a run with options on dir would use: the
+// service and kythe images, and the third-party analyzers, which are read
+// from the configuration in dir if they are not given in options.
+func Images(options Options, dir string) []string {
+	analyzers := options.ThirdPartyAnalyzers
+	if len(analyzers) == 0 {
+		analyzers, _ = service.GlobalConfig(dir)
+	}
+	images := []string{
+		docker.FullImageName(options.Repo, image, options.Tag),
+		docker.FullImageName(options.Repo, kytheImage, options.Tag),
+	}
+	return append(images, analyzers...)
+}
+
+// CheckImages looks up each of the images in reg, without pulling them, and
+// compares them with the digests returned by localDigest.
+func CheckImages(images []string, reg docker.Registry, localDigest func(string) string) []ImageUpdate {
+	var updates []ImageUpdate
+	for _, img := range images {
+		u := ImageUpdate{Image: img, Local: localDigest(img)}
+		if remote, err := reg.Inspect(img); err != nil {
+			u.Err = err
+		} else {
+			u.Remote = remote.Digest
+			u.Changelog = remote.Labels[ChangelogLabel]
+		}
+		updates = append(updates, u)
+	}
+	return updates
+}
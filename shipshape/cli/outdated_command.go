@@ -0,0 +1,79 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/google/shipshape/shipshape/cli"
+	"github.com/google/shipshape/shipshape/util/docker"
+)
+
+// outdatedCommand implements `shipshape outdated`, which reports the images a
+// run would use that have newer versions in their registries, without
+// pulling them.
+func outdatedCommand(args []string) int {
+	fs := flag.NewFlagSet("outdated", flag.ExitOnError)
+	repo := fs.String("repo", cli.DefaultRepo, "The name of the docker repo to use")
+	tag := fs.String("tag", "prod", "Tag to use for the analysis service image")
+	analyzers := fs.String("analyzer_images", "", "Full docker path to images of external analyzers to check (comma-separated). If none are specified, the analyzers in the .shipshape configuration file of the directory are checked.")
+	fs.Usage = func() {
+		fmt.Println("USAGE: shipshape outdated [flags] [<directory>]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return returnError
+	}
+	dir := "."
+	if fs.NArg() == 1 {
+		dir = fs.Arg(0)
+	}
+
+	options := cli.Options{Repo: *repo, Tag: *tag}
+	if *analyzers != "" {
+		options.ThirdPartyAnalyzers = strings.Split(*analyzers, ",")
+	}
+	updates := cli.CheckImages(cli.Images(options, dir), docker.Registry{}, docker.LocalRepoDigest)
+
+	ret := returnNoFindings
+	for _, u := range updates {
+		switch {
+		case u.Err != nil:
+			fmt.Printf("%s: could not check: %v\n", u.Image, u.Err)
+			ret = returnError
+		case !u.Outdated():
+			fmt.Printf("%s: up to date\n", u.Image)
+		default:
+			if u.Local == "" {
+				fmt.Printf("%s: not pulled, latest is %s\n", u.Image, u.Remote)
+			} else {
+				fmt.Printf("%s: update available, %s -> %s\n", u.Image, u.Local, u.Remote)
+			}
+			if u.Changelog != "" {
+				fmt.Printf("  Changes: %s\n", u.Changelog)
+			}
+			if ret == returnNoFindings {
+				ret = returnFindings
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/shipshape/shipshape/util/docker"
+)
+
+func TestCheckImages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/shipshape/service/manifests/prod":
+			w.Header().Set("Docker-Content-Digest", "sha256:new")
+			fmt.Fprint(w, `{"config": {"digest": "sha256:config"}}`)
+		case "/v2/shipshape/service/blobs/sha256:config":
+			fmt.Fprintf(w, `{"config": {"Labels": {"%s": "https://example.com/changes"}}}`, ChangelogLabel)
+		case "/v2/shipshape/kythe/manifests/prod":
+			w.Header().Set("Docker-Content-Digest", "sha256:same")
+			fmt.Fprint(w, `{}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	local := map[string]string{
+		host + "/shipshape/service:prod": "sha256:old",
+		host + "/shipshape/kythe:prod":   "sha256:same",
+	}
+	images := Images(Options{Repo: host + "/shipshape", Tag: "prod", ThirdPartyAnalyzers: []string{host + "/missing:prod"}}, "")
+	updates := CheckImages(images, docker.Registry{Scheme: "http"}, func(img string) string { return local[img] })
+	if len(updates) != 3 {
+		t.Fatalf("Wrong number of images checked: got %v, want 3", updates)
+	}
+
+	if u := updates[0]; !u.Outdated() || u.Remote != "sha256:new" || u.Changelog != "https://example.com/changes" {
+		t.Errorf("Expected an update with a changelog for the service, got %+v", u)
+	}
+	if u := updates[1]; u.Outdated() || u.Err != nil {
+		t.Errorf("Expected kythe to be up to date, got %+v", u)
+	}
+	if u := updates[2]; u.Outdated() || u.Err == nil {
+		t.Errorf("Expected an error for an image that is not in the registry, got %+v", u)
+	}
+}
@@ -0,0 +1,233 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// JSONSchema is a JSON Schema (draft-07) document, or one of its subschemas.
+// Only the keywords that OutputSchema uses are supported.
+type JSONSchema struct {
+	Schema      string `json:"$schema,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Ref         string `json:"$ref,omitempty"`
+	Type        string `json:"type,omitempty"`
+	// Enum are the numbers of the values of a proto enum.
+	Enum       []int32                `json:"enum,omitempty"`
+	Properties map[string]*JSONSchema `json:"properties,omitempty"`
+	// AdditionalProperties is false for messages, whose fields are all known,
+	// and the schema of the values for maps.
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Definitions          map[string]*JSONSchema `json:"definitions,omitempty"`
+}
+
+// protoEnums map the enums in the results to the numbers of their values. The
+// proto package keeps those of the registered enums to itself.
+var protoEnums = map[reflect.Type]map[string]int32{
+	reflect.TypeOf(notepb.Note_Severity(0)):               notepb.Note_Severity_value,
+	reflect.TypeOf(notepb.Note_Effort(0)):                 notepb.Note_Effort_value,
+	reflect.TypeOf(ctxpb.Stage(0)):                        ctxpb.Stage_value,
+	reflect.TypeOf(ctxpb.ShipshapeContext_Environment(0)): ctxpb.ShipshapeContext_Environment_value,
+}
+
+// OutputSchema returns the schema of the results that --json_output writes,
+// in the CurrentSchema version. It is derived from the protos of the results,
+// so it changes with them.
+func OutputSchema() *JSONSchema {
+	defs := make(map[string]*JSONSchema)
+	root := typeSchema(reflect.TypeOf(rpcpb.ShipshapeResponse{}), defs)
+	return &JSONSchema{
+		Schema:      "http://json-schema.org/draft-07/schema#",
+		Title:       "Shipshape results",
+		Description: fmt.Sprintf("The results written by shipshape --json_output, schema version %s. Enums are written as their numbers.", CurrentSchema),
+		Ref:         root.Ref,
+		Definitions: defs,
+	}
+}
+
+// typeSchema returns the schema of the JSON encoding of values of t, adding the
+// schemas of messages to defs.
+func typeSchema(t reflect.Type, defs map[string]*JSONSchema) *JSONSchema {
+	if values, ok := protoEnums[t]; ok {
+		s := &JSONSchema{Type: "integer"}
+		for _, v := range values {
+			s.Enum = append(s.Enum, v)
+		}
+		sort.Sort(int32s(s.Enum))
+		return s
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem(), defs)
+	case reflect.Struct:
+		name := path.Base(t.PkgPath()) + "." + t.Name()
+		if _, ok := defs[name]; !ok {
+			s := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema), AdditionalProperties: false}
+			// Added before its fields, so that recursive messages end.
+			defs[name] = s
+			for i := 0; i < t.NumField(); i++ {
+				f := t.Field(i)
+				key := strings.Split(f.Tag.Get("json"), ",")[0]
+				if f.PkgPath != "" || key == "-" {
+					continue
+				}
+				if key == "" {
+					key = f.Name
+				}
+				s.Properties[key] = typeSchema(f.Type, defs)
+			}
+		}
+		return &JSONSchema{Ref: "#/definitions/" + name}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &JSONSchema{Type: "string", Description: "base64"}
+		}
+		return &JSONSchema{Type: "array", Items: typeSchema(t.Elem(), defs)}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: typeSchema(t.Elem(), defs)}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int32, reflect.Int64, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	}
+	// Anything else, e.g. a oneof, is not constrained.
+	return &JSONSchema{}
+}
+
+type int32s []int32
+
+func (s int32s) Len() int           { return len(s) }
+func (s int32s) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s int32s) Less(i, j int) bool { return s[i] < s[j] }
+
+// ValidateOutput checks that doc, a JSON document, matches OutputSchema. The
+// error describes the first mismatch and where it is, e.g.
+// $.analyze_response[0].note[2].severity.
+func ValidateOutput(doc []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(doc, &v); err != nil {
+		return fmt.Errorf("not valid JSON: %v", err)
+	}
+	schema := OutputSchema()
+	return schema.validate(schema, v, "$")
+}
+
+// validate checks that v, as decoded by encoding/json, matches s, a subschema of
+// root, at the path where.
+func (s *JSONSchema) validate(root *JSONSchema, v interface{}, where string) error {
+	if s.Ref != "" {
+		def, ok := root.Definitions[strings.TrimPrefix(s.Ref, "#/definitions/")]
+		if !ok {
+			return fmt.Errorf("%s: unknown $ref %s", where, s.Ref)
+		}
+		return def.validate(root, v, where)
+	}
+	switch s.Type {
+	case "":
+		return nil
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return typeError(where, v, s.Type)
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			prop, ok := s.Properties[k]
+			if !ok {
+				var isMap bool
+				if prop, isMap = s.AdditionalProperties.(*JSONSchema); !isMap {
+					return fmt.Errorf("%s: unknown property %q", where, k)
+				}
+			}
+			if err := prop.validate(root, obj[k], where+"."+k); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return typeError(where, v, s.Type)
+		}
+		for i, item := range arr {
+			if err := s.Items.validate(root, item, fmt.Sprintf("%s[%d]", where, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return typeError(where, v, s.Type)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return typeError(where, v, s.Type)
+		}
+	case "number", "integer":
+		n, ok := v.(float64)
+		if !ok || (s.Type == "integer" && n != math.Trunc(n)) {
+			return typeError(where, v, s.Type)
+		}
+		if len(s.Enum) == 0 {
+			return nil
+		}
+		for _, e := range s.Enum {
+			if float64(e) == n {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: %v is not one of %v", where, n, s.Enum)
+	}
+	return nil
+}
+
+// typeError describes a value v at where that is not of type want.
+func typeError(where string, v interface{}, want string) error {
+	got := "null"
+	switch v.(type) {
+	case map[string]interface{}:
+		got = "object"
+	case []interface{}:
+		got = "array"
+	case string:
+		got = "string"
+	case bool:
+		got = "boolean"
+	case float64:
+		got = "number"
+	}
+	return fmt.Errorf("%s: got %s, want %s", where, got, want)
+}
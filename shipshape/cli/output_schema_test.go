@@ -0,0 +1,94 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+	textpb "github.com/google/shipshape/shipshape/proto/textrange_proto"
+)
+
+func TestOutputSchema(t *testing.T) {
+	schema := OutputSchema()
+	if got, want := schema.Ref, "#/definitions/shipshape_rpc_proto.ShipshapeResponse"; got != want {
+		t.Errorf("Wrong root: got %s, want %s", got, want)
+	}
+	note, ok := schema.Definitions["note_proto.Note"]
+	if !ok {
+		t.Fatalf("No schema for notes in %v", schema.Definitions)
+	}
+	want := []int32{int32(notepb.Note_BUILD_ERROR), int32(notepb.Note_WARNING), int32(notepb.Note_OTHER)}
+	if got := note.Properties["severity"].Enum; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong severities: got %v, want %v", got, want)
+	}
+	if _, ok := note.Properties["XXX_unrecognized"]; ok {
+		t.Errorf("Internal proto field in the schema of notes")
+	}
+	if _, err := json.Marshal(schema); err != nil {
+		t.Errorf("Could not marshal the schema: %v", err)
+	}
+}
+
+func TestValidateOutput(t *testing.T) {
+	resp := &rpcpb.ShipshapeResponse{
+		AnalyzeResponse: []*rpcpb.AnalyzeResponse{{
+			Note: []*notepb.Note{{
+				Category:    proto.String("PyLint"),
+				Description: proto.String("A note"),
+				Severity:    notepb.Note_OTHER.Enum(),
+				Location: &notepb.Location{
+					Path:  proto.String("a.py"),
+					Range: &textpb.TextRange{StartLine: proto.Int32(3)},
+				},
+			}},
+			Failure: []*rpcpb.AnalysisFailure{{Category: proto.String("go vet"), FailureMessage: proto.String("boom")}},
+		}},
+		RunInfo:       &rpcpb.RunInfo{CliVersion: proto.String("dev"), DurationMs: proto.Int64(1200)},
+		SchemaVersion: proto.String(CurrentSchema),
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateOutput(b); err != nil {
+		t.Errorf("Results of the CLI do not match the schema: %v", err)
+	}
+
+	tests := []struct {
+		doc  string
+		want string
+	}{
+		{`{"analyze_response": [{"note": [{"severity": 7}]}]}`, "$.analyze_response[0].note[0].severity: 7 is not one of"},
+		{`{"analyze_response": [{"note": [{"category": 3}]}]}`, "$.analyze_response[0].note[0].category: got number, want string"},
+		{`{"analyze_response": {}}`, "$.analyze_response: got object, want array"},
+		{`{"run_info": {"duration_ms": 1.5}}`, "$.run_info.duration_ms: got number, want integer"},
+		{`{"notes": []}`, `$: unknown property "notes"`},
+		{`[`, "not valid JSON"},
+	}
+	for _, test := range tests {
+		if err := ValidateOutput([]byte(test.doc)); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("ValidateOutput(%s) = %v, want an error with %q", test.doc, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// Overrider changes the severities and categories of notes, or drops them,
+// as configured by the overrides in the .shipshape file.
+type Overrider struct {
+	// byCategory maps a category to the override for all of its notes, and
+	// bySubcategory maps a category and subcategory to the override for them.
+	byCategory    map[string]*configpb.CategoryOverride
+	bySubcategory map[[2]string]*configpb.CategoryOverride
+}
+
+// NewOverrider returns an Overrider for overrides. If more than one override
+// is for the same notes, the last one is used.
+func NewOverrider(overrides []*configpb.CategoryOverride) *Overrider {
+	o := &Overrider{
+		byCategory:    make(map[string]*configpb.CategoryOverride),
+		bySubcategory: make(map[[2]string]*configpb.CategoryOverride),
+	}
+	for _, override := range overrides {
+		if override.Subcategory != nil {
+			o.bySubcategory[[2]string{override.GetCategory(), override.GetSubcategory()}] = override
+		} else {
+			o.byCategory[override.GetCategory()] = override
+		}
+	}
+	return o
+}
+
+// Filter applies the overrides to the notes in resp, and returns how many
+// notes it dropped.
+func (o *Overrider) Filter(resp *rpcpb.ShipshapeResponse) int {
+	removed := 0
+	for _, analysis := range resp.AnalyzeResponse {
+		var kept []*notepb.Note
+		for _, note := range analysis.Note {
+			override := o.override(note)
+			if override == nil {
+				kept = append(kept, note)
+				continue
+			}
+			if override.GetSuppress() {
+				removed++
+				continue
+			}
+			if override.Severity != nil {
+				note.Severity = notepb.Note_Severity(notepb.Note_Severity_value[override.GetSeverity()]).Enum()
+			}
+			if override.Rename != nil {
+				note.Category = proto.String(override.GetRename())
+			}
+			kept = append(kept, note)
+		}
+		analysis.Note = kept
+	}
+	return removed
+}
+
+// Suppresses reports whether the overrides drop note.
+func (o *Overrider) Suppresses(note *notepb.Note) bool {
+	return o.override(note).GetSuppress()
+}
+
+// override returns the override for note, or nil if there is none.
+func (o *Overrider) override(note *notepb.Note) *configpb.CategoryOverride {
+	if override, ok := o.bySubcategory[[2]string{note.GetCategory(), note.GetSubcategory()}]; ok {
+		return override
+	}
+	return o.byCategory[note.GetCategory()]
+}
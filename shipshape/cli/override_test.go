@@ -0,0 +1,72 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func TestOverriderFilter(t *testing.T) {
+	o := NewOverrider([]*configpb.CategoryOverride{
+		{Category: proto.String("PyLint"), Severity: proto.String("WARNING")},
+		{Category: proto.String("PyLint"), Subcategory: proto.String("missing-docstring"), Severity: proto.String("OTHER")},
+		{Category: proto.String("PyLint"), Subcategory: proto.String("line-too-long"), Suppress: proto.Bool(true)},
+		{Category: proto.String("JSHint"), Rename: proto.String("JavaScript")},
+	})
+	note := func(cat, subcat string) *notepb.Note {
+		return &notepb.Note{Category: proto.String(cat), Subcategory: proto.String(subcat), Severity: notepb.Note_BUILD_ERROR.Enum()}
+	}
+	resp := &rpcpb.ShipshapeResponse{
+		AnalyzeResponse: []*rpcpb.AnalyzeResponse{{
+			Note: []*notepb.Note{
+				note("PyLint", "missing-docstring"),
+				note("PyLint", "line-too-long"),
+				note("PyLint", "unused-import"),
+				note("JSHint", "W033"),
+				note("go vet", "printf"),
+			},
+		}},
+	}
+
+	if got, want := o.Filter(resp), 1; got != want {
+		t.Errorf("Wrong number of notes dropped: got %d, want %d", got, want)
+	}
+	var got []string
+	for _, n := range resp.AnalyzeResponse[0].Note {
+		got = append(got, n.GetCategory()+":"+n.GetSubcategory()+" "+n.GetSeverity().String())
+	}
+	want := []string{
+		"PyLint:missing-docstring OTHER",
+		"PyLint:unused-import WARNING",
+		"JavaScript:W033 BUILD_ERROR",
+		"go vet:printf BUILD_ERROR",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Wrong notes: got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Wrong note %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// Ownership looks up the teams that maintain the analyzers of categories, as
+// configured by the owners in the .shipshape file, so that developers know
+// whom to ask about a note they think is a false positive.
+type Ownership struct {
+	byCategory map[string]*configpb.CategoryOwner
+}
+
+// NewOwnership returns an Ownership for owners. Results written by the CLI
+// carry the owners of their categories, so NewOwnership(resp.Owner) looks up
+// the owners of the categories in resp.
+func NewOwnership(owners []*configpb.CategoryOwner) *Ownership {
+	o := &Ownership{byCategory: make(map[string]*configpb.CategoryOwner)}
+	for _, owner := range owners {
+		o.byCategory[owner.GetCategory()] = owner
+	}
+	return o
+}
+
+// Owner returns the owner of category, or nil if it has none. A nil Ownership
+// has no owners.
+func (o *Ownership) Owner(category string) *configpb.CategoryOwner {
+	if o == nil {
+		return nil
+	}
+	return o.byCategory[category]
+}
+
+// Owners returns the owners of the categories that have notes or failures in
+// resp, sorted by category.
+func (o *Ownership) Owners(resp *rpcpb.ShipshapeResponse) []*configpb.CategoryOwner {
+	seen := make(map[string]bool)
+	var owners []*configpb.CategoryOwner
+	add := func(category string) {
+		if owner := o.Owner(category); owner != nil && !seen[category] {
+			seen[category] = true
+			owners = append(owners, owner)
+		}
+	}
+	for _, analysis := range resp.AnalyzeResponse {
+		for _, note := range analysis.Note {
+			add(note.GetCategory())
+		}
+		for _, failure := range analysis.Failure {
+			add(failure.GetCategory())
+		}
+	}
+	sort.Sort(byOwnedCategory(owners))
+	return owners
+}
+
+type byOwnedCategory []*configpb.CategoryOwner
+
+func (o byOwnedCategory) Len() int           { return len(o) }
+func (o byOwnedCategory) Swap(i, j int)      { o[i], o[j] = o[j], o[i] }
+func (o byOwnedCategory) Less(i, j int) bool { return o[i].GetCategory() < o[j].GetCategory() }
+
+// MaintainedBy describes owner for a reader of the results, e.g. "maintained
+// by java-infra (java-infra@example.com); report false positives at
+// https://issues.example.com/javalint". It is empty if owner is nil. It is
+// always in English, since it goes into summaries and issues that are shared.
+func MaintainedBy(owner *configpb.CategoryOwner) string {
+	return english.MaintainedBy(owner)
+}
+
+// MaintainedBy describes owner as the function MaintainedBy does, translated
+// by c.
+func (c *MessageCatalog) MaintainedBy(owner *configpb.CategoryOwner) string {
+	if owner == nil {
+		return ""
+	}
+	who := owner.GetTeam()
+	switch {
+	case who == "":
+		who = owner.GetContact()
+	case owner.GetContact() != "":
+		who = fmt.Sprintf("%s (%s)", who, owner.GetContact())
+	}
+	parts := []string{c.Sprintf("maintained by %s", who)}
+	if url := owner.GetUrl(); url != "" {
+		parts = append(parts, c.Sprintf("report false positives at %s", url))
+	}
+	return strings.Join(parts, "; ")
+}
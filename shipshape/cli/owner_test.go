@@ -0,0 +1,89 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func TestOwners(t *testing.T) {
+	o := NewOwnership([]*configpb.CategoryOwner{
+		{Category: proto.String("PyLint"), Team: proto.String("python-infra")},
+		{Category: proto.String("JSHint"), Team: proto.String("web")},
+		{Category: proto.String("go vet"), Team: proto.String("go-infra")},
+		{Category: proto.String("Unused"), Team: proto.String("nobody")},
+	})
+	resp := &rpcpb.ShipshapeResponse{
+		AnalyzeResponse: []*rpcpb.AnalyzeResponse{
+			{Note: append(makeNotes("PyLint", 2), makeNotes("JSHint", 1)...)},
+			{
+				Note:    makeNotes("AndroidLint", 1),
+				Failure: []*rpcpb.AnalysisFailure{{Category: proto.String("go vet"), FailureMessage: proto.String("boom")}},
+			},
+		},
+	}
+	var got []string
+	for _, owner := range o.Owners(resp) {
+		got = append(got, owner.GetCategory())
+	}
+	if want := "JSHint,PyLint,go vet"; strings.Join(got, ",") != want {
+		t.Errorf("Wrong owned categories: got %v, want %s", got, want)
+	}
+	if owner := o.Owner("AndroidLint"); owner != nil {
+		t.Errorf("Unowned category has owner %v", owner)
+	}
+}
+
+func TestMaintainedBy(t *testing.T) {
+	tests := []struct {
+		owner *configpb.CategoryOwner
+		want  string
+	}{
+		{nil, ""},
+		{&configpb.CategoryOwner{Team: proto.String("java-infra")}, "maintained by java-infra"},
+		{&configpb.CategoryOwner{Contact: proto.String("java-infra@")}, "maintained by java-infra@"},
+		{
+			&configpb.CategoryOwner{Team: proto.String("java-infra"), Contact: proto.String("java-infra@example.com"), Url: proto.String("https://issues.example.com/javalint")},
+			"maintained by java-infra (java-infra@example.com); report false positives at https://issues.example.com/javalint",
+		},
+	}
+	for _, test := range tests {
+		if got := MaintainedBy(test.owner); got != test.want {
+			t.Errorf("MaintainedBy(%v) = %q, want %q", test.owner, got, test.want)
+		}
+	}
+}
+
+func TestMaintainedByTranslated(t *testing.T) {
+	c := &MessageCatalog{locale: "de", translations: builtinTranslations["de"]}
+	owner := &configpb.CategoryOwner{Team: proto.String("java-infra"), Url: proto.String("https://issues.example.com/javalint")}
+	if got, want := c.MaintainedBy(owner), "betreut von java-infra; Fehlalarme bitte an https://issues.example.com/javalint melden"; got != want {
+		t.Errorf("MaintainedBy(%v) = %q, want %q", owner, got, want)
+	}
+	// Issues and summaries are shared, so they stay in English.
+	defer func(m *MessageCatalog) { Messages = m }(Messages)
+	Messages = c
+	if got, want := MaintainedBy(owner), "maintained by java-infra; report false positives at https://issues.example.com/javalint"; got != want {
+		t.Errorf("MaintainedBy(%v) = %q, want %q", owner, got, want)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// The ways that PathRenderer can show the paths of notes, for --paths.
+const (
+	// PathsRelative shows the paths relative to the directory analyzed.
+	PathsRelative = "relative"
+	// PathsAbsolute shows absolute paths.
+	PathsAbsolute = "absolute"
+	// PathsRepoRoot shows the paths relative to the top of the git repository
+	// that the directory analyzed is in, or to the directory if it is not in one.
+	PathsRepoRoot = "repo-root"
+)
+
+// PathRenderer shows the paths of notes, which are relative to the directory
+// analyzed, in one of the styles above, or if the style is empty, joined to the
+// directory as it was given.
+type PathRenderer struct {
+	style string
+	// prefixes caches each directory relative to the top of its git repository,
+	// with a trailing slash unless it is empty.
+	prefixes map[string]string
+}
+
+// NewPathRenderer returns a renderer for paths in style, which is one of the
+// Paths styles or empty.
+func NewPathRenderer(style string) (*PathRenderer, error) {
+	switch style {
+	case "", PathsRelative, PathsAbsolute, PathsRepoRoot:
+		return &PathRenderer{style: style, prefixes: make(map[string]string)}, nil
+	}
+	return nil, fmt.Errorf("unknown --paths %q (must be %s, %s or %s)", style, PathsRelative, PathsAbsolute, PathsRepoRoot)
+}
+
+// Render returns how to show path, the path of a note relative to directory.
+// Absolute paths, which are outside of the directory, are shown as they are.
+func (r *PathRenderer) Render(directory, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	switch r.style {
+	case PathsRelative:
+		return path
+	case PathsAbsolute:
+		if abs, err := filepath.Abs(filepath.Join(directory, path)); err == nil {
+			return abs
+		}
+	case PathsRepoRoot:
+		prefix, ok := r.prefixes[directory]
+		if !ok {
+			prefix = git(directory, "rev-parse", "--show-prefix")
+			r.prefixes[directory] = prefix
+		}
+		return prefix + path
+	}
+	return filepath.Join(directory, path)
+}
+
+// RenderNotes returns copies of notes, whose paths are relative to directory,
+// with their paths as r shows them. Unless the style is empty, for which the
+// notes are returned as they are.
+func (r *PathRenderer) RenderNotes(directory string, notes []*notepb.Note) []*notepb.Note {
+	if r.style == "" {
+		return notes
+	}
+	rendered := make([]*notepb.Note, len(notes))
+	for i, n := range notes {
+		n = proto.Clone(n).(*notepb.Note)
+		if loc := n.Location; loc != nil && loc.Path != nil {
+			loc.Path = proto.String(r.Render(directory, loc.GetPath()))
+		}
+		rendered[i] = n
+	}
+	return rendered
+}
+
+// canonicalNotePath returns path, the path of a note or fix from an analyzer,
+// as a clean path relative to root, the directory that was analyzed in the
+// container. Analyzers report paths relative to the root, but some report
+// absolute paths in the container, or paths such as ./src/../main.go. A
+// trailing "/", which marks a directory, is kept. Absolute paths outside of
+// root are returned as they are.
+func canonicalNotePath(path, root string) string {
+	if path == "" {
+		return path
+	}
+	isDir := strings.HasSuffix(path, "/")
+	if filepath.IsAbs(path) {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			return path
+		}
+		path = rel
+	}
+	path = filepath.Clean(path)
+	if isDir && path != "/" {
+		path += "/"
+	}
+	return path
+}
+
+// canonicalizePaths rewrites the paths of the notes and fixes in resp with
+// canonicalNotePath.
+func canonicalizePaths(resp *rpcpb.ShipshapeResponse, root string) {
+	for _, analysis := range resp.AnalyzeResponse {
+		for _, note := range analysis.Note {
+			if loc := note.Location; loc != nil && loc.Path != nil {
+				loc.Path = proto.String(canonicalNotePath(loc.GetPath(), root))
+			}
+			for _, fix := range note.Fix {
+				for _, r := range fix.Replacement {
+					if r.Path != nil {
+						r.Path = proto.String(canonicalNotePath(r.GetPath(), root))
+					}
+				}
+			}
+		}
+	}
+}
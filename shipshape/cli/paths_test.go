@@ -0,0 +1,97 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+)
+
+func TestCanonicalNotePath(t *testing.T) {
+	root := "/shipshape-workspace/src"
+	tests := []struct {
+		path, want string
+	}{
+		{"", ""},
+		{"main.go", "main.go"},
+		{"./pkg/../main.go", "main.go"},
+		{"pkg/", "pkg/"},
+		{"/shipshape-workspace/src/pkg/util.go", "pkg/util.go"},
+		{"/shipshape-workspace/src/pkg/", "pkg/"},
+		{"/shipshape-workspace/other/util.go", "/shipshape-workspace/other/util.go"},
+		{"/shipshape-workspace/src2/util.go", "/shipshape-workspace/src2/util.go"},
+	}
+	for _, test := range tests {
+		if got := canonicalNotePath(test.path, root); got != test.want {
+			t.Errorf("canonicalNotePath(%q, %q): got %q, want %q", test.path, root, got, test.want)
+		}
+	}
+}
+
+func TestPathRenderer(t *testing.T) {
+	repo, err := ioutil.TempDir("", "paths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(repo)
+	src := filepath.Join(repo, "src")
+	if err := os.Mkdir(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("git", "init", repo).CombinedOutput(); err != nil {
+		t.Skipf("Could not create a git repository: %v\n%s", err, out)
+	}
+
+	tests := []struct {
+		style, want string
+	}{
+		{"", filepath.Join(src, "pkg/main.go")},
+		{PathsRelative, "pkg/main.go"},
+		{PathsAbsolute, filepath.Join(src, "pkg/main.go")},
+		{PathsRepoRoot, "src/pkg/main.go"},
+	}
+	for _, test := range tests {
+		r, err := NewPathRenderer(test.style)
+		if err != nil {
+			t.Fatalf("NewPathRenderer(%q) failed: %v", test.style, err)
+		}
+		if got := r.Render(src, "pkg/main.go"); got != test.want {
+			t.Errorf("Render with %q: got %q, want %q", test.style, got, test.want)
+		}
+		if got := r.Render(src, "/elsewhere/main.go"); got != "/elsewhere/main.go" {
+			t.Errorf("Render with %q of an absolute path: got %q", test.style, got)
+		}
+	}
+
+	// Outside of a git repository, the paths are relative to the directory.
+	r, _ := NewPathRenderer(PathsRepoRoot)
+	notes := r.RenderNotes(os.TempDir(), []*notepb.Note{{Location: &notepb.Location{Path: proto.String("main.go")}}})
+	if got := notes[0].GetLocation().GetPath(); got != "main.go" {
+		t.Errorf("Wrong path outside of a repository: got %q, want main.go", got)
+	}
+
+	if _, err := NewPathRenderer("home"); err == nil {
+		t.Errorf("Expected an error for an unknown style")
+	}
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"strings"
+
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+)
+
+// PipelineFlags returns the values of the command line flags that give the
+// settings of the pipeline p, by flag name. Settings that p does not have
+// are left out, so that the flags keep their defaults.
+func PipelineFlags(p *configpb.Pipeline) map[string]string {
+	flags := make(map[string]string)
+	set := func(name, value string) {
+		if value != "" {
+			flags[name] = value
+		}
+	}
+	set("event", p.GetEvent())
+	set("categories", strings.Join(p.Categories, ","))
+	set("stages", strings.Join(p.Stages, ","))
+	set("build", p.GetBuild())
+	set("tier", p.GetTier())
+	out := p.GetOutputs()
+	set("format", out.GetFormat())
+	set("json_output", out.GetJson())
+	set("coverage_report", out.GetCoverage())
+	set("history_db", out.GetHistory())
+	set("upload", out.GetUpload())
+	set("webhook_url", out.GetWebhook())
+	return flags
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+)
+
+func TestPipelineFlags(t *testing.T) {
+	p := &configpb.Pipeline{
+		Name:       proto.String("nightly"),
+		Categories: []string{"ErrorProne", "go vet"},
+		Stages:     []string{"PRE_BUILD", "POST_BUILD"},
+		Build:      proto.String("maven"),
+		Outputs: &configpb.PipelineOutputs{
+			Json:    proto.String("nightly.json"),
+			History: proto.String("history.db"),
+		},
+	}
+	want := map[string]string{
+		"categories":  "ErrorProne,go vet",
+		"stages":      "PRE_BUILD,POST_BUILD",
+		"build":       "maven",
+		"json_output": "nightly.json",
+		"history_db":  "history.db",
+	}
+	if got := PipelineFlags(p); !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong flags: got %v, want %v", got, want)
+	}
+
+	if got := PipelineFlags(&configpb.Pipeline{Name: proto.String("empty")}); len(got) != 0 {
+		t.Errorf("Expected no flags for an empty pipeline, got %v", got)
+	}
+}
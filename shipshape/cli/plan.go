@@ -0,0 +1,224 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/shipshape/shipshape/service"
+	"github.com/google/shipshape/shipshape/util/docker"
+
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+)
+
+// PlannedContainer is a container that a run would start, or reuse if one
+// with the same settings is already running.
+type PlannedContainer struct {
+	Name  string
+	Image string
+	// Address is where the container serves, if it does.
+	Address string
+	// Env has the names, but not the values, of the environment variables
+	// set in the container.
+	Env []string
+	// Sandbox describes the restrictions the container runs with, if any.
+	Sandbox string
+}
+
+// Plan describes what a run would do, as far as can be told from the
+// options and the config without touching docker. Which analyzer provides
+// each category is only known once the analyzers are running.
+type Plan struct {
+	Directory string
+	// HostDirectory is the path of Directory on the docker host, if it is
+	// different. If DetectHostDirectory is set, it would be found from the
+	// mounts of the container that the CLI runs in.
+	HostDirectory       string
+	DetectHostDirectory bool
+	Event               string
+	Categories          []string
+	// CategoriesFromConfig is set if the categories were not given, but
+	// come from the config for Event.
+	CategoriesFromConfig bool
+	// Files are the files to analyze, relative to Directory.
+	Files  []string
+	Stages []ctxpb.Stage
+	// Pulls are the images that would be pulled if they are out of date.
+	Pulls      []string
+	Containers []PlannedContainer
+	// LocalAnalyzers are the categories of the analyzers that would run in the
+	// process of the CLI, in the order of the stages they run at.
+	LocalAnalyzers []string
+	// StopAfter is set if the containers would be stopped after the run.
+	StopAfter bool
+}
+
+// Plan resolves the options and the config like Run, and returns what
+// Run would do.
+func (i *Invocation) Plan() (*Plan, error) {
+	fs, _, absRoot, err := i.target()
+	if err != nil {
+		return nil, err
+	}
+	if err := service.ValidateEvent(absRoot, i.options.Event); err != nil {
+		return nil, err
+	}
+	if err := i.configureBuild(absRoot); err != nil {
+		return nil, err
+	}
+	if err := i.checkStages(); err != nil {
+		return nil, err
+	}
+	analyzerEnv, err := i.resolveAnalyzers(absRoot)
+	if err != nil {
+		return nil, err
+	}
+	p := &Plan{
+		Directory:  absRoot,
+		Event:      i.options.Event,
+		Categories: i.options.TriggerCats,
+		Stages:     i.stages(),
+		StopAfter:  !i.options.StayUp,
+	}
+	if i.options.HostWorkspaceRoot != "" {
+		if p.HostDirectory, err = filepath.Abs(i.options.HostWorkspaceRoot); err != nil {
+			return nil, err
+		}
+	} else {
+		p.DetectHostDirectory = i.options.Dind && i.options.DockerSocket
+	}
+
+	if len(p.Categories) == 0 {
+		if p.Categories, err = service.EventCategories(absRoot, i.options.Event); err != nil {
+			return nil, err
+		}
+		if len(p.Categories) == 0 {
+			return nil, fmt.Errorf("no categories to run: pass --categories or configure the event %s in %s", i.options.Event, filepath.Join(absRoot, ".shipshape"))
+		}
+		p.CategoriesFromConfig = true
+	}
+
+	var files []string
+	if !fs.IsDir() {
+		files = []string{filepath.Base(i.options.File)}
+	}
+	if p.Files, err = service.FilesToAnalyze(absRoot, files); err != nil {
+		return nil, err
+	}
+
+	serviceImage, err := i.pinImages(absRoot, docker.FullImageName(i.options.Repo, image, i.options.Tag), analyzerEnv)
+	if err != nil {
+		return nil, err
+	}
+	if i.options.Tag != "local" {
+		p.Pulls = append(append(p.Pulls, serviceImage), i.options.ThirdPartyAnalyzers...)
+	}
+	sandboxes := i.resolveSandboxes(absRoot)
+	for id, analyzer := range i.options.ThirdPartyAnalyzers {
+		container, port := getContainerAndAddress(analyzer, id)
+		var names []string
+		for name := range analyzerEnv[analyzer] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		sb := sandboxes[analyzer]
+		address := fmt.Sprintf("localhost:%d", port)
+		if sb.Offline {
+			address = ""
+		}
+		p.Containers = append(p.Containers, PlannedContainer{Name: container, Image: analyzer, Address: address, Env: names, Sandbox: sb.String()})
+	}
+	address := serviceAddress()
+	if i.options.SocketDir != "" {
+		address = "unix:" + filepath.Join(i.options.SocketDir, docker.ServiceSocket)
+	}
+	p.Containers = append(p.Containers, PlannedContainer{Name: "shipping_container", Image: serviceImage, Address: address})
+
+	for _, stage := range i.stages() {
+		for _, a := range i.localAnalyzers(stage, p.Categories) {
+			p.LocalAnalyzers = append(p.LocalAnalyzers, a.Category())
+		}
+	}
+
+	if i.extracts() {
+		kythe := docker.FullImageName(i.options.Repo, kytheImage, i.options.Tag)
+		if !i.options.LocalKythe {
+			kythe = i.pin(kythe)
+			p.Pulls = append(p.Pulls, kythe)
+		}
+		p.Containers = append(p.Containers, PlannedContainer{Name: "kythe", Image: kythe}, PlannedContainer{Name: "kythe-index", Image: kythe})
+	}
+	return p, nil
+}
+
+// FormatPlan renders p for the terminal.
+func FormatPlan(p *Plan) string {
+	var b bytes.Buffer
+	fmt.Fprintln(&b, "Dry run: no images are pulled and no containers are started.")
+	fmt.Fprintf(&b, "Directory: %s\n", p.Directory)
+	switch {
+	case p.HostDirectory != "":
+		fmt.Fprintf(&b, "Directory on the docker host: %s\n", p.HostDirectory)
+	case p.DetectHostDirectory:
+		fmt.Fprintln(&b, "Directory on the docker host: found from the mounts of this container")
+	}
+	from := "given"
+	if p.CategoriesFromConfig {
+		from = "from the config for event " + p.Event
+	}
+	fmt.Fprintf(&b, "Categories (%s): %s\n", from, strings.Join(p.Categories, ", "))
+	var stages []string
+	for _, s := range p.Stages {
+		stages = append(stages, s.String())
+	}
+	fmt.Fprintf(&b, "Stages: %s\n", strings.Join(stages, ", "))
+	fmt.Fprintf(&b, "Files (%d):\n", len(p.Files))
+	for _, f := range p.Files {
+		fmt.Fprintf(&b, "  %s\n", f)
+	}
+	if len(p.Pulls) > 0 {
+		fmt.Fprintln(&b, "Images to pull if out of date:")
+		for _, image := range p.Pulls {
+			fmt.Fprintf(&b, "  %s\n", image)
+		}
+	}
+	fmt.Fprintln(&b, "Containers to start, or reuse if already running with the same settings:")
+	for _, c := range p.Containers {
+		fmt.Fprintf(&b, "  %s (%s)", c.Name, c.Image)
+		if c.Address != "" {
+			fmt.Fprintf(&b, " at %s", c.Address)
+		}
+		if len(c.Env) > 0 {
+			fmt.Fprintf(&b, " with %s set", strings.Join(c.Env, ", "))
+		}
+		if c.Sandbox != "" {
+			fmt.Fprintf(&b, " sandboxed (%s)", c.Sandbox)
+		}
+		fmt.Fprintln(&b)
+	}
+	if len(p.LocalAnalyzers) > 0 {
+		fmt.Fprintf(&b, "Analyzers run in this process: %s\n", strings.Join(p.LocalAnalyzers, ", "))
+	}
+	if p.StopAfter {
+		fmt.Fprintln(&b, "The containers are stopped after the run.")
+	}
+	return b.String()
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+)
+
+const planConfig = `
+global:
+  images:
+    - gcr.io/example/linter:prod
+  ignore:
+    - vendor/
+  environment:
+    - image: gcr.io/example/linter:prod
+      variables:
+        - name: LINT_LEVEL
+          value: strict
+events:
+  - event: manual
+    categories:
+      - go vet
+      - Linter
+`
+
+func writePlanWorkspace(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "plan_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		".shipshape":     planConfig,
+		"main.go":        "package main",
+		"lib/lib.go":     "package lib",
+		"vendor/dep.go":  "package dep",
+		".git/HEAD":      "ref: refs/heads/master",
+		"lib/lib_doc.md": "# lib",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestPlan(t *testing.T) {
+	dir := writePlanWorkspace(t)
+	defer os.RemoveAll(dir)
+
+	p, err := New(Options{File: dir, Event: "manual", Repo: "gcr.io/shipshape_releases", Tag: "prod", Build: "maven"}).Plan()
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if got, want := p.Categories, []string{"go vet", "Linter"}; !reflect.DeepEqual(got, want) || !p.CategoriesFromConfig {
+		t.Errorf("Wrong categories: got %v (from config %v), want %v from the config", got, p.CategoriesFromConfig, want)
+	}
+	if got, want := p.Files, []string{"lib/lib.go", "lib/lib_doc.md", "main.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong files: got %v, want %v", got, want)
+	}
+	if got, want := p.Stages, []ctxpb.Stage{ctxpb.Stage_PRE_BUILD, ctxpb.Stage_POST_BUILD}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong stages: got %v, want %v", got, want)
+	}
+	wantPulls := []string{"gcr.io/shipshape_releases/service:prod", "gcr.io/example/linter:prod", "gcr.io/shipshape_releases/kythe:prod"}
+	if !reflect.DeepEqual(p.Pulls, wantPulls) {
+		t.Errorf("Wrong pulls: got %v, want %v", p.Pulls, wantPulls)
+	}
+	wantContainers := []PlannedContainer{
+		{Name: "linter_0", Image: "gcr.io/example/linter:prod", Address: "localhost:10010", Env: []string{"LINT_LEVEL"}},
+		{Name: "shipping_container", Image: "gcr.io/shipshape_releases/service:prod", Address: "localhost:10007"},
+		{Name: "kythe", Image: "gcr.io/shipshape_releases/kythe:prod"},
+		{Name: "kythe-index", Image: "gcr.io/shipshape_releases/kythe:prod"},
+	}
+	if !reflect.DeepEqual(p.Containers, wantContainers) {
+		t.Errorf("Wrong containers: got %v, want %v", p.Containers, wantContainers)
+	}
+
+	out := FormatPlan(p)
+	for _, want := range []string{"Categories (from the config for event manual): go vet, Linter", "Files (3):", "linter_0 (gcr.io/example/linter:prod) at localhost:10010 with LINT_LEVEL set"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Plan output is missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "strict") {
+		t.Errorf("Plan output shows an environment value:\n%s", out)
+	}
+}
+
+func TestPlanGivenFile(t *testing.T) {
+	dir := writePlanWorkspace(t)
+	defer os.RemoveAll(dir)
+
+	p, err := New(Options{File: filepath.Join(dir, "main.go"), TriggerCats: []string{"PyLint"}, Event: "manual", Tag: "local", ThirdPartyAnalyzers: []string{"my/analyzer"}}).Plan()
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if got, want := p.Categories, []string{"PyLint"}; !reflect.DeepEqual(got, want) || p.CategoriesFromConfig {
+		t.Errorf("Wrong categories: got %v (from config %v), want %v as given", got, p.CategoriesFromConfig, want)
+	}
+	if got, want := p.Files, []string{"main.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong files: got %v, want %v", got, want)
+	}
+	if len(p.Pulls) != 0 {
+		t.Errorf("Local images would be pulled: %v", p.Pulls)
+	}
+	if got, want := len(p.Containers), 2; got != want {
+		t.Errorf("Wrong number of containers: got %d, want %d", got, want)
+	}
+}
+
+func TestPlanNoCategories(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plan_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if _, err := New(Options{File: dir, Event: "manual"}).Plan(); err == nil {
+		t.Errorf("Expected an error with no categories and no config")
+	}
+}
+
+func TestPlanUnknownEvent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plan_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	_, err = New(Options{File: dir, Event: "nightly", TriggerCats: []string{"go vet"}}).Plan()
+	if err == nil || !strings.Contains(err.Error(), `unknown event "nightly"`) {
+		t.Errorf("Wrong error for an undeclared event: got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"github.com/google/shipshape/shipshape/service"
+	strset "github.com/google/shipshape/shipshape/util/strings"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+)
+
+// PolicyViolation is a policy that selected more notes than it allows.
+type PolicyViolation struct {
+	Policy *configpb.Policy
+	// Notes are all the notes the policy selected.
+	Notes []*notepb.Note
+}
+
+// EvaluatePolicies checks the notes against each of the policies, and
+// returns the policies that were violated.
+func EvaluatePolicies(policies []*configpb.Policy, notes []*notepb.Note) []PolicyViolation {
+	var violations []PolicyViolation
+	for _, p := range policies {
+		var selected []*notepb.Note
+		for _, note := range notes {
+			if policySelects(p, note) {
+				selected = append(selected, note)
+			}
+		}
+		if int32(len(selected)) > p.GetAllowed() {
+			violations = append(violations, PolicyViolation{p, selected})
+		}
+	}
+	return violations
+}
+
+// policySelects reports whether the policy applies to the note.
+func policySelects(p *configpb.Policy, note *notepb.Note) bool {
+	if len(p.Categories) > 0 && !strset.Contains(p.Categories, note.GetCategory()) {
+		return false
+	}
+	if p.Severity != nil && note.GetSeverity().String() != p.GetSeverity() {
+		return false
+	}
+	path := note.GetLocation().GetPath()
+	if len(p.Paths) > 0 && !MatchesAnyPath(p.Paths, path) {
+		return false
+	}
+	return !MatchesAnyPath(p.Exclude, path)
+}
+
+// MatchesAnyPath reports whether path matches one of the patterns, which use
+// the syntax of the paths of a policy; see service.MatchesAnyPath.
+func MatchesAnyPath(patterns []string, path string) bool {
+	return service.MatchesAnyPath(patterns, path)
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+)
+
+func TestMatchesAnyPath(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		path     string
+		expect   bool
+	}{
+		{[]string{"*.java"}, "src/main/Foo.java", true},
+		{[]string{"*Test.java"}, "src/test/FooTest.java", true},
+		{[]string{"*Test.java"}, "src/main/Foo.java", false},
+		{[]string{"src/main/*"}, "src/main/Foo.java", true},
+		{[]string{"src/main/*"}, "src/main/foo/Foo.java", false},
+		{[]string{"src/"}, "src/main/foo/Foo.java", true},
+		{[]string{"src/"}, "srcs/Foo.java", false},
+		{[]string{"test/", "*.py"}, "lib/a.py", true},
+		{[]string{"//java/..."}, "java/com/Foo.java", true},
+		{[]string{"//java/..."}, "javascript/a.js", false},
+		{[]string{"//..."}, "lib/a.py", true},
+		{nil, "lib/a.py", false},
+		{[]string{"*"}, "", false},
+	}
+	for _, test := range tests {
+		if got := MatchesAnyPath(test.patterns, test.path); got != test.expect {
+			t.Errorf("MatchesAnyPath(%v, %q): got %v, want %v", test.patterns, test.path, got, test.expect)
+		}
+	}
+}
+
+func TestEvaluatePolicies(t *testing.T) {
+	errorNote := func(category, path string) *notepb.Note {
+		n := createNote(category, path, 1, "Broken")
+		n.Severity = notepb.Note_BUILD_ERROR.Enum()
+		return n
+	}
+	notes := []*notepb.Note{
+		errorNote("ErrorProne", "src/main/Foo.java"),
+		errorNote("ErrorProne", "src/test/FooTest.java"),
+		createNote("ErrorProne", "src/main/Bar.java", 3, "Deprecated"),
+		createNote("PyLint", "a.py", 3, "Unused import os"),
+		createNote("PyLint", "b.py", 3, "Unused import os"),
+	}
+
+	tests := []struct {
+		desc     string
+		policy   *configpb.Policy
+		violated bool
+		selected int
+	}{
+		{
+			"errors in non-test files",
+			&configpb.Policy{
+				Categories: []string{"ErrorProne"},
+				Severity:   proto.String("BUILD_ERROR"),
+				Exclude:    []string{"*Test.java"},
+			},
+			true, 1,
+		},
+		{
+			"errors in test files only",
+			&configpb.Policy{
+				Severity: proto.String("BUILD_ERROR"),
+				Paths:    []string{"src/test/"},
+				Allowed:  proto.Int32(1),
+			},
+			false, 1,
+		},
+		{
+			"warnings anywhere",
+			&configpb.Policy{
+				Severity: proto.String("WARNING"),
+				Allowed:  proto.Int32(2),
+			},
+			true, 3,
+		},
+		{
+			"category with no notes",
+			&configpb.Policy{Categories: []string{"JSHint"}},
+			false, 0,
+		},
+	}
+	for _, test := range tests {
+		violations := EvaluatePolicies([]*configpb.Policy{test.policy}, notes)
+		if got := len(violations) > 0; got != test.violated {
+			t.Errorf("%s: violated = %v, want %v", test.desc, got, test.violated)
+		}
+		if len(violations) > 0 && len(violations[0].Notes) != test.selected {
+			t.Errorf("%s: selected %d notes, want %d", test.desc, len(violations[0].Notes), test.selected)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	// firstAnalyzerPort and lastAnalyzerPort bound the host ports that the
+	// containers of third-party analyzers are published on.
+	firstAnalyzerPort = 10010
+	lastAnalyzerPort  = 19999
+)
+
+// portAllocator hands out the host ports that containers are published on,
+// within a range. A port is handed out at most once, and never while some
+// process, such as a container started by another run, is listening on it.
+// It is safe for concurrent use.
+type portAllocator struct {
+	first, last int
+	inUse       func(int) bool
+
+	mu    sync.Mutex
+	taken map[int]bool
+}
+
+// newPortAllocator returns a portAllocator for the ports from first to last,
+// which uses inUse to check whether another process is listening on a port.
+func newPortAllocator(first, last int, inUse func(int) bool) *portAllocator {
+	return &portAllocator{first: first, last: last, inUse: inUse, taken: make(map[int]bool)}
+}
+
+// allocate returns preferred if it is in range and free, and otherwise the
+// next free port in range after it, wrapping around to the first.
+func (a *portAllocator) allocate(preferred int) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if preferred < a.first || preferred > a.last {
+		preferred = a.first
+	}
+	n := a.last - a.first + 1
+	for i := 0; i < n; i++ {
+		port := a.first + (preferred-a.first+i)%n
+		if a.taken[port] || a.inUse(port) {
+			continue
+		}
+		a.taken[port] = true
+		return port, nil
+	}
+	return 0, fmt.Errorf("all the ports from %d to %d are in use", a.first, a.last)
+}
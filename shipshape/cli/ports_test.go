@@ -0,0 +1,56 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import "testing"
+
+func TestPortAllocator(t *testing.T) {
+	busy := map[int]bool{10011: true}
+	a := newPortAllocator(10010, 10013, func(port int) bool { return busy[port] })
+	tests := []struct {
+		preferred int
+		want      int
+	}{
+		{10010, 10010},
+		// Handed out already.
+		{10010, 10012},
+		// Another process listens on it.
+		{10011, 10013},
+		// Out of range, and the others are taken.
+		{20000, -1},
+	}
+	for _, test := range tests {
+		got, err := a.allocate(test.preferred)
+		if test.want < 0 {
+			if err == nil {
+				t.Errorf("allocate(%d) = %d, want an error since all ports are taken", test.preferred, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("allocate(%d) = %d, %v, want %d", test.preferred, got, err, test.want)
+		}
+	}
+}
+
+func TestPortAllocatorWraps(t *testing.T) {
+	a := newPortAllocator(10010, 10012, func(int) bool { return false })
+	a.allocate(10012)
+	if got, err := a.allocate(10012); err != nil || got != 10010 {
+		t.Errorf("allocate(10012) = %d, %v once it is taken, want 10010", got, err)
+	}
+}
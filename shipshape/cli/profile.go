@@ -0,0 +1,89 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+
+	glog "github.com/google/shipshape/third_party/go-glog"
+)
+
+// startProfiles starts the CPU profile and execution trace asked for with
+// --cpuprofile and --trace. The returned function stops them and writes the
+// heap profile asked for with --memprofile; it must be called before the CLI
+// exits, or the profiles are left incomplete.
+func startProfiles() (func(), error) {
+	var stops []func()
+	stop := func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			return nil, fmt.Errorf("could not create the CPU profile: %v", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("could not start the CPU profile: %v", err)
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			f.Close()
+		})
+	}
+	if *traceFile != "" {
+		f, err := os.Create(*traceFile)
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("could not create the trace: %v", err)
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			stop()
+			return nil, fmt.Errorf("could not start the trace: %v", err)
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			f.Close()
+		})
+	}
+	if *memProfile != "" {
+		stops = append(stops, writeHeapProfile)
+	}
+	return stop, nil
+}
+
+// writeHeapProfile writes the heap profile to --memprofile.
+func writeHeapProfile() {
+	f, err := os.Create(*memProfile)
+	if err != nil {
+		glog.Errorf("Could not create the heap profile: %v", err)
+		return
+	}
+	defer f.Close()
+	// Collect garbage first, so that the profile shows what is still in use.
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		glog.Errorf("Could not write the heap profile: %v", err)
+	}
+}
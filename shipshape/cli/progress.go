@@ -0,0 +1,95 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// progressWidth is the number of characters in the bar itself.
+const progressWidth = 30
+
+// ProgressBar draws the progress that the service reports as a single line,
+// which is redrawn in place, so it should only be written to a terminal.
+type ProgressBar struct {
+	w        io.Writer
+	progress map[string]*rpcpb.AnalysisProgress
+	shown    bool
+}
+
+func NewProgressBar(w io.Writer) *ProgressBar {
+	return &ProgressBar{w: w, progress: make(map[string]*rpcpb.AnalysisProgress)}
+}
+
+// Update records the progress of the categories in progress, and redraws the bar.
+func (b *ProgressBar) Update(progress []*rpcpb.AnalysisProgress) {
+	for _, p := range progress {
+		b.progress[p.GetCategory()] = p
+	}
+	fmt.Fprintf(b.w, "\r%s\033[K", b.line())
+	b.shown = true
+}
+
+// Clear erases the bar, so that other output can be written. The bar is drawn
+// again on the next Update.
+func (b *ProgressBar) Clear() {
+	if b.shown {
+		fmt.Fprint(b.w, "\r\033[K")
+		b.shown = false
+	}
+}
+
+// line describes the progress of all the categories: a bar for the analyzer
+// calls finished out of all of them, the number of categories done, and how
+// far the first unfinished category has got.
+func (b *ProgressBar) line() string {
+	var cats []string
+	for cat := range b.progress {
+		cats = append(cats, cat)
+	}
+	sort.Strings(cats)
+	var completed, total, done int
+	var current string
+	for _, cat := range cats {
+		p := b.progress[cat]
+		completed += int(p.GetAnalyzersCompleted())
+		total += int(p.GetAnalyzersTotal())
+		if p.GetAnalyzersCompleted() >= p.GetAnalyzersTotal() {
+			done++
+		} else if current == "" {
+			current = Messages.Sprintf("; %s %d/%d analyzers", cat, p.GetAnalyzersCompleted(), p.GetAnalyzersTotal())
+		}
+	}
+	filled := progressWidth
+	if total > 0 {
+		filled = completed * progressWidth / total
+	}
+	bar := strings.Repeat("#", filled) + strings.Repeat("-", progressWidth-filled)
+	return Messages.Sprintf("Analyzing [%s] %d/%d categories done%s", bar, done, len(cats), current)
+}
+
+// IsTerminal returns whether f is a terminal, where a ProgressBar can be shown.
+func IsTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func progress(cat string, completed, total int32) *rpcpb.AnalysisProgress {
+	return &rpcpb.AnalysisProgress{Category: proto.String(cat), AnalyzersCompleted: proto.Int32(completed), AnalyzersTotal: proto.Int32(total)}
+}
+
+func TestProgressBar(t *testing.T) {
+	var buf bytes.Buffer
+	b := NewProgressBar(&buf)
+	b.Update([]*rpcpb.AnalysisProgress{progress("PyLint", 0, 10), progress("JSHint", 0, 10)})
+	b.Update([]*rpcpb.AnalysisProgress{progress("JSHint", 10, 10)})
+
+	if got, want := b.line(), "Analyzing [###############---------------] 1/2 categories done; PyLint 0/10 analyzers"; got != want {
+		t.Errorf("Wrong progress line:\n got %q\nwant %q", got, want)
+	}
+	if got, want := buf.String(), "\rAnalyzing [------------------------------] 0/2 categories done; JSHint 0/10 analyzers\033[K\r"+b.line()+"\033[K"; got != want {
+		t.Errorf("Wrong output:\n got %q\nwant %q", got, want)
+	}
+
+	buf.Reset()
+	b.Clear()
+	b.Clear()
+	if got, want := buf.String(), "\r\033[K"; got != want {
+		t.Errorf("Wrong output from clearing: got %q, want %q", got, want)
+	}
+}
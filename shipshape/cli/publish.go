@@ -0,0 +1,189 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"text/template"
+	"time"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// DefaultWebhookTemplate is the message body used when no template is provided.
+// Templates are executed against a Summary.
+const DefaultWebhookTemplate = `Shipshape found {{.NumNotes}} notes in {{.Directory}}.
+{{range .TopCategories}}  {{.Category}}: {{.Count}}{{if .Owner}}, {{.Owner}}{{end}}
+{{end}}{{if .Effort}}By effort to fix:
+{{range .Effort}}  {{.Effort}}: {{.Count}}
+{{end}}{{end}}{{if .NumSuppressed}}{{.NumSuppressed}} suppressed notes were left out.
+{{end}}{{if .NumFailures}}{{.NumFailures}} analyzers failed to run.
+{{end}}{{if .Incomplete}}The results are incomplete: {{.Incomplete}}
+{{end}}{{if .ArtifactURL}}Full results: {{.ArtifactURL}}
+{{end}}`
+
+// CategoryCount is the number of notes found for a single category.
+type CategoryCount struct {
+	Category string `json:"category"`
+	Count    int    `json:"count"`
+	// Owner says who maintains the category, as formatted by MaintainedBy, if
+	// the results name an owner for it.
+	Owner string `json:"owner,omitempty"`
+}
+
+// Summary is a digest of the results of a shipshape run, suitable for
+// sending to a notification hook.
+type Summary struct {
+	// RunID is the ID of the run, if it is known.
+	RunID       string `json:"run_id,omitempty"`
+	Directory   string `json:"directory"`
+	NumNotes    int    `json:"num_notes"`
+	NumFailures int    `json:"num_failures"`
+	// NumSuppressed is the number of notes left out because they were
+	// suppressed, which Suppressed breaks down by how they were.
+	NumSuppressed int                      `json:"num_suppressed,omitempty"`
+	Suppressed    *rpcpb.SuppressionCounts `json:"suppressed,omitempty"`
+	// TopCategories is sorted by descending count, ties broken by name.
+	TopCategories []CategoryCount `json:"top_categories"`
+	// Effort groups the notes by the effort to fix them, as CountEfforts
+	// does, if any of them are classified.
+	Effort []EffortCount `json:"effort,omitempty"`
+	// ArtifactURL links to the full results (e.g. the uploaded JSON output), if any.
+	ArtifactURL string `json:"artifact_url,omitempty"`
+	// Incomplete is the reason the results are incomplete, if they are.
+	Incomplete           string   `json:"incomplete,omitempty"`
+	IncompleteCategories []string `json:"incomplete_categories,omitempty"`
+}
+
+// Summarize builds a Summary for the given results, keeping at most
+// maxCategories entries in TopCategories. If maxCategories is 0, all
+// categories are kept.
+func Summarize(resp *rpcpb.ShipshapeResponse, directory string, maxCategories int) *Summary {
+	s := &Summary{RunID: resp.GetRunInfo().GetRunId(), Directory: directory}
+	if resp.Suppressed != nil {
+		s.NumSuppressed = SuppressedCount(resp.Suppressed)
+		s.Suppressed = resp.Suppressed
+	}
+	if inc := resp.Incomplete; inc != nil {
+		s.Incomplete = inc.GetReason()
+		s.IncompleteCategories = inc.Category
+	}
+	s.Effort = CountEfforts(AllNotes(resp))
+	counts := make(map[string]int)
+	for _, analysis := range resp.AnalyzeResponse {
+		s.NumFailures += len(analysis.Failure)
+		for _, note := range analysis.Note {
+			counts[note.GetCategory()]++
+			s.NumNotes++
+		}
+	}
+	owners := NewOwnership(resp.Owner)
+	for cat, count := range counts {
+		s.TopCategories = append(s.TopCategories, CategoryCount{cat, count, MaintainedBy(owners.Owner(cat))})
+	}
+	sort.Sort(byCount(s.TopCategories))
+	if maxCategories > 0 && len(s.TopCategories) > maxCategories {
+		s.TopCategories = s.TopCategories[:maxCategories]
+	}
+	return s
+}
+
+// SummaryLine formats the number of notes and failures in s, and the duration of the
+// run, as a single line of space-separated key=value pairs, for scripts to parse:
+// notes=12 failures=0 duration=43s. The duration is rounded to the second.
+func SummaryLine(s *Summary, duration time.Duration) string {
+	seconds := int64((duration + time.Second/2) / time.Second)
+	return fmt.Sprintf("notes=%d failures=%d duration=%ds", s.NumNotes, s.NumFailures, seconds)
+}
+
+type byCount []CategoryCount
+
+func (c byCount) Len() int      { return len(c) }
+func (c byCount) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c byCount) Less(i, j int) bool {
+	if c[i].Count != c[j].Count {
+		return c[i].Count > c[j].Count
+	}
+	return c[i].Category < c[j].Category
+}
+
+// WebhookPublisher posts run summaries to a webhook. The payload is compatible
+// with Slack incoming webhooks: the rendered message is in the "text" field,
+// and the raw summary is included for other consumers.
+type WebhookPublisher struct {
+	URL string
+	// Channel overrides the default channel of a Slack webhook. Optional.
+	Channel  string
+	template *template.Template
+}
+
+type webhookPayload struct {
+	Text    string   `json:"text"`
+	Channel string   `json:"channel,omitempty"`
+	Summary *Summary `json:"summary"`
+}
+
+// webhookTimeout is how long a webhook has to answer, so that one that hangs
+// does not hold up the end of the run, or the next analysis of serve-webhooks.
+var webhookTimeout = 30 * time.Second
+
+// NewWebhookPublisher creates a publisher for url. tmpl is a text/template
+// executed against a Summary; if empty, DefaultWebhookTemplate is used.
+func NewWebhookPublisher(url, channel, tmpl string) (*WebhookPublisher, error) {
+	if tmpl == "" {
+		tmpl = DefaultWebhookTemplate
+	}
+	t, err := template.New("webhook").Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse webhook template: %v", err)
+	}
+	return &WebhookPublisher{URL: url, Channel: channel, template: t}, nil
+}
+
+// Publish renders the summary and posts it to the webhook. The URL of a webhook
+// is usually its credential, so errors only name its host.
+func (p *WebhookPublisher) Publish(s *Summary) error {
+	var text bytes.Buffer
+	if err := p.template.Execute(&text, s); err != nil {
+		return fmt.Errorf("could not render webhook message: %v", err)
+	}
+	body, err := json.Marshal(webhookPayload{text.String(), p.Channel, s})
+	if err != nil {
+		return err
+	}
+	client := http.Client{Timeout: webhookTimeout}
+	resp, err := client.Post(p.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("could not post to the webhook on %s: %v", p.host(), unwrapURLError(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("the webhook on %s returned %s: %s", p.host(), resp.Status, msg)
+	}
+	return nil
+}
+
+// host returns the host of the webhook's URL, which is safe to print.
+func (p *WebhookPublisher) host() string {
+	return URLHost(p.URL)
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func makeNotes(category string, n int) []*notepb.Note {
+	var notes []*notepb.Note
+	for i := 0; i < n; i++ {
+		notes = append(notes, &notepb.Note{
+			Category:    proto.String(category),
+			Description: proto.String("A note"),
+		})
+	}
+	return notes
+}
+
+func TestSummarize(t *testing.T) {
+	resp := &rpcpb.ShipshapeResponse{
+		AnalyzeResponse: []*rpcpb.AnalyzeResponse{
+			{Note: append(makeNotes("JSHint", 2), makeNotes("PyLint", 3)...)},
+			{
+				Note:    makeNotes("AndroidLint", 2),
+				Failure: []*rpcpb.AnalysisFailure{{Category: proto.String("go vet"), FailureMessage: proto.String("boom")}},
+			},
+		},
+		Owner:      []*configpb.CategoryOwner{{Category: proto.String("PyLint"), Team: proto.String("python-infra")}},
+		Suppressed: &rpcpb.SuppressionCounts{Baseline: proto.Int32(2), Inline: proto.Int32(1)},
+	}
+
+	got := Summarize(resp, "/tmp/src", 2)
+	want := &Summary{
+		Directory:     "/tmp/src",
+		NumNotes:      7,
+		NumFailures:   1,
+		NumSuppressed: 3,
+		Suppressed:    resp.Suppressed,
+		TopCategories: []CategoryCount{
+			{"PyLint", 3, "maintained by python-infra"},
+			{"AndroidLint", 2, ""},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong summary: got %v, want %v", got, want)
+	}
+
+	if got, want := len(Summarize(resp, "", 0).TopCategories), 3; got != want {
+		t.Errorf("Wrong number of categories with no limit: got %d, want %d", got, want)
+	}
+}
+
+func TestSummaryLine(t *testing.T) {
+	tests := []struct {
+		summary  *Summary
+		duration time.Duration
+		want     string
+	}{
+		{&Summary{NumNotes: 12}, 43 * time.Second, "notes=12 failures=0 duration=43s"},
+		{&Summary{NumNotes: 0, NumFailures: 2}, 1500 * time.Millisecond, "notes=0 failures=2 duration=2s"},
+		{&Summary{}, 200 * time.Millisecond, "notes=0 failures=0 duration=0s"},
+	}
+	for _, test := range tests {
+		if got := SummaryLine(test.summary, test.duration); got != test.want {
+			t.Errorf("SummaryLine(%v, %v) = %q, want %q", test.summary, test.duration, got, test.want)
+		}
+	}
+}
+
+func TestWebhookPublish(t *testing.T) {
+	var got webhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Could not decode payload: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	p, err := NewWebhookPublisher(server.URL, "#builds", "{{.NumNotes}} notes, top {{(index .TopCategories 0).Category}}")
+	if err != nil {
+		t.Fatalf("Could not create publisher: %v", err)
+	}
+	s := &Summary{NumNotes: 4, TopCategories: []CategoryCount{{"PyLint", 4, ""}}}
+	if err := p.Publish(s); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if want := "4 notes, top PyLint"; got.Text != want {
+		t.Errorf("Wrong message text: got %q, want %q", got.Text, want)
+	}
+	if want := "#builds"; got.Channel != want {
+		t.Errorf("Wrong channel: got %q, want %q", got.Channel, want)
+	}
+	if got.Summary == nil || got.Summary.NumNotes != 4 {
+		t.Errorf("Summary not included in payload: got %v", got.Summary)
+	}
+}
+
+func TestWebhookPublishError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such channel", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	p, err := NewWebhookPublisher(server.URL, "", "")
+	if err != nil {
+		t.Fatalf("Could not create publisher: %v", err)
+	}
+	if err := p.Publish(&Summary{}); err == nil {
+		t.Errorf("Expected an error from a failing webhook")
+	}
+}
+
+func TestWebhookPublishTimeout(t *testing.T) {
+	defer func(d time.Duration) { webhookTimeout = d }(webhookTimeout)
+	webhookTimeout = 100 * time.Millisecond
+	release := make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	p, err := NewWebhookPublisher(server.URL+"/services/T000/B000/hunter2", "", "")
+	if err != nil {
+		t.Fatalf("Could not create publisher: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- p.Publish(&Summary{}) }()
+	select {
+	case err := <-done:
+		if err == nil || strings.Contains(err.Error(), "hunter2") {
+			t.Errorf("Wrong error from a webhook that does not answer: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Publishing to a webhook that does not answer did not time out")
+	}
+}
+
+func TestWebhookPublishErrorHidesURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid token", http.StatusForbidden)
+	}))
+	defer server.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	for _, base := range []string{server.URL, down.URL} {
+		p, err := NewWebhookPublisher(base+"/services/T000/B000/hunter2", "", "")
+		if err != nil {
+			t.Fatalf("Could not create publisher: %v", err)
+		}
+		err = p.Publish(&Summary{})
+		if err == nil {
+			t.Errorf("Expected an error from the webhook on %s", base)
+		} else if strings.Contains(err.Error(), "hunter2") {
+			t.Errorf("Error includes the webhook URL: %v", err)
+		}
+	}
+}
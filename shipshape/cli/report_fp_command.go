@@ -0,0 +1,101 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/google/shipshape/shipshape/cli"
+	"github.com/google/shipshape/shipshape/service"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// reportFPCommand implements `shipshape report-fp`, which reports a note as a
+// false positive to the owners of its analyzer. Reports are always recorded
+// locally, and are also posted to the feedback endpoint configured in
+// .shipshape and filed in an issue tracker if one is chosen.
+func reportFPCommand(args []string) int {
+	fs := flag.NewFlagSet("report-fp", flag.ExitOnError)
+	reason := fs.String("reason", "", "Why the note is a false positive")
+	results := fs.String("results", "", "A results file written by --json_output that has the note, so that the report describes it")
+	dir := fs.String("dir", ".", "The directory that was analyzed, whose .shipshape file configures the feedback endpoint")
+	endpoint := fs.String("endpoint", "", "The URL to post the report to. Defaults to the feedback URL in the global section of .shipshape.")
+	trackers := addTrackerFlags(fs, "")
+	fs.Usage = func() {
+		fmt.Println("USAGE: shipshape report-fp --reason=<reason> [--results=<results.json>] [flags] <fingerprint>")
+		fmt.Println("Pass --tracker to also file the report as an issue. The GitHub token is read from $GITHUB_TOKEN.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *reason == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return returnError
+	}
+
+	tracker, err := trackers.issueTracker()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+	url := *endpoint
+	if url == "" {
+		if url, err = service.FeedbackURL(*dir); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return returnError
+		}
+	}
+	var resp *rpcpb.ShipshapeResponse
+	if *results != "" {
+		if resp, err = cli.LoadResults(*results); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return returnError
+		}
+	}
+	report, err := cli.NewFalsePositiveReport(fs.Arg(0), *reason, resp, *dir)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+
+	if err := cli.RecordFalsePositive(cli.TelemetryDir(), report); err != nil {
+		fmt.Printf("Error: could not record the report: %v\n", err)
+		return returnError
+	}
+	fmt.Printf("Recorded the report in %s\n", filepath.Join(cli.TelemetryDir(), cli.FalsePositiveLog))
+	if report.Owner != "" {
+		fmt.Printf("The %s analyzer is %s.\n", report.Category, report.Owner)
+	}
+	if url != "" {
+		if err := cli.PostFalsePositive(url, report); err != nil {
+			fmt.Printf("Error: could not post the report: %v\n", err)
+			return returnError
+		}
+		// The endpoint may carry a token, and this ends up in CI logs.
+		fmt.Printf("Posted the report to %s\n", cli.URLHost(url))
+	}
+	if tracker != nil {
+		if err := cli.FileFalsePositive(tracker, report); err != nil {
+			fmt.Printf("Error: could not file the report: %v\n", err)
+			return returnError
+		}
+		fmt.Println("Filed the report as an issue")
+	}
+	return returnNoFindings
+}
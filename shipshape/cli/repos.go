@@ -0,0 +1,258 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/shipshape/shipshape/util/redact"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// BatchRepo is a repository listed in a batch manifest.
+type BatchRepo struct {
+	// Name names the repository in the report and the directory it is
+	// checked out in.
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+	// Branch is the branch to analyze. If empty, the default branch of the
+	// remote is.
+	Branch string `yaml:"branch"`
+	// Pipeline is the pipeline in the .shipshape file of the repository to
+	// run. If empty, the pipeline of the manifest is.
+	Pipeline string `yaml:"pipeline"`
+	// Path is the directory in the repository to analyze, if not all of it.
+	Path string `yaml:"path"`
+}
+
+// BatchManifest lists the repositories that `shipshape batch` analyzes. It is
+// a YAML (or JSON) document, for example:
+//
+//	pipeline: nightly
+//	repos:
+//	  - name: billing
+//	    url: https://github.com/example/billing.git
+//	  - name: frontend
+//	    url: git@github.com:example/frontend.git
+//	    branch: release
+//	    pipeline: nightly-js
+//	    path: web
+type BatchManifest struct {
+	// Pipeline is run for the repositories that do not name their own.
+	Pipeline string      `yaml:"pipeline"`
+	Repos    []BatchRepo `yaml:"repos"`
+}
+
+// LoadBatchManifest reads the batch manifest at path.
+func LoadBatchManifest(path string) (*BatchManifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the batch manifest %s: %v", path, err)
+	}
+	var m BatchManifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("could not parse the batch manifest %s: %v", path, err)
+	}
+	if len(m.Repos) == 0 {
+		return nil, fmt.Errorf("the batch manifest %s lists no repos", path)
+	}
+	names := make(map[string]bool)
+	for i, r := range m.Repos {
+		switch {
+		case r.Name == "" || r.URL == "":
+			return nil, fmt.Errorf("repo at index %d of the batch manifest %s must have a name and a url", i, path)
+		case r.Name == "." || r.Name == ".." || strings.ContainsAny(r.Name, `/\`):
+			return nil, fmt.Errorf("repo name %q in the batch manifest %s is not a valid directory name", r.Name, path)
+		case names[r.Name]:
+			return nil, fmt.Errorf("repo %q is listed more than once in the batch manifest %s", r.Name, path)
+		case r.Pipeline == "" && m.Pipeline == "":
+			return nil, fmt.Errorf("repo %q in the batch manifest %s has no pipeline, and the manifest sets no default", r.Name, path)
+		}
+		names[r.Name] = true
+	}
+	return &m, nil
+}
+
+// PipelineOf returns the pipeline to run for r.
+func (m *BatchManifest) PipelineOf(r BatchRepo) string {
+	if r.Pipeline != "" {
+		return r.Pipeline
+	}
+	return m.Pipeline
+}
+
+// SyncRepo checks out the latest commit of r in a directory named after it in
+// workdir, and returns the directory to analyze. Anything left in the checkout
+// by an earlier run is thrown away.
+func SyncRepo(workdir string, r BatchRepo) (string, error) {
+	ref := r.Branch
+	if ref == "" {
+		ref = "HEAD"
+	}
+	dir, err := CheckoutRef(workdir, r.Name, r.URL, ref)
+	if err != nil {
+		return "", err
+	}
+	if r.Path != "" {
+		return filepath.Join(dir, filepath.FromSlash(r.Path)), nil
+	}
+	return dir, nil
+}
+
+// CheckoutRef fetches ref, such as a branch or refs/pull/12/head, from the
+// repository at url and checks it out in the directory name in workdir, which
+// is created the first time and reused after that. Anything left in the
+// checkout by an earlier run is thrown away. It returns the directory. The url
+// and ref may come from a webhook, so those that git would read as options are
+// refused. Its errors name the url without any credentials in it, since they
+// end up in reports.
+func CheckoutRef(workdir, name, url, ref string) (string, error) {
+	safe := SanitizeRemote(url)
+	if strings.HasPrefix(url, "-") || strings.HasPrefix(ref, "-") {
+		return "", fmt.Errorf("could not check out %q of %q: neither can start with -", ref, safe)
+	}
+	dir := filepath.Join(workdir, name)
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if err := runGit(workdir, "init", "--quiet", "--", name); err != nil {
+			return "", fmt.Errorf("could not create a checkout of %s: %v", safe, err)
+		}
+		if err := runGit(dir, "remote", "add", "origin", url); err != nil {
+			return "", fmt.Errorf("could not create a checkout of %s: %v", safe, redactRemote(err, url))
+		}
+	}
+	for _, args := range [][]string{
+		{"remote", "set-url", "origin", url},
+		{"fetch", "--quiet", "origin", ref},
+		{"checkout", "--quiet", "--force", "--detach", "FETCH_HEAD"},
+		{"clean", "--quiet", "-d", "-x", "--force"},
+	} {
+		if err := runGit(dir, args...); err != nil {
+			return "", fmt.Errorf("could not check out %s of %s: %v", ref, safe, redactRemote(err, url))
+		}
+	}
+	return dir, nil
+}
+
+// runGit runs a git command in dir, returning its output as the error if it
+// fails.
+func runGit(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	// Never wait for a password that nobody is there to type.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %v: %s", args[0], err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// redactRemote returns err, from running git on the remote url, with the
+// credentials in url taken out, since git may quote the url in its output.
+func redactRemote(err error, url string) error {
+	msg := strings.Replace(err.Error(), url, SanitizeRemote(url), -1)
+	if i := strings.Index(url, "://"); i >= 0 {
+		host := url[i+3:]
+		if j := strings.Index(host, "@"); j > 0 && !strings.Contains(host[:j], "/") {
+			// The credentials may be quoted on their own, e.g. the user
+			// name that git asks for a password for.
+			msg = strings.Replace(msg, host[:j], redact.Redacted, -1)
+		}
+	}
+	return errors.New(msg)
+}
+
+// RepoResult is the outcome of analyzing one repository of a batch.
+type RepoResult struct {
+	Name string `json:"name"`
+	// URL is the URL of the repository, without any credentials.
+	URL      string `json:"url"`
+	Pipeline string `json:"pipeline"`
+	Commit   string `json:"commit,omitempty"`
+	// Results is the results file the run wrote, if it got that far.
+	Results string `json:"results,omitempty"`
+	// ExitStatus is the exit status of the run: 0 without notes, 1 with
+	// notes and 2 if it failed.
+	ExitStatus int `json:"exit_status"`
+	// Error says why the repository could not be analyzed, if it could not.
+	Error   string   `json:"error,omitempty"`
+	Summary *Summary `json:"summary,omitempty"`
+}
+
+// BatchReport aggregates the results of the repositories of a batch.
+type BatchReport struct {
+	Manifest  string        `json:"manifest"`
+	Timestamp time.Time     `json:"timestamp"`
+	Repos     []*RepoResult `json:"repos"`
+	NumNotes  int           `json:"num_notes"`
+	// NumFailed is the number of repositories that could not be analyzed.
+	NumFailed int `json:"num_failed"`
+}
+
+// Add adds the result of a repository to the report.
+func (b *BatchReport) Add(r *RepoResult) {
+	b.Repos = append(b.Repos, r)
+	if r.Summary != nil {
+		b.NumNotes += r.Summary.NumNotes
+	}
+	if r.Error != "" {
+		b.NumFailed++
+	}
+}
+
+// Write writes the report as JSON to path.
+func (b *BatchReport) Write(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Text formats the report as a table with a line for each repository.
+func (b *BatchReport) Text() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%-24s %-16s %7s %8s  %s\n", "repo", "pipeline", "notes", "failures", "top categories")
+	for _, r := range b.Repos {
+		if r.Summary == nil {
+			fmt.Fprintf(&buf, "%-24s %-16s %7s %8s  %s\n", r.Name, r.Pipeline, "-", "-", "error: "+r.Error)
+			continue
+		}
+		var top []string
+		for i, c := range r.Summary.TopCategories {
+			if i == 3 {
+				break
+			}
+			top = append(top, fmt.Sprintf("%s (%d)", c.Category, c.Count))
+		}
+		line := strings.Join(top, ", ")
+		if r.Error != "" {
+			line = "error: " + r.Error
+		}
+		fmt.Fprintf(&buf, "%-24s %-16s %7d %8d  %s\n", r.Name, r.Pipeline, r.Summary.NumNotes, r.Summary.NumFailures, line)
+	}
+	fmt.Fprintf(&buf, "%d notes in %d repos, %d of which could not be analyzed\n", b.NumNotes, len(b.Repos), b.NumFailed)
+	return buf.String()
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/google/shipshape/shipshape/cli"
+	"github.com/google/shipshape/shipshape/service"
+)
+
+// runCommand implements `shipshape run`, which runs an analysis with the
+// settings of a pipeline in the .shipshape file. It takes the same flags as
+// an analysis, which take precedence over the settings of the pipeline.
+func runCommand(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Println("USAGE: shipshape run <pipeline> [flags] [<directory>]")
+		return returnError
+	}
+	name := args[0]
+	flag.CommandLine.Parse(args[1:])
+	path := "."
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		path = flag.Arg(0)
+	default:
+		fmt.Println("USAGE: shipshape run <pipeline> [flags] [<directory>]")
+		return returnError
+	}
+
+	p, err := service.FindPipeline(configDir(path), name)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for name, value := range cli.PipelineFlags(p) {
+		if given[name] {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			fmt.Printf("Error: could not set --%s from pipeline %q: %v\n", name, p.GetName(), err)
+			return returnError
+		}
+	}
+	return analyze(path)
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/shipshape/shipshape/service"
+
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+)
+
+// scheduledRun is a schedule of a repository.
+type scheduledRun struct {
+	repo     BatchRepo
+	schedule *configpb.Schedule
+	cron     *service.Cron
+}
+
+// Scheduler keeps the schedules in the .shipshape files of the repositories
+// of a batch manifest, and turns them into events when they are due.
+type Scheduler struct {
+	mu   sync.Mutex
+	runs map[string][]scheduledRun
+}
+
+// NewScheduler returns a scheduler without any schedules.
+func NewScheduler() *Scheduler {
+	return &Scheduler{runs: make(map[string][]scheduledRun)}
+}
+
+// Update replaces the schedules of repo with schedules, as read from its
+// .shipshape file.
+func (s *Scheduler) Update(repo BatchRepo, schedules []*configpb.Schedule) error {
+	var runs []scheduledRun
+	for _, sc := range schedules {
+		c, err := service.ParseCron(sc.GetCron())
+		if err != nil {
+			return fmt.Errorf("schedule %q of %s has an invalid cron %q: %v", sc.GetName(), repo.Name, sc.GetCron(), err)
+		}
+		runs = append(runs, scheduledRun{repo, sc, c})
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs[repo.Name] = runs
+	return nil
+}
+
+// Due returns the events of the schedules that run in the minute of t,
+// ordered by repository and schedule.
+func (s *Scheduler) Due(t time.Time) []*GitEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for name := range s.runs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var events []*GitEvent
+	for _, name := range names {
+		for _, run := range s.runs[name] {
+			if run.cron.Matches(t) {
+				events = append(events, run.event())
+			}
+		}
+	}
+	return events
+}
+
+// event returns the event of a run of r.
+func (r scheduledRun) event() *GitEvent {
+	branch := r.schedule.GetBranch()
+	if branch == "" {
+		branch = r.repo.Branch
+	}
+	ref := "HEAD"
+	if branch != "" {
+		ref = "refs/heads/" + branch
+	}
+	return &GitEvent{
+		Repo:     r.repo.Name,
+		CloneURL: r.repo.URL,
+		Ref:      ref,
+		Schedule: r.schedule.GetName(),
+		Pipeline: r.schedule.GetPipeline(),
+		Path:     r.repo.Path,
+	}
+}
+
+// Next returns when the next of the schedules runs after t, and the zero time
+// if there are none.
+func (s *Scheduler) Next(t time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var next time.Time
+	for _, runs := range s.runs {
+		for _, run := range runs {
+			if n := run.cron.Next(t); !n.IsZero() && (next.IsZero() || n.Before(next)) {
+				next = n
+			}
+		}
+	}
+	return next
+}
+
+// Run calls queue with the events that are due at the start of each minute,
+// until stop is closed.
+func (s *Scheduler) Run(stop <-chan struct{}, queue func(*GitEvent)) {
+	for {
+		now := time.Now()
+		minute := now.Truncate(time.Minute).Add(time.Minute)
+		select {
+		case <-stop:
+			return
+		case <-time.After(minute.Sub(now)):
+		}
+		for _, e := range s.Due(minute) {
+			queue(e)
+		}
+	}
+}
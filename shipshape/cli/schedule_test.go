@@ -0,0 +1,72 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+)
+
+func TestScheduler(t *testing.T) {
+	s := NewScheduler()
+	billing := BatchRepo{Name: "billing", URL: "https://github.com/example/billing.git", Branch: "release", Path: "server"}
+	err := s.Update(billing, []*configpb.Schedule{
+		{Name: proto.String("nightly"), Cron: proto.String("0 2 * * *"), Pipeline: proto.String("full")},
+		{Name: proto.String("main"), Cron: proto.String("@hourly"), Pipeline: proto.String("quick"), Branch: proto.String("main")},
+	})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	at := time.Date(2015, time.June, 1, 2, 0, 0, 0, time.Local)
+	want := []*GitEvent{
+		{Repo: "billing", CloneURL: billing.URL, Ref: "refs/heads/release", Schedule: "nightly", Pipeline: "full", Path: "server"},
+		{Repo: "billing", CloneURL: billing.URL, Ref: "refs/heads/main", Schedule: "main", Pipeline: "quick", Path: "server"},
+	}
+	if got := s.Due(at); !reflect.DeepEqual(got, want) {
+		t.Errorf("Due(%v) = %+v, want %+v", at, got, want)
+	}
+	if got := s.Due(at.Add(time.Minute)); len(got) != 0 {
+		t.Errorf("Due(%v) = %+v, want none", at.Add(time.Minute), got)
+	}
+	if got, want := s.Next(at), at.Add(time.Hour); !got.Equal(want) {
+		t.Errorf("Next(%v) = %v, want %v", at, got, want)
+	}
+
+	// Updating the schedules of a repository replaces them.
+	if err := s.Update(billing, nil); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if got := s.Due(at); len(got) != 0 {
+		t.Errorf("Due(%v) after removing the schedules = %+v, want none", at, got)
+	}
+	if err := s.Update(billing, []*configpb.Schedule{{Name: proto.String("bad"), Cron: proto.String("every day")}}); err == nil {
+		t.Errorf("Update with an invalid cron succeeded, want an error")
+	}
+}
+
+func TestScheduledEventString(t *testing.T) {
+	e := &GitEvent{Repo: "billing", Ref: "HEAD", Schedule: "nightly"}
+	if got, want := e.String(), "billing on schedule nightly"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
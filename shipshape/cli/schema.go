@@ -0,0 +1,104 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// The versions of the schema of the results written by --json_output. Results
+// written before the version was recorded are v1. v2 records the version, the
+// Fingerprint of each note, and the severity of every note, even the default
+// WARNING, so that readers need not know the proto defaults.
+const (
+	SchemaV1 = "v1"
+	SchemaV2 = "v2"
+	// CurrentSchema is the version of the results that the CLI writes.
+	CurrentSchema = SchemaV2
+)
+
+// schemaVersions are the versions, oldest first.
+var schemaVersions = []string{SchemaV1, SchemaV2}
+
+// upgrades convert results of each version but the last to the next one, and
+// downgrades convert results of each version but the first to the one before.
+var (
+	upgrades = map[string]func(*rpcpb.ShipshapeResponse){
+		SchemaV1: upgradeV1,
+	}
+	downgrades = map[string]func(*rpcpb.ShipshapeResponse){
+		SchemaV2: downgradeV2,
+	}
+)
+
+// SchemaVersion returns the version of the schema of resp.
+func SchemaVersion(resp *rpcpb.ShipshapeResponse) string {
+	if v := resp.GetSchemaVersion(); v != "" {
+		return v
+	}
+	return SchemaV1
+}
+
+// ConvertSchema converts resp from its version of the schema to the version to,
+// one version at a time.
+func ConvertSchema(resp *rpcpb.ShipshapeResponse, to string) error {
+	from, err := schemaIndex(SchemaVersion(resp))
+	if err != nil {
+		return err
+	}
+	target, err := schemaIndex(to)
+	if err != nil {
+		return err
+	}
+	for ; from < target; from++ {
+		upgrades[schemaVersions[from]](resp)
+	}
+	for ; from > target; from-- {
+		downgrades[schemaVersions[from]](resp)
+	}
+	return nil
+}
+
+// schemaIndex returns the index of version in schemaVersions.
+func schemaIndex(version string) (int, error) {
+	for i, v := range schemaVersions {
+		if v == version {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown schema version %q: must be one of %s", version, strings.Join(schemaVersions, ", "))
+}
+
+func upgradeV1(resp *rpcpb.ShipshapeResponse) {
+	for _, note := range AllNotes(resp) {
+		note.Severity = note.GetSeverity().Enum()
+		note.Fingerprint = proto.String(Fingerprint(note))
+	}
+	resp.SchemaVersion = proto.String(SchemaV2)
+}
+
+func downgradeV2(resp *rpcpb.ShipshapeResponse) {
+	for _, note := range AllNotes(resp) {
+		note.Fingerprint = nil
+	}
+	resp.SchemaVersion = nil
+}
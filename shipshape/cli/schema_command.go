@@ -0,0 +1,62 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/google/shipshape/shipshape/cli"
+)
+
+// schemaCommand implements `shipshape schema`, which prints the JSON Schema of
+// the results written by --json_output, or checks results files against it.
+func schemaCommand(args []string) int {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println("USAGE: shipshape schema")
+		fmt.Println("       shipshape schema <results.json>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		b, err := json.MarshalIndent(cli.OutputSchema(), "", "  ")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return returnError
+		}
+		fmt.Println(string(b))
+		return returnNoFindings
+	}
+
+	status := returnNoFindings
+	for _, path := range fs.Args() {
+		b, err := ioutil.ReadFile(path)
+		if err == nil {
+			err = cli.ValidateOutput(b)
+		}
+		if err != nil {
+			fmt.Printf("%s: %v\n", path, err)
+			status = returnError
+			continue
+		}
+		fmt.Printf("%s: ok\n", path)
+	}
+	return status
+}
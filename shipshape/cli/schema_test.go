@@ -0,0 +1,104 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func TestConvertSchema(t *testing.T) {
+	note := &notepb.Note{Category: proto.String("PyLint"), Description: proto.String("A note")}
+	resp := &rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{{Note: []*notepb.Note{note}}}}
+	if got := SchemaVersion(resp); got != SchemaV1 {
+		t.Errorf("Wrong version of results without one: got %s, want %s", got, SchemaV1)
+	}
+
+	if err := ConvertSchema(resp, SchemaV2); err != nil {
+		t.Fatalf("Could not convert to v2: %v", err)
+	}
+	if got := resp.GetSchemaVersion(); got != SchemaV2 {
+		t.Errorf("Wrong recorded version: got %q, want %q", got, SchemaV2)
+	}
+	if note.Severity == nil || note.GetSeverity() != notepb.Note_WARNING {
+		t.Errorf("Default severity not recorded: got %v", note.Severity)
+	}
+	if got, want := note.GetFingerprint(), Fingerprint(note); got != want {
+		t.Errorf("Wrong fingerprint: got %q, want %q", got, want)
+	}
+	if err := ConvertSchema(resp, SchemaV2); err != nil || note.GetFingerprint() == "" {
+		t.Errorf("Converting to the same version changed the results: %v, %v", err, resp)
+	}
+
+	if err := ConvertSchema(resp, SchemaV1); err != nil {
+		t.Fatalf("Could not convert to v1: %v", err)
+	}
+	if resp.SchemaVersion != nil || note.Fingerprint != nil {
+		t.Errorf("v2 fields left in v1 results: %v", resp)
+	}
+
+	if err := ConvertSchema(resp, "v9"); err == nil {
+		t.Errorf("Expected an error converting to an unknown version")
+	}
+	resp.SchemaVersion = proto.String("v9")
+	if err := ConvertSchema(resp, SchemaV2); err == nil {
+		t.Errorf("Expected an error converting from an unknown version")
+	}
+}
+
+func TestLoadResultsConverts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schema")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "results.json")
+	v1 := &rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{{Note: makeNotes("JSHint", 1)}}}
+	b, err := json.Marshal(v1)
+	if err != nil {
+		t.Fatalf("Could not marshal results: %v", err)
+	}
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		t.Fatalf("Could not write results: %v", err)
+	}
+
+	raw, err := ReadResults(path)
+	if err != nil {
+		t.Fatalf("Could not read results: %v", err)
+	}
+	if got := SchemaVersion(raw); got != SchemaV1 {
+		t.Errorf("Wrong version of the results as written: got %s, want %s", got, SchemaV1)
+	}
+	loaded, err := LoadResults(path)
+	if err != nil {
+		t.Fatalf("Could not load results: %v", err)
+	}
+	if got := SchemaVersion(loaded); got != CurrentSchema {
+		t.Errorf("Wrong version of the loaded results: got %s, want %s", got, CurrentSchema)
+	}
+	if AllNotes(loaded)[0].GetFingerprint() == "" {
+		t.Errorf("Loaded results have no fingerprints: %v", loaded)
+	}
+}
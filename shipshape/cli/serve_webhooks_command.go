@@ -0,0 +1,206 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/shipshape/shipshape/cli"
+	"github.com/google/shipshape/shipshape/service"
+	glog "github.com/google/shipshape/third_party/go-glog"
+)
+
+// serveWebhooksCommand implements `shipshape serve-webhooks`, which listens
+// for the push and pull request webhooks of GitHub and GitLab, checks out the
+// revision of each, runs a pipeline against it and posts a summary of the
+// results to a webhook. It also runs the schedules in the .shipshape files of
+// the repositories of a batch manifest, in the same queue.
+func serveWebhooksCommand(args []string) int {
+	fs := flag.NewFlagSet("serve-webhooks", flag.ExitOnError)
+	listen := fs.String("listen", ":8080", "The address to listen for webhooks on")
+	pipeline := fs.String("pipeline", "", "The pipeline in the .shipshape file of the repository to run")
+	workdir := fs.String("workdir", filepath.Join(cli.TelemetryDir(), "webhooks"), "The directory to check the repositories out in")
+	repos := fs.String("repos", "", "The full names of the repositories to analyze, such as example/billing (comma-separated), each optionally followed by = and the URL to clone it from rather than the one in the webhook. If empty, any repository that sends a webhook with the secret is analyzed.")
+	queue := fs.Int("queue", 20, "How many pushes and pull requests can wait to be analyzed. Webhooks beyond that are turned away.")
+	manifest := fs.String("manifest", "", "A batch manifest of repositories whose .shipshape files have schedules to run. The schedules are read again whenever a repository is checked out for one.")
+	hookURL := fs.String("webhook_url", "", "When specified, post a summary of the results of each analysis to this webhook (e.g. a Slack incoming webhook)")
+	hookChannel := fs.String("webhook_channel", "", "Slack channel to post the summaries to, overriding the webhook's default")
+	hookTemplate := fs.String("webhook_template", "", "File containing a Go text/template for the webhook message, executed against the run summary")
+	fs.Usage = func() {
+		fmt.Println("USAGE: shipshape serve-webhooks --pipeline=<pipeline> [--listen=<address>] [flags]")
+		fmt.Println("Point the webhooks of GitHub at /github and those of GitLab at /gitlab. The secret they are signed with is read from $SHIPSHAPE_WEBHOOK_SECRET.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *pipeline == "" || *queue < 1 || fs.NArg() != 0 {
+		fs.Usage()
+		return returnError
+	}
+	secret := os.Getenv("SHIPSHAPE_WEBHOOK_SECRET")
+	if secret == "" {
+		fmt.Println("Error: $SHIPSHAPE_WEBHOOK_SECRET must be set, so that only the git server can start analyses")
+		return returnError
+	}
+
+	var publisher *cli.WebhookPublisher
+	if *hookURL != "" {
+		var tmpl string
+		if *hookTemplate != "" {
+			b, err := ioutil.ReadFile(*hookTemplate)
+			if err != nil {
+				fmt.Printf("Error: could not read webhook template: %v\n", err)
+				return returnError
+			}
+			tmpl = string(b)
+		}
+		var err error
+		if publisher, err = cli.NewWebhookPublisher(*hookURL, *hookChannel, tmpl); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return returnError
+		}
+	}
+	if err := os.MkdirAll(*workdir, 0755); err != nil {
+		fmt.Printf("Error: could not create the workdir: %v\n", err)
+		return returnError
+	}
+	self, err := selfPath()
+	if err != nil {
+		fmt.Printf("Error: could not find the shipshape binary to run: %v\n", err)
+		return returnError
+	}
+
+	var allowed []string
+	if *repos != "" {
+		allowed = strings.Split(*repos, ",")
+	}
+	var scheduled []cli.BatchRepo
+	if *manifest != "" {
+		m, err := cli.LoadBatchManifest(*manifest)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return returnError
+		}
+		scheduled = m.Repos
+	}
+	scheduler := cli.NewScheduler()
+	receiver := cli.NewWebhookReceiver(secret, allowed, *queue, func(e *cli.GitEvent) {
+		result := analyzeEvent(self, *workdir, *pipeline, e)
+		if e.Schedule != "" {
+			updateSchedules(scheduler, *workdir, findRepo(scheduled, e.Repo))
+		}
+		if result.Error != "" {
+			glog.Errorf("Could not analyze %s: %s", e, result.Error)
+		} else {
+			glog.Infof("Analyzed %s: %d notes", e, result.Summary.NumNotes)
+		}
+		if publisher == nil {
+			return
+		}
+		s := result.Summary
+		if s == nil {
+			s = &cli.Summary{Incomplete: result.Error}
+		}
+		s.Directory = e.String()
+		if err := publisher.Publish(s); err != nil {
+			glog.Errorf("Could not publish the results of %s: %v", e, err)
+		}
+	})
+	if len(scheduled) > 0 {
+		for _, r := range scheduled {
+			if _, err := cli.SyncRepo(*workdir, r); err != nil {
+				glog.Errorf("Could not read the schedules of %s: %v", r.Name, err)
+				continue
+			}
+			updateSchedules(scheduler, *workdir, r)
+		}
+		if next := scheduler.Next(time.Now()); !next.IsZero() {
+			fmt.Printf("The next scheduled run is at %s\n", next.Format("2006-01-02 15:04 MST"))
+		}
+		go scheduler.Run(nil, func(e *cli.GitEvent) { receiver.Queue(e) })
+	}
+	fmt.Printf("Listening for webhooks on %s\n", *listen)
+	if err := http.ListenAndServe(*listen, receiver); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+	return returnNoFindings
+}
+
+// analyzeEvent checks out the revision of e in workdir and runs pipeline, or
+// the pipeline of the schedule of e, against it with the shipshape binary self.
+func analyzeEvent(self, workdir, pipeline string, e *cli.GitEvent) *cli.RepoResult {
+	// The repositories of the manifest are checked out under their names, and
+	// those of webhooks under their full names with the provider, so that
+	// they never share a checkout.
+	name := e.Repo
+	if e.Provider != "" {
+		name = e.Provider + "_" + strings.Replace(e.Repo, "/", "_", -1)
+	}
+	if e.Pipeline != "" {
+		pipeline = e.Pipeline
+	}
+	result := &cli.RepoResult{Name: name, URL: cli.SanitizeRemote(e.CloneURL), Pipeline: pipeline}
+	dir, err := cli.CheckoutRef(workdir, name, e.CloneURL, e.Ref)
+	if err != nil {
+		result.ExitStatus = returnError
+		result.Error = err.Error()
+		return result
+	}
+	if e.Path != "" {
+		dir = filepath.Join(dir, filepath.FromSlash(e.Path))
+	}
+	result.Commit = cli.GetRevisionDetails(dir).GetCommit()
+	if e.Commit != "" && result.Commit != e.Commit {
+		glog.Infof("%s moved on to %s since the webhook was sent", e, result.Commit)
+	}
+	runPipeline(self, workdir, dir, result)
+	return result
+}
+
+// updateSchedules reads the schedules of r from its checkout in workdir.
+func updateSchedules(scheduler *cli.Scheduler, workdir string, r cli.BatchRepo) {
+	dir := filepath.Join(workdir, r.Name)
+	if r.Path != "" {
+		dir = filepath.Join(dir, filepath.FromSlash(r.Path))
+	}
+	schedules, err := service.Schedules(dir)
+	if err == nil {
+		err = scheduler.Update(r, schedules)
+	}
+	if err != nil {
+		glog.Errorf("Could not read the schedules of %s: %v", r.Name, err)
+		return
+	}
+	glog.Infof("%s has %d schedules", r.Name, len(schedules))
+}
+
+// findRepo returns the repository called name in repos.
+func findRepo(repos []cli.BatchRepo, name string) cli.BatchRepo {
+	for _, r := range repos {
+		if r.Name == name {
+			return r
+		}
+	}
+	return cli.BatchRepo{Name: name}
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/shipshape/shipshape/util/docker"
+)
+
+const (
+	// reuseTimeout is how long a running service has to answer before it is
+	// deemed unhealthy and restarted, rather than reused.
+	reuseTimeout = 3 * time.Second
+	// readyTimeout is how long a freshly started service has to become ready.
+	readyTimeout = 10 * time.Second
+	// serviceLogLines is how many of the last lines the service logged are
+	// attached to the error when it does not become ready.
+	serviceLogLines = 20
+)
+
+// notReadyError describes a container that did not become ready in time,
+// with the last lines it logged attached, so that a crash on startup shows
+// up in the error rather than only in the logs.
+func notReadyError(container string, err error) error {
+	result := docker.Logs(container)
+	logs := lastLines(result.Stdout+result.Stderr, serviceLogLines)
+	if logs == "" {
+		return fmt.Errorf("%s did not become ready: %v", container, err)
+	}
+	return fmt.Errorf("%s did not become ready: %v\nThe last lines it logged were:\n%s", container, err, logs)
+}
+
+// lastLines returns the last n lines of s, without a trailing newline.
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
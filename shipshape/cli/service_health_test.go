@@ -0,0 +1,37 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import "testing"
+
+func TestLastLines(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"", 3, ""},
+		{"one\n", 3, "one"},
+		{"one\ntwo\nthree\nfour\n", 2, "three\nfour"},
+		{"one\ntwo", 2, "one\ntwo"},
+	}
+	for _, test := range tests {
+		if got := lastLines(test.s, test.n); got != test.want {
+			t.Errorf("lastLines(%q, %d) = %q, want %q", test.s, test.n, got, test.want)
+		}
+	}
+}
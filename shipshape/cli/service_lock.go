@@ -0,0 +1,138 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	glog "github.com/google/shipshape/third_party/go-glog"
+)
+
+// lockPollInterval is how often a run waiting for the lock of its docker
+// daemon tries to take it.
+const lockPollInterval = 500 * time.Millisecond
+
+// lockHolder is what a lock file records about the run holding it, for the
+// runs that find it locked.
+type lockHolder struct {
+	PID       int       `json:"pid"`
+	RunID     string    `json:"run_id,omitempty"`
+	Workspace string    `json:"workspace"`
+	Started   time.Time `json:"started"`
+}
+
+func (h *lockHolder) String() string {
+	if h == nil {
+		return "another shipshape run"
+	}
+	s := fmt.Sprintf("another shipshape run (pid %d", h.PID)
+	if h.RunID != "" {
+		s += ", run " + h.RunID
+	}
+	return s + ", started " + h.Started.Local().Format("15:04:05") + ", analyzing " + h.Workspace + ")"
+}
+
+// ServiceLock is held by a run for as long as it uses the containers of its
+// docker daemon, so that two runs do not restart the containers under each
+// other. The containers, such as shipping_container, have the same names
+// whatever workspace they analyze, so runs on different workspaces contend
+// for them too. It is an advisory lock on a file, so the operating system
+// releases it if the run dies.
+type ServiceLock struct {
+	f *os.File
+}
+
+// LockService takes the lock of the docker daemon at daemon, whose lock file
+// is kept in dir, for the run runID analyzing root, an absolute path. If
+// another run holds it, LockService waits up to wait for that run to finish,
+// calling waiting once with a description of it, and then fails with an error
+// that says which run it was.
+func LockService(dir, daemon, root, runID string, wait time.Duration, waiting func(holder string)) (*ServiceLock, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("could not create the lock directory: %v", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%x.lock", sha256.Sum256([]byte(daemon))))
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open the lock of %s: %v", daemon, err)
+	}
+	deadline := time.Now().Add(wait)
+	for notified := false; ; notified = true {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if err != syscall.EWOULDBLOCK {
+			f.Close()
+			return nil, fmt.Errorf("could not lock %s: %v", daemon, err)
+		}
+		holder := readLockHolder(path)
+		if !time.Now().Before(deadline) {
+			f.Close()
+			if wait == 0 {
+				return nil, fmt.Errorf("%s is already using the containers on %s; wait for it to finish, or pass --lock_wait to queue behind it", holder, daemon)
+			}
+			return nil, fmt.Errorf("%s is still using the containers on %s after waiting %v for it", holder, daemon, wait)
+		}
+		if !notified && waiting != nil {
+			waiting(holder.String())
+		}
+		time.Sleep(lockPollInterval)
+	}
+
+	b, err := json.Marshal(lockHolder{os.Getpid(), runID, root, time.Now().UTC()})
+	if err == nil {
+		if err = f.Truncate(0); err == nil {
+			_, err = f.WriteAt(b, 0)
+		}
+	}
+	if err != nil {
+		// The lock is held all the same; only the description of us is missing.
+		glog.Errorf("Could not record the run in the lock of %s: %v", daemon, err)
+	}
+	return &ServiceLock{f}, nil
+}
+
+// readLockHolder reads the run holding the lock file at path, or returns nil if
+// it cannot tell.
+func readLockHolder(path string) *lockHolder {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var h lockHolder
+	if err := json.Unmarshal(b, &h); err != nil || h.PID == 0 {
+		return nil
+	}
+	return &h
+}
+
+// Unlock releases the lock, letting the next run on the docker daemon start.
+func (l *ServiceLock) Unlock() error {
+	if l == nil {
+		return nil
+	}
+	l.f.Truncate(0)
+	return l.f.Close()
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockService(t *testing.T) {
+	dir, err := ioutil.TempDir("", "service_lock_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const daemon = "unix:///var/run/docker.sock"
+	first, err := LockService(dir, daemon, "/src/app", "run-1", 0, nil)
+	if err != nil {
+		t.Fatalf("LockService failed: %v", err)
+	}
+	// Other daemons have locks of their own.
+	other, err := LockService(dir, "tcp://build-host:2376", "/src/app", "run-2", 0, nil)
+	if err != nil {
+		t.Fatalf("LockService of another daemon failed: %v", err)
+	}
+	other.Unlock()
+
+	// Runs on other workspaces share the containers of the daemon, so they are
+	// locked out too.
+	for _, root := range []string{"/src/app", "/src/app/sub", "/src/other"} {
+		_, err = LockService(dir, daemon, root, "run-3", 0, nil)
+		if err == nil || !strings.Contains(err.Error(), "run run-1") || !strings.Contains(err.Error(), "analyzing /src/app)") || !strings.Contains(err.Error(), "--lock_wait") {
+			t.Errorf("Wrong error for a locked daemon from %s: got %v", root, err)
+		}
+	}
+
+	var waited []string
+	_, err = LockService(dir, daemon, "/src/other", "run-3", 2*lockPollInterval, func(holder string) { waited = append(waited, holder) })
+	if err == nil || !strings.Contains(err.Error(), "still using") {
+		t.Errorf("Wrong error after waiting for a locked daemon: got %v", err)
+	}
+	if len(waited) != 1 || !strings.Contains(waited[0], "run run-1") {
+		t.Errorf("Wrong runs waited for: got %v", waited)
+	}
+
+	// A run waiting for the lock takes it once the run holding it finishes.
+	go func() {
+		time.Sleep(lockPollInterval)
+		first.Unlock()
+	}()
+	second, err := LockService(dir, daemon, "/src/other", "run-4", time.Minute, nil)
+	if err != nil {
+		t.Fatalf("LockService failed after the lock was released: %v", err)
+	}
+	second.Unlock()
+}
@@ -21,35 +21,143 @@
 package main
 
 import (
+	"crypto"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/google/shipshape/shipshape/cli"
+	"github.com/google/shipshape/shipshape/service"
+	"github.com/google/shipshape/shipshape/util/docker"
+	"github.com/google/shipshape/shipshape/util/redact"
+	glog "github.com/google/shipshape/third_party/go-glog"
 
 	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
 	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
 )
 
 var (
 	analyzerImages = flag.String("analyzer_images", "", "Full docker path to images of external analyzers to use (comma-separated)")
 	build          = flag.String("build", "", "The name of the build system to use to generate compilation units. If empty, will not run the compilation step. Options are maven and go.")
+	stages         = flag.String("stages", "", "Stages to analyze, in order (comma-separated): PRE_BUILD, POST_BUILD, or the stages that analyzers register for beyond the build, DEPENDENCIES, SECURITY and DEPLOYMENT_CONFIG. If none are specified, PRE_BUILD is analyzed, followed by POST_BUILD if --build is given.")
 	categories     = flag.String("categories", "", "Categories to trigger (comma-separated). If none are specified, will use the .shipshape configuration file to decide which categories to run.")
 	dind           = flag.Bool("inside_docker", false, "True if the CLI is run from inside a docker container")
+	dockerAccess   = flag.String("docker_access", "dind", "How the containers shipshape starts can use docker: 'dind' runs them privileged when --inside_docker is set, 'socket' mounts the host's docker socket into them instead")
+	strictSandbox  = flag.Bool("strict_sandbox", false, "Run every third-party analyzer offline, with a read-only root filesystem and all capabilities dropped, on top of any sandbox configured for it in .shipshape. Sandboxed analyzers get no access to docker.")
+	hostRoot       = flag.String("host_workspace_root", "", "The path of the analyzed directory on the docker host, if it differs from the path the CLI sees (e.g. when run in a container). Found from the container's mounts with --inside_docker --docker_access=socket.")
 	event          = flag.String("event", cli.DefaultEvent, "The name of the event to use")
 	jsonOutput     = flag.String("json_output", "", "When specified, log shipshape results to provided .json file")
+	validateOutput = flag.Bool("validate_output", false, "Check that the JSON results match the schema that shipshape schema prints, failing the run if they do not, e.g. in CI for integrators to rely on it")
+	runID          = flag.String("run_id", "", "The ID of the run, which is logged, labels the containers it starts, is sent to the service and is recorded in the results, so that they can be matched up, e.g. to the ID of a CI build. By default, a random UUID")
 	repo           = flag.String("repo", cli.DefaultRepo, "The name of the docker repo to use")
 	stayUp         = flag.Bool("stay_up", true, "True if we should keep the container running, false if we should stop and remove it.")
 	tag            = flag.String("tag", "prod", "Tag to use for the analysis service image. If this is local, we will not attempt to pull the image.")
 	useLocalKythe  = flag.Bool("local_kythe", false, "True if we should not pull down the kythe image. This is used for testing a new kythe image.")
+	followSymlinks = flag.Bool("follow_symlinks", false, "Resolve symlinks in the path to analyze, so that the analyzers see the directory it links to and note paths are relative to it")
+	socketDir      = flag.String("service_socket_dir", "", "When specified, mount this directory into the service's container and talk to the service over a unix socket in it, instead of publishing port 10007 on the host")
+	snapshotDir    = flag.String("snapshot_dir", cli.DefaultSnapshotDir, "Where the state and results of each run are recorded, so that a run on an unchanged workspace, config, analyzer configuration and images shows the last results instead of analyzing again. Empty to disable. See shipshape cache.")
+	transcodeDir   = flag.String("transcode_dir", cli.DefaultTranscodeDir, "Where to stage a copy of the workspace when some files to analyze are Latin-1, UTF-16 or UTF-8 with a byte order mark, so that the analyzers see them as plain UTF-8. Empty to analyze them as they are.")
+	maxImageCache  = flag.String("max_image_cache", "", "When specified, e.g. 20G, remove the least recently used images of earlier shipshape runs before pulling, until the images shipshape has used take at most this much disk space")
+	exportUnits    = flag.String("export_compilations", "", "A directory to copy the compilation units that the POST_BUILD stage extracts from the build to, for other tools or later runs to use.")
+	importUnits    = flag.String("import_compilations", "", "A directory of compilation units, e.g. from --export_compilations, for the POST_BUILD stage to analyze instead of extracting them from the build. Implies the POST_BUILD stage; --build is not needed.")
+	streamOutput   = flag.Bool("stream_analyzer_output", false, "Print what the third-party analyzer containers write to their stdout and stderr while they run, each line prefixed with the name of its container, e.g. to debug a custom analyzer that finds no notes. Turns off the progress bar.")
+	force          = flag.Bool("force", false, "Analyze even if nothing changed since the last run")
+	ignoreLock     = flag.Bool("ignore_lock", false, "Use the latest versions of the images even if they are pinned by a .shipshape.lock file")
+	listEvents     = flag.Bool("list_events", false, "List the built-in events and the custom events declared in the .shipshape file of the given directory (the current one by default), then exit")
+	deterministic  = flag.Bool("deterministic", false, "Run the built-in analyzers with the fixed configuration in the service image, ignoring any of theirs in the workspace (e.g. a pylintrc), so that the results only depend on the code and the images. Use with a .shipshape.lock file to also fix the images.")
+
+	maxDescription   = flag.Int("max_description_length", cli.DefaultMaxDescription, "The most characters of a note description to show in text output; longer ones are truncated. 0 shows them in full. JSON output always has the full descriptions.")
+	fullDescriptions = flag.Bool("full_descriptions", false, "Show note descriptions in full in text output, however long they are")
+
+	cpuProfile = flag.String("cpuprofile", "", "When specified, write a CPU profile of the CLI to this file, for go tool pprof")
+	memProfile = flag.String("memprofile", "", "When specified, write a heap profile of the CLI to this file once the results are handled, for go tool pprof")
+	traceFile  = flag.String("trace", "", "When specified, write an execution trace of the CLI to this file, for go tool trace")
+
+	diagnosePath    = flag.String("diagnose", "", "When specified, write a diagnostics bundle for bug reports to this file after the run: the flags, the request to the service, docker inspect output and logs of the containers, and environment info")
+	diagnoseOnError = flag.Bool("diagnose_on_error", true, "If the run fails because of docker, the containers or the service, write a diagnostics bundle to a temporary file even without --diagnose")
+
+	idleTimeout         = flag.Duration("idle_timeout", 0, "When non-zero, give up if the service sends nothing for this long (e.g. 10m). The service sends heartbeats while analyzers are running, so this only detects a service that has hung.")
+	analyzerParallelism = flag.Int("analyzer_parallelism", 0, "When positive, the most analyzers to run at the same time. Lower this if running all analyzers at once uses too much memory.")
+	batchSize           = flag.Int("batch_size", 0, "When positive, analyze a directory in batches of this many files, each in its own request to the service, so that results arrive sooner and a crash only loses one batch")
+
+	timeBudget = flag.Duration("time_budget", 0, "When non-zero, stop analyzing after this long (e.g. 5m). Categories are run in order of their priority in .shipshape, and those that do not finish are reported as failures.")
+	tier       = flag.String("tier", "", "When specified, only run the categories configured in .shipshape to be in this tier: fast or slow")
+	attribute  = flag.Bool("attribute", false, "Annotate each note with the last author of its line (from git blame) and the owners of its file (from CODEOWNERS)")
+	dryRun     = flag.Bool("dry_run", false, "Print which categories would run on which files and stages, and which images would be pulled and containers started, without touching docker")
+
+	artifactURL     = flag.String("artifact_url", "", "Link to the full results of this run (e.g. a CI artifact). Included in notifications.")
+	webhookURL      = flag.String("webhook_url", "", "When specified, post a summary of the results to this webhook (e.g. a Slack incoming webhook)")
+	webhookChannel  = flag.String("webhook_channel", "", "Slack channel to post the summary to, overriding the webhook's default")
+	webhookTemplate = flag.String("webhook_template", "", "File containing a Go text/template for the webhook message, executed against the run summary")
+	historyDB       = flag.String("history_db", "", "When specified, record the results of this run in the results history at this path. See shipshape trend.")
+	upload          = flag.String("upload", "", "When specified, upload the result files to this cloud storage location (gs://bucket/prefix or s3://bucket/prefix)")
+	coverageReport  = flag.String("coverage_report", "", "When specified, write a JSON report to this file mapping each analyzed file to the categories that processed it, and listing the files that none did")
+	attestation     = flag.String("attestation", "", "When specified, write a signed in-toto attestation of the run to this file, recording the images, config and categories used and a digest of the notes found. Requires --attestation_key.")
+	attestationKey  = flag.String("attestation_key", "", "PEM file with the ECDSA or RSA private key to sign the attestation with")
+	format          = flag.String("format", "text", "How to print the notes: text, or csv or tsv for loading into spreadsheets and BI tools, with columns path, start_line, start_col, category, subcategory, severity, description and fingerprint")
+	paths           = flag.String("paths", "", "How to print the paths of the notes, in text, csv and tsv: relative to the directory analyzed, absolute, or relative to the top of its git repository (repo-root). By default, joined to the directory as it was given")
+	showProgress    = flag.Bool("progress", true, "Show a progress bar on stderr while the analyzers run, if stderr is a terminal and the notes are printed as text")
+	locale          = flag.String("locale", "", "The locale to print messages in, e.g. de or de_DE. By default, taken from $LC_ALL, $LC_MESSAGES or $LANG. The notes are printed as the analyzers wrote them.")
+	noUnicode       = flag.Bool("no_unicode", false, "Print only ASCII, transliterating the notes and messages, e.g. for terminals and screen readers that cannot show other characters")
+	openEditor      = flag.String("open", "", "After the run, open the location of the first note printed in this editor: vscode, idea or vim")
+	quiet           = flag.Bool("quiet", false, "Print only a single summary line, e.g. notes=12 failures=0 duration=43s, instead of the notes and messages about them; for scripts that only need the exit status and get the details from --json_output")
+
+	maxNotes            = flag.Int("max_notes", -1, "When non-negative, fail if more than this many notes are found")
+	maxNotesPerCategory = flag.String("max_notes_per_category", "", "Fail if more notes than the limit are found for a category (comma-separated category=limit pairs, e.g. PyLint=20,JSHint=0)")
+	ratchetFile         = flag.String("ratchet", "", "When specified, fail if any category has more notes than recorded in this file, and record the new counts if none do. The file is created if it does not exist.")
+	baselinePath        = flag.String("baseline", "", "When specified, leave out the notes recorded in this baseline file. See shipshape triage.")
+	failFast            = flag.Bool("fail_fast", false, "Stop the analysis and fail as soon as a note at least as severe as --fail_fast_severity is found, e.g. for pre-commit checks where any such note rejects the commit anyway. Suppressed and baselined notes do not count.")
+	failFastSeverity    = flag.String("fail_fast_severity", "BUILD_ERROR", "The least severe note that stops the analysis with --fail_fast: BUILD_ERROR, WARNING, or OTHER")
+	lockWait            = flag.Duration("lock_wait", 0, "How long to wait for another run using the same docker daemon, on any directory, to finish before failing. By default, fail straight away, instead of both runs restarting the containers under each other.")
+	showSuppressed      = flag.Bool("show_suppressed", false, "Include the notes that are in the baseline, suppressed inline or suppressed by an override in the text and JSON output, marked as suppressed. They do not count towards the exit status or the gates.")
+
 	keyFlags = []string{"analyzer_images", "build", "categories", "inside_docker", "event", "json_output", 
-		"repo", "stay_up", "tag", "local_kythe"}
+		"repo", "stay_up", "tag", "local_kythe", "webhook_url", "upload", "history_db", "max_notes",
+		"max_notes_per_category", "ratchet", "attribute", "time_budget", "tier", "analyzer_parallelism",
+		"docker_access", "host_workspace_root", "idle_timeout", "follow_symlinks", "dry_run",
+		"coverage_report", "baseline", "format", "attestation", "attestation_key",
+		"service_socket_dir", "batch_size", "force", "ignore_lock", "full_descriptions",
+		"deterministic", "diagnose", "stages", "fail_fast", "fail_fast_severity", "quiet",
+		"strict_sandbox", "max_image_cache", "stream_analyzer_output",
+		"export_compilations", "import_compilations", "paths", "transcode_dir",
+		"validate_output", "run_id", "show_suppressed", "lock_wait"}
 )
 
+// subcommands are invoked as `shipshape <command> [flags]` instead of
+// running an analysis.
+var subcommands = map[string]func(args []string) int{
+	"analyzers":      analyzersCommand,
+	"batch":          batchCommand,
+	"bench":          benchCommand,
+	"cache":          cacheCommand,
+	"convert":        convertCommand,
+	"diff":           diffCommand,
+	"doctor":         doctorCommand,
+	"explain":        explainCommand,
+	"export":         exportCommand,
+	"fix":            fixCommand,
+	"outdated":       outdatedCommand,
+	"report-fp":      reportFPCommand,
+	"run":            runCommand,
+	"schema":         schemaCommand,
+	"serve-webhooks": serveWebhooksCommand,
+	"trend":          trendCommand,
+	"triage":         triageCommand,
+	"telemetry":      telemetryCommand,
+	"tui":            tuiCommand,
+	"update":         updateCommand,
+	"verify":         verifyCommand,
+}
+
 const (
 	returnNoFindings = 0
 	returnFindings   = 1
@@ -61,8 +169,16 @@ func shipshapeUsage() {
 	for _, flag := range keyFlags {
 		shipshapeArgs[flag] = true
 	}
-	fmt.Println("USAGE: shipshape [flags] <directory>")
-	fmt.Println("Shipshape flags: (for all flags, run shipshape -help)")
+	printf("USAGE: shipshape [flags] <directory>\n")
+	printf("       shipshape run <pipeline> [flags] [<directory>]\n")
+	printf("       shipshape <command> [flags]\n")
+	var commands []string
+	for name := range subcommands {
+		commands = append(commands, name)
+	}
+	sort.Strings(commands)
+	printf("Commands: %s\n", strings.Join(commands, ", "))
+	printf("Shipshape flags: (for all flags, run shipshape -help)\n")
 	flag.VisitAll(func (f *flag.Flag) {
 		_, isShipshapeArg := shipshapeArgs[f.Name]
 		if (!isShipshapeArg) {
@@ -72,21 +188,56 @@ func shipshapeUsage() {
 		if (defValue == "") {
 			defValue = "\"\""
 		}
-		fmt.Printf("  -%s:\n\t %s (default: %s)\n", f.Name, f.Usage, defValue)
+		printf("  -%s:\n\t %s (default: %s)\n", f.Name, cli.Messages.Text(f.Usage), defValue)
 	})
 }
 
-func outputAsText(msg *rpcpb.ShipshapeResponse, directory string) error {
+// diagnose writes a diagnostics bundle for the run of invocation, which ended
+// with err, to --diagnose. If the run failed because of docker, the containers
+// or the service and --diagnose_on_error is set, the bundle is written to a
+// temporary file otherwise. The webhook URL is a credential, so it is redacted
+// from the bundle, as are the upload locations.
+func diagnose(invocation *cli.Invocation, err error) {
+	path := *diagnosePath
+	if path == "" {
+		if !*diagnoseOnError || !invocation.InfrastructureFailed(err) {
+			return
+		}
+		path = filepath.Join(os.TempDir(), fmt.Sprintf("shipshape-diagnostics-%d.tar.gz", time.Now().Unix()))
+	}
+	redact.Register(*webhookURL, *upload)
+	var flags []string
+	flag.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, f.Name+"="+f.Value.String())
+	})
+	if err := cli.WriteDiagnostics(path, invocation.Diagnostics(os.Args, flags, err)); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not write diagnostics: %v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Diagnostics written to %s. Please attach this file to bug reports.\n", path)
+}
+
+func outputAsText(msg *rpcpb.ShipshapeResponse, directory string, required map[string]bool, owners *cli.Ownership, truncator *cli.DescriptionTruncator, renderer *cli.PathRenderer) error {
 	// TODO(ciera): these results aren't sorted. They should be sorted by path and start line
 	fileNotes := make(map[string][]*notepb.Note)
 	for _, analysis := range msg.AnalyzeResponse {
+		for _, retry := range analysis.Retry {
+			printf("NOTE: Analyzer %s failed and was retried (%d attempts)\n", retry.GetCategory(), retry.GetAttempts())
+		}
+		for _, restart := range analysis.Restart {
+			printf("NOTE: Analyzer %s stopped responding and was restarted: %s\n", restart.GetCategory(), restart.GetReason())
+		}
 		for _, failure := range analysis.Failure {
-			fmt.Printf("WARNING: Analyzer %s failed to run: %s\n", *failure.Category, *failure.FailureMessage)
+			if required[failure.GetCategory()] {
+				printf("ERROR: Required analyzer %s failed to run: %s\n", *failure.Category, *failure.FailureMessage)
+			} else {
+				printf("WARNING: Analyzer %s failed to run: %s\n", *failure.Category, *failure.FailureMessage)
+			}
 		}
-		for _, note := range analysis.Note {
+		for _, note := range append(analysis.Note, analysis.SuppressedNote...) {
 			path := ""
 			if note.Location != nil {
-				path = filepath.Join(directory, note.Location.GetPath())
+				path = renderer.Render(directory, note.Location.GetPath())
 			}
 			fileNotes[path] = append(fileNotes[path], note)
 		}
@@ -94,9 +245,9 @@ func outputAsText(msg *rpcpb.ShipshapeResponse, directory string) error {
 
 	for path, notes := range fileNotes {
 		if path != "" {
-			fmt.Println(path)
+			fmt.Println(text(path))
 		} else {
-			fmt.Println("Global")
+			fmt.Println(cli.Messages.Text("Global"))
 		}
 		for _, note := range notes {
 			loc := ""
@@ -104,30 +255,339 @@ func outputAsText(msg *rpcpb.ShipshapeResponse, directory string) error {
 			if note.Subcategory != nil {
 				subCat = ":" + *note.Subcategory
 			}
-			if note.GetLocation().Range != nil && note.GetLocation().GetRange().StartLine != nil {
-				if note.GetLocation().GetRange().StartColumn != nil {
-					loc = fmt.Sprintf("Line %d, Col %d ", *note.Location.Range.StartLine, *note.Location.Range.StartColumn)
-				} else {
-					loc = fmt.Sprintf("Line %d ", *note.Location.Range.StartLine)
-				}
+			if r := cli.FormatRange(note.GetLocation().GetRange()); r != "" {
+				loc = r + " "
 			}
 
-			fmt.Printf("%s[%s%s]\n", loc, *note.Category, subCat)
-			fmt.Printf("\t%s\n", *note.Description)
+			if how := cli.DescribeSuppression(note); how != "" {
+				fmt.Printf("%s[%s%s] (%s)\n", loc, text(*note.Category), text(subCat), text(how))
+			} else {
+				fmt.Printf("%s[%s%s]\n", loc, text(*note.Category), text(subCat))
+			}
+			fmt.Printf("\t%s\n", text(truncator.Truncate(note.GetDescription())))
+			if url := note.GetUrl(); url != "" {
+				printf("\tSee %s\n", url)
+			}
+			if owner := owners.Owner(note.GetCategory()); owner != nil {
+				printf("\tThe %s analyzer is %s.\n", note.GetCategory(), cli.Messages.MaintainedBy(owner))
+			}
+			if attr := note.GetAttribution(); attr != nil {
+				var who []string
+				if attr.Author != nil {
+					who = append(who, cli.Messages.Sprintf("Author: %s", attr.GetAuthor()))
+				}
+				if len(attr.Owner) > 0 {
+					who = append(who, cli.Messages.Sprintf("Owners: %s", strings.Join(attr.Owner, ", ")))
+				}
+				fmt.Printf("\t%s\n", text(strings.Join(who, "; ")))
+			}
 		}
 		fmt.Println()
 	}
 	return nil
 }
 
+// attest writes a signed attestation that path was analyzed by invocation,
+// which ran categories and found resp.
+func attest(invocation *cli.Invocation, resp *rpcpb.ShipshapeResponse, path string, categories []string, started time.Time, key crypto.Signer) error {
+	run := cli.AttestedRun{
+		Repository: path,
+		Commit:     cli.GetRunMetadata(path).Commit,
+		Dirty:      cli.WorkTreeDirty(path),
+		Images:     make(map[string]string),
+		Event:      *event,
+		Categories: categories,
+		RunID:      invocation.RunID(),
+		StartedOn:  started,
+		FinishedOn: time.Now(),
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		run.Repository = abs
+	}
+	if rev := cli.GetRevisionDetails(path); rev != nil && rev.GetRemoteUrl() != "" {
+		run.Repository = rev.GetRemoteUrl()
+	}
+	for _, image := range invocation.Images() {
+		if digest := docker.ImageDigest(image); digest != "" {
+			run.Images[image] = digest
+		} else {
+			glog.Warningf("Could not find the digest of %s; leaving it out of the attestation", image)
+		}
+	}
+	config, err := ioutil.ReadFile(filepath.Join(configDir(path), ".shipshape"))
+	if err == nil {
+		run.Config = config
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	statement := cli.NewStatement(run, resp)
+	if len(statement.Subject) == 0 {
+		glog.Warningf("%s is not in a git repository; the attestation has no subject", path)
+	}
+	envelope, err := cli.Sign(statement, key)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(envelope, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(*attestation, b, 0644)
+}
+
+// printf prints a message for people, translated into the locale of the CLI.
+func printf(format string, a ...interface{}) {
+	fmt.Print(text(cli.Messages.Sprintf(format, a...)))
+}
+
+// text returns s as it should be printed: transliterated to ASCII with
+// --no_unicode, and as it is otherwise.
+func text(s string) string {
+	if *noUnicode {
+		return cli.ASCII(s)
+	}
+	return s
+}
+
+// setLocale makes the CLI print its messages in locale, or in the locale of
+// the environment if that is empty.
+func setLocale(locale string) {
+	messages, err := cli.LoadMessages(cli.TelemetryDir(), cli.Locale(locale, os.Getenv))
+	if err != nil {
+		glog.Warningf("Could not load the messages: %v", err)
+	}
+	cli.Messages = messages
+}
+
+// openNote opens path at line and col in the editor given by --open, waiting
+// for it to exit; a terminal editor gets the terminal until then.
+func openNote(path string, line, col int) error {
+	args := cli.OpenCommand(*openEditor, path, line, col)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// textOutput reports whether notes and messages about them are printed as
+// text, rather than written to a JSON file or printed as CSV or TSV.
+func textOutput() bool {
+	return !*quiet && *jsonOutput == "" && *format == "text"
+}
+
+// writeNotes prints all the notes found in directory as CSV or TSV, if --format
+// asks for it, with their paths as renderer shows them.
+func writeNotes(resp *rpcpb.ShipshapeResponse, directory string, renderer *cli.PathRenderer) error {
+	if *quiet {
+		return nil
+	}
+	switch *format {
+	case "csv":
+		return cli.WriteCSV(os.Stdout, renderer.RenderNotes(directory, cli.AllNotes(resp)))
+	case "tsv":
+		return cli.WriteTSV(os.Stdout, renderer.RenderNotes(directory, cli.AllNotes(resp)))
+	}
+	return nil
+}
+
+// configDir returns the directory whose configuration file applies to path.
+func configDir(path string) string {
+	if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+		return filepath.Dir(path)
+	}
+	return path
+}
+
+// printEvents prints the built-in events and the custom events declared in
+// the configuration file in dir.
+func printEvents(dir string) error {
+	custom, err := service.CustomEvents(dir)
+	if err != nil {
+		return err
+	}
+	printf("Built-in events:\n")
+	for _, e := range service.BuiltInEvents {
+		printf("  %-12s %s\n", e.Name, cli.Messages.Text(e.Description))
+	}
+	if len(custom) > 0 {
+		printf("Custom events declared in %s:\n", filepath.Join(dir, ".shipshape"))
+		for _, e := range custom {
+			fmt.Printf("  %s\n", e)
+		}
+	}
+	return nil
+}
+
+// gate checks the results against the policies in the configuration file
+// and the note thresholds given on the command line. It prints each check
+// that failed, and reports whether any checks were configured and whether
+// they all passed. ran is the list of categories that were run, if known.
+func gate(resp *rpcpb.ShipshapeResponse, dir string, ran []string) (gated, passed bool, err error) {
+	notes := cli.AllNotes(resp)
+	counts := cli.CountByCategory(notes)
+	var failures []string
+
+	policies, err := service.Policies(dir)
+	if err != nil {
+		return false, false, fmt.Errorf("could not load policies: %v", err)
+	}
+	for _, v := range cli.EvaluatePolicies(policies, notes) {
+		name := v.Policy.GetName()
+		if name == "" {
+			name = v.Policy.String()
+		}
+		failures = append(failures, cli.Messages.Sprintf("policy %q violated (%d notes, %d allowed)", name, len(v.Notes), v.Policy.GetAllowed()))
+	}
+
+	perCategory, err := cli.ParseCategoryLimits(*maxNotesPerCategory)
+	if err != nil {
+		return false, false, err
+	}
+	failures = append(failures, cli.CheckThresholds(counts, *maxNotes, perCategory)...)
+
+	var ratchet *cli.Ratchet
+	if *ratchetFile != "" {
+		if ratchet, err = cli.LoadRatchet(*ratchetFile); err != nil {
+			return false, false, err
+		}
+		failures = append(failures, ratchet.Check(counts)...)
+	}
+
+	if !*quiet {
+		for _, f := range failures {
+			printf("FAILED: %s\n", f)
+		}
+	}
+	passed = len(failures) == 0
+	// Only tighten the ratchet on success, so a regression is reported again
+	// on the next run instead of becoming the new baseline.
+	if ratchet != nil && passed {
+		ratchet.Update(counts, ran)
+		if err := ratchet.Save(*ratchetFile); err != nil {
+			return false, false, fmt.Errorf("could not save ratchet: %v", err)
+		}
+	}
+	gated = len(policies) > 0 || *maxNotes >= 0 || len(perCategory) > 0 || ratchet != nil
+	return gated, passed, nil
+}
+
+// withoutFailed returns the categories that do not have a failure.
+func withoutFailed(categories []string, failures []*rpcpb.AnalysisFailure) []string {
+	failed := make(map[string]bool)
+	for _, f := range failures {
+		failed[f.GetCategory()] = true
+	}
+	var ok []string
+	for _, c := range categories {
+		if !failed[c] {
+			ok = append(ok, c)
+		}
+	}
+	return ok
+}
+
+// writeCoverage writes the coverage report for the results of a run on path, which
+// was expected to run categories and found the encodings in encodings, if it is not
+// nil, to --coverage_report.
+func writeCoverage(resp *rpcpb.ShipshapeResponse, path string, categories []string, encodings *cli.EncodingReport) error {
+	var files []string
+	if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+		files = []string{filepath.Base(path)}
+	}
+	files, err := service.FilesToAnalyze(configDir(path), files)
+	if err != nil {
+		return fmt.Errorf("could not list the analyzed files: %v", err)
+	}
+	report := cli.Coverage(resp, files, categories)
+	if encodings != nil {
+		report.Undecodable = encodings.Undecodable
+	}
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(*coverageReport, b, 0644); err != nil {
+		return err
+	}
+	if len(report.Skipped) > 0 && textOutput() {
+		printf("NOTE: %d of %d files were not processed by any category; see %s\n", len(report.Skipped), len(files), *coverageReport)
+	}
+	if len(report.Undecodable) > 0 && textOutput() {
+		printf("NOTE: %d files are in an unknown encoding, so their notes may be wrong; see %s\n", len(report.Undecodable), *coverageReport)
+	}
+	return nil
+}
+
+// publish posts a summary of the results to the configured webhook.
+func publish(resp *rpcpb.ShipshapeResponse, directory string) error {
+	tmpl := ""
+	if *webhookTemplate != "" {
+		b, err := ioutil.ReadFile(*webhookTemplate)
+		if err != nil {
+			return fmt.Errorf("could not read webhook template: %v", err)
+		}
+		tmpl = string(b)
+	}
+	p, err := cli.NewWebhookPublisher(*webhookURL, *webhookChannel, tmpl)
+	if err != nil {
+		return err
+	}
+	summary := cli.Summarize(resp, directory, 5)
+	summary.ArtifactURL = *artifactURL
+	return p.Publish(summary)
+}
+
 func main() {
+	setLocale("")
+	if len(os.Args) > 1 {
+		if command, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
 	flag.Parse()
+	if *locale != "" {
+		setLocale(*locale)
+	}
+
+	if *listEvents {
+		path := "."
+		if flag.NArg() > 0 {
+			path = flag.Arg(0)
+		}
+		if err := printEvents(configDir(path)); err != nil {
+			printf("Error: %v\n", err)
+			os.Exit(returnError)
+		}
+		os.Exit(returnNoFindings)
+	}
 
 	// Get the file/directory to analyze.
 	if len(flag.Args()) != 1 {
 		shipshapeUsage()
 		os.Exit(returnError)
 	}
+	os.Exit(analyze(flag.Arg(0)))
+}
+
+// analyze runs an analysis of file, a file or directory, with the settings
+// given by the flags, and returns the exit status.
+func analyze(file string) int {
+	if *dockerAccess != "dind" && *dockerAccess != "socket" {
+		printf("Error: unknown --docker_access %q (must be dind or socket)\n", *dockerAccess)
+		return returnError
+	}
+	if *format != "text" && *format != "csv" && *format != "tsv" {
+		printf("Error: unknown --format %q (must be text, csv or tsv)\n", *format)
+		return returnError
+	}
+	if *openEditor != "" && !cli.IsEditor(*openEditor) {
+		printf("Error: unknown --open %q (must be %s)\n", *openEditor, strings.Join(cli.Editors, ", "))
+		return returnError
+	}
+	renderer, err := cli.NewPathRenderer(*paths)
+	if err != nil {
+		printf("Error: %v\n", err)
+		return returnError
+	}
 
 	thirdPartyAnalyzers := []string{}
 	if *analyzerImages != "" {
@@ -137,44 +597,359 @@ func main() {
 	if *categories != "" {
 		cats = strings.Split(*categories, ",")
 	}
+	var stageList []ctxpb.Stage
+	if *stages != "" {
+		var err error
+		if stageList, err = cli.ParseStages(strings.Split(*stages, ",")); err != nil {
+			printf("Error: %v\n", err)
+			return returnError
+		}
+	}
+
+	var imageCache int64
+	if *maxImageCache != "" {
+		var err error
+		if imageCache, err = cli.ParseSize(*maxImageCache); err != nil {
+			printf("Error: invalid --max_image_cache: %v\n", err)
+			return returnError
+		}
+	}
+
+	severity, ok := notepb.Note_Severity_value[*failFastSeverity]
+	if !ok {
+		printf("Error: unknown --fail_fast_severity %q (must be BUILD_ERROR, WARNING or OTHER)\n", *failFastSeverity)
+		return returnError
+	}
 
 	options := cli.Options{
-		File:                flag.Arg(0),
+		File:                file,
 		ThirdPartyAnalyzers: thirdPartyAnalyzers,
 		Build:               *build,
 		TriggerCats:         cats,
 		Dind:                *dind,
+		DockerSocket:        *dockerAccess == "socket",
+		StrictSandbox:       *strictSandbox,
+		HostWorkspaceRoot:   *hostRoot,
 		Event:               *event,
 		Repo:                *repo,
 		StayUp:              *stayUp,
 		Tag:                 *tag,
 		LocalKythe:          *useLocalKythe,
+		ExportCompilations:  *exportUnits,
+		ImportCompilations:  *importUnits,
+		TimeBudget:          *timeBudget,
+		AnalyzerParallelism: *analyzerParallelism,
+		BatchSize:           *batchSize,
+		IdleTimeout:         *idleTimeout,
+		FollowSymlinks:      *followSymlinks,
+		RunID:               *runID,
+		SnapshotDir:         *snapshotDir,
+		LockDir:             filepath.Join(cli.TelemetryDir(), "locks"),
+		LockWait:            *lockWait,
+		TranscodeDir:        *transcodeDir,
+		ImageUseFile:        cli.DefaultImageUseFile,
+		MaxImageCache:       imageCache,
+		Force:               *force,
+		IgnoreLock:          *ignoreLock,
+		Deterministic:       *deterministic,
+		Stages:              stageList,
+		FailFast:            *failFast,
+		FailFastSeverity:    notepb.Note_Severity(severity),
+	}
+	if *socketDir != "" {
+		abs, err := filepath.Abs(*socketDir)
+		if err != nil {
+			printf("Error: %v\n", err)
+			return returnError
+		}
+		if err := os.MkdirAll(abs, 0755); err != nil {
+			printf("Error: could not create --service_socket_dir: %v\n", err)
+			return returnError
+		}
+		options.SocketDir = abs
+	}
+	dir := configDir(options.File)
+	analyzers, err := service.AnalyzerConfigs(dir)
+	if err != nil {
+		printf("Error: %v\n", err)
+		return returnError
+	}
+	required := cli.RequiredCategories(analyzers)
+	overrides, err := service.Overrides(dir)
+	if err != nil {
+		printf("Error: %v\n", err)
+		return returnError
+	}
+	ownerConfig, err := service.Owners(dir)
+	if err != nil {
+		printf("Error: %v\n", err)
+		return returnError
+	}
+	owners := cli.NewOwnership(ownerConfig)
+	expected := cats
+	if len(expected) == 0 {
+		expected, _ = service.EventCategories(dir, *event)
+	}
+	if *tier != "" {
+		if *tier != service.TierFast && *tier != service.TierSlow {
+			printf("Error: unknown tier %q (must be %s or %s)\n", *tier, service.TierFast, service.TierSlow)
+			return returnError
+		}
+		expected = cli.CategoriesInTier(expected, analyzers, *tier)
+		if len(expected) == 0 {
+			printf("No categories in the %s tier to run\n", *tier)
+			return returnNoFindings
+		}
+		options.TriggerCats = expected
+	}
+	if *dryRun {
+		plan, err := cli.New(options).Plan()
+		if err != nil {
+			printf("Error: %v\n", err)
+			return returnError
+		}
+		fmt.Print(cli.FormatPlan(plan))
+		return returnNoFindings
 	}
-	if *jsonOutput == "" {
-		options.HandleResponse = outputAsText
-	} else {
-		var allResponses rpcpb.ShipshapeResponse
-		options.HandleResponse = func(msg *rpcpb.ShipshapeResponse, _ string) error {
-			allResponses.AnalyzeResponse = append(allResponses.AnalyzeResponse, msg.AnalyzeResponse...)
-			return nil
+
+	var signingKey crypto.Signer
+	if *attestation != "" {
+		if *attestationKey == "" {
+			printf("Error: --attestation requires --attestation_key\n")
+			return returnError
+		}
+		if signingKey, err = cli.LoadSigningKey(*attestationKey); err != nil {
+			printf("Error: %v\n", err)
+			return returnError
+		}
+	}
+	started := time.Now()
+
+	var allResponses rpcpb.ShipshapeResponse
+	attributors := make(map[string]*cli.Attributor)
+	// openPath is where the first note to open with --open is.
+	var openPath string
+	var openLine, openCol int
+	deduper := cli.NewDeduper()
+	baseline, err := cli.LoadBaseline(*baselinePath)
+	if err != nil {
+		printf("Error: %v\n", err)
+		return returnError
+	}
+	inline := cli.NewInlineSuppressor()
+	suppressions := cli.NewSuppressions(*showSuppressed)
+	overrider := cli.NewOverrider(overrides)
+	truncator := &cli.DescriptionTruncator{Max: *maxDescription}
+	if *fullDescriptions {
+		truncator.Max = 0
+	}
+	var bar *cli.ProgressBar
+	if *streamOutput {
+		options.AnalyzerOutput = os.Stderr
+	} else if *showProgress && textOutput() && cli.IsTerminal(os.Stderr) {
+		bar = cli.NewProgressBar(os.Stderr)
+		options.HandleProgress = bar.Update
+	}
+	options.HandleResponse = func(msg *rpcpb.ShipshapeResponse, directory string) error {
+		if bar != nil {
+			bar.Clear()
+		}
+		if *attribute {
+			a, ok := attributors[directory]
+			if !ok {
+				var err error
+				if a, err = cli.NewAttributor(directory); err != nil {
+					return err
+				}
+				attributors[directory] = a
+			}
+			a.Attribute(msg)
+		}
+		suppressions.Filter(msg, cli.SuppressedByOverride, overrider.Suppresses)
+		overrider.Filter(msg)
+		deduper.Filter(msg)
+		if *baselinePath != "" {
+			suppressions.Filter(msg, cli.SuppressedByBaseline, baseline.Contains)
 		}
-		options.ResponsesDone = func() error {
+		suppressions.Filter(msg, cli.SuppressedInline, func(note *notepb.Note) bool { return inline.Suppressed(note, directory) })
+		allResponses.AnalyzeResponse = append(allResponses.AnalyzeResponse, msg.AnalyzeResponse...)
+		if *openEditor != "" && openPath == "" {
+			openPath, openLine, openCol, _ = cli.FirstLocation(msg, directory)
+		}
+		if textOutput() {
+			return outputAsText(msg, directory, required, owners, truncator, renderer)
+		}
+		return nil
+	}
+	var streamErr error
+	options.StreamInterrupted = func(err error) {
+		streamErr = err
+	}
+	var invocation *cli.Invocation
+	options.ResponsesDone = func() error {
+		stoppedErr := streamErr
+		if stoppedErr == nil && invocation.FailedFast() {
+			stoppedErr = fmt.Errorf("stopped at the first note at least as severe as %s, for --fail_fast", *failFastSeverity)
+		}
+		cli.MarkIncomplete(&allResponses, expected, stoppedErr)
+		if xrefs := invocation.Xrefs(); xrefs != nil {
+			glog.Infof("Found the symbols of %d notes in the cross-references", xrefs.Enrich(&allResponses))
+		}
+		if err := cli.ConvertSchema(&allResponses, cli.CurrentSchema); err != nil {
+			return err
+		}
+		allResponses.Owner = owners.Owners(&allResponses)
+		allResponses.RunInfo = cli.NewRunInfo(invocation.RunID(), os.Args, options.File, invocation.Images(), docker.ImageDigest, started)
+		if n := deduper.Duplicates(); n > 0 {
+			allResponses.DuplicateNotes = proto.Int32(int32(n))
+			if textOutput() {
+				printf("NOTE: Left out %d duplicate notes\n", n)
+			}
+		}
+		allResponses.Suppressed = suppressions.Counts()
+		if n := suppressions.Total(); n > 0 && textOutput() {
+			if *showSuppressed {
+				printf("NOTE: %d notes are marked as suppressed: %s\n", n, cli.DescribeSuppressions(allResponses.Suppressed))
+			} else {
+				printf("NOTE: Left out %d suppressed notes: %s. Pass --show_suppressed to show them.\n", n, cli.DescribeSuppressions(allResponses.Suppressed))
+			}
+		}
+		if efforts := cli.CountEfforts(cli.AllNotes(&allResponses)); efforts != nil && textOutput() {
+			printf("NOTE: By effort to fix: %s\n", cli.DescribeEfforts(efforts))
+		}
+		if n := truncator.Truncated(); n > 0 && textOutput() {
+			printf("NOTE: Truncated %d long descriptions; pass --full_descriptions to show them in full\n", n)
+		}
+		if inc := allResponses.Incomplete; inc != nil && textOutput() {
+			printf("WARNING: These results are incomplete: %s\n", inc.GetReason())
+			if len(inc.Category) > 0 {
+				printf("Categories that may be missing notes: %s\n", strings.Join(inc.Category, ", "))
+			}
+		}
+		if err := writeNotes(&allResponses, configDir(options.File), renderer); err != nil {
+			return err
+		}
+		if *coverageReport != "" {
+			if err := writeCoverage(&allResponses, options.File, expected, invocation.Encodings()); err != nil {
+				return err
+			}
+		}
+		jsonPath := *jsonOutput
+		if jsonPath == "" && *upload != "" {
+			dir, err := ioutil.TempDir("", "shipshape")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(dir)
+			jsonPath = filepath.Join(dir, "shipshape.json")
+		}
+		if jsonPath != "" || *validateOutput {
 			// TODO(ciera): these results aren't sorted. They should be sorted by path and start line
 			b, err := json.Marshal(allResponses)
 			if err != nil {
 				return err
 			}
-			return ioutil.WriteFile(*jsonOutput, b, 0644)
+			if *validateOutput {
+				if err := cli.ValidateOutput(b); err != nil {
+					return fmt.Errorf("the JSON results do not match their schema (see shipshape schema): %v", err)
+				}
+			}
+			if jsonPath != "" {
+				if err := ioutil.WriteFile(jsonPath, b, 0644); err != nil {
+					return err
+				}
+			}
 		}
+		md := cli.GetRunMetadata(options.File)
+		if *historyDB != "" {
+			if err := cli.OpenHistory(*historyDB).Record(md, &allResponses); err != nil {
+				return err
+			}
+		}
+		if *upload != "" {
+			if err := cli.Upload(*upload, []string{jsonPath}, md); err != nil {
+				return err
+			}
+		}
+		if *webhookURL != "" {
+			// The results are already out, so a webhook that is down doesn't
+			// fail the run.
+			if err := publish(&allResponses, options.File); err != nil {
+				printf("WARNING: Could not publish the summary: %v\n", err)
+			}
+		}
+		return nil
 	}
 
-	numResults, err := cli.New(options).Run()
+	stopProfiles, err := startProfiles()
 	if err != nil {
-		fmt.Printf("Error: %v", err.Error())
-		os.Exit(returnError)
+		printf("Error: %v\n", err)
+		return returnError
+	}
+	invocation = cli.New(options)
+	numResults, err := invocation.Run()
+	stopProfiles()
+	if bar != nil {
+		bar.Clear()
+	}
+	diagnose(invocation, err)
+	recordTelemetry(expected, options.Build != "", started, numResults, err, &allResponses)
+	if err != nil {
+		printf("Error: %v\n", err)
+		return returnError
+	}
+	if *quiet {
+		fmt.Println(cli.SummaryLine(cli.Summarize(&allResponses, options.File, 0), time.Since(started)))
+	}
+	if since, ok := invocation.UnchangedSince(); ok && textOutput() {
+		printf("NOTE: Nothing changed since the run at %s, so these are its results. Pass --force to analyze again.\n", since.Local().Format(time.RFC1123))
+	}
+	if openPath != "" {
+		if err := openNote(openPath, openLine, openCol); err != nil {
+			printf("WARNING: Could not open %s in %s: %v\n", openPath, *openEditor, err)
+		}
+	}
+	if signingKey != nil {
+		if err := attest(invocation, &allResponses, options.File, expected, started, signingKey); err != nil {
+			printf("Error: %v\n", err)
+			return returnError
+		}
+	}
+	// A note that stops the analysis fails the run whatever the gates say, and the
+	// ratchet must not be tightened to the counts of a partial run.
+	if invocation.FailedFast() {
+		if !*quiet {
+			printf("FAILED: found a note at least as severe as %s (--fail_fast)\n", *failFastSeverity)
+		}
+		return returnFindings
+	}
+	requiredFailures, otherFailures := cli.Failures(&allResponses, required)
+	if len(requiredFailures) > 0 {
+		var names []string
+		for _, f := range requiredFailures {
+			names = append(names, f.GetCategory())
+		}
+		printf("Error: required analyzers failed to run: %s\n", strings.Join(names, ", "))
+		return returnError
+	}
+	// Analyzers that failed did not really run, so their categories must
+	// not be treated as clean.
+	ran := withoutFailed(expected, otherFailures)
+	gated, passed, err := gate(&allResponses, dir, ran)
+	if err != nil {
+		printf("Error: %v\n", err)
+		return returnError
+	}
+	// When the results are gated, the gates decide the exit status rather
+	// than whether there were any notes at all.
+	if gated {
+		if !passed {
+			return returnFindings
+		}
+		return returnNoFindings
 	}
 	if numResults != 0 {
-		os.Exit(returnFindings)
+		return returnFindings
 	}
-	os.Exit(returnNoFindings)
+	return returnNoFindings
 }
@@ -21,9 +21,15 @@
 package cli
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -32,9 +38,11 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/google/shipshape/shipshape/service"
 	"github.com/google/shipshape/shipshape/util/docker"
+	"github.com/google/shipshape/shipshape/util/redact"
 	"github.com/google/shipshape/shipshape/util/rpc/client"
 	glog "github.com/google/shipshape/third_party/go-glog"
 
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
 	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
 	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
 )
@@ -45,6 +53,12 @@ const (
 	localLogs  = "/tmp"
 	image      = "service"
 	kytheImage = "kythe"
+	// heartbeat is how often the service is asked to show that it is still
+	// analyzing while no results are ready.
+	heartbeat = 30 * time.Second
+	// servicePort is the host port that the shipshape service is published on,
+	// unless another process is listening on it.
+	servicePort = 10007
 )
 
 type Options struct {
@@ -59,40 +73,245 @@ type Options struct {
 	StayUp      bool
 	Tag         string
 	LocalKythe  bool
+	// ExportCompilations, if set, is a directory that the compilation units that the
+	// kythe image extracts from the build are copied to once the POST_BUILD stage
+	// has them, for other tools or later runs to use.
+	ExportCompilations string
+	// ImportCompilations, if set, is a directory of compilation units, e.g. exported
+	// by an earlier run, that the POST_BUILD stage analyzes instead of extracting them
+	// from the build with the kythe image.
+	ImportCompilations string
+	// LocalAnalyzers run in the process of the CLI at their stage, after the service
+	// has analyzed it. Like the analyzers in containers, they only run if their
+	// categories are triggered, and their results are handled like the service's.
+	LocalAnalyzers []LocalAnalyzer
+	// TranscodeDir, if set, is where a copy of the workspace is staged when some of
+	// the files to analyze are Latin-1, UTF-16 or UTF-8 with a byte order mark. The
+	// analyzers see the copy, with those files converted to plain UTF-8, and the
+	// offsets and fixes of their notes are mapped back to the originals.
+	TranscodeDir string
+	// RunID identifies the run in the CLI's logs, on the containers it starts, in its
+	// requests to the service and in its results. If empty, New generates one.
+	RunID string
+	// ColdStart stops the containers of earlier runs before starting new ones, instead of
+	// reusing them.
+	ColdStart bool
+	// DockerSocket mounts the host's docker socket into the containers that
+	// shipshape starts, instead of running them privileged when Dind is set.
+	DockerSocket bool
+	// StrictSandbox runs every third-party analyzer in at least docker.StrictSandbox, on top of
+	// any sandbox configured for it. Sandboxed analyzers get no access to docker.
+	StrictSandbox bool
+	// HostWorkspaceRoot is the path on the docker host of the directory being
+	// analyzed, if the CLI sees it at a different path (e.g. because the CLI
+	// runs in a container). If empty and the CLI runs in a container that
+	// shares the host's docker socket, it is found from the container's mounts.
+	HostWorkspaceRoot string
+	// TimeBudget limits how long the service may spend analyzing, if it is not zero.
+	TimeBudget time.Duration
+	// AnalyzerParallelism limits how many analyzers the service runs at once, if it is not zero.
+	AnalyzerParallelism int
+	// IdleTimeout gives up on the service if it sends nothing, not even a heartbeat, for this
+	// long, if it is not zero.
+	IdleTimeout time.Duration
+	// FailFast stops the analysis as soon as a note at least as severe as FailFastSeverity
+	// is handled, once HandleResponse has left out any suppressed notes. The results of each
+	// analyzer are then streamed from the service as soon as they are ready, and Run returns
+	// the notes handled before it stopped; FailedFast reports whether it did.
+	FailFast         bool
+	FailFastSeverity notepb.Note_Severity
+	// BatchSize, if it is not zero, splits the files of a directory into batches of this
+	// many, which are analyzed in separate requests to the service, one after the other.
+	BatchSize int
+	// SnapshotDir, if set, is where the state and results of each run are recorded. A run
+	// whose state is the same as that of the last run on the same target shows the results
+	// of that run instead of analyzing again, unless Force is set.
+	SnapshotDir string
+	Force       bool
+	// LockDir, if set, is where the lock files of docker daemons are kept. A run holds
+	// the lock of its daemon while it runs, and a second run on the same daemon, on any
+	// workspace, waits up to LockWait for the first to finish, or fails straight away if
+	// LockWait is 0.
+	LockDir  string
+	LockWait time.Duration
+	// ImageUseFile, if set, is where the time each image was last used by a run is
+	// recorded. If MaxImageCache is not zero, the least recently used images in the
+	// record, other than those of the run, are removed before pulling until the
+	// recorded images take at most MaxImageCache bytes.
+	ImageUseFile  string
+	MaxImageCache int64
+	// IgnoreLock uses the latest versions of the images even if the directory has a
+	// .shipshape.lock file that pins them.
+	IgnoreLock bool
+	// FollowSymlinks analyzes the file or directory that File links to, so
+	// that the workspace mapped into the containers and the paths of the notes
+	// are canonical.
+	FollowSymlinks bool
+	// Deterministic makes the built-in analyzers ignore any configuration of theirs
+	// in the workspace (e.g. a pylintrc) and use the fixed configuration in the
+	// service image instead, so that the results only depend on the code and the
+	// image. Tests use it so that they don't break when the configuration changes.
+	Deterministic bool
+	// Stages are the stages to analyze, in order. If empty, the PRE_BUILD stage is
+	// analyzed, followed by the POST_BUILD one if Build or ImportCompilations is set.
+	Stages []ctxpb.Stage
+	// SocketDir, if set, is a directory that is mounted into the service's container, and
+	// that the service listens on a unix socket in instead of publishing port 10007. It must
+	// be absolute.
+	SocketDir string
 	// Directory has the path the analyzed file is in (msg.AnalyzeResponse.Note.Location.GetPath()
 	// contains only the basename). HandleResponse can be called multiple times although the calls
 	// are not concurrent.
 	HandleResponse func(msg *rpcpb.ShipshapeResponse, directory string) error
-	ResponsesDone  func() error
+	// AnalyzerOutput, if set, is where what the third-party analyzer containers write to
+	// their stdout and stderr is streamed while they run, each line prefixed with the name
+	// of its container.
+	AnalyzerOutput io.Writer
+	// HandleProgress, if set, is called with the progress that the service reports while
+	// the analyzers run. It is not called concurrently with HandleResponse.
+	HandleProgress func(progress []*rpcpb.AnalysisProgress)
+	// StreamInterrupted is called if the stream of results from the service fails part way,
+	// so that the responses handled so far are incomplete. ResponsesDone is still called
+	// afterwards, and Run returns the error.
+	StreamInterrupted func(err error)
+	ResponsesDone     func() error
 }
 
+// streamError is an error from reading the stream of results from the service.
+type streamError struct {
+	err error
+}
+
+func (e streamError) Error() string {
+	return fmt.Sprintf("received an error from calling run: %v", e.err)
+}
+
+// errInterrupted is the streamError reason when the user interrupted the run.
+var errInterrupted = errors.New("interrupted, so the analysis was cancelled")
+
+// errFailFast is returned by handleResponse when a response has a note that stops the
+// analysis because of FailFast.
+var errFailFast = errors.New("found a note that fails the run, so the analysis was cancelled")
+
 type Invocation struct {
 	options Options
+	// images are the images that Run used.
+	images []string
+	// recorded are the responses that Run handled, for its snapshot.
+	recorded []*rpcpb.ShipshapeResponse
+	// unchangedSince is the time of the last run, if Run showed its results.
+	unchangedSince time.Time
+	// lock pins the images of the run, if there is a lock file.
+	lock *Lock
+	// timings are how long Run spent in each phase; the pulls are timed separately,
+	// since they happen in the background.
+	timings Timings
+	pulls   imagePulls
+	// request is the last request that Run sent to the service, and containers are
+	// the containers it started or reused, for a diagnostics bundle.
+	request    *rpcpb.ShipshapeRequest
+	containers []string
+	// failedFast is whether Run stopped at a note because of FailFast.
+	failedFast bool
+	// xrefs are the cross-references of the code from the kythe build, if there was one.
+	xrefs *XrefIndex
+	// subscriptions are sent the notes of their categories as Run receives them.
+	subscriptions []*subscription
+	// encodings describe the files of the workspace that are not plain UTF-8.
+	encodings *EncodingReport
+	// validated is whether Run got past checking the options and the config.
+	validated bool
 }
 
 func New(options Options) *Invocation {
-	return &Invocation{options}
+	if options.RunID == "" {
+		options.RunID = NewRunID()
+	}
+	return &Invocation{options: options}
+}
+
+// RunID returns the ID of the run.
+func (i *Invocation) RunID() string {
+	return i.options.RunID
+}
+
+// Images returns the images of the service, the analyzers and kythe that Run
+// used.
+func (i *Invocation) Images() []string {
+	return i.images
+}
+
+// Timings returns how long Run spent in each phase.
+func (i *Invocation) Timings() Timings {
+	t := i.timings
+	t.Pull = i.pulls.took()
+	return t
+}
+
+// UnchangedSince returns the time of the last run on the same target, and true, if Run
+// showed the results of that run because nothing had changed since.
+func (i *Invocation) UnchangedSince() (time.Time, bool) {
+	return i.unchangedSince, !i.unchangedSince.IsZero()
+}
+
+// FailedFast returns whether Run stopped the analysis at the first note at least as
+// severe as FailFastSeverity, so that the results are incomplete.
+func (i *Invocation) FailedFast() bool {
+	return i.failedFast
+}
+
+// Xrefs returns the cross-references of the code from the kythe build of the run,
+// or nil if there was none or it could not be indexed.
+func (i *Invocation) Xrefs() *XrefIndex {
+	return i.xrefs
+}
+
+// Encodings returns the files of the run that were transcoded for the analyzers
+// or that are in an unknown encoding, or nil if they could not be detected.
+func (i *Invocation) Encodings() *EncodingReport {
+	return i.encodings
 }
 
 func (i *Invocation) Run() (int, error) {
-	glog.Infof("Starting shipshape...")
-	fs, err := os.Stat(i.options.File)
+	glog.Infof("Starting shipshape run %s...", i.options.RunID)
+	docker.RunID = i.options.RunID
+	defer i.closeSubscriptions()
+	start := time.Now()
+	fs, origDir, absRoot, err := i.target()
 	if err != nil {
-		return 0, fmt.Errorf("%s is not a valid file or directory\n", i.options.File)
+		return 0, err
 	}
-
-	origDir := i.options.File
-	if !fs.IsDir() {
-		origDir = filepath.Dir(i.options.File)
+	if i.options.LockDir != "" {
+		lock, err := LockService(i.options.LockDir, docker.Daemon(), absRoot, i.options.RunID, i.options.LockWait, func(holder string) {
+			fmt.Fprint(os.Stderr, Messages.Sprintf("Waiting for %s to finish\n", holder))
+		})
+		if err != nil {
+			return 0, err
+		}
+		defer lock.Unlock()
+	}
+	if err := service.ValidateEvent(absRoot, i.options.Event); err != nil {
+		return 0, err
+	}
+	if err := i.configureBuild(absRoot); err != nil {
+		return 0, err
 	}
+	if err := i.checkStages(); err != nil {
+		return 0, err
+	}
+	i.validated = true
 
-	absRoot, err := filepath.Abs(origDir)
-	if err != nil {
-		return 0, fmt.Errorf("could not get absolute path for %s: %v\n", origDir, err)
+	if problems := docker.Preflight(i.dockerAccess() == docker.DindAccess); len(problems) > 0 {
+		var msgs []string
+		for _, p := range problems {
+			msgs = append(msgs, p.Error())
+		}
+		return 0, fmt.Errorf("docker is not ready to run shipshape:\n%s", strings.Join(msgs, "\n"))
 	}
 
-	if !docker.HasDocker() {
-		return 0, fmt.Errorf("docker could not be found. Make sure you have docker installed.")
+	hostRoot, err := i.hostRoot(absRoot)
+	if err != nil {
+		return 0, err
 	}
 
 	image := docker.FullImageName(i.options.Repo, image, i.options.Tag)
@@ -104,19 +323,78 @@ func (i *Invocation) Run() (int, error) {
 		glog.Infof("No categories provided. Will be using categories specified by the config file for the event %s", i.options.Event)
 	}
 
-	if len(i.options.ThirdPartyAnalyzers) == 0 {
-		i.options.ThirdPartyAnalyzers, err = service.GlobalConfig(absRoot)
-		if err != nil {
-			glog.Infof("Could not get global config; using only the default analyzers: %v", err)
-		}
+	analyzerEnv, err := i.resolveAnalyzers(absRoot)
+	if err != nil {
+		return 0, err
+	}
+	if image, err = i.pinImages(absRoot, image, analyzerEnv); err != nil {
+		return 0, err
 	}
 
-	// If we are not running in local mode, pull the latest copy
+	// Pull the images in the background while the workspace is examined.
+	// If we are not running in local mode, pull the latest copy.
 	// Notice this will use the local tag as a signal to not pull the
 	// third-party analyzers either.
+	var toPull []string
 	if i.options.Tag != "local" {
-		pull(image)
-		pullAnalyzers(i.options.ThirdPartyAnalyzers)
+		toPull = append([]string{image}, i.options.ThirdPartyAnalyzers...)
+	}
+	fullKytheImage := docker.FullImageName(i.options.Repo, kytheImage, i.options.Tag)
+	if i.extracts() && !i.options.LocalKythe {
+		// TODO(ciera): Handle other build systems
+		fullKytheImage = i.pin(fullKytheImage)
+		toPull = append(toPull, fullKytheImage)
+	}
+	used := append([]string{image}, i.options.ThirdPartyAnalyzers...)
+	if i.extracts() {
+		used = append(used, fullKytheImage)
+	}
+	i.trimImageCache(used)
+	if len(toPull) > 0 {
+		if err := checkDiskSpace(toPull); err != nil {
+			return 0, err
+		}
+	}
+	pulls := startPulls(toPull)
+	i.pulls = pulls
+	i.images = append([]string{image}, i.options.ThirdPartyAnalyzers...)
+
+	var extraImages []string
+	if i.extracts() {
+		extraImages = []string{fullKytheImage}
+	}
+	i.detectEncodings(fs, absRoot)
+	stateImages := append(append([]string(nil), i.images...), extraImages...)
+	state, err := i.runState(fs, absRoot, analyzerEnv)
+	if err != nil {
+		glog.Errorf("Could not find the state of the workspace, so analyzing it: %v", err)
+	} else if state != nil && !i.options.Force {
+		prev, err := LoadSnapshot(i.options.SnapshotDir, state.Target)
+		if err != nil {
+			glog.Errorf("Could not load the snapshot of the last run: %v", err)
+		} else if prev != nil {
+			// The pulls are only waited for if nothing but the images can have
+			// changed, so that the containers otherwise start while they run.
+			changed := prev.ChangedBesidesImages(*state)
+			if len(changed) == 0 {
+				if err := imageDigests(state, pulls, stateImages); err != nil {
+					glog.Errorf("Could not compare the images with those of the last run, so analyzing again: %v", err)
+				} else if prev.Matches(*state) {
+					glog.Infof("Nothing changed since the run at %v, so showing its results", prev.Timestamp)
+					i.recordLookup(true)
+					return i.replay(prev, origDir, absRoot)
+				} else {
+					changed = prev.Changed(*state)
+				}
+			}
+			if len(changed) > 0 {
+				glog.Infof("Analyzing again, since these changed since the run at %v: %s", prev.Timestamp, strings.Join(changed, ", "))
+			}
+		}
+		i.recordLookup(false)
+	}
+	if absRoot, hostRoot, err = i.stageTranscoded(absRoot, hostRoot); err != nil {
+		return 0, err
 	}
 
 	// Put in this defer before calling run. Even if run fails, it can
@@ -134,71 +412,495 @@ func (i *Invocation) Run() (int, error) {
 		}
 	}
 
-	containers, errs := startAnalyzers(absRoot, i.options.ThirdPartyAnalyzers, i.options.Dind)
-	for _, err := range errs {
-		glog.Errorf("Could not start up third party analyzer: %v", err)
+	// Start the containers while the files are split into batches. Each
+	// analyzer starts as soon as its own image is pulled, unless its image
+	// says it provides none of the triggered categories, and the service
+	// once the analyzers it links to are up.
+	categories := i.triggeredCategories(absRoot)
+	if i.options.ColdStart {
+		stop("shipping_container", 0)
+		for id, analyzerRepo := range i.options.ThirdPartyAnalyzers {
+			container, _ := getContainerAndAddress(analyzerRepo, id)
+			stop(container, 0)
+		}
+	}
+	sandboxes := i.resolveSandboxes(absRoot)
+	containersStarting := time.Now()
+	started := make(chan serviceStartup, 1)
+	go func() {
+		startupBegan := time.Now()
+		if err := docker.CreateCacheVolumes(); err != nil {
+			glog.Errorf("Could not create tool cache volumes: %v", err)
+		}
+		for _, sb := range sandboxes {
+			if sb.Offline {
+				if err := docker.EnsureSandboxNetwork(); err != nil {
+					glog.Errorf("Could not create the network for offline analyzers: %v", err)
+				}
+				break
+			}
+		}
+		containers, errs := startAnalyzers(hostRoot, i.options.ThirdPartyAnalyzers, analyzerEnv, sandboxes, i.dockerAccess(), pulls, categories)
+		for _, err := range errs {
+			glog.Errorf("Could not start up third party analyzer: %v", err)
+		}
+		pulls.wait(image)
+		i.containers = append([]string{"shipping_container"}, containers...)
+		c, relativeRoot, err := startShipshapeService(image, hostRoot, containers, i.dockerAccess(), i.options.SocketDir, i.options.Deterministic)
+		// The containers could only start once their images were pulled.
+		if last := pulls.finished(append([]string{image}, i.options.ThirdPartyAnalyzers...)); last.After(startupBegan) {
+			startupBegan = last
+		}
+		started <- serviceStartup{c, relativeRoot, time.Since(startupBegan), err}
+	}()
+
+	var files []string
+	var batches [][]string
+	var listErr error
+	if !fs.IsDir() {
+		files = []string{filepath.Base(i.options.File)}
+	} else if i.options.BatchSize > 0 {
+		var all []string
+		if all, listErr = service.FilesToAnalyze(absRoot, nil); listErr == nil {
+			batches = splitBatches(all, i.options.BatchSize)
+		}
+	}
+	revision, ci := GetRevisionDetails(i.options.File), GetCiDetails()
+
+	// Wait for the service even if listing the files failed, so that the
+	// deferred stops do not race with the containers starting.
+	up := <-started
+	if up.err != nil {
+		// The service can't start without its image, and the reason it could not
+		// be pulled says more than the error from running it.
+		if blocked := pulls.blocked(image); blocked != nil && docker.ImageDigest(image) == "" {
+			return 0, blocked
+		}
+		return 0, fmt.Errorf("HTTP client did not become healthy: %v", up.err)
+	}
+	if listErr != nil {
+		return 0, fmt.Errorf("could not list the files to analyze: %v", listErr)
+	}
+	c, relativeRoot := up.client, up.relativeRoot
+	i.timings.Start = up.took
+	stopStreaming := func() {}
+	if i.options.AnalyzerOutput != nil {
+		// Streaming from when the containers started also shows any errors they
+		// had starting up.
+		stopStreaming = i.streamAnalyzerOutput(i.containers[1:], containersStarting)
+	}
+	defer stopStreaming()
+
+	stages := i.stages()
+	req := createRequest(i.options.TriggerCats, files, i.options.Event, filepath.Join(workspace, relativeRoot), stages[0].Enum())
+	if i.options.RunID != "" {
+		req.RunId = proto.String(i.options.RunID)
+	}
+	// The service would report the categories of the local analyzers as missing.
+	serviceCats, local := i.serviceCategories(categories)
+	if local {
+		req.TriggeredCategory = serviceCats
+	}
+	req.ShipshapeContext.RevisionDetails = revision
+	req.ShipshapeContext.CiDetails = ci
+	if i.options.AnalyzerParallelism > 0 {
+		req.AnalyzerParallelism = proto.Int32(int32(i.options.AnalyzerParallelism))
+	}
+	if i.options.HandleProgress != nil {
+		req.ReportProgress = proto.Bool(true)
+	}
+	if i.options.FailFast {
+		req.StreamResults = proto.Bool(true)
+	}
+	i.request = req
+	numNotes := 0
+	for _, stage := range stages {
+		// If desired, generate compilation units with a kythe image
+		if stage == ctxpb.Stage_POST_BUILD && i.options.ImportCompilations != "" {
+			n, err := importCompilations(i.options.ImportCompilations, absRoot)
+			if err != nil {
+				return numNotes, fmt.Errorf("could not import the compilation units from %s: %v", i.options.ImportCompilations, err)
+			}
+			glog.Infof("Imported %d compilation units from %s", n, i.options.ImportCompilations)
+		} else if stage == ctxpb.Stage_POST_BUILD {
+			pulls.wait(fullKytheImage)
+			i.images = append(i.images, fullKytheImage)
+
+			// TODO(emso): Add a check for an already running kythe container.
+			// The below defer should stop the one started below but in case this
+			// failed for some reason (or a kythe container was started in some other
+			// way) the below run command will fail.
+			defer stop("kythe", 10*time.Second)
+			i.containers = append(i.containers, "kythe")
+			glog.Infof("Retrieving compilation units with %s", i.options.Build)
+
+			extractionBegan := time.Now()
+			result := docker.RunKythe(fullKytheImage, "kythe", hostRoot, i.options.Build, i.dockerAccess())
+			i.timings.Extraction = time.Since(extractionBegan)
+			if result.Err != nil {
+				// kythe spews output, so only capture it if something went wrong.
+				printStreams(result)
+				return numNotes, fmt.Errorf("error from run: %v", result.Err)
+			}
+			glog.Infoln("CompilationUnits prepared")
+			if dir := i.options.ExportCompilations; dir != "" {
+				n, err := copyCompilations(filepath.Join(absRoot, compilationsDir), dir)
+				if err != nil {
+					return numNotes, fmt.Errorf("could not export the compilation units to %s: %v", dir, err)
+				}
+				glog.Infof("Exported %d compilation units to %s", n, dir)
+			}
+
+			defer stop("kythe-index", 10*time.Second)
+			i.containers = append(i.containers, "kythe-index")
+			indexingBegan := time.Now()
+			i.indexCompilations(fullKytheImage, hostRoot, absRoot)
+			i.timings.Extraction += time.Since(indexingBegan)
+		}
+
+		req.Stage = stage.Enum()
+		analysisBegan := time.Now()
+		var numStageNotes int
+		var err error
+		if !local || len(serviceCats) > 0 {
+			numStageNotes, err = i.analyzeBatches(c, req, origDir, batches, start)
+		}
+		if err == nil {
+			var numLocalNotes int
+			numLocalNotes, err = i.runLocalAnalyzers(req, categories, absRoot, files, origDir)
+			numStageNotes += numLocalNotes
+		}
+		i.timings.Analysis += time.Since(analysisBegan)
+		numNotes += numStageNotes
+		if err == errFailFast {
+			glog.Infof("Stopped the analysis at a note at least as severe as %s", i.options.FailFastSeverity)
+			break
+		} else if err != nil {
+			return numNotes, i.interrupted(err)
+		}
+	}
+	// Stop before the results are shown, so that they aren't mixed with analyzer output.
+	stopStreaming()
+	// The results of a run that stopped early are not all of them, so they are not recorded.
+	if state != nil && !i.failedFast {
+		if err := imageDigests(state, pulls, stateImages); err != nil {
+			glog.Errorf("Could not record the snapshot of the run: %v", err)
+		} else {
+			i.saveSnapshot(*state)
+		}
+	}
+	if i.options.ResponsesDone != nil {
+		if err := i.options.ResponsesDone(); err != nil {
+			return numNotes, err
+		}
+	}
+
+	glog.Infof("End of Results of run %s.", i.options.RunID)
+	i.timings.Total = time.Since(start)
+	return numNotes, nil
+}
+
+// detectEncodings finds the encodings of the files to analyze in absRoot, the
+// directory of the target fs. If they can not be read, the analyzers see them
+// as they are.
+func (i *Invocation) detectEncodings(fs os.FileInfo, absRoot string) {
+	files := []string{fs.Name()}
+	if fs.IsDir() {
+		var err error
+		if files, err = service.FilesToAnalyze(absRoot, nil); err != nil {
+			glog.Errorf("Could not list the files to detect their encodings: %v", err)
+			return
+		}
+	}
+	r, err := DetectEncodings(absRoot, files)
+	if err != nil {
+		glog.Errorf("Could not detect the encodings of the files: %v", err)
+		return
+	}
+	for _, f := range r.Undecodable {
+		glog.Warningf("Could not detect the encoding of %s, so analyzing it as it is", f)
+	}
+	i.encodings = r
+}
+
+// stageTranscoded returns the directory to analyze instead of absRoot, and its path
+// on the docker host, which is hostRoot for absRoot. If some files need transcoding,
+// it is a copy of absRoot in TranscodeDir with them converted to UTF-8.
+func (i *Invocation) stageTranscoded(absRoot, hostRoot string) (string, string, error) {
+	r := i.encodings
+	if r == nil || len(r.Transcoded) == 0 {
+		return absRoot, hostRoot, nil
 	}
+	// A copy made inside a container is not where the docker host can mount it from.
+	if i.options.TranscodeDir == "" || hostRoot != absRoot {
+		glog.Warningf("Analyzing %d files that are not plain UTF-8 as they are", len(r.Transcoded))
+		r.Transcoded = map[string]Encoding{}
+		return absRoot, hostRoot, nil
+	}
+	dir := stagingDir(i.options.TranscodeDir, absRoot)
+	if err := r.Stage(absRoot, dir); err != nil {
+		return "", "", fmt.Errorf("could not transcode the workspace, set --transcode_dir= to analyze it as it is: %v", err)
+	}
+	glog.Infof("Analyzing a copy of %s in %s, with %d files transcoded to UTF-8", absRoot, dir, len(r.Transcoded))
+	return dir, dir, nil
+}
 
-	var c *client.Client
-	var req *rpcpb.ShipshapeRequest
-	var numNotes int
+// target resolves the file or directory to analyze. It returns its file info, the
+// directory it is in (or itself, if it is a directory) and that directory's absolute path.
+func (i *Invocation) target() (os.FileInfo, string, string, error) {
+	if i.options.FollowSymlinks {
+		resolved, err := filepath.EvalSymlinks(i.options.File)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("could not resolve %s: %v", i.options.File, err)
+		}
+		i.options.File = resolved
+	}
+	fs, err := os.Stat(i.options.File)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("%s is not a valid file or directory\n", i.options.File)
+	}
 
-	// Run it on files
-	relativeRoot := ""
-	c, relativeRoot, err = startShipshapeService(image, absRoot, containers, i.options.Dind)
+	origDir := i.options.File
+	if !fs.IsDir() {
+		origDir = filepath.Dir(i.options.File)
+	}
+
+	absRoot, err := filepath.Abs(origDir)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("could not get absolute path for %s: %v\n", origDir, err)
+	}
+	return fs, origDir, absRoot, nil
+}
+
+// resolveAnalyzers fills in the third-party analyzers from the config in absRoot, unless
+// they were given, and returns the environments of their containers.
+func (i *Invocation) resolveAnalyzers(absRoot string) (map[string]map[string]string, error) {
+	if len(i.options.ThirdPartyAnalyzers) == 0 {
+		var err error
+		i.options.ThirdPartyAnalyzers, err = service.GlobalConfig(absRoot)
+		if err != nil {
+			glog.Infof("Could not get global config; using only the default analyzers: %v", err)
+		}
+	}
+	envs, err := service.AnalyzerEnvironments(absRoot)
 	if err != nil {
-		return 0, fmt.Errorf("HTTP client did not become healthy: %v", err)
+		glog.Infof("Could not get analyzer environments from the config: %v", err)
+	}
+	return analyzerEnvironments(envs, os.Getenv)
+}
+
+// resolveSandboxes returns the sandbox of each third-party analyzer, from the config in
+// absRoot and StrictSandbox. resolveAnalyzers and pinImages must have been called first;
+// the images of the config are pinned like those of the run.
+func (i *Invocation) resolveSandboxes(absRoot string) map[string]docker.Sandbox {
+	sandboxes, err := service.AnalyzerSandboxes(absRoot)
+	if err != nil {
+		glog.Infof("Could not get analyzer sandboxes from the config: %v", err)
+	}
+	for _, sb := range sandboxes {
+		if pinned, ok := i.lock.Pin(sb.GetImage()); ok {
+			sb.Image = proto.String(pinned)
+		}
+	}
+	return analyzerSandboxes(sandboxes, i.options.ThirdPartyAnalyzers, absRoot, i.options.StrictSandbox)
+}
+
+// handleResponse records msg for the snapshot of the run, before passing it on to
+// HandleResponse, which may change it. It returns errFailFast if what is left of msg
+// stops the analysis.
+func (i *Invocation) handleResponse(msg *rpcpb.ShipshapeResponse, directory string) error {
+	if i.encodings != nil {
+		if n := i.encodings.Restore(msg); n > 0 {
+			glog.Warningf("Left out %d fixes that the encodings of their files can not represent", n)
+		}
+	}
+	if i.options.SnapshotDir != "" {
+		i.recorded = append(i.recorded, proto.Clone(msg).(*rpcpb.ShipshapeResponse))
+	}
+	if err := i.options.HandleResponse(msg, directory); err != nil {
+		return err
+	}
+	i.publish(msg)
+	if i.options.FailFast && hasNoteAtLeast(msg, i.options.FailFastSeverity) {
+		i.failedFast = true
+		return errFailFast
+	}
+	return nil
+}
+
+// hasNoteAtLeast returns whether msg has a note at least as severe as min.
+func hasNoteAtLeast(msg *rpcpb.ShipshapeResponse, min notepb.Note_Severity) bool {
+	for _, analysis := range msg.AnalyzeResponse {
+		for _, note := range analysis.Note {
+			if SeverityAtLeast(note.GetSeverity(), min) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runState returns the state of the run on absRoot, the directory of the target fs, or
+// nil if runs are not snapshotted, and env has the environments of the analyzer
+// containers. The workspace is hashed while the images are pulled, so the state has
+// no images until imageDigests adds them.
+func (i *Invocation) runState(fs os.FileInfo, absRoot string, env map[string]map[string]string) (*RunState, error) {
+	if i.options.SnapshotDir == "" {
+		return nil, nil
 	}
 	var files []string
+	target := absRoot
 	if !fs.IsDir() {
-		files = []string{filepath.Base(i.options.File)}
+		files = []string{fs.Name()}
+		target = filepath.Join(absRoot, fs.Name())
 	}
-	req = createRequest(i.options.TriggerCats, files, i.options.Event, filepath.Join(workspace, relativeRoot), ctxpb.Stage_PRE_BUILD.Enum())
-	glog.Infof("Calling with request %v", req)
-	numNotes, err = analyze(c, req, origDir, i.options.HandleResponse)
+	files, err := service.FilesToAnalyze(absRoot, files)
 	if err != nil {
-		return numNotes, fmt.Errorf("error making service call: %v", err)
+		return nil, err
+	}
+	state := &RunState{
+		Target:        target,
+		Images:        make(map[string]string),
+		Event:         i.options.Event,
+		Categories:    i.options.TriggerCats,
+		Build:         i.options.Build,
+		Deterministic: i.options.Deterministic,
+		CLIVersion:    Version,
+	}
+	if len(state.Categories) == 0 {
+		state.Categories = nil
+	}
+	for _, s := range i.options.Stages {
+		state.Stages = append(state.Stages, s.String())
+	}
+	if state.Workspace, err = WorkspaceHash(absRoot, files); err != nil {
+		return nil, err
+	}
+	config, err := ioutil.ReadFile(filepath.Join(absRoot, ".shipshape"))
+	if err == nil {
+		sum := sha256.Sum256(config)
+		state.Config = hex.EncodeToString(sum[:])
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if !i.options.Deterministic {
+		if state.ToolConfig, err = ToolConfigHashes(absRoot); err != nil {
+			return nil, err
+		}
 	}
+	if state.AnalyzerEnv, err = EnvironmentHash(env); err != nil {
+		return nil, err
+	}
+	if dir := i.options.ImportCompilations; dir != "" {
+		units, err := compilationFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+		if state.Compilations, err = WorkspaceHash(dir, units); err != nil {
+			return nil, err
+		}
+	}
+	return state, nil
+}
 
-	// If desired, generate compilation units with a kythe image
-	if i.options.Build != "" {
-		// TODO(ciera): Handle other build systems
-		fullKytheImage := docker.FullImageName(i.options.Repo, kytheImage, i.options.Tag)
-		if !i.options.LocalKythe {
-			pull(fullKytheImage)
-		}
-
-		// TODO(emso): Add a check for an already running kythe container.
-		// The below defer should stop the one started below but in case this
-		// failed for some reason (or a kythe container was started in some other
-		// way) the below run command will fail.
-		defer stop("kythe", 10*time.Second)
-		glog.Infof("Retrieving compilation units with %s", i.options.Build)
-
-		result := docker.RunKythe(fullKytheImage, "kythe", absRoot, i.options.Build, i.options.Dind)
-		if result.Err != nil {
-			// kythe spews output, so only capture it if something went wrong.
-			printStreams(result)
-			return numNotes, fmt.Errorf("error from run: %v", result.Err)
-		}
-		glog.Infoln("CompilationUnits prepared")
-
-		req.Stage = ctxpb.Stage_POST_BUILD.Enum()
-		glog.Infof("Calling with request %v", req)
-		numBuildNotes, err := analyze(c, req, origDir, i.options.HandleResponse)
-		numNotes += numBuildNotes
+// imageDigests adds the digests of images, e.g. those of the service, the analyzers
+// and kythe, to state, looking each up once its pull finishes.
+func imageDigests(state *RunState, pulls imagePulls, images []string) error {
+	for _, image := range images {
+		pulls.wait(image)
+		digest := docker.ImageDigest(image)
+		if digest == "" {
+			return fmt.Errorf("could not find the digest of %s", image)
+		}
+		state.Images[image] = digest
+	}
+	return nil
+}
+
+// recordLookup counts a lookup of the results of an earlier run in the snapshot
+// directory, which found them if hit is set.
+func (i *Invocation) recordLookup(hit bool) {
+	if err := RecordLookup(i.options.SnapshotDir, hit); err != nil {
+		glog.Errorf("Could not count the lookup in the result cache: %v", err)
+	}
+}
+
+// saveSnapshot records the responses of a run with state, so that the next run can
+// show them if nothing changes. Runs in which analyzers failed are not recorded, since
+// the failures may not happen again.
+func (i *Invocation) saveSnapshot(state RunState) {
+	for _, msg := range i.recorded {
+		for _, ar := range msg.AnalyzeResponse {
+			if len(ar.Failure) > 0 {
+				glog.Infof("Not recording a snapshot of a run with failures")
+				return
+			}
+		}
+	}
+	s := &Snapshot{State: state, Timestamp: time.Now().UTC(), Responses: i.recorded}
+	if err := s.Save(i.options.SnapshotDir); err != nil {
+		glog.Errorf("Could not record a snapshot of the run: %v", err)
+	}
+}
+
+// replay passes the responses of the earlier run in prev on as if they had just been
+// received. The cross-references that the kythe build of the earlier run left in the
+// workspace absRoot, if it did, are read again for Xrefs.
+func (i *Invocation) replay(prev *Snapshot, origDir, absRoot string) (int, error) {
+	total := 0
+	for _, msg := range prev.Responses {
+		if err := i.options.HandleResponse(msg, origDir); err != nil {
+			return 0, fmt.Errorf("could not parse results: %v", err.Error())
+		}
+		i.publish(msg)
+		total += numNotes(msg)
+	}
+	i.unchangedSince = prev.Timestamp
+	if i.extracts() {
+		xrefs, err := LoadXrefs(absRoot)
 		if err != nil {
-			return numNotes, fmt.Errorf("error making service call: %v", err)
+			glog.Errorf("Could not read the cross-references, so the notes have no symbols: %v", err)
 		}
+		i.xrefs = xrefs
 	}
 	if i.options.ResponsesDone != nil {
 		if err := i.options.ResponsesDone(); err != nil {
-			return numNotes, err
+			return total, err
 		}
 	}
+	return total, nil
+}
 
-	glog.Infoln("End of Results.")
-	return numNotes, nil
+// interrupted handles an error from analyze. If the stream of results failed, the results
+// received so far are still passed on, marked as incomplete.
+func (i *Invocation) interrupted(err error) error {
+	_, isStream := err.(streamError)
+	err = fmt.Errorf("error making service call: %v", err)
+	if !isStream {
+		return err
+	}
+	if i.options.StreamInterrupted != nil {
+		i.options.StreamInterrupted(err)
+	}
+	if i.options.ResponsesDone != nil {
+		if doneErr := i.options.ResponsesDone(); doneErr != nil {
+			glog.Errorf("Could not finish handling incomplete results: %v", doneErr)
+		}
+	}
+	return err
+}
+
+// setTimeBudget gives req whatever is left of the time budget for a run that started at start.
+func (i *Invocation) setTimeBudget(req *rpcpb.ShipshapeRequest, start time.Time) {
+	if i.options.TimeBudget == 0 {
+		return
+	}
+	left := i.options.TimeBudget - time.Since(start)
+	if left < time.Millisecond {
+		// A budget of zero means no budget, so ask for as little time as possible instead.
+		left = time.Millisecond
+	}
+	req.TimeBudgetMs = proto.Int64(int64(left / time.Millisecond))
 }
 
 func numNotes(msg *rpcpb.ShipshapeResponse) int {
@@ -211,13 +913,13 @@ func numNotes(msg *rpcpb.ShipshapeResponse) int {
 
 // startShipshapeService ensures that there is a service started with the given image and
 // attached analyzers that can analyze the directory at absRoot (an absolute path). If a
-// service is not started up that can do this, it will shut down the existing one and start
-// a new one.
+// service is not started up that can do this, or the one started up does not respond, it
+// will shut down the existing one and start a new one.
 // The methods returns the (ready) client, the relative path from the docker container's mapped
 // volume to the absRoot that we are analyzing, and any errors from attempting to run the service.
 // TODO(ciera): This *should* check the analyzers that are connected, but does not yet
 // do so.
-func startShipshapeService(image, absRoot string, analyzers []string, dind bool) (*client.Client, string, error) {
+func startShipshapeService(image, absRoot string, analyzers []string, access docker.Access, socketDir string, deterministic bool) (*client.Client, string, error) {
 	glog.Infof("Starting shipshape...")
 	container := "shipping_container"
 	// subPath is the relatve path from the mapped volume on shipping container
@@ -226,57 +928,184 @@ func startShipshapeService(image, absRoot string, analyzers []string, dind bool)
 	// Stop and restart the container if:
 	// 1: The container is not using the latest image OR
 	// 2: The container is not mapped to the right directory OR
-	// 3: The container is not linked to the right analyzer containers
+	// 3: The container is not linked to the right analyzer containers OR
+	// 4: The container was started with different access to docker OR
+	// 5: The container listens on a different socket or port OR
+	// 6: The container was started with a different deterministic mode OR
+	// 7: The container does not respond
 	// Otherwise, use the existing container
-	if !docker.ImageMatches(image, container) || !isMapped || !docker.ContainsLinks(container, analyzers) {
-		glog.Infof("Restarting container with %s", image)
-		stop(container, 0)
-		result := docker.RunService(image, container, absRoot, localLogs, analyzers, dind)
-		subPath = ""
-		printStreams(result)
-		if result.Err != nil {
-			return nil, "", result.Err
+	if docker.ImageMatches(image, container) && isMapped && docker.ContainsLinks(container, analyzers) && docker.HasAccess(container, access) && docker.ServesSocket(container, socketDir) && docker.IsDeterministic(container) == deterministic {
+		c := serviceClient(socketDir)
+		err := c.WaitUntilReady(reuseTimeout)
+		if err == nil {
+			glog.Infof("Reusing the running %s", container)
+			return c, subPath, nil
+		}
+		glog.Warningf("The running %s is not responding, so restarting it: %v", container, err)
+	}
+	glog.Infof("Restarting container with %s", image)
+	stop(container, 0)
+	port := servicePort
+	if socketDir == "" {
+		var err error
+		if port, err = docker.AvailablePort(servicePort); err != nil {
+			return nil, "", err
+		}
+		if port != servicePort {
+			glog.Warningf("Port %d is in use by another process, so publishing the service on port %d instead", servicePort, port)
 		}
 	}
+	result := docker.RunService(image, container, absRoot, localLogs, analyzers, access, socketDir, port, deterministic)
+	printStreams(result)
+	if result.Err != nil {
+		return nil, "", result.Err
+	}
 	glog.Infof("Image %s running in service mode", image)
-	c := client.NewHTTPClient("localhost:10007")
-	return c, subPath, c.WaitUntilReady(10 * time.Second)
+	c := serviceClient(socketDir)
+	if err := c.WaitUntilReady(readyTimeout); err != nil {
+		return nil, "", notReadyError(container, err)
+	}
+	return c, "", nil
+}
+
+// serviceClient returns a client for the service listening on a unix socket
+// in socketDir, or at serviceAddress if socketDir is empty.
+func serviceClient(socketDir string) *client.Client {
+	if socketDir != "" {
+		return client.NewUnixClient(filepath.Join(socketDir, docker.ServiceSocket))
+	}
+	return client.NewHTTPClient(serviceAddress())
 }
 
-func analyze(c *client.Client, req *rpcpb.ShipshapeRequest, originalDir string, handleResponse func(msg *rpcpb.ShipshapeResponse, directory string) error) (int, error) {
+// serviceAddress returns where the running service listens, when it does not
+// listen on a unix socket.
+func serviceAddress() string {
+	return fmt.Sprintf("localhost:%d", docker.PublishedPort("shipping_container", servicePort))
+}
+
+func analyze(c *client.Client, opts client.StreamOptions, req *rpcpb.ShipshapeRequest, originalDir string, handleResponse func(msg *rpcpb.ShipshapeResponse, directory string) error, handleProgress func([]*rpcpb.AnalysisProgress)) (int, error) {
 	var totalNotes = 0
+	sc := client.ShipshapeClient{Client: c, StreamOptions: opts}
+	req.RequestId = proto.String(newRequestID())
+	interrupted := cancelOnInterrupt(sc, req.GetRequestId())
+	defer interrupted()
 	glog.Infof("Calling to the shipshape service with %v", req)
-	rd := c.Stream("/ShipshapeService/Run", req)
+	rd := sc.Run(req)
 	defer rd.Close()
 	for {
-		var msg rpcpb.ShipshapeResponse
-		if err := rd.NextResult(&msg); err == io.EOF {
+		msg, err := rd.Next()
+		if err == io.EOF {
 			break
 		} else if err != nil {
-			return 0, fmt.Errorf("received an error from calling run: %v", err.Error())
+			// The service may still be running the analyzers, for instance after an
+			// idle timeout, so stop it from doing work that nobody will read.
+			if !interrupted() {
+				cancelRequest(sc, req.GetRequestId())
+			}
+			return totalNotes, streamError{err}
 		}
 
-		err := handleResponse(&msg, originalDir)
-		if err != nil {
+		if len(msg.Progress) > 0 {
+			if handleProgress != nil {
+				handleProgress(msg.Progress)
+			}
+			continue
+		}
+		canonicalizePaths(msg, req.ShipshapeContext.GetRepoRoot())
+		err = handleResponse(msg, originalDir)
+		if err == errFailFast {
+			// Nobody will read the rest of the results, so stop the service from working on them.
+			if !interrupted() {
+				cancelRequest(sc, req.GetRequestId())
+			}
+			return totalNotes + numNotes(msg), err
+		} else if err != nil {
 			return 0, fmt.Errorf("could not parse results: %v", err.Error())
 		}
-		totalNotes += numNotes(&msg)
+		totalNotes += numNotes(msg)
+	}
+	if interrupted() {
+		return totalNotes, streamError{errInterrupted}
 	}
 	return totalNotes, nil
 }
 
-func pull(image string) {
-	if !docker.OutOfDate(image) {
+// newRequestID returns a random ID for a request, so that it can be cancelled. If there is
+// no randomness to be had, it returns the empty string and the request cannot be cancelled.
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		glog.Errorf("Could not generate a request ID: %v", err)
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// cancelOnInterrupt cancels the request with the given ID if the process is interrupted
+// before the returned function is called. Only the first interrupt is caught, so a second
+// one kills the process as usual. The returned function may be called more than once, and
+// returns whether the request was cancelled.
+func cancelOnInterrupt(sc client.ShipshapeClient, requestID string) func() bool {
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	result := make(chan bool, 1)
+	signal.Notify(sigs, os.Interrupt)
+	go func() {
+		select {
+		case <-sigs:
+			signal.Stop(sigs)
+			fmt.Fprintln(os.Stderr, Messages.Text("Interrupted, cancelling the analysis. Interrupt again to quit immediately."))
+			cancelRequest(sc, requestID)
+			result <- true
+		case <-done:
+			result <- false
+		}
+	}()
+	var once sync.Once
+	var cancelled bool
+	return func() bool {
+		once.Do(func() {
+			signal.Stop(sigs)
+			close(done)
+			cancelled = <-result
+		})
+		return cancelled
+	}
+}
+
+// cancelRequest asks the service to stop working on the request with the given ID.
+func cancelRequest(sc client.ShipshapeClient, requestID string) {
+	if requestID == "" {
 		return
 	}
+	running, err := sc.Cancel(requestID)
+	if err != nil {
+		glog.Errorf("Could not cancel request %s: %v", requestID, err)
+		return
+	}
+	glog.Infof("Cancelled request %s, which was running: %v", requestID, running)
+}
+
+// pull pulls image if it is out of date. If a proxy or firewall blocked the
+// registry, it returns the docker.Problem that explains it; a local copy of
+// the image may still be used, so other errors are only logged.
+func pull(image string) error {
+	if !docker.OutOfDate(image) {
+		return nil
+	}
 	glog.Infof("Pulling image %s", image)
 	result := docker.Pull(image)
 	printStreams(result)
 	if result.Err != nil {
+		if p := docker.PullProblem(image, result.Stderr); p != nil {
+			glog.Errorf("%v", p)
+			return *p
+		}
 		glog.Errorf("Error from pull: %v", result.Err)
-		return
+		return nil
 	}
 	glog.Infoln("Pulling complete")
+	return nil
 }
 
 func stop(container string, timeWait time.Duration) {
@@ -290,46 +1119,155 @@ func stop(container string, timeWait time.Duration) {
 	}
 }
 
-func pullAnalyzers(images []string) {
-	var wg sync.WaitGroup
-	for _, analyzerImage := range images {
-		wg.Add(1)
+// imagePulls are the images being pulled in the background.
+type imagePulls map[string]*imagePull
+
+// imagePull is the pull of a single image. took and finished are set before
+// done is closed.
+type imagePull struct {
+	done     chan struct{}
+	took     time.Duration
+	finished time.Time
+	// blocked is the error of the pull if the registry was blocked.
+	blocked error
+}
+
+// startPulls pulls each of the images, if it is out of date, in the
+// background.
+func startPulls(images []string) imagePulls {
+	pulls := make(imagePulls)
+	for _, image := range images {
+		if _, ok := pulls[image]; ok {
+			continue
+		}
+		p := &imagePull{done: make(chan struct{})}
+		pulls[image] = p
 		go func(image string) {
-			pull(image)
-			wg.Done()
-		}(analyzerImage)
+			began := time.Now()
+			p.blocked = pull(image)
+			p.took, p.finished = time.Since(began), time.Now()
+			close(p.done)
+		}(image)
+	}
+	if len(pulls) > 0 {
+		glog.Info("Pulling images in the background...")
 	}
-	glog.Info("Pulling dockerized analyzers...")
-	wg.Wait()
-	glog.Info("Analyzers pulled")
+	return pulls
 }
 
-func startAnalyzers(sourceDir string, images []string, dind bool) (containers []string, errs []error) {
+// wait blocks until image is pulled. It returns immediately if image is
+// not being pulled.
+func (p imagePulls) wait(image string) {
+	if pull, ok := p[image]; ok {
+		<-pull.done
+	}
+}
+
+// blocked waits for image to be pulled, and returns why the pull failed if the
+// registry was blocked, or nil.
+func (p imagePulls) blocked(image string) error {
+	if pull, ok := p[image]; ok {
+		<-pull.done
+		return pull.blocked
+	}
+	return nil
+}
+
+// finished waits for the images to be pulled and returns when the last of
+// them finished, or the zero time if none of them were pulled.
+func (p imagePulls) finished(images []string) time.Time {
+	var last time.Time
+	for _, image := range images {
+		if pull, ok := p[image]; ok {
+			<-pull.done
+			if pull.finished.After(last) {
+				last = pull.finished
+			}
+		}
+	}
+	return last
+}
+
+// took returns how long the pulls that have finished took. They run at the
+// same time, so this is the longest of them.
+func (p imagePulls) took() time.Duration {
+	var longest time.Duration
+	for _, pull := range p {
+		select {
+		case <-pull.done:
+			if pull.took > longest {
+				longest = pull.took
+			}
+		default:
+		}
+	}
+	return longest
+}
+
+// serviceStartup is the result of starting the shipshape service.
+type serviceStartup struct {
+	client       *client.Client
+	relativeRoot string
+	// took is how long the containers took to start once their images were pulled.
+	took time.Duration
+	err  error
+}
+
+func startAnalyzers(sourceDir string, images []string, env map[string]map[string]string, sandboxes map[string]docker.Sandbox, access docker.Access, pulls imagePulls, categories []string) (containers []string, errs []error) {
 	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ports := newPortAllocator(firstAnalyzerPort, lastAnalyzerPort, docker.PortInUse)
 	for id, fullImage := range images {
 		wg.Add(1)
 		go func(id int, image string) {
+			defer wg.Done()
+			pulls.wait(image)
+			if provides := docker.LabelOf(image, CategoriesLabel); !analyzerNeeded(provides, categories) {
+				glog.Infof("Not starting %v, which provides %s, since none of its categories are triggered", image, provides)
+				return
+			}
 			analyzerContainer, port := getContainerAndAddress(image, id)
-			if docker.ImageMatches(image, analyzerContainer) {
-				glog.Infof("Reusing analyzer %v started at localhost:%d", image, port)
-			} else {
-				glog.Infof("Found no analyzer container (%v) to reuse for %v", analyzerContainer, image)
-				// Analyzer is either running with the wrong image version, or not running
-				// Stopping in case it's the first case
-				result := docker.Stop(analyzerContainer, 0, true)
-				if result.Err != nil {
-					glog.Infof("Failed to stop %v (may not be running)", analyzerContainer)
+			if docker.AnalyzerMatches(image, analyzerContainer, sourceDir, access, env[image], sandboxes[image]) {
+				glog.Infof("Reusing analyzer %v started at localhost:%d", image, docker.PublishedPort(analyzerContainer, port))
+				mu.Lock()
+				containers = append(containers, analyzerContainer)
+				mu.Unlock()
+				return
+			}
+			glog.Infof("Found no analyzer container (%v) to reuse for %v on %v", analyzerContainer, image, sourceDir)
+			// Analyzer is either running with the wrong image version or
+			// workspace, or not running. Stopping in case it's one of the first cases.
+			result := docker.Stop(analyzerContainer, 0, true)
+			if result.Err != nil {
+				glog.Infof("Failed to stop %v (may not be running)", analyzerContainer)
+			}
+			if !sandboxes[image].Offline {
+				usual := port
+				var err error
+				if port, err = ports.allocate(usual); err != nil {
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("could not publish %v: %v", image, err))
+					mu.Unlock()
+					return
 				}
-				result = docker.RunAnalyzer(image, analyzerContainer, sourceDir, localLogs, port, dind)
-				if result.Err != nil {
-					glog.Infof("Could not start %v at localhost:%d: %v, stderr: %v", image, port, result.Err.Error(), result.Stderr)
-					errs = append(errs, result.Err)
+				if port != usual {
+					glog.Warningf("Port %d is taken, so publishing %v on port %d instead", usual, image, port)
+				}
+			}
+			result = docker.RunAnalyzer(image, analyzerContainer, sourceDir, localLogs, port, access, env[image], sandboxes[image])
+			mu.Lock()
+			defer mu.Unlock()
+			if result.Err != nil {
+				glog.Infof("Could not start %v at localhost:%d: %v, stderr: %v", image, port, result.Err.Error(), redact.String(result.Stderr))
+				if blocked := pulls.blocked(image); blocked != nil {
+					errs = append(errs, blocked)
 				} else {
-					glog.Infof("Analyzer %v started at localhost:%d", image, port)
-					containers = append(containers, analyzerContainer)
+					errs = append(errs, result.Err)
 				}
+			} else {
+				glog.Infof("Analyzer %v started at localhost:%d", image, port)
+				containers = append(containers, analyzerContainer)
 			}
-			wg.Done()
 		}(id, fullImage)
 	}
 	if len(images) > 0 {
@@ -340,18 +1278,63 @@ func startAnalyzers(sourceDir string, images []string, dind bool) (containers []
 	return containers, errs
 }
 
+// hostRoot returns the path that the docker daemon sees for absRoot, for
+// mounting it into containers.
+func (i *Invocation) hostRoot(absRoot string) (string, error) {
+	if i.options.HostWorkspaceRoot != "" {
+		return filepath.Abs(i.options.HostWorkspaceRoot)
+	}
+	// With docker-in-docker, the daemon runs in the same container as we do,
+	// so the paths are the same.
+	if !i.options.Dind || !i.options.DockerSocket {
+		return absRoot, nil
+	}
+	hostRoot, err := docker.DetectHostPath(absRoot)
+	if err != nil {
+		return "", fmt.Errorf("could not find the host path of %s, set --host_workspace_root: %v", absRoot, err)
+	}
+	glog.Infof("Using host path %s for %s", hostRoot, absRoot)
+	return hostRoot, nil
+}
+
+// streamOptions returns the heartbeat and idle timeout to use for the stream of results.
+// Heartbeats are sent often enough that a few can be missed before timing out.
+func (i *Invocation) streamOptions() client.StreamOptions {
+	opts := client.StreamOptions{Heartbeat: heartbeat, IdleTimeout: i.options.IdleTimeout}
+	if opts.IdleTimeout > 0 && opts.IdleTimeout/3 < opts.Heartbeat {
+		opts.Heartbeat = opts.IdleTimeout / 3
+	}
+	return opts
+}
+
+// dockerAccess returns the access to docker that the containers shipshape
+// starts should have.
+func (i *Invocation) dockerAccess() docker.Access {
+	switch {
+	case i.options.DockerSocket:
+		return docker.SocketAccess
+	case i.options.Dind:
+		return docker.DindAccess
+	}
+	return docker.NoAccess
+}
+
 func printStreams(result docker.CommandResult) {
 	out := strings.TrimSpace(result.Stdout)
 	err := strings.TrimSpace(result.Stderr)
 	if len(out) > 0 {
-		glog.Infof("stdout:\n%s\n", strings.TrimSpace(result.Stdout))
+		glog.Infof("stdout:\n%s\n", redact.String(out))
 	}
 	if len(err) > 0 {
-		glog.Errorf("stderr:\n%s\n", strings.TrimSpace(result.Stderr))
+		glog.Errorf("stderr:\n%s\n", redact.String(err))
 	}
 }
 
 func getContainerAndAddress(fullImage string, id int) (analyzerContainer string, port int) {
+	// An image pinned to a digest (path@sha256:hex) has no tag.
+	if at := strings.Index(fullImage, "@"); at >= 0 {
+		fullImage = fullImage[:at]
+	}
 	// A docker image URI (location:port/path:tag) can have a host part
 	// with a port number and a path part with a tag.  Both tag and port
 	// are separated by colon, so we need to find out if the last colon is
@@ -364,7 +1347,7 @@ func getContainerAndAddress(fullImage string, id int) (analyzerContainer string,
 		end = len(fullImage)
 	}
 	image := fullImage[slash+1 : end]
-	port = 10010 + id
+	port = firstAnalyzerPort + id
 	analyzerContainer = fmt.Sprintf("%s_%d", image, id)
 	return analyzerContainer, port
 }
@@ -2,8 +2,18 @@ package cli
 
 import (
 	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"testing"
 
+	"github.com/google/shipshape/shipshape/test/echo_analyzer/echo"
+	"github.com/google/shipshape/shipshape/util/docker"
+	"github.com/google/shipshape/shipshape/util/test"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
 	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
 )
 
@@ -18,6 +28,10 @@ var (
 	localKythe = flag.Bool("shipshape_test_local_kythe", false, "if true, don't pull the Kythe docker image")
 )
 
+// echoImage is the trivial analyzer that the fixture workspaces use. It is
+// only ever built locally, by //shipshape/test/echo_analyzer/docker:echo.
+const echoImage = "beta.gcr.io/shipshape_releases/echo:local"
+
 func countFailures(resp rpcpb.ShipshapeResponse) int {
 	failures := 0
 	for _, analyzeResp := range resp.AnalyzeResponse {
@@ -34,6 +48,14 @@ func countNotes(resp rpcpb.ShipshapeResponse) int {
 	return notes
 }
 
+func allNotes(resp rpcpb.ShipshapeResponse) []*notepb.Note {
+	var notes []*notepb.Note
+	for _, analyzeResp := range resp.AnalyzeResponse {
+		notes = append(notes, analyzeResp.Note...)
+	}
+	return notes
+}
+
 func countCategoryNotes(resp rpcpb.ShipshapeResponse, category string) int {
 	notes := 0
 	for _, analyzeResp := range resp.AnalyzeResponse {
@@ -46,16 +68,145 @@ func countCategoryNotes(resp rpcpb.ShipshapeResponse, category string) int {
 	return notes
 }
 
-func TestExternalAnalyzers(t *testing.T) {
-	// Replaces part of the e2e test
-	// Create a fake maven project with android failures
+// fixture returns the path of the fixture workspace name. Under Bazel, tests
+// run from the root of the runfiles.
+func fixture(name string) string {
+	path := filepath.Join("shipshape/cli/testdata", name)
+	if _, err := os.Stat(path); err != nil {
+		return filepath.Join("testdata", name)
+	}
+	return path
+}
+
+// requireDocker skips the test unless docker is available.
+func requireDocker(t *testing.T) {
+	if !docker.HasDocker() {
+		t.Skip("docker is not available")
+	}
+}
+
+// requireEcho skips the test unless docker is available and the echo
+// analyzer image has been built.
+func requireEcho(t *testing.T) {
+	requireDocker(t)
+	if docker.ImageDigest(echoImage) == "" {
+		t.Skipf("%s has not been built", echoImage)
+	}
+}
+
+// echoOptions returns the options for a run on path with the event that the
+// fixture workspaces configure.
+func echoOptions(path string) Options {
+	return Options{
+		File:       path,
+		Event:      "Echo",
+		Repo:       DefaultRepo,
+		StayUp:     true,
+		Tag:        *dockerTag,
+		LocalKythe: *localKythe,
+	}
+}
+
+// runEcho runs shipshape with options and returns its results.
+func runEcho(t *testing.T, options Options) rpcpb.ShipshapeResponse {
+	var allResponses rpcpb.ShipshapeResponse
+	options.HandleResponse = func(shipshapeResp *rpcpb.ShipshapeResponse, _ string) error {
+		allResponses.AnalyzeResponse = append(allResponses.AnalyzeResponse, shipshapeResp.AnalyzeResponse...)
+		return nil
+	}
+	returnedNotesCount, err := New(options).Run()
+	if err != nil {
+		t.Fatalf("Run on %s failed: %v", options.File, err)
+	}
+	if got, want := countFailures(allResponses), 0; got != want {
+		t.Errorf("Run on %s: wrong number of failures; got %v, want %v (proto data: %v)", options.File, got, want, allResponses)
+	}
+	if countedNotes := countNotes(allResponses); returnedNotesCount != countedNotes {
+		t.Errorf("Run on %s: inconsistent note count: returned %v, counted %v (proto data: %v)", options.File, returnedNotesCount, countedNotes, allResponses)
+	}
+	return allResponses
+}
+
+// echoNotes describes the notes of category in resp, sorted.
+func echoNotes(resp rpcpb.ShipshapeResponse, category string) []string {
+	var notes []string
+	for _, analyzeResp := range resp.AnalyzeResponse {
+		for _, note := range analyzeResp.Note {
+			if note.GetCategory() == category {
+				notes = append(notes, fmt.Sprintf("%s:%d: %s", note.GetLocation().GetPath(), note.GetLocation().GetRange().GetStartLine(), note.GetDescription()))
+			}
+		}
+	}
+	sort.Strings(notes)
+	return notes
+}
+
+// expectedEchoNotes describes the notes of category that the echo analyzer
+// should find for a run on path, sorted. If path is a directory, the notes
+// are for all of the files under it, relative to it.
+func expectedEchoNotes(t *testing.T, path, category string) []string {
+	root, files := path, []string{filepath.Base(path)}
+	if fi, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if fi.IsDir() {
+		files = nil
+		err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || info.Name() == ".shipshape" {
+				return err
+			}
+			rel, err := filepath.Rel(path, p)
+			files = append(files, rel)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		root = filepath.Dir(path)
+	}
+
+	orgDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(orgDir)
+	var resp rpcpb.AnalyzeResponse
+	for _, f := range files {
+		notes, err := echo.Find(f, category)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Note = append(resp.Note, notes...)
+	}
+	return echoNotes(rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{&resp}}, category)
+}
+
+// checkEchoNotes checks that a run on path returned exactly the notes of
+// category that the markers in path call for.
+func checkEchoNotes(t *testing.T, resp rpcpb.ShipshapeResponse, path, category string) {
+	want := expectedEchoNotes(t, path, category)
+	if len(want) == 0 {
+		t.Fatalf("Fixture %s has no markers", path)
+	}
+	if got := echoNotes(resp, category); !reflect.DeepEqual(got, want) {
+		t.Errorf("Run on %s: wrong %s notes; got %q, want %q", path, category, got, want)
+	}
+}
 
-	// Run CLI using a .shipshape file
+func TestExternalAnalyzers(t *testing.T) {
+	requireEcho(t)
+	// The echo image and the event's categories come from the .shipshape file.
+	path := fixture("echo")
+	checkEchoNotes(t, runEcho(t, echoOptions(path)), path, "Echo")
 }
 
 func TestBuiltInAnalyzersPreBuild(t *testing.T) {
+	requireDocker(t)
 	options := Options{
-		File:                "shipshape/cli/testdata/workspace1",
+		File:                fixture("workspace1"),
 		ThirdPartyAnalyzers: []string{},
 		Build:               "",
 		TriggerCats:         []string{"PostMessage", "JSHint", "go vet", "PyLint"},
@@ -65,6 +216,7 @@ func TestBuiltInAnalyzersPreBuild(t *testing.T) {
 		StayUp:              true,
 		Tag:                 *dockerTag,
 		LocalKythe:          *localKythe,
+		Deterministic:       true,
 	}
 	var allResponses rpcpb.ShipshapeResponse
 	options.HandleResponse = func(shipshapeResp *rpcpb.ShipshapeResponse, _ string) error {
@@ -83,38 +235,128 @@ func TestBuiltInAnalyzersPreBuild(t *testing.T) {
 	if countedNotes := countNotes(allResponses); returnedNotesCount != countedNotes {
 		t.Errorf("%v: Inconsistent note count: returned %v, counted %v (proto data: %v", testName, returnedNotesCount, countedNotes, allResponses)
 	}
-	if got, want := returnedNotesCount, 39; got != want {
-		t.Errorf("%v: Wrong number of notes; got %v, want %v (proto data: %v)", testName, got, want, allResponses)
-	}
-	if got, want := countCategoryNotes(allResponses, "PostMessage"), 2; got != want {
-		t.Errorf("%v: Wrong number of PostMessage notes; got %v, want %v (proto data: %v)", testName, got, want, allResponses)
+
+	// Check for the issues that the workspace was written to have, rather than
+	// for exact totals, which change whenever an analyzer learns a new check.
+	notes := allNotes(allResponses)
+	expected := []test.NoteKey{
+		// One from each of the dispatchers with a PostMessage analyzer.
+		{Category: "PostMessage"},
+		{Category: "PostMessage"},
+		{Category: "go vet", Path: "test.go", Line: 9},
+		{Category: "JSHint", Path: "test.js", Line: 1},
+		{Category: "PyLint", Path: "test.py", Line: 2},
+		{Category: "PyLint", Path: "test.py", Line: 4},
+		{Category: "PyLint", Path: "test.py", Line: 12},
+		{Category: "PyLint", Path: "test.py", Line: 13},
 	}
-	if got, want := countCategoryNotes(allResponses, "JSHint"), 3; got != want {
-		t.Errorf("%v: Wrong number of JSHint notes; got %v, want %v (proto data: %v)", testName, got, want, allResponses)
+	if ok, msg := test.CheckNotesPresent(expected, notes); !ok {
+		t.Errorf("%v: %s", testName, msg)
 	}
-	if got, want := countCategoryNotes(allResponses, "go vet"), 1; got != want {
-		t.Errorf("%v: Wrong number of go vet notes; got %v, want %v (proto data: %v)", testName, got, want, allResponses)
+	counts := []struct {
+		category        string
+		want, tolerance int
+	}{
+		{"PostMessage", 2, 0},
+		{"go vet", 1, 0},
+		{"JSHint", 3, 1},
+		{"PyLint", 33, 5},
 	}
-	if got, want := countCategoryNotes(allResponses, "PyLint"), 33; got != want {
-		t.Errorf("%v: Wrong number of PyLint notes; got %v, want %v (proto data: %v)", testName, got, want, allResponses)
+	for _, c := range counts {
+		if ok, msg := test.CheckNoteCount(notes, c.category, c.want, c.tolerance); !ok {
+			t.Errorf("%v: %s", testName, msg)
+		}
 	}
 }
 
 func TestBuiltInAnalyzersPostBuild(t *testing.T) {
-	// Replaces part of the e2e test
-	// Test with a kythe maven build
-	// PostMessage and ErrorProne
+	requireEcho(t)
+	// The .shipshape file runs the echo analyzer in the post-build stage, so
+	// its notes only come back if the maven build with Kythe succeeded.
+	path := fixture("echo_build")
+	options := echoOptions(path)
+	options.Build = "maven"
+	resp := runEcho(t, options)
+	checkEchoNotes(t, resp, path, "EchoPostBuild")
+	if got, want := countCategoryNotes(resp, "Echo"), 0; got != want {
+		t.Errorf("Wrong number of pre-build Echo notes; got %v, want %v (proto data: %v)", got, want, resp)
+	}
 }
 
 func TestStreamsMode(t *testing.T) {
-	// Test whether it works in streams mode
-	// Before creating this, ensure that streams mode
-	// is actually still something we need to support.
+	// Test whether it works in streams mode. The protocol itself is
+	// covered by TestServeStreams in the service package.
 }
 
 func TestChangingDirectories(t *testing.T) {
-	// Replaces the changedir test
-	// Make sure to test changing down, changing up, running on the same directory, running on a single file in the same directory, and changing to a sibling
+	requireEcho(t)
+	// Deciding whether the service's workspace can be reused for each of these is covered by
+	// TestRelativePath in util/docker, and the paths of the notes by TestCanonicalNotePath.
+	// This checks that the service analyzes the right files after each change.
+	tests := []struct {
+		desc string
+		path string
+	}{
+		{"first run", "changedir/a"},
+		{"changing down", "changedir/a/b"},
+		{"changing up", "changedir/a"},
+		{"same directory", "changedir/a"},
+		{"single file in the same directory", "changedir/a/a.txt"},
+		{"changing to a sibling", "changedir/c"},
+	}
+	for _, test := range tests {
+		path := fixture(test.path)
+		t.Logf("%s: running on %s", test.desc, path)
+		checkEchoNotes(t, runEcho(t, echoOptions(path)), path, "Echo")
+	}
+}
+
+func TestHandleResponseFailFast(t *testing.T) {
+	response := func(severities ...notepb.Note_Severity) *rpcpb.ShipshapeResponse {
+		ar := &rpcpb.AnalyzeResponse{}
+		for _, s := range severities {
+			ar.Note = append(ar.Note, &notepb.Note{Severity: s.Enum()})
+		}
+		return &rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{ar}}
+	}
+	tests := []struct {
+		desc     string
+		severity notepb.Note_Severity
+		msg      *rpcpb.ShipshapeResponse
+		suppress bool
+		want     bool
+	}{
+		{"no notes", notepb.Note_BUILD_ERROR, response(), false, false},
+		{"less severe notes", notepb.Note_BUILD_ERROR, response(notepb.Note_WARNING, notepb.Note_OTHER), false, false},
+		{"a note at the severity", notepb.Note_BUILD_ERROR, response(notepb.Note_OTHER, notepb.Note_BUILD_ERROR), false, true},
+		{"a more severe note", notepb.Note_WARNING, response(notepb.Note_BUILD_ERROR), false, true},
+		{"a suppressed note", notepb.Note_BUILD_ERROR, response(notepb.Note_BUILD_ERROR), true, false},
+	}
+	for _, test := range tests {
+		suppress := test.suppress
+		i := New(Options{
+			FailFast:         true,
+			FailFastSeverity: test.severity,
+			HandleResponse: func(msg *rpcpb.ShipshapeResponse, directory string) error {
+				if suppress {
+					msg.AnalyzeResponse[0].Note = nil
+				}
+				return nil
+			},
+		})
+		err := i.handleResponse(test.msg, "")
+		if got := err == errFailFast; got != test.want {
+			t.Errorf("%s: got error %v, want fail fast %v", test.desc, err, test.want)
+		}
+		if got := i.FailedFast(); got != test.want {
+			t.Errorf("%s: FailedFast() = %v, want %v", test.desc, got, test.want)
+		}
+	}
+
+	i := New(Options{HandleResponse: func(*rpcpb.ShipshapeResponse, string) error { return nil }})
+	if err := i.handleResponse(response(notepb.Note_BUILD_ERROR), ""); err != nil || i.FailedFast() {
+		t.Errorf("Without FailFast: got error %v and FailedFast() = %v, want neither", err, i.FailedFast())
+	}
 }
 
 func dumpLogs() {
@@ -0,0 +1,336 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// RunState is what the results of a run depend on. A run with the same state as
+// an earlier one would find the same notes, so its CacheKey decides whether the
+// results of the earlier run can be shown instead.
+type RunState struct {
+	// Target is the absolute path of the file or directory analyzed.
+	Target string `json:"target"`
+	// Workspace is the WorkspaceHash of the files analyzed.
+	Workspace string `json:"workspace"`
+	// Config is the hex SHA-256 of the .shipshape file, if there is one.
+	Config string `json:"config,omitempty"`
+	// Images maps each image used to its digest.
+	Images     map[string]string `json:"images"`
+	Event      string            `json:"event"`
+	Categories []string          `json:"categories,omitempty"`
+	Build      string            `json:"build,omitempty"`
+	// Stages are the stages analyzed, if they were given.
+	Stages []string `json:"stages,omitempty"`
+	// Deterministic is whether the analyzers ignored their configuration in
+	// the workspace.
+	Deterministic bool `json:"deterministic,omitempty"`
+	// ToolConfig maps the files at the top of the workspace whose names start
+	// with a dot, such as a .pylintrc, to their hex SHA-256. They are not
+	// analyzed, so the Workspace hash leaves them out, but the analyzers may read
+	// them. They are only recorded if the run is not Deterministic.
+	ToolConfig map[string]string `json:"tool_config,omitempty"`
+	// AnalyzerEnv is the hex SHA-256 of the environments that the analyzer
+	// containers are started with, once the variables in them are expanded.
+	AnalyzerEnv string `json:"analyzer_env,omitempty"`
+	// Compilations is the WorkspaceHash of the compilation units imported for the
+	// POST_BUILD stage, if they were, rather than extracted from the build.
+	Compilations string `json:"compilations,omitempty"`
+	// CLIVersion is the Version of the CLI that ran.
+	CLIVersion string `json:"cli_version"`
+}
+
+// CacheKey returns the key of the results of a run with the state.
+func (s RunState) CacheKey() *CacheKey {
+	k := NewCacheKey()
+	k.Add("target", s.Target)
+	k.Add("workspace", s.Workspace)
+	k.Add("config", s.Config)
+	k.AddAll("image", s.Images)
+	k.Add("event", s.Event)
+	cats := append([]string(nil), s.Categories...)
+	sort.Strings(cats)
+	k.Add("categories", strings.Join(cats, ","))
+	k.Add("build", s.Build)
+	k.Add("stages", strings.Join(s.Stages, ","))
+	k.Add("deterministic", strconv.FormatBool(s.Deterministic))
+	k.AddAll("tool_config", s.ToolConfig)
+	k.Add("analyzer_env", s.AnalyzerEnv)
+	k.Add("compilations", s.Compilations)
+	k.Add("cli_version", s.CLIVersion)
+	return k
+}
+
+// Snapshot records the state of a run and the responses it got, so that a run
+// with the same state can show them instead of analyzing again.
+type Snapshot struct {
+	State     RunState                   `json:"state"`
+	Timestamp time.Time                  `json:"timestamp"`
+	Responses []*rpcpb.ShipshapeResponse `json:"responses"`
+}
+
+// Matches returns whether the snapshot was taken of a run with state.
+func (s *Snapshot) Matches(state RunState) bool {
+	return s.State.CacheKey().Sum() == state.CacheKey().Sum()
+}
+
+// Changed returns the names of the inputs of the CacheKey that differ between
+// the run the snapshot was taken of and a run with state.
+func (s *Snapshot) Changed(state RunState) []string {
+	return s.State.CacheKey().Changed(state.CacheKey())
+}
+
+// ChangedBesidesImages is Changed leaving out the images, so that it can tell
+// that a run needs to analyze again before the images are pulled to look up
+// their digests.
+func (s *Snapshot) ChangedBesidesImages(state RunState) []string {
+	state.Images = s.State.Images
+	return s.Changed(state)
+}
+
+// WorkspaceHash returns the hex SHA-256 of the paths and contents of files,
+// which are relative to root.
+func WorkspaceHash(root string, files []string) (string, error) {
+	sorted := append([]string(nil), files...)
+	sort.Strings(sorted)
+	h := sha256.New()
+	for _, file := range sorted {
+		f, err := os.Open(filepath.Join(root, file))
+		if err != nil {
+			return "", err
+		}
+		fi, err := f.Stat()
+		if err == nil {
+			// The size separates the contents of one file from the path of the next.
+			fmt.Fprintf(h, "%s\x00%d\x00", file, fi.Size())
+			_, err = io.Copy(h, f)
+		}
+		f.Close()
+		if err != nil {
+			return "", fmt.Errorf("could not hash %s: %v", file, err)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ToolConfigHashes returns the hex SHA-256 of each file at the top of root
+// whose name starts with a dot, other than the .shipshape file, keyed by name.
+// These are where analyzers usually look for their configuration.
+func ToolConfigHashes(root string) (map[string]string, error) {
+	infos, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make(map[string]string)
+	for _, fi := range infos {
+		name := fi.Name()
+		if !fi.Mode().IsRegular() || !strings.HasPrefix(name, ".") || name == ".shipshape" {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			return nil, fmt.Errorf("could not hash %s: %v", name, err)
+		}
+		sum := sha256.Sum256(b)
+		hashes[name] = hex.EncodeToString(sum[:])
+	}
+	return hashes, nil
+}
+
+// EnvironmentHash returns the hex SHA-256 of the environments of the analyzer
+// containers, keyed by image, or the empty string if there are none.
+func EnvironmentHash(envs map[string]map[string]string) (string, error) {
+	if len(envs) == 0 {
+		return "", nil
+	}
+	// Maps are marshalled with sorted keys, so the hash is stable.
+	b, err := json.Marshal(envs)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// LoadSnapshot loads the snapshot of the last run on target from dir. If
+// there is none, it returns nil.
+func LoadSnapshot(dir, target string) (*Snapshot, error) {
+	b, err := ioutil.ReadFile(snapshotPath(dir, target))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var s Snapshot
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("could not parse snapshot of %s: %v", target, err)
+	}
+	return &s, nil
+}
+
+// Save writes the snapshot to dir, replacing that of the last run on the same
+// target.
+func (s *Snapshot) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	path := snapshotPath(dir, s.State.Target)
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// snapshotPath returns the file in dir that has the snapshot of the last run
+// on target.
+func snapshotPath(dir, target string) string {
+	sum := sha256.Sum256([]byte(target))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// cacheCounters is the file in a snapshot directory that counts the runs that
+// showed the results of an earlier run, and those that did not.
+const cacheCounters = "counters"
+
+type counters struct {
+	Hits   int `json:"hits"`
+	Misses int `json:"misses"`
+}
+
+// CacheStats describes the results cached in a snapshot directory.
+type CacheStats struct {
+	// Entries is the number of snapshots, one per target, and Bytes their size.
+	Entries int
+	Bytes   int64
+	// Oldest and Newest are when the least and most recently saved snapshots
+	// were saved, if there are any.
+	Oldest, Newest time.Time
+	// Hits counts the runs that showed the results of an earlier run, and
+	// Misses those that analyzed, since the cache was last cleared.
+	Hits, Misses int
+}
+
+// RecordLookup counts a run that looked for the results of an earlier run in
+// the snapshot directory dir, and found them if hit is set.
+func RecordLookup(dir string, hit bool) error {
+	path := filepath.Join(dir, cacheCounters)
+	var c counters
+	if b, err := ioutil.ReadFile(path); err == nil {
+		// A corrupt file just starts the counts again.
+		json.Unmarshal(b, &c)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if hit {
+		c.Hits++
+	} else {
+		c.Misses++
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// ReadCacheStats describes the snapshots in dir, which need not exist.
+func ReadCacheStats(dir string) (CacheStats, error) {
+	var stats CacheStats
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return stats, nil
+	} else if err != nil {
+		return stats, err
+	}
+	for _, fi := range infos {
+		if fi.Name() == cacheCounters {
+			b, err := ioutil.ReadFile(filepath.Join(dir, cacheCounters))
+			if err != nil {
+				return stats, err
+			}
+			var c counters
+			if err := json.Unmarshal(b, &c); err == nil {
+				stats.Hits, stats.Misses = c.Hits, c.Misses
+			}
+			continue
+		}
+		if !isSnapshot(fi) {
+			continue
+		}
+		stats.Entries++
+		stats.Bytes += fi.Size()
+		if stats.Oldest.IsZero() || fi.ModTime().Before(stats.Oldest) {
+			stats.Oldest = fi.ModTime()
+		}
+		if fi.ModTime().After(stats.Newest) {
+			stats.Newest = fi.ModTime()
+		}
+	}
+	return stats, nil
+}
+
+// ClearCache removes the snapshots in dir and resets its counts, so that the
+// next run on each target analyzes again. It returns how many snapshots it
+// removed.
+func ClearCache(dir string) (int, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, fi := range infos {
+		if fi.Name() != cacheCounters && !isSnapshot(fi) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, fi.Name())); err != nil {
+			return removed, err
+		}
+		if fi.Name() != cacheCounters {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// isSnapshot returns whether fi is a snapshot file, as named by snapshotPath.
+func isSnapshot(fi os.FileInfo) bool {
+	return fi.Mode().IsRegular() && filepath.Ext(fi.Name()) == ".json"
+}
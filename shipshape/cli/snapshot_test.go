@@ -0,0 +1,209 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func TestWorkspaceHash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Could not write %s: %v", name, err)
+		}
+	}
+	hash := func(files ...string) string {
+		h, err := WorkspaceHash(dir, files)
+		if err != nil {
+			t.Fatalf("Could not hash %v: %v", files, err)
+		}
+		return h
+	}
+
+	write("a", "ab")
+	write("b", "c")
+	before := hash("a", "b")
+	if got := hash("b", "a"); got != before {
+		t.Errorf("Hash depends on the order of the files: got %s, want %s", got, before)
+	}
+	write("a", "a")
+	write("b", "bc")
+	if got := hash("a", "b"); got == before {
+		t.Errorf("Hash did not change when content moved between files")
+	}
+	if got := hash("a"); got == hash("b") {
+		t.Errorf("Different files have the same hash")
+	}
+	if _, err := WorkspaceHash(dir, []string{"missing"}); err == nil {
+		t.Errorf("Expected an error hashing a missing file")
+	}
+}
+
+func TestSnapshotSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if s, err := LoadSnapshot(dir, "/src"); s != nil || err != nil {
+		t.Errorf("Wrong snapshot when there is none: got %v, %v", s, err)
+	}
+
+	state := RunState{
+		Target:     "/src",
+		Workspace:  "abc",
+		Images:     map[string]string{"service": "sha256:123"},
+		Event:      "manual",
+		Categories: []string{"PyLint"},
+	}
+	s := &Snapshot{
+		State:     state,
+		Timestamp: time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC),
+		Responses: []*rpcpb.ShipshapeResponse{{AnalyzeResponse: []*rpcpb.AnalyzeResponse{{
+			Note: []*notepb.Note{{Category: proto.String("PyLint"), Description: proto.String("A note"), Severity: notepb.Note_WARNING.Enum()}},
+		}}}},
+	}
+	if err := s.Save(filepath.Join(dir, "snapshots")); err != nil {
+		t.Fatalf("Could not save snapshot: %v", err)
+	}
+	got, err := LoadSnapshot(filepath.Join(dir, "snapshots"), "/src")
+	if err != nil {
+		t.Fatalf("Could not load snapshot: %v", err)
+	}
+	if !got.Matches(state) || !got.Timestamp.Equal(s.Timestamp) || !proto.Equal(got.Responses[0], s.Responses[0]) {
+		t.Errorf("Wrong snapshot: got %v, want %v", got, s)
+	}
+	state.Workspace = "abd"
+	state.ToolConfig = map[string]string{".pylintrc": "def"}
+	if got.Matches(state) {
+		t.Errorf("Snapshot matches a changed workspace")
+	}
+	if changed, want := got.Changed(state), []string{"tool_config/.pylintrc", "workspace"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("Wrong changed inputs: got %v, want %v", changed, want)
+	}
+	// Before the images are pulled, the state has no digests.
+	state.Images = map[string]string{}
+	if changed, want := got.ChangedBesidesImages(state), []string{"tool_config/.pylintrc", "workspace"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("Wrong changed inputs besides the images: got %v, want %v", changed, want)
+	}
+	unpulled := s.State
+	unpulled.Images = map[string]string{}
+	if changed := got.ChangedBesidesImages(unpulled); len(changed) != 0 {
+		t.Errorf("Wrong changed inputs besides the images of the same state: got %v, want none", changed)
+	}
+	if other, err := LoadSnapshot(filepath.Join(dir, "snapshots"), "/other"); other != nil || err != nil {
+		t.Errorf("Wrong snapshot of another target: got %v, %v", other, err)
+	}
+}
+
+func TestToolConfigHashes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{".pylintrc", ".shipshape", "a.py", ".git/config"} {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Could not write %s: %v", name, err)
+		}
+	}
+	hashes, err := ToolConfigHashes(dir)
+	if err != nil {
+		t.Fatalf("Could not hash the tool config: %v", err)
+	}
+	var names []string
+	for name := range hashes {
+		names = append(names, name)
+	}
+	if want := []string{".pylintrc"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Wrong tool config files: got %v, want %v", names, want)
+	}
+}
+
+func TestEnvironmentHash(t *testing.T) {
+	if h, err := EnvironmentHash(nil); h != "" || err != nil {
+		t.Errorf("Wrong hash of no environments: got %q, %v", h, err)
+	}
+	env := map[string]map[string]string{"analyzer": {"TOKEN": "a", "LEVEL": "1"}}
+	h, err := EnvironmentHash(env)
+	if err != nil {
+		t.Fatalf("Could not hash environments: %v", err)
+	}
+	env["analyzer"]["LEVEL"] = "2"
+	if changed, _ := EnvironmentHash(env); changed == h {
+		t.Errorf("Hash did not change with the environment")
+	}
+}
+
+func TestCacheStatsAndClear(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	snapshots := filepath.Join(dir, "snapshots")
+
+	if stats, err := ReadCacheStats(snapshots); err != nil || !reflect.DeepEqual(stats, CacheStats{}) {
+		t.Errorf("Wrong stats of a missing cache: got %+v, %v", stats, err)
+	}
+	for _, target := range []string{"/a", "/b"} {
+		s := &Snapshot{State: RunState{Target: target}, Timestamp: time.Now()}
+		if err := s.Save(snapshots); err != nil {
+			t.Fatalf("Could not save snapshot: %v", err)
+		}
+	}
+	for _, hit := range []bool{true, false, false} {
+		if err := RecordLookup(snapshots, hit); err != nil {
+			t.Fatalf("Could not record lookup: %v", err)
+		}
+	}
+
+	stats, err := ReadCacheStats(snapshots)
+	if err != nil {
+		t.Fatalf("Could not read stats: %v", err)
+	}
+	if stats.Entries != 2 || stats.Bytes == 0 || stats.Hits != 1 || stats.Misses != 2 || stats.Oldest.After(stats.Newest) {
+		t.Errorf("Wrong stats: got %+v, want 2 entries, 1 hit and 2 misses", stats)
+	}
+
+	if removed, err := ClearCache(snapshots); removed != 2 || err != nil {
+		t.Errorf("Wrong result of clearing the cache: got %d, %v, want 2 removed", removed, err)
+	}
+	if stats, err := ReadCacheStats(snapshots); err != nil || !reflect.DeepEqual(stats, CacheStats{}) {
+		t.Errorf("Wrong stats of a cleared cache: got %+v, %v", stats, err)
+	}
+	if s, err := LoadSnapshot(snapshots, "/a"); s != nil || err != nil {
+		t.Errorf("Snapshot left after clearing the cache: got %v, %v", s, err)
+	}
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/shipshape/shipshape/service"
+	glog "github.com/google/shipshape/third_party/go-glog"
+
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+)
+
+// ParseStages parses a list of stage names, such as PRE_BUILD, POST_BUILD
+// or SECURITY.
+func ParseStages(names []string) ([]ctxpb.Stage, error) {
+	var stages []ctxpb.Stage
+	for _, name := range names {
+		s, err := service.ParseStage(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, s)
+	}
+	return stages, nil
+}
+
+// stages returns the stages that the run analyzes, in order. Unless they
+// were given, the PRE_BUILD stage is analyzed, followed by the POST_BUILD one
+// if there is a build or compilation units are imported.
+func (i *Invocation) stages() []ctxpb.Stage {
+	if len(i.options.Stages) > 0 {
+		return i.options.Stages
+	}
+	stages := []ctxpb.Stage{ctxpb.Stage_PRE_BUILD}
+	if i.options.Build != "" || i.options.ImportCompilations != "" {
+		stages = append(stages, ctxpb.Stage_POST_BUILD)
+	}
+	return stages
+}
+
+// runsPostBuild returns whether the run analyzes the POST_BUILD stage, and so
+// needs the compilation units of the build.
+func (i *Invocation) runsPostBuild() bool {
+	for _, s := range i.stages() {
+		if s == ctxpb.Stage_POST_BUILD {
+			return true
+		}
+	}
+	return false
+}
+
+// extracts returns whether the run extracts the compilation units of the build
+// with the kythe image, rather than importing them.
+func (i *Invocation) extracts() bool {
+	return i.runsPostBuild() && i.options.ImportCompilations == ""
+}
+
+// configureBuild sets the build system from the analyzers configured in the
+// .shipshape file in absRoot when neither a build system nor compilation units
+// to import were given and one of the triggered categories declares that it
+// needs a build, so that POST_BUILD is analyzed for it. It is an error for the
+// triggered categories to need different build systems.
+func (i *Invocation) configureBuild(absRoot string) error {
+	if i.options.Build != "" || i.options.ImportCompilations != "" {
+		return nil
+	}
+	configs, err := service.AnalyzerConfigs(absRoot)
+	if err != nil || len(configs) == 0 {
+		// The service reports the broken config when it loads it.
+		return nil
+	}
+	builds := make(map[string]string)
+	for _, c := range configs {
+		if c.GetBuild() != "" {
+			builds[c.GetCategory()] = c.GetBuild()
+		}
+	}
+	var category string
+	for _, c := range i.triggeredCategories(absRoot) {
+		build, ok := builds[c]
+		if !ok {
+			continue
+		}
+		if category == "" {
+			category = c
+			i.options.Build = build
+		} else if build != i.options.Build {
+			return fmt.Errorf("categories %s and %s need different build systems (%s and %s): pass --build", category, c, i.options.Build, build)
+		}
+	}
+	if category != "" {
+		glog.Infof("Category %s needs the POST_BUILD stage, building with %s", category, i.options.Build)
+	}
+	return nil
+}
+
+// checkStages returns an error if the stages cannot be run, because the
+// POST_BUILD stage needs a build system to generate the compilation units or
+// units to import, or if the compilation units cannot be imported or exported.
+func (i *Invocation) checkStages() error {
+	if i.runsPostBuild() && i.options.Build == "" && i.options.ImportCompilations == "" {
+		return fmt.Errorf("the POST_BUILD stage needs a build system to generate the compilation units: pass --build, or --import_compilations")
+	}
+	if i.options.ImportCompilations != "" {
+		if i.options.ExportCompilations != "" {
+			return fmt.Errorf("imported compilation units can't be exported: pass only one of --import_compilations and --export_compilations")
+		}
+		if !i.runsPostBuild() {
+			return fmt.Errorf("compilation units are only used by the POST_BUILD stage, which is not analyzed")
+		}
+		n, err := countCompilations(i.options.ImportCompilations)
+		if err != nil {
+			return fmt.Errorf("could not read the compilation units to import: %v", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("there are no compilation units to import in %s", i.options.ImportCompilations)
+		}
+	}
+	if i.options.ExportCompilations != "" && !i.extracts() {
+		return fmt.Errorf("compilation units are only extracted for the POST_BUILD stage, which is not analyzed: pass --build")
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+)
+
+func TestParseStages(t *testing.T) {
+	got, err := ParseStages([]string{"POST_BUILD", " PRE_BUILD", "DEPENDENCIES"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := []ctxpb.Stage{ctxpb.Stage_POST_BUILD, ctxpb.Stage_PRE_BUILD, ctxpb.Stage_DEPENDENCIES}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong stages: got %v, want %v", got, want)
+	}
+	if _, err := ParseStages([]string{"DEPLOY"}); err == nil {
+		t.Errorf("Expected an error for an unknown stage")
+	}
+}
+
+func TestStages(t *testing.T) {
+	tests := []struct {
+		options Options
+		want    []ctxpb.Stage
+		err     bool
+	}{
+		{Options{}, []ctxpb.Stage{ctxpb.Stage_PRE_BUILD}, false},
+		{Options{Build: "maven"}, []ctxpb.Stage{ctxpb.Stage_PRE_BUILD, ctxpb.Stage_POST_BUILD}, false},
+		{Options{Build: "maven", Stages: []ctxpb.Stage{ctxpb.Stage_POST_BUILD}}, []ctxpb.Stage{ctxpb.Stage_POST_BUILD}, false},
+		{Options{Stages: []ctxpb.Stage{ctxpb.Stage_POST_BUILD}}, []ctxpb.Stage{ctxpb.Stage_POST_BUILD}, true},
+		{Options{Build: "maven", ExportCompilations: "/tmp/units"}, []ctxpb.Stage{ctxpb.Stage_PRE_BUILD, ctxpb.Stage_POST_BUILD}, false},
+		{Options{ExportCompilations: "/tmp/units"}, []ctxpb.Stage{ctxpb.Stage_PRE_BUILD}, true},
+	}
+	for _, test := range tests {
+		i := New(test.options)
+		if got := i.stages(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Wrong stages for %+v: got %v, want %v", test.options, got, test.want)
+		}
+		if err := i.checkStages(); (err != nil) != test.err {
+			t.Errorf("Wrong error for %+v: got %v, want an error: %v", test.options, err, test.err)
+		}
+	}
+}
+
+func TestConfigureBuild(t *testing.T) {
+	root, err := ioutil.TempDir("", "configure-build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	writeFiles(t, root, map[string]string{".shipshape": `
+events:
+  - event: default
+    categories:
+      - ErrorProne
+      - PyLint
+analyzers:
+  - category: ErrorProne
+    build: maven
+  - category: Infer
+    build: gradle
+`})
+
+	tests := []struct {
+		options Options
+		want    string
+		err     bool
+	}{
+		{Options{Event: "default"}, "maven", false},
+		{Options{TriggerCats: []string{"PyLint"}}, "", false},
+		{Options{TriggerCats: []string{"Infer", "PyLint"}}, "gradle", false},
+		{Options{TriggerCats: []string{"ErrorProne", "Infer"}}, "", true},
+		{Options{TriggerCats: []string{"ErrorProne", "Infer"}, Build: "go"}, "go", false},
+		{Options{TriggerCats: []string{"ErrorProne"}, ImportCompilations: "/tmp/units"}, "", false},
+	}
+	for _, test := range tests {
+		i := New(test.options)
+		err := i.configureBuild(root)
+		if (err != nil) != test.err {
+			t.Errorf("Wrong error for %+v: got %v, want an error: %v", test.options, err, test.err)
+		}
+		if err == nil && i.options.Build != test.want {
+			t.Errorf("Wrong build for %+v: got %q, want %q", test.options, i.options.Build, test.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// subscriptionBuffer is how many notes a subscription holds before Run waits for
+// the subscriber to receive them.
+const subscriptionBuffer = 100
+
+// subscription is a channel that the notes of some categories are sent on.
+type subscription struct {
+	// categories are the categories of the notes to send, or nil for all of them.
+	categories map[string]bool
+	notes      chan *notepb.Note
+}
+
+// Subscribe returns a channel that each note of the categories, or of every
+// category if none are given, is sent on as soon as Run receives it, once
+// HandleResponse has handled the response it is in. The notes are copies, so
+// they are safe to read while Run goes on. Each subscription gets its own
+// channel, which Run closes when it returns. Subscribe must be called before Run.
+//
+// The subscriber should keep receiving until the channel is closed: Run waits
+// for it once a subscription holds subscriptionBuffer notes.
+func (i *Invocation) Subscribe(categories ...string) <-chan *notepb.Note {
+	s := &subscription{notes: make(chan *notepb.Note, subscriptionBuffer)}
+	if len(categories) > 0 {
+		s.categories = make(map[string]bool)
+		for _, c := range categories {
+			s.categories[c] = true
+		}
+	}
+	i.subscriptions = append(i.subscriptions, s)
+	return s.notes
+}
+
+// publish sends the notes of msg to the subscriptions for their categories.
+func (i *Invocation) publish(msg *rpcpb.ShipshapeResponse) {
+	for _, analysis := range msg.AnalyzeResponse {
+		for _, note := range analysis.Note {
+			for _, s := range i.subscriptions {
+				if s.categories == nil || s.categories[note.GetCategory()] {
+					s.notes <- proto.Clone(note).(*notepb.Note)
+				}
+			}
+		}
+	}
+}
+
+// closeSubscriptions closes the channels of the subscriptions, once Run has
+// sent them all the notes.
+func (i *Invocation) closeSubscriptions() {
+	for _, s := range i.subscriptions {
+		close(s.notes)
+	}
+	i.subscriptions = nil
+}
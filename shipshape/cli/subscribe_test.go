@@ -0,0 +1,68 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func receiveCategories(notes <-chan *notepb.Note) []string {
+	var cats []string
+	for note := range notes {
+		cats = append(cats, note.GetCategory())
+	}
+	return cats
+}
+
+func TestSubscribe(t *testing.T) {
+	i := New(Options{HandleResponse: func(*rpcpb.ShipshapeResponse, string) error { return nil }})
+	errorProne := i.Subscribe("ErrorProne")
+	lint := i.Subscribe("JSHint", "PyLint")
+	all := i.Subscribe()
+
+	first := &rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{
+		{Note: append(makeNotes("ErrorProne", 1), makeNotes("PyLint", 1)...)},
+	}}
+	second := &rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{
+		{Note: makeNotes("JSHint", 1)},
+		{Note: makeNotes("go vet", 1)},
+	}}
+	for _, msg := range []*rpcpb.ShipshapeResponse{first, second} {
+		if err := i.handleResponse(msg, ""); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	// The subscribers get copies, which the run can go on changing.
+	first.AnalyzeResponse[0].Note[0].Category = proto.String("changed")
+	i.closeSubscriptions()
+
+	if got, want := receiveCategories(errorProne), []string{"ErrorProne"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong ErrorProne notes: got %v, want %v", got, want)
+	}
+	if got, want := receiveCategories(lint), []string{"PyLint", "JSHint"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong lint notes: got %v, want %v", got, want)
+	}
+	if got, want := receiveCategories(all), []string{"ErrorProne", "PyLint", "JSHint", "go vet"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong notes of all categories: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,212 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	strset "github.com/google/shipshape/shipshape/util/strings"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// SuppressionMarker starts an inline suppression comment. It is followed by
+// the comma-separated categories to suppress, each optionally with a
+// subcategory as Category:Subcategory, or by nothing to suppress all
+// categories. The comment applies to its own line, and to the next line if
+// it is on a line of its own.
+const SuppressionMarker = "shipshape:ignore"
+
+// InlineSuppressor removes notes that are suppressed by comments in their
+// source files. It reads each file at most once.
+type InlineSuppressor struct {
+	files map[string][]string
+}
+
+// NewInlineSuppressor returns an InlineSuppressor that has read no files.
+func NewInlineSuppressor() *InlineSuppressor {
+	return &InlineSuppressor{files: make(map[string][]string)}
+}
+
+// Filter removes the notes in resp, which were found in directory, that are
+// suppressed inline, and returns how many it removed.
+func (s *InlineSuppressor) Filter(resp *rpcpb.ShipshapeResponse, directory string) int {
+	removed := 0
+	for _, analysis := range resp.AnalyzeResponse {
+		var kept []*notepb.Note
+		for _, note := range analysis.Note {
+			if s.Suppressed(note, directory) {
+				removed++
+				continue
+			}
+			kept = append(kept, note)
+		}
+		analysis.Note = kept
+	}
+	return removed
+}
+
+// Suppressed is true if note, which was found in directory, is suppressed by
+// a comment on its line or on the line before it. Notes without a line cannot
+// be suppressed inline.
+func (s *InlineSuppressor) Suppressed(note *notepb.Note, directory string) bool {
+	path, line := note.GetLocation().GetPath(), int(note.GetLocation().GetRange().GetStartLine())
+	if path == "" || line == 0 {
+		return false
+	}
+	lines := s.lines(filepath.Join(directory, path))
+	if line > len(lines) {
+		return false
+	}
+	if cats, ok := parseSuppression(lines[line-1], false); ok && suppresses(cats, note) {
+		return true
+	}
+	if line > 1 {
+		if cats, ok := parseSuppression(lines[line-2], true); ok && suppresses(cats, note) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *InlineSuppressor) lines(path string) []string {
+	lines, ok := s.files[path]
+	if !ok {
+		if b, err := ioutil.ReadFile(path); err == nil {
+			lines = strings.Split(string(b), "\n")
+		}
+		s.files[path] = lines
+	}
+	return lines
+}
+
+// parseSuppression returns the categories suppressed by a comment in line, if
+// it has one. If alone is set, the comment must be on a line of its own.
+func parseSuppression(line string, alone bool) ([]string, bool) {
+	i := strings.Index(line, SuppressionMarker)
+	if i < 0 {
+		return nil, false
+	}
+	if alone && strings.Trim(line[:i], " \t/#-*!<;") != "" {
+		return nil, false
+	}
+	rest := strings.TrimSpace(line[i+len(SuppressionMarker):])
+	for _, end := range []string{"*/", "-->"} {
+		rest = strings.TrimSpace(strings.TrimSuffix(rest, end))
+	}
+	var cats []string
+	for _, c := range strings.Split(rest, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			cats = append(cats, c)
+		}
+	}
+	return cats, true
+}
+
+// suppresses is true if a suppression of cats applies to note. No categories
+// means all of them.
+func suppresses(cats []string, note *notepb.Note) bool {
+	if len(cats) == 0 {
+		return true
+	}
+	for _, c := range cats {
+		cat, sub := ParseCategory(c)
+		if cat == note.GetCategory() && (sub == "" || sub == note.GetSubcategory()) {
+			return true
+		}
+	}
+	return false
+}
+
+// InlineSuppression is a suppression comment to add to a file.
+type InlineSuppression struct {
+	// Line is the line of the note to suppress.
+	Line int
+	// Category is the category to suppress, optionally as Category:Subcategory.
+	Category string
+}
+
+// commentSyntax returns how a line comment starts and ends in the file at path.
+func commentSyntax(path string) (start, end string, ok bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".go", ".java", ".js", ".ts", ".c", ".cc", ".cpp", ".h", ".hpp", ".cs", ".kt", ".scala", ".swift", ".rs", ".dart", ".php", ".proto":
+		return "// ", "", true
+	case ".py", ".sh", ".bash", ".rb", ".pl", ".yaml", ".yml", ".r", ".bzl", ".toml", ".cfg", ".conf", ".mk":
+		return "# ", "", true
+	case ".sql", ".lua", ".hs":
+		return "-- ", "", true
+	case ".html", ".htm", ".xml", ".md":
+		return "<!-- ", " -->", true
+	case ".css":
+		return "/* ", " */", true
+	}
+	switch filepath.Base(path) {
+	case "BUILD", "Makefile", "Dockerfile":
+		return "# ", "", true
+	}
+	return "", "", false
+}
+
+// AddSuppressions adds comments to the file at path that suppress the given
+// notes. Each comment goes on its own line above the note, indented like it;
+// notes on the same line share a comment.
+func AddSuppressions(path string, sups []InlineSuppression) error {
+	start, end, ok := commentSyntax(path)
+	if !ok {
+		return fmt.Errorf("do not know how to write a comment in %s", path)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(b), "\n")
+	cats := make(map[int][]string)
+	for _, s := range sups {
+		if s.Line < 1 || s.Line > len(lines) {
+			return fmt.Errorf("%s has no line %d", path, s.Line)
+		}
+		if !strset.Contains(cats[s.Line], s.Category) {
+			cats[s.Line] = append(cats[s.Line], s.Category)
+		}
+	}
+	var targets []int
+	for line := range cats {
+		targets = append(targets, line)
+	}
+	// Insert from the bottom, so that the lines above keep their numbers.
+	sort.Sort(sort.Reverse(sort.IntSlice(targets)))
+	for _, line := range targets {
+		target := lines[line-1]
+		indent := target[:len(target)-len(strings.TrimLeft(target, " \t"))]
+		comment := indent + start + SuppressionMarker + " " + strings.Join(cats[line], ", ") + end
+		if strings.HasSuffix(target, "\r") {
+			comment += "\r"
+		}
+		lines = append(lines[:line-1], append([]string{comment}, lines[line-1:]...)...)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), fi.Mode())
+}
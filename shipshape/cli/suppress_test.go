@@ -0,0 +1,126 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+const suppressSource = `package main
+
+// shipshape:ignore go vet
+func a() {}
+
+func b() {} // shipshape:ignore PyLint:unused-import, JSHint
+
+x := 1 // shipshape:ignore
+func c() {}
+`
+
+func TestInlineSuppressor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "suppress_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(suppressSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	withSub := func(n *notepb.Note, sub string) *notepb.Note {
+		n.Subcategory = proto.String(sub)
+		return n
+	}
+	tests := []struct {
+		note *notepb.Note
+		want bool
+	}{
+		{tableNote("go vet", "main.go", 4, notepb.Note_WARNING, "comment on the line before"), true},
+		{tableNote("PyLint", "main.go", 4, notepb.Note_WARNING, "other category"), false},
+		{tableNote("JSHint", "main.go", 6, notepb.Note_WARNING, "trailing comment"), true},
+		{withSub(tableNote("PyLint", "main.go", 6, notepb.Note_WARNING, "matching subcategory"), "unused-import"), true},
+		{withSub(tableNote("PyLint", "main.go", 6, notepb.Note_WARNING, "other subcategory"), "unused-variable"), false},
+		{tableNote("go vet", "main.go", 8, notepb.Note_WARNING, "all categories"), true},
+		// A trailing comment does not apply to the next line.
+		{tableNote("go vet", "main.go", 9, notepb.Note_WARNING, "after a trailing comment"), false},
+		{tableNote("go vet", "main.go", 0, notepb.Note_WARNING, "no line"), false},
+		{tableNote("go vet", "missing.go", 4, notepb.Note_WARNING, "no file"), false},
+	}
+	s := NewInlineSuppressor()
+	for _, test := range tests {
+		if got := s.Suppressed(test.note, dir); got != test.want {
+			t.Errorf("Suppressed(%s): got %v, want %v", test.note.GetDescription(), got, test.want)
+		}
+	}
+
+	resp := &rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{{Note: []*notepb.Note{tests[0].note, tests[1].note}}}}
+	if got, want := s.Filter(resp, dir), 1; got != want {
+		t.Errorf("Wrong number of notes removed: got %d, want %d", got, want)
+	}
+}
+
+func TestAddSuppressions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "suppress_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	goFile := filepath.Join(dir, "main.go")
+	if err := ioutil.WriteFile(goFile, []byte("package main\n\nfunc a() {\n\tx := 1\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = AddSuppressions(goFile, []InlineSuppression{{4, "go vet"}, {3, "CodeAlert"}, {4, "PyLint:unused"}, {4, "go vet"}})
+	if err != nil {
+		t.Fatalf("AddSuppressions failed: %v", err)
+	}
+	got, _ := ioutil.ReadFile(goFile)
+	want := "package main\n\n// shipshape:ignore CodeAlert\nfunc a() {\n\t// shipshape:ignore go vet, PyLint:unused\n\tx := 1\n}\n"
+	if string(got) != want {
+		t.Errorf("Wrong suppressions:\ngot  %q\nwant %q", got, want)
+	}
+
+	// The added comments suppress the notes.
+	s := NewInlineSuppressor()
+	if !s.Suppressed(tableNote("go vet", "main.go", 6, notepb.Note_WARNING, "x"), dir) {
+		t.Errorf("Added comment does not suppress the note")
+	}
+
+	htmlFile := filepath.Join(dir, "index.html")
+	if err := ioutil.WriteFile(htmlFile, []byte("<p>\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddSuppressions(htmlFile, []InlineSuppression{{1, "HTMLLint"}}); err != nil {
+		t.Fatalf("AddSuppressions failed: %v", err)
+	}
+	got, _ = ioutil.ReadFile(htmlFile)
+	if want := "<!-- shipshape:ignore HTMLLint -->\r\n<p>\r\n"; string(got) != want {
+		t.Errorf("Wrong suppression:\ngot  %q\nwant %q", got, want)
+	}
+
+	if err := AddSuppressions(filepath.Join(dir, "data.bin"), []InlineSuppression{{1, "X"}}); err == nil {
+		t.Errorf("Expected an error for a file without known comment syntax")
+	}
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// The ways a note can be suppressed, as recorded in its suppressed_by.
+const (
+	SuppressedByBaseline = "baseline"
+	SuppressedInline     = "inline"
+	SuppressedByOverride = "override"
+)
+
+// suppressionDescriptions describe each way of suppressing notes for the text
+// output, in the order they are listed.
+var suppressionDescriptions = []struct {
+	mechanism, description string
+}{
+	{SuppressedByBaseline, "in the baseline"},
+	{SuppressedInline, "suppressed inline"},
+	{SuppressedByOverride, "suppressed by the overrides in .shipshape"},
+}
+
+// Suppressions leaves the notes that are suppressed, however that is, out of
+// the results, and counts them. Suppressed notes can be kept in the results,
+// apart from the others, to be shown.
+type Suppressions struct {
+	// Show keeps the notes that Filter removes in the suppressed_note of
+	// their analysis, with their suppressed_by set. Nothing that counts the
+	// notes in the results counts them there.
+	Show   bool
+	counts map[string]int
+}
+
+// NewSuppressions returns Suppressions that have counted no notes yet.
+func NewSuppressions(show bool) *Suppressions {
+	return &Suppressions{Show: show, counts: make(map[string]int)}
+}
+
+// Filter removes the notes in resp that suppressed returns true for, which
+// were suppressed by mechanism, and returns how many it removed.
+func (s *Suppressions) Filter(resp *rpcpb.ShipshapeResponse, mechanism string, suppressed func(*notepb.Note) bool) int {
+	removed := 0
+	for _, analysis := range resp.AnalyzeResponse {
+		var kept []*notepb.Note
+		for _, note := range analysis.Note {
+			if !suppressed(note) {
+				kept = append(kept, note)
+				continue
+			}
+			removed++
+			if s.Show {
+				note.SuppressedBy = proto.String(mechanism)
+				analysis.SuppressedNote = append(analysis.SuppressedNote, note)
+			}
+		}
+		analysis.Note = kept
+	}
+	s.counts[mechanism] += removed
+	return removed
+}
+
+// Total returns how many notes have been suppressed.
+func (s *Suppressions) Total() int {
+	total := 0
+	for _, n := range s.counts {
+		total += n
+	}
+	return total
+}
+
+// Counts returns how many notes have been suppressed in each way, to record
+// in the results, or nil if none have.
+func (s *Suppressions) Counts() *rpcpb.SuppressionCounts {
+	if s.Total() == 0 {
+		return nil
+	}
+	c := new(rpcpb.SuppressionCounts)
+	if n := s.counts[SuppressedByBaseline]; n > 0 {
+		c.Baseline = proto.Int32(int32(n))
+	}
+	if n := s.counts[SuppressedInline]; n > 0 {
+		c.Inline = proto.Int32(int32(n))
+	}
+	if n := s.counts[SuppressedByOverride]; n > 0 {
+		c.Override = proto.Int32(int32(n))
+	}
+	return c
+}
+
+// SuppressedCount returns the total of c.
+func SuppressedCount(c *rpcpb.SuppressionCounts) int {
+	return int(c.GetBaseline() + c.GetInline() + c.GetOverride())
+}
+
+// DescribeSuppressions describes c for the text output, e.g. "3 in the
+// baseline, 1 suppressed inline".
+func DescribeSuppressions(c *rpcpb.SuppressionCounts) string {
+	counts := map[string]int32{
+		SuppressedByBaseline: c.GetBaseline(),
+		SuppressedInline:     c.GetInline(),
+		SuppressedByOverride: c.GetOverride(),
+	}
+	var parts []string
+	for _, d := range suppressionDescriptions {
+		if n := counts[d.mechanism]; n > 0 {
+			parts = append(parts, Messages.Sprintf("%d "+d.description, n))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// DescribeSuppression describes how note was suppressed, e.g. "in the
+// baseline", or returns the empty string if it was not.
+func DescribeSuppression(note *notepb.Note) string {
+	for _, d := range suppressionDescriptions {
+		if d.mechanism == note.GetSuppressedBy() {
+			return Messages.Text(d.description)
+		}
+	}
+	return ""
+}
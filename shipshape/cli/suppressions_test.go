@@ -0,0 +1,85 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func TestSuppressionsFilter(t *testing.T) {
+	for _, show := range []bool{false, true} {
+		resp := &rpcpb.ShipshapeResponse{
+			AnalyzeResponse: []*rpcpb.AnalyzeResponse{
+				{Note: append(makeNotes("JSHint", 2), makeNotes("PyLint", 3)...)},
+			},
+		}
+		s := NewSuppressions(show)
+		isCategory := func(cat string) func(*notepb.Note) bool {
+			return func(note *notepb.Note) bool { return note.GetCategory() == cat }
+		}
+		if got, want := s.Filter(resp, SuppressedByBaseline, isCategory("PyLint")), 3; got != want {
+			t.Errorf("Filter with show %v removed %d notes, want %d", show, got, want)
+		}
+		if got, want := s.Filter(resp, SuppressedInline, isCategory("Nothing")), 0; got != want {
+			t.Errorf("Filter with show %v removed %d notes, want %d", show, got, want)
+		}
+		analysis := resp.AnalyzeResponse[0]
+		if got, want := len(analysis.Note), 2; got != want {
+			t.Errorf("Filter with show %v kept %d notes, want %d", show, got, want)
+		}
+		wantShown := 0
+		if show {
+			wantShown = 3
+		}
+		if got := len(analysis.SuppressedNote); got != wantShown {
+			t.Errorf("Filter with show %v kept %d suppressed notes, want %d", show, got, wantShown)
+		}
+		for _, note := range analysis.SuppressedNote {
+			if got, want := DescribeSuppression(note), "in the baseline"; got != want {
+				t.Errorf("DescribeSuppression(%v) = %q, want %q", note, got, want)
+			}
+		}
+		want := &rpcpb.SuppressionCounts{Baseline: proto.Int32(3)}
+		if got := s.Counts(); !proto.Equal(got, want) {
+			t.Errorf("Counts with show %v = %v, want %v", show, got, want)
+		}
+	}
+}
+
+func TestSuppressionsCountsNone(t *testing.T) {
+	if got := NewSuppressions(false).Counts(); got != nil {
+		t.Errorf("Counts with nothing suppressed = %v, want nil", got)
+	}
+}
+
+func TestDescribeSuppressions(t *testing.T) {
+	c := &rpcpb.SuppressionCounts{Baseline: proto.Int32(3), Override: proto.Int32(2), Inline: proto.Int32(1)}
+	if got, want := DescribeSuppressions(c), "3 in the baseline, 1 suppressed inline, 2 suppressed by the overrides in .shipshape"; got != want {
+		t.Errorf("DescribeSuppressions(%v) = %q, want %q", c, got, want)
+	}
+	if got, want := SuppressedCount(c), 6; got != want {
+		t.Errorf("SuppressedCount(%v) = %d, want %d", c, got, want)
+	}
+	if got := DescribeSuppression(makeNotes("PyLint", 1)[0]); got != "" {
+		t.Errorf("DescribeSuppression of a note that is not suppressed = %q, want empty", got)
+	}
+}
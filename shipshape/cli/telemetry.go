@@ -0,0 +1,266 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+const (
+	telemetryConfigFile = "telemetry.json"
+	// TelemetryLog is the file in the telemetry directory that events are
+	// appended to, so that users can see exactly what is recorded.
+	TelemetryLog = "telemetry.log"
+	// TelemetryEnv turns telemetry off for a process when set to "off", e.g.
+	// in CI, whatever the user's setting is.
+	TelemetryEnv = "SHIPSHAPE_TELEMETRY"
+)
+
+// builtInCategories are the categories of the analyzers that ship with
+// shipshape. Only these are named in telemetry, since the categories of
+// third-party analyzers may identify a project.
+var builtInCategories = map[string]bool{
+	"AndroidLint": true,
+	"CodeAlert":   true,
+	"ErrorProne":  true,
+	"go vet":      true,
+	"JSHint":      true,
+	"PostMessage": true,
+	"PyLint":      true,
+	"WordCount":   true,
+}
+
+// failureClasses map the start of the errors that Run returns to the class
+// of the failure recorded in telemetry. The errors themselves are not
+// recorded, since they may contain paths.
+var failureClasses = []struct {
+	prefix, class string
+}{
+	{"docker is not ready", "docker"},
+	{"HTTP client did not become healthy", "service_start"},
+	{"could not list the files", "workspace"},
+	{"error from run", "build"},
+	{"error making service call", "service_call"},
+}
+
+// TelemetryConfig is whether the user has opted in to telemetry. Telemetry
+// is off unless it has been turned on with `shipshape telemetry on`.
+type TelemetryConfig struct {
+	Enabled bool `json:"enabled"`
+	// ID is random, so that the runs of one user can be counted together
+	// without knowing who they are. It is replaced each time telemetry is
+	// turned on.
+	ID string `json:"id,omitempty"`
+	// Endpoint, if set, is where events are posted, as well as being logged.
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// TelemetryEvent is what is recorded about a run. It never has paths, note
+// content or the names of third-party analyzers.
+type TelemetryEvent struct {
+	ID      string `json:"id"`
+	Version string `json:"version"`
+	OS      string `json:"os"`
+	// Date is the day of the run, in UTC.
+	Date string `json:"date"`
+	// Categories are the built-in categories that the run was for, and
+	// OtherCategories counts the rest.
+	Categories      []string `json:"categories"`
+	OtherCategories int      `json:"other_categories,omitempty"`
+	Build           bool     `json:"build,omitempty"`
+	// Duration and Notes are buckets, such as "10s-1m" and "10-99".
+	Duration string `json:"duration"`
+	Notes    string `json:"notes"`
+	// Failure is the class of the error the run ended with, if any, and
+	// FailedCategories are the built-in categories that failed to run.
+	Failure          string   `json:"failure,omitempty"`
+	FailedCategories []string `json:"failed_categories,omitempty"`
+}
+
+// TelemetryDir is where the telemetry setting and log are kept.
+func TelemetryDir() string {
+	return filepath.Join(os.Getenv("HOME"), ".shipshape")
+}
+
+// LoadTelemetryConfig loads the telemetry setting from dir. If there is
+// none, telemetry is off.
+func LoadTelemetryConfig(dir string) (*TelemetryConfig, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, telemetryConfigFile))
+	if os.IsNotExist(err) {
+		return &TelemetryConfig{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var c TelemetryConfig
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %v", filepath.Join(dir, telemetryConfigFile), err)
+	}
+	return &c, nil
+}
+
+// SetTelemetry turns telemetry on or off in dir, and returns the new setting.
+// Turning it on picks a new ID; turning it off forgets the ID.
+func SetTelemetry(dir string, on bool, endpoint string) (*TelemetryConfig, error) {
+	c := &TelemetryConfig{Enabled: on, Endpoint: endpoint}
+	if on {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("could not generate a telemetry ID: %v", err)
+		}
+		c.ID = hex.EncodeToString(b)
+	}
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return c, ioutil.WriteFile(filepath.Join(dir, telemetryConfigFile), append(b, '\n'), 0644)
+}
+
+// TelemetryEnabled returns whether events should be recorded with c in an
+// environment read by getenv.
+func TelemetryEnabled(c *TelemetryConfig, getenv func(string) string) bool {
+	return c.Enabled && c.ID != "" && getenv(TelemetryEnv) != "off" && getenv("DO_NOT_TRACK") == ""
+}
+
+// NewTelemetryEvent describes a run for the categories that took d, found
+// numNotes notes in resp and ended with runErr.
+func NewTelemetryEvent(id string, categories []string, build bool, d time.Duration, numNotes int, runErr error, resp *rpcpb.ShipshapeResponse) TelemetryEvent {
+	e := TelemetryEvent{
+		ID:         id,
+		Version:    Version,
+		OS:         runtime.GOOS,
+		Date:       time.Now().UTC().Format("2006-01-02"),
+		Categories: []string{},
+		Build:      build,
+		Duration:   durationBucket(d),
+		Notes:      countBucket(numNotes),
+	}
+	for _, cat := range categories {
+		if builtInCategories[cat] {
+			e.Categories = append(e.Categories, cat)
+		} else {
+			e.OtherCategories++
+		}
+	}
+	sort.Strings(e.Categories)
+	if runErr != nil {
+		e.Failure = failureClass(runErr)
+	}
+	failed := make(map[string]bool)
+	for _, analysis := range resp.AnalyzeResponse {
+		for _, f := range analysis.Failure {
+			if builtInCategories[f.GetCategory()] && !failed[f.GetCategory()] {
+				failed[f.GetCategory()] = true
+				e.FailedCategories = append(e.FailedCategories, f.GetCategory())
+			}
+		}
+	}
+	sort.Strings(e.FailedCategories)
+	return e
+}
+
+// RecordTelemetry appends e to the log in dir, and posts it to the endpoint
+// of c, if there is one. Posting gives up quickly, so that telemetry never
+// holds up a run.
+func RecordTelemetry(dir string, c *TelemetryConfig, e TelemetryEvent) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, TelemetryLog), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || c.Endpoint == "" {
+		return err
+	}
+	client := http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Post(c.Endpoint, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("could not post telemetry: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("telemetry endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// failureClass returns the class of err for telemetry.
+func failureClass(err error) string {
+	msg := err.Error()
+	for _, c := range failureClasses {
+		if strings.HasPrefix(msg, c.prefix) {
+			if c.class == "service_call" && strings.Contains(msg, errInterrupted.Error()) {
+				return "interrupted"
+			}
+			return c.class
+		}
+	}
+	return "other"
+}
+
+// durationBucket returns the range that d is in.
+func durationBucket(d time.Duration) string {
+	switch {
+	case d < 10*time.Second:
+		return "<10s"
+	case d < time.Minute:
+		return "10s-1m"
+	case d < 5*time.Minute:
+		return "1m-5m"
+	case d < 30*time.Minute:
+		return "5m-30m"
+	}
+	return ">30m"
+}
+
+// countBucket returns the order of magnitude of n.
+func countBucket(n int) string {
+	switch {
+	case n == 0:
+		return "0"
+	case n < 10:
+		return "1-9"
+	case n < 100:
+		return "10-99"
+	case n < 1000:
+		return "100-999"
+	}
+	return "1000+"
+}
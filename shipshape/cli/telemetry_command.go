@@ -0,0 +1,99 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/shipshape/shipshape/cli"
+	glog "github.com/google/shipshape/third_party/go-glog"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// telemetryCommand implements `shipshape telemetry on|off|status`, which
+// opts in to or out of recording anonymous usage metrics.
+func telemetryCommand(args []string) int {
+	fs := flag.NewFlagSet("telemetry", flag.ExitOnError)
+	endpoint := fs.String("endpoint", "", "With on, also post each event to this URL")
+	fs.Usage = func() {
+		fmt.Println("USAGE: shipshape telemetry on|off|status")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return returnError
+	}
+
+	dir := cli.TelemetryDir()
+	switch fs.Arg(0) {
+	case "on", "off":
+		if _, err := cli.SetTelemetry(dir, fs.Arg(0) == "on", *endpoint); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return returnError
+		}
+		fmt.Printf("Telemetry is %s.\n", fs.Arg(0))
+	case "status":
+		c, err := cli.LoadTelemetryConfig(dir)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return returnError
+		}
+		switch {
+		case !c.Enabled:
+			fmt.Println("Telemetry is off. Turn it on with 'shipshape telemetry on'.")
+		case !cli.TelemetryEnabled(c, os.Getenv):
+			fmt.Printf("Telemetry is on, but turned off in this environment by %s=off or DO_NOT_TRACK.\n", cli.TelemetryEnv)
+		default:
+			fmt.Printf("Telemetry is on, with anonymous ID %s.\n", c.ID)
+		}
+		if c.Enabled {
+			fmt.Printf("Events are logged to %s", filepath.Join(dir, cli.TelemetryLog))
+			if c.Endpoint != "" {
+				fmt.Printf(" and posted to %s", c.Endpoint)
+			}
+			fmt.Println(".")
+		}
+	default:
+		fs.Usage()
+		return returnError
+	}
+	return returnNoFindings
+}
+
+// recordTelemetry records the run for categories, if the user has opted in.
+// Telemetry problems are only logged, so that they never fail a run.
+func recordTelemetry(categories []string, build bool, started time.Time, numNotes int, runErr error, resp *rpcpb.ShipshapeResponse) {
+	dir := cli.TelemetryDir()
+	c, err := cli.LoadTelemetryConfig(dir)
+	if err != nil {
+		glog.Errorf("Could not load the telemetry setting: %v", err)
+		return
+	}
+	if !cli.TelemetryEnabled(c, os.Getenv) {
+		return
+	}
+	e := cli.NewTelemetryEvent(c.ID, categories, build, time.Since(started), numNotes, runErr, resp)
+	if err := cli.RecordTelemetry(dir, c, e); err != nil {
+		glog.Errorf("Could not record telemetry: %v", err)
+	}
+}
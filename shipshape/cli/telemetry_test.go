@@ -0,0 +1,198 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func TestSetTelemetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telemetry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := LoadTelemetryConfig(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Enabled {
+		t.Errorf("Telemetry is on by default")
+	}
+
+	on, err := SetTelemetry(dir, true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, err = LoadTelemetryConfig(dir); err != nil || !reflect.DeepEqual(c, on) || c.ID == "" {
+		t.Errorf("Wrong setting after turning telemetry on: got %+v (error %v), want %+v with an ID", c, err, on)
+	}
+	again, err := SetTelemetry(dir, true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID == on.ID {
+		t.Errorf("Turning telemetry on again kept ID %s", on.ID)
+	}
+
+	if _, err := SetTelemetry(dir, false, ""); err != nil {
+		t.Fatal(err)
+	}
+	if c, err = LoadTelemetryConfig(dir); err != nil || c.Enabled || c.ID != "" {
+		t.Errorf("Wrong setting after turning telemetry off: got %+v (error %v)", c, err)
+	}
+}
+
+func TestTelemetryEnabled(t *testing.T) {
+	on := &TelemetryConfig{Enabled: true, ID: "abc"}
+	tests := []struct {
+		desc string
+		c    *TelemetryConfig
+		env  map[string]string
+		want bool
+	}{
+		{"on", on, nil, true},
+		{"off", &TelemetryConfig{}, nil, false},
+		{"turned off by env", on, map[string]string{TelemetryEnv: "off"}, false},
+		{"do not track", on, map[string]string{"DO_NOT_TRACK": "1"}, false},
+	}
+	for _, test := range tests {
+		getenv := func(k string) string { return test.env[k] }
+		if got := TelemetryEnabled(test.c, getenv); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestNewTelemetryEvent(t *testing.T) {
+	resp := &rpcpb.ShipshapeResponse{
+		AnalyzeResponse: []*rpcpb.AnalyzeResponse{{
+			Note: makeNotes("PyLint", 12),
+			Failure: []*rpcpb.AnalysisFailure{
+				{Category: proto.String("JSHint"), FailureMessage: proto.String("could not read /home/me/src/app.js")},
+				{Category: proto.String("AcmeScanner"), FailureMessage: proto.String("license expired")},
+			},
+		}},
+	}
+	e := NewTelemetryEvent("abc", []string{"PyLint", "JSHint", "AcmeScanner"}, false, 90*time.Second, 12, nil, resp)
+	if got, want := e.Categories, []string{"JSHint", "PyLint"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong categories: got %v, want %v", got, want)
+	}
+	if got, want := e.OtherCategories, 1; got != want {
+		t.Errorf("Wrong number of other categories: got %d, want %d", got, want)
+	}
+	if got, want := e.FailedCategories, []string{"JSHint"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong failed categories: got %v, want %v", got, want)
+	}
+	if e.Duration != "1m-5m" || e.Notes != "10-99" || e.Failure != "" {
+		t.Errorf("Wrong buckets: got %+v", e)
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, private := range []string{"/home/me", "AcmeScanner", "license"} {
+		if strings.Contains(string(b), private) {
+			t.Errorf("Event has %q: %s", private, b)
+		}
+	}
+}
+
+func TestFailureClass(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{errors.New("docker is not ready to run shipshape:\ndocker daemon: ..."), "docker"},
+		{errors.New("HTTP client did not become healthy: timed out"), "service_start"},
+		{fmt.Errorf("error making service call: %v", streamError{errors.New("EOF")}), "service_call"},
+		{fmt.Errorf("error making service call: %v", streamError{errInterrupted}), "interrupted"},
+		{errors.New("/home/me/src is not a valid file or directory"), "other"},
+	}
+	for _, test := range tests {
+		if got := failureClass(test.err); got != test.want {
+			t.Errorf("failureClass(%q): got %q, want %q", test.err, got, test.want)
+		}
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	durations := map[time.Duration]string{
+		time.Second:      "<10s",
+		30 * time.Second: "10s-1m",
+		10 * time.Minute: "5m-30m",
+		time.Hour:        ">30m",
+	}
+	for d, want := range durations {
+		if got := durationBucket(d); got != want {
+			t.Errorf("durationBucket(%v): got %q, want %q", d, got, want)
+		}
+	}
+	counts := map[int]string{0: "0", 1: "1-9", 99: "10-99", 500: "100-999", 5000: "1000+"}
+	for n, want := range counts {
+		if got := countBucket(n); got != want {
+			t.Errorf("countBucket(%d): got %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestRecordTelemetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telemetry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var posted TelemetryEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&posted); err != nil {
+			t.Errorf("Could not decode event: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	e := TelemetryEvent{ID: "abc", Categories: []string{"PyLint"}, Duration: "<10s", Notes: "0"}
+	if err := RecordTelemetry(dir, &TelemetryConfig{Enabled: true, ID: "abc", Endpoint: server.URL}, e); err != nil {
+		t.Fatalf("RecordTelemetry failed: %v", err)
+	}
+	if !reflect.DeepEqual(posted, e) {
+		t.Errorf("Wrong event posted: got %+v, want %+v", posted, e)
+	}
+	logged, err := ioutil.ReadFile(filepath.Join(dir, TelemetryLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(logged), "\n"); got != 1 {
+		t.Errorf("Wrong number of events logged: got %d in %q, want 1", got, logged)
+	}
+}
@@ -0,0 +1,3 @@
+def f():
+    # shipshape-echo: in python
+    return 1
@@ -0,0 +1,5 @@
+package main
+
+// shipshape-echo: first
+// shipshape-echo: second
+func main() {}
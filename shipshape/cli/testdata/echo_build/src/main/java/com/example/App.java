@@ -0,0 +1,9 @@
+package com.example;
+
+// shipshape-echo: built
+public class App {
+  public static void main(String[] args) {
+    // shipshape-echo: after the build
+    System.out.println("Hello");
+  }
+}
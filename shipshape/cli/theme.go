@@ -0,0 +1,98 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Theme is how the CLI colors what it prints to a terminal.
+type Theme struct {
+	Name string
+	// File, Hunk, Removed and Added are the escape sequences that start the
+	// file names, hunk headers, removed lines and added lines of a diff. An
+	// empty one leaves that part uncolored.
+	File, Hunk, Removed, Added string
+}
+
+var (
+	// DefaultTheme colors like git does.
+	DefaultTheme = &Theme{Name: "default", File: "\x1b[1m", Hunk: "\x1b[36m", Removed: "\x1b[31m", Added: "\x1b[32m"}
+	// Themes are the themes that --theme can choose.
+	Themes = []*Theme{
+		DefaultTheme,
+		// Bold and bright, so the colors stand out from each other and
+		// from the background for people with low vision.
+		{Name: "high-contrast", File: "\x1b[1;4m", Hunk: "\x1b[1;96m", Removed: "\x1b[1;91m", Added: "\x1b[1;92m"},
+		// Nothing is colored; the + and - at the start of the lines of a
+		// diff still tell them apart.
+		{Name: "no-color"},
+	}
+)
+
+// LookupTheme returns the theme called name, which is one of Themes. If name is
+// empty, the theme is no-color if $NO_COLOR is set, as read by getenv, and the
+// default theme if not.
+func LookupTheme(name string, getenv func(string) string) (*Theme, error) {
+	if name == "" {
+		if getenv("NO_COLOR") != "" {
+			name = "no-color"
+		} else {
+			return DefaultTheme, nil
+		}
+	}
+	var names []string
+	for _, t := range Themes {
+		if t.Name == name {
+			return t, nil
+		}
+		names = append(names, t.Name)
+	}
+	last := len(names) - 1
+	return nil, fmt.Errorf("unknown theme %q (must be %s or %s)", name, strings.Join(names[:last], ", "), names[last])
+}
+
+// Colored returns whether the theme colors anything.
+func (t *Theme) Colored() bool {
+	return t.File != "" || t.Hunk != "" || t.Removed != "" || t.Added != ""
+}
+
+// ColorDiff colors a unified diff for a terminal.
+func (t *Theme) ColorDiff(diff string) string {
+	const reset = "\x1b[m"
+	lines := strings.SplitAfter(diff, "\n")
+	for i, line := range lines {
+		text := strings.TrimSuffix(line, "\n")
+		var color string
+		switch {
+		case strings.HasPrefix(text, "--- ") || strings.HasPrefix(text, "+++ "):
+			color = t.File
+		case strings.HasPrefix(text, "@@"):
+			color = t.Hunk
+		case strings.HasPrefix(text, "-"):
+			color = t.Removed
+		case strings.HasPrefix(text, "+"):
+			color = t.Added
+		}
+		if color == "" {
+			continue
+		}
+		lines[i] = color + text + reset + line[len(text):]
+	}
+	return strings.Join(lines, "")
+}
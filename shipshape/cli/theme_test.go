@@ -0,0 +1,61 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import "testing"
+
+func TestLookupTheme(t *testing.T) {
+	noColor := func(name string) string {
+		if name == "NO_COLOR" {
+			return "1"
+		}
+		return ""
+	}
+	none := func(string) string { return "" }
+	tests := []struct {
+		name   string
+		getenv func(string) string
+		want   string
+	}{
+		{"", none, "default"},
+		{"", noColor, "no-color"},
+		{"high-contrast", noColor, "high-contrast"},
+		{"no-color", none, "no-color"},
+	}
+	for _, test := range tests {
+		theme, err := LookupTheme(test.name, test.getenv)
+		if err != nil || theme.Name != test.want {
+			t.Errorf("LookupTheme(%q) = %v, %v, want %s", test.name, theme, err, test.want)
+		}
+	}
+	if _, err := LookupTheme("solarized", none); err == nil {
+		t.Errorf("LookupTheme(\"solarized\") succeeded, want an error")
+	}
+}
+
+func TestThemeColorDiff(t *testing.T) {
+	diff := "--- a/a.py\n+++ b/a.py\n@@ -1,1 +1,1 @@\n-old\n+new\n"
+	noColor, _ := LookupTheme("no-color", nil)
+	if got := noColor.ColorDiff(diff); got != diff || noColor.Colored() {
+		t.Errorf("The no-color theme colored the diff: %q", got)
+	}
+	highContrast, _ := LookupTheme("high-contrast", nil)
+	want := "\x1b[1;4m--- a/a.py\x1b[m\n\x1b[1;4m+++ b/a.py\x1b[m\n\x1b[1;96m@@ -1,1 +1,1 @@\x1b[m\n\x1b[1;91m-old\x1b[m\n\x1b[1;92m+new\x1b[m\n"
+	if got := highContrast.ColorDiff(diff); got != want {
+		t.Errorf("Wrong high-contrast colors:\ngot  %q\nwant %q", got, want)
+	}
+}
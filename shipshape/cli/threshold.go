@@ -0,0 +1,152 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+)
+
+// CountByCategory returns the number of notes in each category.
+func CountByCategory(notes []*notepb.Note) map[string]int {
+	counts := make(map[string]int)
+	for _, note := range notes {
+		counts[note.GetCategory()]++
+	}
+	return counts
+}
+
+// ParseCategoryLimits parses a comma-separated list of category=limit pairs,
+// e.g. "PyLint=20,JSHint=0".
+func ParseCategoryLimits(s string) (map[string]int, error) {
+	limits := make(map[string]int)
+	if s == "" {
+		return limits, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		i := strings.LastIndex(pair, "=")
+		if i == -1 {
+			return nil, fmt.Errorf("invalid category limit %q: must be of the form category=limit", pair)
+		}
+		limit, err := strconv.Atoi(pair[i+1:])
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid category limit %q: limit must be a non-negative integer", pair)
+		}
+		limits[pair[:i]] = limit
+	}
+	return limits, nil
+}
+
+// CheckThresholds compares the note counts against the limits. A negative
+// maxNotes means there is no limit on the total. It returns a message for
+// each limit that was exceeded.
+func CheckThresholds(counts map[string]int, maxNotes int, perCategory map[string]int) []string {
+	var exceeded []string
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	if maxNotes >= 0 && total > maxNotes {
+		exceeded = append(exceeded, Messages.Sprintf("found %d notes, more than the maximum of %d", total, maxNotes))
+	}
+	for _, cat := range sortedKeys(perCategory) {
+		if counts[cat] > perCategory[cat] {
+			exceeded = append(exceeded, Messages.Sprintf("found %d %s notes, more than the maximum of %d", counts[cat], cat, perCategory[cat]))
+		}
+	}
+	return exceeded
+}
+
+// A Ratchet records the number of notes per category from earlier runs, so
+// that a codebase with existing notes can be held to never getting worse.
+// Each time a run has fewer notes in a category, the ratchet tightens.
+type Ratchet struct {
+	Categories map[string]int `json:"categories"`
+}
+
+// LoadRatchet reads the ratchet stored at path. If there is no ratchet there
+// yet, it returns an empty one.
+func LoadRatchet(path string) (*Ratchet, error) {
+	r := &Ratchet{Categories: make(map[string]int)}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, fmt.Errorf("could not parse ratchet %s: %v", path, err)
+	}
+	if r.Categories == nil {
+		r.Categories = make(map[string]int)
+	}
+	return r, nil
+}
+
+// Check returns a message for each recorded category whose count went up.
+// Categories that have not been recorded yet (e.g. because they were just
+// enabled) never fail; Update starts tracking them.
+func (r *Ratchet) Check(counts map[string]int) []string {
+	var exceeded []string
+	for _, cat := range sortedKeys(r.Categories) {
+		if counts[cat] > r.Categories[cat] {
+			exceeded = append(exceeded, Messages.Sprintf("found %d %s notes, more than the %d recorded by the ratchet", counts[cat], cat, r.Categories[cat]))
+		}
+	}
+	return exceeded
+}
+
+// Update tightens the ratchet to the given counts and records any new
+// categories. ran lists the categories that were run; those with no notes
+// are tightened to zero. It should only be called if Check passed.
+func (r *Ratchet) Update(counts map[string]int, ran []string) {
+	for _, cat := range ran {
+		if _, ok := counts[cat]; !ok {
+			r.Categories[cat] = 0
+		}
+	}
+	for cat, count := range counts {
+		if old, ok := r.Categories[cat]; !ok || count < old {
+			r.Categories[cat] = count
+		}
+	}
+}
+
+// Save writes the ratchet to path.
+func (r *Ratchet) Save(path string) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+func sortedKeys(m map[string]int) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
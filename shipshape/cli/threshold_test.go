@@ -0,0 +1,105 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseCategoryLimits(t *testing.T) {
+	got, err := ParseCategoryLimits("PyLint=20,go vet=0,a=b=3")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := map[string]int{"PyLint": 20, "go vet": 0, "a=b": 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong limits: got %v, want %v", got, want)
+	}
+	for _, bad := range []string{"PyLint", "PyLint=many", "PyLint=-1"} {
+		if _, err := ParseCategoryLimits(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestCheckThresholds(t *testing.T) {
+	counts := map[string]int{"PyLint": 5, "JSHint": 2}
+	tests := []struct {
+		maxNotes    int
+		perCategory map[string]int
+		exceeded    int
+	}{
+		{-1, nil, 0},
+		{7, nil, 0},
+		{6, nil, 1},
+		{-1, map[string]int{"PyLint": 5, "go vet": 0}, 0},
+		{-1, map[string]int{"PyLint": 4, "JSHint": 1}, 2},
+		{0, map[string]int{"JSHint": 1}, 2},
+	}
+	for _, test := range tests {
+		if got := CheckThresholds(counts, test.maxNotes, test.perCategory); len(got) != test.exceeded {
+			t.Errorf("CheckThresholds(%v, %d, %v): got %v, want %d exceeded", counts, test.maxNotes, test.perCategory, got, test.exceeded)
+		}
+	}
+}
+
+func TestRatchet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ratchet_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ratchet.json")
+
+	r, err := LoadRatchet(path)
+	if err != nil {
+		t.Fatalf("Could not load missing ratchet: %v", err)
+	}
+	first := map[string]int{"PyLint": 10, "JSHint": 3}
+	if got := r.Check(first); len(got) != 0 {
+		t.Errorf("Empty ratchet should not fail: got %v", got)
+	}
+	r.Update(first, []string{"PyLint", "JSHint", "go vet"})
+	if err := r.Save(path); err != nil {
+		t.Fatalf("Could not save ratchet: %v", err)
+	}
+
+	r, err = LoadRatchet(path)
+	if err != nil {
+		t.Fatalf("Could not load ratchet: %v", err)
+	}
+	if want := map[string]int{"PyLint": 10, "JSHint": 3, "go vet": 0}; !reflect.DeepEqual(r.Categories, want) {
+		t.Errorf("Wrong recorded counts: got %v, want %v", r.Categories, want)
+	}
+
+	// Fewer PyLint notes, JSHint not run, a new category enabled.
+	second := map[string]int{"PyLint": 8, "ErrorProne": 4}
+	if got := r.Check(second); len(got) != 0 {
+		t.Errorf("Improvement should not fail: got %v", got)
+	}
+	r.Update(second, []string{"PyLint", "ErrorProne"})
+	if want := map[string]int{"PyLint": 8, "JSHint": 3, "go vet": 0, "ErrorProne": 4}; !reflect.DeepEqual(r.Categories, want) {
+		t.Errorf("Wrong tightened counts: got %v, want %v", r.Categories, want)
+	}
+
+	if got := r.Check(map[string]int{"PyLint": 9, "go vet": 1}); len(got) != 2 {
+		t.Errorf("Regressions should fail: got %v", got)
+	}
+}
@@ -0,0 +1,37 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+)
+
+// CategoriesInTier returns the categories whose analyzers are configured
+// to be in tier. Categories without a tier are not in any tier.
+func CategoriesInTier(categories []string, analyzers []*configpb.AnalyzerConfig, tier string) []string {
+	tiers := make(map[string]string)
+	for _, a := range analyzers {
+		tiers[a.GetCategory()] = a.GetTier()
+	}
+	var in []string
+	for _, c := range categories {
+		if tiers[c] == tier {
+			in = append(in, c)
+		}
+	}
+	return in
+}
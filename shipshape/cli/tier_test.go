@@ -0,0 +1,43 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+)
+
+func TestCategoriesInTier(t *testing.T) {
+	analyzers := []*configpb.AnalyzerConfig{
+		{Category: proto.String("go vet"), Tier: proto.String("fast")},
+		{Category: proto.String("JSHint"), Tier: proto.String("fast")},
+		{Category: proto.String("ErrorProne"), Tier: proto.String("slow")},
+		{Category: proto.String("PyLint")},
+	}
+	categories := []string{"go vet", "ErrorProne", "PyLint", "JSHint", "AndroidLint"}
+
+	if got, want := CategoriesInTier(categories, analyzers, "fast"), []string{"go vet", "JSHint"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong fast categories: got %v, want %v", got, want)
+	}
+	if got, want := CategoriesInTier(categories, analyzers, "slow"), []string{"ErrorProne"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong slow categories: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/google/shipshape/shipshape/cli"
+)
+
+// trendCommand implements `shipshape trend`, which reports how the notes
+// recorded in a --history_db changed between two runs.
+func trendCommand(args []string) int {
+	fs := flag.NewFlagSet("trend", flag.ExitOnError)
+	db := fs.String("history_db", "", "The results history to read, as written by shipshape --history_db")
+	from := fs.String("from", "", "Commit (or commit prefix) of the older run. Defaults to the second most recent run.")
+	to := fs.String("to", "", "Commit (or commit prefix) of the newer run. Defaults to the most recent run.")
+	verbose := fs.Bool("v", false, "List the individual new and fixed notes")
+	fs.Usage = func() {
+		fmt.Println("USAGE: shipshape trend --history_db=<path> [--from=<commit>] [--to=<commit>]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *db == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return returnError
+	}
+
+	runs, err := cli.OpenHistory(*db).Runs()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+	if len(runs) == 0 {
+		fmt.Printf("No runs recorded in %s\n", *db)
+		return returnNoFindings
+	}
+
+	fmt.Println("Recorded runs:")
+	for _, run := range runs {
+		fmt.Printf("  %s  %-10.10s %-20s %d notes\n", run.Metadata.Timestamp.Local().Format(time.RFC3339), run.Metadata.Commit, run.Metadata.Branch, len(run.Notes))
+	}
+	fmt.Println()
+
+	newer, older := runs[len(runs)-1], cli.HistoryRun{}
+	if len(runs) > 1 {
+		older = runs[len(runs)-2]
+	}
+	var ok bool
+	if *to != "" {
+		if newer, ok = cli.FindRun(runs, *to); !ok {
+			fmt.Printf("Error: no run recorded for commit %s\n", *to)
+			return returnError
+		}
+	}
+	if *from != "" {
+		if older, ok = cli.FindRun(runs, *from); !ok {
+			fmt.Printf("Error: no run recorded for commit %s\n", *from)
+			return returnError
+		}
+	}
+
+	t := cli.CompareRuns(older, newer)
+	fmt.Printf("From %s to %s: %d new, %d fixed, %d persisting\n", describeRun(older), describeRun(newer), len(t.New), len(t.Fixed), len(t.Persisting))
+	if *verbose {
+		printHistoryNotes("New", t.New)
+		printHistoryNotes("Fixed", t.Fixed)
+	}
+	if len(t.New) > 0 {
+		return returnFindings
+	}
+	return returnNoFindings
+}
+
+func describeRun(run cli.HistoryRun) string {
+	if run.Metadata.Timestamp.IsZero() {
+		return "(nothing)"
+	}
+	if run.Metadata.Commit != "" {
+		return fmt.Sprintf("%.10s", run.Metadata.Commit)
+	}
+	return run.Metadata.Timestamp.Local().Format(time.RFC3339)
+}
+
+func printHistoryNotes(title string, notes []cli.HistoryNote) {
+	if len(notes) == 0 {
+		return
+	}
+	fmt.Printf("\n%s:\n", title)
+	for _, n := range notes {
+		loc := n.Path
+		if n.Line != 0 {
+			loc = fmt.Sprintf("%s:%d", n.Path, n.Line)
+		}
+		if loc == "" {
+			loc = "Global"
+		}
+		fmt.Printf("  %s [%s]\n\t%s\n", loc, n.Category, n.Description)
+	}
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+)
+
+// TriageResult counts the decisions made by Triage.
+type TriageResult struct {
+	Fix        int
+	Baselined  int
+	Suppressed int
+	// Skipped notes were not decided on because triage was quit.
+	Skipped int
+}
+
+// Triage walks through notes, which were found in directory, and asks on out
+// whether each should be fixed, added to the baseline or suppressed inline,
+// reading the answers from in. Notes that are already in the baseline or
+// suppressed inline are left out. Notes to baseline are added to baseline,
+// which the caller saves, and suppression comments are added to the source
+// files once all notes have been decided on, or triage is quit.
+func Triage(notes []*notepb.Note, directory string, baseline *Baseline, in io.Reader, out io.Writer) (TriageResult, error) {
+	var result TriageResult
+	inline := NewInlineSuppressor()
+	var open []*notepb.Note
+	for _, n := range notes {
+		if !baseline.Contains(n) && !inline.Suppressed(n, directory) {
+			open = append(open, n)
+		}
+	}
+
+	sups := make(map[string][]InlineSuppression)
+	answers := bufio.NewReader(in)
+	all := false
+	for i, n := range open {
+		if all {
+			baseline.Add(n)
+			result.Baselined++
+			continue
+		}
+		printTriageNote(out, n, directory, i+1, len(open))
+		answer, quit := askTriage(answers, out, n)
+		if quit {
+			result.Skipped = len(open) - i
+			break
+		}
+		switch answer {
+		case "f":
+			result.Fix++
+		case "b":
+			baseline.Add(n)
+			result.Baselined++
+		case "i":
+			path := filepath.Join(directory, n.GetLocation().GetPath())
+			cat := n.GetCategory()
+			if n.Subcategory != nil {
+				cat += ":" + n.GetSubcategory()
+			}
+			sups[path] = append(sups[path], InlineSuppression{int(n.GetLocation().GetRange().GetStartLine()), cat})
+			result.Suppressed++
+		case "a":
+			all = true
+			baseline.Add(n)
+			result.Baselined++
+		}
+	}
+
+	for path, s := range sups {
+		if err := AddSuppressions(path, s); err != nil {
+			return result, fmt.Errorf("could not suppress notes in %s: %v", path, err)
+		}
+	}
+	return result, nil
+}
+
+func printTriageNote(out io.Writer, n *notepb.Note, directory string, i, total int) {
+	loc := n.GetLocation().GetPath()
+	if loc == "" {
+		loc = "Global"
+	}
+	line := int(n.GetLocation().GetRange().GetStartLine())
+	if line > 0 {
+		loc = fmt.Sprintf("%s:%d", loc, line)
+	}
+	cat := n.GetCategory()
+	if n.Subcategory != nil {
+		cat += ":" + n.GetSubcategory()
+	}
+	fmt.Fprintf(out, "\n[%d/%d] %s [%s]\n\t%s\n", i, total, loc, cat, n.GetDescription())
+	if line > 0 {
+		if preview, err := SourcePreview(filepath.Join(directory, n.GetLocation().GetPath()), line, 2); err == nil {
+			fmt.Fprintln(out, strings.Join(preview, "\n"))
+		}
+	}
+}
+
+// askTriage asks what to do with n until it gets a valid answer. It reports
+// quit if the user quits or there are no more answers.
+func askTriage(answers *bufio.Reader, out io.Writer, n *notepb.Note) (answer string, quit bool) {
+	// Global notes have no line to put a comment on.
+	canInline := n.GetLocation().GetRange().GetStartLine() > 0
+	prompt := Messages.Text("(f)ix, (b)aseline, (i)nline suppress, baseline (a)ll remaining, (q)uit? ")
+	if !canInline {
+		prompt = Messages.Text("(f)ix, (b)aseline, baseline (a)ll remaining, (q)uit? ")
+	}
+	for {
+		fmt.Fprint(out, prompt)
+		line, err := answers.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(line))
+		switch {
+		case answer == "q":
+			return "", true
+		case answer == "f" || answer == "b" || answer == "a" || (answer == "i" && canInline):
+			return answer, false
+		case err != nil:
+			fmt.Fprintln(out)
+			return "", true
+		}
+	}
+}
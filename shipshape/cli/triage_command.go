@@ -0,0 +1,74 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/google/shipshape/shipshape/cli"
+)
+
+// triageCommand implements `shipshape triage`, which walks through the notes in
+// a results file written by --json_output so that they can be adopted into a
+// baseline or suppressed inline.
+func triageCommand(args []string) int {
+	fs := flag.NewFlagSet("triage", flag.ExitOnError)
+	baselinePath := fs.String("baseline", "", "The baseline file to add notes to, as read by shipshape --baseline. It is created if it does not exist.")
+	dir := fs.String("dir", ".", "The directory that was analyzed, which the paths in the results are relative to")
+	fs.Usage = func() {
+		printf("USAGE: shipshape triage --baseline=<path> [--dir=<directory>] <results.json>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *baselinePath == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return returnError
+	}
+
+	results, err := cli.LoadResults(fs.Arg(0))
+	if err != nil {
+		printf("Error: %v\n", err)
+		return returnError
+	}
+	baseline, err := cli.LoadBaseline(*baselinePath)
+	if err != nil {
+		printf("Error: %v\n", err)
+		return returnError
+	}
+	result, err := cli.Triage(cli.AllNotes(results), *dir, baseline, os.Stdin, os.Stdout)
+	if err != nil {
+		printf("Error: %v\n", err)
+		return returnError
+	}
+	if result.Baselined > 0 {
+		if err := baseline.Save(*baselinePath); err != nil {
+			printf("Error: could not save the baseline: %v\n", err)
+			return returnError
+		}
+	}
+	printf("\n%d to fix, %d added to %s, %d suppressed inline", result.Fix, result.Baselined, *baselinePath, result.Suppressed)
+	if result.Skipped > 0 {
+		printf(", %d not triaged", result.Skipped)
+	}
+	fmt.Println()
+	if result.Fix+result.Skipped > 0 {
+		return returnFindings
+	}
+	return returnNoFindings
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+)
+
+func TestTriage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "triage_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source := "import os\nimport sys\nprint('hi')\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.py"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	baseline, _ := LoadBaseline(filepath.Join(dir, "baseline.json"))
+	old := tableNote("PyLint", "a.py", 3, notepb.Note_WARNING, "already in the baseline")
+	baseline.Add(old)
+	notes := []*notepb.Note{
+		old,
+		tableNote("PyLint", "a.py", 1, notepb.Note_WARNING, "unused import os"),
+		tableNote("PyLint", "a.py", 2, notepb.Note_WARNING, "unused import sys"),
+		tableNote("JSHint", "", 0, notepb.Note_WARNING, "global note"),
+		tableNote("PyLint", "a.py", 3, notepb.Note_WARNING, "print statement"),
+		tableNote("PyLint", "a.py", 3, notepb.Note_WARNING, "another"),
+	}
+	// "i" is not allowed for the global note, so it is asked again.
+	in := strings.NewReader("f\nx\ni\ni\nb\na\n")
+	var out bytes.Buffer
+	got, err := Triage(notes, dir, baseline, in, &out)
+	if err != nil {
+		t.Fatalf("Triage failed: %v", err)
+	}
+	want := TriageResult{Fix: 1, Suppressed: 1, Baselined: 3}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong result: got %+v, want %+v", got, want)
+	}
+	if !strings.Contains(out.String(), "[1/5] a.py:1 [PyLint]") || !strings.Contains(out.String(), ">    1  import os") {
+		t.Errorf("Note and source not shown:\n%s", out.String())
+	}
+	if got, want := len(baseline.Notes), 4; got != want {
+		t.Errorf("Wrong number of baseline notes: got %d, want %d", got, want)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "a.py"))
+	if want := "import os\n# shipshape:ignore PyLint\nimport sys\nprint('hi')\n"; string(b) != want {
+		t.Errorf("Wrong suppression:\ngot  %q\nwant %q", b, want)
+	}
+}
+
+func TestTriageQuit(t *testing.T) {
+	notes := []*notepb.Note{
+		tableNote("JSHint", "", 0, notepb.Note_WARNING, "one"),
+		tableNote("JSHint", "", 0, notepb.Note_WARNING, "two"),
+		tableNote("JSHint", "", 0, notepb.Note_WARNING, "three"),
+	}
+	baseline, _ := LoadBaseline("")
+	got, err := Triage(notes, "", baseline, strings.NewReader("b\nq\n"), ioutil.Discard)
+	if err != nil {
+		t.Fatalf("Triage failed: %v", err)
+	}
+	if want := (TriageResult{Baselined: 1, Skipped: 2}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong result: got %+v, want %+v", got, want)
+	}
+	// Running out of answers is the same as quitting.
+	got, _ = Triage(notes, "", baseline, strings.NewReader(""), ioutil.Discard)
+	if want := (TriageResult{Skipped: 2}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong result: got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultMaxDescription is the most characters of a note description that are
+// shown in text output by default.
+const DefaultMaxDescription = 500
+
+// DescriptionTruncator shortens long note descriptions for display, and
+// counts how many it shortened. Only the display is affected; the notes keep
+// their full descriptions.
+type DescriptionTruncator struct {
+	// Max is the most characters of a description to show, or 0 to show
+	// descriptions in full.
+	Max       int
+	truncated int
+}
+
+// Truncate returns desc, shortened to at most Max characters followed by a
+// marker saying how many more there are.
+func (t *DescriptionTruncator) Truncate(desc string) string {
+	n := utf8.RuneCountInString(desc)
+	if t.Max <= 0 || n <= t.Max {
+		return desc
+	}
+	t.truncated++
+	cut := 0
+	for i := 0; i < t.Max; i++ {
+		_, size := utf8.DecodeRuneInString(desc[cut:])
+		cut += size
+	}
+	return fmt.Sprintf("%s... [%d more characters]", strings.TrimRight(desc[:cut], " \t\n"), n-t.Max)
+}
+
+// Truncated returns how many descriptions have been shortened.
+func (t *DescriptionTruncator) Truncated() int {
+	return t.truncated
+}
@@ -0,0 +1,49 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDescriptionTruncator(t *testing.T) {
+	tr := &DescriptionTruncator{Max: 10}
+	tests := []struct {
+		desc, want string
+	}{
+		{"short", "short"},
+		{"exactly 10", "exactly 10"},
+		{"a description that is too long", "a descript... [20 more characters]"},
+		{"ünïcödé ünïcödé", "ünïcödé ün... [5 more characters]"},
+		{"trailing  \nspace", "trailing... [6 more characters]"},
+	}
+	for _, test := range tests {
+		if got := tr.Truncate(test.desc); got != test.want {
+			t.Errorf("Truncate(%q): got %q, want %q", test.desc, got, test.want)
+		}
+	}
+	if got, want := tr.Truncated(), 3; got != want {
+		t.Errorf("Wrong number of truncated descriptions: got %d, want %d", got, want)
+	}
+
+	full := &DescriptionTruncator{}
+	long := strings.Repeat("x", 10*DefaultMaxDescription)
+	if got := full.Truncate(long); got != long || full.Truncated() != 0 {
+		t.Errorf("Expected no truncation without a maximum")
+	}
+}
@@ -0,0 +1,383 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// TableRow is a note in the notes table of `shipshape tui`.
+type TableRow struct {
+	Note *notepb.Note
+	// Path is the file of the note, joined to the directory it was found in,
+	// or empty for a global note.
+	Path string
+}
+
+// SortKey is the order of the notes table.
+type SortKey int
+
+const (
+	SortByPath SortKey = iota
+	SortByCategory
+	SortBySeverity
+	numSortKeys
+)
+
+func (k SortKey) String() string {
+	switch k {
+	case SortByCategory:
+		return "category"
+	case SortBySeverity:
+		return "severity"
+	}
+	return "path"
+}
+
+// NoteTable holds the notes shown by `shipshape tui`: which of them match
+// the filter, in which order, and which one is selected. The selection stays
+// on the same note as notes are added and the order changes.
+type NoteTable struct {
+	rows    []TableRow
+	filter  string
+	sortBy  SortKey
+	visible []int
+	// selected is the index in rows of the selected note, or -1.
+	selected int
+}
+
+// NewNoteTable returns an empty table, sorted by path.
+func NewNoteTable() *NoteTable {
+	return &NoteTable{selected: -1}
+}
+
+// Add adds the notes of msg, which were found in directory.
+func (t *NoteTable) Add(msg *rpcpb.ShipshapeResponse, directory string) {
+	for _, analysis := range msg.AnalyzeResponse {
+		for _, note := range analysis.Note {
+			path := ""
+			if p := note.GetLocation().GetPath(); p != "" {
+				path = filepath.Join(directory, p)
+			}
+			t.rows = append(t.rows, TableRow{note, path})
+		}
+	}
+	t.refresh()
+}
+
+// Total is the number of notes in the table, including those that do not
+// match the filter.
+func (t *NoteTable) Total() int { return len(t.rows) }
+
+// Len is the number of notes that match the filter.
+func (t *NoteTable) Len() int { return len(t.visible) }
+
+// Row returns the i'th note that matches the filter, in order.
+func (t *NoteTable) Row(i int) TableRow { return t.rows[t.visible[i]] }
+
+// Filter is the text that the notes shown must contain.
+func (t *NoteTable) Filter() string { return t.filter }
+
+// SetFilter shows only the notes whose path, category, subcategory or
+// description contain filter, ignoring case.
+func (t *NoteTable) SetFilter(filter string) {
+	t.filter = filter
+	t.refresh()
+}
+
+// SortKey is the order of the table.
+func (t *NoteTable) SortKey() SortKey { return t.sortBy }
+
+// CycleSort changes the order of the table to the next sort key.
+func (t *NoteTable) CycleSort() {
+	t.sortBy = (t.sortBy + 1) % numSortKeys
+	t.refresh()
+}
+
+// Selected returns the position of the selected note among those that
+// match the filter, or -1 if none is selected.
+func (t *NoteTable) Selected() int {
+	for i, r := range t.visible {
+		if r == t.selected {
+			return i
+		}
+	}
+	return -1
+}
+
+// SelectedRow returns the selected note, if any.
+func (t *NoteTable) SelectedRow() (TableRow, bool) {
+	if i := t.Selected(); i >= 0 {
+		return t.Row(i), true
+	}
+	return TableRow{}, false
+}
+
+// Move moves the selection delta notes down, or up if delta is negative,
+// stopping at the first and last notes.
+func (t *NoteTable) Move(delta int) {
+	if len(t.visible) == 0 {
+		return
+	}
+	i := t.Selected() + delta
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(t.visible) {
+		i = len(t.visible) - 1
+	}
+	t.selected = t.visible[i]
+}
+
+func (t *NoteTable) refresh() {
+	filter := strings.ToLower(t.filter)
+	t.visible = t.visible[:0]
+	for i, r := range t.rows {
+		if filter == "" || strings.Contains(strings.ToLower(rowText(r)), filter) {
+			t.visible = append(t.visible, i)
+		}
+	}
+	sort.Stable(byKey{t})
+	if t.Selected() < 0 && len(t.visible) > 0 {
+		t.selected = t.visible[0]
+	}
+}
+
+func rowText(r TableRow) string {
+	n := r.Note
+	return strings.Join([]string{r.Path, n.GetCategory(), n.GetSubcategory(), n.GetDescription()}, "\x00")
+}
+
+type byKey struct{ t *NoteTable }
+
+func (b byKey) Len() int      { return len(b.t.visible) }
+func (b byKey) Swap(i, j int) { b.t.visible[i], b.t.visible[j] = b.t.visible[j], b.t.visible[i] }
+func (b byKey) Less(i, j int) bool {
+	ri, rj := b.t.rows[b.t.visible[i]], b.t.rows[b.t.visible[j]]
+	switch b.t.sortBy {
+	case SortByCategory:
+		if ci, cj := ri.Note.GetCategory(), rj.Note.GetCategory(); ci != cj {
+			return ci < cj
+		}
+	case SortBySeverity:
+		// Lower values are more severe, e.g. BUILD_ERROR before WARNING.
+		if si, sj := ri.Note.GetSeverity(), rj.Note.GetSeverity(); si != sj {
+			return si < sj
+		}
+	}
+	if ri.Path != rj.Path {
+		return ri.Path < rj.Path
+	}
+	return ri.Note.GetLocation().GetRange().GetStartLine() < rj.Note.GetLocation().GetRange().GetStartLine()
+}
+
+// CategoryProgress is the state of an analyzer's category in a run.
+type CategoryProgress struct {
+	Category string
+	Done     bool
+	Failed   bool
+	Notes    int
+}
+
+// Progress tracks which categories of a run have reported their results.
+type Progress struct {
+	categories []*CategoryProgress
+}
+
+// NewProgress returns the progress of a run that is expected to run
+// categories, none of which have reported yet.
+func NewProgress(categories []string) *Progress {
+	p := new(Progress)
+	for _, c := range categories {
+		p.category(c)
+	}
+	return p
+}
+
+// Update records the results in msg. A category is done once it has
+// notes, a failure or coverage in the results.
+func (p *Progress) Update(msg *rpcpb.ShipshapeResponse) {
+	for _, analysis := range msg.AnalyzeResponse {
+		for _, note := range analysis.Note {
+			c := p.category(note.GetCategory())
+			c.Done = true
+			c.Notes++
+		}
+		for _, f := range analysis.Failure {
+			c := p.category(f.GetCategory())
+			c.Done, c.Failed = true, true
+		}
+		for _, cov := range analysis.Coverage {
+			p.category(cov.GetCategory()).Done = true
+		}
+	}
+}
+
+// Finish marks all categories as done, since the run is over.
+func (p *Progress) Finish() {
+	for _, c := range p.categories {
+		c.Done = true
+	}
+}
+
+// Categories returns the progress of each category, in the order they
+// were expected or first reported.
+func (p *Progress) Categories() []CategoryProgress {
+	var cs []CategoryProgress
+	for _, c := range p.categories {
+		cs = append(cs, *c)
+	}
+	return cs
+}
+
+func (p *Progress) category(name string) *CategoryProgress {
+	for _, c := range p.categories {
+		if c.Category == name {
+			return c
+		}
+	}
+	c := &CategoryProgress{Category: name}
+	p.categories = append(p.categories, c)
+	return c
+}
+
+// SourcePreview returns the lines of the file at path around line, numbered,
+// with line marked. It returns at most context lines before and after it.
+func SourcePreview(path string, line, context int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		if n < line-context {
+			continue
+		}
+		if n > line+context {
+			break
+		}
+		mark := " "
+		if n == line {
+			mark = ">"
+		}
+		lines = append(lines, fmt.Sprintf("%s%5d  %s", mark, n, strings.Replace(s.Text(), "\t", "    ", -1)))
+	}
+	return lines, s.Err()
+}
+
+// EditorCommand returns the command line that opens path at line in editor,
+// which is the value of $EDITOR and may include arguments. Most editors take
+// +line before the file; the ones that do not are special-cased.
+func EditorCommand(editor, path string, line int) []string {
+	args := strings.Fields(editor)
+	if len(args) == 0 {
+		args = []string{"vi"}
+	}
+	if line <= 0 {
+		return append(args, path)
+	}
+	switch filepath.Base(args[0]) {
+	case "code", "code-insiders", "codium":
+		return append(args, "-g", fmt.Sprintf("%s:%d", path, line))
+	case "subl", "atom", "zed":
+		return append(args, fmt.Sprintf("%s:%d", path, line))
+	}
+	return append(args, fmt.Sprintf("+%d", line), path)
+}
+
+// RenderTUI lays out the screen of `shipshape tui` as lines of at most width
+// characters, filling height lines. The progress of the categories is at the
+// top, the notes table in the middle and the preview of the selected note, if
+// any, and the status line at the bottom.
+func RenderTUI(p *Progress, t *NoteTable, preview []string, status string, width, height int) []string {
+	var lines []string
+	var states []string
+	for _, c := range p.Categories() {
+		switch {
+		case c.Failed:
+			states = append(states, c.Category+": failed")
+		case c.Done:
+			states = append(states, fmt.Sprintf("%s: %d", c.Category, c.Notes))
+		default:
+			states = append(states, c.Category+": running")
+		}
+	}
+	lines = append(lines, "Analyzers  "+strings.Join(states, "  "))
+	header := fmt.Sprintf("Notes %d", t.Len())
+	if t.Filter() != "" {
+		header += fmt.Sprintf(" of %d matching %q", t.Total(), t.Filter())
+	}
+	lines = append(lines, header+", sorted by "+t.SortKey().String())
+
+	// The table gets whatever is left after the preview and the status line.
+	top := len(lines)
+	rows := height - top - 1
+	if len(preview) > 0 {
+		rows -= len(preview) + 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	selected := t.Selected()
+	first := 0
+	if selected >= rows {
+		first = selected - rows + 1
+	}
+	for i := first; i < t.Len() && i < first+rows; i++ {
+		r := t.Row(i)
+		mark := "  "
+		if i == selected {
+			mark = "> "
+		}
+		loc := r.Path
+		if loc == "" {
+			loc = "Global"
+		}
+		if l := formatLines(r.Note.GetLocation().GetRange()); l != "" {
+			loc += ":" + l
+		}
+		cat := r.Note.GetCategory()
+		if sub := r.Note.GetSubcategory(); sub != "" {
+			cat += ":" + sub
+		}
+		lines = append(lines, fmt.Sprintf("%s%-8s %s [%s] %s", mark, r.Note.GetSeverity(), loc, cat, r.Note.GetDescription()))
+	}
+	for len(lines) < top+rows {
+		lines = append(lines, "")
+	}
+	if len(preview) > 0 {
+		lines = append(lines, strings.Repeat("-", width))
+		lines = append(lines, preview...)
+	}
+	lines = append(lines, status)
+	for i, l := range lines {
+		if len(l) > width {
+			lines[i] = l[:width]
+		}
+	}
+	return lines
+}
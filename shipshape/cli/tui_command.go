@@ -0,0 +1,261 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/google/shipshape/shipshape/cli"
+	"github.com/google/shipshape/shipshape/service"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+const tuiHelp = "j/k: move  /: filter  s: sort  p: preview  e: edit  q: quit"
+
+// tuiCommand implements `shipshape tui`, which runs an analysis and shows its
+// results in the terminal as they arrive.
+func tuiCommand(args []string) int {
+	fs := flag.NewFlagSet("tui", flag.ExitOnError)
+	cats := fs.String("categories", "", "Categories to trigger (comma-separated). If none are specified, will use the .shipshape configuration file to decide which categories to run.")
+	event := fs.String("event", cli.DefaultEvent, "The name of the event to use")
+	repo := fs.String("repo", cli.DefaultRepo, "The name of the docker repo to use")
+	tag := fs.String("tag", "prod", "Tag to use for the analysis service image. If this is local, we will not attempt to pull the image.")
+	build := fs.String("build", "", "The name of the build system to use to generate compilation units. If empty, will not run the compilation step.")
+	fs.Usage = func() {
+		fmt.Println("USAGE: shipshape tui [--categories=<list>] [--event=<event>] <directory>")
+		fmt.Println(tuiHelp)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return returnError
+	}
+
+	options := cli.Options{
+		File:   fs.Arg(0),
+		Build:  *build,
+		Event:  *event,
+		Repo:   *repo,
+		StayUp: true,
+		Tag:    *tag,
+	}
+	if *cats != "" {
+		options.TriggerCats = strings.Split(*cats, ",")
+	}
+	expected := options.TriggerCats
+	if len(expected) == 0 {
+		expected, _ = service.EventCategories(configDir(options.File), *event)
+	}
+
+	// Logs would be drawn over by the screen.
+	flag.Set("stderrthreshold", "FATAL")
+
+	type result struct {
+		msg       *rpcpb.ShipshapeResponse
+		directory string
+	}
+	results := make(chan result)
+	done := make(chan error, 1)
+	options.HandleResponse = func(msg *rpcpb.ShipshapeResponse, directory string) error {
+		results <- result{msg, directory}
+		return nil
+	}
+	term, err := openTerminal()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+	defer term.restore()
+	go func() {
+		_, err := cli.New(options).Run()
+		done <- err
+	}()
+
+	table := cli.NewNoteTable()
+	progress := cli.NewProgress(expected)
+	status := "Analyzing...  " + tuiHelp
+	var runErr error
+	preview, filtering := false, false
+	for {
+		term.draw(progress, table, preview, filtering, status)
+		select {
+		case r := <-results:
+			table.Add(r.msg, r.directory)
+			progress.Update(r.msg)
+		case runErr = <-done:
+			progress.Finish()
+			status = fmt.Sprintf("Done: %d notes.  %s", table.Total(), tuiHelp)
+			if runErr != nil {
+				status = fmt.Sprintf("Error: %v", runErr)
+			}
+			done = nil
+		case key := <-term.keys:
+			if filtering {
+				switch key {
+				case keyEnter, keyEscape:
+					filtering = false
+				case keyBackspace:
+					if f := table.Filter(); f != "" {
+						table.SetFilter(f[:len(f)-1])
+					}
+				default:
+					if len(key) == 1 && key[0] >= ' ' {
+						table.SetFilter(table.Filter() + key)
+					}
+				}
+				continue
+			}
+			switch key {
+			case "q", keyCtrlC:
+				if runErr != nil {
+					return returnError
+				}
+				if table.Total() > 0 {
+					return returnFindings
+				}
+				return returnNoFindings
+			case "j", keyDown:
+				table.Move(1)
+			case "k", keyUp:
+				table.Move(-1)
+			case "/":
+				filtering = true
+			case "s":
+				table.CycleSort()
+			case "p", keyEnter:
+				preview = !preview
+			case "e":
+				if r, ok := table.SelectedRow(); ok && r.Path != "" {
+					if err := term.edit(r.Path, int(r.Note.GetLocation().GetRange().GetStartLine())); err != nil {
+						status = fmt.Sprintf("Error: could not open the editor: %v", err)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Keys read from the terminal that are not printable characters.
+const (
+	keyUp        = "\x1b[A"
+	keyDown      = "\x1b[B"
+	keyEnter     = "\r"
+	keyEscape    = "\x1b"
+	keyBackspace = "\x7f"
+	keyCtrlC     = "\x03"
+)
+
+// rawMode are the stty settings for the UI: keys are read as they are typed,
+// without echo, and reads return after a tenth of a second without a key.
+var rawMode = []string{"raw", "-echo", "min", "0", "time", "1"}
+
+// terminal is the terminal that the UI is drawn on, in raw mode. It shells
+// out to stty, like the CLI shells out to docker and git.
+type terminal struct {
+	saved string
+	keys  chan string
+	// reading is held while reading a key, so that the editor can have the
+	// terminal to itself. Reads time out, so that it is released regularly.
+	reading sync.Mutex
+}
+
+func openTerminal() (*terminal, error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("shipshape tui must be run in a terminal: %v", err)
+	}
+	t := &terminal{saved: strings.TrimSpace(saved), keys: make(chan string)}
+	if _, err := stty(rawMode...); err != nil {
+		return nil, err
+	}
+	fmt.Print("\x1b[?25l")
+	go t.read()
+	return t, nil
+}
+
+func (t *terminal) read() {
+	buf := make([]byte, 16)
+	for {
+		t.reading.Lock()
+		n, err := os.Stdin.Read(buf)
+		t.reading.Unlock()
+		switch {
+		case n > 0:
+			t.keys <- string(buf[:n])
+		case err != nil && err != io.EOF:
+			// The terminal is gone, so nobody can quit.
+			t.keys <- "q"
+			return
+		}
+	}
+}
+
+func (t *terminal) restore() {
+	fmt.Print("\x1b[?25h\x1b[H\x1b[2J")
+	stty(t.saved)
+}
+
+func (t *terminal) size() (width, height int) {
+	width, height = 80, 24
+	if out, err := stty("size"); err == nil {
+		fmt.Sscan(out, &height, &width)
+	}
+	return width, height
+}
+
+func (t *terminal) draw(p *cli.Progress, table *cli.NoteTable, preview, filtering bool, status string) {
+	width, height := t.size()
+	var lines []string
+	if r, ok := table.SelectedRow(); preview && ok && r.Path != "" {
+		var err error
+		if lines, err = cli.SourcePreview(r.Path, int(r.Note.GetLocation().GetRange().GetStartLine()), 3); err != nil {
+			lines = []string{fmt.Sprintf("Could not read %s: %v", r.Path, err)}
+		}
+	}
+	if filtering {
+		status = "Filter: " + table.Filter()
+	}
+	// In raw mode, a newline does not return the cursor to the start of the line.
+	fmt.Print("\x1b[H\x1b[2J" + strings.Join(cli.RenderTUI(p, table, lines, status, width, height), "\r\n"))
+}
+
+// edit opens path at line in $EDITOR, giving it the terminal until it exits.
+func (t *terminal) edit(path string, line int) error {
+	args := cli.EditorCommand(os.Getenv("EDITOR"), path, line)
+	t.reading.Lock()
+	defer t.reading.Unlock()
+	stty(t.saved)
+	defer stty(rawMode...)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
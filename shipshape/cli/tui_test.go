@@ -0,0 +1,193 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+	textpb "github.com/google/shipshape/shipshape/proto/textrange_proto"
+)
+
+func tableNote(category, path string, line int32, severity notepb.Note_Severity, description string) *notepb.Note {
+	return &notepb.Note{
+		Category:    proto.String(category),
+		Description: proto.String(description),
+		Severity:    severity.Enum(),
+		Location: &notepb.Location{
+			Path:  proto.String(path),
+			Range: &textpb.TextRange{StartLine: proto.Int32(line)},
+		},
+	}
+}
+
+func tablePaths(t *NoteTable) []string {
+	var paths []string
+	for i := 0; i < t.Len(); i++ {
+		paths = append(paths, filepath.Base(t.Row(i).Path))
+	}
+	return paths
+}
+
+func TestNoteTable(t *testing.T) {
+	table := NewNoteTable()
+	table.Add(&rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{{Note: []*notepb.Note{
+		tableNote("PyLint", "b.py", 3, notepb.Note_WARNING, "unused import"),
+		tableNote("go vet", "c.go", 1, notepb.Note_BUILD_ERROR, "bad printf"),
+	}}}}, "/src")
+	table.Add(&rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{{Note: []*notepb.Note{
+		tableNote("JSHint", "a.js", 7, notepb.Note_OTHER, "missing semicolon"),
+	}}}}, "/src")
+
+	if got, want := tablePaths(table), []string{"a.js", "b.py", "c.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong order by path: got %v, want %v", got, want)
+	}
+	// The first note was selected when it was added, and stays selected.
+	if r, ok := table.SelectedRow(); !ok || r.Path != "/src/b.py" {
+		t.Errorf("Wrong selected note: got %v", r)
+	}
+
+	table.CycleSort()
+	if got, want := tablePaths(table), []string{"a.js", "b.py", "c.go"}; table.SortKey() != SortByCategory || !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong order by %s: got %v, want %v", table.SortKey(), got, want)
+	}
+	table.CycleSort()
+	if got, want := tablePaths(table), []string{"c.go", "b.py", "a.js"}; table.SortKey() != SortBySeverity || !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong order by %s: got %v, want %v", table.SortKey(), got, want)
+	}
+
+	table.Move(-5)
+	if got := table.Selected(); got != 0 {
+		t.Errorf("Moving past the top: got selection %d, want 0", got)
+	}
+	table.Move(5)
+	if got := table.Selected(); got != 2 {
+		t.Errorf("Moving past the bottom: got selection %d, want 2", got)
+	}
+
+	table.SetFilter("PRINTF")
+	if got, want := tablePaths(table), []string{"c.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong filtered notes: got %v, want %v", got, want)
+	}
+	if r, ok := table.SelectedRow(); !ok || r.Path != "/src/c.go" {
+		t.Errorf("Filtering did not select a matching note: got %v", r)
+	}
+	if got, want := table.Total(), 3; got != want {
+		t.Errorf("Wrong total: got %d, want %d", got, want)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	p := NewProgress([]string{"go vet", "PyLint", "JSHint"})
+	p.Update(&rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{{
+		Note:     []*notepb.Note{tableNote("go vet", "a.go", 1, notepb.Note_WARNING, "x"), tableNote("go vet", "b.go", 1, notepb.Note_WARNING, "y")},
+		Failure:  []*rpcpb.AnalysisFailure{{Category: proto.String("PyLint")}},
+		Coverage: []*rpcpb.AnalysisCoverage{{Category: proto.String("CodeAlert")}},
+	}}})
+	want := []CategoryProgress{
+		{Category: "go vet", Done: true, Notes: 2},
+		{Category: "PyLint", Done: true, Failed: true},
+		{Category: "JSHint"},
+		{Category: "CodeAlert", Done: true},
+	}
+	if got := p.Categories(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong progress: got %v, want %v", got, want)
+	}
+	p.Finish()
+	if got := p.Categories()[2]; !got.Done {
+		t.Errorf("Category not done after the run finished: %v", got)
+	}
+}
+
+func TestSourcePreview(t *testing.T) {
+	f, err := ioutil.TempFile("", "tui_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("one\ntwo\nthree\nfour\nfive\n")
+	f.Close()
+
+	got, err := SourcePreview(f.Name(), 3, 1)
+	if err != nil {
+		t.Fatalf("SourcePreview failed: %v", err)
+	}
+	want := []string{"     2  two", ">    3  three", "     4  four"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong preview: got %q, want %q", got, want)
+	}
+}
+
+func TestEditorCommand(t *testing.T) {
+	tests := []struct {
+		editor string
+		line   int
+		want   []string
+	}{
+		{"vim", 12, []string{"vim", "+12", "a.go"}},
+		{"emacsclient -t", 12, []string{"emacsclient", "-t", "+12", "a.go"}},
+		{"/usr/local/bin/code --wait", 12, []string{"/usr/local/bin/code", "--wait", "-g", "a.go:12"}},
+		{"subl", 12, []string{"subl", "a.go:12"}},
+		{"nano", 0, []string{"nano", "a.go"}},
+		{"", 3, []string{"vi", "+3", "a.go"}},
+	}
+	for _, test := range tests {
+		if got := EditorCommand(test.editor, "a.go", test.line); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("EditorCommand(%q, %d): got %q, want %q", test.editor, test.line, got, test.want)
+		}
+	}
+}
+
+func TestRenderTUI(t *testing.T) {
+	table := NewNoteTable()
+	var notes []*notepb.Note
+	for _, name := range []string{"a.go", "b.go", "c.go", "d.go"} {
+		notes = append(notes, tableNote("go vet", name, 1, notepb.Note_WARNING, "a note that is long enough to be cut off"))
+	}
+	table.Add(&rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{{Note: notes}}}, "")
+	table.Move(3)
+	p := NewProgress([]string{"go vet", "PyLint"})
+	p.Update(&rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{{Note: notes}}})
+
+	lines := RenderTUI(p, table, []string{">    1  package d"}, "q: quit", 40, 8)
+	if got, want := len(lines), 8; got != want {
+		t.Fatalf("Wrong number of lines: got %d, want %d:\n%s", got, want, strings.Join(lines, "\n"))
+	}
+	for _, l := range lines {
+		if len(l) > 40 {
+			t.Errorf("Line is wider than the screen: %q", l)
+		}
+	}
+	if want := "Analyzers  go vet: 4  PyLint: running"; !strings.HasPrefix(want, lines[0]) {
+		t.Errorf("Wrong progress line: got %q, want %q", lines[0], want)
+	}
+	// Three rows fit, so the table scrolls to keep the selected last note visible.
+	if !strings.HasPrefix(lines[2], "  WARNING  b.go:1") || !strings.HasPrefix(lines[4], "> WARNING  d.go:1") {
+		t.Errorf("Wrong table rows:\n%s", strings.Join(lines, "\n"))
+	}
+	if lines[6] != ">    1  package d" || lines[7] != "q: quit" {
+		t.Errorf("Wrong preview and status:\n%s", strings.Join(lines, "\n"))
+	}
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/shipshape/shipshape/cli"
+	"github.com/google/shipshape/shipshape/util/docker"
+)
+
+// updateCommand implements `shipshape update`, which pins the images that a
+// run would use to their current digests in the .shipshape.lock file, so that
+// later runs use exactly those images.
+func updateCommand(args []string) int {
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	repo := fs.String("repo", cli.DefaultRepo, "The name of the docker repo to use")
+	tag := fs.String("tag", "prod", "Tag to use for the analysis service image")
+	analyzers := fs.String("analyzer_images", "", "Full docker path to images of external analyzers to lock (comma-separated). If none are specified, the analyzers in the .shipshape configuration file of the directory are locked.")
+	fs.Usage = func() {
+		fmt.Println("USAGE: shipshape update [flags] [<directory>]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return returnError
+	}
+	dir := "."
+	if fs.NArg() == 1 {
+		dir = fs.Arg(0)
+	}
+
+	options := cli.Options{Repo: *repo, Tag: *tag}
+	if *analyzers != "" {
+		options.ThirdPartyAnalyzers = strings.Split(*analyzers, ",")
+	}
+	old, err := cli.LoadLock(dir)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+	images := cli.Images(options, dir)
+	lock, err := cli.NewLock(cli.CheckImages(images, docker.Registry{}, docker.LocalRepoDigest))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+	if err := lock.Save(dir); err != nil {
+		fmt.Printf("Error: could not write the lock file: %v\n", err)
+		return returnError
+	}
+
+	var prev map[string]string
+	if old != nil {
+		prev = old.Images
+	}
+	for _, image := range images {
+		digest := lock.Images[image]
+		switch {
+		case prev[image] == "":
+			fmt.Printf("%s: locked to %s\n", image, digest)
+		case prev[image] != digest:
+			fmt.Printf("%s: updated from %s to %s\n", image, prev[image], digest)
+		default:
+			fmt.Printf("%s: unchanged\n", image)
+		}
+	}
+	fmt.Printf("Wrote %s\n", filepath.Join(dir, cli.LockFilename))
+	return returnNoFindings
+}
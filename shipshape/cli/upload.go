@@ -0,0 +1,100 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	glog "github.com/google/shipshape/third_party/go-glog"
+)
+
+// Upload copies each of the files to the cloud storage location dest, which
+// must be of the form gs://bucket/prefix or s3://bucket/prefix. The files of
+// each run go under their own path below the prefix, named by runKey, so that
+// runs do not overwrite each other. The run metadata is attached to each
+// uploaded object. Uploading shells out to
+// gsutil or the aws CLI, which must be installed and authenticated.
+func Upload(dest string, files []string, md RunMetadata) error {
+	for _, f := range files {
+		args, err := uploadArgs(dest, f, md)
+		if err != nil {
+			return err
+		}
+		glog.Infof("Uploading %s to %s", f, dest)
+		out, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("could not upload %s to %s: %v\n%s", f, dest, err, out)
+		}
+	}
+	return nil
+}
+
+// uploadArgs returns the command line which copies file to the path for the
+// run described by md under the bucket and prefix named in dest.
+func uploadArgs(dest, file string, md RunMetadata) ([]string, error) {
+	target := strings.TrimSuffix(dest, "/") + "/" + runKey(md) + "/" + filepath.Base(file)
+	meta := map[string]string{
+		"commit":    md.Commit,
+		"branch":    md.Branch,
+		"timestamp": md.Timestamp.Format(time.RFC3339),
+	}
+	switch {
+	case strings.HasPrefix(dest, "gs://"):
+		args := []string{"gsutil"}
+		for _, k := range []string{"commit", "branch", "timestamp"} {
+			if meta[k] != "" {
+				args = append(args, "-h", fmt.Sprintf("x-goog-meta-shipshape-%s:%s", k, meta[k]))
+			}
+		}
+		return append(args, "cp", file, target), nil
+	case strings.HasPrefix(dest, "s3://"):
+		// The metadata is passed as JSON, since the k=v,k=v form cannot hold
+		// a branch with a comma or an equals sign in it.
+		pairs := make(map[string]string)
+		for k, v := range meta {
+			if v != "" {
+				pairs["shipshape-"+k] = v
+			}
+		}
+		b, err := json.Marshal(pairs)
+		if err != nil {
+			return nil, err
+		}
+		return []string{"aws", "s3", "cp", file, target, "--metadata", string(b)}, nil
+	}
+	return nil, fmt.Errorf("unsupported upload location %q: must start with gs:// or s3://", dest)
+}
+
+// runKey names the path that the files of the run described by md are
+// uploaded to: the timestamp of the run, followed by its commit if it is
+// known, e.g. 20151009T120000Z-abc123.
+func runKey(md RunMetadata) string {
+	key := md.Timestamp.UTC().Format("20060102T150405Z")
+	if md.Commit != "" {
+		commit := md.Commit
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		key += "-" + commit
+	}
+	return key
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUploadArgs(t *testing.T) {
+	md := RunMetadata{
+		Commit:    "abc123",
+		Branch:    "master",
+		Timestamp: time.Date(2015, 10, 9, 12, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		dest   string
+		md     RunMetadata
+		expect []string
+	}{
+		{
+			"gs://bucket/prefix/",
+			md,
+			[]string{"gsutil",
+				"-h", "x-goog-meta-shipshape-commit:abc123",
+				"-h", "x-goog-meta-shipshape-branch:master",
+				"-h", "x-goog-meta-shipshape-timestamp:2015-10-09T12:00:00Z",
+				"cp", "/tmp/out/results.json", "gs://bucket/prefix/20151009T120000Z-abc123/results.json"},
+		},
+		{
+			"s3://bucket/prefix",
+			md,
+			[]string{"aws", "s3", "cp", "/tmp/out/results.json", "s3://bucket/prefix/20151009T120000Z-abc123/results.json",
+				"--metadata", `{"shipshape-branch":"master","shipshape-commit":"abc123","shipshape-timestamp":"2015-10-09T12:00:00Z"}`},
+		},
+		{
+			// The branch cannot add keys of its own.
+			"s3://bucket/prefix",
+			RunMetadata{Commit: "0123456789abcdef", Branch: "a,shipshape-commit=b", Timestamp: md.Timestamp},
+			[]string{"aws", "s3", "cp", "/tmp/out/results.json", "s3://bucket/prefix/20151009T120000Z-0123456789ab/results.json",
+				"--metadata", `{"shipshape-branch":"a,shipshape-commit=b","shipshape-commit":"0123456789abcdef","shipshape-timestamp":"2015-10-09T12:00:00Z"}`},
+		},
+		{
+			"gs://bucket",
+			RunMetadata{Timestamp: md.Timestamp},
+			[]string{"gsutil",
+				"-h", "x-goog-meta-shipshape-timestamp:2015-10-09T12:00:00Z",
+				"cp", "/tmp/out/results.json", "gs://bucket/20151009T120000Z/results.json"},
+		},
+	}
+
+	for _, test := range tests {
+		got, err := uploadArgs(test.dest, "/tmp/out/results.json", test.md)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.dest, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.expect) {
+			t.Errorf("%s: got %v, want %v", test.dest, got, test.expect)
+		}
+	}
+
+	if _, err := uploadArgs("ftp://host/dir", "results.json", md); err == nil {
+		t.Errorf("Expected an error for an unsupported location")
+	}
+}
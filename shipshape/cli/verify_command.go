@@ -0,0 +1,111 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/google/shipshape/shipshape/cli"
+)
+
+// verifyCommand implements `shipshape verify`, which checks an attestation
+// written by --attestation, so that a release can be gated on the code
+// having been analyzed.
+func verifyCommand(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	keyPath := fs.String("key", "", "PEM file with the public key that the attestation must be signed with")
+	commit := fs.String("commit", "", "When specified, require the attestation to be for this git commit")
+	results := fs.String("results", "", "When specified, require the notes in this results file written by --json_output to be the ones attested")
+	fs.Usage = func() {
+		fmt.Println("USAGE: shipshape verify --key=<public key> [flags] <attestation>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *keyPath == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return returnError
+	}
+
+	key, err := cli.LoadVerifyingKey(*keyPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+	env, err := cli.LoadEnvelope(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+	s, err := cli.Verify(env, key)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return returnError
+	}
+	if *commit != "" {
+		found := false
+		for _, subject := range s.Subject {
+			if c := subject.Digest["gitCommit"]; c != "" && (strings.HasPrefix(c, *commit) || strings.HasPrefix(*commit, c)) {
+				found = true
+			}
+		}
+		if !found {
+			fmt.Printf("Error: the attestation is not for commit %s\n", *commit)
+			return returnError
+		}
+		if s.Predicate.Dirty {
+			fmt.Printf("Error: the attestation is for commit %s with uncommitted changes\n", *commit)
+			return returnError
+		}
+	}
+	p := s.Predicate
+	if *results != "" {
+		resp, err := cli.LoadResults(*results)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return returnError
+		}
+		if cli.NotesDigest(resp) != p.NotesDigest["sha256"] {
+			fmt.Printf("Error: the notes in %s are not the ones attested\n", *results)
+			return returnError
+		}
+	}
+
+	for _, subject := range s.Subject {
+		fmt.Printf("Subject: %s at %s\n", subject.Name, subject.Digest["gitCommit"])
+	}
+	fmt.Printf("Analyzed %s to %s for event %s\n", p.StartedOn.Format("2006-01-02 15:04:05 MST"), p.FinishedOn.Format("15:04:05"), p.Event)
+	fmt.Printf("Categories: %s\n", strings.Join(p.Categories, ", "))
+	for _, image := range p.Images {
+		for algorithm, digest := range image.Digest {
+			fmt.Printf("Image: %s@%s:%s\n", image.Name, algorithm, digest)
+		}
+	}
+	fmt.Printf("%d notes", p.NumNotes)
+	if len(p.FailedCategories) > 0 {
+		fmt.Printf(", failed categories: %s", strings.Join(p.FailedCategories, ", "))
+	}
+	fmt.Println()
+	if p.Incomplete != "" {
+		fmt.Printf("WARNING: The results were incomplete: %s\n", p.Incomplete)
+	}
+	if p.Dirty {
+		fmt.Printf("WARNING: The work tree had uncommitted changes, so more than the subject commit was analyzed\n")
+	}
+	return returnNoFindings
+}
@@ -0,0 +1,242 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// WarehouseField describes a column of the warehouse schema, in the format
+// used by BigQuery schema files (e.g. bq mk --schema).
+type WarehouseField struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Mode        string `json:"mode"`
+	Description string `json:"description"`
+}
+
+// WarehouseSchema is the published schema of the rows written by
+// WriteWarehouseRows. Columns may be added to it, but existing ones are never
+// renamed or removed, so that tables created from it keep working.
+var WarehouseSchema = []WarehouseField{
+	{"run_timestamp", "TIMESTAMP", "REQUIRED", "When the run happened"},
+	{"commit", "STRING", "NULLABLE", "The git commit that was analyzed"},
+	{"branch", "STRING", "NULLABLE", "The git branch that was analyzed"},
+	{"repository", "STRING", "NULLABLE", "The URL of the repository's origin remote, without credentials"},
+	{"ci_system", "STRING", "NULLABLE", "The continuous integration system that ran shipshape, e.g. github or jenkins"},
+	{"ci_build_url", "STRING", "NULLABLE", "A link to the continuous integration build"},
+	{"fingerprint", "STRING", "REQUIRED", "Identifies the note across runs; see shipshape trend"},
+	{"category", "STRING", "REQUIRED", "The category of the analyzer that found the note"},
+	{"subcategory", "STRING", "NULLABLE", "The kind of problem within the category"},
+	{"severity", "STRING", "REQUIRED", "BUILD_ERROR, WARNING or OTHER"},
+	{"path", "STRING", "NULLABLE", "The path of the file, relative to the analyzed directory. Empty for global notes."},
+	{"start_line", "INTEGER", "NULLABLE", "The first line of the note"},
+	{"start_col", "INTEGER", "NULLABLE", "The first column of the note"},
+	{"end_line", "INTEGER", "NULLABLE", "The last line of the note"},
+	{"end_col", "INTEGER", "NULLABLE", "The last column of the note"},
+	{"description", "STRING", "REQUIRED", "What the note says"},
+	{"more_info", "STRING", "NULLABLE", "A link to more information about the note"},
+	{"url", "STRING", "NULLABLE", "A link to the documentation for the rule that produced the note"},
+}
+
+// WarehouseRow is a single note with the metadata of the run that found it,
+// as described by WarehouseSchema.
+type WarehouseRow struct {
+	RunTimestamp string `json:"run_timestamp"`
+	Commit       string `json:"commit,omitempty"`
+	Branch       string `json:"branch,omitempty"`
+	Repository   string `json:"repository,omitempty"`
+	CiSystem     string `json:"ci_system,omitempty"`
+	CiBuildURL   string `json:"ci_build_url,omitempty"`
+	Fingerprint  string `json:"fingerprint"`
+	Category     string `json:"category"`
+	Subcategory  string `json:"subcategory,omitempty"`
+	Severity     string `json:"severity"`
+	Path         string `json:"path,omitempty"`
+	StartLine    int32  `json:"start_line,omitempty"`
+	StartCol     int32  `json:"start_col,omitempty"`
+	EndLine      int32  `json:"end_line,omitempty"`
+	EndCol       int32  `json:"end_col,omitempty"`
+	Description  string `json:"description"`
+	MoreInfo     string `json:"more_info,omitempty"`
+	URL          string `json:"url,omitempty"`
+}
+
+// WarehouseRun is the metadata of a run that is attached to each of its rows.
+type WarehouseRun struct {
+	RunMetadata
+	Repository string
+	CiSystem   string
+	CiBuildURL string
+}
+
+// WarehouseRows converts the notes in resp to rows for the warehouse.
+func WarehouseRows(run WarehouseRun, resp *rpcpb.ShipshapeResponse) []WarehouseRow {
+	var rows []WarehouseRow
+	for _, note := range AllNotes(resp) {
+		r := note.GetLocation().GetRange()
+		rows = append(rows, WarehouseRow{
+			RunTimestamp: run.Timestamp.UTC().Format(time.RFC3339),
+			Commit:       run.Commit,
+			Branch:       run.Branch,
+			Repository:   run.Repository,
+			CiSystem:     run.CiSystem,
+			CiBuildURL:   run.CiBuildURL,
+			Fingerprint:  Fingerprint(note),
+			Category:     note.GetCategory(),
+			Subcategory:  note.GetSubcategory(),
+			Severity:     note.GetSeverity().String(),
+			Path:         note.GetLocation().GetPath(),
+			StartLine:    r.GetStartLine(),
+			StartCol:     r.GetStartColumn(),
+			EndLine:      r.GetEndLine(),
+			EndCol:       r.GetEndColumn(),
+			Description:  note.GetDescription(),
+			MoreInfo:     note.GetMoreInfo(),
+			URL:          note.GetUrl(),
+		})
+	}
+	return rows
+}
+
+// WriteWarehouseRows writes rows to w as newline-delimited JSON, which can be
+// loaded with bq load --source_format=NEWLINE_DELIMITED_JSON.
+func WriteWarehouseRows(w io.Writer, rows []WarehouseRow) error {
+	enc := json.NewEncoder(w)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bigQueryBatchSize is the number of rows sent in each insertAll request,
+// which BigQuery recommends keeping to at most 500.
+const bigQueryBatchSize = 500
+
+// BigQueryTable streams rows into a BigQuery table with the insertAll API.
+type BigQueryTable struct {
+	// API is the base URL of the BigQuery API.
+	API     string
+	Project string
+	Dataset string
+	Table   string
+	// Token is an OAuth2 access token, e.g. from gcloud auth print-access-token.
+	Token string
+}
+
+// bigQueryName matches the names of projects, datasets and tables that can be
+// put in the path of a request to the BigQuery API as they are.
+var bigQueryName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ParseBigQueryTable parses a table name of the form project.dataset.table
+// or project:dataset.table. The names may only have letters, digits,
+// underscores and dashes.
+func ParseBigQueryTable(name string) (*BigQueryTable, error) {
+	parts := strings.Split(strings.Replace(name, ":", ".", 1), ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid BigQuery table %q: must be project.dataset.table", name)
+	}
+	for _, part := range parts {
+		if !bigQueryName.MatchString(part) {
+			return nil, fmt.Errorf("invalid BigQuery table %q: must be project.dataset.table, with only letters, digits, _ and - in the names", name)
+		}
+	}
+	return &BigQueryTable{
+		API:     "https://bigquery.googleapis.com/bigquery/v2",
+		Project: parts[0],
+		Dataset: parts[1],
+		Table:   parts[2],
+	}, nil
+}
+
+type bigQueryRow struct {
+	InsertID string       `json:"insertId"`
+	JSON     WarehouseRow `json:"json"`
+}
+
+type bigQueryInsertRequest struct {
+	Rows []bigQueryRow `json:"rows"`
+}
+
+type bigQueryInsertResponse struct {
+	InsertErrors []struct {
+		Index  int `json:"index"`
+		Errors []struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	} `json:"insertErrors"`
+}
+
+// Insert streams the rows into the table. Each row is given an insert ID
+// derived from its contents, so that BigQuery drops the duplicates if an
+// export is retried.
+func (b *BigQueryTable) Insert(rows []WarehouseRow) error {
+	for _, name := range []string{b.Project, b.Dataset, b.Table} {
+		if !bigQueryName.MatchString(name) {
+			return fmt.Errorf("invalid BigQuery table %s.%s.%s: only letters, digits, _ and - are allowed in the names", b.Project, b.Dataset, b.Table)
+		}
+	}
+	target := fmt.Sprintf("%s/projects/%s/datasets/%s/tables/%s/insertAll", strings.TrimSuffix(b.API, "/"), b.Project, b.Dataset, b.Table)
+	auth := func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+b.Token)
+	}
+	for start := 0; start < len(rows); start += bigQueryBatchSize {
+		end := start + bigQueryBatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		var req bigQueryInsertRequest
+		for i, row := range rows[start:end] {
+			req.Rows = append(req.Rows, bigQueryRow{insertID(row, start+i), row})
+		}
+		var resp bigQueryInsertResponse
+		if err := callJSON("POST", target, req, &resp, auth); err != nil {
+			return err
+		}
+		if len(resp.InsertErrors) > 0 {
+			e := resp.InsertErrors[0]
+			msg := "unknown error"
+			if len(e.Errors) > 0 {
+				msg = e.Errors[0].Reason + ": " + e.Errors[0].Message
+			}
+			return fmt.Errorf("could not insert %d rows into %s.%s.%s, e.g. row %d: %s",
+				len(resp.InsertErrors), b.Project, b.Dataset, b.Table, start+e.Index, msg)
+		}
+	}
+	return nil
+}
+
+// insertID identifies row for deduplication. Identical notes in the same
+// run are told apart by their index.
+func insertID(row WarehouseRow, index int) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%d", row.RunTimestamp, row.Commit, row.Fingerprint, index)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func warehouseResults(n int) *rpcpb.ShipshapeResponse {
+	var notes []*notepb.Note
+	for i := 0; i < n; i++ {
+		notes = append(notes, tableNote("JSHint", "a.js", int32(i+1), notepb.Note_WARNING, fmt.Sprintf("Note %d", i)))
+	}
+	return &rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{{Note: notes}}}
+}
+
+func TestWarehouseRows(t *testing.T) {
+	run := WarehouseRun{
+		RunMetadata: RunMetadata{Commit: "abc", Branch: "main", Timestamp: time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)},
+		CiSystem:    "travis",
+	}
+	resp := warehouseResults(2)
+	resp.AnalyzeResponse[0].Note[1].Url = proto.String("http://jshint.com/docs")
+	rows := WarehouseRows(run, resp)
+	var buf bytes.Buffer
+	if err := WriteWarehouseRows(&buf, rows); err != nil {
+		t.Fatalf("WriteWarehouseRows failed: %v", err)
+	}
+
+	columns := make(map[string]bool)
+	for _, f := range WarehouseSchema {
+		columns[f.Name] = true
+	}
+	var lines int
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		lines++
+		var row map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatalf("Could not decode row %q: %v", scanner.Text(), err)
+		}
+		for name := range row {
+			if !columns[name] {
+				t.Errorf("Row has column %q, which is not in the schema", name)
+			}
+		}
+		if got, want := row["run_timestamp"], "2015-06-01T12:00:00Z"; got != want {
+			t.Errorf("Wrong run_timestamp: got %v, want %v", got, want)
+		}
+		if got, want := row["ci_system"], "travis"; got != want {
+			t.Errorf("Wrong ci_system: got %v, want %v", got, want)
+		}
+	}
+	if lines != 2 {
+		t.Errorf("Wrong number of rows: got %d, want 2", lines)
+	}
+	if rows[1].StartLine != 2 || rows[1].Severity != "WARNING" || rows[1].Fingerprint == "" || rows[1].URL != "http://jshint.com/docs" {
+		t.Errorf("Wrong row: got %+v", rows[1])
+	}
+}
+
+func TestParseBigQueryTable(t *testing.T) {
+	for _, name := range []string{"p.d.t", "p:d.t"} {
+		b, err := ParseBigQueryTable(name)
+		if err != nil {
+			t.Errorf("ParseBigQueryTable(%q) failed: %v", name, err)
+			continue
+		}
+		if b.Project != "p" || b.Dataset != "d" || b.Table != "t" {
+			t.Errorf("ParseBigQueryTable(%q): got %+v", name, b)
+		}
+	}
+	for _, name := range []string{"", "d.t", "p.d.t.x", "p..t", "p.d.t/../../x", "p.d.t?x=1", "p.d%2F.t"} {
+		if _, err := ParseBigQueryTable(name); err == nil {
+			t.Errorf("ParseBigQueryTable(%q): expected an error", name)
+		}
+	}
+}
+
+func TestBigQueryInsert(t *testing.T) {
+	var batches []int
+	ids := make(map[string]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Path, "/projects/p/datasets/d/tables/t/insertAll"; got != want {
+			t.Errorf("Wrong path: got %q, want %q", got, want)
+		}
+		if got, want := r.Header.Get("Authorization"), "Bearer secret"; got != want {
+			t.Errorf("Wrong authorization: got %q, want %q", got, want)
+		}
+		var req bigQueryInsertRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Could not decode request: %v", err)
+		}
+		batches = append(batches, len(req.Rows))
+		for _, row := range req.Rows {
+			ids[row.InsertID] = true
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	b := &BigQueryTable{API: server.URL, Project: "p", Dataset: "d", Table: "t", Token: "secret"}
+	rows := WarehouseRows(WarehouseRun{}, warehouseResults(bigQueryBatchSize+1))
+	if err := b.Insert(rows); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if len(batches) != 2 || batches[0] != bigQueryBatchSize || batches[1] != 1 {
+		t.Errorf("Wrong batches: got %v", batches)
+	}
+	if len(ids) != len(rows) {
+		t.Errorf("Insert IDs are not unique: got %d for %d rows", len(ids), len(rows))
+	}
+}
+
+func TestBigQueryInsertErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"insertErrors": [{"index": 0, "errors": [{"reason": "invalid", "message": "no such field"}]}]}`))
+	}))
+	defer server.Close()
+
+	b := &BigQueryTable{API: server.URL, Project: "p", Dataset: "d", Table: "t"}
+	if err := b.Insert(WarehouseRows(WarehouseRun{}, warehouseResults(1))); err == nil {
+		t.Errorf("Expected an error for rows that were not inserted")
+	}
+}
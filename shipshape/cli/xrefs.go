@@ -0,0 +1,378 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+	glog "github.com/google/shipshape/third_party/go-glog"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+	textpb "github.com/google/shipshape/shipshape/proto/textrange_proto"
+	"github.com/google/shipshape/shipshape/util/docker"
+)
+
+// xrefsFile is the file of the compilations directory that docker.IndexKythe
+// writes the entries of the kythe graph of the compilation units to.
+const xrefsFile = "entries.json"
+
+// The facts and edges of the kythe graph that the cross-references are read from.
+const (
+	factNodeKind   = "/kythe/node/kind"
+	factLocStart   = "/kythe/loc/start"
+	factLocEnd     = "/kythe/loc/end"
+	edgeNamed      = "/kythe/edge/named"
+	edgeDefines    = "/kythe/edge/defines"
+	edgeBinding    = "/kythe/edge/defines/binding"
+	edgeRef        = "/kythe/edge/ref"
+	edgeRefCall    = "/kythe/edge/ref/call"
+	nodeKindAnchor = "anchor"
+)
+
+// VName names a node of the kythe graph.
+type VName struct {
+	Signature string `json:"signature,omitempty"`
+	Corpus    string `json:"corpus,omitempty"`
+	Root      string `json:"root,omitempty"`
+	Path      string `json:"path,omitempty"`
+	Language  string `json:"language,omitempty"`
+}
+
+// Ticket returns the kythe URI of the node, e.g.
+// kythe://corpus?lang=java?path=src/Foo.java#signature.
+func (v VName) Ticket() string {
+	t := "kythe://" + v.Corpus
+	for _, param := range []struct{ name, value string }{{"lang", v.Language}, {"path", v.Path}, {"root", v.Root}} {
+		if param.value != "" {
+			t += "?" + param.name + "=" + param.value
+		}
+	}
+	if v.Signature != "" {
+		t += "#" + v.Signature
+	}
+	return t
+}
+
+// kytheEntry is a fact of a node of the kythe graph, or an edge of it if EdgeKind
+// is set, as written by kythe's entrystream --write_json. The fact value is base64
+// in JSON, which encoding/json decodes into a []byte.
+type kytheEntry struct {
+	Source    VName  `json:"source"`
+	EdgeKind  string `json:"edge_kind,omitempty"`
+	Target    VName  `json:"target"`
+	FactName  string `json:"fact_name"`
+	FactValue []byte `json:"fact_value,omitempty"`
+}
+
+// anchor is a span of a file, in bytes, that refers to or defines a node.
+type anchor struct {
+	path       string
+	start, end int
+	target     string
+	// kind is the edge from the anchor to the node: a reference, the binding
+	// of the name of its definition, or the whole of its definition.
+	kind string
+}
+
+// XrefIndex finds the symbols that code refers to or defines, from the kythe
+// graph of a build.
+type XrefIndex struct {
+	// root is the directory the paths of the notes and of the graph are relative to.
+	root string
+	// anchors are the references and definitions in each file, keyed by path.
+	anchors map[string][]anchor
+	// names are the fully-qualified names of the nodes that have them, keyed by ticket.
+	names map[string]string
+	// definitions are where each node is defined, keyed by ticket.
+	definitions map[string]anchor
+	// files caches the contents of each file, keyed by path, or nil if it can't be read.
+	files map[string][]byte
+}
+
+// LoadXrefs reads the kythe graph that docker.IndexKythe wrote for the workspace
+// root, or returns nil if there is none.
+func LoadXrefs(root string) (*XrefIndex, error) {
+	f, err := os.Open(filepath.Join(root, compilationsDir, xrefsFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadXrefs(root, f)
+}
+
+// indexCompilations indexes the compilation units extracted into the workspace
+// absRoot, which is at hostRoot on the docker host, with the kythe image, for
+// Xrefs. The notes are still useful without their symbols, so failures are only
+// logged.
+func (i *Invocation) indexCompilations(image, hostRoot, absRoot string) {
+	result := docker.IndexKythe(image, "kythe-index", hostRoot, i.dockerAccess())
+	if result.Err != nil {
+		printStreams(result)
+		glog.Errorf("Could not index the compilation units, so the notes have no symbols: %v", result.Err)
+		return
+	}
+	xrefs, err := LoadXrefs(absRoot)
+	if err != nil {
+		glog.Errorf("Could not read the cross-references, so the notes have no symbols: %v", err)
+		return
+	}
+	i.xrefs = xrefs
+	glog.Infoln("Cross-references indexed")
+}
+
+// ReadXrefs reads a stream of kythe entries in JSON for the code in root.
+func ReadXrefs(root string, r io.Reader) (*XrefIndex, error) {
+	type node struct {
+		vname      VName
+		kind       string
+		start, end int
+		edges      []kytheEntry
+	}
+	nodes := make(map[string]*node)
+	get := func(v VName) *node {
+		t := v.Ticket()
+		n, ok := nodes[t]
+		if !ok {
+			n = &node{vname: v, start: -1, end: -1}
+			nodes[t] = n
+		}
+		return n
+	}
+	x := &XrefIndex{
+		root:        root,
+		anchors:     make(map[string][]anchor),
+		names:       make(map[string]string),
+		definitions: make(map[string]anchor),
+		files:       make(map[string][]byte),
+	}
+	dec := json.NewDecoder(r)
+	for {
+		var e kytheEntry
+		if err := dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("could not read the kythe entries: %v", err)
+		}
+		switch e.EdgeKind {
+		case "":
+			n := get(e.Source)
+			switch e.FactName {
+			case factNodeKind:
+				n.kind = string(e.FactValue)
+			case factLocStart:
+				n.start, _ = strconv.Atoi(string(e.FactValue))
+			case factLocEnd:
+				n.end, _ = strconv.Atoi(string(e.FactValue))
+			}
+		case edgeNamed:
+			x.names[e.Source.Ticket()] = e.Target.Signature
+		case edgeRef, edgeRefCall, edgeBinding, edgeDefines:
+			n := get(e.Source)
+			n.edges = append(n.edges, e)
+		}
+	}
+	for _, n := range nodes {
+		if n.kind != nodeKindAnchor || n.start < 0 || n.end <= n.start {
+			continue
+		}
+		for _, e := range n.edges {
+			a := anchor{path: n.vname.Path, start: n.start, end: n.end, target: e.Target.Ticket(), kind: e.EdgeKind}
+			if a.kind == edgeRefCall {
+				a.kind = edgeRef
+			}
+			x.anchors[a.path] = append(x.anchors[a.path], a)
+			if a.kind == edgeRef {
+				continue
+			}
+			// The binding of the name is a better place to point at than the
+			// whole of the definition.
+			if def, ok := x.definitions[a.target]; !ok || def.kind == edgeDefines && a.kind == edgeBinding {
+				x.definitions[a.target] = a
+			}
+		}
+	}
+	return x, nil
+}
+
+// Enrich fills in the symbol of each note in resp that is on code the graph has a
+// reference or definition at, and returns how many it filled in. A note on a
+// whole line is about the first name defined or referred to on it; a note with
+// a column is about the innermost reference or definition that contains it, or
+// failing that, about the innermost definition that does.
+func (x *XrefIndex) Enrich(resp *rpcpb.ShipshapeResponse) int {
+	n := 0
+	for _, analysis := range resp.AnalyzeResponse {
+		for _, note := range analysis.Note {
+			if note.Symbol != nil {
+				continue
+			}
+			a, ok := x.find(note.GetLocation())
+			if !ok {
+				continue
+			}
+			sym := &notepb.Symbol{Ticket: proto.String(a.target)}
+			if name := x.names[a.target]; name != "" {
+				sym.Name = proto.String(name)
+			}
+			if def, ok := x.definitions[a.target]; ok {
+				sym.Definition = &notepb.Location{Path: proto.String(def.path), Range: x.textRange(def)}
+			}
+			note.Symbol = sym
+			n++
+		}
+	}
+	return n
+}
+
+// find returns the anchor that the code at loc is best described by.
+func (x *XrefIndex) find(loc *notepb.Location) (anchor, bool) {
+	path := loc.GetPath()
+	anchors := x.anchors[path]
+	if len(anchors) == 0 {
+		return anchor{}, false
+	}
+	start, end, point, ok := x.span(path, loc.GetRange())
+	if !ok {
+		return anchor{}, false
+	}
+	var best, enclosing *anchor
+	for i := range anchors {
+		a := &anchors[i]
+		if a.end <= start || a.start >= end {
+			continue
+		}
+		if a.kind == edgeDefines {
+			if a.start <= start && (enclosing == nil || a.end-a.start < enclosing.end-enclosing.start) {
+				enclosing = a
+			}
+			continue
+		}
+		switch {
+		case best == nil:
+			best = a
+		case point && a.end-a.start < best.end-best.start:
+			best = a
+		case !point && (a.start < best.start || a.start == best.start && a.kind == edgeBinding):
+			best = a
+		}
+	}
+	if best == nil && point {
+		best = enclosing
+	}
+	if best == nil {
+		return anchor{}, false
+	}
+	return *best, true
+}
+
+// span returns the bytes of the file at path that r covers: one byte if it has a
+// column or offset, which the returned point is set for, and otherwise its first
+// line. It returns false if r has no line or is outside the file.
+func (x *XrefIndex) span(path string, r *textpb.TextRange) (start, end int, point, ok bool) {
+	if r.StartOffset != nil {
+		return int(r.GetStartOffset()), int(r.GetStartOffset()) + 1, true, true
+	}
+	if r.GetStartLine() <= 0 {
+		return 0, 0, false, false
+	}
+	content := x.file(path)
+	start, end, ok = lineSpan(content, int(r.GetStartLine()))
+	if !ok {
+		return 0, 0, false, false
+	}
+	if col := int(r.GetStartColumn()); col > 0 {
+		off := start
+		for c := 1; c < col && off < end; c++ {
+			_, size := utf8.DecodeRune(content[off:])
+			off += size
+		}
+		return off, off + 1, true, true
+	}
+	return start, end, false, true
+}
+
+// textRange returns the range of the file that a covers, with its lines and
+// columns if the file can be read.
+func (x *XrefIndex) textRange(a anchor) *textpb.TextRange {
+	r := &textpb.TextRange{StartOffset: proto.Int32(int32(a.start)), EndOffset: proto.Int32(int32(a.end))}
+	content := x.file(a.path)
+	if a.end > len(content) {
+		return r
+	}
+	line, col := position(content, a.start)
+	r.StartLine, r.StartColumn = proto.Int32(int32(line)), proto.Int32(int32(col))
+	// The end column is inclusive, so it is that of the last character.
+	_, size := utf8.DecodeLastRune(content[a.start:a.end])
+	line, col = position(content, a.end-size)
+	r.EndLine, r.EndColumn = proto.Int32(int32(line)), proto.Int32(int32(col))
+	return r
+}
+
+// file returns the contents of the file at path, or nil if it can't be read.
+func (x *XrefIndex) file(path string) []byte {
+	content, ok := x.files[path]
+	if !ok {
+		content, _ = ioutil.ReadFile(filepath.Join(x.root, path))
+		x.files[path] = content
+	}
+	return content
+}
+
+// lineSpan returns the offsets of the first byte of the one-based line of content,
+// and of the newline ending it or the end of content.
+func lineSpan(content []byte, line int) (start, end int, ok bool) {
+	for l := 1; l < line; l++ {
+		i := bytes.IndexByte(content[start:], '\n')
+		if i < 0 {
+			return 0, 0, false
+		}
+		start += i + 1
+	}
+	end = len(content)
+	if i := bytes.IndexByte(content[start:], '\n'); i >= 0 {
+		end = start + i
+	}
+	return start, end, true
+}
+
+// position returns the one-based line and column of the character at the offset
+// of content, counting columns in characters.
+func position(content []byte, offset int) (line, col int) {
+	line, col = 1, 1
+	for i := 0; i < offset; {
+		r, size := utf8.DecodeRune(content[i:])
+		if r == '\n' {
+			line, col = line+1, 1
+		} else {
+			col++
+		}
+		i += size
+	}
+	return line, col
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+	textpb "github.com/google/shipshape/shipshape/proto/textrange_proto"
+)
+
+const xrefsSource = "package p;\nclass Foo {\n  void bar() { baz(); }\n}\n"
+
+// xrefsEntries returns the kythe entries of xrefsSource, in src/Foo.java.
+func xrefsEntries(t *testing.T) []byte {
+	node := func(sig string) VName { return VName{Signature: sig, Corpus: "c", Language: "java"} }
+	var entries []kytheEntry
+	anchor := func(sig string, start, end int, edge string, target VName) {
+		a := VName{Signature: sig, Corpus: "c", Path: "src/Foo.java", Language: "java"}
+		entries = append(entries,
+			kytheEntry{Source: a, FactName: factNodeKind, FactValue: []byte(nodeKindAnchor)},
+			kytheEntry{Source: a, FactName: factLocStart, FactValue: []byte(strconv.Itoa(start))},
+			kytheEntry{Source: a, FactName: factLocEnd, FactValue: []byte(strconv.Itoa(end))},
+			kytheEntry{Source: a, EdgeKind: edge, Target: target, FactName: "/"})
+	}
+	anchor("a1", 17, 20, edgeBinding, node("Foo"))
+	anchor("a2", 30, 33, edgeBinding, node("bar"))
+	anchor("a3", 38, 41, edgeRefCall, node("baz"))
+	anchor("a4", 25, 46, edgeDefines, node("bar"))
+	entries = append(entries,
+		kytheEntry{Source: node("Foo"), EdgeKind: edgeNamed, Target: VName{Signature: "p.Foo"}, FactName: "/"},
+		kytheEntry{Source: node("bar"), EdgeKind: edgeNamed, Target: VName{Signature: "p.Foo.bar"}, FactName: "/"})
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return b.Bytes()
+}
+
+func TestVNameTicket(t *testing.T) {
+	v := VName{Signature: "sig", Corpus: "corpus", Path: "src/Foo.java", Language: "java"}
+	if got, want := v.Ticket(), "kythe://corpus?lang=java?path=src/Foo.java#sig"; got != want {
+		t.Errorf("Wrong ticket: got %q, want %q", got, want)
+	}
+}
+
+func TestEnrich(t *testing.T) {
+	root, err := ioutil.TempDir("", "xrefs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	writeFiles(t, root, map[string]string{
+		"src/Foo.java": xrefsSource,
+		filepath.Join(compilationsDir, xrefsFile): string(xrefsEntries(t)),
+	})
+	x, err := LoadXrefs(root)
+	if err != nil {
+		t.Fatalf("Could not load the cross-references: %v", err)
+	}
+
+	note := func(path string, line, col int32) *notepb.Note {
+		return &notepb.Note{Location: &notepb.Location{
+			Path:  proto.String(path),
+			Range: &textpb.TextRange{StartLine: proto.Int32(line), StartColumn: proto.Int32(col)},
+		}}
+	}
+	bar := "kythe://c?lang=java#bar"
+	tests := []struct {
+		note       *notepb.Note
+		ticket     string
+		name       string
+		definition *textpb.TextRange
+	}{
+		// The whole line is about the class it defines.
+		{note("src/Foo.java", 2, 0), "kythe://c?lang=java#Foo", "p.Foo", &textpb.TextRange{
+			StartLine: proto.Int32(2), StartColumn: proto.Int32(7), EndLine: proto.Int32(2), EndColumn: proto.Int32(9),
+			StartOffset: proto.Int32(17), EndOffset: proto.Int32(20),
+		}},
+		// The first name on the line is that of the method.
+		{note("src/Foo.java", 3, 0), bar, "p.Foo.bar", nil},
+		// The call that the column is on.
+		{note("src/Foo.java", 3, 16), "kythe://c?lang=java#baz", "", nil},
+		// No name at the column, so the method around it.
+		{note("src/Foo.java", 3, 3), bar, "p.Foo.bar", nil},
+		{note("src/Foo.java", 1, 0), "", "", nil},
+		{note("src/Bar.java", 2, 0), "", "", nil},
+	}
+	resp := &rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{{}}}
+	for _, test := range tests {
+		resp.AnalyzeResponse[0].Note = append(resp.AnalyzeResponse[0].Note, test.note)
+	}
+	if got, want := x.Enrich(resp), 4; got != want {
+		t.Errorf("Wrong number of notes enriched: got %d, want %d", got, want)
+	}
+	for _, test := range tests {
+		sym := test.note.GetSymbol()
+		if sym.GetTicket() != test.ticket || sym.GetName() != test.name {
+			t.Errorf("Wrong symbol for %v: got %v, want ticket %q and name %q", test.note.Location, sym, test.ticket, test.name)
+		}
+		if test.definition != nil && !proto.Equal(sym.GetDefinition().GetRange(), test.definition) {
+			t.Errorf("Wrong definition for %v: got %v, want %v", test.note.Location, sym.GetDefinition().GetRange(), test.definition)
+		}
+		if test.ticket == bar && sym.GetDefinition().GetRange().GetStartOffset() != 30 {
+			t.Errorf("The definition of bar should be its binding, got %v", sym.GetDefinition())
+		}
+	}
+}
+
+func TestLoadXrefsMissing(t *testing.T) {
+	root, err := ioutil.TempDir("", "xrefs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if x, err := LoadXrefs(root); x != nil || err != nil {
+		t.Errorf("LoadXrefs without entries = %v, %v; want nil, nil", x, err)
+	}
+}
@@ -14,8 +14,9 @@
 # See the License for the specific language governing permissions and
 # limitations under the License.
 
-# Start dispatchers
-./go_dispatcher &> /shipshape-output/shipshape.go_dispatcher.log &
+# Start dispatchers. With DETERMINISTIC set, the go dispatcher ignores the
+# analyzer configuration in the repository.
+./go_dispatcher ${DETERMINISTIC:+--deterministic} &> /shipshape-output/shipshape.go_dispatcher.log &
 java -jar java_dispatcher.jar &> /shipshape-output/shipshape.java_dispatcher.log &
 java -jar javac_dispatcher.jar &> /shipshape-output/shipshape.javac_dispatcher.log &
 
@@ -24,7 +25,10 @@ if [ -z "$START_SERVICE" ]
 then
   echo 'Running shipping container in streaming mode' > /shipshape-output/shipshape.shipping_container.log
   ./shipshape --analyzer_services="$(eval echo $ANALYZERS)"
+elif [ -n "$SERVICE_SOCKET" ]
+then
+  ./shipshape --start_service --socket="$SERVICE_SOCKET" --analyzer_services="$(eval echo $ANALYZERS)" --restart_analyzer_command="$RESTART_ANALYZER" &> /shipshape-output/shipshape.shipping_container.log
 else
-  ./shipshape --start_service --analyzer_services="$(eval echo $ANALYZERS)" &> /shipshape-output/shipshape.shipping_container.log
+  ./shipshape --start_service --analyzer_services="$(eval echo $ANALYZERS)" --restart_analyzer_command="$RESTART_ANALYZER" &> /shipshape-output/shipshape.shipping_container.log
 fi
 
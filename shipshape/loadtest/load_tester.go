@@ -0,0 +1,97 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Binary load_tester puts synthetic load on a running shipshape service. For
+// example, with the service started by `shipshape --stay_up` on a workspace:
+//
+//	load_tester --workspace=/path/to/workspace --generate=500 --files=50 --concurrency=8 --requests=200
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/shipshape/shipshape/loadtest"
+	"github.com/google/shipshape/shipshape/service"
+	"github.com/google/shipshape/shipshape/util/rpc/client"
+)
+
+var (
+	address     = flag.String("service", "localhost:10007", "The address of the shipshape service")
+	socket      = flag.String("service_socket", "", "When specified, talk to the service over this unix socket instead of --service")
+	workspace   = flag.String("workspace", "", "The directory that the service analyzes, as seen by this tool")
+	repoRoot    = flag.String("repo_root", "/shipshape-workspace", "The directory that the service analyzes, as seen by the service")
+	generate    = flag.Int("generate", 0, "When positive, write this many synthetic source files to --workspace first")
+	concurrency = flag.Int("concurrency", 4, "How many requests to have in flight at once")
+	requests    = flag.Int("requests", 100, "How many requests to send in all")
+	files       = flag.Int("files", 10, "How many files of the workspace each request analyzes")
+	categories  = flag.String("categories", "", "Categories to trigger (comma-separated). If none are specified, the service uses the .shipshape configuration file of the workspace.")
+	event       = flag.String("event", "manual", "The name of the event to send")
+	seed        = flag.Int64("seed", time.Now().UnixNano(), "Seed for the choice of files, to repeat a load test")
+)
+
+func main() {
+	flag.Parse()
+	if *workspace == "" {
+		fmt.Println("Error: --workspace is required")
+		os.Exit(2)
+	}
+	var paths []string
+	var err error
+	if *generate > 0 {
+		paths, err = loadtest.GenerateWorkspace(*workspace, *generate)
+	} else {
+		paths, err = service.FilesToAnalyze(*workspace, nil)
+	}
+	if err != nil {
+		fmt.Printf("Error: could not list the workspace files: %v\n", err)
+		os.Exit(2)
+	}
+	if len(paths) == 0 {
+		fmt.Printf("Error: %s has no files to analyze; pass --generate to create some\n", *workspace)
+		os.Exit(2)
+	}
+
+	c := client.NewHTTPClient(*address)
+	if *socket != "" {
+		c = client.NewUnixClient(*socket)
+	}
+	if err := c.WaitUntilReady(10 * time.Second); err != nil {
+		fmt.Printf("Error: the service did not become ready: %v\n", err)
+		os.Exit(2)
+	}
+	cfg := loadtest.Config{
+		Concurrency: *concurrency,
+		Requests:    *requests,
+		Files:       *files,
+		Workspace:   paths,
+		RepoRoot:    *repoRoot,
+		Event:       *event,
+		Seed:        *seed,
+	}
+	if *categories != "" {
+		cfg.Categories = strings.Split(*categories, ",")
+	}
+	fmt.Printf("Sending %d requests of %d files, %d at a time (seed %d)\n", cfg.Requests, cfg.Files, cfg.Concurrency, cfg.Seed)
+	report := loadtest.Run(client.ShipshapeClient{Client: c}, cfg)
+	fmt.Print(report)
+	if failed, _ := report.Failures(); failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,255 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package loadtest drives the Run method of a shipshape service with
+// synthetic requests, to check how it copes with many concurrent clients
+// before it is deployed to serve them.
+package loadtest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/shipshape/shipshape/util/rpc/client"
+
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// Config describes the load to put on the service.
+type Config struct {
+	// Concurrency is how many requests are in flight at once.
+	Concurrency int
+	// Requests is how many requests to send in all.
+	Requests int
+	// Files is how many of the workspace files each request analyzes. They
+	// are chosen at random, so that the requests differ.
+	Files int
+	// Workspace are the files that requests choose from, relative to RepoRoot.
+	Workspace []string
+	// RepoRoot is the root of the workspace as the service sees it.
+	RepoRoot   string
+	Categories []string
+	Event      string
+	// Seed seeds the choice of files, so that a load test can be repeated.
+	Seed int64
+}
+
+// Result is the outcome of a single request.
+type Result struct {
+	Latency time.Duration
+	Notes   int
+	// Err is set if the request failed, e.g. because the service could not
+	// be reached or the stream of results broke off.
+	Err error
+	// AnalyzerFailures are the analyses that failed, even though the request
+	// itself succeeded.
+	AnalyzerFailures int
+}
+
+// Report is the outcome of a load test.
+type Report struct {
+	Results []Result
+	// Elapsed is how long the load test took from the first request to the last
+	// response.
+	Elapsed time.Duration
+}
+
+// Run sends cfg.Requests requests to the service, cfg.Concurrency at a time,
+// and waits for all of their results.
+func Run(c client.ShipshapeClient, cfg Config) *Report {
+	concurrency := cfg.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	reqs := make(chan *rpcpb.ShipshapeRequest)
+	go func() {
+		r := rand.New(rand.NewSource(cfg.Seed))
+		for n := 0; n < cfg.Requests; n++ {
+			reqs <- request(cfg, r, n)
+		}
+		close(reqs)
+	}()
+
+	report := &Report{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for req := range reqs {
+				res := send(c, req)
+				mu.Lock()
+				report.Results = append(report.Results, res)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	report.Elapsed = time.Since(start)
+	return report
+}
+
+// request builds the nth request of a load test, analyzing a random choice of
+// cfg.Files files.
+func request(cfg Config, r *rand.Rand, n int) *rpcpb.ShipshapeRequest {
+	var files []string
+	if cfg.Files < len(cfg.Workspace) {
+		for _, i := range r.Perm(len(cfg.Workspace))[:cfg.Files] {
+			files = append(files, cfg.Workspace[i])
+		}
+		sort.Strings(files)
+	} else {
+		files = cfg.Workspace
+	}
+	return &rpcpb.ShipshapeRequest{
+		TriggeredCategory: cfg.Categories,
+		ShipshapeContext: &ctxpb.ShipshapeContext{
+			RepoRoot: proto.String(cfg.RepoRoot),
+			FilePath: files,
+		},
+		Event:     proto.String(cfg.Event),
+		Stage:     ctxpb.Stage_PRE_BUILD.Enum(),
+		RequestId: proto.String(fmt.Sprintf("loadtest-%d-%d", cfg.Seed, n)),
+	}
+}
+
+// send runs req and reads all of its results.
+func send(c client.ShipshapeClient, req *rpcpb.ShipshapeRequest) Result {
+	start := time.Now()
+	var res Result
+	rd := c.Run(req)
+	defer rd.Close()
+	for {
+		resp, err := rd.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			res.Err = err
+			break
+		}
+		for _, ar := range resp.AnalyzeResponse {
+			res.Notes += len(ar.Note)
+			res.AnalyzerFailures += len(ar.Failure)
+		}
+	}
+	res.Latency = time.Since(start)
+	return res
+}
+
+// Failures returns how many requests failed, and how many succeeded but had
+// analyzers that failed.
+func (r *Report) Failures() (requests, analyzers int) {
+	for _, res := range r.Results {
+		if res.Err != nil {
+			requests++
+		} else if res.AnalyzerFailures > 0 {
+			analyzers++
+		}
+	}
+	return requests, analyzers
+}
+
+// Percentile returns the latency that p percent of the successful requests
+// took at most, using the nearest rank. It returns 0 if no request succeeded.
+func (r *Report) Percentile(p float64) time.Duration {
+	var latencies []time.Duration
+	for _, res := range r.Results {
+		if res.Err == nil {
+			latencies = append(latencies, res.Latency)
+		}
+	}
+	if len(latencies) == 0 {
+		return 0
+	}
+	sort.Sort(byDuration(latencies))
+	rank := int(p/100*float64(len(latencies))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(latencies) {
+		rank = len(latencies) - 1
+	}
+	return latencies[rank]
+}
+
+// String summarizes the report for the terminal.
+func (r *Report) String() string {
+	requests, analyzers := r.Failures()
+	total := len(r.Results)
+	rate := func(n int) float64 {
+		if total == 0 {
+			return 0
+		}
+		return 100 * float64(n) / float64(total)
+	}
+	ms := func(d time.Duration) string { return fmt.Sprintf("%.1fms", d.Seconds()*1000) }
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%d requests in %s (%.1f/s)\n", total, ms(r.Elapsed), float64(total)/r.Elapsed.Seconds())
+	fmt.Fprintf(&b, "Failed requests: %d (%.1f%%)\n", requests, rate(requests))
+	fmt.Fprintf(&b, "Requests with failed analyzers: %d (%.1f%%)\n", analyzers, rate(analyzers))
+	for _, p := range []float64{50, 90, 95, 99, 100} {
+		fmt.Fprintf(&b, "p%-3v %s\n", p, ms(r.Percentile(p)))
+	}
+	return b.String()
+}
+
+type byDuration []time.Duration
+
+func (d byDuration) Len() int           { return len(d) }
+func (d byDuration) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d byDuration) Less(i, j int) bool { return d[i] < d[j] }
+
+// syntheticFiles are the contents of the generated workspace files, by
+// extension. Each has something for the usual analyzers to report, so that
+// the service has notes to stream back.
+var syntheticFiles = map[string]string{
+	".js": "var unused = 1\nfunction f(a) { return a == null }\n",
+	".py": "import os\ndef f(x):\n    return x\n",
+	".go": "package synthetic\n\nimport \"fmt\"\n\nfunc F() { fmt.Printf(\"%d\\n\", \"x\") }\n",
+}
+
+// GenerateWorkspace writes n synthetic source files to dir, cycling through
+// JavaScript, Python and Go, and returns their paths relative to dir.
+func GenerateWorkspace(dir string, n int) ([]string, error) {
+	exts := []string{".js", ".py", ".go"}
+	var files []string
+	for i := 0; i < n; i++ {
+		ext := exts[i%len(exts)]
+		name := filepath.Join(fmt.Sprintf("dir%d", i/100), fmt.Sprintf("file%d%s", i, ext))
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(path, []byte(syntheticFiles[ext]), 0644); err != nil {
+			return nil, err
+		}
+		files = append(files, name)
+	}
+	return files, nil
+}
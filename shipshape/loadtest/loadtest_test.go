@@ -0,0 +1,160 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package loadtest
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/shipshape/shipshape/util/rpc/client"
+	"github.com/google/shipshape/shipshape/util/rpc/server"
+	testutil "github.com/google/shipshape/shipshape/util/test"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// fakeService returns a note per file, fails every third request, and reports
+// an analyzer failure for requests of a single file.
+type fakeService struct {
+	mu       sync.Mutex
+	requests int
+	inFlight int
+	maxIn    int
+}
+
+func (s *fakeService) Run(ctx server.Context, in *rpcpb.ShipshapeRequest, out chan<- *rpcpb.ShipshapeResponse) error {
+	s.mu.Lock()
+	s.requests++
+	n := s.requests
+	s.inFlight++
+	if s.inFlight > s.maxIn {
+		s.maxIn = s.inFlight
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	if n%3 == 0 {
+		return errors.New("overloaded")
+	}
+	ar := &rpcpb.AnalyzeResponse{}
+	for range in.ShipshapeContext.FilePath {
+		ar.Note = append(ar.Note, &notepb.Note{Category: proto.String("Fake"), Description: proto.String("A note")})
+	}
+	if len(in.ShipshapeContext.FilePath) == 1 {
+		ar.Failure = append(ar.Failure, &rpcpb.AnalysisFailure{Category: proto.String("Fake"), FailureMessage: proto.String("boom")})
+	}
+	out <- &rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{ar}}
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	svc := &fakeService{}
+	addr, cleanup, err := testutil.CreatekRPCTestServer(svc, "ShipshapeService")
+	if err != nil {
+		t.Fatalf("Registering the fake service failed: %v", err)
+	}
+	defer cleanup()
+	c := client.ShipshapeClient{Client: client.NewHTTPClient(strings.TrimPrefix(addr, "http://"))}
+
+	cfg := Config{
+		Concurrency: 3,
+		Requests:    9,
+		Files:       2,
+		Workspace:   []string{"a.js", "b.py", "c.go", "d.js"},
+		RepoRoot:    "/shipshape-workspace",
+		Event:       "manual",
+	}
+	report := Run(c, cfg)
+	if got, want := len(report.Results), 9; got != want {
+		t.Fatalf("Wrong number of results: got %d, want %d", got, want)
+	}
+	requests, analyzers := report.Failures()
+	if requests != 3 || analyzers != 0 {
+		t.Errorf("Wrong failures: got %d failed requests and %d with failed analyzers, want 3 and 0", requests, analyzers)
+	}
+	for _, res := range report.Results {
+		if res.Err == nil && res.Notes != 2 {
+			t.Errorf("Wrong number of notes: got %d, want 2", res.Notes)
+		}
+	}
+	if svc.maxIn > 3 {
+		t.Errorf("Too many requests in flight: got %d, want at most 3", svc.maxIn)
+	}
+
+	cfg.Files = 1
+	cfg.Requests = 2
+	if _, analyzers := Run(c, cfg).Failures(); analyzers == 0 {
+		t.Errorf("Expected requests with failed analyzers")
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	r := &Report{}
+	for i := 1; i <= 10; i++ {
+		r.Results = append(r.Results, Result{Latency: time.Duration(i) * time.Second})
+	}
+	r.Results = append(r.Results, Result{Latency: time.Hour, Err: errors.New("failed")})
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{50, 5 * time.Second},
+		{90, 9 * time.Second},
+		{99, 10 * time.Second},
+		{100, 10 * time.Second},
+		{0, time.Second},
+	}
+	for _, test := range tests {
+		if got := r.Percentile(test.p); got != test.want {
+			t.Errorf("Percentile(%v): got %v, want %v", test.p, got, test.want)
+		}
+	}
+	if got := (&Report{}).Percentile(50); got != 0 {
+		t.Errorf("Percentile of no results: got %v, want 0", got)
+	}
+}
+
+func TestGenerateWorkspace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loadtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files, err := GenerateWorkspace(dir, 4)
+	if err != nil {
+		t.Fatalf("GenerateWorkspace failed: %v", err)
+	}
+	want := []string{"dir0/file0.js", "dir0/file1.py", "dir0/file2.go", "dir0/file3.js"}
+	if strings.Join(files, ",") != strings.Join(want, ",") {
+		t.Errorf("Wrong files: got %v, want %v", files, want)
+	}
+	if _, err := os.Stat(dir + "/dir0/file2.go"); err != nil {
+		t.Errorf("File was not written: %v", err)
+	}
+}
@@ -15,6 +15,8 @@ It has these top-level messages:
 	Replacement
 	FixRange
 	AdditionalData
+	Attribution
+	Symbol
 */
 package note_proto_go_src
 
@@ -74,6 +76,52 @@ func (x *Note_Severity) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// How much work fixing a note takes, for planning the cleanup of many
+// notes.
+type Note_Effort int32
+
+const (
+	// The analyzer did not say.
+	Note_UNKNOWN_EFFORT Note_Effort = 0
+	// A quick change by hand, e.g. removing an unused import.
+	Note_TRIVIAL Note_Effort = 1
+	// The note has a fix that can be applied as it is, e.g. with
+	// shipshape fix, or a formatter or similar tool fixes it.
+	Note_AUTO_FIXABLE Note_Effort = 2
+	// Fixing it takes thought, e.g. restructuring code or a design change.
+	Note_MANUAL Note_Effort = 3
+)
+
+var Note_Effort_name = map[int32]string{
+	0: "UNKNOWN_EFFORT",
+	1: "TRIVIAL",
+	2: "AUTO_FIXABLE",
+	3: "MANUAL",
+}
+var Note_Effort_value = map[string]int32{
+	"UNKNOWN_EFFORT": 0,
+	"TRIVIAL":        1,
+	"AUTO_FIXABLE":   2,
+	"MANUAL":         3,
+}
+
+func (x Note_Effort) Enum() *Note_Effort {
+	p := new(Note_Effort)
+	*p = x
+	return p
+}
+func (x Note_Effort) String() string {
+	return proto.EnumName(Note_Effort_name, int32(x))
+}
+func (x *Note_Effort) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(Note_Effort_value, data, "Note_Effort")
+	if err != nil {
+		return err
+	}
+	*x = Note_Effort(value)
+	return nil
+}
+
 // A Note is an arbitrary annotation, attached to a location in a
 // source file, an entire file, or a particular snapshot (e.g. associated with
 // either a code review or a revision).
@@ -105,8 +153,29 @@ type Note struct {
 	// Distinguishes between Notes representing build errors or other actionable
 	// problems, and informational Notes. Useful for UI because
 	// different annotations may need various levels of attention from the user.
-	Severity         *Note_Severity `protobuf:"varint,8,opt,name=severity,enum=shipshape_proto.Note_Severity,def=2" json:"severity,omitempty"`
-	XXX_unrecognized []byte         `json:"-"`
+	Severity *Note_Severity `protobuf:"varint,8,opt,name=severity,enum=shipshape_proto.Note_Severity,def=2" json:"severity,omitempty"`
+	// Who is responsible for the code this note is about. Not set by analyzers;
+	// filled in by clients that look up version control and ownership data.
+	Attribution *Attribution `protobuf:"bytes,9,opt,name=attribution" json:"attribution,omitempty"`
+	// Optional link to the documentation for the rule that produced this note,
+	// e.g. an explanation of the problem and how to fix it. Unlike more_info,
+	// which may point at the analyzer as a whole, this is specific to the
+	// category and subcategory of the note.
+	Url *string `protobuf:"bytes,10,opt,name=url" json:"url,omitempty"`
+	// Identifies the note across runs, however its line moves. Not set by
+	// analyzers; filled in by the CLI in the results it writes.
+	Fingerprint *string `protobuf:"bytes,11,opt,name=fingerprint" json:"fingerprint,omitempty"`
+	// The symbol the code the note is about refers to or defines. Not set by
+	// analyzers; filled in by the CLI from the cross-references of a kythe build.
+	Symbol *Symbol `protobuf:"bytes,12,opt,name=symbol" json:"symbol,omitempty"`
+	// How the note was suppressed: "baseline", "inline" or "override". Not set
+	// by analyzers; set by the CLI on the suppressed notes that it includes in
+	// the results with --show_suppressed.
+	SuppressedBy *string `protobuf:"bytes,13,opt,name=suppressed_by" json:"suppressed_by,omitempty"`
+	// How much work fixing this note takes. Set by analyzers that know;
+	// notes with a fix and no effort count as AUTO_FIXABLE.
+	Effort           *Note_Effort `protobuf:"varint,14,opt,name=effort,enum=shipshape_proto.Note_Effort" json:"effort,omitempty"`
+	XXX_unrecognized []byte       `json:"-"`
 }
 
 func (m *Note) Reset()         { *m = Note{} }
@@ -171,6 +240,48 @@ func (m *Note) GetSeverity() Note_Severity {
 	return Default_Note_Severity
 }
 
+func (m *Note) GetAttribution() *Attribution {
+	if m != nil {
+		return m.Attribution
+	}
+	return nil
+}
+
+func (m *Note) GetUrl() string {
+	if m != nil && m.Url != nil {
+		return *m.Url
+	}
+	return ""
+}
+
+func (m *Note) GetFingerprint() string {
+	if m != nil && m.Fingerprint != nil {
+		return *m.Fingerprint
+	}
+	return ""
+}
+
+func (m *Note) GetSymbol() *Symbol {
+	if m != nil {
+		return m.Symbol
+	}
+	return nil
+}
+
+func (m *Note) GetSuppressedBy() string {
+	if m != nil && m.SuppressedBy != nil {
+		return *m.SuppressedBy
+	}
+	return ""
+}
+
+func (m *Note) GetEffort() Note_Effort {
+	if m != nil && m.Effort != nil {
+		return *m.Effort
+	}
+	return Note_UNKNOWN_EFFORT
+}
+
 // A location within a specific file, a single file, or a snapshot.
 type Location struct {
 	// The context in which to interpret the path and the range, e.g. the
@@ -377,6 +488,73 @@ func (m *AdditionalData) GetGenerationTimestampMillis() uint64 {
 	return 0
 }
 
+// The people responsible for the code a note is about.
+type Attribution struct {
+	// The last author of the line the note starts on, as reported by the
+	// version control system, e.g. "Jane Doe <jane@example.com>".
+	Author *string `protobuf:"bytes,1,opt,name=author" json:"author,omitempty"`
+	// The owners of the file the note is in, e.g. from a CODEOWNERS file.
+	Owner            []string `protobuf:"bytes,2,rep,name=owner" json:"owner,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *Attribution) Reset()         { *m = Attribution{} }
+func (m *Attribution) String() string { return proto.CompactTextString(m) }
+func (*Attribution) ProtoMessage()    {}
+
+func (m *Attribution) GetAuthor() string {
+	if m != nil && m.Author != nil {
+		return *m.Author
+	}
+	return ""
+}
+
+func (m *Attribution) GetOwner() []string {
+	if m != nil {
+		return m.Owner
+	}
+	return nil
+}
+
+// A node of the kythe graph of the code, e.g. a class, method or variable.
+type Symbol struct {
+	// The kythe ticket of the node, e.g. "kythe://corpus?lang=java#signature",
+	// which tools can look its cross-references up with.
+	Ticket *string `protobuf:"bytes,1,opt,name=ticket" json:"ticket,omitempty"`
+	// The fully-qualified name of the symbol, e.g. "com.example.Foo.bar", if
+	// the index names it.
+	Name *string `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	// Where the symbol is defined, if it is in the analyzed code.
+	Definition       *Location `protobuf:"bytes,3,opt,name=definition" json:"definition,omitempty"`
+	XXX_unrecognized []byte    `json:"-"`
+}
+
+func (m *Symbol) Reset()         { *m = Symbol{} }
+func (m *Symbol) String() string { return proto.CompactTextString(m) }
+func (*Symbol) ProtoMessage()    {}
+
+func (m *Symbol) GetTicket() string {
+	if m != nil && m.Ticket != nil {
+		return *m.Ticket
+	}
+	return ""
+}
+
+func (m *Symbol) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *Symbol) GetDefinition() *Location {
+	if m != nil {
+		return m.Definition
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterEnum("shipshape_proto.Note_Severity", Note_Severity_name, Note_Severity_value)
+	proto.RegisterEnum("shipshape_proto.Note_Effort", Note_Effort_name, Note_Effort_value)
 }
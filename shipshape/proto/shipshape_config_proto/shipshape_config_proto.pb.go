@@ -10,7 +10,17 @@ It is generated from these files:
 
 It has these top-level messages:
 	GlobalConfig
+	AnalyzerEnvironment
+	AnalyzerSandbox
+	EnvironmentVariable
 	EventConfig
+	Policy
+	AnalyzerConfig
+	CategoryOverride
+	Pipeline
+	PipelineOutputs
+	CategoryOwner
+	Schedule
 	ShipshapeConfig
 */
 package shipshape_config_proto_go_src
@@ -34,14 +44,35 @@ type GlobalConfig struct {
 	// is a directory, relative to the repository root.
 	// TODO(collinwinter): add support for file=.gitignore syntax to avoid
 	// duplication between multiple systems.
-	Ignore           []string `protobuf:"bytes,2,rep,name=ignore" json:"ignore,omitempty"`
-	XXX_unrecognized []byte   `json:"-"`
+	Ignore []string `protobuf:"bytes,2,rep,name=ignore" json:"ignore,omitempty"`
+	// Environment variables to set in the containers of third-party analyzers,
+	// e.g. a license key for a commercial scanner.
+	Environment []*AnalyzerEnvironment `protobuf:"bytes,3,rep,name=environment" json:"environment,omitempty"`
+	// URL of a manifest of vetted third-party analyzers, which
+	// `shipshape analyzers search` and `shipshape analyzers add` use to find
+	// images to add to the `images` list.
+	Manifest *string `protobuf:"bytes,4,opt,name=manifest" json:"manifest,omitempty"`
+	// Custom events, in addition to the built-in ones (manual, presubmit,
+	// postsubmit, ci and editor), that the events section may configure and
+	// that the CLI accepts with --event.
+	Events []string `protobuf:"bytes,5,rep,name=events" json:"events,omitempty"`
+	// Security profiles for the containers of third-party analyzers.
+	Sandbox []*AnalyzerSandbox `protobuf:"bytes,6,rep,name=sandbox" json:"sandbox,omitempty"`
+	// Whether to "skip" generated files, e.g. those with a "DO NOT EDIT"
+	// header, protocol buffer code and minified JavaScript, or to "analyze"
+	// them like the others.
+	Generated *string `protobuf:"bytes,7,opt,name=generated,def=skip" json:"generated,omitempty"`
+	// The URL that `shipshape report-fp` posts reports of false positives to.
+	Feedback         *string `protobuf:"bytes,8,opt,name=feedback" json:"feedback,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
 }
 
 func (m *GlobalConfig) Reset()         { *m = GlobalConfig{} }
 func (m *GlobalConfig) String() string { return proto.CompactTextString(m) }
 func (*GlobalConfig) ProtoMessage()    {}
 
+const Default_GlobalConfig_Generated string = "skip"
+
 func (m *GlobalConfig) GetImages() []string {
 	if m != nil {
 		return m.Images
@@ -56,6 +87,169 @@ func (m *GlobalConfig) GetIgnore() []string {
 	return nil
 }
 
+func (m *GlobalConfig) GetEnvironment() []*AnalyzerEnvironment {
+	if m != nil {
+		return m.Environment
+	}
+	return nil
+}
+
+func (m *GlobalConfig) GetManifest() string {
+	if m != nil && m.Manifest != nil {
+		return *m.Manifest
+	}
+	return ""
+}
+
+func (m *GlobalConfig) GetEvents() []string {
+	if m != nil {
+		return m.Events
+	}
+	return nil
+}
+
+func (m *GlobalConfig) GetSandbox() []*AnalyzerSandbox {
+	if m != nil {
+		return m.Sandbox
+	}
+	return nil
+}
+
+func (m *GlobalConfig) GetGenerated() string {
+	if m != nil && m.Generated != nil {
+		return *m.Generated
+	}
+	return Default_GlobalConfig_Generated
+}
+
+func (m *GlobalConfig) GetFeedback() string {
+	if m != nil && m.Feedback != nil {
+		return *m.Feedback
+	}
+	return ""
+}
+
+// The environment of the container of one third-party analyzer image.
+type AnalyzerEnvironment struct {
+	// The image, exactly as it appears in the `images` list.
+	Image            *string                `protobuf:"bytes,1,opt,name=image" json:"image,omitempty"`
+	Variables        []*EnvironmentVariable `protobuf:"bytes,2,rep,name=variables" json:"variables,omitempty"`
+	XXX_unrecognized []byte                 `json:"-"`
+}
+
+func (m *AnalyzerEnvironment) Reset()         { *m = AnalyzerEnvironment{} }
+func (m *AnalyzerEnvironment) String() string { return proto.CompactTextString(m) }
+func (*AnalyzerEnvironment) ProtoMessage()    {}
+
+func (m *AnalyzerEnvironment) GetImage() string {
+	if m != nil && m.Image != nil {
+		return *m.Image
+	}
+	return ""
+}
+
+func (m *AnalyzerEnvironment) GetVariables() []*EnvironmentVariable {
+	if m != nil {
+		return m.Variables
+	}
+	return nil
+}
+
+// The security profile of the container of one third-party analyzer image.
+type AnalyzerSandbox struct {
+	// The image, exactly as it appears in the `images` list.
+	Image *string `protobuf:"bytes,1,opt,name=image" json:"image,omitempty"`
+	// Run the analyzer on an internal network, so that it can reach nothing but
+	// the shipshape service.
+	Offline *bool `protobuf:"varint,2,opt,name=offline" json:"offline,omitempty"`
+	// Mount the root filesystem of the container read-only. /tmp is still
+	// writable.
+	Readonly *bool `protobuf:"varint,3,opt,name=readonly" json:"readonly,omitempty"`
+	// Linux capabilities to drop, e.g. NET_RAW, or ALL.
+	Drop []string `protobuf:"bytes,4,rep,name=drop" json:"drop,omitempty"`
+	// Path of a seccomp profile for the container, relative to the directory
+	// containing this file.
+	Seccomp          *string `protobuf:"bytes,5,opt,name=seccomp" json:"seccomp,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *AnalyzerSandbox) Reset()         { *m = AnalyzerSandbox{} }
+func (m *AnalyzerSandbox) String() string { return proto.CompactTextString(m) }
+func (*AnalyzerSandbox) ProtoMessage()    {}
+
+func (m *AnalyzerSandbox) GetImage() string {
+	if m != nil && m.Image != nil {
+		return *m.Image
+	}
+	return ""
+}
+
+func (m *AnalyzerSandbox) GetOffline() bool {
+	if m != nil && m.Offline != nil {
+		return *m.Offline
+	}
+	return false
+}
+
+func (m *AnalyzerSandbox) GetReadonly() bool {
+	if m != nil && m.Readonly != nil {
+		return *m.Readonly
+	}
+	return false
+}
+
+func (m *AnalyzerSandbox) GetDrop() []string {
+	if m != nil {
+		return m.Drop
+	}
+	return nil
+}
+
+func (m *AnalyzerSandbox) GetSeccomp() string {
+	if m != nil && m.Seccomp != nil {
+		return *m.Seccomp
+	}
+	return ""
+}
+
+// A variable to set in an analyzer container. Exactly one of value or secret
+// must be set.
+type EnvironmentVariable struct {
+	Name *string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// The value of the variable.
+	Value *string `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+	// The name of a variable in the environment of the shipshape CLI to take
+	// the value from, so that secrets need not be checked in. The value is not
+	// logged.
+	Secret           *string `protobuf:"bytes,3,opt,name=secret" json:"secret,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *EnvironmentVariable) Reset()         { *m = EnvironmentVariable{} }
+func (m *EnvironmentVariable) String() string { return proto.CompactTextString(m) }
+func (*EnvironmentVariable) ProtoMessage()    {}
+
+func (m *EnvironmentVariable) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *EnvironmentVariable) GetValue() string {
+	if m != nil && m.Value != nil {
+		return *m.Value
+	}
+	return ""
+}
+
+func (m *EnvironmentVariable) GetSecret() string {
+	if m != nil && m.Secret != nil {
+		return *m.Secret
+	}
+	return ""
+}
+
 type EventConfig struct {
 	// Defines points in a development workflow when one may want to run analyses
 	// Pre-defined values used by Leeroy might include "Commit", "Review", and "Deploy".
@@ -90,11 +284,481 @@ func (m *EventConfig) GetCategories() []string {
 	return nil
 }
 
+// A Policy gates a run on its results. Each policy selects a set of notes;
+// the run fails if any policy selects more notes than it allows.
+type Policy struct {
+	// A name for the policy, shown when the policy is violated.
+	Name *string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// The categories the policy applies to. If empty, applies to all categories.
+	Categories []string `protobuf:"bytes,2,rep,name=categories" json:"categories,omitempty"`
+	// If set, only notes with this severity (BUILD_ERROR, WARNING, or OTHER)
+	// are selected.
+	Severity *string `protobuf:"bytes,3,opt,name=severity" json:"severity,omitempty"`
+	// If non-empty, only notes with a path matching one of these patterns are
+	// selected. A pattern ending in "/" matches everything under that directory;
+	// any other pattern is a glob matched against both the full path and the
+	// file name (e.g. "*.java", "src/main/*"). A Bazel-style pattern such as
+	// "//java/..." is the same as "java/".
+	Paths []string `protobuf:"bytes,4,rep,name=paths" json:"paths,omitempty"`
+	// Notes with a path matching one of these patterns are not selected, even
+	// if they match paths. Uses the same syntax as paths (e.g. "*Test.java").
+	Exclude []string `protobuf:"bytes,5,rep,name=exclude" json:"exclude,omitempty"`
+	// The number of selected notes that are tolerated before the policy fails.
+	Allowed          *int32 `protobuf:"varint,6,opt,name=allowed,def=0" json:"allowed,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *Policy) Reset()         { *m = Policy{} }
+func (m *Policy) String() string { return proto.CompactTextString(m) }
+func (*Policy) ProtoMessage()    {}
+
+const Default_Policy_Allowed int32 = 0
+
+func (m *Policy) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *Policy) GetCategories() []string {
+	if m != nil {
+		return m.Categories
+	}
+	return nil
+}
+
+func (m *Policy) GetSeverity() string {
+	if m != nil && m.Severity != nil {
+		return *m.Severity
+	}
+	return ""
+}
+
+func (m *Policy) GetPaths() []string {
+	if m != nil {
+		return m.Paths
+	}
+	return nil
+}
+
+func (m *Policy) GetExclude() []string {
+	if m != nil {
+		return m.Exclude
+	}
+	return nil
+}
+
+func (m *Policy) GetAllowed() int32 {
+	if m != nil && m.Allowed != nil {
+		return *m.Allowed
+	}
+	return Default_Policy_Allowed
+}
+
+// Settings for the analyzer of a single category.
+type AnalyzerConfig struct {
+	// The category these settings apply to.
+	Category *string `protobuf:"bytes,1,opt,name=category" json:"category,omitempty"`
+	// What a failure of the analyzer means for the run: "required" fails the
+	// run, while "best-effort" only reports the failure as a warning.
+	Failure *string `protobuf:"bytes,2,opt,name=failure,def=best-effort" json:"failure,omitempty"`
+	// How many times to analyze the category again after the analyzer fails.
+	// Many failures are transient, e.g. an analyzer that was not ready yet.
+	Retries *int32 `protobuf:"varint,3,opt,name=retries,def=1" json:"retries,omitempty"`
+	// When a run has a time budget, categories with a higher priority are run
+	// first, so that the most important results are found before time is up.
+	Priority *int32 `protobuf:"varint,4,opt,name=priority,def=0" json:"priority,omitempty"`
+	// How expensive the analyzer is: "fast" or "slow". The CLI can run only
+	// the categories of one tier, e.g. only fast ones in a pre-commit hook.
+	Tier *string `protobuf:"bytes,5,opt,name=tier" json:"tier,omitempty"`
+	// If non-empty, the analyzer only runs on files matching one of these
+	// patterns, which use the same syntax as the paths of a policy (e.g.
+	// "//java/..." or "*.java").
+	Paths []string `protobuf:"bytes,6,rep,name=paths" json:"paths,omitempty"`
+	// The analyzer never runs on files matching one of these patterns, even if
+	// they match paths (e.g. "third_party/").
+	Exclude []string `protobuf:"bytes,7,rep,name=exclude" json:"exclude,omitempty"`
+	// The build system, e.g. maven or go, that generates the compilation units
+	// that the analyzer needs in the POST_BUILD stage. When the category is
+	// triggered, the compilation units are extracted with it and POST_BUILD is
+	// analyzed after PRE_BUILD, as if the build system had been passed to the
+	// CLI with --build.
+	Build            *string `protobuf:"bytes,8,opt,name=build" json:"build,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *AnalyzerConfig) Reset()         { *m = AnalyzerConfig{} }
+func (m *AnalyzerConfig) String() string { return proto.CompactTextString(m) }
+func (*AnalyzerConfig) ProtoMessage()    {}
+
+const Default_AnalyzerConfig_Failure string = "best-effort"
+const Default_AnalyzerConfig_Retries int32 = 1
+const Default_AnalyzerConfig_Priority int32 = 0
+
+func (m *AnalyzerConfig) GetCategory() string {
+	if m != nil && m.Category != nil {
+		return *m.Category
+	}
+	return ""
+}
+
+func (m *AnalyzerConfig) GetFailure() string {
+	if m != nil && m.Failure != nil {
+		return *m.Failure
+	}
+	return Default_AnalyzerConfig_Failure
+}
+
+func (m *AnalyzerConfig) GetRetries() int32 {
+	if m != nil && m.Retries != nil {
+		return *m.Retries
+	}
+	return Default_AnalyzerConfig_Retries
+}
+
+func (m *AnalyzerConfig) GetPriority() int32 {
+	if m != nil && m.Priority != nil {
+		return *m.Priority
+	}
+	return Default_AnalyzerConfig_Priority
+}
+
+func (m *AnalyzerConfig) GetTier() string {
+	if m != nil && m.Tier != nil {
+		return *m.Tier
+	}
+	return ""
+}
+
+func (m *AnalyzerConfig) GetPaths() []string {
+	if m != nil {
+		return m.Paths
+	}
+	return nil
+}
+
+func (m *AnalyzerConfig) GetExclude() []string {
+	if m != nil {
+		return m.Exclude
+	}
+	return nil
+}
+
+func (m *AnalyzerConfig) GetBuild() string {
+	if m != nil && m.Build != nil {
+		return *m.Build
+	}
+	return ""
+}
+
+// Changes to the notes of a category, or of one subcategory of it, that are
+// made before the results are checked against the policies.
+type CategoryOverride struct {
+	Category *string `protobuf:"bytes,1,opt,name=category" json:"category,omitempty"`
+	// If set, only notes with this subcategory are changed.
+	Subcategory *string `protobuf:"bytes,2,opt,name=subcategory" json:"subcategory,omitempty"`
+	// If set, the notes are given this severity (BUILD_ERROR, WARNING, or
+	// OTHER).
+	Severity *string `protobuf:"bytes,3,opt,name=severity" json:"severity,omitempty"`
+	// If set, the notes are dropped from the results.
+	Suppress *bool `protobuf:"varint,4,opt,name=suppress,def=0" json:"suppress,omitempty"`
+	// If set, the notes are reported under this category instead.
+	Rename           *string `protobuf:"bytes,5,opt,name=rename" json:"rename,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *CategoryOverride) Reset()         { *m = CategoryOverride{} }
+func (m *CategoryOverride) String() string { return proto.CompactTextString(m) }
+func (*CategoryOverride) ProtoMessage()    {}
+
+const Default_CategoryOverride_Suppress bool = false
+
+func (m *CategoryOverride) GetCategory() string {
+	if m != nil && m.Category != nil {
+		return *m.Category
+	}
+	return ""
+}
+
+func (m *CategoryOverride) GetSubcategory() string {
+	if m != nil && m.Subcategory != nil {
+		return *m.Subcategory
+	}
+	return ""
+}
+
+func (m *CategoryOverride) GetSeverity() string {
+	if m != nil && m.Severity != nil {
+		return *m.Severity
+	}
+	return ""
+}
+
+func (m *CategoryOverride) GetSuppress() bool {
+	if m != nil && m.Suppress != nil {
+		return *m.Suppress
+	}
+	return Default_CategoryOverride_Suppress
+}
+
+func (m *CategoryOverride) GetRename() string {
+	if m != nil && m.Rename != nil {
+		return *m.Rename
+	}
+	return ""
+}
+
+// A named set of settings for a run, such as the check run in an editor or
+// a nightly deep scan, which `shipshape run <name>` runs. Flags given on the
+// command line take precedence over the settings of the pipeline.
+type Pipeline struct {
+	Name *string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// The event whose categories are run, unless categories is set.
+	Event      *string  `protobuf:"bytes,2,opt,name=event" json:"event,omitempty"`
+	Categories []string `protobuf:"bytes,3,rep,name=categories" json:"categories,omitempty"`
+	// The stages to run, in order, e.g. PRE_BUILD, POST_BUILD and SECURITY.
+	// By default, PRE_BUILD is run, followed by POST_BUILD if build is set.
+	Stages []string `protobuf:"bytes,4,rep,name=stages" json:"stages,omitempty"`
+	// The build system that generates the compilation units for the
+	// POST_BUILD stage: maven or go.
+	Build *string `protobuf:"bytes,5,opt,name=build" json:"build,omitempty"`
+	// If set, only the categories of this tier are run: "fast" or "slow".
+	Tier             *string          `protobuf:"bytes,6,opt,name=tier" json:"tier,omitempty"`
+	Outputs          *PipelineOutputs `protobuf:"bytes,7,opt,name=outputs" json:"outputs,omitempty"`
+	XXX_unrecognized []byte           `json:"-"`
+}
+
+func (m *Pipeline) Reset()         { *m = Pipeline{} }
+func (m *Pipeline) String() string { return proto.CompactTextString(m) }
+func (*Pipeline) ProtoMessage()    {}
+
+func (m *Pipeline) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *Pipeline) GetEvent() string {
+	if m != nil && m.Event != nil {
+		return *m.Event
+	}
+	return ""
+}
+
+func (m *Pipeline) GetCategories() []string {
+	if m != nil {
+		return m.Categories
+	}
+	return nil
+}
+
+func (m *Pipeline) GetStages() []string {
+	if m != nil {
+		return m.Stages
+	}
+	return nil
+}
+
+func (m *Pipeline) GetBuild() string {
+	if m != nil && m.Build != nil {
+		return *m.Build
+	}
+	return ""
+}
+
+func (m *Pipeline) GetTier() string {
+	if m != nil && m.Tier != nil {
+		return *m.Tier
+	}
+	return ""
+}
+
+func (m *Pipeline) GetOutputs() *PipelineOutputs {
+	if m != nil {
+		return m.Outputs
+	}
+	return nil
+}
+
+// Where the results of a pipeline go, in addition to the notes printed by
+// the CLI.
+type PipelineOutputs struct {
+	// How the notes are printed: text, csv or tsv.
+	Format *string `protobuf:"bytes,1,opt,name=format" json:"format,omitempty"`
+	// A file to write the results to as JSON.
+	Json *string `protobuf:"bytes,2,opt,name=json" json:"json,omitempty"`
+	// A file to write the coverage report to.
+	Coverage *string `protobuf:"bytes,3,opt,name=coverage" json:"coverage,omitempty"`
+	// The results history database to record the run in.
+	History *string `protobuf:"bytes,4,opt,name=history" json:"history,omitempty"`
+	// The cloud storage location to upload the results to.
+	Upload *string `protobuf:"bytes,5,opt,name=upload" json:"upload,omitempty"`
+	// A webhook to post a summary of the results to.
+	Webhook          *string `protobuf:"bytes,6,opt,name=webhook" json:"webhook,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *PipelineOutputs) Reset()         { *m = PipelineOutputs{} }
+func (m *PipelineOutputs) String() string { return proto.CompactTextString(m) }
+func (*PipelineOutputs) ProtoMessage()    {}
+
+func (m *PipelineOutputs) GetFormat() string {
+	if m != nil && m.Format != nil {
+		return *m.Format
+	}
+	return ""
+}
+
+func (m *PipelineOutputs) GetJson() string {
+	if m != nil && m.Json != nil {
+		return *m.Json
+	}
+	return ""
+}
+
+func (m *PipelineOutputs) GetCoverage() string {
+	if m != nil && m.Coverage != nil {
+		return *m.Coverage
+	}
+	return ""
+}
+
+func (m *PipelineOutputs) GetHistory() string {
+	if m != nil && m.History != nil {
+		return *m.History
+	}
+	return ""
+}
+
+func (m *PipelineOutputs) GetUpload() string {
+	if m != nil && m.Upload != nil {
+		return *m.Upload
+	}
+	return ""
+}
+
+func (m *PipelineOutputs) GetWebhook() string {
+	if m != nil && m.Webhook != nil {
+		return *m.Webhook
+	}
+	return ""
+}
+
+// The team that maintains the analyzer of a category, for developers to ask
+// about its notes, e.g. ones they think are false positives.
+type CategoryOwner struct {
+	Category *string `protobuf:"bytes,1,opt,name=category" json:"category,omitempty"`
+	// The name of the team, e.g. java-infra.
+	Team *string `protobuf:"bytes,2,opt,name=team" json:"team,omitempty"`
+	// How to reach the team, e.g. java-infra@example.com.
+	Contact *string `protobuf:"bytes,3,opt,name=contact" json:"contact,omitempty"`
+	// Where to report false positives, e.g. an issue tracker component.
+	Url              *string `protobuf:"bytes,4,opt,name=url" json:"url,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *CategoryOwner) Reset()         { *m = CategoryOwner{} }
+func (m *CategoryOwner) String() string { return proto.CompactTextString(m) }
+func (*CategoryOwner) ProtoMessage()    {}
+
+func (m *CategoryOwner) GetCategory() string {
+	if m != nil && m.Category != nil {
+		return *m.Category
+	}
+	return ""
+}
+
+func (m *CategoryOwner) GetTeam() string {
+	if m != nil && m.Team != nil {
+		return *m.Team
+	}
+	return ""
+}
+
+func (m *CategoryOwner) GetContact() string {
+	if m != nil && m.Contact != nil {
+		return *m.Contact
+	}
+	return ""
+}
+
+func (m *CategoryOwner) GetUrl() string {
+	if m != nil && m.Url != nil {
+		return *m.Url
+	}
+	return ""
+}
+
+// A pipeline that `shipshape serve-webhooks --manifest` runs at set times,
+// such as a nightly scan of the main branch.
+type Schedule struct {
+	Name *string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// When to run, as a cron expression in the local time of the server:
+	// minute, hour, day of the month, month and day of the week, e.g.
+	// "0 2 * * *" for 02:00 every day, or @hourly, @daily or @weekly.
+	Cron *string `protobuf:"bytes,2,opt,name=cron" json:"cron,omitempty"`
+	// The pipeline to run.
+	Pipeline *string `protobuf:"bytes,3,opt,name=pipeline" json:"pipeline,omitempty"`
+	// The branch to analyze. By default, the branch the repository is
+	// listed with, or else its default branch.
+	Branch           *string `protobuf:"bytes,4,opt,name=branch" json:"branch,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Schedule) Reset()         { *m = Schedule{} }
+func (m *Schedule) String() string { return proto.CompactTextString(m) }
+func (*Schedule) ProtoMessage()    {}
+
+func (m *Schedule) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *Schedule) GetCron() string {
+	if m != nil && m.Cron != nil {
+		return *m.Cron
+	}
+	return ""
+}
+
+func (m *Schedule) GetPipeline() string {
+	if m != nil && m.Pipeline != nil {
+		return *m.Pipeline
+	}
+	return ""
+}
+
+func (m *Schedule) GetBranch() string {
+	if m != nil && m.Branch != nil {
+		return *m.Branch
+	}
+	return ""
+}
+
 // Represents the Shipshape configuration.
 type ShipshapeConfig struct {
-	Global           *GlobalConfig  `protobuf:"bytes,1,opt,name=global" json:"global,omitempty"`
-	Events           []*EventConfig `protobuf:"bytes,2,rep,name=events" json:"events,omitempty"`
-	XXX_unrecognized []byte         `json:"-"`
+	Global *GlobalConfig  `protobuf:"bytes,1,opt,name=global" json:"global,omitempty"`
+	Events []*EventConfig `protobuf:"bytes,2,rep,name=events" json:"events,omitempty"`
+	// Policies to check the results of a run against. If there are any
+	// policies, they decide the exit status of the CLI instead of the
+	// number of notes.
+	Policies []*Policy `protobuf:"bytes,3,rep,name=policies" json:"policies,omitempty"`
+	// Per-category analyzer settings.
+	Analyzers []*AnalyzerConfig `protobuf:"bytes,4,rep,name=analyzers" json:"analyzers,omitempty"`
+	// Changes to the severities and categories of notes. An override for a
+	// subcategory takes precedence over one for the whole category.
+	Overrides []*CategoryOverride `protobuf:"bytes,5,rep,name=overrides" json:"overrides,omitempty"`
+	// Named pipelines, which `shipshape run <name>` runs.
+	Pipelines []*Pipeline `protobuf:"bytes,6,rep,name=pipelines" json:"pipelines,omitempty"`
+	// The teams that maintain the analyzers of categories.
+	Owners []*CategoryOwner `protobuf:"bytes,7,rep,name=owners" json:"owners,omitempty"`
+	// Pipelines to run at set times.
+	Schedules        []*Schedule `protobuf:"bytes,8,rep,name=schedules" json:"schedules,omitempty"`
+	XXX_unrecognized []byte      `json:"-"`
 }
 
 func (m *ShipshapeConfig) Reset()         { *m = ShipshapeConfig{} }
@@ -115,5 +779,47 @@ func (m *ShipshapeConfig) GetEvents() []*EventConfig {
 	return nil
 }
 
+func (m *ShipshapeConfig) GetPolicies() []*Policy {
+	if m != nil {
+		return m.Policies
+	}
+	return nil
+}
+
+func (m *ShipshapeConfig) GetAnalyzers() []*AnalyzerConfig {
+	if m != nil {
+		return m.Analyzers
+	}
+	return nil
+}
+
+func (m *ShipshapeConfig) GetOverrides() []*CategoryOverride {
+	if m != nil {
+		return m.Overrides
+	}
+	return nil
+}
+
+func (m *ShipshapeConfig) GetPipelines() []*Pipeline {
+	if m != nil {
+		return m.Pipelines
+	}
+	return nil
+}
+
+func (m *ShipshapeConfig) GetOwners() []*CategoryOwner {
+	if m != nil {
+		return m.Owners
+	}
+	return nil
+}
+
+func (m *ShipshapeConfig) GetSchedules() []*Schedule {
+	if m != nil {
+		return m.Schedules
+	}
+	return nil
+}
+
 func init() {
 }
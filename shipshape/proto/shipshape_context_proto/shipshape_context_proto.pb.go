@@ -11,6 +11,8 @@ It is generated from these files:
 It has these top-level messages:
 	ShipshapeContext
 	ChangelistDetails
+	RevisionDetails
+	CiDetails
 	CompilationDetails
 */
 package shipshape_context_proto_go_src
@@ -24,6 +26,7 @@ var _ = proto.Marshal
 var _ = math.Inf
 
 // Indicates what stage of Shipshape to plug in to or Shipshape is running in.
+// Stages after POST_BUILD are only run when they are asked for.
 type Stage int32
 
 const (
@@ -32,15 +35,28 @@ const (
 	// guaranteed to be set: compilation_unit.
 	// optionally set: changelist_details.
 	Stage_POST_BUILD Stage = 2
+	// Analysis of the dependencies of the code, e.g. for known vulnerabilities.
+	Stage_DEPENDENCIES Stage = 3
+	// Security analysis of the code.
+	Stage_SECURITY Stage = 4
+	// Analysis of deployment configuration, e.g. Dockerfiles and Kubernetes
+	// manifests.
+	Stage_DEPLOYMENT_CONFIG Stage = 5
 )
 
 var Stage_name = map[int32]string{
 	1: "PRE_BUILD",
 	2: "POST_BUILD",
+	3: "DEPENDENCIES",
+	4: "SECURITY",
+	5: "DEPLOYMENT_CONFIG",
 }
 var Stage_value = map[string]int32{
-	"PRE_BUILD":  1,
-	"POST_BUILD": 2,
+	"PRE_BUILD":         1,
+	"POST_BUILD":        2,
+	"DEPENDENCIES":      3,
+	"SECURITY":          4,
+	"DEPLOYMENT_CONFIG": 5,
 }
 
 func (x Stage) Enum() *Stage {
@@ -121,7 +137,12 @@ type ShipshapeContext struct {
 	ChangelistDetails *ChangelistDetails `protobuf:"bytes,5,opt,name=changelist_details" json:"changelist_details,omitempty"`
 	// TODO(supertri): Do we need dependency details, or build details?
 	CompilationDetails *CompilationDetails `protobuf:"bytes,8,opt,name=compilation_details" json:"compilation_details,omitempty"`
-	XXX_unrecognized   []byte              `json:"-"`
+	// TODO(supertri): Do we need locations of services?
+	// The revision of the code being analyzed, if it is in version control.
+	RevisionDetails *RevisionDetails `protobuf:"bytes,9,opt,name=revision_details" json:"revision_details,omitempty"`
+	// Set when analysis is run by a continuous integration system.
+	CiDetails        *CiDetails `protobuf:"bytes,10,opt,name=ci_details" json:"ci_details,omitempty"`
+	XXX_unrecognized []byte     `json:"-"`
 }
 
 func (m *ShipshapeContext) Reset()         { *m = ShipshapeContext{} }
@@ -170,6 +191,20 @@ func (m *ShipshapeContext) GetCompilationDetails() *CompilationDetails {
 	return nil
 }
 
+func (m *ShipshapeContext) GetRevisionDetails() *RevisionDetails {
+	if m != nil {
+		return m.RevisionDetails
+	}
+	return nil
+}
+
+func (m *ShipshapeContext) GetCiDetails() *CiDetails {
+	if m != nil {
+		return m.CiDetails
+	}
+	return nil
+}
+
 // Provides data describing a changelist, including code review related
 // information.
 type ChangelistDetails struct {
@@ -238,6 +273,75 @@ func (m *ChangelistDetails) GetClientName() string {
 	return ""
 }
 
+// Describes the version control revision of the code being analyzed.
+type RevisionDetails struct {
+	// Commit SHA.
+	Commit *string `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
+	Branch *string `protobuf:"bytes,2,opt,name=branch" json:"branch,omitempty"`
+	// URL of the remote repository, without any credentials.
+	RemoteUrl        *string `protobuf:"bytes,3,opt,name=remote_url" json:"remote_url,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *RevisionDetails) Reset()         { *m = RevisionDetails{} }
+func (m *RevisionDetails) String() string { return proto.CompactTextString(m) }
+func (*RevisionDetails) ProtoMessage()    {}
+
+func (m *RevisionDetails) GetCommit() string {
+	if m != nil && m.Commit != nil {
+		return *m.Commit
+	}
+	return ""
+}
+
+func (m *RevisionDetails) GetBranch() string {
+	if m != nil && m.Branch != nil {
+		return *m.Branch
+	}
+	return ""
+}
+
+func (m *RevisionDetails) GetRemoteUrl() string {
+	if m != nil && m.RemoteUrl != nil {
+		return *m.RemoteUrl
+	}
+	return ""
+}
+
+// Describes the continuous integration build that is running the analysis.
+type CiDetails struct {
+	// Name of the CI system, e.g. "travis" or "jenkins".
+	System           *string `protobuf:"bytes,1,opt,name=system" json:"system,omitempty"`
+	BuildId          *string `protobuf:"bytes,2,opt,name=build_id" json:"build_id,omitempty"`
+	BuildUrl         *string `protobuf:"bytes,3,opt,name=build_url" json:"build_url,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *CiDetails) Reset()         { *m = CiDetails{} }
+func (m *CiDetails) String() string { return proto.CompactTextString(m) }
+func (*CiDetails) ProtoMessage()    {}
+
+func (m *CiDetails) GetSystem() string {
+	if m != nil && m.System != nil {
+		return *m.System
+	}
+	return ""
+}
+
+func (m *CiDetails) GetBuildId() string {
+	if m != nil && m.BuildId != nil {
+		return *m.BuildId
+	}
+	return ""
+}
+
+func (m *CiDetails) GetBuildUrl() string {
+	if m != nil && m.BuildUrl != nil {
+		return *m.BuildUrl
+	}
+	return ""
+}
+
 // Provides information about a specific compilation
 type CompilationDetails struct {
 	// Set when running compiler based analysis; compilation_details describes
@@ -16,14 +16,28 @@ It has these top-level messages:
 	AnalyzeRequest
 	AnalysisFailure
 	AnalyzeResponse
+	AnalysisRetry
+	AnalysisCoverage
+	AnalysisRestart
+	GetDocumentationRequest
+	Documentation
+	GetDocumentationResponse
 	ShipshapeRequest
 	ShipshapeResponse
+	SuppressionCounts
+	RunInfo
+	ImageInfo
+	AnalysisProgress
+	IncompleteResults
+	CancelRequest
+	CancelResponse
 */
 package shipshape_rpc_proto_go_src
 
 import proto "github.com/golang/protobuf/proto"
 import math "math"
 import shipshape_proto1 "github.com/google/shipshape/shipshape/proto/note_proto"
+import shipshape_proto3 "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
 import shipshape_proto2 "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -86,6 +100,20 @@ func (m *GetStageResponse) GetStage() shipshape_proto2.Stage {
 	return shipshape_proto2.Stage_PRE_BUILD
 }
 
+func (m *ShipshapeRequest) GetTimeBudgetMs() int64 {
+	if m != nil && m.TimeBudgetMs != nil {
+		return *m.TimeBudgetMs
+	}
+	return 0
+}
+
+func (m *ShipshapeRequest) GetAnalyzerParallelism() int32 {
+	if m != nil && m.AnalyzerParallelism != nil {
+		return *m.AnalyzerParallelism
+	}
+	return 0
+}
+
 // Provides information to an analyzer to perform its analysis.
 type AnalyzeRequest struct {
 	ShipshapeContext *shipshape_proto2.ShipshapeContext `protobuf:"bytes,1,opt,name=shipshape_context" json:"shipshape_context,omitempty"`
@@ -141,8 +169,23 @@ func (m *AnalysisFailure) GetFailureMessage() string {
 // If the analyzer fails, return a failure_message. Analyzers may also
 // return partial results (only a subset of the notes) in this case.
 type AnalyzeResponse struct {
-	Note             []*shipshape_proto1.Note `protobuf:"bytes,1,rep,name=note" json:"note,omitempty"`
-	Failure          []*AnalysisFailure       `protobuf:"bytes,2,rep,name=failure" json:"failure,omitempty"`
+	Note    []*shipshape_proto1.Note `protobuf:"bytes,1,rep,name=note" json:"note,omitempty"`
+	Failure []*AnalysisFailure       `protobuf:"bytes,2,rep,name=failure" json:"failure,omitempty"`
+	// Categories that were analyzed again after failing. If the category still
+	// failed on its last attempt, there is also a failure for it.
+	Retry []*AnalysisRetry `protobuf:"bytes,3,rep,name=retry" json:"retry,omitempty"`
+	// The files that each category processed. Categories whose analyzers do not
+	// say which files they process have no coverage.
+	Coverage []*AnalysisCoverage `protobuf:"bytes,4,rep,name=coverage" json:"coverage,omitempty"`
+	// Categories whose analyzer stopped responding and was restarted, after
+	// which they were analyzed again. If restarting the analyzer or analyzing
+	// again failed, there is also a failure for the category.
+	Restart []*AnalysisRestart `protobuf:"bytes,5,rep,name=restart" json:"restart,omitempty"`
+	// The stage that the analysis ran in.
+	Stage *shipshape_proto2.Stage `protobuf:"varint,6,opt,name=stage,enum=shipshape_proto.Stage" json:"stage,omitempty"`
+	// The notes that were suppressed, each with its suppressed_by set. Set
+	// only on the results written by the CLI with --show_suppressed.
+	SuppressedNote   []*shipshape_proto1.Note `protobuf:"bytes,7,rep,name=suppressed_note" json:"suppressed_note,omitempty"`
 	XXX_unrecognized []byte                   `json:"-"`
 }
 
@@ -164,6 +207,232 @@ func (m *AnalyzeResponse) GetFailure() []*AnalysisFailure {
 	return nil
 }
 
+func (m *AnalyzeResponse) GetRetry() []*AnalysisRetry {
+	if m != nil {
+		return m.Retry
+	}
+	return nil
+}
+
+func (m *AnalyzeResponse) GetCoverage() []*AnalysisCoverage {
+	if m != nil {
+		return m.Coverage
+	}
+	return nil
+}
+
+func (m *AnalyzeResponse) GetRestart() []*AnalysisRestart {
+	if m != nil {
+		return m.Restart
+	}
+	return nil
+}
+
+func (m *AnalyzeResponse) GetStage() shipshape_proto2.Stage {
+	if m != nil && m.Stage != nil {
+		return *m.Stage
+	}
+	return shipshape_proto2.Stage_PRE_BUILD
+}
+
+func (m *AnalyzeResponse) GetSuppressedNote() []*shipshape_proto1.Note {
+	if m != nil {
+		return m.SuppressedNote
+	}
+	return nil
+}
+
+// Records that a failed category was retried.
+type AnalysisRetry struct {
+	Category *string `protobuf:"bytes,1,opt,name=category" json:"category,omitempty"`
+	// The number of times the category was analyzed, including the first.
+	Attempts         *int32 `protobuf:"varint,2,opt,name=attempts" json:"attempts,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *AnalysisRetry) Reset()         { *m = AnalysisRetry{} }
+func (m *AnalysisRetry) String() string { return proto.CompactTextString(m) }
+func (*AnalysisRetry) ProtoMessage()    {}
+
+func (m *AnalysisRetry) GetCategory() string {
+	if m != nil && m.Category != nil {
+		return *m.Category
+	}
+	return ""
+}
+
+func (m *AnalysisRetry) GetAttempts() int32 {
+	if m != nil && m.Attempts != nil {
+		return *m.Attempts
+	}
+	return 0
+}
+
+// Records the files that a category processed, so that files that were
+// skipped by every category can be found.
+type AnalysisCoverage struct {
+	Category *string `protobuf:"bytes,1,opt,name=category" json:"category,omitempty"`
+	// Paths relative to the repo root.
+	File             []string `protobuf:"bytes,2,rep,name=file" json:"file,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *AnalysisCoverage) Reset()         { *m = AnalysisCoverage{} }
+func (m *AnalysisCoverage) String() string { return proto.CompactTextString(m) }
+func (*AnalysisCoverage) ProtoMessage()    {}
+
+func (m *AnalysisCoverage) GetCategory() string {
+	if m != nil && m.Category != nil {
+		return *m.Category
+	}
+	return ""
+}
+
+func (m *AnalysisCoverage) GetFile() []string {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+// Records that the analyzer of a category was restarted during the analysis.
+type AnalysisRestart struct {
+	Category *string `protobuf:"bytes,1,opt,name=category" json:"category,omitempty"`
+	// The address of the analyzer service.
+	Analyzer *string `protobuf:"bytes,2,opt,name=analyzer" json:"analyzer,omitempty"`
+	// The error that showed the analyzer had stopped responding.
+	Reason           *string `protobuf:"bytes,3,opt,name=reason" json:"reason,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *AnalysisRestart) Reset()         { *m = AnalysisRestart{} }
+func (m *AnalysisRestart) String() string { return proto.CompactTextString(m) }
+func (*AnalysisRestart) ProtoMessage()    {}
+
+func (m *AnalysisRestart) GetCategory() string {
+	if m != nil && m.Category != nil {
+		return *m.Category
+	}
+	return ""
+}
+
+func (m *AnalysisRestart) GetAnalyzer() string {
+	if m != nil && m.Analyzer != nil {
+		return *m.Analyzer
+	}
+	return ""
+}
+
+func (m *AnalysisRestart) GetReason() string {
+	if m != nil && m.Reason != nil {
+		return *m.Reason
+	}
+	return ""
+}
+
+type GetDocumentationRequest struct {
+	Category *string `protobuf:"bytes,1,opt,name=category" json:"category,omitempty"`
+	// If set, only this subcategory of the category is documented.
+	Subcategory      *string `protobuf:"bytes,2,opt,name=subcategory" json:"subcategory,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *GetDocumentationRequest) Reset()         { *m = GetDocumentationRequest{} }
+func (m *GetDocumentationRequest) String() string { return proto.CompactTextString(m) }
+func (*GetDocumentationRequest) ProtoMessage()    {}
+
+func (m *GetDocumentationRequest) GetCategory() string {
+	if m != nil && m.Category != nil {
+		return *m.Category
+	}
+	return ""
+}
+
+func (m *GetDocumentationRequest) GetSubcategory() string {
+	if m != nil && m.Subcategory != nil {
+		return *m.Subcategory
+	}
+	return ""
+}
+
+// Explains the notes of a category, or of one of its subcategories, to the
+// developers who read them.
+type Documentation struct {
+	Category    *string `protobuf:"bytes,1,opt,name=category" json:"category,omitempty"`
+	Subcategory *string `protobuf:"bytes,2,opt,name=subcategory" json:"subcategory,omitempty"`
+	// What the analyzer looks for.
+	Description *string `protobuf:"bytes,3,opt,name=description" json:"description,omitempty"`
+	// Why the notes matter, and when they can be ignored.
+	Rationale *string `protobuf:"bytes,4,opt,name=rationale" json:"rationale,omitempty"`
+	// Code that would be flagged, and how to fix it.
+	Example []string `protobuf:"bytes,5,rep,name=example" json:"example,omitempty"`
+	// Where to find more documentation.
+	Url              *string `protobuf:"bytes,6,opt,name=url" json:"url,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *Documentation) Reset()         { *m = Documentation{} }
+func (m *Documentation) String() string { return proto.CompactTextString(m) }
+func (*Documentation) ProtoMessage()    {}
+
+func (m *Documentation) GetCategory() string {
+	if m != nil && m.Category != nil {
+		return *m.Category
+	}
+	return ""
+}
+
+func (m *Documentation) GetSubcategory() string {
+	if m != nil && m.Subcategory != nil {
+		return *m.Subcategory
+	}
+	return ""
+}
+
+func (m *Documentation) GetDescription() string {
+	if m != nil && m.Description != nil {
+		return *m.Description
+	}
+	return ""
+}
+
+func (m *Documentation) GetRationale() string {
+	if m != nil && m.Rationale != nil {
+		return *m.Rationale
+	}
+	return ""
+}
+
+func (m *Documentation) GetExample() []string {
+	if m != nil {
+		return m.Example
+	}
+	return nil
+}
+
+func (m *Documentation) GetUrl() string {
+	if m != nil && m.Url != nil {
+		return *m.Url
+	}
+	return ""
+}
+
+type GetDocumentationResponse struct {
+	Documentation    *Documentation `protobuf:"bytes,1,opt,name=documentation" json:"documentation,omitempty"`
+	XXX_unrecognized []byte         `json:"-"`
+}
+
+func (m *GetDocumentationResponse) Reset()         { *m = GetDocumentationResponse{} }
+func (m *GetDocumentationResponse) String() string { return proto.CompactTextString(m) }
+func (*GetDocumentationResponse) ProtoMessage()    {}
+
+func (m *GetDocumentationResponse) GetDocumentation() *Documentation {
+	if m != nil {
+		return m.Documentation
+	}
+	return nil
+}
+
 type ShipshapeRequest struct {
 	// The ShipshapeContext to use for this run
 	ShipshapeContext *shipshape_proto2.ShipshapeContext `protobuf:"bytes,1,opt,name=shipshape_context" json:"shipshape_context,omitempty"`
@@ -173,8 +442,26 @@ type ShipshapeRequest struct {
 	// The event we are running for
 	Event *string `protobuf:"bytes,3,opt,name=event" json:"event,omitempty"`
 	// Which stage to run
-	Stage            *shipshape_proto2.Stage `protobuf:"varint,4,opt,name=stage,enum=shipshape_proto.Stage" json:"stage,omitempty"`
-	XXX_unrecognized []byte                  `json:"-"`
+	Stage *shipshape_proto2.Stage `protobuf:"varint,4,opt,name=stage,enum=shipshape_proto.Stage" json:"stage,omitempty"`
+	// If set, the time in milliseconds the service may spend on this request.
+	// Categories are run in order of priority, and those that do not finish
+	// within the budget are reported as failures.
+	TimeBudgetMs *int64 `protobuf:"varint,5,opt,name=time_budget_ms" json:"time_budget_ms,omitempty"`
+	// If set, the maximum number of analyzers to run at the same time.
+	AnalyzerParallelism *int32 `protobuf:"varint,6,opt,name=analyzer_parallelism" json:"analyzer_parallelism,omitempty"`
+	// If set, identifies the request so that it can be cancelled with Cancel
+	// while it runs.
+	RequestId *string `protobuf:"bytes,7,opt,name=request_id" json:"request_id,omitempty"`
+	// If set, the service sends responses with only progress while the
+	// analyzers run, before the responses with the results.
+	ReportProgress *bool `protobuf:"varint,8,opt,name=report_progress" json:"report_progress,omitempty"`
+	// If set, the service sends the results of each analyzer as soon as they
+	// are received, instead of all of them once the analysis is done.
+	StreamResults *bool `protobuf:"varint,9,opt,name=stream_results" json:"stream_results,omitempty"`
+	// If set, identifies the run of the CLI that sent the request, so that the
+	// service's logs can be matched to its results.
+	RunId            *string `protobuf:"bytes,10,opt,name=run_id" json:"run_id,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
 }
 
 func (m *ShipshapeRequest) Reset()         { *m = ShipshapeRequest{} }
@@ -209,8 +496,58 @@ func (m *ShipshapeRequest) GetStage() shipshape_proto2.Stage {
 	return shipshape_proto2.Stage_PRE_BUILD
 }
 
+func (m *ShipshapeRequest) GetRequestId() string {
+	if m != nil && m.RequestId != nil {
+		return *m.RequestId
+	}
+	return ""
+}
+
+func (m *ShipshapeRequest) GetReportProgress() bool {
+	if m != nil && m.ReportProgress != nil {
+		return *m.ReportProgress
+	}
+	return false
+}
+
+func (m *ShipshapeRequest) GetStreamResults() bool {
+	if m != nil && m.StreamResults != nil {
+		return *m.StreamResults
+	}
+	return false
+}
+
+func (m *ShipshapeRequest) GetRunId() string {
+	if m != nil && m.RunId != nil {
+		return *m.RunId
+	}
+	return ""
+}
+
 type ShipshapeResponse struct {
-	AnalyzeResponse  []*AnalyzeResponse `protobuf:"bytes,1,rep,name=analyze_response" json:"analyze_response,omitempty"`
+	// The version of the schema of the results, e.g. v2. Set only on the
+	// results written by the CLI; those written before it was set are v1.
+	// Declared first so that it heads their JSON form.
+	SchemaVersion *string `protobuf:"bytes,6,opt,name=schema_version" json:"schema_version,omitempty"`
+	// How the results were produced. Set only on the results written by the
+	// CLI, and declared next so that it follows the schema version at the head
+	// of their JSON form.
+	RunInfo         *RunInfo           `protobuf:"bytes,5,opt,name=run_info" json:"run_info,omitempty"`
+	AnalyzeResponse []*AnalyzeResponse `protobuf:"bytes,1,rep,name=analyze_response" json:"analyze_response,omitempty"`
+	// Set if the results do not cover everything that was asked for.
+	Incomplete *IncompleteResults `protobuf:"bytes,2,opt,name=incomplete" json:"incomplete,omitempty"`
+	// The number of notes that were left out because they were identical to
+	// another note, e.g. one found in both the pre-build and post-build stages.
+	DuplicateNotes *int32 `protobuf:"varint,3,opt,name=duplicate_notes" json:"duplicate_notes,omitempty"`
+	// How far the analysis has got, for the categories whose progress changed.
+	// Responses with progress have no results.
+	Progress []*AnalysisProgress `protobuf:"bytes,4,rep,name=progress" json:"progress,omitempty"`
+	// The teams that maintain the categories with notes or failures, as
+	// configured in .shipshape. Set only on the results written by the CLI.
+	Owner []*shipshape_proto3.CategoryOwner `protobuf:"bytes,7,rep,name=owner" json:"owner,omitempty"`
+	// How many notes the CLI left out because they were suppressed. Set only
+	// if there were any.
+	Suppressed       *SuppressionCounts `protobuf:"bytes,8,opt,name=suppressed" json:"suppressed,omitempty"`
 	XXX_unrecognized []byte             `json:"-"`
 }
 
@@ -218,6 +555,20 @@ func (m *ShipshapeResponse) Reset()         { *m = ShipshapeResponse{} }
 func (m *ShipshapeResponse) String() string { return proto.CompactTextString(m) }
 func (*ShipshapeResponse) ProtoMessage()    {}
 
+func (m *ShipshapeResponse) GetSchemaVersion() string {
+	if m != nil && m.SchemaVersion != nil {
+		return *m.SchemaVersion
+	}
+	return ""
+}
+
+func (m *ShipshapeResponse) GetRunInfo() *RunInfo {
+	if m != nil {
+		return m.RunInfo
+	}
+	return nil
+}
+
 func (m *ShipshapeResponse) GetAnalyzeResponse() []*AnalyzeResponse {
 	if m != nil {
 		return m.AnalyzeResponse
@@ -225,5 +576,294 @@ func (m *ShipshapeResponse) GetAnalyzeResponse() []*AnalyzeResponse {
 	return nil
 }
 
+func (m *ShipshapeResponse) GetIncomplete() *IncompleteResults {
+	if m != nil {
+		return m.Incomplete
+	}
+	return nil
+}
+
+func (m *ShipshapeResponse) GetDuplicateNotes() int32 {
+	if m != nil && m.DuplicateNotes != nil {
+		return *m.DuplicateNotes
+	}
+	return 0
+}
+
+func (m *ShipshapeResponse) GetProgress() []*AnalysisProgress {
+	if m != nil {
+		return m.Progress
+	}
+	return nil
+}
+
+func (m *ShipshapeResponse) GetOwner() []*shipshape_proto3.CategoryOwner {
+	if m != nil {
+		return m.Owner
+	}
+	return nil
+}
+
+func (m *ShipshapeResponse) GetSuppressed() *SuppressionCounts {
+	if m != nil {
+		return m.Suppressed
+	}
+	return nil
+}
+
+// Counts the notes that were suppressed, by how they were suppressed.
+type SuppressionCounts struct {
+	// Notes that are in the baseline.
+	Baseline *int32 `protobuf:"varint,1,opt,name=baseline" json:"baseline,omitempty"`
+	// Notes suppressed by a comment in their source file.
+	Inline *int32 `protobuf:"varint,2,opt,name=inline" json:"inline,omitempty"`
+	// Notes suppressed by an override in .shipshape.
+	Override         *int32 `protobuf:"varint,3,opt,name=override" json:"override,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *SuppressionCounts) Reset()         { *m = SuppressionCounts{} }
+func (m *SuppressionCounts) String() string { return proto.CompactTextString(m) }
+func (*SuppressionCounts) ProtoMessage()    {}
+
+func (m *SuppressionCounts) GetBaseline() int32 {
+	if m != nil && m.Baseline != nil {
+		return *m.Baseline
+	}
+	return 0
+}
+
+func (m *SuppressionCounts) GetInline() int32 {
+	if m != nil && m.Inline != nil {
+		return *m.Inline
+	}
+	return 0
+}
+
+func (m *SuppressionCounts) GetOverride() int32 {
+	if m != nil && m.Override != nil {
+		return *m.Override
+	}
+	return 0
+}
+
+// Describes a run of the CLI, so that its results can be audited and the run
+// reproduced.
+type RunInfo struct {
+	// The version of the shipshape CLI.
+	CliVersion *string `protobuf:"bytes,1,opt,name=cli_version" json:"cli_version,omitempty"`
+	// The command line the CLI was run with, including the program name.
+	CommandLine []string `protobuf:"bytes,2,rep,name=command_line" json:"command_line,omitempty"`
+	// The absolute path of the directory or file that was analyzed.
+	WorkspaceRoot *string `protobuf:"bytes,3,opt,name=workspace_root" json:"workspace_root,omitempty"`
+	// The git commit the workspace was at, if it is in a git repository.
+	Commit *string `protobuf:"bytes,4,opt,name=commit" json:"commit,omitempty"`
+	// The image of the shipshape service.
+	ServiceImage *ImageInfo `protobuf:"bytes,5,opt,name=service_image" json:"service_image,omitempty"`
+	// The analyzer images, and the kythe image if the code was built.
+	Image []*ImageInfo `protobuf:"bytes,6,rep,name=image" json:"image,omitempty"`
+	// When the run started, in milliseconds since the epoch.
+	StartTimestampMillis *int64 `protobuf:"varint,7,opt,name=start_timestamp_millis" json:"start_timestamp_millis,omitempty"`
+	// How long the run took, in milliseconds.
+	DurationMs *int64 `protobuf:"varint,8,opt,name=duration_ms" json:"duration_ms,omitempty"`
+	// The random ID of the run, which is also in its logs, on the containers it
+	// started and in its requests to the service.
+	RunId            *string `protobuf:"bytes,9,opt,name=run_id" json:"run_id,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *RunInfo) Reset()         { *m = RunInfo{} }
+func (m *RunInfo) String() string { return proto.CompactTextString(m) }
+func (*RunInfo) ProtoMessage()    {}
+
+func (m *RunInfo) GetCliVersion() string {
+	if m != nil && m.CliVersion != nil {
+		return *m.CliVersion
+	}
+	return ""
+}
+
+func (m *RunInfo) GetCommandLine() []string {
+	if m != nil {
+		return m.CommandLine
+	}
+	return nil
+}
+
+func (m *RunInfo) GetWorkspaceRoot() string {
+	if m != nil && m.WorkspaceRoot != nil {
+		return *m.WorkspaceRoot
+	}
+	return ""
+}
+
+func (m *RunInfo) GetCommit() string {
+	if m != nil && m.Commit != nil {
+		return *m.Commit
+	}
+	return ""
+}
+
+func (m *RunInfo) GetServiceImage() *ImageInfo {
+	if m != nil {
+		return m.ServiceImage
+	}
+	return nil
+}
+
+func (m *RunInfo) GetImage() []*ImageInfo {
+	if m != nil {
+		return m.Image
+	}
+	return nil
+}
+
+func (m *RunInfo) GetStartTimestampMillis() int64 {
+	if m != nil && m.StartTimestampMillis != nil {
+		return *m.StartTimestampMillis
+	}
+	return 0
+}
+
+func (m *RunInfo) GetDurationMs() int64 {
+	if m != nil && m.DurationMs != nil {
+		return *m.DurationMs
+	}
+	return 0
+}
+
+func (m *RunInfo) GetRunId() string {
+	if m != nil && m.RunId != nil {
+		return *m.RunId
+	}
+	return ""
+}
+
+// An image used by a run.
+type ImageInfo struct {
+	// The image as it was run, e.g. gcr.io/shipshape_releases/service:prod.
+	Name *string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// The digest of the image, such as sha256:..., if it is known.
+	Digest           *string `protobuf:"bytes,2,opt,name=digest" json:"digest,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *ImageInfo) Reset()         { *m = ImageInfo{} }
+func (m *ImageInfo) String() string { return proto.CompactTextString(m) }
+func (*ImageInfo) ProtoMessage()    {}
+
+func (m *ImageInfo) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *ImageInfo) GetDigest() string {
+	if m != nil && m.Digest != nil {
+		return *m.Digest
+	}
+	return ""
+}
+
+// Records how many of the analyzers called for a category have finished so
+// far. An analyzer returns the notes for all of the files it is sent at once,
+// so progress is counted per analyzer call, not per file.
+type AnalysisProgress struct {
+	Category           *string `protobuf:"bytes,1,opt,name=category" json:"category,omitempty"`
+	AnalyzersCompleted *int32  `protobuf:"varint,2,opt,name=analyzers_completed" json:"analyzers_completed,omitempty"`
+	AnalyzersTotal     *int32  `protobuf:"varint,3,opt,name=analyzers_total" json:"analyzers_total,omitempty"`
+	XXX_unrecognized   []byte  `json:"-"`
+}
+
+func (m *AnalysisProgress) Reset()         { *m = AnalysisProgress{} }
+func (m *AnalysisProgress) String() string { return proto.CompactTextString(m) }
+func (*AnalysisProgress) ProtoMessage()    {}
+
+func (m *AnalysisProgress) GetCategory() string {
+	if m != nil && m.Category != nil {
+		return *m.Category
+	}
+	return ""
+}
+
+func (m *AnalysisProgress) GetAnalyzersCompleted() int32 {
+	if m != nil && m.AnalyzersCompleted != nil {
+		return *m.AnalyzersCompleted
+	}
+	return 0
+}
+
+func (m *AnalysisProgress) GetAnalyzersTotal() int32 {
+	if m != nil && m.AnalyzersTotal != nil {
+		return *m.AnalyzersTotal
+	}
+	return 0
+}
+
+// Describes what is missing from a set of results.
+type IncompleteResults struct {
+	// Why the results are incomplete, e.g. the stream from the service ended
+	// early or an analyzer crashed.
+	Reason *string `protobuf:"bytes,1,opt,name=reason" json:"reason,omitempty"`
+	// The categories whose results may be missing notes.
+	Category         []string `protobuf:"bytes,2,rep,name=category" json:"category,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *IncompleteResults) Reset()         { *m = IncompleteResults{} }
+func (m *IncompleteResults) String() string { return proto.CompactTextString(m) }
+func (*IncompleteResults) ProtoMessage()    {}
+
+func (m *IncompleteResults) GetReason() string {
+	if m != nil && m.Reason != nil {
+		return *m.Reason
+	}
+	return ""
+}
+
+func (m *IncompleteResults) GetCategory() []string {
+	if m != nil {
+		return m.Category
+	}
+	return nil
+}
+
+// Asks the service to stop working on a request started with Run.
+type CancelRequest struct {
+	// The request_id of the request to cancel.
+	RequestId        *string `protobuf:"bytes,1,opt,name=request_id" json:"request_id,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *CancelRequest) Reset()         { *m = CancelRequest{} }
+func (m *CancelRequest) String() string { return proto.CompactTextString(m) }
+func (*CancelRequest) ProtoMessage()    {}
+
+func (m *CancelRequest) GetRequestId() string {
+	if m != nil && m.RequestId != nil {
+		return *m.RequestId
+	}
+	return ""
+}
+
+type CancelResponse struct {
+	// Whether a running request had the request_id. The request may already
+	// have finished, or never have started.
+	Cancelled        *bool  `protobuf:"varint,1,opt,name=cancelled" json:"cancelled,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *CancelResponse) Reset()         { *m = CancelResponse{} }
+func (m *CancelResponse) String() string { return proto.CompactTextString(m) }
+func (*CancelResponse) ProtoMessage()    {}
+
+func (m *CancelResponse) GetCancelled() bool {
+	if m != nil && m.Cancelled != nil {
+		return *m.Cancelled
+	}
+	return false
+}
+
 func init() {
 }
@@ -46,7 +46,16 @@ type TextRange struct {
 	// columns.
 	// If set, all other fields must be set as well.
 	// Must be non-negative.
-	EndColumn        *int32 `protobuf:"varint,4,opt,name=end_column,def=0" json:"end_column,omitempty"`
+	EndColumn *int32 `protobuf:"varint,4,opt,name=end_column,def=0" json:"end_column,omitempty"`
+	// The byte offset in the file of the first byte of the range, counted from
+	// zero. Editors and tools that apply fixes can use the offsets instead of
+	// converting lines and columns, which count characters.
+	// If set, end_offset must be set as well.
+	StartOffset *int32 `protobuf:"varint,5,opt,name=start_offset" json:"start_offset,omitempty"`
+	// The byte offset just past the last byte of the range, i.e. the range
+	// spans (end_offset-start_offset) bytes.
+	// If set, start_offset must be set as well, and not be greater.
+	EndOffset        *int32 `protobuf:"varint,6,opt,name=end_offset" json:"end_offset,omitempty"`
 	XXX_unrecognized []byte `json:"-"`
 }
 
@@ -87,5 +96,19 @@ func (m *TextRange) GetEndColumn() int32 {
 	return Default_TextRange_EndColumn
 }
 
+func (m *TextRange) GetStartOffset() int32 {
+	if m != nil && m.StartOffset != nil {
+		return *m.StartOffset
+	}
+	return 0
+}
+
+func (m *TextRange) GetEndOffset() int32 {
+	if m != nil && m.EndOffset != nil {
+		return *m.EndOffset
+	}
+	return 0
+}
+
 func init() {
 }
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"log"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/shipshape/shipshape/util/rpc/server"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// cancelledMessage is the failure reported for the categories of a request that was
+// cancelled before they finished.
+const cancelledMessage = "Cancelled by the client"
+
+// runningRequests tracks the requests with a request ID that are running, so that they
+// can be cancelled.
+type runningRequests struct {
+	mu sync.Mutex
+	// cancel has a channel for each running request, which is closed when it is cancelled.
+	cancel map[string]chan struct{}
+}
+
+func newRunningRequests() *runningRequests {
+	return &runningRequests{cancel: make(map[string]chan struct{})}
+}
+
+// start records that the request id is running, and returns the channel that is closed
+// if it is cancelled. A request that is still running with the same id can no longer be
+// cancelled.
+func (r *runningRequests) start(id string) <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := make(chan struct{})
+	r.cancel[id] = c
+	return c
+}
+
+// finish records that the request id, which returned c from start, is done.
+func (r *runningRequests) finish(id string, c <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel[id] == c {
+		delete(r.cancel, id)
+	}
+}
+
+// stop cancels the request id, and returns whether it was running.
+func (r *runningRequests) stop(id string) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.cancel[id]
+	if ok {
+		close(c)
+		delete(r.cancel, id)
+	}
+	return ok
+}
+
+// Cancel stops working on the running request with the given request ID. Analyzers that
+// have not been called for it yet are not called, and the calls that are running are
+// abandoned; their categories are reported as failures, and the request then returns.
+func (sd ShipshapeDriver) Cancel(ctx server.Context, in *rpcpb.CancelRequest) (*rpcpb.CancelResponse, error) {
+	cancelled := in.GetRequestId() != "" && sd.running.stop(in.GetRequestId())
+	log.Printf("Received cancellation of request %q, running: %v", in.GetRequestId(), cancelled)
+	return &rpcpb.CancelResponse{Cancelled: proto.Bool(cancelled)}, nil
+}
+
+// isCancelled returns whether c, a channel from runningRequests.start, has been closed.
+// A nil channel is never closed.
+func isCancelled(c <-chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	strset "github.com/google/shipshape/shipshape/util/strings"
+	testutil "github.com/google/shipshape/shipshape/util/test"
+
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func TestRunningRequests(t *testing.T) {
+	r := newRunningRequests()
+	if r.stop("a") {
+		t.Errorf("Stopped a request that was not running")
+	}
+	a := r.start("a")
+	if isCancelled(a) {
+		t.Errorf("Request was cancelled before it was stopped")
+	}
+	if !r.stop("a") {
+		t.Errorf("Could not stop a running request")
+	}
+	if !isCancelled(a) {
+		t.Errorf("Request was not cancelled after it was stopped")
+	}
+	if r.stop("a") {
+		t.Errorf("Stopped a request twice")
+	}
+
+	// A finished request cannot be stopped, and finishing an old request with the
+	// same id leaves the new one running.
+	old := r.start("b")
+	r.finish("b", old)
+	if r.stop("b") {
+		t.Errorf("Stopped a request that had finished")
+	}
+	old = r.start("b")
+	b := r.start("b")
+	r.finish("b", old)
+	if !r.stop("b") || !isCancelled(b) {
+		t.Errorf("Could not stop the latest request with a reused id")
+	}
+}
+
+func TestCancel(t *testing.T) {
+	driver := NewTestDriver(nil)
+	c := driver.running.start("req")
+	resp, err := driver.Cancel(nil, &rpcpb.CancelRequest{RequestId: proto.String("req")})
+	if err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	if !resp.GetCancelled() || !isCancelled(c) {
+		t.Errorf("Running request was not cancelled: got %v", resp)
+	}
+	resp, err = driver.Cancel(nil, &rpcpb.CancelRequest{RequestId: proto.String("other")})
+	if err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	if resp.GetCancelled() {
+		t.Errorf("Cancelled a request that was not running")
+	}
+}
+
+func TestCallAllAnalyzersCancelled(t *testing.T) {
+	ctx := &ctxpb.ShipshapeContext{FilePath: []string{"dir1/A"}}
+	fast := &slowDispatcher{delay: 0}
+	slow := &slowDispatcher{delay: 2 * time.Second}
+	fastAddr, cleanup, err := testutil.CreatekRPCTestServer(fast, "AnalyzerService")
+	if err != nil {
+		t.Fatalf("Registering analyzer service failed: %v", err)
+	}
+	defer cleanup()
+	slowAddr, cleanup, err := testutil.CreatekRPCTestServer(slow, "AnalyzerService")
+	if err != nil {
+		t.Fatalf("Registering analyzer service failed: %v", err)
+	}
+	defer cleanup()
+	driver := NewTestDriver([]serviceInfo{
+		serviceInfo{fastAddr, strset.New("Fast"), ctxpb.Stage_PRE_BUILD},
+		serviceInfo{slowAddr, strset.New("Slow"), ctxpb.Stage_PRE_BUILD},
+	})
+	driver.cancelled = driver.running.start("req")
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		driver.running.stop("req")
+	}()
+
+	start := time.Now()
+	ars := driver.callAllAnalyzers(strset.New("Fast", "Slow"), ctx, ctxpb.Stage_PRE_BUILD, nil)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Cancelled request did not return promptly: took %v", elapsed)
+	}
+
+	var notes, failed []string
+	for _, ar := range ars {
+		for _, n := range ar.Note {
+			notes = append(notes, n.GetCategory())
+		}
+		for _, f := range ar.Failure {
+			failed = append(failed, f.GetCategory())
+			if f.GetFailureMessage() != cancelledMessage {
+				t.Errorf("Wrong failure message for %s: got %q, want %q", f.GetCategory(), f.GetFailureMessage(), cancelledMessage)
+			}
+		}
+	}
+	if want := []string{"Fast"}; !reflect.DeepEqual(notes, want) {
+		t.Errorf("Wrong notes: got %v, want %v", notes, want)
+	}
+	if want := []string{"Slow"}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("Wrong failures: got %v, want %v", failed, want)
+	}
+}
@@ -27,11 +27,23 @@ import (
 
 	yaml "gopkg.in/yaml.v2"
 
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
 	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
 )
 
 const (
 	defaultName = "default"
+
+	// FailureRequired marks an analyzer whose failure fails the run.
+	FailureRequired = "required"
+	// FailureBestEffort marks an analyzer whose failure is only a warning.
+	FailureBestEffort = "best-effort"
+
+	// TierFast marks an analyzer that is cheap enough to run on every change.
+	TierFast = "fast"
+	// TierSlow marks an analyzer that is expensive to run.
+	TierSlow = "slow"
 )
 
 // config is a struct for handling configuration for analyses. Given a Shipshape Context, it will access
@@ -41,6 +53,18 @@ type config struct {
 	images     []string
 	ignore     []string
 	categories []string
+	policies   []*configpb.Policy
+	analyzers  []*configpb.AnalyzerConfig
+	env        []*configpb.AnalyzerEnvironment
+	sandbox    []*configpb.AnalyzerSandbox
+	manifest   string
+	overrides  []*configpb.CategoryOverride
+	events     []string
+	pipelines  []*configpb.Pipeline
+	generated  string
+	owners     []*configpb.CategoryOwner
+	feedback   string
+	schedules  []*configpb.Schedule
 }
 
 // unmarshalConfigBytes parses a YAML payload into a Shipshape config. It normalizes
@@ -83,10 +107,89 @@ func buildConfig(rawConfig *configpb.ShipshapeConfig, eventName string) *config
 	if g := rawConfig.Global; g != nil {
 		c.images = append(c.images, g.Images...)
 		c.ignore = append(c.ignore, g.Ignore...)
+		c.env = append(c.env, g.Environment...)
+		c.sandbox = append(c.sandbox, g.Sandbox...)
+		c.manifest = g.GetManifest()
+		c.events = append(c.events, g.Events...)
+		c.generated = g.GetGenerated()
+		c.feedback = g.GetFeedback()
 	}
+	c.policies = append(c.policies, rawConfig.Policies...)
+	c.analyzers = append(c.analyzers, rawConfig.Analyzers...)
+	c.overrides = append(c.overrides, rawConfig.Overrides...)
+	c.pipelines = append(c.pipelines, rawConfig.Pipelines...)
+	c.owners = append(c.owners, rawConfig.Owners...)
+	c.schedules = append(c.schedules, rawConfig.Schedules...)
 	return c
 }
 
+// skipsGenerated returns whether generated files are left out of the analysis, which
+// they are unless the config says to analyze them.
+func (c *config) skipsGenerated() bool {
+	return c == nil || c.generated != GeneratedAnalyze
+}
+
+// analyzer returns the settings for the analyzer of category, or nil if there are none.
+// The getters of a nil AnalyzerConfig return the defaults, so the result can be used
+// directly. A nil config has no settings.
+func (c *config) analyzer(category string) *configpb.AnalyzerConfig {
+	if c != nil {
+		for _, a := range c.analyzers {
+			if a.GetCategory() == category {
+				return a
+			}
+		}
+	}
+	return nil
+}
+
+// routedFiles returns those of files that the analyzer of category runs on: the ones
+// matching its paths, if it has any, less the ones matching its excluded paths.
+func (c *config) routedFiles(category string, files []string) []string {
+	a := c.analyzer(category)
+	if len(a.GetPaths()) == 0 && len(a.GetExclude()) == 0 {
+		return files
+	}
+	var routed []string
+	for _, f := range files {
+		if (len(a.Paths) == 0 || MatchesAnyPath(a.Paths, f)) && !MatchesAnyPath(a.Exclude, f) {
+			routed = append(routed, f)
+		}
+	}
+	return routed
+}
+
+// MatchesAnyPath reports whether path matches one of the patterns. A pattern
+// ending in "/" matches everything under that directory, as does a Bazel-style
+// pattern such as "//java/..."; any other pattern is a glob matched against
+// both the full path and the file name.
+func MatchesAnyPath(patterns []string, path string) bool {
+	if path == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "//") && strings.HasSuffix(pattern, "...") {
+			pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "//"), "...")
+			if pattern == "" {
+				return true
+			}
+		}
+		if strings.HasSuffix(pattern, "/") {
+			if strings.HasPrefix(path, pattern) {
+				return true
+			}
+			continue
+		}
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // validateConfig looks for errors in the given configuration proto.
 // TODO(collinwinter): return all the errors, not just the first one.
 func validateConfig(rawConfig *configpb.ShipshapeConfig) error {
@@ -108,7 +211,130 @@ func validateConfig(rawConfig *configpb.ShipshapeConfig) error {
 			return fmt.Errorf("Multiple events with name %q (indexes %v)", name, strings.Join(indexes, ", "))
 		}
 	}
-	return nil
+	for i, p := range rawConfig.Policies {
+		if p.Severity != nil {
+			if _, ok := notepb.Note_Severity_value[p.GetSeverity()]; !ok {
+				return fmt.Errorf("Policy at index %v has unknown severity %q", i, p.GetSeverity())
+			}
+		}
+		if p.GetAllowed() < 0 {
+			return fmt.Errorf("Policy at index %v must allow a non-negative number of notes", i)
+		}
+	}
+	for i, a := range rawConfig.Analyzers {
+		if a.Category == nil {
+			return fmt.Errorf("Analyzer at index %v is missing a category", i)
+		}
+		if f := a.GetFailure(); f != FailureRequired && f != FailureBestEffort {
+			return fmt.Errorf("Analyzer %q has unknown failure policy %q (must be %q or %q)", a.GetCategory(), f, FailureRequired, FailureBestEffort)
+		}
+		if a.GetRetries() < 0 {
+			return fmt.Errorf("Analyzer %q must have a non-negative number of retries", a.GetCategory())
+		}
+		if t := a.GetTier(); a.Tier != nil && t != TierFast && t != TierSlow {
+			return fmt.Errorf("Analyzer %q has unknown tier %q (must be %q or %q)", a.GetCategory(), t, TierFast, TierSlow)
+		}
+		for _, p := range append(a.GetPaths(), a.GetExclude()...) {
+			if strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "//") {
+				return fmt.Errorf("Analyzer %q has absolute path %q; paths are relative to the repository root", a.GetCategory(), p)
+			}
+		}
+	}
+	for i, o := range rawConfig.Overrides {
+		if o.GetCategory() == "" {
+			return fmt.Errorf("Override at index %v is missing a category", i)
+		}
+		if o.Severity != nil {
+			if _, ok := notepb.Note_Severity_value[o.GetSeverity()]; !ok {
+				return fmt.Errorf("Override at index %v has unknown severity %q", i, o.GetSeverity())
+			}
+		}
+		if o.GetSuppress() && (o.Severity != nil || o.Rename != nil) {
+			return fmt.Errorf("Override at index %v suppresses notes, so it cannot also change their severity or category", i)
+		}
+		if !o.GetSuppress() && o.Severity == nil && o.Rename == nil {
+			return fmt.Errorf("Override at index %v must set a severity, a rename, or suppress", i)
+		}
+	}
+	owned := make(map[string]bool)
+	for i, o := range rawConfig.Owners {
+		if o.GetCategory() == "" {
+			return fmt.Errorf("Owner at index %v is missing a category", i)
+		}
+		if o.GetTeam() == "" && o.GetContact() == "" {
+			return fmt.Errorf("Owner of %q must have a team or a contact", o.GetCategory())
+		}
+		if owned[o.GetCategory()] {
+			return fmt.Errorf("Category %q has more than one owner", o.GetCategory())
+		}
+		owned[o.GetCategory()] = true
+	}
+	for i, e := range rawConfig.GetGlobal().GetEnvironment() {
+		if e.Image == nil {
+			return fmt.Errorf("Environment at index %v is missing an image", i)
+		}
+		for j, v := range e.Variables {
+			if v.GetName() == "" {
+				return fmt.Errorf("Variable at index %v of the environment for %q is missing a name", j, e.GetImage())
+			}
+			if (v.Value == nil) == (v.Secret == nil) {
+				return fmt.Errorf("Variable %q of the environment for %q must have exactly one of a value or a secret", v.GetName(), e.GetImage())
+			}
+		}
+	}
+	if g := rawConfig.GetGlobal().GetGenerated(); g != GeneratedSkip && g != GeneratedAnalyze {
+		return fmt.Errorf("Unknown setting %q for generated files (must be %q or %q)", g, GeneratedSkip, GeneratedAnalyze)
+	}
+	for i, sb := range rawConfig.GetGlobal().GetSandbox() {
+		if sb.Image == nil {
+			return fmt.Errorf("Sandbox at index %v is missing an image", i)
+		}
+		for _, c := range sb.Drop {
+			if c == "" {
+				return fmt.Errorf("Sandbox for %q drops an empty capability", sb.GetImage())
+			}
+		}
+	}
+	pipelineNames := make(map[string]bool)
+	for i, p := range rawConfig.Pipelines {
+		if p.GetName() == "" {
+			return fmt.Errorf("Pipeline at index %v is missing a name", i)
+		}
+		if pipelineNames[p.GetName()] {
+			return fmt.Errorf("Multiple pipelines with name %q", p.GetName())
+		}
+		pipelineNames[p.GetName()] = true
+		postBuild := false
+		for _, s := range p.Stages {
+			if _, ok := ctxpb.Stage_value[s]; !ok {
+				return fmt.Errorf("Pipeline %q has unknown stage %q (must be one of %s)", p.GetName(), s, strings.Join(StageNames(), ", "))
+			}
+			postBuild = postBuild || s == ctxpb.Stage_POST_BUILD.String()
+		}
+		if postBuild && p.GetBuild() == "" {
+			return fmt.Errorf("Pipeline %q runs the POST_BUILD stage, so it must set a build", p.GetName())
+		}
+		if t := p.GetTier(); p.Tier != nil && t != TierFast && t != TierSlow {
+			return fmt.Errorf("Pipeline %q has unknown tier %q (must be %q or %q)", p.GetName(), t, TierFast, TierSlow)
+		}
+	}
+	scheduleNames := make(map[string]bool)
+	for i, sc := range rawConfig.Schedules {
+		if sc.GetName() == "" {
+			return fmt.Errorf("Schedule at index %v is missing a name", i)
+		}
+		if scheduleNames[sc.GetName()] {
+			return fmt.Errorf("Multiple schedules with name %q", sc.GetName())
+		}
+		scheduleNames[sc.GetName()] = true
+		if _, err := ParseCron(sc.GetCron()); err != nil {
+			return fmt.Errorf("Schedule %q has an invalid cron %q: %v", sc.GetName(), sc.GetCron(), err)
+		}
+		if !pipelineNames[sc.GetPipeline()] {
+			return fmt.Errorf("Schedule %q runs unknown pipeline %q", sc.GetName(), sc.GetPipeline())
+		}
+	}
+	return validateEvents(rawConfig)
 }
 
 // GlobalConfig retrieves the global configuration settings for the specified
@@ -122,6 +348,148 @@ func GlobalConfig(path string) ([]string, error) {
 	return cfg.images, nil
 }
 
+// EventCategories retrieves the categories configured for the given event
+// in the configuration file in the directory at path.
+func EventCategories(path, event string) ([]string, error) {
+	cfg, err := loadConfig(filepath.Join(path, configFilename), event)
+	if err != nil || cfg == nil {
+		return nil, err
+	}
+	return cfg.categories, nil
+}
+
+// Policies retrieves the result policies from the configuration file in the
+// directory at path. It returns no policies if there is no configuration file.
+func Policies(path string) ([]*configpb.Policy, error) {
+	cfg, err := loadConfig(filepath.Join(path, configFilename), "")
+	if err != nil || cfg == nil {
+		return nil, err
+	}
+	return cfg.policies, nil
+}
+
+// AnalyzerConfigs retrieves the per-category analyzer settings from the
+// configuration file in the directory at path.
+func AnalyzerConfigs(path string) ([]*configpb.AnalyzerConfig, error) {
+	cfg, err := loadConfig(filepath.Join(path, configFilename), "")
+	if err != nil || cfg == nil {
+		return nil, err
+	}
+	return cfg.analyzers, nil
+}
+
+// Overrides retrieves the changes to the severities and categories of notes
+// from the configuration file in the directory at path.
+func Overrides(path string) ([]*configpb.CategoryOverride, error) {
+	cfg, err := loadConfig(filepath.Join(path, configFilename), "")
+	if err != nil || cfg == nil {
+		return nil, err
+	}
+	return cfg.overrides, nil
+}
+
+// Owners retrieves the teams that maintain the analyzers of categories from
+// the configuration file in the directory at path.
+func Owners(path string) ([]*configpb.CategoryOwner, error) {
+	cfg, err := loadConfig(filepath.Join(path, configFilename), "")
+	if err != nil || cfg == nil {
+		return nil, err
+	}
+	return cfg.owners, nil
+}
+
+// IgnorePaths retrieves the directories that are not analyzed from the
+// configuration file in the directory at path.
+func IgnorePaths(path string) ([]string, error) {
+	cfg, err := loadConfig(filepath.Join(path, configFilename), "")
+	if err != nil || cfg == nil {
+		return nil, err
+	}
+	return cfg.ignore, nil
+}
+
+// AnalyzerEnvironments retrieves the environments of third-party analyzer
+// containers from the configuration file in the directory at path.
+func AnalyzerEnvironments(path string) ([]*configpb.AnalyzerEnvironment, error) {
+	cfg, err := loadConfig(filepath.Join(path, configFilename), "")
+	if err != nil || cfg == nil {
+		return nil, err
+	}
+	return cfg.env, nil
+}
+
+// AnalyzerSandboxes retrieves the security profiles of third-party analyzer
+// containers from the configuration file in the directory at path.
+func AnalyzerSandboxes(path string) ([]*configpb.AnalyzerSandbox, error) {
+	cfg, err := loadConfig(filepath.Join(path, configFilename), "")
+	if err != nil || cfg == nil {
+		return nil, err
+	}
+	return cfg.sandbox, nil
+}
+
+// ManifestURL retrieves the URL of the manifest of vetted analyzers from the
+// configuration file in the directory at path, or the empty string if there
+// is none.
+func ManifestURL(path string) (string, error) {
+	cfg, err := loadConfig(filepath.Join(path, configFilename), "")
+	if err != nil || cfg == nil {
+		return "", err
+	}
+	return cfg.manifest, nil
+}
+
+// FeedbackURL retrieves the URL that reports of false positives are posted to
+// from the configuration file in the directory at path, or the empty string
+// if there is none.
+func FeedbackURL(path string) (string, error) {
+	cfg, err := loadConfig(filepath.Join(path, configFilename), "")
+	if err != nil || cfg == nil {
+		return "", err
+	}
+	return cfg.feedback, nil
+}
+
+// Pipelines retrieves the named pipelines from the configuration file in the
+// directory at path.
+func Pipelines(path string) ([]*configpb.Pipeline, error) {
+	cfg, err := loadConfig(filepath.Join(path, configFilename), "")
+	if err != nil || cfg == nil {
+		return nil, err
+	}
+	return cfg.pipelines, nil
+}
+
+// Schedules retrieves the schedules of pipelines from the configuration file
+// in the directory at path.
+func Schedules(path string) ([]*configpb.Schedule, error) {
+	cfg, err := loadConfig(filepath.Join(path, configFilename), "")
+	if err != nil || cfg == nil {
+		return nil, err
+	}
+	return cfg.schedules, nil
+}
+
+// FindPipeline retrieves the pipeline called name from the configuration
+// file in the directory at path.
+func FindPipeline(path, name string) (*configpb.Pipeline, error) {
+	pipelines, err := Pipelines(path)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, p := range pipelines {
+		if p.GetName() == name {
+			return p, nil
+		}
+		names = append(names, p.GetName())
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no pipelines are configured in %s", filepath.Join(path, configFilename))
+	}
+	return nil, fmt.Errorf("no pipeline %q in %s (found %s)", name, filepath.Join(path, configFilename), strings.Join(names, ", "))
+}
+
 // loadConfig looks at given path for a Shipshape config file, loading the configuration
 // for the given event, if found.
 func loadConfig(configPath string, eventName string) (*config, error) {
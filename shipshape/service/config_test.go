@@ -19,7 +19,11 @@ package service
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -40,6 +44,8 @@ global:
   ignore:
     - file=.gitignore
     - third_party/
+  events:
+    - deploy
 
 events:
   - event: default
@@ -84,6 +90,8 @@ global:
   ignore:
     - file=.gitignore
     - third_party/
+  events:
+    - deploy
 
 events:
   - event: deploy
@@ -114,6 +122,300 @@ events:
 	}
 }
 
+func TestPolicies(t *testing.T) {
+	yaml := `
+events:
+  - event: default
+    categories:
+      - ErrorProne
+policies:
+  - name: no ErrorProne errors outside tests
+    categories:
+      - ErrorProne
+    severity: BUILD_ERROR
+    exclude:
+      - "*Test.java"
+  - name: at most five warnings
+    allowed: 5`
+
+	rawCfg, err := unmarshalConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateConfig(rawCfg); err != nil {
+		t.Fatalf("Unexpected error validating config: %v", err)
+	}
+	policies := buildConfig(rawCfg, "default").policies
+	if len(policies) != 2 {
+		t.Fatalf("Wrong number of policies: got %v, want 2", policies)
+	}
+	p := policies[0]
+	if p.GetName() != "no ErrorProne errors outside tests" || p.GetSeverity() != "BUILD_ERROR" ||
+		!reflect.DeepEqual(p.Categories, []string{"ErrorProne"}) || !reflect.DeepEqual(p.Exclude, []string{"*Test.java"}) {
+		t.Errorf("Incorrect first policy: got %v", p)
+	}
+	if got, want := p.GetAllowed(), int32(0); got != want {
+		t.Errorf("Incorrect default allowance: got %v, want %v", got, want)
+	}
+	if got, want := policies[1].GetAllowed(), int32(5); got != want {
+		t.Errorf("Incorrect allowance: got %v, want %v", got, want)
+	}
+}
+
+func TestAnalyzerConfigs(t *testing.T) {
+	yaml := `
+events:
+  - event: default
+    categories:
+      - ErrorProne
+      - PyLint
+analyzers:
+  - category: ErrorProne
+    failure: required
+  - category: PyLint`
+
+	rawCfg, err := unmarshalConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateConfig(rawCfg); err != nil {
+		t.Fatalf("Unexpected error validating config: %v", err)
+	}
+	analyzers := buildConfig(rawCfg, "default").analyzers
+	if len(analyzers) != 2 {
+		t.Fatalf("Wrong number of analyzers: got %v, want 2", analyzers)
+	}
+	if got, want := analyzers[0].GetFailure(), FailureRequired; got != want {
+		t.Errorf("Incorrect failure policy: got %v, want %v", got, want)
+	}
+	if got, want := analyzers[1].GetFailure(), FailureBestEffort; got != want {
+		t.Errorf("Incorrect default failure policy: got %v, want %v", got, want)
+	}
+}
+
+func TestAnalyzerEnvironments(t *testing.T) {
+	yaml := `
+global:
+  images:
+    - gcr.io/example/scanner:prod
+  environment:
+    - image: gcr.io/example/scanner:prod
+      variables:
+        - name: SCANNER_MODE
+          value: strict
+        - name: SCANNER_LICENSE
+          secret: SCANNER_LICENSE_KEY
+events:
+  - event: default
+    categories:
+      - Scanner`
+
+	rawCfg, err := unmarshalConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateConfig(rawCfg); err != nil {
+		t.Fatalf("Unexpected error validating config: %v", err)
+	}
+	env := buildConfig(rawCfg, "default").env
+	if len(env) != 1 || len(env[0].Variables) != 2 {
+		t.Fatalf("Wrong environments: got %v, want one with 2 variables", env)
+	}
+	if got, want := env[0].GetImage(), "gcr.io/example/scanner:prod"; got != want {
+		t.Errorf("Incorrect image: got %v, want %v", got, want)
+	}
+	if got, want := env[0].Variables[1].GetSecret(), "SCANNER_LICENSE_KEY"; got != want {
+		t.Errorf("Incorrect secret: got %v, want %v", got, want)
+	}
+}
+
+func TestAnalyzerSandboxes(t *testing.T) {
+	yaml := `
+global:
+  images:
+    - gcr.io/example/scanner:prod
+  sandbox:
+    - image: gcr.io/example/scanner:prod
+      offline: true
+      readonly: true
+      drop:
+        - ALL
+      seccomp: seccomp/scanner.json
+events:
+  - event: default
+    categories:
+      - Scanner`
+
+	rawCfg, err := unmarshalConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateConfig(rawCfg); err != nil {
+		t.Fatalf("Unexpected error validating config: %v", err)
+	}
+	sandbox := buildConfig(rawCfg, "default").sandbox
+	if len(sandbox) != 1 {
+		t.Fatalf("Wrong sandboxes: got %v, want one", sandbox)
+	}
+	sb := sandbox[0]
+	if !sb.GetOffline() || !sb.GetReadonly() {
+		t.Errorf("Sandbox should be offline and read-only: got %v", sb)
+	}
+	if got, want := sb.GetDrop(), []string{"ALL"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Incorrect dropped capabilities: got %v, want %v", got, want)
+	}
+	if got, want := sb.GetSeccomp(), "seccomp/scanner.json"; got != want {
+		t.Errorf("Incorrect seccomp profile: got %v, want %v", got, want)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	yaml := `
+global:
+  manifest: https://example.com/analyzers.yaml
+  feedback: https://example.com/false-positives
+events:
+  - event: default
+    categories:
+      - go vet`
+
+	rawCfg, err := unmarshalConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buildConfig(rawCfg, "default").manifest, "https://example.com/analyzers.yaml"; got != want {
+		t.Errorf("Incorrect manifest: got %v, want %v", got, want)
+	}
+	if got, want := buildConfig(rawCfg, "default").feedback, "https://example.com/false-positives"; got != want {
+		t.Errorf("Incorrect feedback URL: got %v, want %v", got, want)
+	}
+}
+
+func TestPipelines(t *testing.T) {
+	yaml := `
+events:
+  - event: default
+    categories:
+      - go vet
+pipelines:
+  - name: editor
+    event: editor
+    tier: fast
+  - name: nightly
+    categories: [go vet, ErrorProne]
+    build: maven
+    outputs:
+      format: csv
+      json: nightly.json`
+
+	rawCfg, err := unmarshalConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateConfig(rawCfg); err != nil {
+		t.Fatalf("Unexpected error validating config: %v", err)
+	}
+	pipelines := buildConfig(rawCfg, "").pipelines
+	if len(pipelines) != 2 {
+		t.Fatalf("Wrong number of pipelines: got %v, want 2", pipelines)
+	}
+	if got, want := pipelines[0].GetTier(), TierFast; got != want {
+		t.Errorf("Incorrect tier: got %v, want %v", got, want)
+	}
+	p := pipelines[1]
+	if p.GetBuild() != "maven" || !reflect.DeepEqual(p.Categories, []string{"go vet", "ErrorProne"}) {
+		t.Errorf("Incorrect nightly pipeline: got %v", p)
+	}
+	if got, want := p.GetOutputs().GetJson(), "nightly.json"; got != want {
+		t.Errorf("Incorrect JSON output: got %v, want %v", got, want)
+	}
+}
+
+func TestSchedules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	yaml := `
+events:
+  - event: default
+    categories: [go vet]
+pipelines:
+  - name: full
+    categories: [go vet, ErrorProne]
+schedules:
+  - name: nightly
+    cron: "0 2 * * *"
+    pipeline: full
+    branch: main`
+	if err := ioutil.WriteFile(filepath.Join(dir, configFilename), []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	schedules, err := Schedules(dir)
+	if err != nil {
+		t.Fatalf("Could not get the schedules: %v", err)
+	}
+	if len(schedules) != 1 {
+		t.Fatalf("Wrong number of schedules: got %v, want 1", schedules)
+	}
+	if s := schedules[0]; s.GetName() != "nightly" || s.GetCron() != "0 2 * * *" || s.GetPipeline() != "full" || s.GetBranch() != "main" {
+		t.Errorf("Incorrect schedule: got %v", s)
+	}
+}
+
+func TestOwners(t *testing.T) {
+	yaml := `
+events:
+  - event: default
+    categories:
+      - JavaLint
+owners:
+  - category: JavaLint
+    team: java-infra
+    contact: java-infra@example.com
+    url: https://issues.example.com/javalint`
+
+	rawCfg, err := unmarshalConfigBytes([]byte(yaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateConfig(rawCfg); err != nil {
+		t.Fatalf("Unexpected error validating config: %v", err)
+	}
+	owners := buildConfig(rawCfg, "").owners
+	if len(owners) != 1 {
+		t.Fatalf("Wrong number of owners: got %v, want 1", owners)
+	}
+	if o := owners[0]; o.GetTeam() != "java-infra" || o.GetContact() != "java-infra@example.com" || o.GetUrl() != "https://issues.example.com/javalint" {
+		t.Errorf("Incorrect owner: got %v", o)
+	}
+}
+
+func TestFindPipeline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	yaml := "events:\n  - event: default\n    categories: [go vet]\npipelines:\n  - name: pr\n    event: presubmit\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, configFilename), []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := FindPipeline(dir, "pr")
+	if err != nil {
+		t.Fatalf("Could not find pipeline: %v", err)
+	}
+	if got, want := p.GetEvent(), EventPresubmit; got != want {
+		t.Errorf("Incorrect event: got %v, want %v", got, want)
+	}
+	if _, err := FindPipeline(dir, "nightly"); err == nil || !strings.Contains(err.Error(), "found pr") {
+		t.Errorf("Wrong error for a missing pipeline: got %v", err)
+	}
+}
+
 func TestValidYamlInvalidConfig(t *testing.T) {
 	tests := []struct {
 		label string
@@ -179,6 +481,270 @@ events:
       - Benchmark`,
 			errors.New("Multiple events with name \"review\" (indexes 0, 1)"),
 		},
+		{
+			"Policy with unknown severity",
+			`
+events:
+  - event: review
+    categories:
+      - Loadtest
+policies:
+  - name: no errors
+    severity: ERROR`,
+			errors.New("Policy at index 0 has unknown severity \"ERROR\""),
+		},
+		{
+			"Policy with negative allowance",
+			`
+events:
+  - event: review
+    categories:
+      - Loadtest
+policies:
+  - name: no errors
+  - name: a few warnings
+    allowed: -1`,
+			errors.New("Policy at index 1 must allow a non-negative number of notes"),
+		},
+		{
+			"Analyzer without category",
+			`
+events:
+  - event: review
+    categories:
+      - Loadtest
+analyzers:
+  - failure: required`,
+			errors.New("Analyzer at index 0 is missing a category"),
+		},
+		{
+			"Analyzer with unknown failure policy",
+			`
+events:
+  - event: review
+    categories:
+      - Loadtest
+analyzers:
+  - category: Loadtest
+    failure: optional`,
+			errors.New("Analyzer \"Loadtest\" has unknown failure policy \"optional\" (must be \"required\" or \"best-effort\")"),
+		},
+		{
+			"Analyzer with negative retries",
+			`
+events:
+  - event: review
+    categories:
+      - Loadtest
+analyzers:
+  - category: Loadtest
+    retries: -1`,
+			errors.New("Analyzer \"Loadtest\" must have a non-negative number of retries"),
+		},
+		{
+			"Analyzer with unknown tier",
+			`
+events:
+  - event: review
+    categories:
+      - Loadtest
+analyzers:
+  - category: Loadtest
+    tier: medium`,
+			errors.New("Analyzer \"Loadtest\" has unknown tier \"medium\" (must be \"fast\" or \"slow\")"),
+		},
+		{
+			"Unknown setting for generated files",
+			`
+global:
+  generated: lint
+events:
+  - event: review
+    categories:
+      - Loadtest`,
+			errors.New("Unknown setting \"lint\" for generated files (must be \"skip\" or \"analyze\")"),
+		},
+		{
+			"Override with unknown severity",
+			`
+events:
+  - event: review
+    categories:
+      - PyLint
+overrides:
+  - category: PyLint
+    severity: INFO`,
+			errors.New("Override at index 0 has unknown severity \"INFO\""),
+		},
+		{
+			"Override that suppresses and renames",
+			`
+events:
+  - event: review
+    categories:
+      - PyLint
+overrides:
+  - category: PyLint
+    suppress: true
+    rename: Python`,
+			errors.New("Override at index 0 suppresses notes, so it cannot also change their severity or category"),
+		},
+		{
+			"Owner without a team or contact",
+			`
+events:
+  - event: review
+    categories:
+      - JavaLint
+owners:
+  - category: JavaLint
+    url: https://issues.example.com/javalint`,
+			errors.New("Owner of \"JavaLint\" must have a team or a contact"),
+		},
+		{
+			"Category with two owners",
+			`
+events:
+  - event: review
+    categories:
+      - JavaLint
+owners:
+  - category: JavaLint
+    team: java-infra
+  - category: JavaLint
+    contact: java-infra@example.com`,
+			errors.New("Category \"JavaLint\" has more than one owner"),
+		},
+		{
+			"Override that does nothing",
+			`
+events:
+  - event: review
+    categories:
+      - PyLint
+overrides:
+  - category: PyLint
+    subcategory: missing-docstring`,
+			errors.New("Override at index 0 must set a severity, a rename, or suppress"),
+		},
+		{
+			"Analyzer with absolute path",
+			`
+events:
+  - event: review
+    categories:
+      - Loadtest
+analyzers:
+  - category: Loadtest
+    paths:
+      - /java/`,
+			errors.New("Analyzer \"Loadtest\" has absolute path \"/java/\"; paths are relative to the repository root"),
+		},
+		{
+			"Environment variable with a value and a secret",
+			`
+global:
+  environment:
+    - image: scanner
+      variables:
+        - name: LICENSE
+          value: abc
+          secret: LICENSE_KEY
+events:
+  - event: review
+    categories:
+      - Loadtest`,
+			errors.New("Variable \"LICENSE\" of the environment for \"scanner\" must have exactly one of a value or a secret"),
+		},
+		{
+			"Sandbox without an image",
+			`
+global:
+  sandbox:
+    - offline: true
+events:
+  - event: review
+    categories:
+      - Loadtest`,
+			errors.New("Sandbox at index 0 is missing an image"),
+		},
+		{
+			"Pipeline without a name",
+			`
+events:
+  - event: review
+    categories:
+      - Loadtest
+pipelines:
+  - event: review`,
+			errors.New("Pipeline at index 0 is missing a name"),
+		},
+		{
+			"Pipeline with an unknown stage",
+			`
+events:
+  - event: review
+    categories:
+      - Loadtest
+pipelines:
+  - name: nightly
+    stages: [PRE_BUILD, DEPLOY]`,
+			errors.New("Pipeline \"nightly\" has unknown stage \"DEPLOY\" (must be one of PRE_BUILD, POST_BUILD, DEPENDENCIES, SECURITY, DEPLOYMENT_CONFIG)"),
+		},
+		{
+			"Pipeline running the post-build stage without a build",
+			`
+events:
+  - event: review
+    categories:
+      - Loadtest
+pipelines:
+  - name: nightly
+    stages: [POST_BUILD]`,
+			errors.New("Pipeline \"nightly\" runs the POST_BUILD stage, so it must set a build"),
+		},
+		{
+			"Multiple pipelines with the same name",
+			`
+events:
+  - event: review
+    categories:
+      - Loadtest
+pipelines:
+  - name: nightly
+  - name: nightly`,
+			errors.New("Multiple pipelines with name \"nightly\""),
+		},
+		{
+			"Schedule with an invalid cron",
+			`
+events:
+  - event: review
+    categories:
+      - Loadtest
+pipelines:
+  - name: nightly
+schedules:
+  - name: drift
+    cron: "0 25 * * *"
+    pipeline: nightly`,
+			errors.New("Schedule \"drift\" has an invalid cron \"0 25 * * *\": bad hour \"25\": must be between 0 and 23"),
+		},
+		{
+			"Schedule of an unknown pipeline",
+			`
+events:
+  - event: review
+    categories:
+      - Loadtest
+pipelines:
+  - name: nightly
+schedules:
+  - name: drift
+    cron: "@daily"
+    pipeline: weekly`,
+			errors.New("Schedule \"drift\" runs unknown pipeline \"weekly\""),
+		},
 	}
 
 	for _, test := range tests {
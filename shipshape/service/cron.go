@@ -0,0 +1,152 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronAliases are the shorthands for common cron expressions.
+var cronAliases = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// cronFields are the fields of a cron expression, in order, with the values
+// they can take.
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of the month", 1, 31},
+	{"month", 1, 12},
+	{"day of the week", 0, 7},
+}
+
+// Cron is a parsed cron expression.
+type Cron struct {
+	// values holds, for each field, whether each of its values matches.
+	values [5][]bool
+	// anyDayOfMonth and anyDayOfWeek record whether the day of the month
+	// and the day of the week are *, which decides how they combine.
+	anyDayOfMonth, anyDayOfWeek bool
+}
+
+// ParseCron parses a cron expression of five fields: minute, hour, day of the
+// month, month and day of the week. Each field is *, a number, a range such as
+// 1-5, any of those followed by a step such as */15, or a comma-separated list
+// of them. Sunday is both 0 and 7. The aliases @hourly, @daily, @weekly and
+// @monthly are also accepted.
+func ParseCron(spec string) (*Cron, error) {
+	if alias, ok := cronAliases[spec]; ok {
+		spec = alias
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("expected %d fields, got %d", len(cronFields), len(fields))
+	}
+	c := &Cron{anyDayOfMonth: fields[2] == "*", anyDayOfWeek: fields[4] == "*"}
+	for i, f := range cronFields {
+		c.values[i] = make([]bool, f.max+1)
+		for _, part := range strings.Split(fields[i], ",") {
+			if err := c.parsePart(i, part); err != nil {
+				return nil, fmt.Errorf("bad %s %q: %v", f.name, fields[i], err)
+			}
+		}
+	}
+	if c.values[4][7] {
+		c.values[4][0] = true
+	}
+	return c, nil
+}
+
+// parsePart parses one of the comma-separated parts of field i.
+func (c *Cron) parsePart(i int, part string) error {
+	f := cronFields[i]
+	step := 1
+	if slash := strings.Index(part, "/"); slash >= 0 {
+		var err error
+		if step, err = strconv.Atoi(part[slash+1:]); err != nil || step < 1 {
+			return fmt.Errorf("the step must be a positive number")
+		}
+		part = part[:slash]
+	}
+	lo, hi := f.min, f.max
+	if part != "*" {
+		bounds := strings.SplitN(part, "-", 2)
+		var err error
+		if lo, err = strconv.Atoi(bounds[0]); err != nil {
+			return fmt.Errorf("%q is not a number", bounds[0])
+		}
+		hi = lo
+		if len(bounds) == 2 {
+			if hi, err = strconv.Atoi(bounds[1]); err != nil {
+				return fmt.Errorf("%q is not a number", bounds[1])
+			}
+		}
+		if lo < f.min || hi > f.max || lo > hi {
+			return fmt.Errorf("must be between %d and %d", f.min, f.max)
+		}
+	}
+	for v := lo; v <= hi; v += step {
+		c.values[i][v] = true
+	}
+	return nil
+}
+
+// Matches returns whether c runs in the minute of t.
+func (c *Cron) Matches(t time.Time) bool {
+	return c.matchesDay(t) && c.values[0][t.Minute()] && c.values[1][t.Hour()]
+}
+
+// matchesDay returns whether c runs on the day of t. As in cron, if both the
+// day of the month and the day of the week are restricted, a day that
+// matches either of them matches.
+func (c *Cron) matchesDay(t time.Time) bool {
+	if !c.values[3][int(t.Month())] {
+		return false
+	}
+	dayOfMonth, dayOfWeek := c.values[2][t.Day()], c.values[4][int(t.Weekday())]
+	if c.anyDayOfMonth || c.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
+
+// Next returns the first minute after t that c runs in, or the zero time if
+// it does not run in the next five years.
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for end := t.AddDate(5, 0, 0); t.Before(end); {
+		if !c.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.Matches(t) {
+			return t
+		}
+		t = t.Add(time.Minute)
+	}
+	return time.Time{}
+}
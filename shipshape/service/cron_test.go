@@ -0,0 +1,86 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, spec := range []string{"0 2 * * *", "*/15 9-17 * * 1-5", "0 0 1,15 * *", "30 4 * * 7", "@hourly", "@weekly"} {
+		if _, err := ParseCron(spec); err != nil {
+			t.Errorf("ParseCron(%q) failed: %v", spec, err)
+		}
+	}
+	for _, spec := range []string{"", "0 2 * *", "60 * * * *", "0 0 0 * *", "* * * 13 *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@yearly"} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	// 2015-06-01 was a Monday.
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2015, time.June, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		spec string
+		t    time.Time
+		want bool
+	}{
+		{"0 2 * * *", at(1, 2, 0), true},
+		{"0 2 * * *", at(1, 2, 1), false},
+		{"*/15 9-17 * * 1-5", at(5, 17, 45), true},
+		{"*/15 9-17 * * 1-5", at(6, 10, 0), false},
+		{"0 0 * * 0", at(7, 0, 0), true},
+		{"0 0 * * 7", at(7, 0, 0), true},
+		// With both days restricted, either of them matches.
+		{"0 0 15 * 1", at(1, 0, 0), true},
+		{"0 0 15 * 1", at(15, 0, 0), true},
+		{"0 0 15 * 1", at(16, 0, 0), false},
+		{"0 0 15 * *", at(1, 0, 0), false},
+	}
+	for _, test := range tests {
+		c, err := ParseCron(test.spec)
+		if err != nil {
+			t.Fatalf("ParseCron(%q) failed: %v", test.spec, err)
+		}
+		if got := c.Matches(test.t); got != test.want {
+			t.Errorf("ParseCron(%q).Matches(%v) = %v, want %v", test.spec, test.t, got, test.want)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	c, err := ParseCron("0 2 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2015, time.June, 1, 2, 0, 30, 0, time.UTC)
+	if got, want := c.Next(from), time.Date(2015, time.June, 2, 2, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next(%v) = %v, want %v", from, got, want)
+	}
+	never, err := ParseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := never.Next(from); !got.IsZero() {
+		t.Errorf("Next of February 30th = %v, want the zero time", got)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -44,18 +45,40 @@ const (
 	configFilename        = ".shipshape"
 	compilationsDir       = "compilations"
 	sourceContainer       = "shipping_container"
+	budgetExceeded        = "Did not finish within the time budget for this run"
+	// How long to wait for an analyzer to list its categories and stage.
+	analyzerInfoTimeout = 30 * time.Second
 )
 
 var (
-	clients = make(map[string]*client.Client)
+	clients   = make(map[string]*client.Client)
+	clientsMu sync.Mutex
 )
 
 type ShipshapeDriver struct {
 	AnalyzerLocations []string
+	// RestartCommand is run with sh to restart an analyzer that stops responding in the
+	// middle of a run, with the address of the analyzer in $ANALYZER. If it is empty,
+	// analyzers are not restarted.
+	RestartCommand string
 	// serviceMap is a mapping from analyzer locations to the categories they have available
 	// and the stage they should be run at.
 	// The range of serviceMap is the same as AnalyzerLocations
 	serviceMap map[string]serviceInfo
+	// parallelism is the maximum number of analyzers to call at once for a request.
+	// If zero, all analyzers are called at once.
+	parallelism int
+	// running has the requests that can be cancelled, and is shared by all copies of the driver.
+	running *runningRequests
+	// cancelled is closed if the request being run is cancelled.
+	cancelled <-chan struct{}
+	// progress is where the progress of the request being run is sent, if it asked for it.
+	progress chan<- *rpcpb.ShipshapeResponse
+	// results is where the results of each analyzer are sent as soon as they are received,
+	// if the request asked for them to be streamed, and streamed records the ones that were
+	// so that they are not sent again at the end.
+	results  chan<- *rpcpb.ShipshapeResponse
+	streamed map[*rpcpb.AnalyzeResponse]bool
 }
 
 type serviceInfo struct {
@@ -72,7 +95,7 @@ func NewDriver(analyzerLocations []string) *ShipshapeDriver {
 	for _, addr := range analyzerLocations {
 		addrs = append(addrs, strings.TrimPrefix(addr, "http://"))
 	}
-	return &ShipshapeDriver{AnalyzerLocations: addrs}
+	return &ShipshapeDriver{AnalyzerLocations: addrs, running: newRunningRequests()}
 }
 
 // NewTestDriver is only for testing. It creates a ShipshapeDriver
@@ -85,20 +108,42 @@ func NewTestDriver(services []serviceInfo) *ShipshapeDriver {
 		addrs = append(addrs, trimmed)
 		trimmedServices[trimmed] = serviceInfo{trimmed, info.categories, info.stage}
 	}
-	return &ShipshapeDriver{AnalyzerLocations: addrs, serviceMap: trimmedServices}
+	return &ShipshapeDriver{AnalyzerLocations: addrs, serviceMap: trimmedServices, running: newRunningRequests()}
 }
 
 // Run runs the analyzers that this driver knows about on the provided ShipshapeRequest,
 // taking configuration into account.
 func (sd ShipshapeDriver) Run(ctx server.Context, in *rpcpb.ShipshapeRequest, out chan<- *rpcpb.ShipshapeResponse) error {
+	start := time.Now()
 	var ars []*rpcpb.AnalyzeResponse
-	log.Printf("Received analysis request for event %v, stage %v, categories %v, repo %v", *in.Event, *in.Stage, in.TriggeredCategory, *in.ShipshapeContext.RepoRoot)
+	log.Printf("Received analysis request of run %q for event %v, stage %v, categories %v, repo %v", in.GetRunId(), *in.Event, *in.Stage, in.TriggeredCategory, *in.ShipshapeContext.RepoRoot)
+	if id := in.GetRequestId(); id != "" && sd.running != nil {
+		sd.cancelled = sd.running.start(id)
+		defer sd.running.finish(id, sd.cancelled)
+	}
+	if in.GetReportProgress() {
+		sd.progress = out
+	}
+	if in.GetStreamResults() {
+		sd.results = out
+		sd.streamed = make(map[*rpcpb.AnalyzeResponse]bool)
+	}
 
-	// However we exit, send back the set of collected AnalyzeResponses
-	// TODO(ciera): we should be streaming back the responses, not sending them all at the end.
+	// However we exit, send back the collected AnalyzeResponses that were not streamed.
+	stage := contextpb.Stage_PRE_BUILD
+	if in.Stage != nil {
+		stage = *in.Stage
+	}
 	defer func() {
+		var unsent []*rpcpb.AnalyzeResponse
+		for _, ar := range ars {
+			if !sd.streamed[ar] {
+				ar.Stage = stage.Enum()
+				unsent = append(unsent, ar)
+			}
+		}
 		out <- &rpcpb.ShipshapeResponse{
-			AnalyzeResponse: ars,
+			AnalyzeResponse: unsent,
 		}
 	}()
 
@@ -140,6 +185,7 @@ func (sd ShipshapeDriver) Run(ctx server.Context, in *rpcpb.ShipshapeRequest, ou
 
 	// Find out what categories we have available, and remove/warn on the missing ones
 	sd.serviceMap = sd.getAllServiceInfo()
+	sd.parallelism = int(in.GetAnalyzerParallelism())
 	allCats := sd.allCats()
 	missingCats := strset.New().AddSet(desiredCats).RemoveSet(allCats)
 	for missing := range missingCats {
@@ -153,13 +199,9 @@ func (sd ShipshapeDriver) Run(ctx server.Context, in *rpcpb.ShipshapeRequest, ou
 	}
 
 	// TODO(ciera): move this global ignore stuff into the CLI processing
-	ignorePaths := []string{}
-	if cfg != nil {
-		ignorePaths = cfg.ignore
-	}
 	// Fill in the file_paths if they are empty in the context
 	context := proto.Clone(in.ShipshapeContext).(*contextpb.ShipshapeContext)
-	context.FilePath, err = retrieveAndFilterFiles(*context.RepoRoot, context.FilePath, ignorePaths)
+	context.FilePath, err = retrieveAndFilterFiles(*context.RepoRoot, context.FilePath, cfg)
 	if err != nil {
 		log.Printf("Had problems accessing files: %v", err.Error())
 		ars = append(ars, generateFailure("Driver setup", fmt.Sprint(err)))
@@ -172,14 +214,15 @@ func (sd ShipshapeDriver) Run(ctx server.Context, in *rpcpb.ShipshapeRequest, ou
 
 	// TODO(ciera): rather than pass the stage through here and checking all analyzers,
 	// filter out the stages earlier, when we check categories
-	stage := contextpb.Stage_PRE_BUILD
-	if in.Stage != nil {
-		stage = *in.Stage
-	}
-
 	log.Printf("Analyzing stage %s", stage.String())
-	if stage == contextpb.Stage_PRE_BUILD {
-		ars = append(ars, sd.callAllAnalyzers(desiredCats, context, stage)...)
+	// Every stage but POST_BUILD is run like PRE_BUILD, on the analyzers that
+	// registered for it.
+	if stage != contextpb.Stage_POST_BUILD {
+		if budget := in.GetTimeBudgetMs(); budget > 0 {
+			ars = append(ars, sd.callWithinBudget(desiredCats, context, stage, cfg, start.Add(time.Duration(budget)*time.Millisecond))...)
+		} else {
+			ars = append(ars, sd.callAllAnalyzers(desiredCats, context, stage, cfg)...)
+		}
 	} /*else {
 		comps := filepath.Join(*context.RepoRoot, compilationsDir)
 		compUnits, err := findCompilationUnits(comps)
@@ -195,7 +238,7 @@ func (sd ShipshapeDriver) Run(ctx server.Context, in *rpcpb.ShipshapeRequest, ou
 				CompilationDescriptionPath: proto.String(path),
 			}
 			log.Printf("Calling services with comp unit at %s", path)
-			ars = append(ars, sd.callAllAnalyzers(desiredCats, context, stage)...)
+			ars = append(ars, sd.callAllAnalyzers(desiredCats, context, stage, cfg)...)
 		}
 
 	}
@@ -205,6 +248,23 @@ func (sd ShipshapeDriver) Run(ctx server.Context, in *rpcpb.ShipshapeRequest, ou
 	return nil
 }
 
+// GetDocumentation asks the analyzer that provides the requested category for its
+// documentation.
+func (sd ShipshapeDriver) GetDocumentation(ctx server.Context, in *rpcpb.GetDocumentationRequest) (*rpcpb.GetDocumentationResponse, error) {
+	for analyzer, info := range sd.getAllServiceInfo() {
+		if !info.categories.Contains(in.GetCategory()) {
+			continue
+		}
+		c := client.AnalyzerClient{Client: getHTTPClient(analyzer), Timeout: analyzerInfoTimeout}
+		doc, err := c.GetDocumentation(in.GetCategory(), in.GetSubcategory())
+		if err != nil {
+			return nil, fmt.Errorf("could not get the documentation of %q from %s: %v", in.GetCategory(), analyzer, err)
+		}
+		return &rpcpb.GetDocumentationResponse{Documentation: doc}, nil
+	}
+	return nil, fmt.Errorf("the category %q could not be found at the locations %v", in.GetCategory(), sd.AnalyzerLocations)
+}
+
 // WaitForAnalyzers witll wait for all the given analyzers to become healthy
 // That is, their service is up and ready to serve requests.
 // Returns a mapping of which analyzers had which errors.
@@ -226,8 +286,9 @@ func WaitForAnalyzers(analyzerList []string) map[string]error {
 }
 
 // retrieveAndFilter files returns a list of files (initiated with files if that is non-empty,
-// or from recursing on root if it is) and removes the ones in the ignore list.
-func retrieveAndFilterFiles(root string, files []string, ignore []string) ([]string, error) {
+// or from recursing on root if it is) and removes the ones in the ignore list of cfg, and
+// the generated ones unless cfg says to analyze them. cfg may be nil.
+func retrieveAndFilterFiles(root string, files []string, cfg *config) ([]string, error) {
 	if len(files) == 0 {
 		log.Printf("No files, getting some")
 		var err error
@@ -237,7 +298,27 @@ func retrieveAndFilterFiles(root string, files []string, ignore []string) ([]str
 		}
 	}
 
-	return filterPaths(ignore, files), nil
+	var ignore []string
+	if cfg != nil {
+		ignore = cfg.ignore
+	}
+	files = filterPaths(ignore, files)
+	if cfg.skipsGenerated() {
+		files = filterGenerated(root, files)
+	}
+	return files, nil
+}
+
+// FilesToAnalyze returns the files that the service analyzes for a request on the
+// repository at root: the given files, or all files under root if there are none,
+// less those in the ignored directories of the configuration file in root and,
+// unless it says to analyze them, the generated ones.
+func FilesToAnalyze(root string, files []string) ([]string, error) {
+	cfg, err := loadConfig(filepath.Join(root, configFilename), "")
+	if err != nil {
+		return nil, err
+	}
+	return retrieveAndFilterFiles(root, files, cfg)
 }
 
 // collectAllFiles returns a list of all files for the passed-in root
@@ -290,34 +371,243 @@ nextFile:
 // callAllAnalyzers loops through the analyzer services, determines whether analyze should be called
 // on each, and then calls it with the appropriate set of files and categories.
 // It takes the configuration and the original context, and returns a slice of AnalyzeResponses.
-func (sd ShipshapeDriver) callAllAnalyzers(desiredCats strset.Set, context *contextpb.ShipshapeContext, stage contextpb.Stage) []*rpcpb.AnalyzeResponse {
+func (sd ShipshapeDriver) callAllAnalyzers(desiredCats strset.Set, context *contextpb.ShipshapeContext, stage contextpb.Stage, cfg *config) []*rpcpb.AnalyzeResponse {
+	ars, _ := sd.dispatch(desiredCats, context, stage, cfg, time.Time{})
+	return ars
+}
+
+// callWithinBudget calls the analyzers like callAllAnalyzers, but runs the categories in
+// order of decreasing priority, and stops at the deadline. Categories of the same priority
+// run together. Calls that are still running at the deadline are abandoned. Categories that
+// were not run, or did not finish, are reported as failures.
+func (sd ShipshapeDriver) callWithinBudget(desiredCats strset.Set, context *contextpb.ShipshapeContext, stage contextpb.Stage, cfg *config, deadline time.Time) []*rpcpb.AnalyzeResponse {
+	byPriority := make(map[int32]strset.Set)
+	var priorities []int
+	for cat := range desiredCats {
+		p := cfg.analyzer(cat).GetPriority()
+		if byPriority[p] == nil {
+			byPriority[p] = strset.New()
+			priorities = append(priorities, int(p))
+		}
+		byPriority[p].Add(cat)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(priorities)))
+
+	var ars []*rpcpb.AnalyzeResponse
+	skipped := strset.New()
+	for _, p := range priorities {
+		cats := byPriority[int32(p)]
+		if isCancelled(sd.cancelled) {
+			ars = append(ars, cancelledFailures(cats)...)
+			continue
+		}
+		if !time.Now().Before(deadline) {
+			skipped.AddSet(cats)
+			continue
+		}
+		done, unfinished := sd.dispatch(cats, context, stage, cfg, deadline)
+		ars = append(ars, done...)
+		skipped.AddSet(unfinished)
+	}
+	cats := skipped.ToSlice()
+	sort.Strings(cats)
+	for _, cat := range cats {
+		log.Printf("Category %s did not finish within the time budget", cat)
+		ars = append(ars, generateFailure(cat, budgetExceeded))
+	}
+	return ars
+}
+
+// dispatch calls each analyzer that supports some of the desired categories, and collects
+// the responses. If deadline is not zero, responses that have not arrived by the deadline
+// are abandoned, and their categories are returned as unfinished. If the request is
+// cancelled, the responses that have not arrived are abandoned too, and their categories
+// are reported as failures.
+func (sd ShipshapeDriver) dispatch(desiredCats strset.Set, context *contextpb.ShipshapeContext, stage contextpb.Stage, cfg *config, deadline time.Time) ([]*rpcpb.AnalyzeResponse, strset.Set) {
 	var ars []*rpcpb.AnalyzeResponse
 	var chans []chan *rpcpb.AnalyzeResponse
+	var chanCats []strset.Set
+	var chanContexts []*contextpb.ShipshapeContext
+	var slots chan bool
+	if sd.parallelism > 0 {
+		slots = make(chan bool, sd.parallelism)
+	}
 	for analyzer, info := range sd.serviceMap {
 		if info.stage != stage {
 			continue
 		}
-		cats := info.categories.Intersect(desiredCats)
-
-		log.Printf("Analyzer %s filtered to categories %v and files %v", analyzer, cats, context.FilePath)
+		// Categories of the analyzer that are routed to different files are
+		// analyzed in separate calls.
+		for _, r := range cfg.route(info.categories.Intersect(desiredCats), context) {
+			log.Printf("Analyzer %s filtered to categories %v and files %v", analyzer, r.cats, r.context.FilePath)
 
-		// If there are any categories to run on for this analyzer service,
-		// go ahead and call analyze
-		if len(cats) > 0 {
-			c := make(chan *rpcpb.AnalyzeResponse)
+			// Buffered, so that abandoned calls can still finish.
+			c := make(chan *rpcpb.AnalyzeResponse, 1)
 			chans = append(chans, c)
+			chanCats = append(chanCats, r.cats)
+			chanContexts = append(chanContexts, r.context)
 			req := &rpcpb.AnalyzeRequest{
-				ShipshapeContext: context,
-				Category:         cats.ToSlice(),
+				ShipshapeContext: r.context,
+				Category:         r.cats.ToSlice(),
 			}
-			go callAnalyze(analyzer, req, c)
+			go func(analyzer string, req *rpcpb.AnalyzeRequest, c chan<- *rpcpb.AnalyzeResponse) {
+				if slots != nil {
+					slots <- true
+					defer func() { <-slots }()
+				}
+				if isCancelled(sd.cancelled) {
+					resp := &rpcpb.AnalyzeResponse{}
+					for _, cat := range req.Category {
+						resp.Failure = append(resp.Failure, generateFailure(cat, cancelledMessage).Failure...)
+					}
+					c <- resp
+					return
+				}
+				if !deadline.IsZero() && !time.Now().Before(deadline) {
+					// The collector may be about to give up on this call, so make sure
+					// the categories are still reported if it does not.
+					resp := &rpcpb.AnalyzeResponse{}
+					for _, cat := range req.Category {
+						resp.Failure = append(resp.Failure, generateFailure(cat, budgetExceeded).Failure...)
+					}
+					c <- resp
+					return
+				}
+				c <- sd.analyzeWithRetries(analyzer, req, cfg)
+			}(analyzer, req, c)
+		}
+	}
+
+	// A category may be analyzed by more than one analyzer, so count the calls for each.
+	dispatched := strset.New()
+	calls := make(map[string]int)
+	finished := make(map[string]int)
+	for _, cats := range chanCats {
+		dispatched.AddSet(cats)
+		for cat := range cats {
+			calls[cat]++
 		}
 	}
+	sd.reportProgress(dispatched, finished, calls)
 
 	// Collect up all the responses where we actually called analyze
-	for _, c := range chans {
-		ar := <-c
-		ars = append(ars, filterResults(context, ar))
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(deadline.Sub(time.Now()))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	unfinished := strset.New()
+	expired := false
+	cancelled := false
+	for i, c := range chans {
+		if expired || cancelled {
+			select {
+			case ar := <-c:
+				ars = append(ars, sd.streamResults(filterResults(chanContexts[i], ar), stage))
+			default:
+				if cancelled {
+					ars = append(ars, cancelledFailures(chanCats[i])...)
+				} else {
+					unfinished.AddSet(chanCats[i])
+				}
+			}
+			continue
+		}
+		select {
+		case ar := <-c:
+			ars = append(ars, sd.streamResults(filterResults(chanContexts[i], ar), stage))
+			for cat := range chanCats[i] {
+				finished[cat]++
+			}
+			sd.reportProgress(chanCats[i], finished, calls)
+		case <-timeout:
+			expired = true
+			unfinished.AddSet(chanCats[i])
+		case <-sd.cancelled:
+			log.Printf("Request cancelled, abandoning the remaining analyzers")
+			cancelled = true
+			ars = append(ars, cancelledFailures(chanCats[i])...)
+		}
+	}
+	return ars, unfinished
+}
+
+// route is a call to an analyzer for some of its categories, with the files that they are
+// routed to.
+type route struct {
+	cats    strset.Set
+	context *contextpb.ShipshapeContext
+}
+
+// route groups cats by the files that their routing rules in the config select from
+// those of context, so that categories routed to the same files are analyzed together.
+// Categories with no files to analyze are left out.
+func (c *config) route(cats strset.Set, context *contextpb.ShipshapeContext) []route {
+	sorted := cats.ToSlice()
+	sort.Strings(sorted)
+	var routes []route
+	byFiles := make(map[string]int)
+	for _, cat := range sorted {
+		files := c.routedFiles(cat, context.FilePath)
+		if len(files) == 0 {
+			log.Printf("Category %s has no files to analyze under its configured paths", cat)
+			continue
+		}
+		key := strings.Join(files, "\x00")
+		if i, ok := byFiles[key]; ok {
+			routes[i].cats.Add(cat)
+			continue
+		}
+		ctx := context
+		if len(files) != len(context.FilePath) {
+			ctx = proto.Clone(context).(*contextpb.ShipshapeContext)
+			ctx.FilePath = files
+		}
+		byFiles[key] = len(routes)
+		routes = append(routes, route{strset.New(cat), ctx})
+	}
+	return routes
+}
+
+// streamResults sends ar, the results of an analyzer at stage, as soon as they are
+// received, if the request asked for them to be streamed. It returns ar.
+func (sd ShipshapeDriver) streamResults(ar *rpcpb.AnalyzeResponse, stage contextpb.Stage) *rpcpb.AnalyzeResponse {
+	if sd.results == nil {
+		return ar
+	}
+	ar.Stage = stage.Enum()
+	sd.streamed[ar] = true
+	sd.results <- &rpcpb.ShipshapeResponse{AnalyzeResponse: []*rpcpb.AnalyzeResponse{ar}}
+	return ar
+}
+
+// reportProgress sends the progress of cats, for each of which completed of the total
+// analyzer calls have finished, if the request asked for progress.
+func (sd ShipshapeDriver) reportProgress(cats strset.Set, completed, total map[string]int) {
+	if sd.progress == nil || len(cats) == 0 {
+		return
+	}
+	sorted := cats.ToSlice()
+	sort.Strings(sorted)
+	resp := &rpcpb.ShipshapeResponse{}
+	for _, cat := range sorted {
+		resp.Progress = append(resp.Progress, &rpcpb.AnalysisProgress{
+			Category:           proto.String(cat),
+			AnalyzersCompleted: proto.Int32(int32(completed[cat])),
+			AnalyzersTotal:     proto.Int32(int32(total[cat])),
+		})
+	}
+	sd.progress <- resp
+}
+
+// cancelledFailures reports each of cats as cancelled, sorted by category.
+func cancelledFailures(cats strset.Set) []*rpcpb.AnalyzeResponse {
+	sorted := cats.ToSlice()
+	sort.Strings(sorted)
+	var ars []*rpcpb.AnalyzeResponse
+	for _, cat := range sorted {
+		ars = append(ars, generateFailure(cat, cancelledMessage))
 	}
 	return ars
 }
@@ -337,8 +627,11 @@ func filterResults(context *contextpb.ShipshapeContext, response *rpcpb.AnalyzeR
 	}
 
 	return &rpcpb.AnalyzeResponse{
-		Note:    keep,
-		Failure: response.Failure,
+		Note:     keep,
+		Failure:  response.Failure,
+		Retry:    response.Retry,
+		Coverage: response.Coverage,
+		Restart:  response.Restart,
 	}
 }
 
@@ -370,26 +663,22 @@ func (sd ShipshapeDriver) getAllServiceInfo() map[string]serviceInfo {
 // callGetAnalyzerInfo requests the categories for the specified analyzer and puts them onto the
 // channel provided. If anything goes wrong, it returns the empty set.
 func callGetAnalyzerInfo(analyzer string, out chan<- serviceInfo) {
-	httpClient := getHTTPClient(analyzer)
-	var catResp rpcpb.GetCategoryResponse
-	var stageResp rpcpb.GetStageResponse
+	c := client.AnalyzerClient{Client: getHTTPClient(analyzer), Timeout: analyzerInfoTimeout}
 	var cats strset.Set
 	var stage contextpb.Stage
 	// TODO(ciera): Maybe we should just combine these into one call...
-	err := httpClient.Call("/AnalyzerService/GetCategory", &rpcpb.GetCategoryRequest{}, &catResp)
+	categories, err := c.GetCategory()
 	if err != nil {
 		log.Printf("Could not get categories from %s: %v", analyzer, err)
 		cats = strset.New()
 	} else {
-		cats = strset.New(catResp.Category...)
+		cats = strset.New(categories...)
 	}
 
-	err = httpClient.Call("/AnalyzerService/GetStage", &rpcpb.GetStageRequest{}, &stageResp)
+	stage, err = c.GetStage()
 	if err != nil {
 		log.Printf("Could not get stage from %s: %v", analyzer, err)
 		cats = strset.New()
-	} else {
-		stage = *stageResp.Stage
 	}
 
 	out <- serviceInfo{
@@ -399,23 +688,69 @@ func callGetAnalyzerInfo(analyzer string, out chan<- serviceInfo) {
 	}
 }
 
-// callAnalyze attempts to call analyze for the specified analyzer with the given request.
-// If anything goes wrong, it puts an AnalysisFailure into the AnalyzeResponse.
-func callAnalyze(analyzer string, req *rpcpb.AnalyzeRequest, out chan<- *rpcpb.AnalyzeResponse) {
-	httpClient := getHTTPClient(analyzer)
-	var resp rpcpb.AnalyzeResponse
-	err := httpClient.Call("/AnalyzerService/Analyze", req, &resp)
-	if err != nil {
-		out <- &rpcpb.AnalyzeResponse{
-			Failure: []*rpcpb.AnalysisFailure{
-				&rpcpb.AnalysisFailure{
-					FailureMessage: proto.String(fmt.Sprintf("Error from analyzer %s: %v", analyzer, err)),
-				},
-			},
+// analyzeWithRetries calls analyze for the specified analyzer. Each category that fails
+// is analyzed again on its own, up to the number of retries configured for it, and its
+// notes and failures are replaced by those of the last attempt. Retried categories are
+// recorded in the response. Failed categories are not retried once the request is cancelled.
+func (sd ShipshapeDriver) analyzeWithRetries(analyzer string, req *rpcpb.AnalyzeRequest, cfg *config) *rpcpb.AnalyzeResponse {
+	resp := sd.callAnalyze(analyzer, req)
+	attempts := make(map[string]int)
+	for {
+		retry := strset.New()
+		for _, f := range resp.Failure {
+			cat := f.GetCategory()
+			if attempts[cat] == 0 {
+				attempts[cat] = 1
+			}
+			if cat != "" && attempts[cat] <= int(cfg.analyzer(cat).GetRetries()) {
+				retry.Add(cat)
+			}
+		}
+		if len(retry) == 0 || isCancelled(sd.cancelled) {
+			break
+		}
+		for _, cat := range retry.ToSlice() {
+			attempts[cat]++
+			log.Printf("Retrying category %s on analyzer %s (attempt %d)", cat, analyzer, attempts[cat])
+			retryReq := &rpcpb.AnalyzeRequest{ShipshapeContext: req.ShipshapeContext, Category: []string{cat}}
+			resp = replaceCategory(resp, cat, sd.callAnalyze(analyzer, retryReq))
+		}
+	}
+	for _, cat := range req.Category {
+		if attempts[cat] > 1 {
+			resp.Retry = append(resp.Retry, &rpcpb.AnalysisRetry{
+				Category: proto.String(cat),
+				Attempts: proto.Int32(int32(attempts[cat])),
+			})
+		}
+	}
+	return resp
+}
+
+// replaceCategory returns the notes, failures and coverage of resp, with those for the
+// category cat replaced by the ones in update. The restarts of both are kept.
+func replaceCategory(resp *rpcpb.AnalyzeResponse, cat string, update *rpcpb.AnalyzeResponse) *rpcpb.AnalyzeResponse {
+	merged := &rpcpb.AnalyzeResponse{}
+	for _, n := range resp.Note {
+		if n.GetCategory() != cat {
+			merged.Note = append(merged.Note, n)
+		}
+	}
+	for _, f := range resp.Failure {
+		if f.GetCategory() != cat {
+			merged.Failure = append(merged.Failure, f)
+		}
+	}
+	for _, c := range resp.Coverage {
+		if c.GetCategory() != cat {
+			merged.Coverage = append(merged.Coverage, c)
 		}
-	} else {
-		out <- &resp
 	}
+	merged.Note = append(merged.Note, update.Note...)
+	merged.Failure = append(merged.Failure, update.Failure...)
+	merged.Coverage = append(merged.Coverage, update.Coverage...)
+	merged.Restart = append(resp.Restart, update.Restart...)
+	return merged
 }
 
 // findCompilationUnits takes a path which contains compilation units, and recursively
@@ -459,6 +794,8 @@ func generateFailure(cat string, message string) *rpcpb.AnalyzeResponse {
 
 // getHTTPClient provides a (cached) HTTPClient for the address specified.
 func getHTTPClient(addr string) *client.Client {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
 	httpClient, exists := clients[addr]
 	if !exists {
 		clients[addr] = client.NewHTTPClient(addr)
@@ -19,8 +19,12 @@ package service
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/google/shipshape/shipshape/util/rpc/server"
@@ -28,6 +32,7 @@ import (
 	testutil "github.com/google/shipshape/shipshape/util/test"
 
 	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
 	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
 	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
 )
@@ -73,6 +78,13 @@ func (f fakeDispatcher) Analyze(ctx server.Context, in *rpcpb.AnalyzeRequest) (*
 	}, nil
 }
 
+func (f fakeDispatcher) GetDocumentation(ctx server.Context, in *rpcpb.GetDocumentationRequest) (*rpcpb.GetDocumentationResponse, error) {
+	return &rpcpb.GetDocumentationResponse{Documentation: &rpcpb.Documentation{
+		Category:    in.Category,
+		Description: proto.String("Documentation from " + strings.Join(f.categories, ",")),
+	}}, nil
+}
+
 type errDispatcher struct{}
 
 func (errDispatcher) GetCategory(ctx server.Context, in *rpcpb.GetCategoryRequest) (*rpcpb.GetCategoryResponse, error) {
@@ -101,6 +113,76 @@ func (f fullFakeDispatcher) Analyze(ctx server.Context, in *rpcpb.AnalyzeRequest
 	return f.response, nil
 }
 
+// flakyDispatcher fails each category the first failures times it is analyzed.
+type flakyDispatcher struct {
+	failures int
+	calls    map[string]int
+}
+
+func (f *flakyDispatcher) Analyze(ctx server.Context, in *rpcpb.AnalyzeRequest) (*rpcpb.AnalyzeResponse, error) {
+	resp := &rpcpb.AnalyzeResponse{}
+	for _, cat := range in.Category {
+		f.calls[cat]++
+		if f.calls[cat] <= f.failures {
+			resp.Failure = append(resp.Failure, &rpcpb.AnalysisFailure{
+				Category:       proto.String(cat),
+				FailureMessage: proto.String("not ready"),
+			})
+			continue
+		}
+		resp.Note = append(resp.Note, &notepb.Note{
+			Category:    proto.String(cat),
+			Description: proto.String("A note"),
+			Location:    testutil.CreateLocation("dir1/A"),
+		})
+	}
+	return resp, nil
+}
+
+// slowDispatcher produces a note only after a delay.
+type slowDispatcher struct {
+	delay time.Duration
+	calls int32
+}
+
+func (s *slowDispatcher) Analyze(ctx server.Context, in *rpcpb.AnalyzeRequest) (*rpcpb.AnalyzeResponse, error) {
+	atomic.AddInt32(&s.calls, 1)
+	time.Sleep(s.delay)
+	return &rpcpb.AnalyzeResponse{
+		Note: []*notepb.Note{{
+			Category:    proto.String(in.Category[0]),
+			Description: proto.String("A note"),
+			Location:    testutil.CreateLocation("dir1/A"),
+		}},
+	}, nil
+}
+
+// countingDispatcher records the most calls to Analyze that were running at once,
+// across all the dispatchers sharing its counter.
+type countingDispatcher struct {
+	counter *concurrencyCounter
+}
+
+type concurrencyCounter struct {
+	mu            sync.Mutex
+	running, most int
+}
+
+func (d countingDispatcher) Analyze(ctx server.Context, in *rpcpb.AnalyzeRequest) (*rpcpb.AnalyzeResponse, error) {
+	c := d.counter
+	c.mu.Lock()
+	c.running++
+	if c.running > c.most {
+		c.most = c.running
+	}
+	c.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	c.mu.Lock()
+	c.running--
+	c.mu.Unlock()
+	return &rpcpb.AnalyzeResponse{}, nil
+}
+
 func TestGetServiceInfo(t *testing.T) {
 	addr2, cleanup, err := testutil.CreatekRPCTestServer(&fakeDispatcher{[]string{"Foo", "Bar"}, nil}, "AnalyzerService")
 	if err != nil {
@@ -153,6 +235,31 @@ func TestGetServiceInfo(t *testing.T) {
 	}
 }
 
+func TestGetDocumentation(t *testing.T) {
+	addrFoo, cleanup, err := testutil.CreatekRPCTestServer(&fakeDispatcher{[]string{"Foo"}, nil}, "AnalyzerService")
+	if err != nil {
+		t.Fatalf("Registering analyzer service failed: %v", err)
+	}
+	defer cleanup()
+	addrBar, cleanup, err := testutil.CreatekRPCTestServer(&fakeDispatcher{[]string{"Bar", "Baz"}, nil}, "AnalyzerService")
+	if err != nil {
+		t.Fatalf("Registering analyzer service failed: %v", err)
+	}
+	defer cleanup()
+
+	driver := NewDriver([]string{addrFoo, addrBar})
+	resp, err := driver.GetDocumentation(nil, &rpcpb.GetDocumentationRequest{Category: proto.String("Baz")})
+	if err != nil {
+		t.Fatalf("GetDocumentation failed: %v", err)
+	}
+	if got, want := resp.GetDocumentation().GetDescription(), "Documentation from Bar,Baz"; got != want {
+		t.Errorf("Wrong documentation: got %q, want %q", got, want)
+	}
+	if _, err := driver.GetDocumentation(nil, &rpcpb.GetDocumentationRequest{Category: proto.String("Missing")}); err == nil {
+		t.Errorf("Expected an error for an unknown category")
+	}
+}
+
 func TestCallAllAnalyzers(t *testing.T) {
 	dispatcher := &fakeDispatcher{categories: []string{"Foo", "Bar"}, files: []string{"dir1/A.h", "dir1/A.cc"}}
 	addr, cleanup, err := testutil.CreatekRPCTestServer(dispatcher, "AnalyzerService")
@@ -187,7 +294,7 @@ func TestCallAllAnalyzers(t *testing.T) {
 	for _, test := range tests {
 		ctx := &ctxpb.ShipshapeContext{FilePath: test.files}
 
-		ars := driver.callAllAnalyzers(strset.New(test.categories...), ctx, ctxpb.Stage_PRE_BUILD)
+		ars := driver.callAllAnalyzers(strset.New(test.categories...), ctx, ctxpb.Stage_PRE_BUILD, nil)
 		var notes []*notepb.Note
 
 		for _, ar := range ars {
@@ -204,6 +311,29 @@ func TestCallAllAnalyzers(t *testing.T) {
 	}
 }
 
+func TestCallAllAnalyzersCustomStage(t *testing.T) {
+	var services []serviceInfo
+	for cat, stage := range map[string]ctxpb.Stage{"Foo": ctxpb.Stage_PRE_BUILD, "Bar": ctxpb.Stage_SECURITY} {
+		addr, cleanup, err := testutil.CreatekRPCTestServer(&fakeDispatcher{categories: []string{cat}, files: []string{"A.cc"}}, "AnalyzerService")
+		if err != nil {
+			t.Fatalf("Registering analyzer service failed: %v", err)
+		}
+		defer cleanup()
+		services = append(services, serviceInfo{addr, strset.New(cat), stage})
+	}
+	driver := NewTestDriver(services)
+	ctx := &ctxpb.ShipshapeContext{FilePath: []string{"A.cc"}}
+	var cats []string
+	for _, ar := range driver.callAllAnalyzers(strset.New("Foo", "Bar"), ctx, ctxpb.Stage_SECURITY, nil) {
+		for _, n := range ar.Note {
+			cats = append(cats, n.GetCategory())
+		}
+	}
+	if want := []string{"Bar"}; !reflect.DeepEqual(cats, want) {
+		t.Errorf("Wrong categories analyzed in the SECURITY stage: got %v, want %v", cats, want)
+	}
+}
+
 func TestCallAllAnalyzersErrorCases(t *testing.T) {
 	ctx := &ctxpb.ShipshapeContext{FilePath: []string{"dir1/A", "dir2/B"}}
 
@@ -281,7 +411,7 @@ func TestCallAllAnalyzersErrorCases(t *testing.T) {
 			serviceInfo{addr, strset.New("Foo"), ctxpb.Stage_PRE_BUILD},
 		})
 
-		ars := driver.callAllAnalyzers(strset.New("Foo"), ctx, ctxpb.Stage_PRE_BUILD)
+		ars := driver.callAllAnalyzers(strset.New("Foo"), ctx, ctxpb.Stage_PRE_BUILD, nil)
 		var notes []*notepb.Note
 		var failures []*rpcpb.AnalysisFailure
 
@@ -302,6 +432,163 @@ func TestCallAllAnalyzersErrorCases(t *testing.T) {
 	}
 }
 
+func TestCallAllAnalyzersRetries(t *testing.T) {
+	ctx := &ctxpb.ShipshapeContext{FilePath: []string{"dir1/A"}}
+	noRetries := &config{analyzers: []*configpb.AnalyzerConfig{{Category: proto.String("Bar"), Retries: proto.Int32(0)}}}
+
+	tests := []struct {
+		label         string
+		failures      int
+		cfg           *config
+		expectNotes   int
+		expectFailure []string
+		expectRetry   map[string]int32
+	}{
+		{"transient failure", 1, nil, 2, nil, map[string]int32{"Foo": 2, "Bar": 2}},
+		{"persistent failure", 2, nil, 0, []string{"Foo", "Bar"}, map[string]int32{"Foo": 2, "Bar": 2}},
+		{"retries disabled", 1, noRetries, 1, []string{"Bar"}, map[string]int32{"Foo": 2}},
+	}
+	for _, test := range tests {
+		addr, cleanup, err := testutil.CreatekRPCTestServer(&flakyDispatcher{test.failures, make(map[string]int)}, "AnalyzerService")
+		if err != nil {
+			t.Fatalf("Registering analyzer service failed: %v", err)
+		}
+		defer cleanup()
+
+		driver := NewTestDriver([]serviceInfo{
+			serviceInfo{addr, strset.New("Foo", "Bar"), ctxpb.Stage_PRE_BUILD},
+		})
+		ars := driver.callAllAnalyzers(strset.New("Foo", "Bar"), ctx, ctxpb.Stage_PRE_BUILD, test.cfg)
+		if len(ars) != 1 {
+			t.Fatalf("Wrong number of responses for %q: got %v, want 1", test.label, ars)
+		}
+		ar := ars[0]
+		if len(ar.Note) != test.expectNotes {
+			t.Errorf("Wrong number of notes for %q: got %v, want %d", test.label, ar.Note, test.expectNotes)
+		}
+		var failed []string
+		for _, f := range ar.Failure {
+			failed = append(failed, f.GetCategory())
+		}
+		if !strset.Equal(failed, test.expectFailure) {
+			t.Errorf("Wrong failures for %q: got %v, want %v", test.label, failed, test.expectFailure)
+		}
+		retries := make(map[string]int32)
+		for _, r := range ar.Retry {
+			retries[r.GetCategory()] = r.GetAttempts()
+		}
+		if !reflect.DeepEqual(retries, test.expectRetry) {
+			t.Errorf("Wrong retries for %q: got %v, want %v", test.label, retries, test.expectRetry)
+		}
+	}
+}
+
+func TestReplaceCategoryCoverage(t *testing.T) {
+	resp := &rpcpb.AnalyzeResponse{
+		Failure: []*rpcpb.AnalysisFailure{{Category: proto.String("Bar")}},
+		Coverage: []*rpcpb.AnalysisCoverage{
+			{Category: proto.String("Foo"), File: []string{"a.go"}},
+		},
+	}
+	update := &rpcpb.AnalyzeResponse{
+		Coverage: []*rpcpb.AnalysisCoverage{
+			{Category: proto.String("Bar"), File: []string{"a.go", "b.go"}},
+		},
+	}
+	got := replaceCategory(resp, "Bar", update)
+	if len(got.Failure) != 0 {
+		t.Errorf("Failure of the replaced category was kept: %v", got.Failure)
+	}
+	var cats []string
+	for _, c := range got.Coverage {
+		cats = append(cats, c.GetCategory())
+	}
+	if want := []string{"Foo", "Bar"}; !reflect.DeepEqual(cats, want) {
+		t.Errorf("Wrong coverage categories: got %v, want %v", cats, want)
+	}
+}
+
+func TestCallWithinBudget(t *testing.T) {
+	ctx := &ctxpb.ShipshapeContext{FilePath: []string{"dir1/A"}}
+	fast := &slowDispatcher{delay: 0}
+	slow := &slowDispatcher{delay: time.Second}
+	fastAddr, cleanup, err := testutil.CreatekRPCTestServer(fast, "AnalyzerService")
+	if err != nil {
+		t.Fatalf("Registering analyzer service failed: %v", err)
+	}
+	defer cleanup()
+	slowAddr, cleanup, err := testutil.CreatekRPCTestServer(slow, "AnalyzerService")
+	if err != nil {
+		t.Fatalf("Registering analyzer service failed: %v", err)
+	}
+	defer cleanup()
+	driver := NewTestDriver([]serviceInfo{
+		serviceInfo{fastAddr, strset.New("Fast"), ctxpb.Stage_PRE_BUILD},
+		serviceInfo{slowAddr, strset.New("Slow"), ctxpb.Stage_PRE_BUILD},
+	})
+
+	tests := []struct {
+		label        string
+		slowPriority int32
+		expectNotes  []string
+		expectFailed []string
+		expectCalls  int32
+	}{
+		{"slow category last", -1, []string{"Fast"}, []string{"Slow"}, 1},
+		{"slow category first", 1, nil, []string{"Fast", "Slow"}, 0},
+	}
+	for _, test := range tests {
+		atomic.StoreInt32(&fast.calls, 0)
+		cfg := &config{analyzers: []*configpb.AnalyzerConfig{{Category: proto.String("Slow"), Priority: proto.Int32(test.slowPriority)}}}
+		ars := driver.callWithinBudget(strset.New("Fast", "Slow"), ctx, ctxpb.Stage_PRE_BUILD, cfg, time.Now().Add(100*time.Millisecond))
+
+		var notes, failed []string
+		for _, ar := range ars {
+			for _, n := range ar.Note {
+				notes = append(notes, n.GetCategory())
+			}
+			for _, f := range ar.Failure {
+				failed = append(failed, f.GetCategory())
+			}
+		}
+		if !reflect.DeepEqual(notes, test.expectNotes) {
+			t.Errorf("Wrong notes for %q: got %v, want %v", test.label, notes, test.expectNotes)
+		}
+		if !reflect.DeepEqual(failed, test.expectFailed) {
+			t.Errorf("Wrong failures for %q: got %v, want %v", test.label, failed, test.expectFailed)
+		}
+		if calls := atomic.LoadInt32(&fast.calls); calls != test.expectCalls {
+			t.Errorf("Wrong number of calls to the fast analyzer for %q: got %d, want %d", test.label, calls, test.expectCalls)
+		}
+	}
+}
+
+func TestCallAllAnalyzersParallelism(t *testing.T) {
+	ctx := &ctxpb.ShipshapeContext{FilePath: []string{"dir1/A"}}
+	counter := &concurrencyCounter{}
+	var services []serviceInfo
+	for _, cat := range []string{"A", "B", "C", "D"} {
+		addr, cleanup, err := testutil.CreatekRPCTestServer(countingDispatcher{counter}, "AnalyzerService")
+		if err != nil {
+			t.Fatalf("Registering analyzer service failed: %v", err)
+		}
+		defer cleanup()
+		services = append(services, serviceInfo{addr, strset.New(cat), ctxpb.Stage_PRE_BUILD})
+	}
+
+	for _, parallelism := range []int{1, 2} {
+		counter.most = 0
+		driver := NewTestDriver(services)
+		driver.parallelism = parallelism
+		if got := len(driver.callAllAnalyzers(strset.New("A", "B", "C", "D"), ctx, ctxpb.Stage_PRE_BUILD, nil)); got != 4 {
+			t.Errorf("Wrong number of responses with parallelism %d: got %d, want 4", parallelism, got)
+		}
+		if counter.most > parallelism {
+			t.Errorf("Too many analyzers ran at once with parallelism %d: got %d", parallelism, counter.most)
+		}
+	}
+}
+
 func TestFilterPaths(t *testing.T) {
 	tests := []struct {
 		label         string
@@ -400,3 +687,140 @@ func TestFindCompilationUnitsError(t *testing.T) {
 	}
 }
 */
+
+// routingDispatcher records the files it is asked to analyze for each category.
+type routingDispatcher struct {
+	fakeDispatcher
+	mu    sync.Mutex
+	files map[string][]string
+}
+
+func (r *routingDispatcher) Analyze(ctx server.Context, in *rpcpb.AnalyzeRequest) (*rpcpb.AnalyzeResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, cat := range in.Category {
+		r.files[cat] = in.ShipshapeContext.FilePath
+	}
+	return &rpcpb.AnalyzeResponse{}, nil
+}
+
+func TestCallAllAnalyzersRouting(t *testing.T) {
+	rawCfg, err := unmarshalConfigBytes([]byte(`
+events:
+  - event: default
+    categories: [Foo, Bar, Baz]
+analyzers:
+  - category: Foo
+    paths: [//java/...]
+  - category: Bar
+    exclude: [third_party/]
+  - category: Baz
+    paths: [docs/]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := buildConfig(rawCfg, "default")
+
+	d := &routingDispatcher{fakeDispatcher: fakeDispatcher{categories: []string{"Foo", "Bar", "Baz"}}, files: make(map[string][]string)}
+	addr, cleanup, err := testutil.CreatekRPCTestServer(d, "AnalyzerService")
+	if err != nil {
+		t.Fatalf("Registering analyzer service failed: %v", err)
+	}
+	defer cleanup()
+	driver := NewTestDriver([]serviceInfo{{addr, strset.New("Foo", "Bar", "Baz"), ctxpb.Stage_PRE_BUILD}})
+	ctx := &ctxpb.ShipshapeContext{FilePath: []string{"java/A.java", "third_party/B.java", "lib/C.py"}}
+	driver.callAllAnalyzers(strset.New("Foo", "Bar", "Baz"), ctx, ctxpb.Stage_PRE_BUILD, cfg)
+
+	want := map[string][]string{
+		"Foo": {"java/A.java"},
+		"Bar": {"java/A.java", "lib/C.py"},
+	}
+	if !reflect.DeepEqual(d.files, want) {
+		t.Errorf("Wrong files analyzed: got %v, want %v", d.files, want)
+	}
+}
+
+func TestCallAllAnalyzersProgress(t *testing.T) {
+	ctx := &ctxpb.ShipshapeContext{FilePath: []string{"dir1/A", "dir1/B"}}
+	var services []serviceInfo
+	// Foo is analyzed by two analyzers, so it is done after both of them.
+	for _, cat := range []string{"Foo", "Bar", "Foo"} {
+		addr, cleanup, err := testutil.CreatekRPCTestServer(&fakeDispatcher{categories: []string{cat}}, "AnalyzerService")
+		if err != nil {
+			t.Fatalf("Registering analyzer service failed: %v", err)
+		}
+		defer cleanup()
+		services = append(services, serviceInfo{addr, strset.New(cat), ctxpb.Stage_PRE_BUILD})
+	}
+	driver := NewTestDriver(services)
+	progress := make(chan *rpcpb.ShipshapeResponse, 10)
+	driver.progress = progress
+	driver.callAllAnalyzers(strset.New("Foo", "Bar"), ctx, ctxpb.Stage_PRE_BUILD, nil)
+	close(progress)
+
+	var got []string
+	for msg := range progress {
+		if len(msg.AnalyzeResponse) > 0 {
+			t.Errorf("Progress response has results: %v", msg)
+		}
+		for _, p := range msg.Progress {
+			got = append(got, fmt.Sprintf("%s %d/%d", p.GetCategory(), p.GetAnalyzersCompleted(), p.GetAnalyzersTotal()))
+		}
+	}
+	if len(got) != 5 {
+		t.Fatalf("Wrong progress: got %v, want the start of each category and the end of each analyzer", got)
+	}
+	if want := []string{"Bar 0/1", "Foo 0/2"}; !reflect.DeepEqual(got[:2], want) {
+		t.Errorf("Wrong starting progress: got %v, want %v", got[:2], want)
+	}
+	end := got[2:]
+	sort.Strings(end)
+	if want := []string{"Bar 1/1", "Foo 1/2", "Foo 2/2"}; !reflect.DeepEqual(end, want) {
+		t.Errorf("Wrong final progress: got %v, want %v", end, want)
+	}
+}
+
+func TestCallAllAnalyzersStreamResults(t *testing.T) {
+	ctx := &ctxpb.ShipshapeContext{FilePath: []string{"dir1/A"}}
+	var services []serviceInfo
+	for _, cat := range []string{"Foo", "Bar"} {
+		addr, cleanup, err := testutil.CreatekRPCTestServer(&fakeDispatcher{categories: []string{cat}, files: []string{"dir1/A"}}, "AnalyzerService")
+		if err != nil {
+			t.Fatalf("Registering analyzer service failed: %v", err)
+		}
+		defer cleanup()
+		services = append(services, serviceInfo{addr, strset.New(cat), ctxpb.Stage_PRE_BUILD})
+	}
+	driver := NewTestDriver(services)
+	results := make(chan *rpcpb.ShipshapeResponse, 10)
+	driver.results = results
+	driver.streamed = make(map[*rpcpb.AnalyzeResponse]bool)
+	ars := driver.callAllAnalyzers(strset.New("Foo", "Bar"), ctx, ctxpb.Stage_PRE_BUILD, nil)
+	close(results)
+
+	var got []string
+	for msg := range results {
+		if len(msg.AnalyzeResponse) != 1 {
+			t.Errorf("Streamed response does not have the results of one analyzer: %v", msg)
+		}
+		for _, ar := range msg.AnalyzeResponse {
+			if !driver.streamed[ar] {
+				t.Errorf("Streamed results not recorded as streamed: %v", ar)
+			}
+			if ar.GetStage() != ctxpb.Stage_PRE_BUILD {
+				t.Errorf("Streamed results have the wrong stage: got %v, want PRE_BUILD", ar.GetStage())
+			}
+			for _, n := range ar.Note {
+				got = append(got, n.GetCategory())
+			}
+		}
+	}
+	sort.Strings(got)
+	if want := []string{"Bar", "Foo"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong streamed notes: got %v, want %v", got, want)
+	}
+	if len(ars) != 2 {
+		t.Errorf("Wrong number of results returned: got %d, want 2", len(ars))
+	}
+}
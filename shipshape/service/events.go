@@ -0,0 +1,133 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	configpb "github.com/google/shipshape/shipshape/proto/shipshape_config_proto"
+	strset "github.com/google/shipshape/shipshape/util/strings"
+)
+
+// The built-in events. An event names the situation shipshape is run in, and
+// selects the categories configured for it in the events section of the
+// configuration file.
+const (
+	// EventManual is a run started by hand from the command line.
+	EventManual = "manual"
+	// EventPresubmit is a run on a change before it is submitted.
+	EventPresubmit = "presubmit"
+	// EventPostsubmit is a run on a change after it is submitted.
+	EventPostsubmit = "postsubmit"
+	// EventCI is a run in a continuous integration build.
+	EventCI = "ci"
+	// EventEditor is a run on the files open in an editor.
+	EventEditor = "editor"
+)
+
+// EventInfo describes a built-in event.
+type EventInfo struct {
+	Name        string
+	Description string
+}
+
+// BuiltInEvents are the events that are always supported, in the order they
+// are listed.
+var BuiltInEvents = []EventInfo{
+	{EventManual, "a run started by hand from the command line"},
+	{EventPresubmit, "a run on a change before it is submitted"},
+	{EventPostsubmit, "a run on a change after it is submitted"},
+	{EventCI, "a run in a continuous integration build"},
+	{EventEditor, "a run on the files open in an editor"},
+}
+
+// IsBuiltInEvent returns whether name is one of the BuiltInEvents.
+func IsBuiltInEvent(name string) bool {
+	for _, e := range BuiltInEvents {
+		if e.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CustomEvents retrieves the custom events declared in the global section of
+// the configuration file in the directory at path.
+func CustomEvents(path string) ([]string, error) {
+	cfg, err := loadConfig(filepath.Join(path, configFilename), "")
+	if err != nil || cfg == nil {
+		return nil, err
+	}
+	return cfg.events, nil
+}
+
+// ValidateEvent checks that event is a built-in event or a custom event
+// declared in the configuration file in the directory at path. The empty
+// event, which selects the default categories, is always valid.
+func ValidateEvent(path, event string) error {
+	if event == "" || IsBuiltInEvent(event) {
+		return nil
+	}
+	custom, err := CustomEvents(path)
+	if err != nil {
+		return err
+	}
+	if strset.Contains(custom, event) {
+		return nil
+	}
+	return fmt.Errorf("unknown event %q: must be one of %s, or a custom event declared in the events list of the global section of %s",
+		event, strings.Join(builtInEventNames(), ", "), filepath.Join(path, configFilename))
+}
+
+// validateEvents checks that every event configured in rawConfig, and every
+// event run by a pipeline, is a built-in event, the default, or declared in
+// the global section.
+func validateEvents(rawConfig *configpb.ShipshapeConfig) error {
+	custom := rawConfig.GetGlobal().GetEvents()
+	for i, name := range custom {
+		if name == "" {
+			return fmt.Errorf("Custom event at index %v is missing a name", i)
+		}
+		if name == defaultName || IsBuiltInEvent(name) {
+			return fmt.Errorf("Custom event %q is already a built-in event", name)
+		}
+	}
+	for _, ec := range rawConfig.Events {
+		name := ec.GetEvent()
+		if name != defaultName && !IsBuiltInEvent(name) && !strset.Contains(custom, name) {
+			return fmt.Errorf("Event %q is not a built-in event (%s); declare it in the events list of the global section",
+				name, strings.Join(builtInEventNames(), ", "))
+		}
+	}
+	for _, p := range rawConfig.Pipelines {
+		if name := p.GetEvent(); p.Event != nil && !IsBuiltInEvent(name) && !strset.Contains(custom, name) {
+			return fmt.Errorf("Pipeline %q has event %q, which is not a built-in event (%s); declare it in the events list of the global section",
+				p.GetName(), name, strings.Join(builtInEventNames(), ", "))
+		}
+	}
+	return nil
+}
+
+func builtInEventNames() []string {
+	var names []string
+	for _, e := range BuiltInEvents {
+		names = append(names, e.Name)
+	}
+	return names
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfigEvents(t *testing.T) {
+	tests := []struct {
+		label string
+		yaml  string
+		err   string
+	}{
+		{
+			"Built-in and default events",
+			`
+events:
+  - event: default
+    categories: [go vet]
+  - event: presubmit
+    categories: [go vet, JSHint]
+  - event: editor
+    categories: [JSHint]`,
+			"",
+		},
+		{
+			"Declared custom event",
+			`
+global:
+  events: [deploy]
+events:
+  - event: deploy
+    categories: [Loadtest]`,
+			"",
+		},
+		{
+			"Undeclared custom event",
+			`
+events:
+  - event: deploy
+    categories: [Loadtest]`,
+			`Event "deploy" is not a built-in event (manual, presubmit, postsubmit, ci, editor); declare it in the events list of the global section`,
+		},
+		{
+			"Custom event shadowing a built-in one",
+			`
+global:
+  events: [ci]
+events:
+  - event: ci
+    categories: [Loadtest]`,
+			`Custom event "ci" is already a built-in event`,
+		},
+	}
+
+	for _, test := range tests {
+		rawCfg, err := unmarshalConfigBytes([]byte(test.yaml))
+		if err != nil {
+			t.Fatalf("Error in %q: %v", test.label, err)
+		}
+		err = validateConfig(rawCfg)
+		if test.err == "" && err != nil {
+			t.Errorf("Unexpected error for %q: %v", test.label, err)
+		} else if test.err != "" && (err == nil || err.Error() != test.err) {
+			t.Errorf("Wrong error for %q: got %v, want %q", test.label, err, test.err)
+		}
+	}
+}
+
+func TestValidateEvent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	yaml := "global:\n  events: [deploy]\nevents:\n  - event: deploy\n    categories: [Loadtest]\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, configFilename), []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, event := range []string{"", EventManual, EventCI, "deploy"} {
+		if err := ValidateEvent(dir, event); err != nil {
+			t.Errorf("Unexpected error for event %q: %v", event, err)
+		}
+	}
+	err = ValidateEvent(dir, "Manual")
+	if err == nil || !strings.Contains(err.Error(), "unknown event \"Manual\"") {
+		t.Errorf("Wrong error for an unknown event: got %v", err)
+	}
+
+	custom, err := CustomEvents(dir)
+	if err != nil || len(custom) != 1 || custom[0] != "deploy" {
+		t.Errorf("Wrong custom events: got %v, %v, want [deploy]", custom, err)
+	}
+	if err := ValidateEvent(filepath.Join(dir, "missing"), "deploy"); err == nil {
+		t.Errorf("Expected an error for a custom event without a config file")
+	}
+}
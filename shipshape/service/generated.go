@@ -0,0 +1,98 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// GeneratedSkip leaves generated files out of the analysis.
+	GeneratedSkip = "skip"
+	// GeneratedAnalyze analyzes generated files like the others.
+	GeneratedAnalyze = "analyze"
+
+	// generatedHeader is how much of the start of a file is searched for a marker.
+	generatedHeader = 1024
+	// minifiedSample is how much of the start of a JavaScript or CSS file is read to
+	// tell whether it is minified.
+	minifiedSample = 64 * 1024
+	// minifiedLineLength is the average length of the lines of minified code, above
+	// which no person would write them.
+	minifiedLineLength = 250
+)
+
+// generatedSuffixes end the names of files written by code generators, e.g. protoc,
+// and minifiers.
+var generatedSuffixes = []string{"_pb2.py", "_pb2_grpc.py", ".pb.go", ".pb.gw.go", ".pb.cc", ".pb.h", ".min.js", ".min.css"}
+
+// generatedMarkers are put in the header of the files they write by most code
+// generators: "DO NOT EDIT" by Go's and many others, "@generated" by others.
+var generatedMarkers = [][]byte{[]byte("DO NOT EDIT"), []byte("@generated")}
+
+// isGenerated reports whether the file at path looks generated: by its name, by a
+// marker in its header, or, for JavaScript and CSS, by being minified. A file that
+// can not be read is not taken to be generated, so that the analyzers report it.
+func isGenerated(path string) bool {
+	for _, suffix := range generatedSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	ext := filepath.Ext(path)
+	minifiable := ext == ".js" || ext == ".css"
+	size := generatedHeader
+	if minifiable {
+		size = minifiedSample
+	}
+	buf := make([]byte, size)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false
+	}
+	buf = buf[:n]
+	header := buf
+	if len(header) > generatedHeader {
+		header = header[:generatedHeader]
+	}
+	for _, marker := range generatedMarkers {
+		if bytes.Contains(header, marker) {
+			return true
+		}
+	}
+	return minifiable && len(buf)/(bytes.Count(buf, []byte("\n"))+1) > minifiedLineLength
+}
+
+// filterGenerated drops the files, relative to root, that look generated.
+func filterGenerated(root string, files []string) []string {
+	var kept []string
+	for _, f := range files {
+		if !isGenerated(filepath.Join(root, f)) {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
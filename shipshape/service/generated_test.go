@@ -0,0 +1,75 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFilesToAnalyzeSkipsGenerated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"main.go":         "package main\n",
+		"api/api.pb.go":   "package api\n",
+		"api/api_pb2.py":  "import sys\n",
+		"gen/types.go":    "// Code generated by stringer; DO NOT EDIT.\n\npackage gen\n",
+		"gen/Parser.java": "/* @generated by antlr */\nclass Parser {}\n",
+		"web/app.js":      "function f() {\n  return 1;\n}\n",
+		"web/vendor.js":   strings.Repeat("var a=1;", 200) + "\n",
+		"web/lib.min.js":  "var a=1;\n",
+		"docs/README.md":  strings.Repeat("A long line of prose with no newlines. ", 20) + "\n",
+		"late/marker.py":  strings.Repeat("# comment\n", 200) + "# DO NOT EDIT\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := FilesToAnalyze(dir, nil)
+	if err != nil {
+		t.Fatalf("FilesToAnalyze failed: %v", err)
+	}
+	want := []string{"docs/README.md", "late/marker.py", "main.go", "web/app.js"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong files with generated ones skipped: got %v, want %v", got, want)
+	}
+
+	config := "global:\n  generated: analyze\nevents:\n  - event: default\n    categories: [go vet]\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, configFilename), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = FilesToAnalyze(dir, nil); err != nil {
+		t.Fatalf("FilesToAnalyze failed: %v", err)
+	}
+	if len(got) != len(files) {
+		t.Errorf("Wrong files with generated ones analyzed: got %v, want all %d", got, len(files))
+	}
+}
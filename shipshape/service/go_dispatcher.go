@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"path/filepath"
 
 	"github.com/google/shipshape/shipshape/analyzers/codealert"
 	"github.com/google/shipshape/shipshape/analyzers/govet"
@@ -35,7 +36,9 @@ import (
 )
 
 var (
-	servicePort = flag.Int("port", 10005, "Service port")
+	servicePort   = flag.Int("port", 10005, "Service port")
+	deterministic = flag.Bool("deterministic", false, "Ignore the analyzer configuration in the repository, and use the fixed configuration in --config_dir instead")
+	configDir     = flag.String("config_dir", "/deterministic", "Directory with the pylintrc and jshintrc to use with --deterministic")
 )
 
 func main() {
@@ -43,12 +46,20 @@ func main() {
 
 	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds | log.Lshortfile)
 
+	jsa, pya := new(jshint.JSHintAnalyzer), new(pylint.PyLintAnalyzer)
+	if *deterministic {
+		// The versions of the analyzers are fixed by the image, so with a fixed
+		// configuration the results only depend on the code.
+		log.Printf("Running deterministically with the configuration in %s", *configDir)
+		jsa.Config = filepath.Join(*configDir, "jshintrc")
+		pya.RCFile = filepath.Join(*configDir, "pylintrc")
+	}
 	analyzers := []api.Analyzer{
 		new(postmessage.PostMessageAnalyzer),
 		new(wordcount.WordCountAnalyzer),
-		new(jshint.JSHintAnalyzer),
+		jsa,
 		new(codealert.CodeAlertAnalyzer),
-		new(pylint.PyLintAnalyzer),
+		pya,
 		new(govet.GoVetAnalyzer),
 	}
 	analyzerService := api.CreateAnalyzerService(analyzers, ctxpb.Stage_PRE_BUILD)
@@ -0,0 +1,196 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/shipshape/shipshape/util/rpc/server"
+
+	contextpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// RESTPrefix is the path that the REST API is served under, next to the
+// K-RPC endpoint.
+const RESTPrefix = "/v1/"
+
+// RESTAnalyzeRequest is the body of POST /v1/analyze.
+type RESTAnalyzeRequest struct {
+	// Root is the directory to analyze. A relative path is relative to the
+	// workspace the handler was created with.
+	Root string `json:"root"`
+	// Files are the files to analyze, relative to Root. If empty, all files
+	// are analyzed.
+	Files []string `json:"files"`
+	// Categories are the categories to run. If empty, the categories come
+	// from the config file in Root for Event.
+	Categories []string `json:"categories"`
+	// Event defaults to manual.
+	Event string `json:"event"`
+	// Stage is one of the stages, e.g. PRE_BUILD or POST_BUILD, and defaults
+	// to PRE_BUILD.
+	Stage string `json:"stage"`
+}
+
+// RESTCategory is an entry in the response to GET /v1/categories.
+type RESTCategory struct {
+	Name     string `json:"name"`
+	Analyzer string `json:"analyzer"`
+	Stage    string `json:"stage"`
+}
+
+type restCategories struct {
+	Categories []RESTCategory `json:"categories"`
+}
+
+type restError struct {
+	Error string `json:"error"`
+}
+
+// restHandler serves the REST API for a driver.
+type restHandler struct {
+	driver    *ShipshapeDriver
+	workspace string
+}
+
+// NewRESTHandler returns a handler serving a REST+JSON API for driver under
+// RESTPrefix, for integrations that would rather not speak K-RPC:
+//
+//	POST /v1/analyze with a RESTAnalyzeRequest runs the analysis and
+//	responds with the ShipshapeResponse as JSON, in the format written by
+//	shipshape --json_output.
+//	GET /v1/categories lists the categories the analyzers provide.
+//
+// Errors are sent as a JSON object with an "error" field.
+func NewRESTHandler(driver *ShipshapeDriver, workspace string) http.Handler {
+	h := &restHandler{driver, workspace}
+	mux := http.NewServeMux()
+	mux.HandleFunc(RESTPrefix+"analyze", h.analyze)
+	mux.HandleFunc(RESTPrefix+"categories", h.categories)
+	mux.HandleFunc(RESTPrefix, func(w http.ResponseWriter, r *http.Request) {
+		writeRESTError(w, http.StatusNotFound, fmt.Errorf("no such method %s", r.URL.Path))
+	})
+	return mux
+}
+
+func (h *restHandler) analyze(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeRESTError(w, http.StatusMethodNotAllowed, fmt.Errorf("method must be POST"))
+		return
+	}
+	var in RESTAnalyzeRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeRESTError(w, http.StatusBadRequest, fmt.Errorf("could not parse request: %v", err))
+		return
+	}
+	req, err := h.request(in)
+	if err != nil {
+		writeRESTError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	out := make(chan *rpcpb.ShipshapeResponse)
+	done := make(chan error, 1)
+	go func() {
+		done <- h.driver.Run(server.Map{}, req, out)
+		close(out)
+	}()
+	var resp rpcpb.ShipshapeResponse
+	for msg := range out {
+		resp.AnalyzeResponse = append(resp.AnalyzeResponse, msg.AnalyzeResponse...)
+	}
+	if err := <-done; err != nil {
+		log.Printf("REST analysis of %s failed: %v", req.ShipshapeContext.GetRepoRoot(), err)
+		writeRESTError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeREST(w, &resp)
+}
+
+// request converts in to a ShipshapeRequest.
+func (h *restHandler) request(in RESTAnalyzeRequest) (*rpcpb.ShipshapeRequest, error) {
+	root := in.Root
+	if !filepath.IsAbs(root) {
+		root = filepath.Join(h.workspace, root)
+	}
+	event := in.Event
+	if event == "" {
+		event = EventManual
+	}
+	stage := contextpb.Stage_PRE_BUILD
+	if in.Stage != "" {
+		var err error
+		if stage, err = ParseStage(in.Stage); err != nil {
+			return nil, err
+		}
+	}
+	return &rpcpb.ShipshapeRequest{
+		TriggeredCategory: in.Categories,
+		Event:             proto.String(event),
+		Stage:             stage.Enum(),
+		ShipshapeContext: &contextpb.ShipshapeContext{
+			RepoRoot: proto.String(root),
+			FilePath: in.Files,
+		},
+	}, nil
+}
+
+func (h *restHandler) categories(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeRESTError(w, http.StatusMethodNotAllowed, fmt.Errorf("method must be GET"))
+		return
+	}
+	resp := restCategories{Categories: []RESTCategory{}}
+	for analyzer, info := range h.driver.getAllServiceInfo() {
+		for cat := range info.categories {
+			resp.Categories = append(resp.Categories, RESTCategory{cat, analyzer, info.stage.String()})
+		}
+	}
+	sort.Sort(byCategoryName(resp.Categories))
+	writeREST(w, resp)
+}
+
+type byCategoryName []RESTCategory
+
+func (c byCategoryName) Len() int      { return len(c) }
+func (c byCategoryName) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c byCategoryName) Less(i, j int) bool {
+	if c[i].Name != c[j].Name {
+		return c[i].Name < c[j].Name
+	}
+	return c[i].Analyzer < c[j].Analyzer
+}
+
+func writeREST(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Could not write REST response: %v", err)
+	}
+}
+
+func writeRESTError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(restError{err.Error()})
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	testutil "github.com/google/shipshape/shipshape/util/test"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func TestRESTAnalyze(t *testing.T) {
+	dispatcher := &fakeDispatcher{categories: []string{"Foo"}, files: []string{"A.cc"}}
+	addr, cleanup, err := testutil.CreatekRPCTestServer(dispatcher, "AnalyzerService")
+	if err != nil {
+		t.Fatalf("Registering analyzer service failed: %v", err)
+	}
+	defer cleanup()
+
+	workspace, err := ioutil.TempDir("", "rest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(workspace)
+	if err := os.Mkdir(filepath.Join(workspace, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(NewRESTHandler(NewDriver([]string{addr}), workspace))
+	defer server.Close()
+
+	r, err := http.Post(server.URL+"/v1/analyze", "application/json", strings.NewReader(`{"root": "src", "files": ["A.cc"], "categories": ["Foo"]}`))
+	if err != nil {
+		t.Fatalf("Could not call analyze: %v", err)
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		t.Fatalf("Wrong status: got %s, want 200 OK", r.Status)
+	}
+	var resp rpcpb.ShipshapeResponse
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	var notes []string
+	for _, ar := range resp.AnalyzeResponse {
+		for _, n := range ar.Note {
+			notes = append(notes, n.GetCategory()+":"+n.GetLocation().GetPath())
+		}
+	}
+	if want := []string{"Foo:A.cc"}; !reflect.DeepEqual(notes, want) {
+		t.Errorf("Wrong notes: got %v, want %v", notes, want)
+	}
+}
+
+func TestRESTCategories(t *testing.T) {
+	addrFoo, cleanupFoo, err := testutil.CreatekRPCTestServer(&fakeDispatcher{categories: []string{"Foo", "Bar"}}, "AnalyzerService")
+	if err != nil {
+		t.Fatalf("Registering analyzer service failed: %v", err)
+	}
+	defer cleanupFoo()
+
+	server := httptest.NewServer(NewRESTHandler(NewDriver([]string{addrFoo}), ""))
+	defer server.Close()
+
+	r, err := http.Get(server.URL + "/v1/categories")
+	if err != nil {
+		t.Fatalf("Could not list categories: %v", err)
+	}
+	defer r.Body.Close()
+	var got restCategories
+	if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	analyzer := strings.TrimPrefix(addrFoo, "http://")
+	want := restCategories{[]RESTCategory{
+		{"Bar", analyzer, "PRE_BUILD"},
+		{"Foo", analyzer, "PRE_BUILD"},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong categories: got %v, want %v", got, want)
+	}
+}
+
+func TestRESTErrors(t *testing.T) {
+	server := httptest.NewServer(NewRESTHandler(NewDriver(nil), ""))
+	defer server.Close()
+
+	tests := []struct {
+		desc, method, path, body string
+		code                     int
+	}{
+		{"analyze with GET", "GET", "/v1/analyze", "", http.StatusMethodNotAllowed},
+		{"malformed request", "POST", "/v1/analyze", "{", http.StatusBadRequest},
+		{"unknown stage", "POST", "/v1/analyze", `{"stage": "LATER"}`, http.StatusBadRequest},
+		{"categories with POST", "POST", "/v1/categories", "", http.StatusMethodNotAllowed},
+		{"unknown method", "GET", "/v1/nothing", "", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, server.URL+test.path, strings.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("%s: request failed: %v", test.desc, err)
+			continue
+		}
+		var body restError
+		json.NewDecoder(r.Body).Decode(&body)
+		r.Body.Close()
+		if r.StatusCode != test.code || body.Error == "" {
+			t.Errorf("%s: got %d %q, want %d with an error", test.desc, r.StatusCode, body.Error, test.code)
+		}
+	}
+}
@@ -37,10 +37,10 @@ var (
 	// TODO(supertri): add a stringList flag option
 	analyzers    = flag.String("analyzer_services", "localhost:10005,localhost:10006,localhost:10008", "Addresses of analyzer services (comma-separated)")
 	startService = flag.Bool("start_service", false, "Start a shipshape service, if false we use streams to handle requests (stdin/stdout)")
-)
-
-const (
-	serviceName = "ShipshapeService"
+	streams      = flag.Bool("streams", false, "Serve K-RPC requests on stdin, writing the responses to stdout (see docs/streams-mode.md)")
+	socket       = flag.String("socket", "", "With --start_service, listen on the unix domain socket at this path instead of on --port")
+	workspace    = flag.String("workspace", "/shipshape-workspace", "Directory that relative roots in REST requests are resolved against")
+	restart      = flag.String("restart_analyzer_command", "", "Command run with sh to restart an analyzer that stops responding mid-run, with its address in $ANALYZER")
 )
 
 func main() {
@@ -65,21 +65,41 @@ func main() {
 	}
 
 	shipshapeService := service.NewDriver(analyzerList)
+	shipshapeService.RestartCommand = *restart
 
 	if *startService {
 		// Start shipshape service
-		s1 := server.Service{Name: serviceName}
+		s1 := server.Service{Name: service.ServiceName}
 		if err := s1.Register(shipshapeService); err != nil {
 			log.Fatalf("Registering shipshape service failed: %v", err)
 		}
-		addr := fmt.Sprintf(":%d", *servicePort)
-		log.Printf("Starting server endpoint at %q with service name %s\n", addr, serviceName)
 		http.Handle("/", server.Endpoint{&s1})
-		if err := http.ListenAndServe(addr, nil); err != nil {
-			log.Fatalf("Server startup failed: %v", err)
+		http.Handle(service.RESTPrefix, service.NewRESTHandler(shipshapeService, *workspace))
+		if *socket != "" {
+			log.Printf("Starting server endpoint at unix socket %s with service name %s\n", *socket, service.ServiceName)
+			l, err := service.ListenUnix(*socket)
+			if err != nil {
+				log.Fatalf("Server startup failed: %v", err)
+			}
+			if err := http.Serve(l, nil); err != nil {
+				log.Fatalf("Server failed: %v", err)
+			}
+		} else {
+			addr := fmt.Sprintf(":%d", *servicePort)
+			log.Printf("Starting server endpoint at %q with service name %s\n", addr, service.ServiceName)
+			if err := http.ListenAndServe(addr, nil); err != nil {
+				log.Fatalf("Server startup failed: %v", err)
+			}
+		}
+	} else if *streams {
+		log.Println("Serving requests on stdin")
+		if err := service.ServeStreams(shipshapeService, os.Stdin, os.Stdout); err != nil {
+			log.Fatalf("Serving streams failed: %v", err)
 		}
 	} else {
-		log.Println("Waiting for stdin. Specify --start_service if you meant to start as a service.")
+		// Bare requests are still accepted for compatibility, but only the
+		// first response is written.
+		log.Println("Waiting for a bare request on stdin. Specify --start_service if you meant to start as a service, or --streams to use the streams protocol.")
 
 		// Read request bytes from stdin
 		requestBytes, err := ioutil.ReadAll(os.Stdin)
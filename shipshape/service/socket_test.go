@@ -0,0 +1,64 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "service.sock")
+
+	// A socket left behind by an earlier listener is replaced.
+	for i := 0; i < 2; i++ {
+		l, err := ListenUnix(path)
+		if err != nil {
+			t.Fatalf("ListenUnix failed on attempt %d: %v", i, err)
+		}
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			t.Errorf("Could not connect on attempt %d: %v", i, err)
+		} else {
+			conn.Close()
+		}
+		if fi, err := os.Stat(path); err != nil {
+			t.Errorf("Could not stat socket: %v", err)
+		} else if got := fi.Mode().Perm(); got != 0666 {
+			t.Errorf("Wrong mode of socket: got %v, want %v", got, os.FileMode(0666))
+		}
+		// Keep the socket file around, as a crashed service would.
+		l.(*net.UnixListener).SetUnlinkOnClose(false)
+		l.Close()
+	}
+
+	other := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(other, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ListenUnix(other); err == nil {
+		t.Errorf("Expected an error listening on a regular file")
+	}
+}
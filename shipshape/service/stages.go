@@ -0,0 +1,49 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	contextpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+)
+
+// StageNames returns the names of the stages that analyzers can register
+// for, in the order they are defined.
+func StageNames() []string {
+	var values []int
+	for v := range contextpb.Stage_name {
+		values = append(values, int(v))
+	}
+	sort.Ints(values)
+	var names []string
+	for _, v := range values {
+		names = append(names, contextpb.Stage_name[int32(v)])
+	}
+	return names
+}
+
+// ParseStage returns the stage called name.
+func ParseStage(name string) (contextpb.Stage, error) {
+	s, ok := contextpb.Stage_value[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown stage %q (must be one of %s)", name, strings.Join(StageNames(), ", "))
+	}
+	return contextpb.Stage(s), nil
+}
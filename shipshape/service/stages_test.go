@@ -0,0 +1,44 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+)
+
+func TestStageNames(t *testing.T) {
+	want := []string{"PRE_BUILD", "POST_BUILD", "DEPENDENCIES", "SECURITY", "DEPLOYMENT_CONFIG"}
+	if got := StageNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong stage names: got %v, want %v", got, want)
+	}
+}
+
+func TestParseStage(t *testing.T) {
+	s, err := ParseStage("SECURITY")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if s != ctxpb.Stage_SECURITY {
+		t.Errorf("Wrong stage: got %v, want %v", s, ctxpb.Stage_SECURITY)
+	}
+	if _, err := ParseStage("security"); err == nil {
+		t.Errorf("Expected an error for a stage in the wrong case")
+	}
+}
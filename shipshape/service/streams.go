@@ -0,0 +1,41 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/google/shipshape/shipshape/util/rpc/server"
+)
+
+// ServiceName is the name that the ShipshapeDriver is served under, so its Run
+// method is "/ShipshapeService/Run".
+const ServiceName = "ShipshapeService"
+
+// ServeStreams serves driver over r and w rather than over HTTP, so that a
+// build system can run the service as a filter. The framing is the same as
+// the HTTP endpoint's: r holds a sequence of JSON-encoded K-RPC requests, and
+// for each one w gets the JSON-encoded responses in order, ending with either
+// a success or an error response. ServeStreams returns when r is exhausted.
+func ServeStreams(driver *ShipshapeDriver, r io.Reader, w io.Writer) error {
+	s := server.Service{Name: ServiceName}
+	if err := s.Register(driver); err != nil {
+		return fmt.Errorf("registering shipshape service failed: %v", err)
+	}
+	return server.Endpoint{&s}.ServePipes(server.Map{}, r, w)
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/shipshape/shipshape/util/rpc/client"
+	testutil "github.com/google/shipshape/shipshape/util/test"
+
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func TestServeStreams(t *testing.T) {
+	dispatcher := &fakeDispatcher{categories: []string{"Foo"}, files: []string{"A.cc"}}
+	addr, cleanup, err := testutil.CreatekRPCTestServer(dispatcher, "AnalyzerService")
+	if err != nil {
+		t.Fatalf("Registering analyzer service failed: %v", err)
+	}
+	defer cleanup()
+
+	root, err := ioutil.TempDir("", "streams")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	var in bytes.Buffer
+	w := client.NewPipeWriter(&in)
+	req := &rpcpb.ShipshapeRequest{
+		TriggeredCategory: []string{"Foo"},
+		Event:             proto.String("manual"),
+		Stage:             ctxpb.Stage_PRE_BUILD.Enum(),
+		ShipshapeContext: &ctxpb.ShipshapeContext{
+			RepoRoot: proto.String(root),
+			FilePath: []string{"A.cc"},
+		},
+	}
+	if err := w.Send("/ShipshapeService/Run", req); err != nil {
+		t.Fatalf("Could not send request: %v", err)
+	}
+	if err := w.Send("/ShipshapeService/NoSuchMethod", req); err != nil {
+		t.Fatalf("Could not send request: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := ServeStreams(NewDriver([]string{addr}), &in, &out); err != nil {
+		t.Fatalf("ServeStreams failed: %v", err)
+	}
+
+	var (
+		resp      rpcpb.ShipshapeResponse
+		notes     int
+		successes int
+		errs      int
+	)
+	err = client.NewPipeReader(&out).Receive(&resp, func(id []byte, err error, success bool) bool {
+		switch {
+		case err != nil:
+			errs++
+		case success:
+			successes++
+		default:
+			for _, ar := range resp.AnalyzeResponse {
+				notes += len(ar.Note)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Could not read responses: %v", err)
+	}
+	if got, want := notes, 1; got != want {
+		t.Errorf("Wrong number of notes: got %d, want %d", got, want)
+	}
+	if got, want := successes, 1; got != want {
+		t.Errorf("Wrong number of successful requests: got %d, want %d", got, want)
+	}
+	if got, want := errs, 1; got != want {
+		t.Errorf("Wrong number of failed requests: got %d, want %d", got, want)
+	}
+}
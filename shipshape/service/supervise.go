@@ -0,0 +1,117 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/shipshape/shipshape/util/rpc/client"
+
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// analyzerProbeTimeout is how long an analyzer has to list its categories after a call
+// to it failed, before it is considered to have stopped responding.
+const analyzerProbeTimeout = 5 * time.Second
+
+// restartMu makes restarts of analyzers happen one at a time, so that requests that find
+// the same analyzer dead do not restart it more than once.
+var restartMu sync.Mutex
+
+// responding returns whether the analyzer at addr answers within analyzerProbeTimeout.
+func responding(addr string) bool {
+	_, err := client.AnalyzerClient{Client: getHTTPClient(addr), Timeout: analyzerProbeTimeout}.GetCategory()
+	return err == nil
+}
+
+// restartAnalyzer runs command with sh to restart the analyzer at addr, which is passed to
+// it in $ANALYZER, and waits for the analyzer to become healthy again. If the analyzer is
+// responding by the time it is its turn, because another request restarted it, it is
+// left alone.
+func restartAnalyzer(command, addr string) error {
+	restartMu.Lock()
+	defer restartMu.Unlock()
+	if responding(addr) {
+		return nil
+	}
+	log.Printf("Restarting analyzer %s", addr)
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(), "ANALYZER="+addr)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("restart command failed: %v: %s", err, bytes.TrimSpace(out))
+	}
+	if err := getHTTPClient(addr).WaitUntilReady(analyzerHealthTimeout); err != nil {
+		return fmt.Errorf("analyzer did not become healthy after restarting: %v", err)
+	}
+	log.Printf("Restarted analyzer %s", addr)
+	return nil
+}
+
+// callAnalyze calls analyze for the specified analyzer with the given request. If the call
+// fails because the analyzer has stopped responding, and the driver has a RestartCommand,
+// the analyzer is restarted and the request is sent to it again, once; the restart is
+// recorded in the response. If anything else goes wrong, it puts an AnalysisFailure for
+// each requested category into the AnalyzeResponse.
+func (sd ShipshapeDriver) callAnalyze(analyzer string, req *rpcpb.AnalyzeRequest) *rpcpb.AnalyzeResponse {
+	c := client.AnalyzerClient{Client: getHTTPClient(analyzer)}
+	resp, err := c.Analyze(req)
+	if err == nil {
+		return resp
+	}
+	if sd.RestartCommand == "" || isCancelled(sd.cancelled) || responding(analyzer) {
+		return analyzerFailures(req.Category, fmt.Sprintf("Error from analyzer %s: %v", analyzer, err))
+	}
+	log.Printf("Analyzer %s stopped responding: %v", analyzer, err)
+	var restarts []*rpcpb.AnalysisRestart
+	for _, cat := range req.Category {
+		restarts = append(restarts, &rpcpb.AnalysisRestart{
+			Category: proto.String(cat),
+			Analyzer: proto.String(analyzer),
+			Reason:   proto.String(err.Error()),
+		})
+	}
+	if rerr := restartAnalyzer(sd.RestartCommand, analyzer); rerr != nil {
+		failed := analyzerFailures(req.Category, fmt.Sprintf("Analyzer %s stopped responding (%v) and could not be restarted: %v", analyzer, err, rerr))
+		failed.Restart = restarts
+		return failed
+	}
+	resp, err = c.Analyze(req)
+	if err != nil {
+		resp = analyzerFailures(req.Category, fmt.Sprintf("Error from analyzer %s after restarting it: %v", analyzer, err))
+	}
+	resp.Restart = append(resp.Restart, restarts...)
+	return resp
+}
+
+// analyzerFailures returns a response with a failure with message for each of cats.
+func analyzerFailures(cats []string, message string) *rpcpb.AnalyzeResponse {
+	failed := &rpcpb.AnalyzeResponse{}
+	for _, cat := range cats {
+		failed.Failure = append(failed.Failure, &rpcpb.AnalysisFailure{
+			Category:       proto.String(cat),
+			FailureMessage: proto.String(message),
+		})
+	}
+	return failed
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package service
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/shipshape/shipshape/util/rpc/server"
+	strset "github.com/google/shipshape/shipshape/util/strings"
+
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// newCrashedAnalyzer starts an analyzer that drops every connection until the file marker
+// exists, as if it had crashed and only came back once restarted.
+func newCrashedAnalyzer(t *testing.T, marker string) (string, func()) {
+	svc := server.Service{Name: "AnalyzerService"}
+	if err := svc.Register(fakeDispatcher{categories: []string{"Crashy"}, files: []string{"dir1/A"}}); err != nil {
+		t.Fatalf("Registering analyzer service failed: %v", err)
+	}
+	endpoint := server.Endpoint{&svc}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := os.Stat(marker); err != nil {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		endpoint.ServeHTTP(w, r)
+	}))
+	return strings.TrimPrefix(s.URL, "http://"), s.Close
+}
+
+func TestCallAnalyzeRestarts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "supervise")
+	if err != nil {
+		t.Fatalf("Could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := &ctxpb.ShipshapeContext{FilePath: []string{"dir1/A"}}
+	req := &rpcpb.AnalyzeRequest{ShipshapeContext: ctx, Category: []string{"Crashy"}}
+
+	tests := []struct {
+		label          string
+		command        string
+		expectNotes    int
+		expectFailure  string
+		expectRestarts int
+	}{
+		{"no restart command", "", 0, "Error from analyzer", 0},
+		{"restart fails", "echo no docker; exit 3", 0, "could not be restarted: restart command failed: exit status 3: no docker", 1},
+		{"restart succeeds", `echo "$ANALYZER" > ` + filepath.Join(dir, "marker"), 1, "", 1},
+	}
+	for _, test := range tests {
+		marker := filepath.Join(dir, "marker")
+		os.Remove(marker)
+		addr, cleanup := newCrashedAnalyzer(t, marker)
+		driver := NewTestDriver([]serviceInfo{{addr, strset.New("Crashy"), ctxpb.Stage_PRE_BUILD}})
+		driver.RestartCommand = test.command
+
+		resp := driver.callAnalyze(addr, req)
+		cleanup()
+		if got := len(resp.Note); got != test.expectNotes {
+			t.Errorf("Wrong number of notes for %q: got %d, want %d", test.label, got, test.expectNotes)
+		}
+		var failure string
+		if len(resp.Failure) > 0 {
+			failure = resp.Failure[0].GetFailureMessage()
+		}
+		if (test.expectFailure == "") != (failure == "") || !strings.Contains(failure, test.expectFailure) {
+			t.Errorf("Wrong failure for %q: got %q, want one containing %q", test.label, failure, test.expectFailure)
+		}
+		if got := len(resp.Restart); got != test.expectRestarts {
+			t.Errorf("Wrong number of restarts for %q: got %d, want %d", test.label, got, test.expectRestarts)
+		} else if got > 0 && (resp.Restart[0].GetCategory() != "Crashy" || resp.Restart[0].GetAnalyzer() != addr) {
+			t.Errorf("Wrong restart for %q: got %v", test.label, resp.Restart[0])
+		}
+		if test.expectNotes > 0 {
+			if got, err := ioutil.ReadFile(marker); err != nil || strings.TrimSpace(string(got)) != addr {
+				t.Errorf("Restart command was not given the analyzer address: got %q, %v, want %q", got, err, addr)
+			}
+		}
+	}
+}
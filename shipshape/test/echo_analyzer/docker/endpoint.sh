@@ -0,0 +1,22 @@
+#!/bin/bash
+
+# Copyright 2015 Google Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#   http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# This script is run by docker when the docker container receives a run
+# instruction. It starts the echo_service in the stage given by ECHO_STAGE,
+# which a .shipshape file can set for the container, and stores the output to
+# a log file.
+
+./echo_service --stage="${ECHO_STAGE:-PRE_BUILD}" &> /shipshape-output/shipshape.echo.log
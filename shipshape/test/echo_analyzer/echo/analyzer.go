@@ -0,0 +1,94 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package echo implements a trivial analyzer for integration tests. It
+// reports a note for each line of a file that has a marker, with the text
+// after the marker as its description, so that a test workspace says which
+// notes it should get back.
+package echo
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+	rangepb "github.com/google/shipshape/shipshape/proto/textrange_proto"
+)
+
+// Marker starts the text that the analyzer echoes back.
+const Marker = "shipshape-echo:"
+
+// Analyzer echoes the markers of the files it is given. Its category depends
+// on the stage it runs in, so that tests can tell the stages' notes apart.
+type Analyzer struct {
+	Stage ctxpb.Stage
+}
+
+func (a Analyzer) Category() string {
+	if a.Stage == ctxpb.Stage_POST_BUILD {
+		return "EchoPostBuild"
+	}
+	return "Echo"
+}
+
+// Analyze reads each of the files relative to the current directory, which is
+// the root of the repository.
+func (a Analyzer) Analyze(ctx *ctxpb.ShipshapeContext) ([]*notepb.Note, error) {
+	var notes []*notepb.Note
+	for _, path := range ctx.FilePath {
+		found, err := Find(path, a.Category())
+		if err != nil {
+			return notes, err
+		}
+		for _, note := range found {
+			note.Location.SourceContext = ctx.SourceContext
+		}
+		notes = append(notes, found...)
+	}
+	return notes, nil
+}
+
+// Find returns the notes of category for the markers in the file at path.
+// Tests use it to find the notes that a run on a workspace should return.
+func Find(path, category string) ([]*notepb.Note, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var notes []*notepb.Note
+	scanner := bufio.NewScanner(f)
+	for line := int32(1); scanner.Scan(); line++ {
+		i := strings.Index(scanner.Text(), Marker)
+		if i < 0 {
+			continue
+		}
+		notes = append(notes, &notepb.Note{
+			Category:    proto.String(category),
+			Description: proto.String(strings.TrimSpace(scanner.Text()[i+len(Marker):])),
+			Location: &notepb.Location{
+				Path:  proto.String(filepath.ToSlash(path)),
+				Range: &rangepb.TextRange{StartLine: proto.Int32(line)},
+			},
+		})
+	}
+	return notes, scanner.Err()
+}
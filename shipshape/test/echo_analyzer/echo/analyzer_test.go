@@ -0,0 +1,70 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package echo
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/shipshape/shipshape/util/file"
+
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+)
+
+func TestAnalyze(t *testing.T) {
+	// Under Bazel, tests run from the root of the runfiles.
+	dir := "shipshape/test/echo_analyzer/echo/testdata"
+	if _, err := os.Stat(dir); err != nil {
+		dir = "testdata"
+	}
+	orgDir, restore, err := file.ChangeDir(dir)
+	if err != nil {
+		t.Fatalf("Could not change into %s from %s: %v", dir, orgDir, err)
+	}
+	defer restore()
+
+	ctx := &ctxpb.ShipshapeContext{FilePath: []string{"a.txt", "sub/b.txt"}}
+	notes, err := Analyzer{}.Analyze(ctx)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	want := []struct {
+		path        string
+		line        int32
+		description string
+	}{
+		{"a.txt", 2, "hello"},
+		{"a.txt", 4, "world"},
+		{"sub/b.txt", 1, "nested"},
+	}
+	if len(notes) != len(want) {
+		t.Fatalf("Wrong number of notes: got %v, want %d", notes, len(want))
+	}
+	for i, w := range want {
+		n := notes[i]
+		if n.GetCategory() != "Echo" || n.GetLocation().GetPath() != w.path || n.GetLocation().GetRange().GetStartLine() != w.line || n.GetDescription() != w.description {
+			t.Errorf("Wrong note %d: got %v, want %+v", i, n, w)
+		}
+	}
+
+	if got, want := (Analyzer{Stage: ctxpb.Stage_POST_BUILD}).Category(), "EchoPostBuild"; got != want {
+		t.Errorf("Wrong post-build category: got %q, want %q", got, want)
+	}
+	if _, err := (Analyzer{}).Analyze(&ctxpb.ShipshapeContext{FilePath: []string{"missing.txt"}}); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Binary echo_service serves the echo analyzer, for integration tests. It
+// runs in the stage given by --stage, which the endpoint script takes from
+// the ECHO_STAGE environment variable of the container.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/google/shipshape/shipshape/api"
+	"github.com/google/shipshape/shipshape/test/echo_analyzer/echo"
+	"github.com/google/shipshape/shipshape/util/rpc/server"
+
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+)
+
+var (
+	servicePort = flag.Int("port", 10005, "Service port")
+	stage       = flag.String("stage", "PRE_BUILD", "The stage to run in, e.g. PRE_BUILD, POST_BUILD or SECURITY")
+)
+
+func main() {
+	flag.Parse()
+
+	st, ok := ctxpb.Stage_value[strings.ToUpper(*stage)]
+	if !ok {
+		log.Fatalf("Unknown stage %q", *stage)
+	}
+	s := server.Service{Name: "AnalyzerService"}
+	a := echo.Analyzer{Stage: ctxpb.Stage(st)}
+	if err := s.Register(api.CreateAnalyzerService([]api.Analyzer{a}, a.Stage)); err != nil {
+		log.Fatalf("Registering analyzer service failed: %v", err)
+	}
+
+	addr := fmt.Sprintf(":%d", *servicePort)
+	fmt.Fprintf(os.Stderr, "-- Starting server endpoint at %q\n", addr)
+	http.Handle("/", server.Endpoint{&s})
+
+	if err := http.ListenAndServe(addr, nil); err != nil {
+		log.Fatalf("Server startup failed: %v", err)
+	}
+}
@@ -44,7 +44,7 @@ var (
 	repoBase    = flag.String("repo_base", "/tmp", "The root of the repo to use, if LOCAL or the base directory to copy repo into if not LOCAL")
 	volumeName  = flag.String("volume_name", "/shipshape-workspace", "The name of the shipping_container volume")
 	event       = flag.String("event", "TestClient", "The name of the event to use")
-	stage       = flag.String("stage", "PRE_BUILD", "The stage to test, either PRE_BUILD or POST_BUILD")
+	stage       = flag.String("stage", "PRE_BUILD", "The stage to test, e.g. PRE_BUILD or POST_BUILD")
 )
 
 const (
@@ -87,15 +87,11 @@ func main() {
 	if *filePaths != "" {
 		paths = strings.Split(*filePaths, ",")
 	}
-	var stageEnum ctxpb.Stage
-	switch *stage {
-	case "POST_BUILD":
-		stageEnum = ctxpb.Stage_POST_BUILD
-	case "PRE_BUILD":
-		stageEnum = ctxpb.Stage_PRE_BUILD
-	default:
+	s, ok := ctxpb.Stage_value[*stage]
+	if !ok {
 		log.Fatalf("Invalid stage %q", *stage)
 	}
+	stageEnum := ctxpb.Stage(s)
 
 	req := &rpcpb.ShipshapeRequest{
 		TriggeredCategory: trigger,
@@ -109,16 +105,16 @@ func main() {
 	}
 	log.Println("About to call out to the shipshape service")
 
-	rd := c.Stream("/ShipshapeService/Run", req)
+	rd := client.ShipshapeClient{Client: c}.Run(req)
 	defer rd.Close()
 	for {
-		var msg rpcpb.ShipshapeResponse
-		if err := rd.NextResult(&msg); err == io.EOF {
+		msg, err := rd.Next()
+		if err == io.EOF {
 			break
 		} else if err != nil {
 			log.Fatalf("Error from call: %v", err)
 		}
-		log.Printf("result:\n\n%v\n", proto.MarshalTextString(&msg))
+		log.Printf("result:\n\n%v\n", proto.MarshalTextString(msg))
 	}
 
 	log.Printf("Done.")
@@ -21,23 +21,130 @@ package docker
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
+	"github.com/google/shipshape/shipshape/util/redact"
 	glog "github.com/google/shipshape/third_party/go-glog"
 )
 
 const (
 	shipshapeWork = "/shipshape-workspace"
 	shipshapeLogs = "/shipshape-output"
+
+	// ImageLabel and WorkspaceLabel are set on analyzer containers to record
+	// the image digest and workspace path they were started with.
+	ImageLabel     = "shipshape.image"
+	WorkspaceLabel = "shipshape.workspace"
+
+	// AccessLabel records the Access that a container was started with.
+	AccessLabel = "shipshape.docker_access"
+
+	// DockerSocket is where the docker daemon listens, both on the host and in
+	// containers started with SocketAccess.
+	DockerSocket = "/var/run/docker.sock"
+
+	// HostWorkspaceEnv is set in containers started with SocketAccess to the
+	// host path of the shipshape workspace. Containers that they start share
+	// the host's daemon, so their volumes need host paths rather than paths
+	// under /shipshape-workspace.
+	HostWorkspaceEnv = "SHIPSHAPE_HOST_WORKSPACE"
+
+	// CacheLabel marks the named volumes created for CacheVolumes.
+	CacheLabel = "shipshape.cache"
+
+	// EnvLabel records a hash of the extra environment that an analyzer
+	// container was started with, so that it is restarted when that changes.
+	// The values themselves are not recorded, since they may be secrets.
+	EnvLabel = "shipshape.env"
+
+	// restartAnalyzerCommand is how a service started with SocketAccess restarts an
+	// analyzer container that stops responding. The service passes the analyzer's address
+	// in $ANALYZER, whose host is the container's link alias, which is its name. The
+	// shipshape image has curl but not the docker client, so this uses the docker API.
+	restartAnalyzerCommand = `curl -sSf --unix-socket ` + DockerSocket + ` -X POST "http://localhost/containers/${ANALYZER%:*}/restart?t=0"`
+
+	// SocketLabel records the host directory that the service was started to
+	// listen in, if it listens on a unix socket rather than on port 10007.
+	SocketLabel = "shipshape.socket"
+
+	// DeterministicLabel records whether the service was started to ignore the
+	// analyzer configuration in the repository.
+	DeterministicLabel = "shipshape.deterministic"
+
+	// ServiceSocket is the name of the service's unix socket in that directory.
+	ServiceSocket = "service.sock"
+
+	// RunLabel records the ID of the run of the CLI that started a container.
+	// Containers that later runs reuse keep the ID of the first one.
+	RunLabel = "shipshape.run_id"
+
+	shipshapeSocketDir = "/shipshape-socket"
 )
 
+// CacheVolumes maps the named volumes that hold tool caches to the paths
+// they are mounted at in analyzer and kythe containers. They persist across
+// runs so that dependencies are not downloaded again each time.
+var CacheVolumes = map[string]string{
+	"shipshape-cache-maven": "/root/.m2",
+	"shipshape-cache-pip":   "/root/.cache/pip",
+	"shipshape-cache-npm":   "/root/.npm",
+}
+
+// RunID, if set, is the ID of the run of the CLI that is starting containers,
+// which labels them with RunLabel.
+var RunID string
+
+// Access is how the containers that shipshape starts can use docker themselves.
+type Access int
+
+const (
+	// NoAccess gives containers no access to docker.
+	NoAccess Access = iota
+	// DindAccess runs containers with --privileged, so that they can run their
+	// own docker daemon (docker-in-docker).
+	DindAccess
+	// SocketAccess mounts the host's docker socket into containers, so that
+	// they share the host's daemon without being privileged.
+	SocketAccess
+)
+
+func (a Access) String() string {
+	switch a {
+	case DindAccess:
+		return "dind"
+	case SocketAccess:
+		return "socket"
+	}
+	return "none"
+}
+
+// args adds the volumes and environment that a container on workspacePath
+// needs for access to volumeMap and environment, and returns the extra flags
+// for docker run.
+func (a Access) args(volumeMap, environment map[string]string, workspacePath string) []string {
+	switch a {
+	case DindAccess:
+		return []string{"--privileged"}
+	case SocketAccess:
+		volumeMap[DockerSocket] = DockerSocket
+		environment[HostWorkspaceEnv] = workspacePath
+	}
+	return nil
+}
+
 // TODO(ciera): Consider making these all use channels.
 type CommandResult struct {
 	Stdout string
@@ -85,6 +192,17 @@ func HasDocker() bool {
 	return cmd.Run() == nil
 }
 
+// Daemon returns the address of the docker daemon that the docker commands
+// talk to: $DOCKER_HOST if it is set, and the local socket otherwise. The
+// containers that shipshape names, such as shipping_container, are unique per
+// daemon.
+func Daemon() string {
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		return host
+	}
+	return "unix://" + DockerSocket
+}
+
 // FullImageName creates a full image name from a repository URI, an image name, and a tag.
 func FullImageName(repo, image, tag string) string {
 	fullImage := repo
@@ -139,13 +257,19 @@ func setupArgs(container string, portMap map[int]int, volumeMap map[string]strin
 	args = append(args, volumeList...)
 	args = append(args, environmentVars...)
 	args = append(args, fmt.Sprintf("--name=%s", container))
+	if RunID != "" {
+		args = append(args, fmt.Sprintf("--label=%s=%s", RunLabel, RunID))
+	}
 	return args
 }
 
 // RunAnalyzer runs the analyzer image with container analyzerContainer. It runs it at port (mapped
-// to internal port 10005), binds the volumes for the workspacePath and logsPath, and gives the privileged
-// if dind (docker-in-docker) is true.
-func RunAnalyzer(image, analyzerContainer, workspacePath, logsPath string, port int, dind bool) CommandResult {
+// to internal port 10005), binds the volumes for the workspacePath and logsPath, and gives it
+// the requested access to docker. The variables in env are set in the container from a file
+// that only the user can read, so that their values are not on the command line or in the
+// logs. The container is confined by sandbox; a sandboxed analyzer gets no access to
+// docker, which would let it escape, and an offline one publishes no port.
+func RunAnalyzer(image, analyzerContainer, workspacePath, logsPath string, port int, access Access, env map[string]string, sandbox Sandbox) CommandResult {
 	stdout := bytes.NewBuffer(nil)
 	stderr := bytes.NewBuffer(nil)
 	if len(analyzerContainer) == 0 {
@@ -156,14 +280,33 @@ func RunAnalyzer(image, analyzerContainer, workspacePath, logsPath string, port
 		workspacePath: shipshapeWork,
 		logsPath:      shipshapeLogs,
 	}
+	addCacheVolumes(volumeMap)
+	environment := make(map[string]string)
+	access = sandboxAccess(access, sandbox)
+	ports := map[int]int{port: 10005}
+	labels := analyzerLabels(imageID(image), workspacePath, access, env, sandbox)
+	labels[PortLabel] = strconv.Itoa(port)
+	if sandbox.Offline {
+		ports = nil
+		delete(labels, PortLabel)
+	}
 	args := []string{"run"}
-	if dind {
-		args = append(args, "--privileged")
+	args = append(args, access.args(volumeMap, environment, workspacePath)...)
+	args = append(args, sandbox.args()...)
+	args = append(args, setupArgs(analyzerContainer, ports, volumeMap, nil, environment)...)
+	args = append(args, labelArgs(labels)...)
+	if len(env) > 0 {
+		envFile, err := writeEnvFile(env)
+		if err != nil {
+			return CommandResult{"", "", err}
+		}
+		// docker reads the file before it starts the container.
+		defer os.Remove(envFile)
+		args = append(args, "--env-file="+envFile)
 	}
-	args = append(args, setupArgs(analyzerContainer, map[int]int{port: 10005}, volumeMap, nil, nil)...)
 	args = append(args, "-d", image)
 
-	glog.Infof("Running 'docker %v'\n", args)
+	glog.Infof("Running 'docker %v'\n", redact.Strings(args))
 
 	cmd := exec.Command("docker", args...)
 	cmd.Stdout = stdout
@@ -172,11 +315,178 @@ func RunAnalyzer(image, analyzerContainer, workspacePath, logsPath string, port
 	return CommandResult{stdout.String(), stderr.String(), err}
 }
 
+// analyzerLabels returns the labels that identify an analyzer container
+// started from the image with digest imageID on workspacePath, with env and
+// in sandbox.
+func analyzerLabels(imageID, workspacePath string, access Access, env map[string]string, sandbox Sandbox) map[string]string {
+	return map[string]string{
+		ImageLabel:     imageID,
+		WorkspaceLabel: workspacePath,
+		AccessLabel:    access.String(),
+		EnvLabel:       envHash(env),
+		SandboxLabel:   sandbox.String(),
+	}
+}
+
+// sandboxAccess returns the access to docker that an analyzer in sandbox is
+// given: none if it is sandboxed at all, and access otherwise.
+func sandboxAccess(access Access, sandbox Sandbox) Access {
+	if !sandbox.IsZero() {
+		return NoAccess
+	}
+	return access
+}
+
+// envHash returns a digest of env, or the empty string if env is empty.
+func envHash(env map[string]string) string {
+	if len(env) == 0 {
+		return ""
+	}
+	var vars []string
+	for k, v := range env {
+		vars = append(vars, k+"="+v)
+	}
+	sort.Strings(vars)
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(vars, "\x00"))))
+}
+
+// writeEnvFile writes env to a new file for docker run --env-file, sorted by
+// name, and returns its path. The file is only readable by the user, and the
+// caller removes it. docker reads the file a line at a time, so names with an
+// = and names or values with a line break are rejected.
+func writeEnvFile(env map[string]string) (string, error) {
+	var lines []string
+	for k, v := range env {
+		if k == "" || strings.ContainsAny(k, "=\n\r") || strings.ContainsAny(v, "\n\r") {
+			return "", fmt.Errorf("the environment variable %q cannot be passed to docker: names cannot be empty or contain =, and neither names nor values can contain line breaks", k)
+		}
+		lines = append(lines, k+"="+v+"\n")
+	}
+	sort.Strings(lines)
+	// TempFile creates the file with mode 0600.
+	f, err := ioutil.TempFile("", "shipshape_env")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(strings.Join(lines, "")); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// labelArgs converts labels into docker run flags, sorted by key. Labels
+// with empty values are left out.
+func labelArgs(labels map[string]string) []string {
+	var args []string
+	for k, v := range labels {
+		if v == "" {
+			continue
+		}
+		args = append(args, fmt.Sprintf("--label=%s=%s", k, v))
+	}
+	sort.Strings(args)
+	return args
+}
+
+// addCacheVolumes adds the CacheVolumes to volumeMap, skipping any whose
+// container path is already mapped from the host.
+func addCacheVolumes(volumeMap map[string]string) {
+	mapped := make(map[string]bool)
+	for _, containerVolume := range volumeMap {
+		mapped[containerVolume] = true
+	}
+	for volume, containerVolume := range CacheVolumes {
+		if !mapped[containerVolume] {
+			volumeMap[volume] = containerVolume
+		}
+	}
+}
+
+// CreateCacheVolumes creates any of the CacheVolumes that do not exist yet,
+// labelled with CacheLabel.
+func CreateCacheVolumes() error {
+	for _, volume := range CacheVolumeNames() {
+		if _, err := exec.Command("docker", "volume", "inspect", volume).CombinedOutput(); err == nil {
+			continue
+		}
+		out, err := exec.Command("docker", "volume", "create", "--label="+CacheLabel, "--name="+volume).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("could not create volume %s: %v\n%s", volume, err, out)
+		}
+	}
+	return nil
+}
+
+// CacheVolumeNames returns the names of the CacheVolumes, sorted.
+func CacheVolumeNames() []string {
+	var names []string
+	for volume := range CacheVolumes {
+		names = append(names, volume)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ListCacheVolumes returns the names of the existing tool cache volumes.
+func ListCacheVolumes() ([]string, error) {
+	out, err := exec.Command("docker", "volume", "ls", "-q", "--filter=label="+CacheLabel).Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not list volumes: %v", err)
+	}
+	names := strings.Fields(string(out))
+	sort.Strings(names)
+	return names, nil
+}
+
+// RemoveVolume removes the named volume. It fails if a container is still
+// using the volume.
+func RemoveVolume(volume string) CommandResult {
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	cmd := exec.Command("docker", "volume", "rm", volume)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	return trimResult(stdout, stderr, err)
+}
+
+// ImageSize returns the size in bytes of the local copy of image, or an
+// error if there is none.
+func ImageSize(image string) (int64, error) {
+	out, err := inspect(image, "{{.Size}}")
+	if err != nil {
+		return 0, fmt.Errorf("could not inspect %s: %s", image, strings.TrimSpace(string(out)))
+	}
+	return strconv.ParseInt(trimInspect(out), 10, 64)
+}
+
+// RemoveImage removes the local copy of image. It fails if a container is
+// still using the image.
+func RemoveImage(image string) CommandResult {
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	cmd := exec.Command("docker", "rmi", image)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	return trimResult(stdout, stderr, err)
+}
+
 // RunService runs the shipshape service at image, as the container named container. It binds the
 // shipshape workspace and logs appropriately. It starts with the third-party analyzers already
-// running at analyzerContainers. The service is started with the privileged flag if dind (docker-in-docker)
-// is true.
-func RunService(image, container, workspacePath, logsPath string, analyzerContainers []string, dind bool) CommandResult {
+// running at analyzerContainers, and with the requested access to docker; with SocketAccess, the
+// service restarts analyzer containers that stop responding. Analyzers that run offline, on
+// SandboxNetwork, are not linked; the service joins that network to reach them instead. If socketDir is not
+// empty, it is mounted into the container and the service listens on ServiceSocket in it,
+// instead of publishing its port 10007 on port of the host. If deterministic is true, the
+// built-in analyzers use the fixed configuration in the image rather than any in the workspace.
+func RunService(image, container, workspacePath, logsPath string, analyzerContainers []string, access Access, socketDir string, port int, deterministic bool) CommandResult {
 	stdout := bytes.NewBuffer(nil)
 	stderr := bytes.NewBuffer(nil)
 	if len(container) == 0 {
@@ -187,31 +497,64 @@ func RunService(image, container, workspacePath, logsPath string, analyzerContai
 
 	var locations []string
 	for _, container := range analyzerContainers {
-		locations = append(locations, fmt.Sprintf(`$%s_PORT_10005_TCP_ADDR:$%s_PORT_10005_TCP_PORT`, strings.ToUpper(container), strings.ToUpper(container)))
+		// Use the link alias rather than the address in the link's environment, since docker
+		// points the alias at the new address when the analyzer container is restarted.
+		locations = append(locations, container+":10005")
 	}
 	locations = append(locations, "localhost:10005", "localhost:10006", "localhost:10008")
 
+	environment := map[string]string{"START_SERVICE": "true", "ANALYZERS": strings.Join(locations, ",")}
 	args := []string{"run"}
-	if dind {
-		args = append(args, "--privileged")
+	args = append(args, access.args(volumeMap, environment, workspacePath)...)
+	if access == SocketAccess {
+		environment["RESTART_ANALYZER"] = restartAnalyzerCommand
+	}
+	ports := map[int]int{port: 10007}
+	published := strconv.Itoa(port)
+	if socketDir != "" {
+		volumeMap[socketDir] = shipshapeSocketDir
+		environment["SERVICE_SOCKET"] = shipshapeSocketDir + "/" + ServiceSocket
+		ports = nil
+		published = ""
 	}
-	args = append(args, setupArgs(container, map[int]int{10007: 10007}, volumeMap, analyzerContainers, map[string]string{"START_SERVICE": "true", "ANALYZERS": strings.Join(locations, ",")})...)
+	if deterministic {
+		environment["DETERMINISTIC"] = "true"
+	}
+	var links []string
+	sandboxed := false
+	for _, analyzer := range analyzerContainers {
+		if onSandboxNetwork(analyzer) {
+			sandboxed = true
+		} else {
+			links = append(links, analyzer)
+		}
+	}
+	args = append(args, setupArgs(container, ports, volumeMap, links, environment)...)
+	args = append(args, labelArgs(map[string]string{AccessLabel: access.String(), SocketLabel: socketDir, PortLabel: published, DeterministicLabel: strconv.FormatBool(deterministic)})...)
 	args = append(args, "-d", image)
 
-	glog.Infof("Running 'docker %v'\n", args)
+	glog.Infof("Running 'docker %v'\n", redact.Strings(args))
 
 	cmd := exec.Command("docker", args...)
 	cmd.Stdout = stdout
 	cmd.Stderr = stderr
 	err := cmd.Run()
+	if err == nil && sandboxed {
+		// The service checks that the analyzers are up on each request, so
+		// it is fine for it to join the network only once it has started.
+		cmd = exec.Command("docker", "network", "connect", SandboxNetwork, container)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		err = cmd.Run()
+	}
 	return CommandResult{stdout.String(), stderr.String(), err}
 }
 
 // RunKythe runs the specified kythe docker image at the named container. It uses the
-// source root and extractor specified, and gives the privileged flag if dind (docker-in-docker) is true.
+// source root and extractor specified, and gives it the requested access to docker.
 // It returns stdout, stderr, and any errors from running.
 // This is a blocking call, and should be wrapped in a go routine for asynchonous use.
-func RunKythe(image, container, sourcePath, extractor string, dind bool) CommandResult {
+func RunKythe(image, container, sourcePath, extractor string, access Access) CommandResult {
 	stdout := bytes.NewBuffer(nil)
 	stderr := bytes.NewBuffer(nil)
 	if len(container) == 0 {
@@ -226,14 +569,14 @@ func RunKythe(image, container, sourcePath, extractor string, dind bool) Command
 	if len(home) > 0 {
 		volumeMap[filepath.Join(home, ".m2")] = "/root/.m2"
 	}
+	addCacheVolumes(volumeMap)
 
 	// TODO(ciera): Can we exclude files in the .shipshape ignore path?
 	// TODO(ciera/emso): Can we use the same command for blaze extraction?
+	environment := make(map[string]string)
 	args := []string{"run"}
-	if dind {
-		args = append(args, "--privileged")
-	}
-	args = append(args, setupArgs(container, nil, volumeMap, nil, nil)...)
+	args = append(args, access.args(volumeMap, environment, sourcePath)...)
+	args = append(args, setupArgs(container, nil, volumeMap, nil, environment)...)
 	args = append(args, "-i", "-a", "stdin", "-a", "stderr", "-a", "stdout", image)
 	args = append(args, "--extract", extractor)
 
@@ -244,6 +587,37 @@ func RunKythe(image, container, sourcePath, extractor string, dind bool) Command
 	return CommandResult{stdout.String(), stderr.String(), err}
 }
 
+// IndexKythe runs the specified kythe docker image at the named container to index the
+// compilation units that RunKythe extracted from the source root. The image writes the
+// entries of the cross-reference graph, one JSON object per line, to entries.json in
+// the compilations directory. It gives the image the requested access to docker.
+// It returns stdout, stderr, and any errors from running.
+// This is a blocking call, and should be wrapped in a go routine for asynchonous use.
+func IndexKythe(image, container, sourcePath string, access Access) CommandResult {
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	if len(container) == 0 {
+		return CommandResult{"", "", errors.New("need to provide a name for the container")}
+	}
+
+	volumeMap := map[string]string{
+		filepath.Join(sourcePath, "compilations"): "/compilations",
+		sourcePath: "/repo",
+	}
+	environment := make(map[string]string)
+	args := []string{"run"}
+	args = append(args, access.args(volumeMap, environment, sourcePath)...)
+	args = append(args, setupArgs(container, nil, volumeMap, nil, environment)...)
+	args = append(args, "-i", "-a", "stdin", "-a", "stderr", "-a", "stdout", image)
+	args = append(args, "--index")
+
+	cmd := exec.Command("docker", args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	return CommandResult{stdout.String(), stderr.String(), err}
+}
+
 // Stop stops a running container.
 // It returns stdout, stderr, and any errors from running.
 // This is a blocking call, and should be wrapped in a go routine for asynchonous use.
@@ -294,6 +668,102 @@ func ImageMatches(image, container string) bool {
 	return bytes.Equal(imageHash, containerHash)
 }
 
+// AnalyzerMatches returns whether the analyzer container can be reused for
+// image on workspacePath. It must be running the current version of image,
+// and must have been started on the same workspace and with the same access,
+// env and sandbox; a container started for another directory would analyze
+// stale files.
+func AnalyzerMatches(image, container, workspacePath string, access Access, env map[string]string, sandbox Sandbox) bool {
+	if !ImageMatches(image, container) {
+		return false
+	}
+	want := analyzerLabels(imageID(image), workspacePath, sandboxAccess(access, sandbox), env, sandbox)
+	for k, v := range want {
+		got := label(container, k)
+		if k == WorkspaceLabel {
+			// The workspace may have been given in another case, or with a
+			// trailing slash, and still be the same directory.
+			if same, subPath := relativePath(got, v, caseInsensitive(v)); same && subPath == "" {
+				continue
+			}
+		}
+		if got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ServesSocket returns whether the service at container was started to listen
+// on a unix socket in socketDir, or on a port if socketDir is empty.
+func ServesSocket(container, socketDir string) bool {
+	return label(container, SocketLabel) == socketDir
+}
+
+// IsDeterministic returns whether the service container was started with deterministic
+// set. Containers started before the label existed are not.
+func IsDeterministic(container string) bool {
+	return label(container, DeterministicLabel) == "true"
+}
+
+// HasAccess returns whether container was started with access.
+func HasAccess(container string, access Access) bool {
+	return label(container, AccessLabel) == access.String()
+}
+
+// imageID returns the digest of image, or the empty string if it cannot
+// be inspected.
+func imageID(image string) string {
+	id, err := inspect(image, "{{.Id}}")
+	if err != nil {
+		return ""
+	}
+	return trimInspect(id)
+}
+
+// ImageDigest returns the content digest of image, such as sha256:..., or the
+// empty string if it cannot be inspected. The digest the registry knows the
+// image by is preferred, since it can be checked against the registry; a
+// local image that was never pushed or pulled only has its ID.
+func ImageDigest(image string) string {
+	out, err := inspect(image, "{{range .RepoDigests}}{{.}} {{end}}")
+	if err != nil {
+		return ""
+	}
+	if digests := strings.Fields(trimInspect(out)); len(digests) > 0 {
+		return repoDigest(digests[0])
+	}
+	return imageID(image)
+}
+
+// repoDigest returns the digest part of a repo digest such as
+// gcr.io/shipshape_releases/service@sha256:....
+func repoDigest(ref string) string {
+	return ref[strings.LastIndex(ref, "@")+1:]
+}
+
+// LabelOf returns the value of the label key on the local copy of image,
+// or the empty string if it is not set or image has not been pulled.
+func LabelOf(image, key string) string {
+	return label(image, key)
+}
+
+// label returns the value of the label key on container, or the empty string
+// if it is not set.
+func label(container, key string) string {
+	v, err := inspect(container, fmt.Sprintf(`{{index .Config.Labels %q}}`, key))
+	if err != nil {
+		return ""
+	}
+	return trimInspect(v)
+}
+
+// trimInspect strips the whitespace and the quotes of the format string from
+// the output of inspect.
+func trimInspect(out []byte) string {
+	return strings.Trim(strings.TrimSpace(string(out)), "'")
+}
+
 // MappedVolume returns whether path is already mapped into the workspace
 // of the shipshape service running at container. If it is, it returns the relative path
 // of path within the mapped volume.
@@ -305,19 +775,65 @@ func MappedVolume(path, container string) (bool, string) {
 	if err != nil {
 		return false, ""
 	}
-	volume := strings.TrimSpace(string(v))
+	return relativePath(strings.TrimSpace(trimInspect(v)), path, caseInsensitive(path))
+}
+
+// relativePath returns whether path is volume or a directory under it, and if
+// so, the path of path relative to volume, with slashes. Both must be absolute;
+// trailing slashes do not matter. If caseInsensitive is set, the paths are on a
+// filesystem that ignores case, as by default on macOS and Windows, so they
+// are compared ignoring case too, and the relative path keeps the case of path.
+func relativePath(volume, path string, caseInsensitive bool) (bool, string) {
+	if volume == "" {
+		return false, ""
+	}
+	volume = filepath.ToSlash(filepath.Clean(volume))
+	path = filepath.ToSlash(filepath.Clean(path))
+	same := func(a, b string) bool { return a == b }
+	if caseInsensitive {
+		same = strings.EqualFold
+	}
 	// Handle the equal case
-	if path == volume {
+	if same(path, volume) {
 		return true, ""
 	}
 	// Handle the subdirectory case by adding a trailing '/'
-	// Want to rule out the case: volume='/a/b2' and path='/a/b'
-	path += "/"
-	return strings.HasPrefix(volume, path), strings.TrimPrefix(path, volume)
+	// Want to rule out the case: volume='/a/b' and path='/a/b2'
+	prefix := strings.TrimSuffix(volume, "/") + "/"
+	if len(path) <= len(prefix) || !same(path[:len(prefix)], prefix) {
+		return false, ""
+	}
+	return true, path[len(prefix):]
+}
+
+// caseInsensitive returns whether path, which must exist, is on a filesystem
+// that ignores case, by looking it up with the case of its letters swapped.
+// If that cannot tell, it guesses from the OS, since the default filesystems
+// of macOS and Windows ignore case.
+func caseInsensitive(path string) bool {
+	swapped := swapCase(path)
+	fi, err := os.Stat(path)
+	if swapped == path || err != nil {
+		return runtime.GOOS == "darwin" || runtime.GOOS == "windows"
+	}
+	sfi, err := os.Stat(swapped)
+	return err == nil && os.SameFile(fi, sfi)
+}
+
+// swapCase returns s with its upper case letters in lower case and the other
+// way around.
+func swapCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, s)
 }
 
 // ContainsLinks returns whether the given container has links to the given
-// list of containers.
+// list of containers. A container on SandboxNetwork counts as linked if the
+// given container is on that network too.
 func ContainsLinks(container string, linkedContainers []string) bool {
 	l, err := inspect(container, `{{.HostConfig.Links}}`)
 	if err != nil {
@@ -325,13 +841,68 @@ func ContainsLinks(container string, linkedContainers []string) bool {
 	}
 	links := strings.TrimSpace(string(l))
 	for _, linkedContainer := range linkedContainers {
-		if !strings.Contains(links, linkedContainer) {
+		if onSandboxNetwork(linkedContainer) {
+			if !onSandboxNetwork(container) {
+				return false
+			}
+		} else if !strings.Contains(links, linkedContainer) {
 			return false
 		}
 	}
 	return true
 }
 
+// Inspect returns the output of docker inspect on name, which must be either an image
+// or a container, with any registered secrets redacted.
+func Inspect(name string) CommandResult {
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	cmd := exec.Command("docker", "inspect", name)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	return CommandResult{redact.String(stdout.String()), redact.String(stderr.String()), err}
+}
+
+// Logs returns what the container has written to its stdout and stderr, with any
+// registered secrets redacted.
+func Logs(container string) CommandResult {
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	cmd := exec.Command("docker", "logs", container)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	return CommandResult{redact.String(stdout.String()), redact.String(stderr.String()), err}
+}
+
+// FollowLogs streams what the container writes to its stdout and stderr from since on
+// to stdout and stderr, until stop is called. The output is not redacted.
+func FollowLogs(container string, since time.Time, stdout, stderr io.Writer) (stop func(), err error) {
+	cmd := exec.Command("docker", "logs", "--follow", fmt.Sprintf("--since=%d", since.Unix()), container)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("could not follow the logs of %s: %v", container, err)
+	}
+	return func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}, nil
+}
+
+// Version returns the output of docker version, which describes both the client and
+// the daemon.
+func Version() CommandResult {
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	cmd := exec.Command("docker", "version")
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	return trimResult(stdout, stderr, err)
+}
+
 // inspect runs docker inspect on name, which must be either an image or a container.
 // If non-empty, it uses the specified format string.
 // Returns the combined stdout/stderr from running docker inspect
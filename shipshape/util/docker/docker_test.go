@@ -17,7 +17,11 @@
 package docker
 
 import (
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -29,6 +33,173 @@ func TestHasDocker(t *testing.T) {
 	}
 }
 
+func TestAnalyzerLabelArgs(t *testing.T) {
+	got := labelArgs(analyzerLabels("sha256:abc", "/home/me/project", SocketAccess, nil, Sandbox{}))
+	want := []string{
+		"--label=shipshape.docker_access=socket",
+		"--label=shipshape.image=sha256:abc",
+		"--label=shipshape.workspace=/home/me/project",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong label args: got %v, want %v", got, want)
+	}
+
+	env := map[string]string{"LICENSE": "secret"}
+	if envHash(env) == envHash(map[string]string{"LICENSE": "other"}) {
+		t.Errorf("Different environments have the same hash")
+	}
+	labels := labelArgs(analyzerLabels("sha256:abc", "/home/me/project", SocketAccess, env, Sandbox{}))
+	if got, want := len(labels), 4; got != want {
+		t.Errorf("Wrong number of labels with an environment: got %d, want %d", got, want)
+	}
+	for _, l := range labels {
+		if strings.Contains(l, "secret") {
+			t.Errorf("Label %q contains an environment value", l)
+		}
+	}
+}
+
+func TestRelativePath(t *testing.T) {
+	tests := []struct {
+		desc, volume, path string
+		mapped             bool
+		subPath            string
+	}{
+		{"same directory", "/home/me/project", "/home/me/project", true, ""},
+		{"changing down", "/home/me/project", "/home/me/project/src/main", true, "src/main"},
+		{"changing up", "/home/me/project", "/home/me", false, ""},
+		{"sibling", "/home/me/project", "/home/me/other", false, ""},
+		{"sibling with the same prefix", "/home/me/project", "/home/me/project2", false, ""},
+		{"volume with a trailing slash", "/home/me/project/", "/home/me/project/src", true, "src"},
+		{"path with a trailing slash", "/home/me/project", "/home/me/project/src/", true, "src"},
+		{"same directory with a trailing slash", "/home/me/project", "/home/me/project/", true, ""},
+		{"different case", "/Users/me/Project", "/Users/me/project/src", false, ""},
+		{"nothing mapped", "", "/home/me/project", false, ""},
+	}
+	for _, test := range tests {
+		mapped, subPath := relativePath(test.volume, test.path, false)
+		if mapped != test.mapped || subPath != test.subPath {
+			t.Errorf("%s: got (%v, %q), want (%v, %q)", test.desc, mapped, subPath, test.mapped, test.subPath)
+		}
+	}
+}
+
+func TestRelativePathIgnoringCase(t *testing.T) {
+	tests := []struct {
+		desc, volume, path string
+		mapped             bool
+		subPath            string
+	}{
+		{"same directory in another case", "/Users/me/Project", "/users/me/project", true, ""},
+		{"changing down in another case", "/Users/me/Project/", "/Users/me/project/Src/Main", true, "Src/Main"},
+		{"sibling with the same prefix", "/Users/me/Project", "/Users/me/project2", false, ""},
+		{"changing up", "/Users/me/Project", "/users/me", false, ""},
+	}
+	for _, test := range tests {
+		mapped, subPath := relativePath(test.volume, test.path, true)
+		if mapped != test.mapped || subPath != test.subPath {
+			t.Errorf("%s: got (%v, %q), want (%v, %q)", test.desc, mapped, subPath, test.mapped, test.subPath)
+		}
+	}
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "Case")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// The filesystem ignores case if the directory can be found in another.
+	_, err = os.Stat(swapCase(dir))
+	if got, want := caseInsensitive(dir), err == nil; got != want {
+		t.Errorf("caseInsensitive(%q) = %v, want %v", dir, got, want)
+	}
+	if got, want := swapCase("/Users/me/a.go"), "/uSERS/ME/A.GO"; got != want {
+		t.Errorf("swapCase: got %q, want %q", got, want)
+	}
+}
+
+func TestWriteEnvFile(t *testing.T) {
+	path, err := writeEnvFile(map[string]string{"LICENSE": "secret", "MODE": "a=b c"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.Remove(path)
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fi.Mode().Perm(); got != 0600 {
+		t.Errorf("Wrong mode of the env file: got %v, want 0600", got)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "LICENSE=secret\nMODE=a=b c\n"; got != want {
+		t.Errorf("Wrong env file: got %q, want %q", got, want)
+	}
+
+	for _, env := range []map[string]string{
+		{"LICENSE": "secret\nOTHER=injected"},
+		{"A=B": "value"},
+		{"": "value"},
+	} {
+		if path, err := writeEnvFile(env); err == nil {
+			os.Remove(path)
+			t.Errorf("Expected an error for %q", env)
+		}
+	}
+}
+
+func TestAddCacheVolumes(t *testing.T) {
+	volumeMap := map[string]string{
+		"/src":         "/repo",
+		"/home/me/.m2": "/root/.m2",
+	}
+	addCacheVolumes(volumeMap)
+	want := map[string]string{
+		"/src":                "/repo",
+		"/home/me/.m2":        "/root/.m2",
+		"shipshape-cache-pip": "/root/.cache/pip",
+		"shipshape-cache-npm": "/root/.npm",
+	}
+	if !reflect.DeepEqual(volumeMap, want) {
+		t.Errorf("Wrong volumes: got %v, want %v", volumeMap, want)
+	}
+}
+
+func TestAccessArgs(t *testing.T) {
+	tests := []struct {
+		access      Access
+		args        []string
+		volumes     map[string]string
+		environment map[string]string
+	}{
+		{NoAccess, nil, map[string]string{}, map[string]string{}},
+		{DindAccess, []string{"--privileged"}, map[string]string{}, map[string]string{}},
+		{
+			SocketAccess,
+			nil,
+			map[string]string{"/var/run/docker.sock": "/var/run/docker.sock"},
+			map[string]string{"SHIPSHAPE_HOST_WORKSPACE": "/src"},
+		},
+	}
+	for _, test := range tests {
+		volumes, environment := make(map[string]string), make(map[string]string)
+		args := test.access.args(volumes, environment, "/src")
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("%v: wrong args: got %v, want %v", test.access, args, test.args)
+		}
+		if !reflect.DeepEqual(volumes, test.volumes) {
+			t.Errorf("%v: wrong volumes: got %v, want %v", test.access, volumes, test.volumes)
+		}
+		if !reflect.DeepEqual(environment, test.environment) {
+			t.Errorf("%v: wrong environment: got %v, want %v", test.access, environment, test.environment)
+		}
+	}
+}
+
 // NB! This test is using a set of predefined container names that might clash with already
 // existing container names (docker_test_container*). The tests clean up after themselves so
 // matching containers will be cleaned up in the test tear down. Rerunning a test should
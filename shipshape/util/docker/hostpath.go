@@ -0,0 +1,108 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package docker
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Mount is a volume mounted into a container.
+type Mount struct {
+	// Source is the path on the host.
+	Source string
+	// Destination is the path in the container.
+	Destination string
+}
+
+// containerID matches the id of a docker container in /proc/self/cgroup.
+var containerID = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// SelfContainerID returns the id of the docker container that this process is
+// running in. It falls back on the hostname, which docker sets to the short id
+// of the container by default.
+func SelfContainerID() (string, error) {
+	if f, err := os.Open("/proc/self/cgroup"); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if id := containerID.FindString(scanner.Text()); id != "" {
+				return id, nil
+			}
+		}
+	}
+	return os.Hostname()
+}
+
+// ContainerMounts returns the volumes mounted into container.
+func ContainerMounts(container string) ([]Mount, error) {
+	out, err := exec.Command("docker", "inspect", "--format", `{{range .Mounts}}{{.Source}}:{{.Destination}}{{"\n"}}{{end}}`, container).Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not inspect the mounts of %s: %v", container, err)
+	}
+	var mounts []Mount
+	for _, line := range strings.Split(string(out), "\n") {
+		if i := strings.LastIndex(line, ":"); i > 0 {
+			mounts = append(mounts, Mount{Source: line[:i], Destination: line[i+1:]})
+		}
+	}
+	return mounts, nil
+}
+
+// HostPath translates path, in a container with the given mounts, into the
+// corresponding path on the host. It uses the most specific mount containing
+// path, and returns false if there is none.
+func HostPath(path string, mounts []Mount) (string, bool) {
+	best := -1
+	var rel string
+	for i, m := range mounts {
+		r, err := filepath.Rel(m.Destination, path)
+		if err != nil || r == ".." || strings.HasPrefix(r, "../") {
+			continue
+		}
+		if best == -1 || len(m.Destination) > len(mounts[best].Destination) {
+			best, rel = i, r
+		}
+	}
+	if best == -1 {
+		return "", false
+	}
+	return filepath.Join(mounts[best].Source, rel), true
+}
+
+// DetectHostPath translates path, inside the container that this process is
+// running in, into the path that the host's docker daemon sees.
+func DetectHostPath(path string) (string, error) {
+	id, err := SelfContainerID()
+	if err != nil {
+		return "", fmt.Errorf("could not find the id of this container: %v", err)
+	}
+	mounts, err := ContainerMounts(id)
+	if err != nil {
+		return "", err
+	}
+	host, ok := HostPath(path, mounts)
+	if !ok {
+		return "", fmt.Errorf("%s is not in a volume mounted from the host", path)
+	}
+	return host, nil
+}
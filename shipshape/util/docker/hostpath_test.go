@@ -0,0 +1,46 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package docker
+
+import (
+	"testing"
+)
+
+func TestHostPath(t *testing.T) {
+	mounts := []Mount{
+		{"/var/lib/ci/build", "/workspace"},
+		{"/var/lib/ci/cache", "/workspace/cache"},
+		{"/var/run/docker.sock", "/var/run/docker.sock"},
+	}
+	tests := []struct {
+		path string
+		host string
+		ok   bool
+	}{
+		{"/workspace", "/var/lib/ci/build", true},
+		{"/workspace/src/app", "/var/lib/ci/build/src/app", true},
+		{"/workspace/cache/pip", "/var/lib/ci/cache/pip", true},
+		{"/workspace2/src", "", false},
+		{"/home/me", "", false},
+	}
+	for _, test := range tests {
+		host, ok := HostPath(test.path, mounts)
+		if host != test.host || ok != test.ok {
+			t.Errorf("HostPath(%q): got (%q, %v), want (%q, %v)", test.path, host, ok, test.host, test.ok)
+		}
+	}
+}
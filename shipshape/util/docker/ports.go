@@ -0,0 +1,61 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package docker
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// PortLabel records the host port that a container publishes, which is not
+// the usual one if another process was listening on that when it started.
+const PortLabel = "shipshape.port"
+
+// PortInUse returns whether some process is listening on port of the loopback
+// interface, where containers publish their ports.
+func PortInUse(port int) bool {
+	l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return true
+	}
+	l.Close()
+	return false
+}
+
+// AvailablePort returns port if nothing is listening on it, and otherwise a
+// port that nothing is listening on, as chosen by the kernel.
+func AvailablePort(port int) (int, error) {
+	if !PortInUse(port) {
+		return port, nil
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("port %d is in use, and could not find another one: %v", port, err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// PublishedPort returns the host port that container publishes, or port if
+// it was started before PortLabel was set.
+func PublishedPort(container string, port int) int {
+	if p, err := strconv.Atoi(label(container, PortLabel)); err == nil {
+		return p
+	}
+	return port
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package docker
+
+import (
+	"net"
+	"testing"
+)
+
+func TestAvailablePort(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %v", err)
+	}
+	taken := l.Addr().(*net.TCPAddr).Port
+	if !PortInUse(taken) {
+		t.Errorf("PortInUse(%d) = false while listening on it", taken)
+	}
+	port, err := AvailablePort(taken)
+	if err != nil {
+		t.Fatalf("AvailablePort(%d) failed: %v", taken, err)
+	}
+	if port == taken || PortInUse(port) {
+		t.Errorf("AvailablePort(%d) = %d, want a free port", taken, port)
+	}
+
+	l.Close()
+	if port, err := AvailablePort(taken); err != nil || port != taken {
+		t.Errorf("AvailablePort(%d) = %d, %v once it is free, want %d", taken, port, err, taken)
+	}
+}
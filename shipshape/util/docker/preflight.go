@@ -0,0 +1,174 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package docker
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	// MinAPIVersion is the oldest docker remote API that shipshape supports.
+	// Named volumes and container labels need at least 1.21 (docker 1.9).
+	MinAPIVersion = "1.21"
+
+	// minFreeBytes is the space needed to pull the shipshape images.
+	minFreeBytes = 2 << 30
+
+	// capSysAdmin is the capability bit that privileged containers have and
+	// that a docker daemon nested inside a container needs.
+	capSysAdmin = 21
+)
+
+// Problem is a docker precondition that does not hold.
+type Problem struct {
+	Check   string
+	Message string
+	// Remedy tells the user how to fix the problem.
+	Remedy string
+}
+
+func (p Problem) Error() string {
+	return fmt.Sprintf("%s: %s\n\t%s", p.Check, p.Message, p.Remedy)
+}
+
+// Preflight checks that docker can run shipshape: it is installed, the daemon
+// is reachable and recent enough, the user has permission to use it, and
+// there is disk space for the images. If dind is true, it also checks that
+// docker-in-docker can work. It returns the problems found, if any; if docker
+// can't be reached, the remaining checks are skipped.
+func Preflight(dind bool) []Problem {
+	if !HasDocker() {
+		return []Problem{{
+			Check:   "docker installed",
+			Message: "docker could not be found in PATH",
+			Remedy:  "Install docker (https://docs.docker.com/installation/) and make sure it is in your PATH.",
+		}}
+	}
+	out, err := exec.Command("docker", "version", "--format", "{{.Server.APIVersion}}").CombinedOutput()
+	if err != nil {
+		return []Problem{daemonProblem(string(out))}
+	}
+
+	var problems []Problem
+	if v := strings.TrimSpace(string(out)); compareVersions(v, MinAPIVersion) < 0 {
+		problems = append(problems, Problem{
+			Check:   "docker version",
+			Message: fmt.Sprintf("the docker daemon supports API version %s, but shipshape needs at least %s", v, MinAPIVersion),
+			Remedy:  "Upgrade docker to version 1.9 or later.",
+		})
+	}
+	if free, dir, err := FreeSpace(); err == nil && free < minFreeBytes {
+		problems = append(problems, Problem{
+			Check:   "disk space",
+			Message: fmt.Sprintf("only %d MB free in %s", free>>20, dir),
+			Remedy:  "Free up space, e.g. by removing unused images with 'docker rmi'.",
+		})
+	}
+	if dind {
+		if caps, err := effectiveCaps("/proc/self/status"); err == nil && caps&(1<<capSysAdmin) == 0 {
+			problems = append(problems, Problem{
+				Check:   "docker-in-docker",
+				Message: "--dind is set, but this process is not running in a privileged container",
+				Remedy:  "Start the container that runs shipshape with 'docker run --privileged'.",
+			})
+		}
+	}
+	return problems
+}
+
+// FreeSpace returns how many bytes are free in the directory where the docker
+// daemon keeps its images, and that directory. The directory may not be
+// visible to us (e.g. on a remote daemon or in a VM), in which case it
+// returns an error.
+func FreeSpace() (uint64, string, error) {
+	root, err := exec.Command("docker", "info", "--format", "{{.DockerRootDir}}").Output()
+	if err != nil {
+		return 0, "", fmt.Errorf("could not find the docker root directory: %v", err)
+	}
+	dir := strings.TrimSpace(string(root))
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(dir, &fs); err != nil {
+		return 0, dir, err
+	}
+	return uint64(fs.Bavail) * uint64(fs.Bsize), dir, nil
+}
+
+// daemonProblem explains why `docker version` could not reach the daemon,
+// given its output.
+func daemonProblem(output string) Problem {
+	msg := strings.TrimSpace(output)
+	if strings.Contains(msg, "permission denied") {
+		return Problem{
+			Check:   "docker permissions",
+			Message: msg,
+			Remedy:  "Add your user to the docker group with 'sudo usermod -aG docker $USER', then log in again.",
+		}
+	}
+	return Problem{
+		Check:   "docker daemon",
+		Message: msg,
+		Remedy:  "Start the docker daemon (e.g. 'sudo service docker start'), or set DOCKER_HOST to point at a running one.",
+	}
+}
+
+// compareVersions compares two dotted version numbers, returning -1, 0 or 1.
+// Missing or non-numeric parts compare as 0.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// effectiveCaps reads the effective capability set from a /proc/<pid>/status
+// file.
+func effectiveCaps(statusFile string) (uint64, error) {
+	f, err := os.Open(statusFile)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 2 && fields[0] == "CapEff:" {
+			return strconv.ParseUint(fields[1], 16, 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no CapEff in %s", statusFile)
+}
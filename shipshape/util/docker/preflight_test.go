@@ -0,0 +1,78 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.21", "1.21", 0},
+		{"1.9", "1.21", -1},
+		{"1.22", "1.21", 1},
+		{"2.0", "1.21", 1},
+		{"1.21.0", "1.21", 0},
+		{"", "1.21", -1},
+	}
+	for _, test := range tests {
+		if got := compareVersions(test.a, test.b); got != test.want {
+			t.Errorf("compareVersions(%q, %q): got %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestDaemonProblem(t *testing.T) {
+	tests := []struct {
+		output string
+		check  string
+	}{
+		{"Got permission denied while trying to connect to the Docker daemon socket", "docker permissions"},
+		{"Cannot connect to the Docker daemon. Is the docker daemon running on this host?", "docker daemon"},
+	}
+	for _, test := range tests {
+		if got := daemonProblem(test.output).Check; got != test.check {
+			t.Errorf("daemonProblem(%q): got check %q, want %q", test.output, got, test.check)
+		}
+	}
+}
+
+func TestEffectiveCaps(t *testing.T) {
+	f, err := ioutil.TempFile("", "status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("Name:\tsh\nCapInh:\t0000000000000000\nCapEff:\t00000000a80425fb\n")
+	f.Close()
+
+	caps, err := effectiveCaps(f.Name())
+	if err != nil {
+		t.Fatalf("effectiveCaps failed: %v", err)
+	}
+	if caps&(1<<capSysAdmin) != 0 {
+		t.Errorf("Unprivileged capabilities %x include CAP_SYS_ADMIN", caps)
+	}
+	if got, want := caps, uint64(0xa80425fb); got != want {
+		t.Errorf("Wrong capabilities: got %x, want %x", got, want)
+	}
+}
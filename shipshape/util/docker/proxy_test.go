@@ -0,0 +1,82 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package docker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClientProxy(t *testing.T) {
+	env := map[string]string{"https_proxy": "http://proxy:3128", "HTTP_PROXY": "http://proxy:8080", "NO_PROXY": "localhost"}
+	got := ClientProxy(func(name string) string { return env[name] })
+	want := ProxySettings{HTTP: "http://proxy:8080", HTTPS: "http://proxy:3128", NoProxy: "localhost"}
+	if got != want {
+		t.Errorf("Wrong proxy settings: got %v, want %v", got, want)
+	}
+	if !ClientProxy(func(string) string { return "" }).IsZero() {
+		t.Errorf("Expected no proxy in an empty environment")
+	}
+}
+
+func TestBlockedReason(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"Error response from daemon: Get https://gcr.io/v2/: proxyconnect tcp: dial tcp 10.0.0.1:3128: connect: connection refused", "proxy could not be reached"},
+		{"received unexpected HTTP status: 407 Proxy Authentication Required", "requires authentication"},
+		{"Get https://gcr.io/v2/: x509: certificate signed by unknown authority", "intercepts TLS"},
+		{"Get https://gcr.io/v2/: net/http: request canceled while waiting for connection (Client.Timeout exceeded while awaiting headers)", "firewall or proxy"},
+		{"Error: image example/analyzer:prod not found", ""},
+	}
+	for _, test := range tests {
+		got := blockedReason(test.output)
+		if (test.want == "") != (got == "") || !strings.Contains(got, test.want) {
+			t.Errorf("blockedReason(%q) = %q, want it to contain %q", test.output, got, test.want)
+		}
+	}
+}
+
+func TestPullProblem(t *testing.T) {
+	proxy := ProxySettings{HTTPS: "http://proxy:3128"}
+	const image = "gcr.io/shipshape_releases/service:prod"
+	tests := []struct {
+		desc           string
+		reason         string
+		client, daemon ProxySettings
+		want           string
+	}{
+		{"proxy only in the CLI's environment", "timed out", proxy, ProxySettings{}, "docker.service.d/http-proxy.conf"},
+		{"intercepting proxy", "the registry's certificate is not trusted", proxy, proxy, "/etc/docker/certs.d/gcr.io/ca.crt"},
+		{"proxy needs credentials", "the proxy requires authentication", proxy, proxy, "user:password@"},
+		{"no proxy anywhere", "timed out", ProxySettings{}, ProxySettings{}, "make sure that gcr.io is reachable"},
+		{"daemon proxy blocks the registry", "timed out", proxy, proxy, "NO_PROXY"},
+	}
+	for _, test := range tests {
+		p := pullProblem(image, test.reason, test.client, test.daemon)
+		if !strings.Contains(p.Message, image) {
+			t.Errorf("%s: message %q does not name the image", test.desc, p.Message)
+		}
+		if !strings.Contains(p.Remedy, test.want) {
+			t.Errorf("%s: remedy %q does not contain %q", test.desc, p.Remedy, test.want)
+		}
+	}
+	if p := PullProblem(image, "Error: manifest unknown"); p != nil {
+		t.Errorf("Expected no problem for a missing image, got %v", p)
+	}
+}
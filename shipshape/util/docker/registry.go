@@ -0,0 +1,357 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	dockerHub = "registry-1.docker.io"
+
+	manifestV2    = "application/vnd.docker.distribution.manifest.v2+json"
+	manifestList  = "application/vnd.docker.distribution.manifest.list.v2+json"
+	ociManifest   = "application/vnd.oci.image.manifest.v1+json"
+	ociIndex      = "application/vnd.oci.image.index.v1+json"
+	manifestTypes = manifestV2 + ", " + manifestList + ", " + ociManifest + ", " + ociIndex
+)
+
+// ImageRef is an image name split into the registry that serves it, the
+// repository within that registry and the tag.
+type ImageRef struct {
+	Registry   string
+	Repository string
+	Tag        string
+}
+
+// ParseImageRef splits image the way docker does: the first path component
+// is the registry if it looks like a host name, and otherwise the image is
+// on Docker Hub. The tag defaults to latest.
+func ParseImageRef(image string) ImageRef {
+	ref := ImageRef{Registry: dockerHub, Repository: image, Tag: "latest"}
+	if at := strings.Index(ref.Repository, "@"); at >= 0 {
+		ref.Repository = ref.Repository[:at]
+	}
+	if colon := strings.LastIndex(ref.Repository, ":"); colon > strings.LastIndex(ref.Repository, "/") {
+		ref.Repository, ref.Tag = ref.Repository[:colon], ref.Repository[colon+1:]
+	}
+	if slash := strings.Index(ref.Repository, "/"); slash >= 0 {
+		if host := ref.Repository[:slash]; strings.ContainsAny(host, ".:") || host == "localhost" {
+			ref.Registry, ref.Repository = host, ref.Repository[slash+1:]
+		}
+	}
+	if ref.Registry == dockerHub && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = "library/" + ref.Repository
+	}
+	return ref
+}
+
+// RemoteImage is the image that a tag currently points at in its registry.
+type RemoteImage struct {
+	// Digest is the digest of the manifest, which is what docker records in
+	// the RepoDigests of a pulled image.
+	Digest string
+	Labels map[string]string
+	// Size is the compressed size of the layers and the configuration of the
+	// linux/amd64 image, which is how much a pull downloads.
+	Size int64
+}
+
+// Registry looks up images with the docker registry HTTP API, without
+// pulling them. Only anonymous access is supported, so private images cannot
+// be looked up.
+type Registry struct {
+	Client *http.Client
+	// Scheme is the URL scheme of the registries; https if empty.
+	Scheme string
+}
+
+// Inspect returns the digest and labels of the image that the tag of image
+// points at. For a multi-platform image the labels are those of the
+// linux/amd64 image.
+func (r Registry) Inspect(image string) (*RemoteImage, error) {
+	ref := ParseImageRef(image)
+	s := &registrySession{Registry: r, ref: ref}
+	body, mediaType, digest, err := s.manifest(ref.Tag)
+	if err != nil {
+		return nil, err
+	}
+	remote := &RemoteImage{Digest: digest}
+	if mediaType == manifestList || mediaType == ociIndex {
+		if body, _, _, err = s.platformManifest(body); err != nil {
+			return nil, err
+		}
+	}
+	var m struct {
+		Config struct {
+			Digest string `json:"digest"`
+			Size   int64  `json:"size"`
+		} `json:"config"`
+		Layers []struct {
+			Size int64 `json:"size"`
+		} `json:"layers"`
+	}
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, fmt.Errorf("could not parse the manifest of %s: %v", image, err)
+	}
+	remote.Size = m.Config.Size
+	for _, l := range m.Layers {
+		remote.Size += l.Size
+	}
+	if m.Config.Digest == "" {
+		return remote, nil
+	}
+	config, err := s.get("blobs/"+m.Config.Digest, "")
+	if err != nil {
+		return nil, err
+	}
+	defer config.Body.Close()
+	var c struct {
+		Config struct {
+			Labels map[string]string `json:"Labels"`
+		} `json:"config"`
+	}
+	if err := json.NewDecoder(config.Body).Decode(&c); err != nil {
+		return nil, fmt.Errorf("could not parse the configuration of %s: %v", image, err)
+	}
+	remote.Labels = c.Config.Labels
+	return remote, nil
+}
+
+// registrySession makes requests for one repository, reusing the token that
+// the registry issued for it.
+type registrySession struct {
+	Registry
+	ref   ImageRef
+	token string
+}
+
+// manifest fetches the manifest for reference, which is a tag or a digest,
+// and returns it with its media type and digest.
+func (s *registrySession) manifest(reference string) ([]byte, string, string, error) {
+	resp, err := s.get("manifests/"+reference, manifestTypes)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("could not read the manifest of %s: %v", s.ref.Repository, err)
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		digest = fmt.Sprintf("sha256:%x", sha256.Sum256(body))
+	}
+	mediaType := resp.Header.Get("Content-Type")
+	if i := strings.Index(mediaType, ";"); i >= 0 {
+		mediaType = mediaType[:i]
+	}
+	return body, mediaType, digest, nil
+}
+
+// platformManifest fetches the linux/amd64 manifest listed in index, or the
+// first one if there is none for that platform.
+func (s *registrySession) platformManifest(index []byte) ([]byte, string, string, error) {
+	var list struct {
+		Manifests []struct {
+			Digest   string `json:"digest"`
+			Platform struct {
+				Architecture string `json:"architecture"`
+				OS           string `json:"os"`
+			} `json:"platform"`
+		} `json:"manifests"`
+	}
+	if err := json.Unmarshal(index, &list); err != nil {
+		return nil, "", "", fmt.Errorf("could not parse the manifest list of %s: %v", s.ref.Repository, err)
+	}
+	if len(list.Manifests) == 0 {
+		return nil, "", "", fmt.Errorf("the manifest list of %s is empty", s.ref.Repository)
+	}
+	digest := list.Manifests[0].Digest
+	for _, m := range list.Manifests {
+		if m.Platform.OS == "linux" && m.Platform.Architecture == "amd64" {
+			digest = m.Digest
+			break
+		}
+	}
+	return s.manifest(digest)
+}
+
+// get requests path within the repository. If the registry asks for a
+// token, an anonymous one is fetched and the request is retried with it.
+func (s *registrySession) get(path, accept string) (*http.Response, error) {
+	scheme := s.Scheme
+	if scheme == "" {
+		scheme = "https"
+	}
+	u := fmt.Sprintf("%s://%s/v2/%s/%s", scheme, s.ref.Registry, s.ref.Repository, path)
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if s.token != "" {
+			req.Header.Set("Authorization", "Bearer "+s.token)
+		}
+		resp, err := s.client().Do(req)
+		if err != nil {
+			return nil, unreachable(s.ref.Registry, err.Error())
+		}
+		if resp.StatusCode == http.StatusProxyAuthRequired {
+			resp.Body.Close()
+			return nil, unreachable(s.ref.Registry, resp.Status)
+		}
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			if s.token, err = s.fetchToken(challenge); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s returned %s for %s", s.ref.Registry, resp.Status, u)
+		}
+		return resp, nil
+	}
+}
+
+// unreachable is the error for a request to registry that failed with msg. If
+// a proxy or firewall looks to be in the way, it says so.
+func unreachable(registry, msg string) error {
+	if reason := blockedReason(msg); reason != "" {
+		return fmt.Errorf("could not reach %s: %s (%s). If you are behind a proxy, set HTTPS_PROXY, and NO_PROXY for any registries that can be reached directly", registry, reason, msg)
+	}
+	return fmt.Errorf("could not reach %s: %v", registry, msg)
+}
+
+// fetchToken gets an anonymous token as described by a Bearer challenge.
+func (s *registrySession) fetchToken(challenge string) (string, error) {
+	params := parseChallenge(challenge)
+	if params == nil || params["realm"] == "" {
+		return "", fmt.Errorf("%s requires authentication that is not supported: %q", s.ref.Registry, challenge)
+	}
+	q := url.Values{}
+	for _, k := range []string{"service", "scope"} {
+		if params[k] != "" {
+			q.Set(k, params[k])
+		}
+	}
+	if q.Get("scope") == "" {
+		q.Set("scope", "repository:"+s.ref.Repository+":pull")
+	}
+	resp, err := s.client().Get(params["realm"] + "?" + q.Encode())
+	if err != nil {
+		return "", fmt.Errorf("could not get a token for %s: %v", s.ref.Registry, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not get a token for %s: %s", s.ref.Registry, resp.Status)
+	}
+	var t struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return "", fmt.Errorf("could not parse the token from %s: %v", s.ref.Registry, err)
+	}
+	if t.Token == "" {
+		return t.AccessToken, nil
+	}
+	return t.Token, nil
+}
+
+func (s *registrySession) client() *http.Client {
+	if s.Client == nil {
+		return http.DefaultClient
+	}
+	return s.Client
+}
+
+// parseChallenge returns the parameters of a WWW-Authenticate header such as
+// `Bearer realm="https://auth",service="registry"`, or nil if it is not a
+// Bearer challenge.
+func parseChallenge(challenge string) map[string]string {
+	const prefix = "bearer "
+	if len(challenge) < len(prefix) || strings.ToLower(challenge[:len(prefix)]) != prefix {
+		return nil
+	}
+	params := make(map[string]string)
+	rest := challenge[len(prefix):]
+	for rest != "" {
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(strings.TrimLeft(rest[:eq], ",")))
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else if comma := strings.Index(rest, ","); comma >= 0 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[key] = value
+	}
+	return params
+}
+
+// LocalRepoDigest returns the digest that image had in its registry when it
+// was last pulled, or the empty string if it was never pulled (for example
+// because it was built locally).
+func LocalRepoDigest(image string) string {
+	out, err := inspect(image, "{{range .RepoDigests}}{{.}} {{end}}")
+	if err != nil {
+		return ""
+	}
+	return matchRepoDigest(image, strings.Fields(trimInspect(out)))
+}
+
+// matchRepoDigest picks the digest for the repository of image from the
+// RepoDigests of a local image, which lists one for each repository that it
+// was pulled from.
+func matchRepoDigest(image string, repoDigests []string) string {
+	want := ParseImageRef(image)
+	for _, rd := range repoDigests {
+		at := strings.LastIndex(rd, "@")
+		if at < 0 {
+			continue
+		}
+		got := ParseImageRef(rd[:at])
+		if got.Registry == want.Registry && got.Repository == want.Repository {
+			return rd[at+1:]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package docker
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseImageRef(t *testing.T) {
+	tests := []struct {
+		image string
+		want  ImageRef
+	}{
+		{"gcr.io/shipshape_releases/service:prod", ImageRef{"gcr.io", "shipshape_releases/service", "prod"}},
+		{"localhost:5000/analyzer", ImageRef{"localhost:5000", "analyzer", "latest"}},
+		{"example/analyzer:v1", ImageRef{dockerHub, "example/analyzer", "v1"}},
+		{"ubuntu", ImageRef{dockerHub, "library/ubuntu", "latest"}},
+		{"gcr.io/a/b@sha256:abc", ImageRef{"gcr.io", "a/b", "latest"}},
+	}
+	for _, test := range tests {
+		if got := ParseImageRef(test.image); got != test.want {
+			t.Errorf("ParseImageRef(%q): got %v, want %v", test.image, got, test.want)
+		}
+	}
+}
+
+func TestMatchRepoDigest(t *testing.T) {
+	digests := []string{"example/analyzer@sha256:hub", "gcr.io/example/analyzer@sha256:gcr"}
+	if got, want := matchRepoDigest("gcr.io/example/analyzer:prod", digests), "sha256:gcr"; got != want {
+		t.Errorf("Wrong digest for gcr.io: got %q, want %q", got, want)
+	}
+	if got, want := matchRepoDigest("example/analyzer", digests), "sha256:hub"; got != want {
+		t.Errorf("Wrong digest for Docker Hub: got %q, want %q", got, want)
+	}
+	if got := matchRepoDigest("other/analyzer", digests); got != "" {
+		t.Errorf("Expected no digest for an image that was not pulled, got %q", got)
+	}
+}
+
+func TestRegistryInspect(t *testing.T) {
+	const (
+		indexDigest    = "sha256:index"
+		manifestDigest = "sha256:amd64"
+		configDigest   = "sha256:config"
+	)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if got, want := r.URL.Query().Get("scope"), "repository:shipshape/service:pull"; got != want {
+				t.Errorf("Wrong token scope: got %q, want %q", got, want)
+			}
+			fmt.Fprint(w, `{"token": "secret"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="repository:shipshape/service:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/shipshape/service/manifests/prod":
+			if !strings.Contains(r.Header.Get("Accept"), manifestList) {
+				t.Errorf("Manifest lists not accepted: %q", r.Header.Get("Accept"))
+			}
+			w.Header().Set("Content-Type", manifestList)
+			w.Header().Set("Docker-Content-Digest", indexDigest)
+			fmt.Fprintf(w, `{"manifests": [{"digest": "sha256:arm64", "platform": {"architecture": "arm64", "os": "linux"}},
+				{"digest": "%s", "platform": {"architecture": "amd64", "os": "linux"}}]}`, manifestDigest)
+		case "/v2/shipshape/service/manifests/" + manifestDigest:
+			w.Header().Set("Content-Type", manifestV2)
+			fmt.Fprintf(w, `{"config": {"digest": "%s", "size": 100}, "layers": [{"size": 1000}, {"size": 20000}]}`, configDigest)
+		case "/v2/shipshape/service/blobs/" + configDigest:
+			fmt.Fprint(w, `{"config": {"Labels": {"shipshape.changelog": "https://example.com/changes"}}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	r := Registry{Scheme: "http"}
+	host := strings.TrimPrefix(server.URL, "http://")
+	got, err := r.Inspect(host + "/shipshape/service:prod")
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	want := &RemoteImage{
+		Digest: indexDigest,
+		Labels: map[string]string{"shipshape.changelog": "https://example.com/changes"},
+		Size:   21100,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong image: got %v, want %v", got, want)
+	}
+
+	if _, err := r.Inspect(host + "/shipshape/kythe:prod"); err == nil {
+		t.Errorf("Expected an error for an image that is not in the registry")
+	}
+}
+
+func TestRegistryBehindProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusProxyAuthRequired)
+	}))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := Registry{Scheme: "http", Client: &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}}
+	_, err = r.Inspect("gcr.io/example/analyzer:prod")
+	if err == nil {
+		t.Fatalf("Expected an error from a proxy that requires authentication")
+	}
+	for _, want := range []string{"gcr.io", "the proxy requires authentication", "HTTPS_PROXY"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Error %q does not contain %q", err, want)
+		}
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	got := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/ubuntu:pull"`)
+	want := map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/ubuntu:pull",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong parameters: got %v, want %v", got, want)
+	}
+	if got := parseChallenge(`Basic realm="registry"`); got != nil {
+		t.Errorf("Expected no parameters for a Basic challenge, got %v", got)
+	}
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package docker
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+const (
+	// SandboxNetwork is the internal docker network that offline analyzers run
+	// on. It has no route out of the host; the service joins it to reach them.
+	SandboxNetwork = "shipshape-sandbox"
+
+	// SandboxLabel records the Sandbox that an analyzer container was started
+	// with.
+	SandboxLabel = "shipshape.sandbox"
+)
+
+// Sandbox is the security profile of an analyzer container. The zero Sandbox
+// runs the container with docker's defaults.
+type Sandbox struct {
+	// Offline runs the container on SandboxNetwork rather than the default
+	// bridge, and publishes no ports on the host.
+	Offline bool
+	// ReadOnly mounts the root filesystem read-only, with a tmpfs at /tmp.
+	ReadOnly bool
+	// Drop lists the Linux capabilities to drop, e.g. NET_RAW, or ALL.
+	Drop []string
+	// Seccomp is the path of a seccomp profile to run the container with.
+	Seccomp string
+}
+
+// StrictSandbox returns the tight defaults for third-party analyzers: offline,
+// with a read-only root filesystem and all capabilities dropped.
+func StrictSandbox() Sandbox {
+	return Sandbox{Offline: true, ReadOnly: true, Drop: []string{"ALL"}}
+}
+
+// Tighten returns a Sandbox with the restrictions of both s and other. If
+// both have a seccomp profile, the one of other is used.
+func (s Sandbox) Tighten(other Sandbox) Sandbox {
+	t := Sandbox{
+		Offline:  s.Offline || other.Offline,
+		ReadOnly: s.ReadOnly || other.ReadOnly,
+		Seccomp:  s.Seccomp,
+	}
+	if other.Seccomp != "" {
+		t.Seccomp = other.Seccomp
+	}
+	seen := make(map[string]bool)
+	for _, c := range append(append([]string(nil), s.Drop...), other.Drop...) {
+		c = strings.ToUpper(c)
+		if !seen[c] {
+			seen[c] = true
+			t.Drop = append(t.Drop, c)
+		}
+	}
+	sort.Strings(t.Drop)
+	return t
+}
+
+// IsZero returns whether s has no restrictions.
+func (s Sandbox) IsZero() bool {
+	return !s.Offline && !s.ReadOnly && len(s.Drop) == 0 && s.Seccomp == ""
+}
+
+// String describes the restrictions of s, e.g. "offline,readonly,drop=ALL",
+// or returns the empty string if it has none.
+func (s Sandbox) String() string {
+	var parts []string
+	if s.Offline {
+		parts = append(parts, "offline")
+	}
+	if s.ReadOnly {
+		parts = append(parts, "readonly")
+	}
+	if len(s.Drop) > 0 {
+		parts = append(parts, "drop="+strings.Join(s.Drop, "+"))
+	}
+	if s.Seccomp != "" {
+		parts = append(parts, "seccomp="+s.Seccomp)
+	}
+	return strings.Join(parts, ",")
+}
+
+// args returns the docker run flags that apply s. A sandboxed container also
+// cannot gain privileges, e.g. through setuid binaries.
+func (s Sandbox) args() []string {
+	if s.IsZero() {
+		return nil
+	}
+	var args []string
+	if s.Offline {
+		args = append(args, "--network="+SandboxNetwork)
+	}
+	if s.ReadOnly {
+		args = append(args, "--read-only", "--tmpfs=/tmp")
+	}
+	for _, c := range s.Drop {
+		args = append(args, "--cap-drop="+c)
+	}
+	if s.Seccomp != "" {
+		args = append(args, "--security-opt=seccomp="+s.Seccomp)
+	}
+	return append(args, "--security-opt=no-new-privileges")
+}
+
+// EnsureSandboxNetwork creates SandboxNetwork as an internal network if it
+// does not exist yet.
+func EnsureSandboxNetwork() error {
+	if _, err := exec.Command("docker", "network", "inspect", SandboxNetwork).CombinedOutput(); err == nil {
+		return nil
+	}
+	out, err := exec.Command("docker", "network", "create", "--internal", SandboxNetwork).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("could not create network %s: %v\n%s", SandboxNetwork, err, out)
+	}
+	return nil
+}
+
+// onSandboxNetwork returns whether container is connected to SandboxNetwork.
+func onSandboxNetwork(container string) bool {
+	out, err := inspect(container, fmt.Sprintf(`{{if index .NetworkSettings.Networks %q}}true{{end}}`, SandboxNetwork))
+	return err == nil && trimInspect(out) == "true"
+}
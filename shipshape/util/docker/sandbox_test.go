@@ -0,0 +1,88 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package docker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSandboxArgs(t *testing.T) {
+	tests := []struct {
+		sandbox Sandbox
+		want    []string
+	}{
+		{Sandbox{}, nil},
+		{
+			Sandbox{Offline: true},
+			[]string{"--network=shipshape-sandbox", "--security-opt=no-new-privileges"},
+		},
+		{
+			Sandbox{ReadOnly: true, Drop: []string{"NET_RAW", "SYS_ADMIN"}, Seccomp: "/src/seccomp.json"},
+			[]string{
+				"--read-only",
+				"--tmpfs=/tmp",
+				"--cap-drop=NET_RAW",
+				"--cap-drop=SYS_ADMIN",
+				"--security-opt=seccomp=/src/seccomp.json",
+				"--security-opt=no-new-privileges",
+			},
+		},
+	}
+	for _, test := range tests {
+		if got := test.sandbox.args(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: wrong args: got %v, want %v", test.sandbox, got, test.want)
+		}
+	}
+}
+
+func TestSandboxTighten(t *testing.T) {
+	configured := Sandbox{Drop: []string{"net_raw"}, Seccomp: "/src/seccomp.json"}
+	got := configured.Tighten(StrictSandbox())
+	want := Sandbox{Offline: true, ReadOnly: true, Drop: []string{"ALL", "NET_RAW"}, Seccomp: "/src/seccomp.json"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong tightened sandbox: got %v, want %v", got, want)
+	}
+	if got := (Sandbox{}).Tighten(Sandbox{}); !got.IsZero() {
+		t.Errorf("Tightening two empty sandboxes gave %v, want an empty one", got)
+	}
+}
+
+func TestSandboxLabel(t *testing.T) {
+	if got, want := StrictSandbox().String(), "offline,readonly,drop=ALL"; got != want {
+		t.Errorf("Wrong label: got %q, want %q", got, want)
+	}
+	got := labelArgs(analyzerLabels("sha256:abc", "/src", NoAccess, nil, StrictSandbox()))
+	want := []string{
+		"--label=shipshape.docker_access=none",
+		"--label=shipshape.image=sha256:abc",
+		"--label=shipshape.sandbox=offline,readonly,drop=ALL",
+		"--label=shipshape.workspace=/src",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong label args: got %v, want %v", got, want)
+	}
+}
+
+func TestSandboxAccess(t *testing.T) {
+	if got, want := sandboxAccess(SocketAccess, Sandbox{}), SocketAccess; got != want {
+		t.Errorf("Unsandboxed access: got %v, want %v", got, want)
+	}
+	if got, want := sandboxAccess(DindAccess, Sandbox{ReadOnly: true}), NoAccess; got != want {
+		t.Errorf("Sandboxed access: got %v, want %v", got, want)
+	}
+}
@@ -18,6 +18,7 @@
 package httpencoding
 
 import (
+	"bytes"
 	"compress/gzip"
 	"compress/zlib"
 	"fmt"
@@ -26,50 +27,102 @@ import (
 	"strings"
 )
 
+// AcceptRequestEncodingHeader is set by servers on their responses to list
+// the encodings that they accept for request bodies. Clients should only
+// compress requests once a server has advertised that it can decode them.
+const AcceptRequestEncodingHeader = "X-Accept-Request-Encoding"
+
+// MinCompressSize is the smallest request body that is worth compressing.
+const MinCompressSize = 1024
+
+// A FlushWriteCloser is an encoding writer that can also flush the data
+// written so far through to the client, as needed when streaming.
+type FlushWriteCloser interface {
+	io.WriteCloser
+	Flush() error
+}
+
 // CompressData returns a writer that writes encoded data to w. The chosen
 // encoding is based on the Accept-Encoding header and defaults to the identity
-// encoding.
-func CompressData(w http.ResponseWriter, r *http.Request) io.WriteCloser {
+// encoding. It also advertises that the server accepts gzip-encoded requests.
+func CompressData(w http.ResponseWriter, r *http.Request) FlushWriteCloser {
+	w.Header().Set(AcceptRequestEncodingHeader, "gzip")
 	encodings := strings.Split(r.Header.Get("Accept-Encoding"), ",")
 	for _, encoding := range encodings {
-		switch encoding {
+		// Ignore any quality value (e.g. "gzip;q=1.0").
+		if i := strings.Index(encoding, ";"); i >= 0 {
+			encoding = encoding[:i]
+		}
+		switch strings.TrimSpace(encoding) {
 		case "gzip":
 			w.Header().Set("Content-Encoding", "gzip")
-			return gzip.NewWriter(w)
+			return &flushWriter{gzip.NewWriter(w), w}
 		case "deflate":
 			w.Header().Set("Content-Encoding", "deflate")
-			return zlib.NewWriter(w)
+			return &flushWriter{zlib.NewWriter(w), w}
 		case "identity":
-			return noopCloser{w}
+			return &flushWriter{noopCloser{w}, w}
 		}
 	}
-	return noopCloser{w}
+	return &flushWriter{noopCloser{w}, w}
 }
 
 // UncompressData returns a reads that decodes data from r.Body. The encoding is
 // determined based on the Content-Encoding header and an error is returned if
 // the encoding is unknown.
 func UncompressData(r *http.Response) (io.ReadCloser, error) {
-	encoding := r.Header.Get("Content-Encoding")
+	return decode(r.Header.Get("Content-Encoding"), r.Body)
+}
+
+// UncompressRequest returns a reader that decodes the body of the request r,
+// as UncompressData does for responses.
+func UncompressRequest(r *http.Request) (io.ReadCloser, error) {
+	return decode(r.Header.Get("Content-Encoding"), r.Body)
+}
+
+// AcceptsRequestEncoding returns whether the server that sent r advertised
+// that it accepts request bodies with the given encoding.
+func AcceptsRequestEncoding(r *http.Response, encoding string) bool {
+	for _, e := range strings.Split(r.Header.Get(AcceptRequestEncodingHeader), ",") {
+		if strings.TrimSpace(e) == encoding {
+			return true
+		}
+	}
+	return false
+}
+
+// Gzip returns data compressed with gzip.
+func Gzip(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decode(encoding string, body io.ReadCloser) (io.ReadCloser, error) {
 	var (
 		cr  io.ReadCloser
 		err error
 	)
 	switch encoding {
 	case "gzip":
-		cr, err = gzip.NewReader(r.Body)
+		cr, err = gzip.NewReader(body)
 	case "deflate":
-		cr, err = zlib.NewReader(r.Body)
-	case "identity":
-	case "":
-		return r.Body, nil
+		cr, err = zlib.NewReader(body)
+	case "identity", "":
+		return body, nil
 	default:
 		return nil, fmt.Errorf("unknown encoding: %q", encoding)
 	}
 	if err != nil {
 		return nil, err
 	}
-	return &decodedReader{r.Body, cr}, nil
+	return &decodedReader{body, cr}, nil
 }
 
 // noopCloser is a io.WriteCloser with a no-op Close
@@ -82,6 +135,28 @@ func (noopCloser) Close() error {
 	return nil
 }
 
+// flushWriter is an encoding writer that, when flushed, flushes both the
+// encoder and the underlying http.ResponseWriter.
+type flushWriter struct {
+	io.WriteCloser
+	w http.ResponseWriter
+}
+
+// Flush implements FlushWriteCloser.
+func (f *flushWriter) Flush() error {
+	if fl, ok := f.WriteCloser.(interface {
+		Flush() error
+	}); ok {
+		if err := fl.Flush(); err != nil {
+			return err
+		}
+	}
+	if fl, ok := f.w.(http.Flusher); ok {
+		fl.Flush()
+	}
+	return nil
+}
+
 type decodedReader struct {
 	orig io.ReadCloser
 	r    io.ReadCloser
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2014 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package httpencoding
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("some note text "), 200)
+	compressed, err := Gzip(data)
+	if err != nil {
+		t.Fatalf("Gzip failed: %v", err)
+	}
+	if len(compressed) >= len(data) {
+		t.Errorf("Compressed size %d is not smaller than %d", len(compressed), len(data))
+	}
+
+	tests := []struct {
+		encoding string
+		body     []byte
+	}{
+		{"gzip", compressed},
+		{"identity", data},
+		{"", data},
+	}
+	for _, test := range tests {
+		r, err := http.NewRequest("POST", "http://localhost/", bytes.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.encoding != "" {
+			r.Header.Set("Content-Encoding", test.encoding)
+		}
+		body, err := UncompressRequest(r)
+		if err != nil {
+			t.Errorf("%q: UncompressRequest failed: %v", test.encoding, err)
+			continue
+		}
+		got, err := ioutil.ReadAll(body)
+		if err != nil {
+			t.Errorf("%q: could not read the body: %v", test.encoding, err)
+		} else if !bytes.Equal(got, data) {
+			t.Errorf("%q: wrong body: got %d bytes, want %d", test.encoding, len(got), len(data))
+		}
+	}
+}
+
+func TestCompressData(t *testing.T) {
+	tests := []struct {
+		accept   string
+		encoding string
+	}{
+		{"gzip", "gzip"},
+		{"deflate, gzip", "deflate"},
+		{"gzip;q=1.0, identity;q=0.5", "gzip"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		r, err := http.NewRequest("POST", "http://localhost/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Accept-Encoding", test.accept)
+		w := httptest.NewRecorder()
+		cw := CompressData(w, r)
+		cw.Write([]byte("hello"))
+		if err := cw.Flush(); err != nil {
+			t.Errorf("%q: Flush failed: %v", test.accept, err)
+		}
+		cw.Close()
+		if got := w.Header().Get("Content-Encoding"); got != test.encoding {
+			t.Errorf("%q: wrong encoding: got %q, want %q", test.accept, got, test.encoding)
+		}
+		if got, want := w.Header().Get(AcceptRequestEncodingHeader), "gzip"; got != want {
+			t.Errorf("%q: wrong accepted request encodings: got %q, want %q", test.accept, got, want)
+		}
+	}
+}
@@ -66,6 +66,7 @@ package client
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -102,9 +103,15 @@ var httpClient = &http.Client{
 // httpTransport is a handle for a K-RPC HTTP server.
 type httpTransport struct {
 	url *url.URL
+	// client sends the requests. If nil, httpClient is used.
+	client *http.Client
 
 	// atomically incremented id per request sent
 	id uint64
+
+	// gzipRequests is set to 1 (atomically) once the server has advertised
+	// that it accepts gzip-encoded requests.
+	gzipRequests int32
 }
 
 // A Transport is a K-RPC protocol implementation
@@ -131,6 +138,23 @@ func NewHTTPClient(addr string) *Client {
 	return &Client{&httpTransport{url: u}}
 }
 
+// NewUnixClient creates a client connected to a K-RPC server listening for
+// HTTP on the unix domain socket at path.
+func NewUnixClient(path string) *Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	c := &http.Client{
+		Transport: &http.Transport{
+			MaxIdleConnsPerHost: 128,
+			// The host of the URL is ignored; every connection is to path.
+			Dial: func(network, addr string) (net.Conn, error) {
+				return dialer.Dial("unix", path)
+			},
+		},
+	}
+	u := &url.URL{Scheme: "http", Host: "unix", Path: "/"}
+	return &Client{&httpTransport{url: u, client: c}}
+}
+
 func discardAndClose(r io.ReadCloser) error {
 	io.Copy(ioutil.Discard, r) // Ignore errors
 	if err := r.Close(); err != nil {
@@ -145,7 +169,7 @@ func logDiscardAndClose(r io.ReadCloser) {
 	}
 }
 
-func encodeRequest(version string, id *uint64, serviceMethod string, params interface{}) ([]byte, error) {
+func encodeRequest(version string, id *uint64, serviceMethod string, params interface{}, heartbeat time.Duration) ([]byte, error) {
 	data, err := json.Marshal(params)
 	if err != nil {
 		return nil, fmt.Errorf("error encoding params: %v", err)
@@ -155,10 +179,11 @@ func encodeRequest(version string, id *uint64, serviceMethod string, params inte
 		return nil, fmt.Errorf("error encoding id: %v", err)
 	}
 	req, err := json.Marshal(&protocol.Request{
-		Version: version,
-		ID:      idData,
-		Method:  serviceMethod,
-		Params:  data,
+		Version:         version,
+		ID:              idData,
+		Method:          serviceMethod,
+		Params:          data,
+		HeartbeatMillis: int64(heartbeat / time.Millisecond),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error encoding protocol request: %v", err)
@@ -168,23 +193,45 @@ func encodeRequest(version string, id *uint64, serviceMethod string, params inte
 
 // SendRequest implements the Transport interface over HTTP
 func (c *httpTransport) SendRequest(version string, serviceMethod string, params interface{}) (io.ReadCloser, error) {
-	req, err := encodeRequest(version, &c.id, serviceMethod, params)
+	return c.sendRequest(version, serviceMethod, params, 0)
+}
+
+// sendRequest is SendRequest, also asking for heartbeats at the given
+// interval if it is positive.
+func (c *httpTransport) sendRequest(version string, serviceMethod string, params interface{}, heartbeat time.Duration) (io.ReadCloser, error) {
+	req, err := encodeRequest(version, &c.id, serviceMethod, params, heartbeat)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := httpClient.Do(&http.Request{
-		Method: "POST",
-		URL:    c.url,
-		Header: map[string][]string{
-			"Content-Type":    []string{httpContentType},
-			"Accept-Encoding": []string{"gzip", "deflate"},
-		},
+	header := map[string][]string{
+		"Content-Type":    []string{httpContentType},
+		"Accept-Encoding": []string{"gzip", "deflate"},
+	}
+	if len(req) >= httpencoding.MinCompressSize && atomic.LoadInt32(&c.gzipRequests) == 1 {
+		compressed, err := httpencoding.Gzip(req)
+		if err != nil {
+			return nil, fmt.Errorf("error compressing request: %v", err)
+		}
+		req = compressed
+		header["Content-Encoding"] = []string{"gzip"}
+	}
+	hc := c.client
+	if hc == nil {
+		hc = httpClient
+	}
+	resp, err := hc.Do(&http.Request{
+		Method:        "POST",
+		URL:           c.url,
+		Header:        header,
 		Body:          ioutil.NopCloser(bytes.NewBuffer(req)),
 		ContentLength: int64(len(req)),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("HTTP failure: %v", err)
 	}
+	if httpencoding.AcceptsRequestEncoding(resp, "gzip") {
+		atomic.StoreInt32(&c.gzipRequests, 1)
+	}
 
 	if resp.StatusCode != 200 {
 		message := new(bytes.Buffer)
@@ -209,7 +256,7 @@ func unmarshalResult(dec *json.Decoder, result interface{}) (*protocol.Response,
 	switch {
 	case resp.Error != nil:
 		return nil, resp.Error
-	case resp.Success:
+	case resp.Success, resp.Heartbeat:
 		return &resp, nil
 	default:
 		if err := json.Unmarshal(resp.Result, result); err != nil {
@@ -233,12 +280,47 @@ func (c *Client) Call(serviceMethod string, params interface{}, result interface
 	return err
 }
 
+// ErrTimedOut is returned by CallTimeout if the call does not finish in time.
+var ErrTimedOut = errors.New("rpc call timed out")
+
+// CallTimeout is like Call, but returns ErrTimedOut if the result has not been
+// received within timeout. If timeout is not positive, it is the same as Call.
+// A call that times out is abandoned, not cancelled; its result is discarded
+// by the server side.
+func (c *Client) CallTimeout(serviceMethod string, params interface{}, result interface{}, timeout time.Duration) error {
+	if timeout <= 0 {
+		return c.Call(serviceMethod, params, result)
+	}
+	done := make(chan error, 1)
+	var raw json.RawMessage
+	go func() {
+		done <- c.Call(serviceMethod, params, &raw)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(raw, result); err != nil {
+			return fmt.Errorf("error unmarshalling result: %v", err)
+		}
+		return nil
+	case <-timer.C:
+		return ErrTimedOut
+	}
+}
+
 // Reader provides sequential access to a streaming RPC call's results. When no
 // longer used, Readers must be Closed to ensure resources are not leaked.
 type Reader struct {
 	resp io.ReadCloser
 	dec  *json.Decoder
 	err  error
+
+	// idle, if set, closes resp when the stream has been idle too long.
+	idle *idleReader
 }
 
 // NextResult decodes the next available value into result. io.EOF is returned
@@ -249,7 +331,13 @@ func (r *Reader) NextResult(result interface{}) error {
 	}
 
 	resp, err := unmarshalResult(r.dec, result)
+	for err == nil && resp.Heartbeat {
+		resp, err = unmarshalResult(r.dec, result)
+	}
 	if err != nil {
+		if r.idle != nil && r.idle.timedOut() {
+			err = ErrIdleTimeout
+		}
 		r.err = err
 		return err
 	}
@@ -270,6 +358,9 @@ func (r *Reader) Close() error {
 		// may happen if there was an initial Reader error set
 		return nil
 	}
+	if r.idle != nil {
+		r.idle.stop()
+	}
 	return discardAndClose(r.resp)
 }
 
@@ -277,7 +368,76 @@ func (r *Reader) Close() error {
 // accessed through the returned Reader.
 func (c *Client) Stream(serviceMethod string, params interface{}) *Reader {
 	resp, err := c.SendRequest(protocol.Version2Streaming, serviceMethod, params)
-	return &Reader{resp, json.NewDecoder(resp), err}
+	return &Reader{resp: resp, dec: json.NewDecoder(resp), err: err}
+}
+
+// ErrIdleTimeout is returned by a Reader when nothing has been received on the
+// stream for longer than its StreamOptions.IdleTimeout.
+var ErrIdleTimeout = errors.New("rpc stream was idle for too long")
+
+// StreamOptions configures a call made with StreamWith.
+type StreamOptions struct {
+	// Heartbeat asks the server to send a heartbeat at this interval, so that
+	// the stream is not idle while results are being computed. Heartbeats are
+	// skipped by the Reader.
+	Heartbeat time.Duration
+	// IdleTimeout ends the stream with ErrIdleTimeout if nothing, not even a
+	// heartbeat, has been received for this long. If zero, the stream can be
+	// idle indefinitely.
+	IdleTimeout time.Duration
+}
+
+// StreamWith is like Stream, but with heartbeats and an idle timeout as
+// given in opts. Heartbeats are only supported by the HTTP transport.
+func (c *Client) StreamWith(serviceMethod string, params interface{}, opts StreamOptions) *Reader {
+	var (
+		resp io.ReadCloser
+		err  error
+	)
+	if t, ok := c.Transport.(*httpTransport); ok {
+		resp, err = t.sendRequest(protocol.Version2Streaming, serviceMethod, params, opts.Heartbeat)
+	} else {
+		resp, err = c.SendRequest(protocol.Version2Streaming, serviceMethod, params)
+	}
+	if err != nil || opts.IdleTimeout <= 0 {
+		return &Reader{resp: resp, dec: json.NewDecoder(resp), err: err}
+	}
+	idle := newIdleReader(resp, opts.IdleTimeout)
+	return &Reader{resp: resp, dec: json.NewDecoder(idle), idle: idle}
+}
+
+// idleReader closes the underlying reader if no Read returns data for longer
+// than the timeout, which makes a blocked Read fail.
+type idleReader struct {
+	r       io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	expired int32
+}
+
+func newIdleReader(r io.ReadCloser, timeout time.Duration) *idleReader {
+	i := &idleReader{r: r, timeout: timeout}
+	i.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&i.expired, 1)
+		r.Close()
+	})
+	return i
+}
+
+func (i *idleReader) Read(p []byte) (int, error) {
+	n, err := i.r.Read(p)
+	if n > 0 {
+		i.timer.Reset(i.timeout)
+	}
+	return n, err
+}
+
+func (i *idleReader) timedOut() bool {
+	return atomic.LoadInt32(&i.expired) == 1
+}
+
+func (i *idleReader) stop() {
+	i.timer.Stop()
 }
 
 // WriteStream calls the given method and writes each JSON response to w.
@@ -350,6 +510,10 @@ func (r *PipeReader) Receive(result interface{}, f func(id []byte, err error, su
 			return err
 		}
 
+		if resp.Heartbeat {
+			continue
+		}
+
 		if resp.Error == nil && !resp.Success {
 			if err := json.Unmarshal(resp.Result, result); err != nil {
 				return fmt.Errorf("error unmarshalling result: %v", err)
@@ -385,7 +549,7 @@ func NewPipeWriter(w io.Writer) *PipeWriter {
 
 // Send writes a server request for the given serviceMethod and params value.
 func (c *PipeWriter) Send(serviceMethod string, params interface{}) error {
-	if req, err := encodeRequest(protocol.Version2Streaming, &c.id, serviceMethod, params); err != nil {
+	if req, err := encodeRequest(protocol.Version2Streaming, &c.id, serviceMethod, params, 0); err != nil {
 		return err
 	} else if _, err := c.w.Write(req); err != nil {
 		return fmt.Errorf("error writing request: %v", err)
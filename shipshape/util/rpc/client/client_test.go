@@ -0,0 +1,145 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/shipshape/shipshape/util/rpc/server"
+	testutil "github.com/google/shipshape/shipshape/util/test"
+
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+func TestRequestCompression(t *testing.T) {
+	s := server.Service{Name: "AnalyzerService"}
+	if err := s.Register(fakeAnalyzer{}); err != nil {
+		t.Fatalf("Registering analyzer service failed: %v", err)
+	}
+	var encodings []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		server.Endpoint{&s}.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	var files []string
+	for i := 0; i < 100; i++ {
+		files = append(files, fmt.Sprintf("src/main/java/com/example/File%d.java", i))
+	}
+	req := &rpcpb.AnalyzeRequest{ShipshapeContext: &ctxpb.ShipshapeContext{FilePath: files}}
+	c := AnalyzerClient{Client: NewHTTPClient(strings.TrimPrefix(ts.URL, "http://"))}
+	for i := 0; i < 2; i++ {
+		if _, err := c.Analyze(req); err != nil {
+			t.Fatalf("Analyze failed: %v", err)
+		}
+	}
+	// The first request finds out that the server accepts gzip.
+	if got, want := strings.Join(encodings, ","), ",gzip"; got != want {
+		t.Errorf("Wrong request encodings: got %q, want %q", got, want)
+	}
+}
+
+type slowStreamer struct {
+	delay time.Duration
+}
+
+func (s slowStreamer) Run(ctx server.Context, in *rpcpb.GetCategoryRequest, out chan<- *rpcpb.GetCategoryResponse) error {
+	for i := 0; i < 2; i++ {
+		time.Sleep(s.delay)
+		out <- &rpcpb.GetCategoryResponse{Category: []string{fmt.Sprint(i)}}
+	}
+	return nil
+}
+
+func TestStreamHeartbeats(t *testing.T) {
+	addr, cleanup, err := testutil.CreatekRPCTestServer(slowStreamer{200 * time.Millisecond}, "SlowService")
+	if err != nil {
+		t.Fatalf("Registering slow service failed: %v", err)
+	}
+	defer cleanup()
+	c := NewHTTPClient(strings.TrimPrefix(addr, "http://"))
+
+	tests := []struct {
+		opts    StreamOptions
+		results int
+		err     error
+	}{
+		{StreamOptions{Heartbeat: 20 * time.Millisecond, IdleTimeout: 100 * time.Millisecond}, 2, io.EOF},
+		// The idle timeout starts once the response headers, which come with
+		// the first result, have been received.
+		{StreamOptions{IdleTimeout: 100 * time.Millisecond}, 1, ErrIdleTimeout},
+		{StreamOptions{}, 2, io.EOF},
+	}
+	for _, test := range tests {
+		rd := c.StreamWith("/SlowService/Run", &rpcpb.GetCategoryRequest{}, test.opts)
+		results := 0
+		for {
+			var resp rpcpb.GetCategoryResponse
+			if err = rd.NextResult(&resp); err != nil {
+				break
+			}
+			results++
+		}
+		rd.Close()
+		if results != test.results || err != test.err {
+			t.Errorf("%+v: got %d results and %v, want %d results and %v", test.opts, results, err, test.results, test.err)
+		}
+	}
+}
+
+func TestUnixClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unix_client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "service.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Could not listen on %s: %v", path, err)
+	}
+	s := server.Service{Name: "AnalyzerService"}
+	if err := s.Register(fakeAnalyzer{categories: []string{"Foo"}}); err != nil {
+		t.Fatalf("Registering analyzer service failed: %v", err)
+	}
+	go http.Serve(l, server.Endpoint{&s})
+	defer l.Close()
+
+	c := NewUnixClient(path)
+	if err := c.WaitUntilReady(time.Second); err != nil {
+		t.Fatalf("Service on %s did not become ready: %v", path, err)
+	}
+	cats, err := AnalyzerClient{Client: c}.GetCategory()
+	if err != nil {
+		t.Fatalf("GetCategory failed: %v", err)
+	}
+	if len(cats) != 1 || cats[0] != "Foo" {
+		t.Errorf("Wrong categories: got %v, want [Foo]", cats)
+	}
+}
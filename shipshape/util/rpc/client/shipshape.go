@@ -0,0 +1,199 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+)
+
+// The methods of the shipshape service and of analyzer services.
+const (
+	RunMethod         = "/ShipshapeService/Run"
+	CancelMethod      = "/ShipshapeService/Cancel"
+	GetCategoryMethod = "/AnalyzerService/GetCategory"
+	GetStageMethod    = "/AnalyzerService/GetStage"
+	AnalyzeMethod     = "/AnalyzerService/Analyze"
+)
+
+// The methods that get the documentation of a category, from an analyzer and
+// from the shipshape service, which asks the analyzer for it.
+const (
+	GetDocumentationMethod          = "/AnalyzerService/GetDocumentation"
+	ShipshapeGetDocumentationMethod = "/ShipshapeService/GetDocumentation"
+)
+
+// cancelTimeout limits how long ShipshapeClient.Cancel waits for the service.
+const cancelTimeout = 10 * time.Second
+
+// AnalyzerClient calls the methods of an analyzer service, and checks that
+// the responses are well formed.
+type AnalyzerClient struct {
+	*Client
+	// Timeout limits each call, if it is positive.
+	Timeout time.Duration
+}
+
+// GetCategory returns the categories that the analyzer provides.
+func (c AnalyzerClient) GetCategory() ([]string, error) {
+	var resp rpcpb.GetCategoryResponse
+	if err := c.CallTimeout(GetCategoryMethod, &rpcpb.GetCategoryRequest{}, &resp, c.Timeout); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, cat := range resp.Category {
+		if cat == "" {
+			return nil, fmt.Errorf("invalid %s response: empty category name", GetCategoryMethod)
+		}
+		if seen[cat] {
+			return nil, fmt.Errorf("invalid %s response: category %q listed twice", GetCategoryMethod, cat)
+		}
+		seen[cat] = true
+	}
+	return resp.Category, nil
+}
+
+// GetStage returns the stage that the analyzer runs in.
+func (c AnalyzerClient) GetStage() (ctxpb.Stage, error) {
+	var resp rpcpb.GetStageResponse
+	if err := c.CallTimeout(GetStageMethod, &rpcpb.GetStageRequest{}, &resp, c.Timeout); err != nil {
+		return 0, err
+	}
+	if resp.Stage == nil {
+		return 0, fmt.Errorf("invalid %s response: no stage", GetStageMethod)
+	}
+	if _, ok := ctxpb.Stage_name[int32(*resp.Stage)]; !ok {
+		return 0, fmt.Errorf("invalid %s response: unknown stage %d", GetStageMethod, *resp.Stage)
+	}
+	return *resp.Stage, nil
+}
+
+// Analyze runs the analyzer on req.
+func (c AnalyzerClient) Analyze(req *rpcpb.AnalyzeRequest) (*rpcpb.AnalyzeResponse, error) {
+	var resp rpcpb.AnalyzeResponse
+	if err := c.CallTimeout(AnalyzeMethod, req, &resp, c.Timeout); err != nil {
+		return nil, err
+	}
+	if err := validateAnalyzeResponse(&resp); err != nil {
+		return nil, fmt.Errorf("invalid %s response: %v", AnalyzeMethod, err)
+	}
+	return &resp, nil
+}
+
+// GetDocumentation returns the documentation of category, or of its
+// subcategory if that is not empty.
+func (c AnalyzerClient) GetDocumentation(category, subcategory string) (*rpcpb.Documentation, error) {
+	return getDocumentation(c.Client, GetDocumentationMethod, category, subcategory, c.Timeout)
+}
+
+func getDocumentation(c *Client, method, category, subcategory string, timeout time.Duration) (*rpcpb.Documentation, error) {
+	req := &rpcpb.GetDocumentationRequest{Category: proto.String(category)}
+	if subcategory != "" {
+		req.Subcategory = proto.String(subcategory)
+	}
+	var resp rpcpb.GetDocumentationResponse
+	if err := c.CallTimeout(method, req, &resp, timeout); err != nil {
+		return nil, err
+	}
+	if resp.Documentation == nil {
+		return nil, fmt.Errorf("invalid %s response: no documentation", method)
+	}
+	return resp.Documentation, nil
+}
+
+// validateAnalyzeResponse checks that every note and failure in resp is
+// attributed to a category.
+func validateAnalyzeResponse(resp *rpcpb.AnalyzeResponse) error {
+	for _, note := range resp.Note {
+		if note.GetCategory() == "" {
+			return fmt.Errorf("note %q has no category", note.GetDescription())
+		}
+		if r := note.GetLocation().GetRange(); r != nil {
+			if (r.StartOffset == nil) != (r.EndOffset == nil) {
+				return fmt.Errorf("note %q must have both a start and an end offset, or neither", note.GetDescription())
+			}
+			if r.GetStartOffset() < 0 || r.GetEndOffset() < r.GetStartOffset() {
+				return fmt.Errorf("note %q has invalid offsets %d-%d", note.GetDescription(), r.GetStartOffset(), r.GetEndOffset())
+			}
+		}
+	}
+	for _, failure := range resp.Failure {
+		if failure.GetCategory() == "" {
+			return fmt.Errorf("failure %q has no category", failure.GetFailureMessage())
+		}
+	}
+	return nil
+}
+
+// ShipshapeClient calls the methods of the shipshape service.
+type ShipshapeClient struct {
+	*Client
+	// StreamOptions configures the heartbeats and idle timeout of Run.
+	StreamOptions StreamOptions
+}
+
+// Run starts an analysis. Its results can be read from the returned
+// RunReader, which must be closed when no longer used.
+func (c ShipshapeClient) Run(req *rpcpb.ShipshapeRequest) *RunReader {
+	return &RunReader{c.StreamWith(RunMethod, req, c.StreamOptions)}
+}
+
+// Cancel stops the running request with the given request ID, and returns
+// whether it was running. It gives up after cancelTimeout, since it is usually
+// called when the service has stopped responding.
+func (c ShipshapeClient) Cancel(requestID string) (bool, error) {
+	var resp rpcpb.CancelResponse
+	if err := c.CallTimeout(CancelMethod, &rpcpb.CancelRequest{RequestId: proto.String(requestID)}, &resp, cancelTimeout); err != nil {
+		return false, err
+	}
+	return resp.GetCancelled(), nil
+}
+
+// GetDocumentation returns the documentation of category, or of its
+// subcategory if that is not empty, from the analyzer that provides it.
+func (c ShipshapeClient) GetDocumentation(category, subcategory string) (*rpcpb.Documentation, error) {
+	return getDocumentation(c.Client, ShipshapeGetDocumentationMethod, category, subcategory, 0)
+}
+
+// RunReader reads the results of ShipshapeClient.Run.
+type RunReader struct {
+	rd *Reader
+}
+
+// Next returns the next response, or io.EOF if there are no more.
+func (r *RunReader) Next() (*rpcpb.ShipshapeResponse, error) {
+	var resp rpcpb.ShipshapeResponse
+	if err := r.rd.NextResult(&resp); err != nil {
+		return nil, err
+	}
+	for _, ar := range resp.AnalyzeResponse {
+		if err := validateAnalyzeResponse(ar); err != nil {
+			return nil, fmt.Errorf("invalid %s response: %v", RunMethod, err)
+		}
+	}
+	return &resp, nil
+}
+
+// Close discards the rest of the results.
+func (r *RunReader) Close() error {
+	return r.rd.Close()
+}
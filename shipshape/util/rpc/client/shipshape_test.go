@@ -0,0 +1,141 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/shipshape/shipshape/util/rpc/server"
+	testutil "github.com/google/shipshape/shipshape/util/test"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	ctxpb "github.com/google/shipshape/shipshape/proto/shipshape_context_proto"
+	rpcpb "github.com/google/shipshape/shipshape/proto/shipshape_rpc_proto"
+	textpb "github.com/google/shipshape/shipshape/proto/textrange_proto"
+)
+
+type fakeAnalyzer struct {
+	categories []string
+	stage      *ctxpb.Stage
+	notes      []*notepb.Note
+	delay      time.Duration
+}
+
+func (f fakeAnalyzer) GetCategory(ctx server.Context, in *rpcpb.GetCategoryRequest) (*rpcpb.GetCategoryResponse, error) {
+	time.Sleep(f.delay)
+	return &rpcpb.GetCategoryResponse{Category: f.categories}, nil
+}
+
+func (f fakeAnalyzer) GetStage(ctx server.Context, in *rpcpb.GetStageRequest) (*rpcpb.GetStageResponse, error) {
+	return &rpcpb.GetStageResponse{Stage: f.stage}, nil
+}
+
+func (f fakeAnalyzer) Analyze(ctx server.Context, in *rpcpb.AnalyzeRequest) (*rpcpb.AnalyzeResponse, error) {
+	return &rpcpb.AnalyzeResponse{Note: f.notes}, nil
+}
+
+func newAnalyzerClient(t *testing.T, f fakeAnalyzer, timeout time.Duration) (AnalyzerClient, func()) {
+	addr, cleanup, err := testutil.CreatekRPCTestServer(f, "AnalyzerService")
+	if err != nil {
+		t.Fatalf("Registering analyzer service failed: %v", err)
+	}
+	return AnalyzerClient{NewHTTPClient(strings.TrimPrefix(addr, "http://")), timeout}, cleanup
+}
+
+func TestAnalyzerClient(t *testing.T) {
+	c, cleanup := newAnalyzerClient(t, fakeAnalyzer{
+		categories: []string{"Foo", "Bar"},
+		stage:      ctxpb.Stage_POST_BUILD.Enum(),
+		notes:      []*notepb.Note{{Category: proto.String("Foo"), Description: proto.String("A note")}},
+	}, 0)
+	defer cleanup()
+
+	cats, err := c.GetCategory()
+	if err != nil {
+		t.Fatalf("GetCategory failed: %v", err)
+	}
+	if len(cats) != 2 || cats[0] != "Foo" || cats[1] != "Bar" {
+		t.Errorf("Wrong categories: got %v, want [Foo Bar]", cats)
+	}
+	stage, err := c.GetStage()
+	if err != nil {
+		t.Fatalf("GetStage failed: %v", err)
+	}
+	if got, want := stage, ctxpb.Stage_POST_BUILD; got != want {
+		t.Errorf("Wrong stage: got %v, want %v", got, want)
+	}
+	resp, err := c.Analyze(&rpcpb.AnalyzeRequest{Category: []string{"Foo"}})
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if got, want := len(resp.Note), 1; got != want {
+		t.Errorf("Wrong number of notes: got %d, want %d", got, want)
+	}
+}
+
+func TestAnalyzerClientInvalidResponses(t *testing.T) {
+	c, cleanup := newAnalyzerClient(t, fakeAnalyzer{
+		categories: []string{"Foo", "Foo"},
+		notes:      []*notepb.Note{{Description: proto.String("No category")}},
+	}, 0)
+	defer cleanup()
+
+	if _, err := c.GetCategory(); err == nil {
+		t.Errorf("Expected an error for duplicate categories")
+	}
+	if _, err := c.GetStage(); err == nil {
+		t.Errorf("Expected an error for a missing stage")
+	}
+	if _, err := c.Analyze(&rpcpb.AnalyzeRequest{}); err == nil {
+		t.Errorf("Expected an error for a note without a category")
+	}
+}
+
+func TestValidateNoteOffsets(t *testing.T) {
+	tests := []struct {
+		r     *textpb.TextRange
+		valid bool
+	}{
+		{&textpb.TextRange{StartLine: proto.Int32(3)}, true},
+		{&textpb.TextRange{StartOffset: proto.Int32(0), EndOffset: proto.Int32(12)}, true},
+		{&textpb.TextRange{StartOffset: proto.Int32(4), EndOffset: proto.Int32(4)}, true},
+		{&textpb.TextRange{StartOffset: proto.Int32(4)}, false},
+		{&textpb.TextRange{StartOffset: proto.Int32(8), EndOffset: proto.Int32(4)}, false},
+	}
+	for _, test := range tests {
+		resp := &rpcpb.AnalyzeResponse{Note: []*notepb.Note{{
+			Category:    proto.String("Foo"),
+			Description: proto.String("A note"),
+			Location:    &notepb.Location{Path: proto.String("a.go"), Range: test.r},
+		}}}
+		if err := validateAnalyzeResponse(resp); (err == nil) != test.valid {
+			t.Errorf("validateAnalyzeResponse with range %v: got error %v, want valid = %v", test.r, err, test.valid)
+		}
+	}
+}
+
+func TestAnalyzerClientTimeout(t *testing.T) {
+	c, cleanup := newAnalyzerClient(t, fakeAnalyzer{delay: time.Second}, 10*time.Millisecond)
+	defer cleanup()
+
+	if _, err := c.GetCategory(); err != ErrTimedOut {
+		t.Errorf("Wrong error for a slow call: got %v, want %v", err, ErrTimedOut)
+	}
+}
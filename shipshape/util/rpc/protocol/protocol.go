@@ -72,6 +72,12 @@ type Request struct {
 	// Params is a JSON encoded object or array that holds the parameter values to
 	// be used during the invocation of the method. This member MAY be omitted.
 	Params json.RawMessage `json:"params,omitempty"`
+
+	// HeartbeatMillis asks the server to send a heartbeat response every this
+	// many milliseconds while a Version2Streaming call is running, so that
+	// the connection is not idle while results are being computed. Servers
+	// that don't support heartbeats ignore it.
+	HeartbeatMillis int64 `json:"heartbeat_ms,omitempty"`
 }
 
 // CheckID returns an error if the given JSON is not a valid ID
@@ -107,6 +113,10 @@ type Response struct {
 	Error *Error `json:"error,omitempty"`
 
 	Success bool `json:"success,omitempty"`
+
+	// Heartbeat marks a response that only shows that the call is still
+	// running. It has no result, and clients skip it.
+	Heartbeat bool `json:"heartbeat,omitempty"`
 }
 
 // Error is a descriptor object for an RPC error
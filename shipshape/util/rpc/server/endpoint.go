@@ -25,6 +25,8 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/shipshape/shipshape/util/httpencoding"
 	"github.com/google/shipshape/shipshape/util/rpc/protocol"
@@ -93,6 +95,9 @@ type responseWriter struct {
 	req *protocol.Request
 	en  *json.Encoder
 
+	// mu serializes writes, since heartbeats are written concurrently with
+	// results.
+	mu       sync.Mutex
 	results  uint
 	finished bool
 }
@@ -102,6 +107,8 @@ func newResponseWriter(req *protocol.Request, en *json.Encoder) *responseWriter
 }
 
 func (w *responseWriter) write(resp *protocol.Response) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	if w.results > 0 && w.req.Version != protocol.Version2Streaming {
 		return errors.New("attempt to write multiple results in non-streaming protocol")
 	} else if w.finished {
@@ -138,6 +145,44 @@ func (w *responseWriter) Result(result json.RawMessage) error {
 	return w.write(&protocol.Response{Result: result})
 }
 
+// heartbeat writes a streaming heartbeat response. Heartbeats are not counted
+// as results.
+func (w *responseWriter) heartbeat() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.finished {
+		return nil
+	}
+	return w.en.Encode(&protocol.Response{ID: w.req.ID, Version: w.req.Version, Heartbeat: true})
+}
+
+// startHeartbeats writes a heartbeat every interval until the returned func
+// is called.
+func (w *responseWriter) startHeartbeats(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan bool)
+	stopped := make(chan bool)
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-ticker.C:
+				if err := w.heartbeat(); err != nil {
+					log.Printf("Could not write heartbeat: %v", err)
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+		<-stopped
+	}
+}
+
 // Success writes a streaming success response
 func (w *responseWriter) Success() error {
 	if err := w.write(&protocol.Response{Success: true}); err != nil {
@@ -239,6 +284,9 @@ func (e Endpoint) handleRequest(ctx Context, de *json.Decoder, en *json.Encoder)
 	}
 
 	// Invoke method with params
+	if req.Version == protocol.Version2Streaming && req.HeartbeatMillis > 0 {
+		defer wr.startHeartbeats(time.Duration(req.HeartbeatMillis) * time.Millisecond)()
+	}
 	err = method.Invoke(ctx, req.Params, out)
 	if outErr != nil {
 		panic(outErr)
@@ -280,6 +328,20 @@ func (e Endpoint) handleRequest(ctx Context, de *json.Decoder, en *json.Encoder)
 	panic("unexpected end of request handler")
 }
 
+// flushEncoder flushes each response to the client as soon as it is encoded,
+// so that streamed results are not held back by the compressor.
+type flushEncoder struct {
+	w httpencoding.FlushWriteCloser
+}
+
+func (f flushEncoder) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, f.w.Flush()
+}
+
 // ServePipes implements the rpc protocol over an input and output stream
 func (e Endpoint) ServePipes(ctx Context, r io.Reader, w io.Writer) error {
 	de := json.NewDecoder(r)
@@ -301,11 +363,18 @@ func (e Endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	body, err := httpencoding.UncompressRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	defer body.Close()
+
 	cw := httpencoding.CompressData(w, r)
 	defer cw.Close()
 
-	de := json.NewDecoder(r.Body)
-	en := json.NewEncoder(cw)
+	de := json.NewDecoder(body)
+	en := json.NewEncoder(flushEncoder{cw})
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	if err := e.handleRequest(r.Header, de, en); err != nil {
 		log.Printf("HTTP RPC Error: %v", err)
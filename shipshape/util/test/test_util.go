@@ -109,6 +109,66 @@ func CheckNoteContainsContent(expected []*notepb.Note, actual []*notepb.Note) (b
 	return false, fmt.Sprintf("Had unmatched results.\nExpect: %v\nActual: %v", unmatched, remaining)
 }
 
+// NoteKey identifies a note by its category and where it is. Descriptions are
+// left out, since they change between versions of an analyzer.
+type NoteKey struct {
+	Category string
+	Path     string
+	Line     int32
+}
+
+// KeyOf returns the NoteKey of note. Notes without a location have an empty
+// path and line 0.
+func KeyOf(note *notepb.Note) NoteKey {
+	return NoteKey{
+		Category: note.GetCategory(),
+		Path:     note.GetLocation().GetPath(),
+		Line:     note.GetLocation().GetRange().GetStartLine(),
+	}
+}
+
+// CheckNotesPresent checks that actual has a note for each of the expected
+// keys, with as many notes as the key is repeated in expected. Unlike
+// CheckNoteContainsContent, other notes are allowed, so that a test can check
+// the notes it cares about without depending on everything an analyzer finds.
+// Returns whether every key was found, and if not, a message to print to the
+// tests of the missing keys.
+func CheckNotesPresent(expected []NoteKey, actual []*notepb.Note) (bool, string) {
+	found := make(map[NoteKey]int)
+	for _, note := range actual {
+		found[KeyOf(note)]++
+	}
+	var missing []NoteKey
+	for _, key := range expected {
+		if found[key] == 0 {
+			missing = append(missing, key)
+			continue
+		}
+		found[key]--
+	}
+	if len(missing) == 0 {
+		return true, ""
+	}
+	return false, fmt.Sprintf("Missing notes.\nExpect: %+v\nActual: %v", missing, actual)
+}
+
+// CheckNoteCount checks that the number of notes of category in actual is
+// within tolerance of want, so that a test doesn't break each time an
+// analyzer finds one more or one fewer issue. Returns whether the count is in
+// range, and if not, a message to print to the tests.
+func CheckNoteCount(actual []*notepb.Note, category string, want, tolerance int) (bool, string) {
+	got := 0
+	for _, note := range actual {
+		if note.GetCategory() == category {
+			got++
+		}
+	}
+	if got >= want-tolerance && got <= want+tolerance {
+		return true, ""
+	}
+	return false, fmt.Sprintf("Wrong number of %s notes: got %d, want %d (+/- %d)", category, got, want, tolerance)
+}
+
 // CheckFailureContainsContent checks that there is a 1:1 mapping from expected
 // failures to actual faulres. The description in the execpted failure needs
 // only to be a substring of the actual failure. Returns whether such a 1:1
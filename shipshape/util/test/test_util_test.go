@@ -0,0 +1,79 @@
+/*
+ * Copyright 2015 Google Inc. All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package test
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	notepb "github.com/google/shipshape/shipshape/proto/note_proto"
+	rangepb "github.com/google/shipshape/shipshape/proto/textrange_proto"
+)
+
+func note(category, path string, line int32) *notepb.Note {
+	n := &notepb.Note{Category: proto.String(category), Description: proto.String("A note")}
+	if path != "" {
+		n.Location = &notepb.Location{Path: proto.String(path), Range: &rangepb.TextRange{StartLine: proto.Int32(line)}}
+	}
+	return n
+}
+
+func TestCheckNotesPresent(t *testing.T) {
+	actual := []*notepb.Note{
+		note("PostMessage", "", 0),
+		note("PostMessage", "", 0),
+		note("PyLint", "a.py", 2),
+		note("PyLint", "a.py", 7),
+	}
+	tests := []struct {
+		desc     string
+		expected []NoteKey
+		ok       bool
+	}{
+		{"none", nil, true},
+		{"some", []NoteKey{{"PyLint", "a.py", 2}}, true},
+		{"repeated", []NoteKey{{Category: "PostMessage"}, {Category: "PostMessage"}}, true},
+		{"too many repeats", []NoteKey{{Category: "PostMessage"}, {Category: "PostMessage"}, {Category: "PostMessage"}}, false},
+		{"wrong line", []NoteKey{{"PyLint", "a.py", 3}}, false},
+		{"wrong category", []NoteKey{{"JSHint", "a.py", 2}}, false},
+	}
+	for _, test := range tests {
+		if ok, msg := CheckNotesPresent(test.expected, actual); ok != test.ok {
+			t.Errorf("%s: got %v (%s), want %v", test.desc, ok, msg, test.ok)
+		}
+	}
+}
+
+func TestCheckNoteCount(t *testing.T) {
+	actual := []*notepb.Note{note("PyLint", "a.py", 1), note("PyLint", "a.py", 2), note("JSHint", "a.js", 1)}
+	tests := []struct {
+		want, tolerance int
+		ok              bool
+	}{
+		{2, 0, true},
+		{3, 0, false},
+		{3, 1, true},
+		{1, 1, true},
+		{0, 1, false},
+	}
+	for _, test := range tests {
+		if ok, msg := CheckNoteCount(actual, "PyLint", test.want, test.tolerance); ok != test.ok {
+			t.Errorf("CheckNoteCount(want %d, tolerance %d): got %v (%s), want %v", test.want, test.tolerance, ok, msg, test.ok)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+def _shipshape_aspect_impl(target, ctx):
+  srcs = set()
+  if hasattr(ctx.rule.files, "srcs"):
+    srcs += ctx.rule.files.srcs
+  if hasattr(ctx.rule.files, "hdrs"):
+    srcs += ctx.rule.files.hdrs
+  return struct(shipshape_srcs = srcs)
+
+# shipshape_aspect collects the sources of a target, including the generated
+# ones, for shipshape_test to analyze.
+shipshape_aspect = aspect(_shipshape_aspect_impl)
+
+def _quote(s):
+  return "'" + s.replace("'", "'\\''") + "'"
+
+def shipshape_test_impl(ctx):
+  target = ctx.attr.target
+  srcs = list(target.shipshape_srcs)
+  compilations = ctx.files.compilations
+
+  args = ["--snapshot_dir=", "--progress=false"]
+  if ctx.attr.categories:
+    args += ["--categories=" + ",".join(ctx.attr.categories)]
+  if ctx.attr.analyzers:
+    args += ["--analyzer_images=" + ",".join(ctx.attr.analyzers)]
+  if ctx.attr.event:
+    args += ["--event=" + ctx.attr.event]
+  args += ctx.attr.args_
+
+  cmd = "\n".join([
+      "#!/bin/bash",
+      "set -e",
+      "workspace=\"${TEST_TMPDIR}/workspace\"",
+      "rm -rf \"$workspace\"",
+      "mkdir -p \"$workspace\"",
+      "for src in %s; do" % " ".join([_quote(f.short_path) for f in srcs]),
+      "  mkdir -p \"$workspace/$(dirname \"$src\")\"",
+      "  cp -L \"$src\" \"$workspace/$src\"",
+      "done",
+  ])
+  if ctx.file.config:
+    cmd += "\ncp -L %s \"$workspace/.shipshape\"" % _quote(ctx.file.config.short_path)
+  args_cmd = "args=(%s)" % " ".join([_quote(a) for a in args])
+  if compilations:
+    cmd += "\n".join([
+        "",
+        "units=\"${TEST_TMPDIR}/compilations\"",
+        "rm -rf \"$units\"",
+        "mkdir -p \"$units\"",
+        "cp -L %s \"$units\"" % " ".join([_quote(f.short_path) for f in compilations]),
+    ])
+    args_cmd += "\nargs+=(\"--import_compilations=$units\")"
+  cmd += "\n".join([
+      "",
+      args_cmd,
+      "if [[ -n \"${TEST_UNDECLARED_OUTPUTS_DIR}\" ]]; then",
+      "  args+=(\"--json_output=${TEST_UNDECLARED_OUTPUTS_DIR}/shipshape.json\")",
+      "fi",
+      # The exit status is 0 without notes, 1 with notes and 2 on errors, so
+      # the test fails if there are any notes.
+      "exec %s \"${args[@]}\" \"$workspace\"" % _quote(ctx.executable._shipshape.short_path),
+      "",
+  ])
+  ctx.file_action(
+      output = ctx.outputs.executable,
+      content = cmd,
+      executable = True)
+
+  runfiles = ctx.runfiles(
+      files = srcs + compilations + ctx.files.config + [ctx.executable._shipshape],
+      collect_data = True)
+  return struct(runfiles = runfiles)
+
+_shipshape_test = rule(
+    shipshape_test_impl,
+    attrs = {
+        "target": attr.label(
+            mandatory = True,
+            aspects = [shipshape_aspect],
+        ),
+        "compilations": attr.label_list(allow_files = FileType([".kindex", ".kzip"])),
+        "config": attr.label(
+            allow_files = True,
+            single_file = True,
+        ),
+        "categories": attr.string_list(),
+        "analyzers": attr.string_list(),
+        "event": attr.string(),
+        "args_": attr.string_list(),
+        "_shipshape": attr.label(
+            default = Label("//shipshape/cli:shipshape"),
+            executable = True,
+        ),
+    },
+    executable = True,
+    test = True,
+)
+
+def shipshape_test(name, target, compilations=[], config=None, categories=[],
+                   analyzers=[], event="", args=[], tags=[], **kwargs):
+  """Analyzes the sources of target with shipshape, failing if there are notes.
+
+  The sources are copied into a fresh workspace, along with config as its
+  .shipshape file, and analyzed by the shipshape CLI with the categories and
+  third-party analyzer images given. The compilation units that the sources of
+  target were extracted to, e.g. by a kythe extractor, are analyzed in the
+  POST_BUILD stage. Extra CLI flags can be passed in args. The JSON results
+  are kept with the test outputs.
+
+  The tests talk to docker on the host and share the shipshape containers on
+  it, so they are local and exclusive: Bazel runs them one at a time, and only
+  reruns those whose inputs changed.
+  """
+  _shipshape_test(
+      name = name,
+      target = target,
+      compilations = compilations,
+      config = config,
+      categories = categories,
+      analyzers = analyzers,
+      event = event,
+      args_ = args,
+      tags = tags + ["local", "exclusive", "shipshape"],
+      **kwargs
+  )